@@ -0,0 +1,13 @@
+// Command binance_recorder records Binance order book deltas; it is "helix
+// record binance-l2".
+package main
+
+import (
+	"os"
+
+	"github.com/helix-lab/helix/gateway/internal/app/l2recorder"
+)
+
+func main() {
+	os.Exit(l2recorder.BinanceMain(os.Args[1:]))
+}
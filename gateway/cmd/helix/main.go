@@ -0,0 +1,186 @@
+// Command helix is the single entry point for the gateway tools:
+//
+//	helix gateway            run the market-data gateway
+//	helix record l2|trades   record Bybit order book deltas or trades to CSV
+//	helix record binance-l2  record Binance order book deltas to the same CSV
+//	helix record okx-l2      record OKX order book deltas, checksum-checked
+//	helix record kraken      record Kraken Futures order book deltas and trades
+//	helix record sync        record one symbol's books from several venues in step
+//	helix replay             replay a captured frame log
+//	helix bookcheck          rebuild top-of-book from a recorded L2 CSV
+//	helix crosscheck         compare a depth-1 capture's top with a deeper one's
+//	helix downsample         sample an L2 CSV's top-of-book at a fixed cadence
+//	helix heatmap            export an L2 CSV's liquidity as a time×price matrix
+//	helix backtest           run strategies over recorded captures
+//	helix makerfill          ask whether a resting order would have filled
+//	helix validate           check a gateway config file or recorded capture
+//	helix verify             check captures against their sealed checksums
+//	helix catalog            list or serve recorded captures and their lineage
+//	helix gc                 age captures out of a data directory by retention policy
+//	helix merge              merge overlapping captures from redundant recorders
+//	helix slice              cut a time or seq window out of a capture
+//	helix convert            convert CSV captures to Parquet
+//	helix download           backfill trades or klines from exchange archives
+//	helix journal            replay a gateway event journal into its state
+//	helix secrets            manage and check API credentials
+//	helix bench              run the performance regression benchmarks
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/internal/app"
+	"github.com/helix-lab/helix/gateway/internal/app/backtest"
+	benchcmd "github.com/helix-lab/helix/gateway/internal/app/bench"
+	"github.com/helix-lab/helix/gateway/internal/app/bookcheck"
+	catalogcmd "github.com/helix-lab/helix/gateway/internal/app/catalog"
+	"github.com/helix-lab/helix/gateway/internal/app/convert"
+	"github.com/helix-lab/helix/gateway/internal/app/crosscheck"
+	"github.com/helix-lab/helix/gateway/internal/app/download"
+	"github.com/helix-lab/helix/gateway/internal/app/downsample"
+	"github.com/helix-lab/helix/gateway/internal/app/gateway"
+	"github.com/helix-lab/helix/gateway/internal/app/gc"
+	"github.com/helix-lab/helix/gateway/internal/app/heatmap"
+	"github.com/helix-lab/helix/gateway/internal/app/journal"
+	"github.com/helix-lab/helix/gateway/internal/app/l2recorder"
+	"github.com/helix-lab/helix/gateway/internal/app/makerfill"
+	"github.com/helix-lab/helix/gateway/internal/app/merge"
+	"github.com/helix-lab/helix/gateway/internal/app/replay"
+	"github.com/helix-lab/helix/gateway/internal/app/secrets"
+	"github.com/helix-lab/helix/gateway/internal/app/slice"
+	"github.com/helix-lab/helix/gateway/internal/app/syncrecord"
+	"github.com/helix-lab/helix/gateway/internal/app/tradeshttp"
+	"github.com/helix-lab/helix/gateway/internal/app/tradesrecorder"
+	"github.com/helix-lab/helix/gateway/internal/app/verify"
+	"github.com/helix-lab/helix/gateway/pkg/catalog"
+	"github.com/helix-lab/helix/gateway/pkg/config"
+)
+
+var commands = []app.Command{
+	{Name: "gateway", Summary: "run the market-data gateway", Main: gateway.Main},
+	{Name: "record", Summary: "record Bybit data: l2, trades or trades-http; Binance: binance-l2; OKX: okx-l2; Kraken Futures: kraken", Main: record},
+	{Name: "replay", Summary: "replay a frame log captured with gateway -tap", Main: replay.Main},
+	{Name: "bookcheck", Summary: "rebuild sampled top-of-book from an L2 CSV", Main: bookcheck.Main},
+	{Name: "crosscheck", Summary: "cross-validate a shallow L2 feed's top against a deeper feed's rebuilt book", Main: crosscheck.Main},
+	{Name: "downsample", Summary: "sample top-of-book or top-N levels of an L2 CSV every interval", Main: downsample.Main},
+	{Name: "heatmap", Summary: "export an L2 CSV's liquidity per time and price bucket as CSV, Parquet or PNG", Main: heatmap.Main},
+	{Name: "backtest", Summary: "run strategies over recorded L2, trades and frame logs", Main: backtest.Main},
+	{Name: "makerfill", Summary: "replay captures against a hypothetical resting order and report if and when it fills", Main: makerfill.Main},
+	{Name: "validate", Summary: "validate a gateway config or a recorded CSV capture", Main: validate},
+	{Name: "verify", Summary: "recompute capture SHA-256s and compare them with their sidecars", Main: verify.Main},
+	{Name: "catalog", Summary: "list or serve recorded captures with lineage and validation", Main: catalogcmd.Main},
+	{Name: "gc", Summary: "remove captures past their retention, keeping unvalidated or unuploaded ones", Main: gc.Main},
+	{Name: "merge", Summary: "merge overlapping L2 or trades captures of one symbol", Main: merge.Main},
+	{Name: "slice", Summary: "extract a time or seq window of a capture, starting from a valid book", Main: slice.Main},
+	{Name: "convert", Summary: "convert L2, trades and bookcheck CSVs to Parquet", Main: convert.Main},
+	{Name: "download", Summary: "backfill Bybit or Binance trades and klines from their public archives", Main: download.Main},
+	{Name: "journal", Summary: "replay a gateway event journal to its books, orders and positions at any event", Main: journal.Main},
+	{Name: "secrets", Summary: "create keys, seal and check API credentials", Main: secrets.Main},
+	{Name: "bench", Summary: "benchmark book apply, JSON decode, CSV write, route and publish; JSON results", Main: benchcmd.Main},
+}
+
+var recorders = []app.Command{
+	{Name: "l2", Summary: "order book deltas over websocket", Main: l2recorder.Main},
+	{Name: "binance-l2", Summary: "Binance order book deltas over websocket, synced to REST snapshots", Main: l2recorder.BinanceMain},
+	{Name: "okx-l2", Summary: "OKX order book deltas over websocket, resubscribed on checksum mismatch", Main: l2recorder.OKXMain},
+	{Name: "kraken", Summary: "Kraken Futures order book deltas and trades over websocket, resubscribed on seq gaps", Main: l2recorder.KrakenMain},
+	{Name: "trades", Summary: "public trades over websocket", Main: tradesrecorder.Main},
+	{Name: "trades-http", Summary: "public trades by polling the REST API", Main: tradeshttp.Main},
+	{Name: "sync", Summary: "order book deltas of one symbol from several venues over one shared window", Main: syncrecord.Main},
+}
+
+func main() {
+	os.Exit(dispatch("helix", commands, os.Args[1:]))
+}
+
+func dispatch(prog string, cmds []app.Command, args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		usage(prog, cmds)
+		if len(args) == 0 {
+			return 2
+		}
+		return 0
+	}
+	for _, c := range cmds {
+		if c.Name == args[0] {
+			return c.Main(args[1:])
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%s: unknown command %q\n\n", prog, args[0])
+	usage(prog, cmds)
+	return 2
+}
+
+func usage(prog string, cmds []app.Command) {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags]\n\ncommands:\n", prog)
+	for _, c := range cmds {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", c.Name, c.Summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for the command's flags.\n", prog)
+}
+
+func record(args []string) int {
+	return dispatch("helix record", recorders, args)
+}
+
+func validate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	var common app.Common
+	common.Register(fs)
+	gap := fs.Duration("gap", catalog.DefaultGap, "Count row-to-row silences of at least this long in captures")
+	if code := common.Parse(fs, args); code >= 0 {
+		return code
+	}
+	defer common.Close()
+	paths := fs.Args()
+	if common.ConfigPath != "" {
+		paths = append([]string{common.ConfigPath}, paths...)
+	}
+	if len(paths) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: helix validate [-config] [-gap] <config or capture.csv>...")
+		return 2
+	}
+	code := 0
+	for _, p := range paths {
+		if strings.EqualFold(filepath.Ext(p), ".csv") {
+			if !validateCapture(p, *gap) {
+				code = 1
+			}
+			continue
+		}
+		cfg, err := config.Load(p)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			code = 1
+			continue
+		}
+		g := cfg.Gateway
+		fmt.Printf("%s: ok (%d venues, symbols %v)\n", p, len(g.Venues), g.AllSymbols())
+	}
+	return code
+}
+
+// validateCapture checks a recorded CSV and records the result in its
+// validation sidecar for the catalog.
+func validateCapture(path string, gap time.Duration) bool {
+	v, err := catalog.Validate(path, gap)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return false
+	}
+	if err := catalog.WriteValidation(path, v); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return false
+	}
+	fmt.Printf("%s: %s (%d rows, %d gaps of %s or more, longest %s)\n", path, v.Status, v.Rows, v.Gaps,
+		gap, time.Duration(v.MaxGapMs)*time.Millisecond)
+	for _, e := range v.Errors {
+		fmt.Printf("  %s\n", e)
+	}
+	return v.Status == catalog.StatusOK
+}
@@ -0,0 +1,13 @@
+// Command kraken_recorder records Kraken Futures order book deltas and
+// trades; it is "helix record kraken".
+package main
+
+import (
+	"os"
+
+	"github.com/helix-lab/helix/gateway/internal/app/l2recorder"
+)
+
+func main() {
+	os.Exit(l2recorder.KrakenMain(os.Args[1:]))
+}
@@ -0,0 +1,13 @@
+// Command okx_recorder records OKX order book deltas; it is "helix record
+// okx-l2".
+package main
+
+import (
+	"os"
+
+	"github.com/helix-lab/helix/gateway/internal/app/l2recorder"
+)
+
+func main() {
+	os.Exit(l2recorder.OKXMain(os.Args[1:]))
+}
@@ -0,0 +1,186 @@
+// Package app holds what every helix subcommand shares: the -config and
+// logging flags and how they are applied.
+package app
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/config"
+	"github.com/helix-lab/helix/gateway/pkg/router"
+	"github.com/helix-lab/helix/gateway/pkg/strategy"
+)
+
+// Exit codes shared by the subcommands, so supervisors can tell a bad
+// config from a lost feed.
+const (
+	ExitOK      = 0
+	ExitFailure = 1
+	ExitUsage   = 2
+	ExitConfig  = 3
+	// ExitStartup: a listener, output file or exporter could not be set up.
+	ExitStartup = 4
+	// ExitFeedLost: every feed stayed silent for longer than allowed.
+	ExitFeedLost = 5
+)
+
+// Command is one helix subcommand; Main returns the process exit code.
+type Command struct {
+	Name    string
+	Summary string
+	Main    func(args []string) int
+}
+
+// Common are the flags every subcommand accepts.
+type Common struct {
+	ConfigPath string
+	LogFile    string
+
+	logFile *os.File
+}
+
+func (c *Common) Register(fs *flag.FlagSet) {
+	fs.StringVar(&c.ConfigPath, "config", "", "Gateway config file (YAML or JSON)")
+	fs.StringVar(&c.LogFile, "log_file", "", "Append logs to this file instead of stderr")
+}
+
+// Parse parses args and applies the logging flags. It returns the exit code
+// to use when parsing failed (0 for -h), or -1 to continue.
+func (c *Common) Parse(fs *flag.FlagSet, args []string) int {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
+	if c.LogFile != "" {
+		f, err := os.OpenFile(c.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			log.Printf("open log file: %v", err)
+			return 1
+		}
+		c.logFile = f
+		log.SetOutput(f)
+	}
+	return -1
+}
+
+// Close restores stderr logging and closes the log file.
+func (c *Common) Close() {
+	if c.logFile != nil {
+		log.SetOutput(os.Stderr)
+		c.logFile.Close()
+		c.logFile = nil
+	}
+}
+
+// Config loads -config, or returns nil when it was not given.
+func (c *Common) Config() (*config.File, error) {
+	if c.ConfigPath == "" {
+		return nil, nil
+	}
+	return config.Load(c.ConfigPath)
+}
+
+// IsSet reports whether name was given explicitly on the command line, so
+// config values only fill in flags the user left alone.
+func IsSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// BybitVenue returns the first live Bybit venue in cfg.
+func BybitVenue(cfg *config.File) (config.Venue, bool) {
+	if cfg == nil {
+		return config.Venue{}, false
+	}
+	for _, v := range cfg.Gateway.Venues {
+		if v.Kind == config.KindBybit {
+			return v, true
+		}
+	}
+	return config.Venue{}, false
+}
+
+// BybitDefaults fills -endpoint, -symbol and (when depth is non-nil)
+// -depth from the config's Bybit venue, unless they were given explicitly.
+func (c *Common) BybitDefaults(fs *flag.FlagSet, endpoint, symbol *string, depth *int) error {
+	cfg, err := c.Config()
+	if err != nil {
+		return err
+	}
+	v, ok := BybitVenue(cfg)
+	if !ok {
+		return nil
+	}
+	if !IsSet(fs, "endpoint") && v.WSPublic != "" {
+		*endpoint = v.WSPublic
+	}
+	if !IsSet(fs, "symbol") && len(v.Symbols) > 0 {
+		*symbol = v.Symbols[0]
+	}
+	if depth != nil && !IsSet(fs, "depth") {
+		*depth = v.SymbolDepth(*symbol)
+	}
+	return nil
+}
+
+// Fees takes maker and taker fees from the config, keeping the built-in schedule
+// for venues that don't set one.
+func Fees(g config.Gateway) router.FeeModel {
+	fees := router.DefaultFees()
+	for _, v := range g.Venues {
+		if v.Fees.TakerBps > 0 {
+			fees.Taker[v.Name] = v.Fees.TakerBps / 1e4
+		}
+		if v.Fees.MakerBps > 0 {
+			fees.Maker[v.Name] = v.Fees.MakerBps / 1e4
+		}
+	}
+	return fees
+}
+
+// Strategies builds the configured strategies and passes each to add.
+func Strategies(list []config.Strategy, add func(strategy.Config, strategy.Strategy)) error {
+	for _, sc := range list {
+		cfg := strategy.Config{Name: sc.Name, Symbols: sc.Symbols, Timer: time.Duration(sc.Timer), Params: sc.Params, Quota: Quota(sc.Quota)}
+		s, err := strategy.New(sc.Kind, cfg)
+		if err != nil {
+			return fmt.Errorf("%s: %w", sc.Name, err)
+		}
+		add(cfg, s)
+	}
+	return nil
+}
+
+// Quota is a configured strategy quota.
+func Quota(q config.Quota) strategy.Quota {
+	return strategy.Quota{MaxOrdersPerSec: q.MaxOrdersPerSec, MaxOpenNotional: q.MaxOpenNotional, MaxPosition: q.MaxPosition}
+}
+
+// MsFlag is a flag.Func parsing RFC 3339 or Unix milliseconds into *ms.
+func MsFlag(ms *int64) func(string) error {
+	return func(v string) error {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			*ms = n
+			return nil
+		}
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return err
+		}
+		*ms = t.UnixMilli()
+		return nil
+	}
+}
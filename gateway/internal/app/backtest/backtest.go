@@ -0,0 +1,281 @@
+// Package backtest implements "helix backtest": run the configured
+// strategies over recorded L2, trades and frame-log captures.
+package backtest
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/internal/app"
+	"github.com/helix-lab/helix/gateway/pkg/backtest"
+	"github.com/helix-lab/helix/gateway/pkg/catalog"
+	"github.com/helix-lab/helix/gateway/pkg/config"
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+)
+
+// Main runs "helix backtest" with args (without the subcommand name).
+func Main(args []string) int {
+	fs := flag.NewFlagSet("backtest", flag.ContinueOnError)
+	var common app.Common
+	common.Register(fs)
+	l2 := fs.String("l2", "", "L2 delta CSV written by 'helix record l2', or the .run.json of a rotated recording")
+	trades := fs.String("trades", "", "Trades CSV written by 'helix record trades'")
+	frames := fs.String("frames", "", "Frame log written by gateway -tap, or a raw frame -archive")
+	venue := fs.String("venue", "BYBIT", "Venue the -l2 and -trades captures were recorded on")
+	symbol := fs.String("symbol", "BTCUSDT", "Symbol the -l2 and -trades captures were recorded for")
+	latencyAssumed := fs.Duration("latency", 0, "Simulated delay from order submit to execution")
+	tradesOut := fs.String("trades_out", "", "Write the simulated fills to this CSV")
+	reportOut := fs.String("report", "", "Write a JSON report (PnL curve, fill quality, drawdown) to this file")
+	reportHTML := fs.String("report_html", "", "Also render the report as HTML to this file")
+	requireValidated := fs.Bool("require_validated", false, "Refuse -l2 and -trades captures that 'helix validate' has not passed")
+	verify := fs.Bool("verify", false, "Refuse -l2 and -trades captures whose sidecar checksums are missing or do not match")
+	allowGaps := fs.Bool("allow_gaps", false, "Load rotated runs whose segments do not chain by seq")
+	sweep := fs.String("latency_sweep", "", "Comma-separated latencies to rerun for a sensitivity table, e.g. 0,5ms,50ms")
+	profile := fs.String("profile", "", "Run under this latency profile instead of -latency: colo, cloud, retail or one from the config")
+	profileSweep := fs.String("profile_sweep", "", "Comma-separated latency profiles, or all, to rerun for the sensitivity table")
+	if code := common.Parse(fs, args); code >= 0 {
+		return code
+	}
+	defer common.Close()
+	if *l2 == "" && *frames == "" {
+		fmt.Fprintln(os.Stderr, "backtest: need -l2 or -frames")
+		return app.ExitUsage
+	}
+	cfg, err := common.Config()
+	if err != nil {
+		log.Printf("config: %v", err)
+		return app.ExitConfig
+	}
+	if cfg == nil {
+		cfg = config.Default()
+	}
+	gw := cfg.Gateway
+
+	var streams [][]backtest.Event
+	// check vets an -l2 or -trades capture before it is loaded.
+	check := func(path string) error {
+		if *requireValidated {
+			ds, ok, err := catalog.Inspect(path)
+			if err == nil && !ok {
+				err = errors.New("not an L2 or trades capture")
+			}
+			if err == nil && ds.Status != catalog.StatusOK {
+				err = fmt.Errorf("validation status %s", ds.Status)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if *verify {
+			return catalog.Verified(path)
+		}
+		return nil
+	}
+	load := func(path string, fn func(string) ([]backtest.Event, error)) bool {
+		if path == "" {
+			return true
+		}
+		files := []string{path}
+		if catalog.IsManifest(path) && path != *frames {
+			segs, run, err := catalog.OpenRun(path, *allowGaps)
+			if err != nil {
+				log.Printf("load %v", err)
+				return false
+			}
+			if !run.Complete {
+				log.Printf("load %s: run is incomplete, the recorder did not finish it", path)
+			}
+			files = segs
+		}
+		var evs []backtest.Event
+		for _, f := range files {
+			if f != *frames {
+				if err := check(f); err != nil {
+					log.Printf("load %s: %v", f, err)
+					return false
+				}
+			}
+			seg, err := fn(f)
+			if err != nil {
+				log.Printf("load %s: %v", f, err)
+				return false
+			}
+			evs = append(evs, seg...)
+		}
+		log.Printf("loaded %s: %d events", path, len(evs))
+		streams = append(streams, evs)
+		return true
+	}
+	if !load(*l2, func(p string) ([]backtest.Event, error) { return backtest.LoadL2CSV(p, *venue, *symbol) }) ||
+		!load(*trades, func(p string) ([]backtest.Event, error) { return backtest.LoadTradesCSV(p, *venue, *symbol) }) ||
+		!load(*frames, backtest.LoadFrames) {
+		return app.ExitFailure
+	}
+
+	var latencies []time.Duration
+	if *sweep != "" {
+		for _, s := range strings.Split(*sweep, ",") {
+			d, err := time.ParseDuration(strings.TrimSpace(s))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "backtest: -latency_sweep: %v\n", err)
+				return app.ExitUsage
+			}
+			latencies = append(latencies, d)
+		}
+	}
+
+	profiles := append([]backtest.Profile(nil), backtest.Profiles...)
+	for _, lp := range gw.Backtest.LatencyProfiles {
+		p := backtest.Profile{Name: lp.Name, Latency: time.Duration(lp.Latency), Venues: map[string]time.Duration{}}
+		for v, d := range lp.Venues {
+			p.Venues[v] = time.Duration(d)
+		}
+		if i := indexProfile(profiles, lp.Name); i >= 0 {
+			profiles[i] = p
+		} else {
+			profiles = append(profiles, p)
+		}
+	}
+	run := backtest.Profile{Latency: *latencyAssumed}
+	if *profile != "" {
+		if app.IsSet(fs, "latency") {
+			fmt.Fprintln(os.Stderr, "backtest: -profile and -latency are exclusive")
+			return app.ExitUsage
+		}
+		p, ok := backtest.FindProfile(profiles, *profile)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "backtest: -profile: unknown profile %q\n", *profile)
+			return app.ExitUsage
+		}
+		run = p
+	}
+	var swept []backtest.Profile
+	if *profileSweep == "all" {
+		swept = profiles
+	} else if *profileSweep != "" {
+		for _, name := range strings.Split(*profileSweep, ",") {
+			p, ok := backtest.FindProfile(profiles, strings.TrimSpace(name))
+			if !ok {
+				fmt.Fprintf(os.Stderr, "backtest: -profile_sweep: unknown profile %q\n", name)
+				return app.ExitUsage
+			}
+			swept = append(swept, p)
+		}
+	}
+
+	list := gw.Strategies
+	if len(list) == 0 {
+		list = []config.Strategy{{Name: "demo", Kind: "demo", Timer: config.Duration(time.Second)}}
+	}
+	// every run needs fresh strategies
+	build := func(p backtest.Profile) (*backtest.Engine, error) {
+		engine := backtest.New(p.Apply(backtest.Config{
+			Fees:   app.Fees(gw),
+			Limits: executor.Limits{MaxOrderSize: gw.MaxOrderSize, MaxPosition: gw.Risk.MaxPosition},
+			Base:   gw.BaseCurrency,
+		}))
+		return engine, app.Strategies(list, engine.Add)
+	}
+	engine, err := build(run)
+	if err != nil {
+		log.Printf("strategies: %v", err)
+		return app.ExitConfig
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	events := backtest.Merge(streams...)
+	res, err := engine.Run(ctx, events)
+	if err != nil {
+		log.Printf("backtest: %v", err)
+		return app.ExitFailure
+	}
+	fmt.Printf("[Backtest] %s .. %s events=%d orders=%d fills=%d rejected=%d unfilled=%d\n",
+		res.Start.UTC().Format(time.RFC3339), res.End.UTC().Format(time.RFC3339),
+		res.Events, res.Orders, len(res.Fills), res.Rejected, res.Unfilled)
+	for _, p := range res.Positions {
+		fmt.Printf("[Backtest] position %s %s qty=%g avg=%.4f realized=%.4f\n", p.Venue, p.Symbol, p.Qty, p.AvgPrice, p.Realized)
+	}
+	fmt.Printf("[Backtest] pnl=%.4f realized=%.4f unrealized=%.4f fees=%.4f\n", res.PnL(), res.Realized, res.Unrealized, res.Fees)
+	rep := backtest.NewReport(res, run.Latency)
+	rep.Latency, rep.Profile = run.String(), run.Name
+	if len(latencies) > 0 || len(swept) > 0 {
+		byLatency := func(d time.Duration) (*backtest.Engine, error) { return build(backtest.Profile{Latency: d}) }
+		if err := rep.Sweep(ctx, events, latencies, byLatency); err != nil {
+			log.Printf("latency sweep: %v", err)
+			return app.ExitFailure
+		}
+		if err := rep.SweepProfiles(ctx, events, swept, build); err != nil {
+			log.Printf("profile sweep: %v", err)
+			return app.ExitFailure
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "PROFILE\tLATENCY\tPNL\tFEES\tFILLS\tSLIPPAGE_BPS\tMAX_DRAWDOWN")
+		for _, r := range rep.Sensitivity {
+			name := r.Profile
+			if name == "" {
+				name = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%.4f\t%.4f\t%d\t%.2f\t%.4f\n", name, r.Latency, r.PnL, r.Fees, r.Fills, r.SlippageBps, r.MaxDrawdown)
+		}
+		w.Flush()
+	}
+	if *reportOut != "" || *reportHTML != "" {
+		fmt.Printf("[Backtest] max_drawdown=%.4f\n", rep.MaxDrawdown)
+		if !writeFile(*reportOut, rep.WriteJSON) || !writeFile(*reportHTML, rep.WriteHTML) {
+			return app.ExitStartup
+		}
+	}
+	if *tradesOut != "" {
+		f, err := os.Create(*tradesOut)
+		if err != nil {
+			log.Printf("trades_out: %v", err)
+			return app.ExitStartup
+		}
+		defer f.Close()
+		if err := res.WriteTrades(f); err != nil {
+			log.Printf("trades_out: %v", err)
+			return app.ExitFailure
+		}
+	}
+	return app.ExitOK
+}
+
+func indexProfile(profiles []backtest.Profile, name string) int {
+	for i, p := range profiles {
+		if strings.EqualFold(p.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// writeFile creates path and fills it with write; an empty path is skipped.
+func writeFile(path string, write func(io.Writer) error) bool {
+	if path == "" {
+		return true
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		log.Printf("%s: %v", path, err)
+		return false
+	}
+	err = write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		log.Printf("%s: %v", path, err)
+		return false
+	}
+	return true
+}
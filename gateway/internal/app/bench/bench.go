@@ -0,0 +1,93 @@
+// Package bench implements "helix bench": run the performance regression
+// suite over a recorded L2 capture and write JSON results, optionally
+// comparing them with a baseline run.
+package bench
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+
+	"github.com/helix-lab/helix/gateway/internal/app"
+	"github.com/helix-lab/helix/gateway/pkg/bench"
+)
+
+// Main runs "helix bench" with args (without the subcommand name).
+func Main(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	var common app.Common
+	common.Register(fs)
+	fixture := fs.String("fixture", "", "L2 CSV capture to drive the cases (empty = synthetic)")
+	symbol := fs.String("symbol", "BTCUSDT", "Symbol of the -fixture capture")
+	run := fs.String("run", "", "Only run cases matching this regexp")
+	count := fs.Int("count", 1, "Run each case this many times and keep the median")
+	out := fs.String("out", "", "Write the JSON results here (empty = stdout)")
+	baseline := fs.String("baseline", "", "Compare with the JSON results of an earlier run")
+	maxRegress := fs.Float64("max_regress", 0, "With -baseline, fail when a case's ns/op grows by more than this fraction (0 = report only)")
+	if code := common.Parse(fs, args); code >= 0 {
+		return code
+	}
+	defer common.Close()
+
+	var match *regexp.Regexp
+	if *run != "" {
+		var err error
+		if match, err = regexp.Compile(*run); err != nil {
+			log.Printf("bench: -run: %v", err)
+			return app.ExitUsage
+		}
+	}
+	var base *bench.Report
+	if *baseline != "" {
+		b, err := os.ReadFile(*baseline)
+		if err != nil {
+			log.Printf("bench: %v", err)
+			return app.ExitStartup
+		}
+		base = new(bench.Report)
+		if err := json.Unmarshal(b, base); err != nil {
+			log.Printf("bench: %s: %v", *baseline, err)
+			return app.ExitStartup
+		}
+	}
+	fix := bench.Synthetic(20000, 200)
+	if *fixture != "" {
+		var err error
+		if fix, err = bench.LoadFixture(*fixture, *symbol); err != nil {
+			log.Printf("bench: %v", err)
+			return app.ExitStartup
+		}
+	}
+
+	rep := bench.Run(fix, match, *count)
+	b, _ := json.MarshalIndent(rep, "", "  ")
+	b = append(b, '\n')
+	if *out == "" {
+		os.Stdout.Write(b)
+	} else if err := os.WriteFile(*out, b, 0o644); err != nil {
+		log.Printf("bench: %v", err)
+		return app.ExitFailure
+	}
+	if base == nil {
+		return app.ExitOK
+	}
+
+	if base.Fixture != rep.Fixture || base.GOARCH != rep.GOARCH || base.CPUs != rep.CPUs {
+		fmt.Fprintf(os.Stderr, "warning: baseline ran on %s/%s with %d CPUs, this run on %s/%s with %d\n",
+			base.Fixture, base.GOARCH, base.CPUs, rep.Fixture, rep.GOARCH, rep.CPUs)
+	}
+	code := app.ExitOK
+	for _, c := range bench.Compare(*base, rep) {
+		verdict := ""
+		if *maxRegress > 0 && c.Delta > *maxRegress {
+			verdict = "  REGRESSION"
+			code = app.ExitFailure
+		}
+		fmt.Fprintf(os.Stderr, "%-16s %12.1f -> %12.1f ns/op %+7.1f%%  allocs %d -> %d%s\n",
+			c.Name, c.Base.NsPerOp, c.Current.NsPerOp, 100*c.Delta, c.Base.AllocsPerOp, c.Current.AllocsPerOp, verdict)
+	}
+	return code
+}
@@ -0,0 +1,184 @@
+package bookcheck
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/helix-lab/helix/gateway/internal/app"
+	"github.com/helix-lab/helix/gateway/pkg/catalog"
+	"github.com/helix-lab/helix/gateway/pkg/l2book"
+)
+
+// sampler writes every nth complete message's top of book.
+type sampler struct {
+	book    *l2book.Book
+	every   int
+	counter int
+	format  l2book.NumFormat
+	w       *csv.Writer
+}
+
+func (s *sampler) emit() error {
+	b := s.book
+	if !b.Ready() {
+		return nil
+	}
+	s.counter++
+	if s.every > 0 && s.counter%s.every == 0 {
+		bid, bidSz, ask, askSz := b.RawTop()
+		return s.w.Write([]string{
+			strconv.FormatInt(b.LastTsMs, 10),
+			strconv.FormatInt(b.LastSeq, 10),
+			s.format.Format(b.BestBid, bid),
+			s.format.Format(b.BestAsk, ask),
+			s.format.Format(b.BidSize, bidSz),
+			s.format.Format(b.AskSize, askSz),
+		})
+	}
+	return nil
+}
+
+// Main runs "helix bookcheck" with args (without the subcommand name).
+func Main(args []string) int {
+	fs := flag.NewFlagSet("bookcheck", flag.ContinueOnError)
+	var common app.Common
+	common.Register(fs)
+	inPath := fs.String("in", "data/replay/bybit_l2.csv", "input CSV path")
+	outPath := fs.String("out", "go_bookcheck.csv", "output CSV path")
+	every := fs.Int("every", 100, "bookcheck stride")
+	format := fs.String("format", l2book.FormatFixed, "How prices and sizes are written: fixed (-digits decimals), sig (-digits significant digits) or raw (as recorded); match the recorder's -bookcheck_format to diff the two")
+	digits := fs.Int("digits", l2book.DefaultDigits, "Decimals or significant digits for -format")
+	compare := fs.Bool("compare", false, "Compare the two bookcheck CSVs given as arguments instead of writing one; exits 1 when they differ")
+	normalize := fs.Bool("normalize", false, "With -compare, rewrite both files' prices and sizes in -format before comparing, so files written in different formats compare by value")
+	if code := common.Parse(fs, args); code >= 0 {
+		return code
+	}
+	defer common.Close()
+	numFormat, err := l2book.ParseNumFormat(*format, *digits)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bookcheck: %v\n", err)
+		return app.ExitUsage
+	}
+	if *compare {
+		if fs.NArg() != 2 {
+			fmt.Fprintln(os.Stderr, "bookcheck: -compare needs two bookcheck CSVs as arguments")
+			return app.ExitUsage
+		}
+		return compareFiles(fs.Arg(0), fs.Arg(1), *normalize, numFormat)
+	}
+
+	in, err := os.Open(*inPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open input: %v\n", err)
+		return 1
+	}
+	defer in.Close()
+
+	out, err := os.Create(*outPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create output: %v\n", err)
+		return 1
+	}
+	defer out.Close()
+
+	writer := csv.NewWriter(out)
+	if err := writer.Write(l2book.BookcheckHeader); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write header: %v\n", err)
+		return 1
+	}
+
+	reader := csv.NewReader(in)
+	reader.FieldsPerRecord = -1
+	var parser l2book.Parser
+	// the sidecar's columns read captures split without their header
+	cols, err := catalog.Columns(*inPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read meta: %v\n", err)
+		return 1
+	}
+	if cols != nil {
+		parser.SetColumns(cols)
+	}
+	state := &sampler{book: l2book.New(), every: *every, format: numFormat, w: writer}
+	if numFormat.Mode == l2book.FormatRaw {
+		state.book.KeepRaw()
+	}
+
+	for {
+		fields, err := reader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if errors.Is(err, csv.ErrFieldCount) {
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "read error: %v\n", err)
+			return 1
+		}
+		d, ok := parser.Parse(fields)
+		if !ok {
+			continue
+		}
+		if state.book.LastSeq >= 0 && d.Seq != state.book.LastSeq {
+			if err := state.emit(); err != nil {
+				fmt.Fprintf(os.Stderr, "fatal: %v\n", err)
+				return 1
+			}
+		}
+
+		if err := state.book.Apply(d); err != nil {
+			fmt.Fprintf(os.Stderr, "fatal: %v\n", err)
+			return 1
+		}
+	}
+
+	if err := state.emit(); err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %v\n", err)
+		return 1
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		fmt.Fprintf(os.Stderr, "flush error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// compareFiles prints how bookcheck CSVs a and b differ.
+func compareFiles(a, b string, normalize bool, norm l2book.NumFormat) int {
+	fa, err := os.Open(a)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open input: %v\n", err)
+		return 1
+	}
+	defer fa.Close()
+	fb, err := os.Open(b)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open input: %v\n", err)
+		return 1
+	}
+	defer fb.Close()
+	d, err := l2book.CompareBookchecks(fa, fb, normalize, norm)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "read error: %v\n", err)
+		return 1
+	}
+	for _, m := range d.Mismatches {
+		fmt.Println(m)
+	}
+	if d.Rows[0] != d.Rows[1] {
+		fmt.Printf("length mismatch: %s=%d %s=%d\n", a, d.Rows[0], b, d.Rows[1])
+	}
+	if !d.Equal() {
+		return 1
+	}
+	fmt.Printf("bookcheck files match (%d rows)\n", d.Rows[0])
+	return 0
+}
@@ -0,0 +1,79 @@
+// Package catalog implements "helix catalog": list the recorded captures
+// under a directory with their lineage and validation status, or serve the
+// catalog and its recorded-data queries over HTTP.
+package catalog
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/internal/app"
+	"github.com/helix-lab/helix/gateway/pkg/catalog"
+	"github.com/helix-lab/helix/gateway/pkg/mdapi"
+)
+
+// Main runs "helix catalog" with args (without the subcommand name).
+func Main(args []string) int {
+	fs := flag.NewFlagSet("catalog", flag.ContinueOnError)
+	var common app.Common
+	common.Register(fs)
+	dir := fs.String("data", "", "Directory of recorded captures")
+	status := fs.String("status", "", "Only list captures with these comma-separated validation statuses, e.g. ok")
+	addr := fs.String("addr", "", "Serve /md/v1/datasets and /md/v1/history/ for -data on this address instead of listing")
+	token := fs.String("token", os.Getenv("HELIX_MD_TOKEN"), "Bearer token required by -addr (empty = open)")
+	if code := common.Parse(fs, args); code >= 0 {
+		return code
+	}
+	defer common.Close()
+	if *dir == "" {
+		fmt.Fprintln(os.Stderr, "catalog: need -data")
+		return app.ExitUsage
+	}
+
+	if *addr != "" {
+		srv := &http.Server{Addr: *addr, Handler: (&mdapi.Server{Token: *token, DataDir: *dir}).Handler(),
+			ReadHeaderTimeout: 5 * time.Second}
+		log.Printf("catalog: serving %s on %s", *dir, *addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("catalog: %v", err)
+			return app.ExitStartup
+		}
+		return app.ExitOK
+	}
+
+	cat, err := catalog.Scan(*dir)
+	if err != nil {
+		log.Printf("catalog: %v", err)
+		return app.ExitFailure
+	}
+	sets := cat.Datasets
+	if *status != "" {
+		sets = catalog.WithStatus(sets, strings.Split(*status, ",")...)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tKIND\tVENUE\tSYMBOL\tSTART\tEND\tSTATUS\tGAPS\tRECORDER")
+	for _, d := range sets {
+		gaps := "-"
+		if v := d.Validation; v != nil {
+			gaps = fmt.Sprintf("%d (max %s)", v.Gaps, time.Duration(v.MaxGapMs)*time.Millisecond)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", d.Path, d.Kind, d.Venue, d.Symbol,
+			ts(d.StartMs), ts(d.EndMs), d.Status, gaps, d.Version)
+	}
+	w.Flush()
+	return app.ExitOK
+}
+
+func ts(ms int64) string {
+	if ms == 0 {
+		return "-"
+	}
+	return time.UnixMilli(ms).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,61 @@
+// Package convert implements "helix convert": rewrite recorded CSV
+// captures (L2, trades, bookcheck) as Parquet.
+package convert
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/helix-lab/helix/gateway/internal/app"
+	"github.com/helix-lab/helix/gateway/pkg/capfile"
+	"github.com/helix-lab/helix/gateway/pkg/parquet"
+)
+
+// Main runs "helix convert" with args (without the subcommand name).
+func Main(args []string) int {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	var common app.Common
+	common.Register(fs)
+	codecName := fs.String("codec", "gzip", "Parquet page compression: gzip or none")
+	outDir := fs.String("out_dir", "", "Write the .parquet files here (empty = next to each CSV)")
+	rowGroup := fs.Int("row_group", 0, "Rows per Parquet row group (0 = default)")
+	if code := common.Parse(fs, args); code >= 0 {
+		return code
+	}
+	defer common.Close()
+	codec, err := parquet.ParseCodec(*codecName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "convert: -codec: %v\n", err)
+		return app.ExitUsage
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: helix convert [-codec gzip] [-out_dir dir] <capture.csv>...")
+		return app.ExitUsage
+	}
+	if *outDir != "" {
+		if err := os.MkdirAll(*outDir, 0o755); err != nil {
+			log.Printf("convert: %v", err)
+			return app.ExitStartup
+		}
+	}
+
+	code := app.ExitOK
+	for _, src := range fs.Args() {
+		dst := strings.TrimSuffix(src, filepath.Ext(src)) + ".parquet"
+		if *outDir != "" {
+			dst = filepath.Join(*outDir, filepath.Base(dst))
+		}
+		rows, err := capfile.ToParquet(src, dst, codec, *rowGroup)
+		if err != nil {
+			log.Printf("convert: %v", err)
+			code = app.ExitFailure
+			continue
+		}
+		log.Printf("convert: %s -> %s (%d rows)", src, dst, rows)
+	}
+	return code
+}
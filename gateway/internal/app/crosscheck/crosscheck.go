@@ -0,0 +1,63 @@
+// Package crosscheck implements "helix crosscheck": compare the native top
+// of book of a shallow L2 capture with the top rebuilt from a deeper one of
+// the same symbol, as recorded together by "helix record l2 -cross_depth".
+package crosscheck
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/helix-lab/helix/gateway/internal/app"
+	"github.com/helix-lab/helix/gateway/pkg/l2book"
+)
+
+// Main runs "helix crosscheck" with args (without the subcommand name).
+func Main(args []string) int {
+	fs := flag.NewFlagSet("crosscheck", flag.ContinueOnError)
+	var common app.Common
+	common.Register(fs)
+	shallow := fs.String("shallow", "", "L2 capture of the shallow feed, e.g. orderbook.1")
+	deep := fs.String("deep", "", "L2 capture of the deep feed, e.g. orderbook.50")
+	tol := fs.Float64("tol", 1e-9, "Price and size tolerance")
+	maxMismatch := fs.Float64("max_mismatch", 0, "Fail when more than this fraction of aligned tops disagree")
+	if code := common.Parse(fs, args); code >= 0 {
+		return code
+	}
+	defer common.Close()
+	if *shallow == "" || *deep == "" {
+		fmt.Fprintln(os.Stderr, "usage: helix crosscheck -shallow l2.csv -deep l2.depth50.csv [-tol 1e-9] [-max_mismatch 0]")
+		return app.ExitUsage
+	}
+
+	var tops [2][]l2book.Top
+	for i, p := range []string{*shallow, *deep} {
+		f, err := os.Open(p)
+		if err != nil {
+			log.Printf("crosscheck: %v", err)
+			return app.ExitStartup
+		}
+		tops[i], err = l2book.Tops(f)
+		f.Close()
+		if err != nil {
+			log.Printf("crosscheck: %s: %v", p, err)
+			return app.ExitFailure
+		}
+	}
+	st := l2book.CrossCheck(tops[0], tops[1], *tol)
+	fmt.Printf("shallow tops %d, deep tops %d, aligned %d, matched %d\n", st.Shallow, st.Deep, st.Aligned, st.Matched)
+	for _, m := range st.Mismatches {
+		fmt.Printf("  mismatch %s\n", m)
+	}
+	if st.Aligned == 0 {
+		fmt.Println("no tops share a timestamp; were the captures recorded together?")
+		return app.ExitFailure
+	}
+	if bad := float64(st.Aligned-st.Matched) / float64(st.Aligned); bad > *maxMismatch {
+		fmt.Printf("FAIL: %.4f%% of aligned tops disagree\n", 100*bad)
+		return app.ExitFailure
+	}
+	fmt.Println("ok")
+	return app.ExitOK
+}
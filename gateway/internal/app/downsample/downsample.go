@@ -0,0 +1,71 @@
+// Package downsample implements "helix downsample": sample a recorded L2
+// capture's top of book, or top N levels, at a fixed cadence for work that
+// does not need every delta.
+package downsample
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/internal/app"
+	"github.com/helix-lab/helix/gateway/pkg/capfile"
+	"github.com/helix-lab/helix/gateway/pkg/l2book"
+)
+
+// Main runs "helix downsample" with args (without the subcommand name).
+func Main(args []string) int {
+	fs := flag.NewFlagSet("downsample", flag.ContinueOnError)
+	var common app.Common
+	common.Register(fs)
+	inPath := fs.String("in", "", "L2 capture CSV to sample")
+	outPath := fs.String("out", "", "Output CSV path")
+	every := fs.Duration("every", 100*time.Millisecond, "Sampling interval")
+	levels := fs.Int("levels", 1, "Levels per side (1 = bookcheck's top-of-book columns)")
+	if code := common.Parse(fs, args); code >= 0 {
+		return code
+	}
+	defer common.Close()
+	if *inPath == "" || *outPath == "" {
+		fmt.Fprintln(os.Stderr, "usage: helix downsample -in l2.csv -out sampled.csv [-every 100ms] [-levels 1]")
+		return app.ExitUsage
+	}
+	if *every < time.Millisecond || *levels < 1 {
+		fmt.Fprintln(os.Stderr, "downsample: -every must be at least 1ms and -levels at least 1")
+		return app.ExitUsage
+	}
+
+	in, err := os.Open(*inPath)
+	if err != nil {
+		log.Printf("downsample: %v", err)
+		return app.ExitStartup
+	}
+	defer in.Close()
+	out, err := os.Create(*outPath)
+	if err != nil {
+		log.Printf("downsample: %v", err)
+		return app.ExitStartup
+	}
+	st, err := l2book.Downsample(in, out, *every, *levels)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		log.Printf("downsample: %s: %v", *inPath, err)
+		os.Remove(*outPath)
+		return app.ExitFailure
+	}
+	if err := capfile.WriteMeta(*inPath, *outPath, map[string]any{
+		"downsampled_from": *inPath,
+		"sample_ms":        every.Milliseconds(),
+		"levels":           *levels,
+	}); err != nil {
+		log.Printf("downsample: %v", err)
+		return app.ExitFailure
+	}
+	log.Printf("downsample: %s -> %s (%d deltas, %d messages, %d samples, %d skipped)",
+		*inPath, *outPath, st.Deltas, st.Messages, st.Samples, st.Skipped)
+	return app.ExitOK
+}
@@ -0,0 +1,16 @@
+package gateway
+
+// database/sql drivers for -store_driver and -tsdb_url: "sqlite" (pure Go,
+// no cgo) and "pgx" for Postgres and TimescaleDB.
+import (
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// storeDriverName maps -store_driver onto the driver registered for it.
+func storeDriverName(name string) string {
+	if name == "postgres" {
+		return "pgx"
+	}
+	return name
+}
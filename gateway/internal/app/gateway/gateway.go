@@ -0,0 +1,1480 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/internal/app"
+	"github.com/helix-lab/helix/gateway/internal/app/replay"
+	"github.com/helix-lab/helix/gateway/pkg/admin"
+	"github.com/helix-lab/helix/gateway/pkg/basis"
+	"github.com/helix-lab/helix/gateway/pkg/bridge"
+	"github.com/helix-lab/helix/gateway/pkg/candles"
+	"github.com/helix-lab/helix/gateway/pkg/capture"
+	"github.com/helix-lab/helix/gateway/pkg/chaos"
+	"github.com/helix-lab/helix/gateway/pkg/clickhouse"
+	"github.com/helix-lab/helix/gateway/pkg/config"
+	"github.com/helix-lab/helix/gateway/pkg/eventlog"
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/fix"
+	"github.com/helix-lab/helix/gateway/pkg/fundarb"
+	"github.com/helix-lab/helix/gateway/pkg/fx"
+	"github.com/helix-lab/helix/gateway/pkg/heatmap"
+	"github.com/helix-lab/helix/gateway/pkg/instruments"
+	"github.com/helix-lab/helix/gateway/pkg/latency"
+	"github.com/helix-lab/helix/gateway/pkg/mdapi"
+	"github.com/helix-lab/helix/gateway/pkg/metrics"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/orderstore"
+	"github.com/helix-lab/helix/gateway/pkg/portfolio"
+	"github.com/helix-lab/helix/gateway/pkg/probe"
+	"github.com/helix-lab/helix/gateway/pkg/ratelimit"
+	"github.com/helix-lab/helix/gateway/pkg/risk"
+	"github.com/helix-lab/helix/gateway/pkg/router"
+	"github.com/helix-lab/helix/gateway/pkg/secrets"
+	"github.com/helix-lab/helix/gateway/pkg/shadow"
+	"github.com/helix-lab/helix/gateway/pkg/state"
+	"github.com/helix-lab/helix/gateway/pkg/stratapi"
+	"github.com/helix-lab/helix/gateway/pkg/strategy"
+	"github.com/helix-lab/helix/gateway/pkg/tape"
+	"github.com/helix-lab/helix/gateway/pkg/tracing"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+	"github.com/helix-lab/helix/gateway/pkg/tsdb"
+	"github.com/helix-lab/helix/gateway/pkg/ws"
+)
+
+// Run modes: the same strategies and router against different ends.
+const (
+	ModeLive   = "live"   // connectors in, actions published
+	ModePaper  = "paper"  // connectors in, simulated fills
+	ModeReplay = "replay" // frame log in, simulated fills
+)
+
+// candleGrace is how long live bars wait past their end for delayed
+// trades before the heartbeat completes them.
+const candleGrace = 500 * time.Millisecond
+
+var depthUpdates = metrics.Default.CounterVec("helix_gateway_depth_updates_total", "Depth updates consumed by the gateway loop.", "venue", "symbol")
+
+// Main runs "helix gateway" with args (without the subcommand name).
+func Main(args []string) int {
+	fs := flag.NewFlagSet("gateway", flag.ContinueOnError)
+	var common app.Common
+	common.Register(fs)
+	mode := fs.String("mode", ModeLive, "Run mode: live, paper (simulated fills) or replay (-replay_in frame log, simulated fills)")
+	replayIn := fs.String("replay_in", "", "Frame log written by -tap to feed in -mode replay")
+	replaySpeed := fs.Float64("replay_speed", 1, "Replay speed in -mode replay relative to the recording (0 = as fast as possible)")
+	decisionLat := fs.String("paper_decision_latency", "", "Simulated strategy decision delay in paper and replay modes: a duration, uniform:A,B, normal:MEAN,SD or lognormal:MEDIAN,SHAPE")
+	transitLat := fs.String("paper_transit_latency", "", "Simulated order transit delay to the venue, as -paper_decision_latency")
+	ackLat := fs.String("paper_ack_latency", "", "Simulated fill report delay from the venue, as -paper_decision_latency")
+	latSeed := fs.Int64("paper_latency_seed", 1, "Seed for the simulated latency draws")
+	chaosPath := fs.String("chaos", "", "Inject the faults of this chaos scenario file (disconnects, storms, malformed frames, slow consumer, transport failures); for resilience testing only")
+	dryRun := fs.Bool("dry_run", false, "Route and publish routing decisions, but send no orders (shadow testing)")
+	policy := fs.String("backpressure", "block", "Router backpressure policy (block, conflate, drop_oldest, grow_bounded)")
+	nbboOn := fs.Bool("nbbo", false, "Publish the consolidated best bid and offer per symbol, with the venues quoting it, whenever it changes")
+	bookSnapEvery := fs.Duration("book_snapshots", 0, "Publish every book's top -book_snapshot_depth levels at this interval, for consumers that don't apply deltas (0 = off)")
+	bookSnapDepth := fs.Int("book_snapshot_depth", 20, "Levels per side in -book_snapshots")
+	heatDir := fs.String("heatmap_dir", "", "Sample every book into a time×price liquidity heatmap and write one per book here at shutdown (empty = off)")
+	heatEvery := fs.Duration("heatmap_every", time.Second, "Time bucket of -heatmap_dir")
+	heatPx := fs.Float64("heatmap_tick", 0, "Price bucket of -heatmap_dir (0 = each book's smallest level gap)")
+	heatLevels := fs.Int("heatmap_levels", 50, "Levels per side sampled into -heatmap_dir")
+	heatFormat := fs.String("heatmap_format", "png", "Format of -heatmap_dir: csv, parquet or png")
+	publishWindow := fs.Duration("publish_window", 0, "Conflate depth updates per venue and symbol for this long before publishing, e.g. 5ms (0 = publish every update; trades bypass it)")
+	backlog := fs.Int("max_backlog", ws.DefaultRouterConfig().MaxBacklog, "Router backlog bound for drop_oldest/grow_bounded")
+	shards := fs.Int("shards", 1, "Process depth on this many goroutines, each owning the symbols that hash to it, so a busy symbol only delays its shard")
+	simSeed := fs.Int64("sim_seed", 0, "Seed for the synthetic feeds (0 = built-in seeds)")
+	bybitSymbols := fs.String("bybit_symbols", "", "Comma-separated symbols for a live Bybit feed (empty = synthetic feeds)")
+	bybitEndpoint := fs.String("bybit_endpoint", "wss://stream.bybit.com/v5/public/linear", "Bybit public websocket endpoint")
+	bybitLiq := fs.Bool("bybit_liquidations", false, "Also subscribe Bybit liquidation prints")
+	bybitFunding := fs.Bool("bybit_funding", false, "Also subscribe Bybit funding rates (tickers)")
+	bookEvents := fs.Bool("book_events", false, "Derive level added/removed/size-changed and BBO improved/worsened events from every venue's book and publish them to the transport and strategies")
+	statusPoll := fs.Duration("status_poll", 0, "Poll venue system-status endpoints at this interval (0 = off)")
+	metricsAddr := fs.String("metrics_addr", "", "Serve Prometheus metrics on this address, e.g. :9102 (empty = off)")
+	pprofOn := fs.Bool("pprof", false, "Also serve /debug/pprof/ on the metrics address")
+	stateFile := fs.String("state_file", "", "Persist books, open orders, positions and subscriptions here and warm-start from it (empty = off)")
+	stateEvery := fs.Duration("state_interval", 5*time.Second, "How often -state_file is written")
+	journalPath := fs.String("journal", "", "Append every market event and routing, order and fill decision to this sequenced journal; replay it with helix journal (empty = off)")
+	shadowOut := fs.String("shadow_out", "", "Write every decision of the gateway.shadows candidates and the production strategies they shadow to this JSONL file (empty = off)")
+	shadowReport := fs.String("shadow_report", "", "Write the shadows' divergence from production to this JSON file at exit (empty = print only)")
+	shadowWindow := fs.Duration("shadow_window", shadow.DefaultWindow, "How far apart a production and a shadow decision may be and still match")
+	stateBookAge := fs.Duration("state_max_book_age", time.Minute, "Seed books from -state_file only when it is younger than this")
+	liveStall := fs.Duration("live_stall", 5*time.Second, "/live on the metrics address fails once the event loop stalls this long")
+	adminToken := fs.String("admin_token", os.Getenv("HELIX_ADMIN_TOKEN"), "Bearer token for the /v1/ admin API and its /dashboard on the metrics address (empty = both off)")
+	storeDriver := fs.String("store_driver", "sqlite", "Database for -store_dsn: sqlite (a file path) or postgres (a postgres:// URL)")
+	storeDSN := fs.String("store_dsn", "", "Record orders, fills, positions and routing decisions in this database (empty = off)")
+	candleList := fs.String("candles", "", "Aggregate trades into bars at these intervals, e.g. 1s,1m,5m, and publish them (empty = off; enables trade streams)")
+	tapeList := fs.String("tape", "", "Publish rolling buy/sell volume, trade counts and large prints per symbol over these windows, e.g. 10s,1m,5m (empty = off; enables trade streams)")
+	tapeEvery := fs.Duration("tape_every", time.Second, "How often -tape publishes its statistics")
+	tapeLargeQty := fs.Float64("tape_large_qty", 0, "Flag trades of at least this size as large prints (0 = use -tape_large_multiple)")
+	tapeLargeMult := fs.Float64("tape_large_multiple", 10, "Flag trades this many times the mean size over the longest -tape window as large prints")
+	candlesOut := fs.String("candles_out", "", "Also append completed bars to this CSV file")
+	mdAPI := fs.Bool("md_api", false, "Serve books, recent trades and candles as JSON under /md/v1/ on -metrics_addr (enables trade streams)")
+	mdData := fs.String("md_data", "", "Directory of recorded captures that -md_api history queries read")
+	mdToken := fs.String("md_token", os.Getenv("HELIX_MD_TOKEN"), "Bearer token required by -md_api (empty = open); prefer HELIX_MD_TOKEN or -md_token_file")
+	mdTokenFile := fs.String("md_token_file", "", "Read the -md_api bearer token from this file instead of -md_token")
+	bridgeOn := fs.Bool("bridge", false, "Serve books and feed health to downstream gateways' helix venues at "+bridge.Path+" on -metrics_addr")
+	bridgeToken := fs.String("bridge_token", os.Getenv("HELIX_BRIDGE_TOKEN"), "Bearer token -bridge clients must present")
+	stratAPI := fs.String("strategy_api_addr", "", "Let gateway.clients run strategies out of process over gRPC on this address, e.g. :9879 (empty = off; enables trade streams)")
+	fixAddr := fs.String("fix_addr", "", "Accept FIX 4.4 order entry from gateway.fix_clients on this address, e.g. :9878 (empty = off)")
+	fixCompID := fs.String("fix_comp_id", fix.DefaultCompID, "The gateway's CompID on -fix_addr and -fix_dropcopy_addr")
+	fixDropCopy := fs.String("fix_dropcopy_addr", "", "Copy every order opening, cancel and fill to gateway.drop_copies over FIX on this address (empty = off)")
+	instrumentsEvery := fs.Duration("instruments", 0, "Fetch instrument specs from bybit venues' REST APIs at startup and at this interval, and check orders against them (0 = off)")
+	instrumentsCache := fs.String("instruments_cache", "", "Keep -instruments specs in this file, loaded at startup in case the venues cannot be reached (empty = memory only)")
+	portfolioPoll := fs.Duration("portfolio_poll", 0, "Poll venue accounts (bybit venues with credentials) at this interval into a portfolio view, reconciling their positions against the tracked ones (0 = off)")
+	portfolioBase := fs.String("portfolio_base", "", "Currency the portfolio, risk and PnL are valued in (default: the config's base_currency)")
+	fundingArb := fs.Duration("funding_arb", 0, "Rank funding and basis arbitrage across venues at this interval and publish the opportunities (0 = off; enables funding streams)")
+	fundingArbHold := fs.Int("funding_arb_hold", 3, "Funding periods a -funding_arb trade is assumed to be held")
+	fundingArbMin := fs.Float64("funding_arb_min_bps", 0, "Publish -funding_arb opportunities netting at least this many bps after fees")
+	basisEvery := fs.Duration("basis", 0, "Sample and publish perp basis over spot at this interval (0 = off; enables funding streams for marks)")
+	basisWindow := fs.Duration("basis_window", 5*time.Minute, "Rolling window of the -basis statistics")
+	var basisAbove, basisBelow *float64
+	fs.Func("basis_alert_above_bps", "Alert when a -basis perp premium exceeds this many bps (unset = off)", func(s string) error {
+		v, err := strconv.ParseFloat(s, 64)
+		basisAbove = &v
+		return err
+	})
+	fs.Func("basis_alert_below_bps", "Alert when a -basis perp premium falls below this many bps, e.g. -20 (unset = off)", func(s string) error {
+		v, err := strconv.ParseFloat(s, 64)
+		basisBelow = &v
+		return err
+	})
+	tsdbURL := fs.String("tsdb_url", "", "Push book and latency series to InfluxDB at this write URL, or to TimescaleDB at a postgres:// URL (empty = off)")
+	tsdbToken := fs.String("tsdb_token", "", "InfluxDB v2 API token for -tsdb_url")
+	tsdbEvery := fs.Duration("tsdb_every", 10*time.Second, "How often to push -tsdb_url series")
+	clickhouseURL := fs.String("clickhouse_url", "", "Also write depth and NBBO into ClickHouse at this HTTP URL, e.g. http://localhost:8123 (empty = off)")
+	clickhouseDB := fs.String("clickhouse_db", "helix", "ClickHouse database for -clickhouse_url")
+	otlpEndpoint := fs.String("otlp_endpoint", "", "OTLP/HTTP traces endpoint, e.g. http://localhost:4318/v1/traces (empty = off)")
+	traceSample := fs.Float64("trace_sample", 0.01, "Fraction of depth ticks traced (actions are always traced)")
+	tapPath := fs.String("tap", "", "Optional JSONL path to tee raw frames from live connectors")
+	archivePath := fs.String("archive", "", "Optional path to archive every raw frame, lossless, with nanosecond receive times (binary, plus .idx)")
+	latencyReport := fs.String("latency_report", "", "Append per-interval latency summaries to this file (.csv for CSV, else JSON lines)")
+	latencyReportEvery := fs.Duration("latency_report_interval", time.Minute, "Interval of -latency_report summaries")
+	clockPoll := fs.Duration("clock_poll", 0, "Measure venue clock offsets at this interval (0 = off)")
+	maxSkew := fs.Duration("max_clock_skew", 250*time.Millisecond, "Flag clock drift when a venue offset exceeds this")
+	daemon := fs.Bool("daemon", false, "Run until SIGINT/SIGTERM, forwarding market data without the demo actions")
+	feedTimeout := fs.Duration("feed_timeout", 0, "Exit with code 5 when no market data arrives for this long (0 = never)")
+	latencyBuffer := fs.Int("latency_buffer", 8192, "Async profiler sample queue; samples beyond it are dropped and counted")
+	if code := common.Parse(fs, args); code >= 0 {
+		return code
+	}
+	defer common.Close()
+	switch *mode {
+	case ModeLive, ModePaper:
+	case ModeReplay:
+		if *replayIn == "" {
+			fmt.Fprintln(os.Stderr, "gateway: -mode replay needs -replay_in")
+			return app.ExitUsage
+		}
+		if *chaosPath != "" {
+			fmt.Fprintln(os.Stderr, "gateway: -chaos needs live connectors, not -mode replay")
+			return app.ExitUsage
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "gateway: unknown -mode %q (want live, paper or replay)\n", *mode)
+		return app.ExitUsage
+	}
+	simLatency := executor.Latency{Seed: *latSeed}
+	for _, l := range []struct {
+		name string
+		in   string
+		out  *executor.Dist
+	}{{"paper_decision_latency", *decisionLat, &simLatency.Decision}, {"paper_transit_latency", *transitLat, &simLatency.Transit}, {"paper_ack_latency", *ackLat, &simLatency.Ack}} {
+		d, err := executor.ParseDist(l.in)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gateway: -%s: %v\n", l.name, err)
+			return app.ExitUsage
+		}
+		*l.out = d
+	}
+	if *mode == ModeReplay && *replaySpeed > 0 {
+		// delays are on the recording's clock
+		simLatency.Scale = *replaySpeed
+	}
+	if *mdAPI && *metricsAddr == "" {
+		fmt.Fprintln(os.Stderr, "gateway: -md_api needs -metrics_addr")
+		return app.ExitUsage
+	}
+	if *mdTokenFile != "" {
+		tok, err := secrets.TokenFile(*mdTokenFile)
+		if err != nil {
+			log.Printf("md_token_file: %v", err)
+			return app.ExitConfig
+		}
+		*mdToken = tok
+	}
+	if *bridgeOn && (*metricsAddr == "" || *bridgeToken == "") {
+		fmt.Fprintln(os.Stderr, "gateway: -bridge needs -metrics_addr and -bridge_token")
+		return app.ExitUsage
+	}
+	if *adminToken != "" && *metricsAddr == "" {
+		fmt.Fprintln(os.Stderr, "gateway: -admin_token needs -metrics_addr")
+		return app.ExitUsage
+	}
+	if *heatDir != "" {
+		switch strings.ToLower(*heatFormat) {
+		case "csv", "parquet", "png":
+		default:
+			fmt.Fprintf(os.Stderr, "gateway: -heatmap_format %q (want csv, parquet or png)\n", *heatFormat)
+			return app.ExitUsage
+		}
+		if *heatEvery <= 0 {
+			fmt.Fprintln(os.Stderr, "gateway: -heatmap_every must be positive")
+			return app.ExitUsage
+		}
+	}
+
+	var bars *candles.Aggregator
+	if *candleList != "" {
+		intervals, err := candles.ParseIntervals(*candleList)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gateway: -candles: %v\n", err)
+			return app.ExitUsage
+		}
+		bars = candles.New(intervals...)
+	}
+	var flow *tape.Tape
+	if *tapeList != "" {
+		windows, err := candles.ParseIntervals(*tapeList)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gateway: -tape: %v\n", err)
+			return app.ExitUsage
+		}
+		if *tapeEvery <= 0 {
+			fmt.Fprintln(os.Stderr, "gateway: -tape_every must be positive")
+			return app.ExitUsage
+		}
+		flow = tape.New(tape.Config{Windows: windows, LargeQty: *tapeLargeQty, LargeMultiple: *tapeLargeMult})
+	}
+
+	// -config replaces the venue/router flags
+	cfg, err := common.Config()
+	if err != nil {
+		log.Printf("config: %v", err)
+		return app.ExitConfig
+	}
+	if cfg == nil {
+		cfg = flagConfig(*policy, *backlog, *simSeed, *shards, *bybitSymbols, *bybitEndpoint, *bybitLiq, *bybitFunding)
+		if err := cfg.Validate(); err != nil {
+			log.Printf("flags: %v", err)
+			return app.ExitUsage
+		}
+	}
+	gw := cfg.Gateway
+	if bars != nil || flow != nil || *mdAPI || *stratAPI != "" {
+		for i := range gw.Venues {
+			gw.Venues[i].Trades = true
+		}
+	}
+	if *fundingArb > 0 || *basisEvery > 0 {
+		for i := range gw.Venues {
+			gw.Venues[i].Funding = true
+		}
+	}
+	if *bookEvents {
+		for i := range gw.Venues {
+			gw.Venues[i].BookEvents = true
+		}
+	}
+
+	bp, _ := ws.ParsePolicy(gw.Router.Backpressure)
+	routerCfg := ws.DefaultRouterConfig()
+	routerCfg.Policy = bp
+	routerCfg.MaxBacklog = gw.Router.MaxBacklog
+	routerCfg.SimSeed = gw.Router.SimSeed
+	routerCfg.Shards = gw.Router.Shards
+
+	wsRouter := ws.NewRouterWithConfig(routerCfg)
+	var frameLog *capture.FrameLog
+	if *tapPath != "" {
+		frameLog, err = capture.OpenFrameLog(*tapPath, 0)
+		if err != nil {
+			log.Printf("open tap: %v", err)
+			return app.ExitStartup
+		}
+		defer frameLog.Close()
+	}
+	var archive *capture.Archive
+	if *archivePath != "" {
+		archive, err = capture.OpenArchive(*archivePath, 0)
+		if err != nil {
+			log.Printf("open archive: %v", err)
+			return app.ExitStartup
+		}
+		defer func() {
+			if err := archive.Close(); err != nil {
+				log.Printf("close archive: %v", err)
+			}
+		}()
+	}
+	limits := ratelimit.New()
+	for _, v := range gw.Venues {
+		limits.SetRate(v.Name, "", v.RateLimit.RequestsPerSec)
+		limits.SetRate(v.Name, ratelimit.Orders, v.RateLimit.OrdersPerSec)
+	}
+	var faults *chaos.Injector
+	if *chaosPath != "" {
+		sc, err := chaos.Load(*chaosPath)
+		if err != nil {
+			log.Printf("chaos: %v", err)
+			return app.ExitConfig
+		}
+		faults = chaos.New(*sc)
+		faults.Logf = log.Printf
+		log.Printf("chaos: injecting %d faults from %s", len(sc.Faults), *chaosPath)
+	}
+	var replayConn *replay.Connector
+	if *mode == ModeReplay {
+		replayConn = replay.NewConnector(*replayIn, *replaySpeed)
+		wsRouter.Add(replayConn)
+	} else {
+		conns, err := connectors(gw, tapper(frameLog, archive), limits)
+		if err != nil {
+			log.Printf("connectors: %v", err)
+			return app.ExitConfig
+		}
+		for _, c := range conns {
+			if faults != nil {
+				c = faults.Wrap(c)
+			}
+			wsRouter.Add(c)
+		}
+	}
+	bookMgr := orderbook.NewManager()
+	bookMgr.SetTakerFees(app.Fees(gw).Taker)
+	if *portfolioBase != "" {
+		gw.BaseCurrency = strings.ToUpper(*portfolioBase)
+	}
+	rates := fx.New(bookMgr, gw.BaseCurrency)
+	var nbbo *orderbook.NBBOTracker
+	if *nbboOn {
+		nbbo = orderbook.NewNBBOTracker(bookMgr)
+	}
+	pub := transport.NewPublisher(gw.PublishEndpoint)
+	if faults != nil {
+		faults.Books = bookMgr
+		pub.Fail = faults.Publish
+	}
+	publishDepth := pub.PublishDepth
+	var conflater *transport.Conflater
+	var publishTick, bookSnapTick, tapeTick, heatTick <-chan time.Time
+	if flow != nil {
+		ticker := time.NewTicker(*tapeEvery)
+		defer ticker.Stop()
+		tapeTick = ticker.C
+	}
+	if *bookSnapEvery > 0 {
+		ticker := time.NewTicker(*bookSnapEvery)
+		defer ticker.Stop()
+		bookSnapTick = ticker.C
+	}
+	var heat *liveHeatmaps
+	if *heatDir != "" {
+		heat = &liveHeatmaps{dir: *heatDir, format: strings.ToLower(*heatFormat), maps: map[[3]string]*heatmap.Heatmap{},
+			cfg: heatmap.Config{Every: *heatEvery, Tick: *heatPx, Levels: *heatLevels}}
+		ticker := time.NewTicker(*heatEvery)
+		defer ticker.Stop()
+		heatTick = ticker.C
+	}
+	if *publishWindow > 0 {
+		conflater = transport.NewConflater(pub.PublishDepth)
+		publishDepth = conflater.Depth
+		ticker := time.NewTicker(*publishWindow)
+		defer ticker.Stop()
+		publishTick = ticker.C
+	}
+	smart := router.NewSmartRouter(app.Fees(gw))
+	sender := executor.NewOrderSender(pub, smart)
+	tracker := executor.NewTracker()
+	tracker.OnComplete(func(o executor.Order) { pub.PublishOrderComplete(o.Completion()) })
+	sender.SetTracker(tracker)
+	sender.SetRateLimiter(limits)
+	orderLimits := executor.Limits{MaxOrderSize: gw.MaxOrderSize, MaxPosition: gw.Risk.MaxPosition}
+	var specs *instruments.Cache
+	if *instrumentsEvery > 0 {
+		specs = instruments.NewCache(*instrumentsCache, instrumentSources(gw.Venues, limits)...)
+		specs.Interval, specs.Logf = *instrumentsEvery, log.Printf
+		if err := specs.Load(); err != nil {
+			log.Printf("instruments: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := specs.Refresh(ctx); err != nil {
+			log.Printf("instruments: %v", err)
+		}
+		cancel()
+		smart.SetInstruments(specs.Tradable)
+		orderLimits.Instrument = specs.Check
+	}
+	sender.SetLimits(orderLimits)
+	sender.SetDryRun(*dryRun)
+	sender.SetTestnet(gw.Testnet)
+	if gw.Testnet {
+		log.Printf("gateway: testnet profile: orders are marked and stored as testnet")
+	}
+	var riskEng *risk.Engine
+	if l := riskLimits(gw.Risk); l != (risk.Limits{}) {
+		riskEng = risk.New(l, tracker, risk.BookMarks(bookMgr))
+		riskEng.SetRates(rates)
+		riskEng.SetKillSwitch(sender)
+		riskEng.SetPublisher(pub)
+		sender.SetChecker(riskEng)
+	}
+	var pf *portfolio.Portfolio
+	if *portfolioPoll > 0 {
+		sources, err := accountSources(gw.Venues, limits)
+		if err != nil {
+			log.Printf("portfolio: %v", err)
+			return app.ExitConfig
+		}
+		pf = portfolio.New(rates.Base, rates.Rate, tracker, sources...)
+		pf.Interval, pf.Logf = *portfolioPoll, log.Printf
+		pf.SetPublisher(pub.PublishPortfolio)
+		spot := map[string]bool{}
+		for _, v := range gw.Venues {
+			spot[v.Name] = v.Category == config.CategorySpot
+		}
+		smart.SetInventory(func(venue string, a transport.Action, px float64) bool {
+			return !spot[venue] || pf.Covers(venue, a, px)
+		})
+		if riskEng != nil {
+			riskEng.SetPositions(pf.Positions)
+		}
+	}
+	var arb *fundarb.Monitor
+	var arbTick <-chan time.Time
+	if *fundingArb > 0 {
+		arb = fundarb.New(fundarb.Config{Spot: spotVenues(gw.Venues), Fees: app.Fees(gw), Periods: *fundingArbHold, MinNetBps: *fundingArbMin}, bookMgr)
+		ticker := time.NewTicker(*fundingArb)
+		defer ticker.Stop()
+		arbTick = ticker.C
+	}
+	var basisMon *basis.Monitor
+	var basisTick <-chan time.Time
+	if *basisEvery > 0 {
+		basisMon = basis.New(basis.Config{Spot: spotVenues(gw.Venues), Window: *basisWindow,
+			AlertAboveBps: basisAbove, AlertBelowBps: basisBelow}, bookMgr)
+		basisMon.SetAlert(func(b transport.Basis) {
+			log.Printf("basis: %s %s at %.2fbps is %s the alert threshold", b.Venue, b.Symbol, b.BasisBps, b.Alert)
+		})
+		ticker := time.NewTicker(*basisEvery)
+		defer ticker.Stop()
+		basisTick = ticker.C
+	}
+	var paper *executor.Paper
+	if *mode != ModeLive {
+		paper = executor.NewPaper(bookMgr)
+		if !simLatency.IsZero() {
+			paper.SetLatency(simLatency)
+			log.Printf("paper latency: decision %s, transit %s, ack %s", simLatency.Decision, simLatency.Transit, simLatency.Ack)
+		}
+		sender.SetSink(paper)
+	}
+	var api *stratapi.Server
+	if *stratAPI != "" {
+		if api, err = strategyAPI(gw.Clients); err != nil {
+			log.Printf("strategy api: %v", err)
+			return app.ExitConfig
+		}
+	}
+	var fixSrv *fix.Server
+	if *fixAddr != "" {
+		if fixSrv, err = fixServer(*fixCompID, gw.FIXClients, tracker, sender); err != nil {
+			log.Printf("fix: %v", err)
+			return app.ExitConfig
+		}
+		fixSrv.Logf = log.Printf
+	}
+	host, err := strategies(gw, bookMgr, sender, tracker, api, fixSrv, *daemon)
+	if err != nil {
+		log.Printf("strategies: %v", err)
+		return app.ExitConfig
+	}
+	if fixSrv != nil {
+		if err := fixSrv.Listen(*fixAddr); err != nil {
+			log.Printf("fix: %v", err)
+			return app.ExitStartup
+		}
+		log.Printf("fix: accepting order entry on %s as %s", fixSrv.Addr(), *fixCompID)
+	}
+	if api != nil {
+		if err := api.Listen(*stratAPI); err != nil {
+			log.Printf("strategy api: %v", err)
+			return app.ExitStartup
+		}
+		log.Printf("strategy api: serving %s on %s", stratapi.Method, api.Addr())
+	}
+	var journals executor.Journals
+	var store *orderstore.Store
+	storeDone := make(chan struct{})
+	if *storeDSN != "" {
+		store, err = orderstore.Open(orderstore.Config{Driver: storeDriverName(*storeDriver), DSN: *storeDSN, Testnet: gw.Testnet, Logf: log.Printf})
+		if err == nil {
+			migrateCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			err = store.Migrate(migrateCtx)
+			cancel()
+		}
+		if err != nil {
+			log.Printf("store: %v", err)
+			return app.ExitStartup
+		}
+		defer store.CloseDB()
+		journals = append(journals, store)
+		go func() {
+			defer close(storeDone)
+			store.Run()
+		}()
+	} else {
+		close(storeDone)
+	}
+	var dropCopy *fix.DropCopy
+	if *fixDropCopy != "" {
+		if dropCopy, err = fixDropCopies(*fixCompID, gw.DropCopies); err != nil {
+			log.Printf("fix drop copy: %v", err)
+			return app.ExitConfig
+		}
+		dropCopy.Logf = log.Printf
+		if err := dropCopy.Listen(*fixDropCopy); err != nil {
+			log.Printf("fix drop copy: %v", err)
+			return app.ExitStartup
+		}
+		log.Printf("fix: drop copy on %s as %s", dropCopy.Addr(), *fixCompID)
+		// after the last fills are journaled
+		defer dropCopy.Close()
+		journals = append(journals, dropCopy)
+	}
+	var events *eventlog.Log
+	if *journalPath != "" {
+		if events, err = eventlog.Open(*journalPath); err != nil {
+			log.Printf("journal: %v", err)
+			return app.ExitStartup
+		}
+		defer func() {
+			if err := events.Close(); err != nil {
+				log.Printf("journal: %v", err)
+			}
+		}()
+		log.Printf("journal: appending to %s after event %d", *journalPath, events.Seq())
+		journals = append(journals, events)
+	}
+	var routeLog *admin.RouteLog
+	if *adminToken != "" {
+		routeLog = admin.NewRouteLog(200)
+		journals = append(journals, routeLog)
+	}
+	var shadows *shadow.Runner
+	if len(gw.Shadows) > 0 {
+		if shadows, err = shadowRunner(gw, bookMgr, orderLimits); err != nil {
+			log.Printf("shadows: %v", err)
+			return app.ExitConfig
+		}
+		shadows.Window = *shadowWindow
+		if *shadowOut != "" {
+			f, err := os.Create(*shadowOut)
+			if err != nil {
+				log.Printf("shadow_out: %v", err)
+				return app.ExitStartup
+			}
+			defer f.Close()
+			shadows.Record(f)
+		}
+		journals = append(journals, shadows)
+		log.Printf("shadows: %d candidates paper trading beside production", len(gw.Shadows))
+	}
+	if len(journals) > 0 {
+		tracker.SetJournal(journals)
+		sender.SetJournal(journals)
+	}
+	if *stateFile != "" {
+		if err := warmStart(*stateFile, *stateBookAge, bookMgr, tracker, wsRouter); err != nil {
+			log.Printf("state: %v", err)
+			return app.ExitStartup
+		}
+	}
+
+	// ctx ends on SIGINT/SIGTERM; runCtx additionally ends when the main
+	// loop exits, stopping the background pollers.
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	runCtx, stopRun := context.WithCancel(ctx)
+	defer stopRun()
+
+	stateDone := make(chan struct{})
+	if *stateFile != "" && *stateEvery > 0 {
+		go func() {
+			defer close(stateDone)
+			state.Run(runCtx, *stateFile, *stateEvery, captureState(bookMgr, tracker, wsRouter), log.Printf)
+		}()
+	} else {
+		close(stateDone)
+	}
+
+	stopLatency := latency.Default.StartAsync(*latencyBuffer)
+	defer stopLatency()
+	reportDone := make(chan struct{})
+	reportCtx, stopReport := context.WithCancel(context.Background())
+	defer stopReport()
+	if *latencyReport != "" {
+		rep := latency.NewFileReporter(latency.Default, *latencyReport, *latencyReportEvery)
+		go func() {
+			defer close(reportDone)
+			if err := rep.Run(reportCtx); err != nil {
+				log.Printf("latency report: %v", err)
+			}
+		}()
+	} else {
+		close(reportDone)
+	}
+
+	if pf != nil {
+		go pf.Run(runCtx)
+	}
+	if faults != nil {
+		go faults.Run(runCtx)
+	}
+	if specs != nil {
+		go specs.Run(runCtx)
+	}
+	var monitor *ws.StatusMonitor
+	if *statusPoll > 0 {
+		monitor = ws.NewStatusMonitor(*statusPoll, statusSources(gw.Testnet)...)
+		monitor.Lead = time.Minute
+		go monitor.Run(runCtx)
+		wsRouter.SetStatusMonitor(monitor)
+	}
+	// venues in maintenance or out of order calls are routed around
+	smart.SetAvailability(func(venue string) bool {
+		return (monitor == nil || !monitor.InMaintenance(venue)) && limits.Available(venue, ratelimit.Orders)
+	})
+
+	var clock *ws.ClockMonitor
+	if *clockPoll > 0 {
+		clock = ws.NewClockMonitor(*clockPoll, timeSources(gw.Testnet)...)
+		clock.MaxSkew = *maxSkew
+		clock.Logf = log.Printf
+		go clock.Run(runCtx)
+		sender.SetClockOffset(clock.Offset)
+	}
+
+	probes := probe.New(*liveStall)
+	probes.AddCheck("feeds", wsRouter.FeedsReady)
+	if replayConn == nil {
+		probes.AddCheck("books", func() error { return warmBooks(gw, wsRouter, bookMgr) })
+	}
+
+	var bridgeSrv *bridge.Server
+	if *bridgeOn {
+		bridgeSrv = &bridge.Server{Token: *bridgeToken, Books: bookMgr, Health: wsRouter.Health}
+	}
+	var replays *replayRunner
+	var recent *mdapi.Recent
+	if *metricsAddr != "" {
+		srv := metrics.NewServer(metrics.Default, *pprofOn)
+		srv.Register(wsRouter, bookMgr, sender, tracker, host, metrics.SourceFunc(func(reg *metrics.Registry) {
+			metrics.RegisterLatency(reg, latency.Default)
+		}))
+		if clock != nil {
+			srv.Register(clock)
+		}
+		if riskEng != nil {
+			srv.Register(riskEng)
+		}
+		if pf != nil {
+			srv.Register(pf)
+		}
+		probes.Handle(srv.Handle)
+		if *mdAPI {
+			recent = mdapi.NewRecent(1000)
+			md := &mdapi.Server{Token: *mdToken, Books: bookMgr, Recent: recent, DataDir: *mdData}
+			srv.Handle("/md/v1/", md.Handler())
+		}
+		if bridgeSrv != nil {
+			srv.Handle(bridge.Path, bridgeSrv.Handler())
+		}
+		if *adminToken != "" {
+			replays = newReplayRunner(runCtx, func(u transport.DepthUpdate) {
+				bookMgr.Apply(u)
+				publishDepth(u)
+			})
+			api := &admin.Server{Token: *adminToken, Books: bookMgr, Router: wsRouter,
+				Orders: tracker, Sender: sender, Risk: riskEng, Portfolio: pf, Instruments: specs, Replay: replays,
+				Routes: routeLog, Latency: latency.Default, Strategies: host}
+			srv.Handle("/v1/", api.Handler())
+			srv.Handle("/dashboard", api.Dashboard())
+			log.Printf("admin: dashboard at /dashboard on %s", *metricsAddr)
+		}
+		if err := srv.Start(*metricsAddr); err != nil {
+			log.Printf("metrics server: %v", err)
+			return app.ExitStartup
+		}
+		defer func() {
+			shutCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = srv.Shutdown(shutCtx)
+		}()
+	}
+
+	var chSink *clickhouse.Sink
+	chDone := make(chan struct{})
+	if *clickhouseURL != "" {
+		chSink = clickhouse.New(clickhouse.Config{URL: *clickhouseURL, Database: *clickhouseDB, Logf: log.Printf})
+		migrateCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err := chSink.Migrate(migrateCtx)
+		cancel()
+		if err != nil {
+			log.Printf("clickhouse: %v", err)
+			return app.ExitStartup
+		}
+		go func() {
+			defer close(chDone)
+			chSink.Run()
+		}()
+	} else {
+		close(chDone)
+	}
+	if *tsdbURL != "" {
+		w, err := tsdbWriter(*tsdbURL, *tsdbToken)
+		if err != nil {
+			log.Printf("tsdb: %v", err)
+			return app.ExitStartup
+		}
+		pusher := &tsdb.Pusher{Writer: w, Every: *tsdbEvery, Logf: log.Printf,
+			Sources: []tsdb.Source{tsdb.Books(bookMgr), tsdb.Latency(latency.Default)}}
+		go pusher.Run(runCtx)
+	}
+
+	var tickSample *tracing.Tracer
+	if *otlpEndpoint != "" {
+		exp := tracing.NewOTLPExporter(*otlpEndpoint, "helix-gateway")
+		defer exp.Shutdown()
+		tracing.SetTracer(tracing.NewTracer(exp, 1))
+		tickSample = tracing.NewTracer(exp, *traceSample)
+	}
+
+	wsRouter.Start()
+	if *daemon {
+		log.Printf("gateway running in %s mode (dry run %t): %d venues, publishing on %s", *mode, *dryRun, len(gw.Venues), gw.PublishEndpoint)
+	}
+
+	var strategyTick <-chan time.Time
+	if d := host.Interval(); d > 0 {
+		ticker := time.NewTicker(d)
+		defer ticker.Stop()
+		strategyTick = ticker.C
+	}
+	// without -daemon the gateway exits once its strategies finish
+	var strategiesDone <-chan struct{}
+	if !*daemon {
+		strategiesDone = host.Done()
+	}
+	var submits <-chan stratapi.Submit
+	if api != nil {
+		submits = api.Submits()
+	}
+	var fixRequests <-chan fix.Request
+	if fixSrv != nil {
+		fixRequests = fixSrv.Requests()
+	}
+	var paperReady <-chan struct{}
+	if paper != nil {
+		paperReady = paper.Ready()
+	}
+	var shadowTick <-chan time.Time
+	var shadowReady <-chan struct{}
+	if shadows != nil {
+		if d := shadows.Interval(); d > 0 {
+			ticker := time.NewTicker(d)
+			defer ticker.Stop()
+			shadowTick = ticker.C
+		}
+		shadowReady = shadows.Ready()
+	}
+	// a finished replay stops the gateway once its last updates are consumed
+	var replayDone <-chan struct{}
+	var replayIdle <-chan time.Time
+	if replayConn != nil {
+		replayDone = replayConn.Done()
+	}
+	// keeps /live passing while the market is quiet
+	var barLog *candles.CSVWriter
+	if bars != nil && *candlesOut != "" {
+		f, err := os.Create(*candlesOut)
+		if err != nil {
+			log.Printf("candles_out: %v", err)
+			return app.ExitStartup
+		}
+		defer f.Close()
+		barLog = candles.NewCSVWriter(f)
+	}
+	emitBars := func(ctx context.Context, done []transport.Bar) {
+		for _, b := range done {
+			pub.PublishBar(b)
+			host.Bar(ctx, b)
+			if shadows != nil {
+				shadows.Bar(ctx, b)
+			}
+			if recent != nil {
+				recent.AddBar(b)
+			}
+			if barLog != nil {
+				if err := barLog.Write(b); err != nil {
+					log.Printf("candles_out: %v", err)
+				}
+			}
+		}
+	}
+
+	heartbeat := time.NewTicker(time.Second)
+	defer heartbeat.Stop()
+	var watchdog <-chan time.Time
+	if *feedTimeout > 0 {
+		wd := time.NewTicker(*feedTimeout / 4)
+		defer wd.Stop()
+		watchdog = wd.C
+	}
+
+	exit := app.ExitOK
+	// written by the depth shards as well as the loop
+	var lastData atomic.Int64
+	touch := func() { lastData.Store(time.Now().UnixNano()) }
+	sinceData := func() time.Duration { return time.Duration(time.Now().UnixNano() - lastData.Load()) }
+	touch()
+	depth := func(ctx context.Context, update transport.DepthUpdate) {
+		depthUpdates.With(update.Venue, update.Symbol).Inc()
+		touch()
+		if faults != nil {
+			faults.Stall()
+		}
+		if events != nil {
+			events.Depth(update)
+		}
+		traceTick(ctx, tickSample, update, func(tctx context.Context) {
+			_, span := tracing.Start(tctx, "orderbook.apply")
+			bookMgr.Apply(update)
+			span.End()
+			if nbbo != nil {
+				if n, changed := nbbo.Update(update); changed {
+					pub.PublishNBBO(n)
+				}
+			}
+			if paper != nil {
+				paper.Depth(update)
+			}
+			if chSink != nil {
+				chSink.Depth(update)
+				chSink.BBO(update, orderbook.MergeBest(bookMgr.MarketSnapshot(update.Symbol, update.Category)))
+			}
+			publishDepth(update)
+			if bridgeSrv != nil {
+				bridgeSrv.Depth(update)
+			}
+			host.Book(tctx, update)
+			if shadows != nil {
+				shadows.Depth(tctx, update)
+			}
+		})
+	}
+	// with shards each drains its own lane; the loop only takes a single one
+	updates := wsRouter.Updates()
+	var shardWG sync.WaitGroup
+	shardCtx, stopShards := context.WithCancel(ctx)
+	defer stopShards()
+	if lanes := wsRouter.Shards(); len(lanes) > 1 {
+		updates = nil
+		for _, lane := range lanes {
+			shardWG.Add(1)
+			go func(lane <-chan transport.DepthUpdate) {
+				defer shardWG.Done()
+				for {
+					select {
+					case u := <-lane:
+						depth(shardCtx, u)
+					case <-shardCtx.Done():
+						return
+					}
+				}
+			}(lane)
+		}
+	}
+loop:
+	for {
+		probes.Beat()
+		select {
+		case now := <-heartbeat.C:
+			if bars != nil && replayConn == nil {
+				emitBars(ctx, bars.Advance(now.Add(-candleGrace)))
+			}
+			if riskEng != nil {
+				riskEng.Evaluate(now)
+			}
+		case <-ctx.Done():
+			log.Printf("signal received, shutting down")
+			break loop
+		case <-strategiesDone:
+			break loop
+		case <-watchdog:
+			if d := sinceData(); d > *feedTimeout {
+				log.Printf("no market data for %s, shutting down", d.Truncate(time.Second))
+				exit = app.ExitFeedLost
+				break loop
+			}
+		case update := <-updates:
+			depth(ctx, update)
+		case t := <-wsRouter.Trades():
+			touch()
+			if events != nil {
+				events.Trade(t)
+			}
+			if paper != nil {
+				paper.Trade(t)
+			}
+			pub.PublishTrade(t)
+			host.Trade(ctx, t)
+			if shadows != nil {
+				shadows.Trade(ctx, t)
+			}
+			if recent != nil {
+				recent.AddTrade(t)
+			}
+			if bars != nil {
+				emitBars(ctx, bars.Trade(t))
+			}
+			if flow != nil {
+				if lp, large := flow.Trade(t); large {
+					pub.PublishLargePrint(lp)
+				}
+			}
+		case liq := <-wsRouter.Liquidations():
+			touch()
+			if events != nil {
+				events.Liquidation(liq)
+			}
+			pub.PublishLiquidation(liq)
+		case ev := <-wsRouter.BookEvents():
+			pub.PublishBookEvent(ev)
+			host.BookEvent(ctx, ev)
+			if shadows != nil {
+				shadows.BookEvent(ctx, ev)
+			}
+		case fr := <-wsRouter.Funding():
+			touch()
+			if events != nil {
+				events.Funding(fr)
+			}
+			pub.PublishFunding(fr)
+			if arb != nil {
+				arb.Funding(fr)
+			}
+			if basisMon != nil {
+				basisMon.Mark(fr)
+			}
+		case now := <-tapeTick:
+			// a replay's tape rolls on its trades' timestamps
+			if replayConn == nil {
+				flow.Advance(now.UnixMilli())
+			}
+			for _, s := range flow.Stats() {
+				pub.PublishTapeStats(s)
+			}
+		case <-publishTick:
+			conflater.Flush()
+		case <-bookSnapTick:
+			for _, b := range bookSnapshots(wsRouter, bookMgr, *bookSnapDepth) {
+				pub.PublishBookSnapshot(b)
+			}
+		case <-heatTick:
+			heat.add(bookSnapshots(wsRouter, bookMgr, *heatLevels))
+		case now := <-arbTick:
+			for _, o := range arb.Evaluate(now) {
+				pub.PublishOpportunity(o)
+			}
+		case now := <-basisTick:
+			for _, b := range basisMon.Sample(now) {
+				pub.PublishBasis(b)
+			}
+		case now := <-strategyTick:
+			host.Timer(ctx, now)
+		case now := <-shadowTick:
+			shadows.Timer(ctx, now)
+		case <-shadowReady:
+			shadows.Fills(ctx)
+		case sub := <-submits:
+			api.Exec(ctx, sub, host)
+		case req := <-fixRequests:
+			fixSrv.Exec(ctx, req, host)
+		case <-paperReady:
+			for _, f := range paper.TakeFills() {
+				if err := host.Fill(ctx, f); err != nil {
+					log.Printf("paper fill %s: %v", f.OrderID, err)
+				}
+			}
+			if riskEng != nil {
+				riskEng.Publish(time.Now())
+			}
+		case <-replayDone:
+			replayDone = nil
+			idle := time.NewTicker(50 * time.Millisecond)
+			defer idle.Stop()
+			replayIdle = idle.C
+		case <-replayIdle:
+			if sinceData() > 100*time.Millisecond {
+				break loop
+			}
+		}
+	}
+
+	// connectors first so nothing new arrives, then pollers, then sinks
+	probes.Drain()
+	wsRouter.Stop()
+	stopShards()
+	shardWG.Wait()
+	if fixSrv != nil {
+		_ = fixSrv.Close()
+	}
+	if api != nil {
+		api.Close()
+	}
+	if paper != nil {
+		for _, f := range paper.TakeFills() {
+			_ = host.Fill(ctx, f)
+		}
+	}
+	if shadows != nil {
+		shadows.Fills(ctx)
+	}
+	if bars != nil {
+		emitBars(ctx, bars.Flush())
+	}
+	stopRun()
+	if replays != nil {
+		replays.wait()
+	}
+	if conflater != nil {
+		conflater.Flush()
+	}
+	<-stateDone
+	if store != nil {
+		store.Close()
+	}
+	<-storeDone
+	if store != nil && store.Dropped() > 0 {
+		fmt.Printf("[Gateway] store dropped=%d records on a full write queue\n", store.Dropped())
+	}
+	if chSink != nil {
+		chSink.Close()
+	}
+	<-chDone
+	if heat != nil {
+		paths, err := heat.write()
+		fmt.Printf("[Gateway] heatmaps written=%d err=%v\n", len(paths), err)
+	}
+	if conflater != nil {
+		fmt.Printf("[Gateway] depth publish window=%s conflated=%d\n", *publishWindow, conflater.Conflated())
+	}
+	bpStats := wsRouter.Backpressure()
+	fmt.Printf("[Gateway] backpressure policy=%s backlog=%d high_water=%d conflated=%d dropped=%d blocked=%d\n",
+		bpStats.Policy, bpStats.Backlog, bpStats.HighWater, bpStats.Conflated, bpStats.Dropped, bpStats.BlockedTimes)
+	for _, sub := range wsRouter.Subscribers() {
+		fmt.Printf("[Gateway] subscriber %s delivered=%d dropped=%d buffered=%d/%d\n",
+			sub.Name, sub.Delivered, sub.Dropped, sub.Buffered, sub.Capacity)
+	}
+	for _, h := range wsRouter.Health() {
+		fmt.Printf("[Gateway] feed %s status=%s last_update=%s reconnects=%d gaps=%d recovered=%d conns=%d\n",
+			h.Venue, h.Status, h.LastUpdate.Format(time.RFC3339Nano), h.Reconnects, h.Gaps.Gaps, h.Gaps.Recovered, len(h.Conns))
+	}
+	if faults != nil {
+		n := faults.Injected()
+		fmt.Printf("[Gateway] chaos disconnect=%d storm=%d malformed=%d slow_consumer=%d transport=%d\n",
+			n[chaos.KindDisconnect], n[chaos.KindStorm], n[chaos.KindMalformed], n[chaos.KindSlowConsumer], n[chaos.KindTransport])
+	}
+	for _, sym := range bookMgr.Symbols() {
+		for _, cat := range bookMgr.Categories(sym) {
+			nbbo := orderbook.MergeBest(bookMgr.MarketSnapshot(sym, cat))
+			market := sym
+			if cat != "" {
+				market += "/" + cat
+			}
+			fmt.Printf("[Gateway] book %s nbbo bid=%.2f ask=%.2f\n", market, nbbo.BestBid, nbbo.BestAsk)
+		}
+	}
+	for _, p := range tracker.Positions() {
+		fmt.Printf("[Gateway] position %s %s qty=%g avg=%.2f realized=%.2f\n", p.Venue, p.Symbol, p.Qty, p.AvgPrice, p.Realized)
+	}
+	if shadows != nil {
+		report := shadows.Report(host.Status())
+		for _, d := range report {
+			fmt.Printf("[Gateway] shadow %s of %s orders=%d/%d matched=%d only_production=%d only_shadow=%d match_rate=%.3f lag_ms=%.1f pnl=%.2f/%.2f\n",
+				d.Candidate, d.Production, d.ProductionOrders, d.CandidateOrders, d.Matched, d.OnlyProduction, d.OnlyCandidate,
+				d.MatchRate, d.MeanLagMs, d.ProductionPnL, d.CandidatePnL)
+		}
+		if err := shadows.Close(); err != nil {
+			log.Printf("shadow_out: %v", err)
+		}
+		if *shadowReport != "" {
+			if err := writeShadowReport(*shadowReport, report); err != nil {
+				log.Printf("shadow_report: %v", err)
+			}
+		}
+	}
+	if replayConn != nil {
+		st, err := replayConn.Result()
+		fmt.Printf("[Gateway] replay %s frames=%d skipped=%d err=%v\n", *replayIn, st.Frames, st.Skipped, err)
+	}
+	if frameLog != nil {
+		fmt.Printf("[Gateway] tap frames written=%d dropped=%d\n", frameLog.Written(), frameLog.Dropped())
+	}
+	if archive != nil {
+		fmt.Printf("[Gateway] archive frames written=%d stalls=%d\n", archive.Written(), archive.Stalls())
+	}
+	stopLatency()
+	stopReport()
+	<-reportDone
+	if n := latency.Default.Overflow(); n > 0 {
+		fmt.Printf("[Gateway] latency samples dropped=%d\n", n)
+	}
+	latency.Default.WriteReport(os.Stdout)
+	if *daemon {
+		log.Printf("gateway stopped (exit %d)", exit)
+	} else {
+		fmt.Println("Gateway simulation finished.")
+	}
+	return exit
+}
+
+// warmBooks fails until every subscribed symbol of every configured venue
+// has a two-sided book.
+func warmBooks(g config.Gateway, r *ws.Router, books *orderbook.Manager) error {
+	subs := r.Subscriptions()
+	var cold []string
+	for _, v := range g.Venues {
+		symbols, ok := subs[v.Name]
+		if !ok {
+			symbols = v.Symbols
+		}
+		for _, sym := range books.Cold(v.Name, symbols) {
+			cold = append(cold, v.Name+"/"+sym)
+		}
+	}
+	if len(cold) > 0 {
+		return fmt.Errorf("books not warm: %s", strings.Join(cold, ", "))
+	}
+	return nil
+}
+
+// strategies hosts the configured strategies, strategy API clients and FIX
+// clients. Without any, the demo strategy runs five one-second rounds unless the
+// gateway is a daemon.
+func strategies(g config.Gateway, books *orderbook.Manager, sender *executor.OrderSender, tracker *executor.Tracker, api *stratapi.Server, fixSrv *fix.Server, daemon bool) (*strategy.Host, error) {
+	host := strategy.NewHost(books, sender, tracker)
+	if api != nil {
+		api.Strategies(host.Add)
+	}
+	if fixSrv != nil {
+		fixSrv.Strategies(host.Add)
+	}
+	list := g.Strategies
+	if len(list) == 0 && api == nil && fixSrv == nil && !daemon {
+		list = []config.Strategy{{Name: "demo", Kind: "demo", Timer: config.Duration(time.Second),
+			Params: map[string]any{"rounds": 5.0}}}
+	}
+	if err := app.Strategies(list, host.Add); err != nil {
+		return nil, err
+	}
+	return host, nil
+}
+
+// shadowRunner hosts the gateway.shadows candidates, each beside the
+// production strategy it names.
+func shadowRunner(g config.Gateway, books *orderbook.Manager, limits executor.Limits) (*shadow.Runner, error) {
+	r := shadow.New(books, app.Fees(g), limits)
+	for _, sh := range g.Shadows {
+		of := sh.Of
+		if err := app.Strategies([]config.Strategy{sh.Strategy}, func(c strategy.Config, s strategy.Strategy) { r.Add(of, c, s) }); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func writeShadowReport(path string, report []shadow.Divergence) error {
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+// riskLimits are the gateway.risk limits the risk engine enforces; all
+// zero means no engine.
+func riskLimits(r config.Risk) risk.Limits {
+	return risk.Limits{MaxSymbolPosition: r.MaxSymbolPosition, MaxVenueNotional: r.MaxVenueNotional,
+		MaxAccountNotional: r.MaxNotional, MaxDailyLoss: r.MaxDailyLoss,
+		MaxOrdersPerSec: r.MaxOrdersPerSec, MaxOrdersPerMin: r.MaxOrdersPerMin}
+}
+
+// spotVenues names the spot-category venues.
+func spotVenues(venues []config.Venue) []string {
+	var out []string
+	for _, v := range venues {
+		if v.Category == config.CategorySpot {
+			out = append(out, v.Name)
+		}
+	}
+	return out
+}
+
+// accountSources are the account pollers for -portfolio_poll: every bybit
+// venue with credentials.
+func accountSources(venues []config.Venue, limits *ratelimit.Limiter) ([]portfolio.Source, error) {
+	var out []portfolio.Source
+	for _, v := range venues {
+		if v.Kind != config.KindBybit || v.Credentials == "" {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		creds, err := secrets.Resolve(ctx, v.Credentials, "")
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("%s credentials: %w", v.Name, err)
+		}
+		acct := portfolio.NewBybitAccount(v.Name, v.REST, v.Category, creds)
+		acct.Limits = limits
+		out = append(out, acct)
+	}
+	return out, nil
+}
+
+// statusSources are the -status_poll endpoints; Binance has no testnet
+// status endpoint, so a testnet gateway only polls Bybit's.
+func statusSources(testnet bool) []ws.StatusSource {
+	if testnet {
+		bybit := ws.NewBybitStatus()
+		bybit.URL = config.BybitTestnetREST + "/v5/system/status"
+		return []ws.StatusSource{bybit}
+	}
+	return []ws.StatusSource{ws.NewBybitStatus(), ws.NewBinanceStatus()}
+}
+
+// timeSources are the -clock_poll endpoints, on the testnets for a
+// testnet gateway.
+func timeSources(testnet bool) []ws.TimeSource {
+	bybit, binance := ws.NewBybitTime(), ws.NewBinanceTime()
+	if testnet {
+		bybit.URL = config.BybitTestnetREST + "/v5/market/time"
+		binance.URL = "https://testnet.binance.vision/api/v3/time"
+	}
+	return []ws.TimeSource{bybit, binance}
+}
+
+// instrumentSources are the spec fetchers for -instruments: every bybit
+// venue, in its category.
+func instrumentSources(venues []config.Venue, limits *ratelimit.Limiter) []instruments.Source {
+	var out []instruments.Source
+	for _, v := range venues {
+		if v.Kind == config.KindBybit {
+			src := instruments.NewBybit(v.Name, v.REST, v.Category)
+			src.Limits = limits
+			out = append(out, src)
+		}
+	}
+	return out
+}
+
+// strategyAPI builds the strategy API server for clients, reading each
+// token from its environment variable.
+func strategyAPI(clients []config.Client) (*stratapi.Server, error) {
+	if len(clients) == 0 {
+		return nil, errors.New("no gateway.clients configured")
+	}
+	list := make([]stratapi.Client, 0, len(clients))
+	for _, c := range clients {
+		token := os.Getenv(c.TokenEnv)
+		if token == "" {
+			return nil, fmt.Errorf("client %s: %s is not set", c.Name, c.TokenEnv)
+		}
+		r := c.Risk
+		list = append(list, stratapi.Client{Name: c.Name, Token: token, Symbols: c.Symbols,
+			Limits: stratapi.Limits{MaxOrderSize: r.MaxOrderSize, MaxNotional: r.MaxNotional, MaxPosition: r.MaxPosition, MaxOrdersPerSec: r.MaxOrdersPerSec},
+			Quota:  app.Quota(c.Quota)})
+	}
+	return stratapi.New(list)
+}
+
+// fixServer builds the FIX acceptor for clients, reading each password
+// from its environment variable.
+func fixServer(compID string, clients []config.FIXClient, tracker *executor.Tracker, sender *executor.OrderSender) (*fix.Server, error) {
+	if len(clients) == 0 {
+		return nil, errors.New("no gateway.fix_clients configured")
+	}
+	list := make([]fix.Client, 0, len(clients))
+	for _, c := range clients {
+		password := os.Getenv(c.PasswordEnv)
+		if password == "" {
+			return nil, fmt.Errorf("client %s: %s is not set", c.CompID, c.PasswordEnv)
+		}
+		r := c.Risk
+		list = append(list, fix.Client{CompID: c.CompID, Password: password, Symbols: c.Symbols,
+			Limits: stratapi.Limits{MaxOrderSize: r.MaxOrderSize, MaxNotional: r.MaxNotional, MaxPosition: r.MaxPosition, MaxOrdersPerSec: r.MaxOrdersPerSec}})
+	}
+	return fix.NewServer(compID, list, tracker, sender)
+}
+
+// fixDropCopies builds the FIX drop copy for copies, reading each password
+// from its environment variable.
+func fixDropCopies(compID string, copies []config.DropCopy) (*fix.DropCopy, error) {
+	if len(copies) == 0 {
+		return nil, errors.New("no gateway.drop_copies configured")
+	}
+	list := make([]fix.DropCopyClient, 0, len(copies))
+	for _, d := range copies {
+		password := os.Getenv(d.PasswordEnv)
+		if password == "" {
+			return nil, fmt.Errorf("drop copy %s: %s is not set", d.CompID, d.PasswordEnv)
+		}
+		list = append(list, fix.DropCopyClient{CompID: d.CompID, Password: password, Accounts: d.Accounts})
+	}
+	return fix.NewDropCopy(compID, list)
+}
+
+// traceTick wraps the per-update work in a sampled "gateway.tick" span; depth
+// ticks are far more frequent than actions, so they get their own ratio.
+func traceTick(ctx context.Context, sampler *tracing.Tracer, u transport.DepthUpdate, fn func(context.Context)) {
+	if sampler == nil {
+		fn(ctx)
+		return
+	}
+	tctx, span := sampler.Start(ctx, "gateway.tick", tracing.String("venue", u.Venue), tracing.String("symbol", u.Symbol))
+	fn(tctx)
+	span.End()
+}
+
+// flagConfig builds the equivalent config for the legacy flags: a live Bybit
+// stream when symbols are given, otherwise the two synthetic venues.
+func flagConfig(policy string, backlog int, seed int64, shards int, bybitSymbols, bybitEndpoint string, liq, funding bool) *config.File {
+	cfg := config.Default()
+	g := &cfg.Gateway
+	g.Router = config.Router{Backpressure: policy, MaxBacklog: backlog, SimSeed: seed, Shards: shards}
+	if bybitSymbols != "" {
+		g.Symbols = strings.Split(bybitSymbols, ",")
+		g.Venues = []config.Venue{{
+			Name: "BYBIT", Kind: config.KindBybit, Category: config.CategoryOf(bybitEndpoint),
+			WSPublic: bybitEndpoint, Symbols: g.Symbols,
+			Depth: 1, Liquidations: liq, Funding: funding, Fees: config.Fees{TakerBps: 6},
+		}}
+	}
+	return cfg
+}
+
+// tapper returns the raw frame tap for a venue's connection: the -tap frame
+// log, the -archive, both, or nil when neither is on.
+func tapper(frameLog *capture.FrameLog, archive *capture.Archive) func(source string) func(int64, []byte) {
+	if frameLog == nil && archive == nil {
+		return nil
+	}
+	return func(source string) func(int64, []byte) {
+		switch {
+		case archive == nil:
+			return frameLog.Tap(source)
+		case frameLog == nil:
+			return archive.Tap(source)
+		}
+		logTap, archiveTap := frameLog.Tap(source), archive.Tap(source)
+		return func(recvNs int64, frame []byte) {
+			archiveTap(recvNs, frame)
+			logTap(recvNs, frame)
+		}
+	}
+}
+
+// bookSnapshots are the full books of the connectors that keep them and,
+// for every other book, its top of book as a one-level snapshot.
+func bookSnapshots(r *ws.Router, books *orderbook.Manager, depth int) []transport.BookSnapshot {
+	out := r.BookSnapshots(depth)
+	full := make(map[[3]string]bool, len(out))
+	for _, s := range out {
+		full[[3]string{s.Venue, s.Symbol, s.Category}] = true
+	}
+	now := time.Now().UnixMilli()
+	for _, b := range books.Books() {
+		if full[[3]string{b.Venue, b.Symbol, b.Category}] {
+			continue
+		}
+		out = append(out, transport.BookSnapshot{Venue: b.Venue, Symbol: b.Symbol, Category: b.Category,
+			Bids: []transport.BookLevel{{Price: b.BestBid, Size: b.BidSize}},
+			Asks: []transport.BookLevel{{Price: b.BestAsk, Size: b.AskSize}}, TsMs: now})
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Symbol != out[j].Symbol {
+			return out[i].Symbol < out[j].Symbol
+		}
+		return out[i].Venue < out[j].Venue
+	})
+	return out
+}
+
+// connectors builds one Connector per configured venue. Sim venues named
+// like the demo ones reuse their models; others get distinct seeds. Bybit
+// streams recover sequence gaps from their venue's REST API, paced by limits.
+// Helix venues read their bridge token from their credentials.
+func connectors(g config.Gateway, tap func(source string) func(int64, []byte), limits *ratelimit.Limiter) ([]ws.Connector, error) {
+	var out []ws.Connector
+	for i, v := range g.Venues {
+		switch v.Kind {
+		case config.KindHelix:
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			creds, err := secrets.Resolve(ctx, v.Credentials, "")
+			cancel()
+			if err != nil {
+				return nil, fmt.Errorf("%s credentials: %w", v.Name, err)
+			}
+			c := bridge.NewConnector(v.Name, v.WSPublic, creds.Key, v.Symbols)
+			c.Upstream, c.Logf = v.Upstream, log.Printf
+			out = append(out, c)
+		case config.KindBybit:
+			stream := ws.NewBybitStream(v.WSPublic, v.Symbols, v.Depth)
+			stream.Name = v.Name
+			stream.Category = v.Category
+			stream.Trades = v.Trades
+			stream.Liquidations = v.Liquidations
+			stream.Funding = v.Funding
+			stream.BookEvents = v.BookEvents
+			stream.Logf = log.Printf
+			stream.REST, stream.Limits = v.REST, limits
+			for _, sym := range v.Symbols {
+				if d := v.SymbolDepth(sym); d != v.Depth {
+					if stream.SymbolDepth == nil {
+						stream.SymbolDepth = map[string]int{}
+					}
+					stream.SymbolDepth[sym] = d
+				}
+			}
+			if tap != nil {
+				stream.Tap = tap(stream.Venue())
+			}
+			out = append(out, stream)
+		default:
+			sim := ws.DefaultSimConfig(v.Name)
+			sim.Seed = g.Router.SimSeed + int64(i) + 1
+			for _, demo := range ws.SyntheticConfigs(g.Router.SimSeed) {
+				if demo.Venue == v.Name {
+					sim = demo
+				}
+			}
+			sim.Symbols = v.Symbols
+			sim.Category = v.Category
+			out = append(out, ws.NewSimConnector(sim))
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,67 @@
+package gateway
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/helix-lab/helix/gateway/pkg/heatmap"
+	"github.com/helix-lab/helix/gateway/pkg/l2book"
+	"github.com/helix-lab/helix/gateway/pkg/parquet"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// liveHeatmaps builds a heatmap per book from -heatmap_dir's periodic
+// snapshots and writes them out at shutdown.
+type liveHeatmaps struct {
+	dir    string
+	format string
+	cfg    heatmap.Config
+	maps   map[[3]string]*heatmap.Heatmap
+}
+
+func (l *liveHeatmaps) add(snaps []transport.BookSnapshot) {
+	for _, s := range snaps {
+		key := [3]string{s.Venue, s.Symbol, s.Category}
+		h := l.maps[key]
+		if h == nil {
+			h = heatmap.New(l.cfg)
+			l.maps[key] = h
+		}
+		h.Add(s.TsMs, levels(s.Bids), levels(s.Asks))
+	}
+}
+
+func levels(ls []transport.BookLevel) []l2book.Level {
+	out := make([]l2book.Level, len(ls))
+	for i, l := range ls {
+		out[i] = l2book.Level{Price: l.Price, Size: l.Size}
+	}
+	return out
+}
+
+// write saves every heatmap as <venue>_<symbol>[_<category>].<format> and
+// returns the paths written.
+func (l *liveHeatmaps) write() ([]string, error) {
+	if err := os.MkdirAll(l.dir, 0o755); err != nil {
+		return nil, err
+	}
+	var paths []string
+	for key, h := range l.maps {
+		if len(h.Columns) == 0 {
+			continue
+		}
+		name := key[0] + "_" + key[1]
+		if key[2] != "" {
+			name += "_" + key[2]
+		}
+		path := filepath.Join(l.dir, strings.ToLower(name)+"."+l.format)
+		if err := h.WriteFile(path, parquet.Gzip); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
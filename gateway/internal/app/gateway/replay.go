@@ -0,0 +1,84 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/internal/app/replay"
+	"github.com/helix-lab/helix/gateway/pkg/admin"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// replayRunner lets the admin API replay a frame log into the live book
+// manager and publisher alongside the connectors.
+type replayRunner struct {
+	ctx     context.Context
+	onDepth func(transport.DepthUpdate)
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+	status admin.ReplayStatus
+}
+
+func newReplayRunner(ctx context.Context, onDepth func(transport.DepthUpdate)) *replayRunner {
+	return &replayRunner{ctx: ctx, onDepth: onDepth}
+}
+
+func (r *replayRunner) StartReplay(path string, speed float64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.status.Running {
+		return errors.New("a replay is already running")
+	}
+	ctx, cancel := context.WithCancel(r.ctx)
+	done := make(chan struct{})
+	r.cancel, r.done = cancel, done
+	r.status = admin.ReplayStatus{Running: true, Path: path, Speed: speed, StartedAt: time.Now()}
+	go func() {
+		defer close(done)
+		st, err := replay.Run(ctx, path, speed, r.onDepth)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.status.Running = false
+		r.status.Frames, r.status.Depth = st.Frames, st.Depth
+		if err != nil && !errors.Is(err, context.Canceled) {
+			r.status.Err = err.Error()
+		}
+		log.Printf("admin replay %s: frames=%d depth=%d err=%v", path, st.Frames, st.Depth, err)
+	}()
+	return nil
+}
+
+// StopReplay cancels the running replay and waits for it to exit.
+func (r *replayRunner) StopReplay() error {
+	r.mu.Lock()
+	if !r.status.Running {
+		r.mu.Unlock()
+		return errors.New("no replay running")
+	}
+	cancel, done := r.cancel, r.done
+	r.mu.Unlock()
+	cancel()
+	<-done
+	return nil
+}
+
+func (r *replayRunner) ReplayStatus() admin.ReplayStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// wait blocks until any running replay has exited.
+func (r *replayRunner) wait() {
+	r.mu.Lock()
+	done := r.done
+	r.mu.Unlock()
+	if done != nil {
+		<-done
+	}
+}
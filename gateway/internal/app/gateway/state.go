@@ -0,0 +1,62 @@
+package gateway
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/state"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+	"github.com/helix-lab/helix/gateway/pkg/ws"
+)
+
+// captureState snapshots what a restarted gateway needs to warm-start.
+func captureState(books *orderbook.Manager, tracker *executor.Tracker, r *ws.Router) func() state.State {
+	return func() state.State {
+		return state.State{
+			Books:         books.Books(),
+			Orders:        tracker.OpenOrders(),
+			Positions:     tracker.Positions(),
+			Subscriptions: r.Subscriptions(),
+		}
+	}
+}
+
+// warmStart restores orders, positions and subscriptions from path. Books
+// are only seeded when the state is younger than maxBookAge; the
+// connectors' own snapshots replace them either way. A missing file is a
+// cold start, not an error.
+func warmStart(path string, maxBookAge time.Duration, books *orderbook.Manager, tracker *executor.Tracker, r *ws.Router) error {
+	st, err := state.Load(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Printf("state: no %s, cold start", path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	age := st.Age().Truncate(time.Millisecond)
+	tracker.Restore(st.Orders, st.Positions)
+	for venue, symbols := range st.Subscriptions {
+		if err := r.SubscribeSymbols(venue, symbols...); err != nil {
+			log.Printf("state: restore %s subscriptions: %v", venue, err)
+		}
+	}
+	seeded := 0
+	if age <= maxBookAge {
+		for _, b := range st.Books {
+			books.Apply(transport.DepthUpdate{Venue: b.Venue, Symbol: b.Symbol, Category: b.Category,
+				BestBid: b.BestBid, BestAsk: b.BestAsk, BidSize: b.BidSize, AskSize: b.AskSize})
+			seeded++
+		}
+	}
+	log.Printf("state: warm start from %s saved %s ago: %d books seeded, %d open orders, %d positions",
+		path, age, seeded, len(st.Orders), len(st.Positions))
+	if len(st.Orders) > 0 {
+		log.Printf("state: %d orders were open at the last save and may still be live on the venues", len(st.Orders))
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package gateway
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/tsdb"
+)
+
+// tsdbWriter picks the writer for -tsdb_url: postgres:// URLs go to
+// TimescaleDB, anything else is an InfluxDB write endpoint.
+func tsdbWriter(rawURL, token string) (tsdb.Writer, error) {
+	if !strings.HasPrefix(rawURL, "postgres://") && !strings.HasPrefix(rawURL, "postgresql://") {
+		return &tsdb.Influx{URL: rawURL, Token: token}, nil
+	}
+	db, err := sql.Open("pgx", rawURL)
+	if err != nil {
+		return nil, err
+	}
+	w := &tsdb.Timescale{DB: db}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := w.Migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return w, nil
+}
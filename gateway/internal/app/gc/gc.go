@@ -0,0 +1,122 @@
+// Package gc implements "helix gc": age recorded captures out of a data
+// directory by a retention policy, so capture hosts don't fill their disks.
+package gc
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/internal/app"
+	"github.com/helix-lab/helix/gateway/pkg/catalog"
+)
+
+// Main runs "helix gc" with args (without the subcommand name).
+func Main(args []string) int {
+	fs := flag.NewFlagSet("gc", flag.ContinueOnError)
+	var common app.Common
+	common.Register(fs)
+	dir := fs.String("data", "", "Directory of recorded captures")
+	keepDays := fs.Float64("keep_days", 0, "Keep captures whose last row is younger than this many days (0 = forever)")
+	maxGB := fs.Float64("max_gb", 0, "Remove the oldest captures until the directory's captures fit in this many GB (0 = no limit)")
+	validated := fs.Bool("validated", true, "Only remove captures whose validation status is ok")
+	uploaded := fs.Bool("uploaded", false, "Only remove captures recorded as uploaded by -mark_uploaded")
+	dryRun := fs.Bool("dry_run", false, "Print what would be removed without removing it")
+	mark := fs.String("mark_uploaded", "", "Record the capture files given as arguments as uploaded to this destination, then exit")
+	if code := common.Parse(fs, args); code >= 0 {
+		return code
+	}
+	defer common.Close()
+
+	if *mark != "" {
+		if fs.NArg() == 0 {
+			fmt.Fprintln(os.Stderr, "gc: -mark_uploaded needs capture files as arguments")
+			return app.ExitUsage
+		}
+		for _, path := range fs.Args() {
+			if err := catalog.MarkUploaded(path, *mark, time.Now()); err != nil {
+				log.Printf("gc: %v", err)
+				return app.ExitFailure
+			}
+		}
+		return app.ExitOK
+	}
+	if *dir == "" {
+		fmt.Fprintln(os.Stderr, "gc: need -data")
+		return app.ExitUsage
+	}
+	cfg, err := common.Config()
+	if err != nil {
+		log.Printf("gc: %v", err)
+		return app.ExitConfig
+	}
+	if cfg != nil {
+		r := cfg.Gateway.Recorder.Retention
+		if r.KeepDays != nil && !app.IsSet(fs, "keep_days") {
+			*keepDays = *r.KeepDays
+		}
+		if r.MaxGB != nil && !app.IsSet(fs, "max_gb") {
+			*maxGB = *r.MaxGB
+		}
+		if r.Validated != nil && !app.IsSet(fs, "validated") {
+			*validated = *r.Validated
+		}
+		if r.Uploaded != nil && !app.IsSet(fs, "uploaded") {
+			*uploaded = *r.Uploaded
+		}
+	}
+	if *keepDays < 0 || *maxGB < 0 {
+		fmt.Fprintln(os.Stderr, "gc: -keep_days and -max_gb must not be negative")
+		return app.ExitUsage
+	}
+
+	cat, err := catalog.Scan(*dir)
+	if err != nil {
+		log.Printf("gc: %v", err)
+		return app.ExitFailure
+	}
+	policy := catalog.Retention{
+		KeepFor:   time.Duration(*keepDays * float64(24*time.Hour)),
+		MaxBytes:  int64(*maxGB * 1e9),
+		Validated: *validated,
+		Uploaded:  *uploaded,
+	}
+	plan := catalog.PlanRetention(cat, policy, time.Now())
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ACTION\tPATH\tEND\tBYTES\tREASON")
+	for _, r := range plan.Remove {
+		fmt.Fprintf(w, "remove\t%s\t%s\t%d\t%s\n", r.Dataset.Path, ts(r.Dataset.EndMs), r.Dataset.Bytes, r.Reason)
+	}
+	for _, r := range plan.Held {
+		fmt.Fprintf(w, "keep\t%s\t%s\t%d\t%s\n", r.Dataset.Path, ts(r.Dataset.EndMs), r.Dataset.Bytes, r.Reason)
+	}
+	w.Flush()
+
+	code := app.ExitOK
+	if !*dryRun {
+		for _, r := range plan.Remove {
+			if err := catalog.Remove(r.Dataset); err != nil {
+				log.Printf("gc: %v", err)
+				code = app.ExitFailure
+			}
+		}
+	}
+	verb := "freed"
+	if *dryRun {
+		verb = "would free"
+	}
+	log.Printf("gc: %d of %d captures removed, %d held; %s %d of %d bytes",
+		len(plan.Remove), len(cat.Datasets), len(plan.Held), verb, plan.Freed, plan.Bytes)
+	return code
+}
+
+func ts(ms int64) string {
+	if ms == 0 {
+		return "-"
+	}
+	return time.UnixMilli(ms).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,63 @@
+// Package heatmap implements "helix heatmap": rebuild a recorded L2
+// capture's book and export its liquidity as a time×price matrix.
+package heatmap
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/internal/app"
+	"github.com/helix-lab/helix/gateway/pkg/heatmap"
+	"github.com/helix-lab/helix/gateway/pkg/parquet"
+)
+
+// Main runs "helix heatmap" with args (without the subcommand name).
+func Main(args []string) int {
+	fs := flag.NewFlagSet("heatmap", flag.ContinueOnError)
+	var common app.Common
+	common.Register(fs)
+	inPath := fs.String("in", "", "L2 capture CSV to rebuild")
+	outPath := fs.String("out", "", "Output path; .csv, .parquet or .png picks the format")
+	every := fs.Duration("every", time.Second, "Time bucket")
+	tick := fs.Float64("tick", 0, "Price bucket (0 = the capture's smallest level gap)")
+	levels := fs.Int("levels", 50, "Levels per side sampled into each time bucket")
+	codecName := fs.String("codec", "gzip", "Parquet page compression: gzip or none")
+	if code := common.Parse(fs, args); code >= 0 {
+		return code
+	}
+	defer common.Close()
+	if *inPath == "" || *outPath == "" {
+		fmt.Fprintln(os.Stderr, "usage: helix heatmap -in l2.csv -out heatmap.png|.csv|.parquet [-every 1s] [-tick 0] [-levels 50]")
+		return app.ExitUsage
+	}
+	if *every < time.Millisecond || *levels < 1 || *tick < 0 {
+		fmt.Fprintln(os.Stderr, "heatmap: -every must be at least 1ms, -levels at least 1 and -tick not negative")
+		return app.ExitUsage
+	}
+	codec, err := parquet.ParseCodec(*codecName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "heatmap: -codec: %v\n", err)
+		return app.ExitUsage
+	}
+
+	in, err := os.Open(*inPath)
+	if err != nil {
+		log.Printf("heatmap: %v", err)
+		return app.ExitStartup
+	}
+	defer in.Close()
+	h, st, err := heatmap.FromCapture(in, heatmap.Config{Every: *every, Tick: *tick, Levels: *levels})
+	if err != nil {
+		log.Printf("heatmap: %s: %v", *inPath, err)
+		return app.ExitFailure
+	}
+	if err := h.WriteFile(*outPath, codec); err != nil {
+		log.Printf("heatmap: %v", err)
+		return app.ExitFailure
+	}
+	log.Printf("heatmap: %s -> %s (%d samples, tick %g, %d skipped)", *inPath, *outPath, len(h.Columns), h.Tick, st.Skipped)
+	return app.ExitOK
+}
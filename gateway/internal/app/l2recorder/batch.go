@@ -0,0 +1,66 @@
+package l2recorder
+
+import "sync"
+
+// rowBatch is one message's rows on their way to the writer. Prices and
+// sizes are copied into text, since the frame they were decoded from is
+// reused by the connection as soon as the handler returns. Batches come
+// from rowBatches and go back once written, so a sustained stream
+// allocates nothing per message.
+type rowBatch struct {
+	rows []csvRow
+	text []byte
+	// flag is a self-check divergence for the writer to record.
+	flag string
+}
+
+// span is a field's position in its batch's text.
+type span struct{ from, to int }
+
+func (b *rowBatch) add(v []byte) span {
+	from := len(b.text)
+	b.text = append(b.text, v...)
+	return span{from, len(b.text)}
+}
+
+func (b *rowBatch) field(s span) []byte { return b.text[s.from:s.to] }
+
+// maxPooledRows keeps a rare huge snapshot from pinning its batch.
+const maxPooledRows = 4096
+
+var rowBatches = sync.Pool{New: func() any { return new(rowBatch) }}
+
+func getBatch() *rowBatch {
+	b := rowBatches.Get().(*rowBatch)
+	b.rows, b.text, b.flag = b.rows[:0], b.text[:0], ""
+	return b
+}
+
+func putBatch(b *rowBatch) {
+	if cap(b.rows) <= maxPooledRows {
+		rowBatches.Put(b)
+	}
+}
+
+// appendField appends one CSV field, quoted only when it has to be, as
+// encoding/csv would write it.
+func appendField(dst, f []byte) []byte {
+	quote := len(f) > 0 && (f[0] == ' ' || f[0] == '\t')
+	for _, c := range f {
+		if c == ',' || c == '"' || c == '\r' || c == '\n' {
+			quote = true
+			break
+		}
+	}
+	if !quote {
+		return append(dst, f...)
+	}
+	dst = append(dst, '"')
+	for _, c := range f {
+		if c == '"' {
+			dst = append(dst, '"')
+		}
+		dst = append(dst, c)
+	}
+	return append(dst, '"')
+}
@@ -0,0 +1,360 @@
+package l2recorder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/internal/app"
+	"github.com/helix-lab/helix/gateway/pkg/capture"
+	"github.com/helix-lab/helix/gateway/pkg/clock"
+	"github.com/helix-lab/helix/gateway/pkg/wsclient"
+)
+
+const (
+	binanceVersion = "binance_recorder/1.0"
+	binanceVenue   = "BINANCE"
+	// binanceRefetch spaces snapshot requests while none lines up with
+	// the stream.
+	binanceRefetch = time.Second
+)
+
+// binanceDepth is one depthUpdate. Futures streams carry pu, the previous
+// event's u; spot streams chain by U == previous u + 1.
+type binanceDepth struct {
+	Event string      `json:"e"`
+	Time  int64       `json:"E"`
+	Trade int64       `json:"T"`
+	First int64       `json:"U"`
+	Last  int64       `json:"u"`
+	Prev  int64       `json:"pu"`
+	Bids  [][2]string `json:"b"`
+	Asks  [][2]string `json:"a"`
+}
+
+// binanceSnapshot is the REST depth response.
+type binanceSnapshot struct {
+	LastUpdateID int64       `json:"lastUpdateId"`
+	Time         int64       `json:"E"`
+	Bids         [][2]string `json:"bids"`
+	Asks         [][2]string `json:"asks"`
+}
+
+// BinanceMain runs "helix record binance-l2" with args (without the
+// subcommand name): Binance's depth-diff stream, synced to a REST snapshot
+// and resynced on every break, written in the same CSV schema as "helix
+// record l2" so bookcheck and the rest read either venue.
+func BinanceMain(args []string) int {
+	fs := flag.NewFlagSet("record binance-l2", flag.ContinueOnError)
+	var common app.Common
+	common.Register(fs)
+	symbol := fs.String("symbol", "BTCUSDT", "Binance symbol, e.g. BTCUSDT")
+	endpoint := fs.String("endpoint", "wss://fstream.binance.com/ws", "Binance websocket endpoint (futures or spot)")
+	restURL := fs.String("rest", "https://fapi.binance.com/fapi/v1/depth", "Binance REST depth endpoint the stream is synced to")
+	limit := fs.Int("limit", 1000, "Levels per side in the REST snapshot")
+	speed := fs.String("speed", "100ms", "Depth stream update speed: 100ms, 250ms or 500ms")
+	out := fs.String("out", "data/replay/binance_l2.csv", "CSV file to write L2 deltas (ts_ms,seq,prev_seq,book_side,price,size,type)")
+	duration := fs.Duration("duration", time.Minute, "How long to record before exiting")
+	venueName := fs.String("venue", "", "Venue name for the sidecar and the venue column (empty = BINANCE)")
+	stopAt := fs.String("stop_at", "", "Stop at this RFC3339 wall time instead of after -duration")
+	progress := fs.Duration("progress", 0, "Print a JSON progress line (rows, msgs/sec, reconnects, lag) to stdout this often (0 = off)")
+	archivePath := fs.String("archive", "", "Also archive every raw frame, lossless, with nanosecond receive times to this binary file (plus .idx), for 'helix replay'")
+	staleAfter := fs.Duration("stale_after", 10*time.Second, "Reconnect when no book data arrived for this long (0 = off, only the read timeout)")
+	columns := fs.String("columns", "", "Comma-separated CSV columns to write, as for 'helix record l2' (empty = the config's recorder.columns, else the default)")
+	rotate := fs.Duration("rotate", 0, "Start a new segment file at every multiple of this (e.g. 1h) and list them in a run manifest (0 = one file)")
+	if code := common.Parse(fs, args); code >= 0 {
+		return code
+	}
+	defer common.Close()
+	var colNames []string
+	if *columns != "" {
+		colNames = strings.Split(*columns, ",")
+	} else if cfg, err := common.Config(); err == nil && cfg != nil {
+		colNames = cfg.Gateway.Recorder.Columns
+	}
+	cols, err := parseColumns(colNames, false)
+	if err != nil {
+		log.Printf("-columns: %v", err)
+		return app.ExitUsage
+	}
+	switch *speed {
+	case "100ms", "250ms", "500ms":
+	default:
+		log.Printf("-speed: want 100ms, 250ms or 500ms, got %q", *speed)
+		return app.ExitUsage
+	}
+	if *venueName == "" {
+		*venueName = binanceVenue
+	}
+
+	rootCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	clk := clock.Real
+	startWall := clk.Now()
+	endWall := startWall.Add(*duration)
+	if *stopAt != "" {
+		if endWall, err = time.Parse(time.RFC3339Nano, *stopAt); err != nil {
+			log.Printf("-stop_at: %v", err)
+			return app.ExitUsage
+		}
+	}
+	runCtx, cancel := context.WithDeadline(rootCtx, endWall)
+	defer cancel()
+
+	if err := os.MkdirAll(filepath.Dir(*out), 0o755); err != nil {
+		log.Printf("mkdir output dir: %v", err)
+		return app.ExitStartup
+	}
+	topic := strings.ToLower(*symbol) + "@depth@" + *speed
+	segs, err := newSegments(clk, *out, *rotate, false, metaInfo{
+		Version:   binanceVersion,
+		Symbol:    *symbol,
+		Venue:     *venueName,
+		Endpoint:  *endpoint,
+		Depth:     *limit,
+		Topic:     topic,
+		Columns:   cols,
+		StartTime: startWall.Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		log.Printf("%v", err)
+		return app.ExitStartup
+	}
+	var archive *capture.Archive
+	if *archivePath != "" {
+		if archive, err = capture.OpenArchive(*archivePath, 0); err != nil {
+			log.Printf("open archive: %v", err)
+			return app.ExitStartup
+		}
+	}
+
+	var conn connStats
+	st := &stream{topic: topic, out: *out, segs: segs, rows: make(chan *rowBatch, batchChanSize), done: make(chan struct{})}
+	st.prog = &app.Progress{Recorder: "binance_l2", Topic: topic, Out: *out, Reconnects: conn.reconnects.Load, Stale: conn.stale.Load}
+	go func() {
+		defer close(st.done)
+		atomic.StoreUint64(&st.written, writerLoop(runCtx, clk, st.segs, st.rows, st.prog))
+	}()
+	log.Printf("recording %s (%s, snapshots from %s), out=%s", topic, *endpoint, *restURL, *out)
+	if *progress > 0 {
+		go st.prog.Run(runCtx, os.Stdout, *progress)
+	}
+
+	bs := &binanceSync{
+		ctx:    runCtx,
+		clk:    clk,
+		client: &http.Client{Timeout: 10 * time.Second},
+		rest:   *restURL,
+		symbol: strings.ToUpper(*symbol),
+		limit:  *limit,
+		st:     st,
+	}
+	var tap func(int64, []byte)
+	if archive != nil {
+		tap = archive.Tap(binanceVenue)
+	}
+	client := wsclient.New(wsclient.Config{
+		Endpoint:         *endpoint,
+		Topics:           []string{topic},
+		SubscribeRequest: wsclient.BinanceSubscribe,
+		ParseAck:         wsclient.BinanceAck,
+		StaleAfter:       *staleAfter,
+		Tap:              tap,
+		OnMessage:        bs.handle,
+		// events missed while disconnected need a new snapshot
+		OnConnect: func(int) { bs.synced, bs.snap = false, nil },
+		OnReconnect: func(err error) {
+			conn.reconnects.Add(1)
+			if errors.Is(err, wsclient.ErrStale) {
+				conn.stale.Add(1)
+			}
+		},
+	})
+	_ = client.Run(runCtx)
+
+	close(st.rows)
+	<-st.done
+	if archive != nil {
+		if err := archive.Close(); err != nil {
+			log.Printf("close archive: %v", err)
+		}
+	}
+	if err := segs.close(); err != nil {
+		log.Printf("finalize %s: %v", *out, err)
+	}
+	log.Printf("recorded %s of %s, rows=%d, snapshots=%d, resyncs=%d, csv=%s",
+		time.Since(startWall).Truncate(time.Second), topic, atomic.LoadUint64(&st.written), bs.snapshots, bs.resyncs, *out)
+	if *progress > 0 {
+		st.prog.Emit(os.Stdout, "done")
+	}
+	if bs.snapshots == 0 {
+		log.Printf("never synced to a snapshot")
+		return app.ExitFailure
+	}
+	return app.ExitOK
+}
+
+// binanceSync keeps the stream in step with a REST snapshot: events that
+// end before the snapshot are dropped, the first one spanning it follows
+// the snapshot's rows, and a break in the U/u (or pu) chain starts over
+// with a new snapshot. It runs on the connection's goroutine.
+type binanceSync struct {
+	ctx    context.Context
+	clk    clock.Clock
+	client *http.Client
+	rest   string
+	symbol string
+	limit  int
+	st     *stream
+
+	// snap is fetched and waiting for the event that spans it
+	snap      *binanceSnapshot
+	nextFetch time.Time
+	synced    bool
+	lastSeq   int64
+	ev        binanceDepth
+
+	snapshots, resyncs int
+}
+
+func (s *binanceSync) handle(frame []byte) bool {
+	s.ev = binanceDepth{Bids: s.ev.Bids[:0], Asks: s.ev.Asks[:0]}
+	if err := json.Unmarshal(frame, &s.ev); err != nil || s.ev.Event != "depthUpdate" {
+		return false
+	}
+	ev := &s.ev
+	recv := s.clk.Now().UnixMilli()
+	ts := ev.Trade
+	if ts == 0 {
+		ts = ev.Time
+	}
+	if ts == 0 {
+		ts = recv
+	}
+	s.st.prog.Msgs.Add(1)
+	s.st.prog.LastTsMs.Store(ts)
+
+	if s.synced {
+		next := ev.First == s.lastSeq+1
+		if ev.Prev != 0 {
+			next = ev.Prev == s.lastSeq
+		}
+		if next {
+			s.send(s.rows(ts, recv, ev.Last, s.lastSeq, "delta", ev.Bids, ev.Asks))
+			s.lastSeq = ev.Last
+			return true
+		}
+		if ev.Last <= s.lastSeq {
+			return true
+		}
+		log.Printf("binance: %s chain broke after %d (event %d-%d); resyncing", s.symbol, s.lastSeq, ev.First, ev.Last)
+		s.synced = false
+		s.resyncs++
+	}
+	if s.snap == nil {
+		if s.clk.Now().Before(s.nextFetch) {
+			return true
+		}
+		snap, err := s.fetch()
+		if err != nil {
+			log.Printf("binance: snapshot: %v", err)
+			s.nextFetch = s.clk.Now().Add(binanceRefetch)
+			return true
+		}
+		s.snap = snap
+	}
+	id := s.snap.LastUpdateID
+	switch {
+	case ev.Last <= id:
+		// ended at or before the snapshot
+		return true
+	case ev.First > id+1:
+		// the snapshot is older than the stream; fetch a newer one
+		s.snap, s.nextFetch = nil, s.clk.Now().Add(binanceRefetch)
+		return true
+	}
+	snapTs := s.snap.Time
+	if snapTs == 0 {
+		snapTs = recv
+	}
+	prev := s.lastSeq
+	if prev == 0 {
+		prev = id - 1
+	}
+	s.send(s.rows(snapTs, recv, id, prev, "snapshot", s.snap.Bids, s.snap.Asks))
+	s.send(s.rows(ts, recv, ev.Last, id, "delta", ev.Bids, ev.Asks))
+	s.snap, s.synced, s.lastSeq = nil, true, ev.Last
+	s.snapshots++
+	return true
+}
+
+// rows is one message's levels as a batch.
+func (s *binanceSync) rows(ts, recv, seq, prev int64, typ string, bids, asks [][2]string) *rowBatch {
+	b := getBatch()
+	for _, side := range []struct {
+		name   string
+		levels [][2]string
+	}{{"bid", bids}, {"ask", asks}} {
+		for _, l := range side.levels {
+			b.rows = append(b.rows, csvRow{tsMs: ts, seq: seq, prevSeq: prev, recvMs: recv, side: side.name,
+				price: b.add([]byte(l[0])), size: b.add([]byte(l[1])), rowType: typ})
+		}
+	}
+	return b
+}
+
+func (s *binanceSync) send(b *rowBatch) {
+	if len(b.rows) == 0 {
+		putBatch(b)
+		return
+	}
+	select {
+	case s.st.rows <- b:
+	case <-s.ctx.Done():
+		putBatch(b)
+	}
+}
+
+func (s *binanceSync) fetch() (*binanceSnapshot, error) {
+	u, err := url.Parse(s.rest)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("symbol", s.symbol)
+	q.Set("limit", fmt.Sprint(s.limit))
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var snap binanceSnapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snap); err != nil {
+		return nil, err
+	}
+	if snap.LastUpdateID == 0 {
+		return nil, errors.New("no lastUpdateId")
+	}
+	return &snap, nil
+}
@@ -0,0 +1,79 @@
+package l2recorder
+
+import (
+	"fmt"
+	"strings"
+)
+
+// column is one CSV column the recorder can write.
+type column int
+
+const (
+	colTsMs column = iota
+	colSeq
+	colPrevSeq
+	colBookSide
+	colPrice
+	colSize
+	colBestBid
+	colBestAsk
+	colBidSize
+	colAskSize
+	colType
+	colRecvTsMs
+	colSymbol
+	colVenue
+)
+
+var columnNames = map[string]column{
+	"ts_ms": colTsMs, "seq": colSeq, "prev_seq": colPrevSeq,
+	"book_side": colBookSide, "price": colPrice, "size": colSize,
+	"best_bid": colBestBid, "best_ask": colBestAsk, "bid_size": colBidSize, "ask_size": colAskSize,
+	"type": colType, "recv_ts_ms": colRecvTsMs, "symbol": colSymbol, "venue": colVenue,
+}
+
+// The default headers, and the columns readers cannot rebuild the book
+// without.
+var (
+	deltaColumns  = []string{"ts_ms", "seq", "prev_seq", "book_side", "price", "size", "type"}
+	bboColumns    = []string{"ts_ms", "seq", "prev_seq", "best_bid", "best_ask", "bid_size", "ask_size", "type"}
+	deltaRequired = []string{"ts_ms", "seq", "book_side", "price", "size", "type"}
+	bboRequired   = []string{"ts_ms", "seq", "best_bid", "best_ask", "bid_size", "ask_size", "type"}
+	// extraColumns fit either format.
+	extraColumns = []string{"prev_seq", "recv_ts_ms", "symbol", "venue"}
+)
+
+// parseColumns checks a column selection for the format, in the order it
+// will be written; an empty one is the format's default header.
+func parseColumns(names []string, bbo bool) ([]string, error) {
+	defaults, required := deltaColumns, deltaRequired
+	if bbo {
+		defaults, required = bboColumns, bboRequired
+	}
+	if len(names) == 0 {
+		return defaults, nil
+	}
+	allowed := map[string]bool{}
+	for _, n := range append(required, extraColumns...) {
+		allowed[n] = true
+	}
+	seen := map[string]bool{}
+	out := make([]string, 0, len(names))
+	for _, n := range names {
+		n = strings.ToLower(strings.TrimSpace(n))
+		switch {
+		case !allowed[n]:
+			return nil, fmt.Errorf("column %q: want one of %s", n, strings.Join(append(required, extraColumns...), ","))
+		case seen[n]:
+			return nil, fmt.Errorf("column %q given twice", n)
+		}
+		seen[n] = true
+		out = append(out, n)
+	}
+	for _, n := range required {
+		if !seen[n] {
+			return nil, fmt.Errorf("missing column %q, which readers need to rebuild the book", n)
+		}
+	}
+	return out, nil
+}
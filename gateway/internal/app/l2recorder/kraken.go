@@ -0,0 +1,411 @@
+package l2recorder
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/internal/app"
+	"github.com/helix-lab/helix/gateway/pkg/capture"
+	"github.com/helix-lab/helix/gateway/pkg/catalog"
+	"github.com/helix-lab/helix/gateway/pkg/clock"
+	"github.com/helix-lab/helix/gateway/pkg/wsclient"
+)
+
+const (
+	krakenVersion = "kraken_recorder/1.0"
+	krakenVenue   = "KRAKEN"
+)
+
+// krakenMsg is one Kraken Futures push: a book_snapshot, a one-level book
+// update, a trade_snapshot or a trade. Numbers stay as sent.
+type krakenMsg struct {
+	Feed      string        `json:"feed"`
+	ProductID string        `json:"product_id"`
+	Seq       int64         `json:"seq"`
+	Timestamp int64         `json:"timestamp"`
+	Side      string        `json:"side"`
+	Price     json.Number   `json:"price"`
+	Qty       json.Number   `json:"qty"`
+	Bids      []krakenLevel `json:"bids"`
+	Asks      []krakenLevel `json:"asks"`
+	// trades
+	UID    string        `json:"uid"`
+	Time   int64         `json:"time"`
+	Trades []krakenTrade `json:"trades"`
+}
+
+type krakenLevel struct {
+	Price json.Number `json:"price"`
+	Qty   json.Number `json:"qty"`
+}
+
+type krakenTrade struct {
+	UID   string      `json:"uid"`
+	Side  string      `json:"side"`
+	Seq   int64       `json:"seq"`
+	Time  int64       `json:"time"`
+	Price json.Number `json:"price"`
+	Qty   json.Number `json:"qty"`
+}
+
+// KrakenMain runs "helix record kraken" with args (without the subcommand
+// name): a Kraken Futures product's book feed, written in the same CSV
+// schema as "helix record l2" and resubscribed for a fresh snapshot on a
+// seq gap, and its trade feed in the schema of "helix record trades".
+func KrakenMain(args []string) int {
+	fs := flag.NewFlagSet("record kraken", flag.ContinueOnError)
+	var common app.Common
+	common.Register(fs)
+	symbol := fs.String("symbol", "PF_XBTUSD", "Kraken Futures product ID, e.g. PF_XBTUSD or PI_XBTUSD")
+	endpoint := fs.String("endpoint", "wss://futures.kraken.com/ws/v1", "Kraken Futures public websocket endpoint")
+	out := fs.String("out", "data/replay/kraken_l2.csv", "CSV file to write L2 deltas (ts_ms,seq,prev_seq,book_side,price,size,type)")
+	tradesOut := fs.String("trades_out", "data/replay/kraken_trades.csv", "CSV file to write trades (ts_ms,side,price,size,trade_id) (empty = no trade feed)")
+	duration := fs.Duration("duration", time.Minute, "How long to record before exiting")
+	venueName := fs.String("venue", "", "Venue name for the sidecars and the venue column (empty = KRAKEN)")
+	stopAt := fs.String("stop_at", "", "Stop at this RFC3339 wall time instead of after -duration")
+	progress := fs.Duration("progress", 0, "Print a JSON progress line (rows, msgs/sec, reconnects, lag) to stdout this often (0 = off)")
+	archivePath := fs.String("archive", "", "Also archive every raw frame, lossless, with nanosecond receive times to this binary file (plus .idx), for 'helix replay'")
+	staleAfter := fs.Duration("stale_after", 10*time.Second, "Reconnect when no book or trade data arrived for this long (0 = off, only the read timeout)")
+	columns := fs.String("columns", "", "Comma-separated L2 CSV columns to write, as for 'helix record l2' (empty = the config's recorder.columns, else the default)")
+	rotate := fs.Duration("rotate", 0, "Start a new L2 segment file at every multiple of this (e.g. 1h) and list them in a run manifest (0 = one file)")
+	if code := common.Parse(fs, args); code >= 0 {
+		return code
+	}
+	defer common.Close()
+	var colNames []string
+	if *columns != "" {
+		colNames = strings.Split(*columns, ",")
+	} else if cfg, err := common.Config(); err == nil && cfg != nil {
+		colNames = cfg.Gateway.Recorder.Columns
+	}
+	cols, err := parseColumns(colNames, false)
+	if err != nil {
+		log.Printf("-columns: %v", err)
+		return app.ExitUsage
+	}
+	if *venueName == "" {
+		*venueName = krakenVenue
+	}
+
+	rootCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	clk := clock.Real
+	startWall := clk.Now()
+	endWall := startWall.Add(*duration)
+	if *stopAt != "" {
+		if endWall, err = time.Parse(time.RFC3339Nano, *stopAt); err != nil {
+			log.Printf("-stop_at: %v", err)
+			return app.ExitUsage
+		}
+	}
+	runCtx, cancel := context.WithDeadline(rootCtx, endWall)
+	defer cancel()
+
+	if err := os.MkdirAll(filepath.Dir(*out), 0o755); err != nil {
+		log.Printf("mkdir output dir: %v", err)
+		return app.ExitStartup
+	}
+	topic := "book:" + *symbol
+	segs, err := newSegments(clk, *out, *rotate, false, metaInfo{
+		Version:   krakenVersion,
+		Symbol:    *symbol,
+		Venue:     *venueName,
+		Endpoint:  *endpoint,
+		Topic:     topic,
+		Columns:   cols,
+		StartTime: startWall.Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		log.Printf("%v", err)
+		return app.ExitStartup
+	}
+	topics := []string{topic}
+	kr := &krakenSync{ctx: runCtx, clk: clk, product: *symbol}
+	if *tradesOut != "" {
+		if kr.trades, err = newKrakenTrades(*tradesOut, metaInfo{
+			Version:   krakenVersion,
+			Symbol:    *symbol,
+			Venue:     *venueName,
+			Endpoint:  *endpoint,
+			Topic:     "trade:" + *symbol,
+			Columns:   krakenTradesHeader,
+			StartTime: startWall.Format(time.RFC3339Nano),
+		}); err != nil {
+			log.Printf("%v", err)
+			return app.ExitStartup
+		}
+		topics = append(topics, "trade:"+*symbol)
+	}
+	var archive *capture.Archive
+	if *archivePath != "" {
+		if archive, err = capture.OpenArchive(*archivePath, 0); err != nil {
+			log.Printf("open archive: %v", err)
+			return app.ExitStartup
+		}
+	}
+
+	var conn connStats
+	st := &stream{topic: topic, out: *out, segs: segs, rows: make(chan *rowBatch, batchChanSize), done: make(chan struct{})}
+	st.prog = &app.Progress{Recorder: "kraken", Topic: topic, Out: *out, Reconnects: conn.reconnects.Load, Stale: conn.stale.Load}
+	kr.st = st
+	go func() {
+		defer close(st.done)
+		atomic.StoreUint64(&st.written, writerLoop(runCtx, clk, st.segs, st.rows, st.prog))
+	}()
+	log.Printf("recording %s (%s), out=%s, trades_out=%s", strings.Join(topics, ","), *endpoint, *out, *tradesOut)
+	if *progress > 0 {
+		go st.prog.Run(runCtx, os.Stdout, *progress)
+	}
+
+	var tap func(int64, []byte)
+	if archive != nil {
+		tap = archive.Tap(krakenVenue)
+	}
+	client := wsclient.New(wsclient.Config{
+		Endpoint:           *endpoint,
+		Topics:             topics,
+		SubscribeRequest:   wsclient.KrakenSubscribe,
+		UnsubscribeRequest: wsclient.KrakenUnsubscribe,
+		ParseAck:           wsclient.KrakenAck(topics),
+		MaxArgsPerRequest:  1,
+		// Kraken drops connections that send nothing for a minute
+		PingInterval: 30 * time.Second,
+		StaleAfter:   *staleAfter,
+		Tap:          tap,
+		OnMessage:    kr.handle,
+		// a new connection starts with a new snapshot
+		OnConnect: func(int) { kr.synced = false },
+		OnReconnect: func(err error) {
+			conn.reconnects.Add(1)
+			if errors.Is(err, wsclient.ErrStale) {
+				conn.stale.Add(1)
+			}
+		},
+	})
+	kr.resubscribe = client.Resubscribe
+	_ = client.Run(runCtx)
+
+	close(st.rows)
+	<-st.done
+	if archive != nil {
+		if err := archive.Close(); err != nil {
+			log.Printf("close archive: %v", err)
+		}
+	}
+	if err := segs.close(); err != nil {
+		log.Printf("finalize %s: %v", *out, err)
+	}
+	if kr.trades != nil {
+		if err := kr.trades.close(); err != nil {
+			log.Printf("finalize %s: %v", *tradesOut, err)
+		}
+	}
+	log.Printf("recorded %s of %s, rows=%d, snapshots=%d, seq_gaps=%d, trades=%d, csv=%s",
+		time.Since(startWall).Truncate(time.Second), *symbol, atomic.LoadUint64(&st.written), kr.snapshots, kr.gaps, kr.tradeRows, *out)
+	if *progress > 0 {
+		st.prog.Emit(os.Stdout, "done")
+	}
+	if kr.snapshots == 0 {
+		log.Printf("never received a book snapshot")
+		return app.ExitFailure
+	}
+	return app.ExitOK
+}
+
+// krakenSync writes the book feed from its snapshot on, checking the seq
+// chain: a gap drops the book and resubscribes, and nothing is written
+// until the new snapshot. Trades are written once each, by seq; those a
+// resubscribe's or reconnect's trade_snapshot holds past the last one
+// written fill the gap. It runs on the connection's goroutine.
+type krakenSync struct {
+	ctx         context.Context
+	clk         clock.Clock
+	product     string
+	st          *stream
+	trades      *krakenTrades
+	resubscribe func()
+
+	synced  bool
+	lastSeq int64
+	// lastTrade is the seq of the last trade written; tradesStarted is
+	// set by the first trade_snapshot, which is history from before the
+	// recording.
+	lastTrade     int64
+	tradesStarted bool
+	msg           krakenMsg
+
+	snapshots, gaps, tradeRows int
+}
+
+func (s *krakenSync) handle(frame []byte) bool {
+	s.msg = krakenMsg{}
+	if err := json.Unmarshal(frame, &s.msg); err != nil || s.msg.Feed == "" {
+		return false
+	}
+	if s.msg.ProductID != "" && s.msg.ProductID != s.product {
+		return false
+	}
+	recv := s.clk.Now().UnixMilli()
+	m := &s.msg
+	switch m.Feed {
+	case "book_snapshot":
+		ts := orNow(m.Timestamp, recv)
+		s.st.prog.Msgs.Add(1)
+		s.st.prog.LastTsMs.Store(ts)
+		prev := s.lastSeq
+		if prev == 0 {
+			prev = m.Seq - 1
+		}
+		b := getBatch()
+		for _, side := range []struct {
+			name   string
+			levels []krakenLevel
+		}{{"bid", m.Bids}, {"ask", m.Asks}} {
+			for _, l := range side.levels {
+				b.rows = append(b.rows, csvRow{tsMs: ts, seq: m.Seq, prevSeq: prev, recvMs: recv, side: side.name,
+					price: b.add([]byte(l.Price)), size: b.add([]byte(l.Qty)), rowType: "snapshot"})
+			}
+		}
+		s.send(b)
+		s.synced, s.lastSeq = true, m.Seq
+		s.snapshots++
+	case "book":
+		ts := orNow(m.Timestamp, recv)
+		s.st.prog.Msgs.Add(1)
+		s.st.prog.LastTsMs.Store(ts)
+		switch {
+		case !s.synced || m.Seq <= s.lastSeq:
+			// before the snapshot we are waiting for, or a repeat
+			return true
+		case m.Seq != s.lastSeq+1:
+			log.Printf("kraken: %s book seq %d after %d; resubscribing", s.product, m.Seq, s.lastSeq)
+			s.gaps++
+			s.synced = false
+			s.resubscribe()
+			return true
+		}
+		side := "ask"
+		if m.Side == "buy" {
+			side = "bid"
+		}
+		b := getBatch()
+		b.rows = append(b.rows, csvRow{tsMs: ts, seq: m.Seq, prevSeq: s.lastSeq, recvMs: recv, side: side,
+			price: b.add([]byte(m.Price)), size: b.add([]byte(m.Qty)), rowType: "delta"})
+		s.send(b)
+		s.lastSeq = m.Seq
+	case "trade_snapshot":
+		if s.trades == nil {
+			return false
+		}
+		sort.Slice(m.Trades, func(i, j int) bool { return m.Trades[i].Seq < m.Trades[j].Seq })
+		if !s.tradesStarted {
+			s.tradesStarted = true
+			if n := len(m.Trades); n > 0 {
+				s.lastTrade = m.Trades[n-1].Seq
+			}
+			return true
+		}
+		for _, t := range m.Trades {
+			s.trade(t)
+		}
+		s.trades.flush()
+	case "trade":
+		if s.trades == nil {
+			return false
+		}
+		s.tradesStarted = true
+		s.trade(krakenTrade{UID: m.UID, Side: m.Side, Seq: m.Seq, Time: m.Time, Price: m.Price, Qty: m.Qty})
+		s.trades.flush()
+	default:
+		// heartbeats and ticker feeds
+		return false
+	}
+	return true
+}
+
+func (s *krakenSync) trade(t krakenTrade) {
+	if t.Seq <= s.lastTrade {
+		return
+	}
+	if err := s.trades.write(t); err != nil {
+		log.Printf("trades write: %v", err)
+		return
+	}
+	s.lastTrade = t.Seq
+	s.tradeRows++
+}
+
+func (s *krakenSync) send(b *rowBatch) {
+	if len(b.rows) == 0 {
+		putBatch(b)
+		return
+	}
+	select {
+	case s.st.rows <- b:
+	case <-s.ctx.Done():
+		putBatch(b)
+	}
+}
+
+func orNow(ts, now int64) int64 {
+	if ts == 0 {
+		return now
+	}
+	return ts
+}
+
+var krakenTradesHeader = []string{"ts_ms", "side", "price", "size", "trade_id"}
+
+// krakenTrades is the trades CSV, sealed into its sidecar on close.
+type krakenTrades struct {
+	path string
+	f    *os.File
+	bw   *bufio.Writer
+	w    *csv.Writer
+	rec  []string
+}
+
+func newKrakenTrades(path string, meta metaInfo) (*krakenTrades, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	meta.OutputCSV, meta.OutputMeta = path, sidecarMetaPath(path)
+	if err := writeMeta(meta.OutputMeta, meta); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	t := &krakenTrades{path: path, f: f, bw: bufio.NewWriterSize(f, bufioSize), rec: make([]string, len(krakenTradesHeader))}
+	t.w = csv.NewWriter(t.bw)
+	return t, t.w.Write(krakenTradesHeader)
+}
+
+func (t *krakenTrades) write(tr krakenTrade) error {
+	t.rec[0], t.rec[1], t.rec[2], t.rec[3], t.rec[4] = strconv.FormatInt(tr.Time, 10), tr.Side, tr.Price.String(), tr.Qty.String(), tr.UID
+	return t.w.Write(t.rec)
+}
+
+func (t *krakenTrades) flush() { t.w.Flush() }
+
+func (t *krakenTrades) close() error {
+	t.w.Flush()
+	if err := errors.Join(t.w.Error(), t.bw.Flush(), t.f.Close()); err != nil {
+		return err
+	}
+	return catalog.Seal(t.path)
+}
@@ -0,0 +1,373 @@
+package l2recorder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"hash/crc32"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/internal/app"
+	"github.com/helix-lab/helix/gateway/pkg/capture"
+	"github.com/helix-lab/helix/gateway/pkg/clock"
+	"github.com/helix-lab/helix/gateway/pkg/wsclient"
+)
+
+const (
+	okxVersion = "okx_recorder/1.0"
+	okxVenue   = "OKX"
+	// okxChecksumDepth is how many levels per side OKX's checksum covers.
+	okxChecksumDepth = 25
+)
+
+// okxBooks is one push on a books channel; Data holds one book message.
+type okxBooks struct {
+	Arg struct {
+		Channel string `json:"channel"`
+		InstID  string `json:"instId"`
+	} `json:"arg"`
+	Action string `json:"action"`
+	Data   []struct {
+		Asks      [][]string `json:"asks"`
+		Bids      [][]string `json:"bids"`
+		Ts        string     `json:"ts"`
+		Checksum  int32      `json:"checksum"`
+		SeqID     int64      `json:"seqId"`
+		PrevSeqID int64      `json:"prevSeqId"`
+	} `json:"data"`
+}
+
+// OKXMain runs "helix record okx-l2" with args (without the subcommand
+// name): an OKX books channel, checked message by message against its
+// CRC32 checksum and seqId chain and resubscribed for a fresh snapshot on
+// any mismatch, written in the same CSV schema as "helix record l2".
+func OKXMain(args []string) int {
+	fs := flag.NewFlagSet("record okx-l2", flag.ContinueOnError)
+	var common app.Common
+	common.Register(fs)
+	symbol := fs.String("symbol", "BTC-USDT-SWAP", "OKX instrument ID, e.g. BTC-USDT-SWAP or BTC-USDT")
+	channel := fs.String("channel", "books-l2-tbt", "Books channel: books-l2-tbt, books50-l2-tbt or books (the tbt channels need an account OKX serves them to)")
+	endpoint := fs.String("endpoint", "wss://ws.okx.com:8443/ws/v5/public", "OKX public websocket endpoint")
+	out := fs.String("out", "data/replay/okx_l2.csv", "CSV file to write L2 deltas (ts_ms,seq,prev_seq,book_side,price,size,type)")
+	duration := fs.Duration("duration", time.Minute, "How long to record before exiting")
+	venueName := fs.String("venue", "", "Venue name for the sidecar and the venue column (empty = OKX)")
+	stopAt := fs.String("stop_at", "", "Stop at this RFC3339 wall time instead of after -duration")
+	progress := fs.Duration("progress", 0, "Print a JSON progress line (rows, msgs/sec, reconnects, lag) to stdout this often (0 = off)")
+	archivePath := fs.String("archive", "", "Also archive every raw frame, lossless, with nanosecond receive times to this binary file (plus .idx), for 'helix replay'")
+	staleAfter := fs.Duration("stale_after", 10*time.Second, "Reconnect when no book data arrived for this long (0 = off, only the read timeout)")
+	columns := fs.String("columns", "", "Comma-separated CSV columns to write, as for 'helix record l2' (empty = the config's recorder.columns, else the default)")
+	rotate := fs.Duration("rotate", 0, "Start a new segment file at every multiple of this (e.g. 1h) and list them in a run manifest (0 = one file)")
+	if code := common.Parse(fs, args); code >= 0 {
+		return code
+	}
+	defer common.Close()
+	var colNames []string
+	if *columns != "" {
+		colNames = strings.Split(*columns, ",")
+	} else if cfg, err := common.Config(); err == nil && cfg != nil {
+		colNames = cfg.Gateway.Recorder.Columns
+	}
+	cols, err := parseColumns(colNames, false)
+	if err != nil {
+		log.Printf("-columns: %v", err)
+		return app.ExitUsage
+	}
+	switch *channel {
+	case "books-l2-tbt", "books50-l2-tbt", "books":
+	default:
+		log.Printf("-channel: want books-l2-tbt, books50-l2-tbt or books, got %q", *channel)
+		return app.ExitUsage
+	}
+	if *venueName == "" {
+		*venueName = okxVenue
+	}
+
+	rootCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	clk := clock.Real
+	startWall := clk.Now()
+	endWall := startWall.Add(*duration)
+	if *stopAt != "" {
+		if endWall, err = time.Parse(time.RFC3339Nano, *stopAt); err != nil {
+			log.Printf("-stop_at: %v", err)
+			return app.ExitUsage
+		}
+	}
+	runCtx, cancel := context.WithDeadline(rootCtx, endWall)
+	defer cancel()
+
+	if err := os.MkdirAll(filepath.Dir(*out), 0o755); err != nil {
+		log.Printf("mkdir output dir: %v", err)
+		return app.ExitStartup
+	}
+	topic := *channel + ":" + *symbol
+	segs, err := newSegments(clk, *out, *rotate, false, metaInfo{
+		Version:   okxVersion,
+		Symbol:    *symbol,
+		Venue:     *venueName,
+		Endpoint:  *endpoint,
+		Topic:     topic,
+		Columns:   cols,
+		StartTime: startWall.Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		log.Printf("%v", err)
+		return app.ExitStartup
+	}
+	var archive *capture.Archive
+	if *archivePath != "" {
+		if archive, err = capture.OpenArchive(*archivePath, 0); err != nil {
+			log.Printf("open archive: %v", err)
+			return app.ExitStartup
+		}
+	}
+
+	var conn connStats
+	st := &stream{topic: topic, out: *out, segs: segs, rows: make(chan *rowBatch, batchChanSize), done: make(chan struct{})}
+	st.prog = &app.Progress{Recorder: "okx_l2", Topic: topic, Out: *out, Reconnects: conn.reconnects.Load, Stale: conn.stale.Load}
+	go func() {
+		defer close(st.done)
+		atomic.StoreUint64(&st.written, writerLoop(runCtx, clk, st.segs, st.rows, st.prog))
+	}()
+	log.Printf("recording %s (%s), out=%s", topic, *endpoint, *out)
+	if *progress > 0 {
+		go st.prog.Run(runCtx, os.Stdout, *progress)
+	}
+
+	ox := &okxSync{ctx: runCtx, clk: clk, inst: *symbol, st: st}
+	var tap func(int64, []byte)
+	if archive != nil {
+		tap = archive.Tap(okxVenue)
+	}
+	client := wsclient.New(wsclient.Config{
+		Endpoint:           *endpoint,
+		Topics:             []string{topic},
+		SubscribeRequest:   wsclient.OKXSubscribe,
+		UnsubscribeRequest: wsclient.OKXUnsubscribe,
+		ParseAck:           wsclient.OKXAck,
+		StaleAfter:         *staleAfter,
+		Tap:                tap,
+		OnMessage:          ox.handle,
+		// a new connection starts with a new snapshot
+		OnConnect: func(int) { ox.synced = false },
+		OnReconnect: func(err error) {
+			conn.reconnects.Add(1)
+			if errors.Is(err, wsclient.ErrStale) {
+				conn.stale.Add(1)
+			}
+		},
+	})
+	ox.resubscribe = client.Resubscribe
+	_ = client.Run(runCtx)
+
+	close(st.rows)
+	<-st.done
+	if archive != nil {
+		if err := archive.Close(); err != nil {
+			log.Printf("close archive: %v", err)
+		}
+	}
+	if err := segs.close(); err != nil {
+		log.Printf("finalize %s: %v", *out, err)
+	}
+	log.Printf("recorded %s of %s, rows=%d, snapshots=%d, checksum_mismatches=%d, seq_gaps=%d, csv=%s",
+		time.Since(startWall).Truncate(time.Second), topic, atomic.LoadUint64(&st.written), ox.snapshots, ox.mismatches, ox.gaps, *out)
+	if *progress > 0 {
+		st.prog.Emit(os.Stdout, "done")
+	}
+	if ox.snapshots == 0 {
+		log.Printf("never received a snapshot")
+		return app.ExitFailure
+	}
+	return app.ExitOK
+}
+
+// okxSync keeps a local book from the channel's snapshot and updates and
+// checks each message against OKX's checksum of it before writing the
+// message. A mismatch or a break in the seqId chain drops the book and
+// resubscribes; nothing is written until the new snapshot. It runs on the
+// connection's goroutine.
+type okxSync struct {
+	ctx         context.Context
+	clk         clock.Clock
+	inst        string
+	st          *stream
+	resubscribe func()
+
+	bids, asks okxSide
+	synced     bool
+	lastSeq    int64
+	msg        okxBooks
+	buf        []byte
+
+	snapshots, mismatches, gaps int
+}
+
+func (s *okxSync) handle(frame []byte) bool {
+	s.msg = okxBooks{}
+	if err := json.Unmarshal(frame, &s.msg); err != nil || s.msg.Action == "" || len(s.msg.Data) == 0 {
+		return false
+	}
+	if s.msg.Arg.InstID != "" && s.msg.Arg.InstID != s.inst {
+		return false
+	}
+	recv := s.clk.Now().UnixMilli()
+	for _, d := range s.msg.Data {
+		ts, _ := strconv.ParseInt(d.Ts, 10, 64)
+		if ts == 0 {
+			ts = recv
+		}
+		s.st.prog.Msgs.Add(1)
+		s.st.prog.LastTsMs.Store(ts)
+
+		prev := d.PrevSeqID
+		switch {
+		case s.msg.Action == "snapshot":
+			s.bids.reset(d.Bids, true)
+			s.asks.reset(d.Asks, false)
+			prev = s.lastSeq
+			if prev == 0 {
+				prev = d.SeqID - 1
+			}
+		case !s.synced:
+			// updates from before the snapshot we are waiting for
+			continue
+		case d.PrevSeqID != s.lastSeq:
+			log.Printf("okx: %s seqId chain broke after %d (update %d after %d); resubscribing", s.inst, s.lastSeq, d.SeqID, d.PrevSeqID)
+			s.gaps++
+			s.restart()
+			return true
+		default:
+			s.bids.apply(d.Bids, true)
+			s.asks.apply(d.Asks, false)
+		}
+		if sum := s.checksum(); sum != d.Checksum {
+			log.Printf("okx: %s checksum %d, book gives %d at seqId %d; resubscribing", s.inst, d.Checksum, sum, d.SeqID)
+			s.mismatches++
+			s.restart()
+			return true
+		}
+		typ := "delta"
+		if s.msg.Action == "snapshot" {
+			typ = "snapshot"
+			s.snapshots++
+		}
+		s.send(s.rows(ts, recv, d.SeqID, prev, typ, d.Bids, d.Asks))
+		s.synced, s.lastSeq = true, d.SeqID
+	}
+	return true
+}
+
+// restart drops the book until the snapshot a resubscribe brings.
+func (s *okxSync) restart() {
+	s.synced = false
+	s.bids.levels, s.asks.levels = s.bids.levels[:0], s.asks.levels[:0]
+	s.resubscribe()
+}
+
+// checksum is OKX's CRC32 of the top 25 levels, bids and asks
+// interleaved as bidPx:bidSz:askPx:askSz:..., with the strings as sent.
+func (s *okxSync) checksum() int32 {
+	b := s.buf[:0]
+	for i := 0; i < okxChecksumDepth; i++ {
+		for _, side := range []*okxSide{&s.bids, &s.asks} {
+			if i < len(side.levels) {
+				if len(b) > 0 {
+					b = append(b, ':')
+				}
+				b = append(b, side.levels[i].px...)
+				b = append(b, ':')
+				b = append(b, side.levels[i].sz...)
+			}
+		}
+	}
+	s.buf = b
+	return int32(crc32.ChecksumIEEE(b))
+}
+
+// rows is one message's levels as a batch.
+func (s *okxSync) rows(ts, recv, seq, prev int64, typ string, bids, asks [][]string) *rowBatch {
+	b := getBatch()
+	for _, side := range []struct {
+		name   string
+		levels [][]string
+	}{{"bid", bids}, {"ask", asks}} {
+		for _, l := range side.levels {
+			if len(l) < 2 {
+				continue
+			}
+			b.rows = append(b.rows, csvRow{tsMs: ts, seq: seq, prevSeq: prev, recvMs: recv, side: side.name,
+				price: b.add([]byte(l[0])), size: b.add([]byte(l[1])), rowType: typ})
+		}
+	}
+	return b
+}
+
+func (s *okxSync) send(b *rowBatch) {
+	if len(b.rows) == 0 {
+		putBatch(b)
+		return
+	}
+	select {
+	case s.st.rows <- b:
+	case <-s.ctx.Done():
+		putBatch(b)
+	}
+}
+
+// okxLevel keeps the price and size strings OKX sent, which its checksum
+// is computed over.
+type okxLevel struct {
+	price  float64
+	px, sz string
+}
+
+// okxSide is one side of the book, best first.
+type okxSide struct {
+	levels []okxLevel
+}
+
+func (b *okxSide) reset(levels [][]string, bids bool) {
+	b.levels = b.levels[:0]
+	b.apply(levels, bids)
+}
+
+func (b *okxSide) apply(levels [][]string, bids bool) {
+	for _, l := range levels {
+		if len(l) < 2 {
+			continue
+		}
+		price, err := strconv.ParseFloat(l[0], 64)
+		if err != nil {
+			continue
+		}
+		i := sort.Search(len(b.levels), func(i int) bool {
+			if bids {
+				return b.levels[i].price <= price
+			}
+			return b.levels[i].price >= price
+		})
+		found := i < len(b.levels) && b.levels[i].price == price
+		size, _ := strconv.ParseFloat(l[1], 64)
+		switch {
+		case size == 0 && found:
+			b.levels = append(b.levels[:i], b.levels[i+1:]...)
+		case size == 0:
+		case found:
+			b.levels[i] = okxLevel{price: price, px: l[0], sz: l[1]}
+		default:
+			b.levels = append(b.levels, okxLevel{})
+			copy(b.levels[i+1:], b.levels[i:])
+			b.levels[i] = okxLevel{price: price, px: l[0], sz: l[1]}
+		}
+	}
+}
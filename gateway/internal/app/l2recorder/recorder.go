@@ -0,0 +1,628 @@
+package l2recorder
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/helix-lab/helix/gateway/internal/app"
+	"github.com/helix-lab/helix/gateway/pkg/capture"
+	"github.com/helix-lab/helix/gateway/pkg/catalog"
+	"github.com/helix-lab/helix/gateway/pkg/clock"
+	"github.com/helix-lab/helix/gateway/pkg/decimal"
+	"github.com/helix-lab/helix/gateway/pkg/l2book"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/ws/bybitjson"
+	"github.com/helix-lab/helix/gateway/pkg/wsclient"
+)
+
+const (
+	progVersion = "bybit_recorder/1.1"
+	// defaultVenue is what the venue column holds without -venue.
+	defaultVenue = "BYBIT"
+
+	// Writer performance knobs
+	batchChanSize = 1024 // messages, each a rowBatch
+	bookCheckChan = 512
+	bufioSize     = 1 << 20 // 1MB
+	flushEveryN   = 200
+	flushEveryDur = 500 * time.Millisecond
+)
+
+type metaInfo struct {
+	Version  string `json:"version"`
+	Symbol   string `json:"symbol"`
+	Venue    string `json:"venue,omitempty"`
+	Endpoint string `json:"endpoint"`
+	Depth    int    `json:"depth"`
+	Topic    string `json:"topic"`
+	Format   string `json:"format,omitempty"`
+	// Columns is the CSV header, for readers of files split without it.
+	Columns    []string `json:"columns"`
+	StartTime  string   `json:"start_time"`
+	OutputCSV  string   `json:"output_csv"`
+	OutputMeta string   `json:"output_meta"`
+	// SelfCheck is where -selfcheck_every found the capture's book
+	// diverging; empty while it has not.
+	SelfCheck string `json:"selfcheck,omitempty"`
+}
+
+// 传给 writer 的最小数据结构：price/size 保留原始文本（在 rowBatch.text 里），避免 float/format 成本
+type csvRow struct {
+	tsMs    int64
+	seq     int64
+	prevSeq int64
+	recvMs  int64
+	side    string
+	price   span
+	size    span
+	rowType string
+	// -bbo_only 时只用 top，不用 side/price/size
+	top bboTop
+}
+
+// bboTop is a reconstructed best bid/ask.
+type bboTop struct {
+	bestBid, bidSz, bestAsk, askSz float64
+}
+
+type bookCheckRow struct {
+	tsMs    int64
+	seq     int64
+	bestBid float64
+	bestAsk float64
+	bidSz   float64
+	askSz   float64
+	// raw is the top as quoted (bid, bid size, ask, ask size), kept for
+	// -bookcheck_format raw
+	raw [4]string
+}
+
+// Main runs "helix record l2" with args (without the subcommand name).
+func Main(args []string) int {
+	fs := flag.NewFlagSet("record l2", flag.ContinueOnError)
+	var common app.Common
+	common.Register(fs)
+	symbol := fs.String("symbol", "BTCUSDT", "Bybit symbol, e.g. BTCUSDT")
+	endpoint := fs.String("endpoint", "wss://stream.bybit.com/v5/public/linear", "Bybit public websocket endpoint")
+	depth := fs.Int("depth", 1, "Orderbook depth to subscribe (1 or 50)")
+	out := fs.String("out", "data/replay/bybit_l2.csv", "CSV file to write L2 deltas (ts_ms,seq,prev_seq,book_side,price,size,type)")
+	duration := fs.Duration("duration", time.Minute, "How long to record before exiting")
+	venueName := fs.String("venue", "", "Venue name for the sidecar and the venue column (empty = BYBIT, the catalog going by the endpoint)")
+	stopAt := fs.String("stop_at", "", "Stop at this RFC3339 wall time instead of after -duration, so recorders started apart stop together")
+	bookcheck := fs.String("bookcheck", "", "Optional path to write sampled top-of-book for determinism check")
+	bookcheckEvery := fs.Int("bookcheck_every", 100, "Sample every N messages into bookcheck (only if --bookcheck set)")
+	bookcheckFormat := fs.String("bookcheck_format", l2book.FormatFixed, "How -bookcheck writes prices and sizes: fixed (-bookcheck_digits decimals), sig (-bookcheck_digits significant digits) or raw (as the venue quoted them); match 'helix bookcheck -format' to diff the two")
+	bookcheckDigits := fs.Int("bookcheck_digits", l2book.DefaultDigits, "Decimals or significant digits for -bookcheck_format")
+	crossDepth := fs.Int("cross_depth", 0, "Also record this orderbook depth (e.g. 50 with -depth 1) on the same connection, for 'helix crosscheck'")
+	crossOut := fs.String("cross_out", "", "CSV for the -cross_depth stream (empty = <out>.depth<N>.csv)")
+	bboOnly := fs.Bool("bbo_only", false, "Write a row (ts_ms,seq,prev_seq,best_bid,best_ask,bid_size,ask_size,type) only when the rebuilt top of book changes")
+	progress := fs.Duration("progress", 0, "Print a JSON progress line per stream (rows, msgs/sec, reconnects, lag) to stdout this often (0 = off)")
+	archivePath := fs.String("archive", "", "Also archive every raw frame, lossless, with nanosecond receive times to this binary file (plus .idx), for 'helix replay'")
+	staleAfter := fs.Duration("stale_after", 10*time.Second, "Reconnect when no book data arrived for this long, even if the connection looks alive (0 = off, only the read timeout)")
+	selfCheckEvery := fs.Int("selfcheck_every", 0, "Rebuild the book from the recorded levels as 'helix bookcheck' would and compare it with the recorder's own every N messages, flagging the capture's meta on divergence (0 = off)")
+	selfCheckAbort := fs.Bool("selfcheck_abort", false, "Stop recording and exit 1 when -selfcheck_every finds a divergence")
+	columns := fs.String("columns", "", "Comma-separated CSV columns to write, in order: the default header's (see -out and -bbo_only), of which prev_seq may be dropped, plus recv_ts_ms, symbol and venue (empty = the config's recorder.columns, else the default)")
+	rotate := fs.Duration("rotate", 0, "Start a new segment file at every multiple of this (e.g. 1h) and list them in a run manifest (0 = one file)")
+	if code := common.Parse(fs, args); code >= 0 {
+		return code
+	}
+	defer common.Close()
+	if err := common.BybitDefaults(fs, endpoint, symbol, depth); err != nil {
+		log.Printf("config: %v", err)
+		return 1
+	}
+	var colNames []string
+	if *columns != "" {
+		colNames = strings.Split(*columns, ",")
+	} else if cfg, err := common.Config(); err == nil && cfg != nil {
+		colNames = cfg.Gateway.Recorder.Columns
+	}
+	cols, err := parseColumns(colNames, *bboOnly)
+	if err != nil {
+		log.Printf("-columns: %v", err)
+		return app.ExitUsage
+	}
+	bcFormat, err := l2book.ParseNumFormat(*bookcheckFormat, *bookcheckDigits)
+	if err != nil {
+		log.Printf("-bookcheck_format: %v", err)
+		return app.ExitUsage
+	}
+	var stopWall time.Time
+	if *stopAt != "" {
+		if stopWall, err = time.Parse(time.RFC3339Nano, *stopAt); err != nil {
+			log.Printf("-stop_at: %v", err)
+			return app.ExitUsage
+		}
+	}
+
+	// Ctrl+C support
+	rootCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	clk := clock.Real
+	startWall := clk.Now()
+	endWall := startWall.Add(*duration)
+	if !stopWall.IsZero() {
+		endWall = stopWall
+	}
+
+	runCtx, cancel := context.WithDeadline(rootCtx, endWall)
+	defer cancel()
+
+	// Ensure output dir exists
+	outDir := filepath.Dir(*out)
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		log.Fatalf("mkdir output dir: %v", err)
+	}
+
+	// Write the meta sidecars and open the (first) CSVs, one per depth
+	depths := []int{*depth}
+	if *crossDepth > 0 {
+		if *crossDepth == *depth {
+			log.Printf("-cross_depth must differ from -depth")
+			return app.ExitUsage
+		}
+		depths = append(depths, *crossDepth)
+	}
+	var streams []*stream
+	var conn connStats
+	for i, d := range depths {
+		st := &stream{topic: fmt.Sprintf("orderbook.%d.%s", d, *symbol), out: *out}
+		st.prog = &app.Progress{Recorder: "l2", Topic: st.topic, Reconnects: conn.reconnects.Load, Stale: conn.stale.Load}
+		if i > 0 {
+			st.out = *crossOut
+			if st.out == "" {
+				ext := filepath.Ext(*out)
+				st.out = fmt.Sprintf("%s.depth%d%s", strings.TrimSuffix(*out, ext), d, ext)
+			}
+		}
+		st.prog.Out = st.out
+		segs, err := newSegments(clk, st.out, *rotate, *bboOnly, metaInfo{
+			Version:   progVersion,
+			Symbol:    *symbol,
+			Venue:     *venueName,
+			Endpoint:  *endpoint,
+			Depth:     d,
+			Topic:     st.topic,
+			Columns:   cols,
+			StartTime: startWall.Format(time.RFC3339Nano),
+		})
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		log.Printf("meta written: %s", sidecarMetaPath(segs.path))
+
+		// Channel: reader -> writer
+		st.segs, st.rows, st.done = segs, make(chan *rowBatch, batchChanSize), make(chan struct{})
+		go func() {
+			defer close(st.done)
+			n := writerLoop(runCtx, clk, st.segs, st.rows, st.prog)
+			atomic.StoreUint64(&st.written, n)
+		}()
+		streams = append(streams, st)
+	}
+	var archive *capture.Archive
+	if *archivePath != "" {
+		a, err := capture.OpenArchive(*archivePath, 0)
+		if err != nil {
+			log.Fatalf("open archive: %v", err)
+		}
+		archive = a
+	}
+	bcCh := make(chan bookCheckRow, bookCheckChan)
+
+	// bookcheck writer if requested
+	bcDone := make(chan struct{})
+	if *bookcheck == "" {
+		close(bcDone)
+	} else {
+		bcPath := *bookcheck
+		bcF, err := os.Create(bcPath)
+		if err != nil {
+			log.Fatalf("open bookcheck: %v", err)
+		}
+		go func() {
+			defer close(bcDone)
+			defer bcF.Close()
+			bw := bufio.NewWriterSize(bcF, bufioSize)
+			w := csv.NewWriter(bw)
+			w.Write(l2book.BookcheckHeader)
+			w.Flush()
+			ticker := clk.NewTicker(flushEveryDur)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-runCtx.Done():
+					w.Flush()
+					bw.Flush()
+					return
+				case row, ok := <-bcCh:
+					if !ok {
+						w.Flush()
+						bw.Flush()
+						return
+					}
+					rec := []string{
+						strconv.FormatInt(row.tsMs, 10),
+						strconv.FormatInt(row.seq, 10),
+						bcFormat.Format(row.bestBid, row.raw[0]),
+						bcFormat.Format(row.bestAsk, row.raw[2]),
+						bcFormat.Format(row.bidSz, row.raw[1]),
+						bcFormat.Format(row.askSz, row.raw[3]),
+					}
+					if err := w.Write(rec); err != nil {
+						log.Printf("bookcheck write err: %v", err)
+					}
+				case <-ticker.C():
+					w.Flush()
+					bw.Flush()
+				}
+			}
+		}()
+	}
+
+	for _, st := range streams {
+		log.Printf("recording %s (%s), out=%s", st.topic, *endpoint, st.out)
+		if *progress > 0 {
+			go st.prog.Run(runCtx, os.Stdout, *progress)
+		}
+	}
+
+	// Start reader loop (handles reconnect + subscribe)
+	var tap func(int64, []byte)
+	if archive != nil {
+		tap = archive.Tap("BYBIT")
+	}
+	// an aborting self-check stops only the reader, so the writers still
+	// drain what it queued, the flag included
+	readCtx, stopRead := context.WithCancel(runCtx)
+	defer stopRead()
+	var check *selfCheck
+	if *selfCheckEvery > 0 {
+		var abort func()
+		if *selfCheckAbort {
+			abort = stopRead
+		}
+		check = newSelfCheck(*selfCheckEvery, abort)
+	}
+	readLoop(readCtx, *endpoint, *staleAfter, streams, &conn, tap, bcCh, *bookcheckEvery, *bookcheck != "", bcFormat.Mode == l2book.FormatRaw, *bboOnly, check)
+
+	// Reader is done => close channels so writers can drain and exit
+	for _, st := range streams {
+		close(st.rows)
+	}
+	close(bcCh)
+	for _, st := range streams {
+		<-st.done
+	}
+	<-bcDone
+	if archive != nil {
+		if err := archive.Close(); err != nil {
+			log.Printf("close archive: %v", err)
+		}
+		log.Printf("archived %d frames (%d stalls) to %s", archive.Written(), archive.Stalls(), *archivePath)
+	}
+	if n := conn.reconnects.Load(); n > 0 {
+		log.Printf("reconnected %d times, %d for a stale stream", n, conn.stale.Load())
+	}
+
+	// finalize: checksum the data files into the sidecars
+	elapsed := time.Since(startWall).Truncate(time.Second)
+	for i, st := range streams {
+		var sealed []string
+		if i == 0 && *bookcheck != "" {
+			sealed = append(sealed, *bookcheck)
+		}
+		if err := st.segs.close(sealed...); err != nil {
+			log.Printf("finalize %s: %v", st.out, err)
+		}
+		if st.segs.run != nil {
+			log.Printf("recorded %s of %s, rows=%d, segments=%d, run=%s",
+				elapsed, st.topic, atomic.LoadUint64(&st.written), len(st.segs.run.Segments), catalog.ManifestPath(st.out))
+		} else {
+			log.Printf("recorded %s of %s, rows=%d, csv=%s, meta=%s",
+				elapsed, st.topic, atomic.LoadUint64(&st.written), st.out, sidecarMetaPath(st.out))
+		}
+		if *progress > 0 {
+			st.prog.Emit(os.Stdout, "done")
+		}
+	}
+	if check != nil && check.failed != "" && *selfCheckAbort {
+		return app.ExitFailure
+	}
+	return 0
+}
+
+// stream is one subscribed orderbook topic and its output.
+type stream struct {
+	topic   string
+	out     string
+	segs    *segments
+	rows    chan *rowBatch
+	written uint64
+	done    chan struct{}
+	prog    *app.Progress
+}
+
+// connStats counts the reader's reconnects, and which of them a stale
+// stream forced, for the progress lines.
+type connStats struct {
+	reconnects atomic.Uint64
+	stale      atomic.Uint64
+}
+
+// 读/解析：只做 JSON + 本地 top-of-book，重连/心跳交给 wsclient，写盘完全交给 writer
+// streams[0] is the primary topic; only it feeds bookcheck and check.
+// With bboOnly a row is written only when a message changes the top of
+// book; its prev_seq is the previous row's seq while the upstream chain is
+// unbroken, and the upstream prev_seq across a break, so continuity can
+// still be checked.
+func readLoop(ctx context.Context, endpoint string, staleAfter time.Duration, streams []*stream, conn *connStats, tap func(int64, []byte), bc chan<- bookCheckRow, bcEvery int, enableBC, bcRaw, bboOnly bool, check *selfCheck) {
+	type topicState struct {
+		bids, asks *l2book.Ladder
+		lastSeq    int64
+		msgCount   int
+		out        chan<- *rowBatch
+		prog       *app.Progress
+		primary    bool
+		// the primary's quoted strings, for a raw bookcheck
+		raw *l2book.Raw
+		// bbo-only
+		lastTop     bboTop
+		lastWritten int64
+		broken      bool
+	}
+	states := make(map[string]*topicState, len(streams))
+	topics := make([]string, 0, len(streams))
+	for i, st := range streams {
+		states[st.topic] = &topicState{bids: l2book.NewLadder(true), asks: l2book.NewLadder(false), out: st.rows, prog: st.prog, primary: i == 0}
+		if i == 0 && enableBC && bcRaw {
+			states[st.topic].raw = l2book.NewRaw()
+		}
+		topics = append(topics, st.topic)
+	}
+
+	getTop := func(st *topicState) (bestBid, bidSz, bestAsk, askSz float64) {
+		bid, bsz, _ := st.bids.Best()
+		ask, asz, _ := st.asks.Best()
+		return bid.Float(), bsz.Float(), ask.Float(), asz.Float()
+	}
+
+	// frames arrive one at a time on the one connection, so msg is reused
+	var msg bybitjson.Book
+	handle := func(data []byte) bool {
+		if err := msg.Decode(data); err != nil {
+			return false
+		}
+		if len(msg.Bids) == 0 && len(msg.Asks) == 0 {
+			return false
+		}
+		st := states[string(msg.Topic)]
+		if st == nil {
+			return false
+		}
+		// top-of-book requires [price, size]
+		recv := time.Now().UnixMilli()
+		ts := msg.Ts
+		if ts == 0 {
+			ts = recv
+		}
+
+		seq := msg.U
+		prev := msg.Pu
+		if msg.Seq != 0 {
+			seq = msg.Seq
+		}
+		if prev == 0 && st.lastSeq > 0 {
+			prev = st.lastSeq
+		}
+		if prev == 0 && seq > 0 {
+			prev = seq - 1
+		}
+		if st.lastSeq > 0 && prev != st.lastSeq {
+			st.broken = true
+		}
+		st.lastSeq = seq
+		st.prog.Msgs.Add(1)
+		st.prog.LastTsMs.Store(ts)
+
+		var rowType string
+		switch string(msg.Type) {
+		case "snapshot":
+			rowType = "snapshot"
+		case "delta":
+			rowType = "delta"
+		default:
+			rowType = string(msg.Type)
+		}
+		batch := getBatch()
+		emit := func(levels []bybitjson.Level, side string) {
+			ladder := st.asks
+			if side == "bid" {
+				ladder = st.bids
+			}
+			for _, lvl := range levels {
+				px, _ := decimal.ParseBytes(lvl.Price)
+				qty, _ := decimal.ParseBytes(lvl.Size)
+				ladder.Set(px, qty)
+				if st.raw != nil {
+					st.raw.Set(side == "bid", px, qty, string(lvl.Price), string(lvl.Size))
+				}
+				if bboOnly {
+					continue
+				}
+				batch.rows = append(batch.rows, csvRow{
+					tsMs:    ts,
+					seq:     seq,
+					prevSeq: prev,
+					recvMs:  recv,
+					side:    side,
+					price:   batch.add(lvl.Price),
+					size:    batch.add(lvl.Size),
+					rowType: rowType,
+				})
+			}
+		}
+
+		if msg.IsSnapshot() {
+			st.bids.Reset()
+			st.asks.Reset()
+			if st.raw != nil {
+				st.raw.Reset()
+			}
+		}
+
+		emit(msg.Bids, "bid")
+		emit(msg.Asks, "ask")
+		if bboOnly {
+			var top bboTop
+			top.bestBid, top.bidSz, top.bestAsk, top.askSz = getTop(st)
+			if top != st.lastTop || st.broken || msg.IsSnapshot() {
+				row := csvRow{tsMs: ts, seq: seq, prevSeq: st.lastWritten, recvMs: recv, rowType: rowType, top: top}
+				if st.lastWritten == 0 || st.broken {
+					row.prevSeq = prev
+				}
+				batch.rows = append(batch.rows, row)
+				st.lastTop, st.lastWritten, st.broken = top, seq, false
+			}
+		}
+		if st.primary && check != nil && check.failed == "" {
+			err := check.message(ts, seq, prev, msg.IsSnapshot(), msg.Bids, msg.Asks, func() (top bboTop) {
+				top.bestBid, top.bidSz, top.bestAsk, top.askSz = getTop(st)
+				return top
+			})
+			if err != nil {
+				check.failed = fmt.Sprintf("seq %d (ts %d): %v", seq, ts, err)
+				log.Printf("selfcheck: %s diverged at %s", streams[0].topic, check.failed)
+				// the writer flags the segment this message lands in
+				batch.flag = check.failed
+			}
+		}
+		// the writer may recycle the batch as soon as it has it
+		flagged := batch.flag != ""
+		if len(batch.rows) == 0 && !flagged {
+			putBatch(batch)
+		} else {
+			select {
+			case st.out <- batch:
+			case <-ctx.Done():
+				putBatch(batch)
+				return true
+			}
+		}
+		if flagged && check.abort != nil {
+			check.abort()
+		}
+
+		st.msgCount++
+		if st.primary && enableBC && bcEvery > 0 && st.msgCount%bcEvery == 0 {
+			bestBid, bidSz, bestAsk, askSz := getTop(st)
+			row := bookCheckRow{tsMs: ts, seq: seq, bestBid: bestBid, bestAsk: bestAsk, bidSz: bidSz, askSz: askSz}
+			if st.raw != nil {
+				row.raw[0], row.raw[1], row.raw[2], row.raw[3] = st.raw.Top(st.bids, st.asks)
+			}
+			select {
+			case bc <- row:
+			default:
+			}
+		}
+		return true
+	}
+
+	client := wsclient.New(wsclient.Config{
+		Endpoint:   endpoint,
+		Topics:     topics,
+		StaleAfter: staleAfter,
+		Tap:        tap,
+		OnMessage:  handle,
+		OnReconnect: func(err error) {
+			conn.reconnects.Add(1)
+			if errors.Is(err, wsclient.ErrStale) {
+				n := conn.stale.Add(1)
+				log.Printf("stale stream on %s (%d so far): %v; reconnecting", endpoint, n, err)
+			}
+		},
+	})
+	_ = client.Run(ctx)
+}
+
+// writer：只负责写盘 + 批量 flush
+func writerLoop(ctx context.Context, clk clock.Clock, segs *segments, rows <-chan *rowBatch, prog *app.Progress) uint64 {
+	ticker := clk.NewTicker(flushEveryDur)
+	defer ticker.Stop()
+
+	var n uint64
+	sinceFlush := 0
+
+	flush := func() {
+		if err := segs.flush(); err != nil {
+			log.Fatalf("%v", err)
+		}
+		sinceFlush = 0
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// drain? 这里不 drain，退出由 rowCh close + writerDone 控制
+			flush()
+			return n
+		case <-ticker.C():
+			if sinceFlush > 0 {
+				flush()
+			}
+		case batch, ok := <-rows:
+			if !ok {
+				flush()
+				return n
+			}
+			for _, row := range batch.rows {
+				if err := segs.write(batch, row); err != nil {
+					log.Fatalf("write row: %v", err)
+				}
+			}
+			if batch.flag != "" {
+				if err := segs.flag(batch.flag); err != nil {
+					log.Printf("selfcheck: %v", err)
+				}
+			}
+			k := len(batch.rows)
+			putBatch(batch)
+			prog.Rows.Add(uint64(k))
+
+			n += uint64(k)
+			sinceFlush += k
+			if sinceFlush >= flushEveryN {
+				flush()
+			}
+		}
+	}
+}
+
+func sidecarMetaPath(csvPath string) string {
+	dir := filepath.Dir(csvPath)
+	base := filepath.Base(csvPath)
+	ext := filepath.Ext(base)
+	name := strings.TrimSuffix(base, ext)
+	return filepath.Join(dir, name+".meta.json")
+}
+
+func writeMeta(path string, meta metaInfo) error {
+	b, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
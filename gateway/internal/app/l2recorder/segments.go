@@ -0,0 +1,202 @@
+package l2recorder
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/catalog"
+	"github.com/helix-lab/helix/gateway/pkg/clock"
+)
+
+// segments is the CSV output. With rotation on it rolls over to a new
+// segment file (foo.0000.csv, foo.0001.csv, ...) at every multiple of
+// rotate, always between messages, and keeps the run manifest
+// (foo.run.json) current; otherwise it is the one -out file. With bbo it
+// writes top-of-book rows instead of level deltas. Rows have the columns
+// meta.Columns names, in that order.
+type segments struct {
+	clock  clock.Clock
+	out    string
+	rotate time.Duration
+	bbo    bool
+	meta   metaInfo
+	run    *catalog.Manifest
+	cols   []column
+
+	n     int
+	path  string
+	f     *os.File
+	bw    *bufio.Writer
+	until time.Time
+	cur   catalog.Segment
+	// line is the serialization buffer rows are formatted into
+	line []byte
+}
+
+func newSegments(clk clock.Clock, out string, rotate time.Duration, bbo bool, meta metaInfo) (*segments, error) {
+	s := &segments{clock: clk, out: out, rotate: rotate, bbo: bbo, meta: meta}
+	if bbo {
+		s.meta.Format = "bbo"
+	}
+	for _, name := range meta.Columns {
+		s.cols = append(s.cols, columnNames[name])
+	}
+	if rotate > 0 {
+		s.run = &catalog.Manifest{Version: meta.Version, Kind: catalog.KindL2, Symbol: meta.Symbol,
+			Topic: meta.Topic, StartTime: meta.StartTime}
+		if bbo {
+			s.run.Kind = "bbo"
+		}
+	}
+	return s, s.open()
+}
+
+func (s *segments) open() error {
+	s.path = s.out
+	if s.run != nil {
+		s.path = catalog.SegmentPath(s.out, s.n)
+		s.until = s.clock.Now().Truncate(s.rotate).Add(s.rotate)
+	}
+	meta := s.meta
+	meta.OutputCSV, meta.OutputMeta = s.path, sidecarMetaPath(s.path)
+	if err := writeMeta(meta.OutputMeta, meta); err != nil {
+		return fmt.Errorf("write meta: %w", err)
+	}
+	f, err := os.Create(s.path)
+	if err != nil {
+		return fmt.Errorf("open output csv: %w", err)
+	}
+	s.f, s.bw = f, bufio.NewWriterSize(f, bufioSize)
+	s.cur = catalog.Segment{Path: filepath.Base(s.path)}
+	if _, err := s.bw.WriteString(strings.Join(s.meta.Columns, ",") + "\n"); err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+	return s.flush()
+}
+
+// write appends row of b, first rolling over when a new message starts
+// past the segment's end.
+func (s *segments) write(b *rowBatch, row csvRow) error {
+	if s.run != nil && s.cur.Rows > 0 && row.seq != s.cur.LastSeq && !s.clock.Now().Before(s.until) {
+		if err := s.finish(); err != nil {
+			return err
+		}
+		s.n++
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	if s.cur.Rows == 0 {
+		s.cur.StartMs, s.cur.FirstSeq, s.cur.FirstPrevSeq = row.tsMs, row.seq, row.prevSeq
+		s.cur.Snapshot = row.rowType == "snapshot"
+	}
+	s.cur.EndMs, s.cur.LastSeq = row.tsMs, row.seq
+	s.cur.Rows++
+
+	l := s.line[:0]
+	for i, c := range s.cols {
+		if i > 0 {
+			l = append(l, ',')
+		}
+		switch c {
+		case colTsMs:
+			l = strconv.AppendInt(l, row.tsMs, 10)
+		case colSeq:
+			l = strconv.AppendInt(l, row.seq, 10)
+		case colPrevSeq:
+			l = strconv.AppendInt(l, row.prevSeq, 10)
+		case colBookSide:
+			l = append(l, row.side...)
+		case colPrice:
+			l = appendField(l, b.field(row.price))
+		case colSize:
+			l = appendField(l, b.field(row.size))
+		case colBestBid:
+			l = strconv.AppendFloat(l, row.top.bestBid, 'f', -1, 64)
+		case colBestAsk:
+			l = strconv.AppendFloat(l, row.top.bestAsk, 'f', -1, 64)
+		case colBidSize:
+			l = strconv.AppendFloat(l, row.top.bidSz, 'f', -1, 64)
+		case colAskSize:
+			l = strconv.AppendFloat(l, row.top.askSz, 'f', -1, 64)
+		case colType:
+			l = append(l, row.rowType...)
+		case colRecvTsMs:
+			l = strconv.AppendInt(l, row.recvMs, 10)
+		case colSymbol:
+			l = appendField(l, []byte(s.meta.Symbol))
+		case colVenue:
+			if s.meta.Venue == "" {
+				l = append(l, defaultVenue...)
+			} else {
+				l = appendField(l, []byte(s.meta.Venue))
+			}
+		}
+	}
+	s.line = append(l, '\n')
+	_, err := s.bw.Write(s.line)
+	return err
+}
+
+// flag records a self-check divergence in the current file's sidecar and
+// every later one's.
+func (s *segments) flag(note string) error {
+	s.meta.SelfCheck = note
+	meta := s.meta
+	meta.OutputCSV, meta.OutputMeta = s.path, sidecarMetaPath(s.path)
+	if err := writeMeta(meta.OutputMeta, meta); err != nil {
+		return fmt.Errorf("flag meta: %w", err)
+	}
+	return nil
+}
+
+func (s *segments) flush() error {
+	if err := s.bw.Flush(); err != nil {
+		return fmt.Errorf("flush bufio: %w", err)
+	}
+	return nil
+}
+
+// finish closes the current file, seals its checksum (and extra files')
+// into its sidecar and records it in the run manifest.
+func (s *segments) finish(extra ...string) error {
+	if err := s.flush(); err != nil {
+		return err
+	}
+	if err := s.f.Sync(); err != nil {
+		return fmt.Errorf("sync csv: %w", err)
+	}
+	if err := s.f.Close(); err != nil {
+		return err
+	}
+	if err := catalog.Seal(s.path, extra...); err != nil {
+		return fmt.Errorf("seal meta: %w", err)
+	}
+	if s.run == nil {
+		return nil
+	}
+	ds, _, err := catalog.Inspect(s.path)
+	if err != nil {
+		return err
+	}
+	s.cur.Bytes, s.cur.Sha256 = ds.Bytes, ds.Sha256
+	s.run.Add(s.cur)
+	return catalog.WriteManifest(catalog.ManifestPath(s.out), s.run)
+}
+
+// close finishes the last file and marks the run complete.
+func (s *segments) close(extra ...string) error {
+	if err := s.finish(extra...); err != nil {
+		return err
+	}
+	if s.run == nil {
+		return nil
+	}
+	s.run.Complete = true
+	return catalog.WriteManifest(catalog.ManifestPath(s.out), s.run)
+}
@@ -0,0 +1,68 @@
+package l2recorder
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/helix-lab/helix/gateway/pkg/l2book"
+	"github.com/helix-lab/helix/gateway/pkg/ws/bybitjson"
+)
+
+// selfCheck rebuilds the primary stream's book from its levels as helix
+// bookcheck will read them back from the capture, and every N messages
+// compares it with the recorder's own, so a corrupt capture shows up
+// while it is recorded rather than days later.
+type selfCheck struct {
+	every int
+	// abort, if set, stops the recording at the first divergence.
+	abort func()
+	book  *l2book.Book
+	msgs  int
+	// failed is the first divergence; checking stops there.
+	failed string
+}
+
+func newSelfCheck(every int, abort func()) *selfCheck {
+	return &selfCheck{every: every, abort: abort, book: l2book.New()}
+}
+
+// message applies one message and, when it is due, compares the rebuilt
+// top with the recorder's.
+func (c *selfCheck) message(tsMs, seq, prev int64, snapshot bool, bids, asks []bybitjson.Level, top func() bboTop) error {
+	apply := func(levels []bybitjson.Level, side rune) error {
+		for _, l := range levels {
+			px, err := strconv.ParseFloat(string(l.Price), 64)
+			if err != nil || !(px > 0) {
+				continue
+			}
+			qty, err := strconv.ParseFloat(string(l.Size), 64)
+			if err != nil {
+				continue
+			}
+			d := l2book.Delta{Seq: seq, PrevSeq: prev, Snapshot: snapshot, TsMs: tsMs, Side: side, Price: px, Qty: qty}
+			if err := c.book.Update(d); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := apply(bids, 'b'); err != nil {
+		return err
+	}
+	if err := apply(asks, 'a'); err != nil {
+		return err
+	}
+	if err := c.book.Check(); err != nil {
+		return err
+	}
+	c.msgs++
+	if c.msgs%c.every != 0 || !c.book.Ready() {
+		return nil
+	}
+	b, t := c.book, top()
+	if b.BestBid != t.bestBid || b.BidSize != t.bidSz || b.BestAsk != t.bestAsk || b.AskSize != t.askSz {
+		return fmt.Errorf("rebuilt top %g x %g / %g x %g, recorder has %g x %g / %g x %g",
+			b.BestBid, b.BidSize, b.BestAsk, b.AskSize, t.bestBid, t.bidSz, t.bestAsk, t.askSz)
+	}
+	return nil
+}
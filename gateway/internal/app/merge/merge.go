@@ -0,0 +1,56 @@
+// Package merge implements "helix merge": combine overlapping L2 or trades
+// captures of one symbol, e.g. from redundant recorders, into one file.
+package merge
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/helix-lab/helix/gateway/internal/app"
+	"github.com/helix-lab/helix/gateway/pkg/capfile"
+)
+
+// Main runs "helix merge" with args (without the subcommand name).
+func Main(args []string) int {
+	fs := flag.NewFlagSet("merge", flag.ContinueOnError)
+	var common app.Common
+	common.Register(fs)
+	out := fs.String("out", "", "Merged capture to write (its .meta.json records the sources)")
+	if code := common.Parse(fs, args); code >= 0 {
+		return code
+	}
+	defer common.Close()
+	if *out == "" || fs.NArg() < 2 {
+		fmt.Fprintln(os.Stderr, "usage: helix merge -out merged.csv <capture.csv> <capture.csv>...")
+		return app.ExitUsage
+	}
+
+	t, st, err := capfile.Merge(fs.Args())
+	if err != nil {
+		log.Printf("merge: %v", err)
+		return app.ExitFailure
+	}
+	for _, s := range st.Sources {
+		latency := "unknown latency"
+		if s.LatencyMs >= 0 {
+			latency = fmt.Sprintf("median latency %.0fms", s.LatencyMs)
+		}
+		log.Printf("merge: %s: %d rows, %d kept, %d missing messages filled, %s", s.Path, s.Rows, s.Kept, s.Filled, latency)
+	}
+	if err := t.WriteFile(*out); err != nil {
+		log.Printf("merge: %v", err)
+		return app.ExitStartup
+	}
+	sources := make([]string, len(st.Sources))
+	for i, s := range st.Sources {
+		sources[i] = s.Path
+	}
+	if err := capfile.WriteMeta(sources[0], *out, map[string]any{"merged_from": sources}); err != nil {
+		log.Printf("merge: %v", err)
+		return app.ExitStartup
+	}
+	log.Printf("merge: wrote %s: %d %s rows, %d duplicates dropped, %d seq gaps left", *out, st.Rows, st.Kind, st.Duplicates, st.Gaps)
+	return app.ExitOK
+}
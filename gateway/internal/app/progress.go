@@ -0,0 +1,112 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Progress counts what a recorder has captured for its -progress lines:
+// one JSON object per line on stdout, so supervisors can follow capture
+// health without parsing the log.
+type Progress struct {
+	Recorder string
+	Topic    string
+	Out      string
+
+	Rows atomic.Uint64
+	Msgs atomic.Uint64
+	// LastTsMs is the exchange timestamp of the newest message; lag is
+	// measured against it.
+	LastTsMs atomic.Int64
+	// Reconnects reports the connection's reconnects; nil for none.
+	Reconnects func() uint64
+	// Stale reports how many of them stale-stream detection forced.
+	Stale func() uint64
+
+	start    time.Time
+	lastMsgs uint64
+	lastAt   time.Time
+}
+
+// ProgressEvent is one progress line.
+type ProgressEvent struct {
+	Event      string  `json:"event"`
+	Time       string  `json:"time"`
+	Recorder   string  `json:"recorder"`
+	Topic      string  `json:"topic,omitempty"`
+	Out        string  `json:"out,omitempty"`
+	Rows       uint64  `json:"rows"`
+	Msgs       uint64  `json:"msgs"`
+	MsgsPerSec float64 `json:"msgs_per_sec"`
+	Reconnects uint64  `json:"reconnects"`
+	Stale      uint64  `json:"stale"`
+	// LagMs is wall clock minus the newest message's exchange timestamp,
+	// -1 before the first message.
+	LagMs    int64   `json:"lag_ms"`
+	ElapsedS float64 `json:"elapsed_s"`
+}
+
+// progressMu keeps lines from concurrent streams whole and guards Event.
+var progressMu sync.Mutex
+
+// Event builds a line; msgs/sec covers the time since the previous one.
+// It is not safe for concurrent use; Emit serialises it.
+func (p *Progress) Event(event string, now time.Time) ProgressEvent {
+	if p.start.IsZero() {
+		p.start, p.lastAt = now, now
+	}
+	msgs := p.Msgs.Load()
+	ev := ProgressEvent{
+		Event:    event,
+		Time:     now.UTC().Format(time.RFC3339Nano),
+		Recorder: p.Recorder,
+		Topic:    p.Topic,
+		Out:      p.Out,
+		Rows:     p.Rows.Load(),
+		Msgs:     msgs,
+		LagMs:    -1,
+		ElapsedS: now.Sub(p.start).Seconds(),
+	}
+	if dt := now.Sub(p.lastAt).Seconds(); dt > 0 {
+		ev.MsgsPerSec = float64(msgs-p.lastMsgs) / dt
+	}
+	p.lastMsgs, p.lastAt = msgs, now
+	if p.Reconnects != nil {
+		ev.Reconnects = p.Reconnects()
+	}
+	if p.Stale != nil {
+		ev.Stale = p.Stale()
+	}
+	if ts := p.LastTsMs.Load(); ts > 0 {
+		ev.LagMs = now.UnixMilli() - ts
+	}
+	return ev
+}
+
+// Emit writes one line to w.
+func (p *Progress) Emit(w io.Writer, event string) {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	b, _ := json.Marshal(p.Event(event, time.Now()))
+	w.Write(append(b, '\n'))
+}
+
+// Run emits a "start" line, then a "progress" line every interval until
+// ctx ends. The recorder emits "done" itself once its output is final.
+func (p *Progress) Run(ctx context.Context, w io.Writer, every time.Duration) {
+	p.Emit(w, "start")
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			p.Emit(w, "progress")
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Package replay implements "helix replay": feed a captured frame log
+// (gateway -tap) or raw frame archive (-archive) back through the venue parsers and book manager.
+package replay
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/internal/app"
+	"github.com/helix-lab/helix/gateway/pkg/capture"
+	"github.com/helix-lab/helix/gateway/pkg/clock"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+	"github.com/helix-lab/helix/gateway/pkg/ws"
+)
+
+// Stats summarises one replay.
+type Stats struct {
+	Frames       int
+	Skipped      int
+	Depth        int
+	Liquidations int
+	Funding      int
+}
+
+// Run replays the frames in path. speed scales the recorded inter-frame
+// gaps (1 = as recorded); 0 replays as fast as possible. onDepth, if set,
+// sees every rebuilt top-of-book.
+func Run(ctx context.Context, path string, speed float64, onDepth func(transport.DepthUpdate)) (Stats, error) {
+	return RunClock(ctx, clock.Real, path, speed, onDepth)
+}
+
+// RunClock is Run paced by clk, so a virtual clock replays deterministically.
+func RunClock(ctx context.Context, clk clock.Clock, path string, speed float64, onDepth func(transport.DepthUpdate)) (Stats, error) {
+	var st Stats
+	depth := make(chan transport.DepthUpdate, 1024)
+	liq := make(chan transport.Liquidation, 1024)
+	funding := make(chan transport.FundingRate, 1024)
+	feeds := ws.Feeds{Depth: depth, Liquidations: liq, Funding: funding}
+	drain := func() {
+		for {
+			select {
+			case u := <-depth:
+				st.Depth++
+				if onDepth != nil {
+					onDepth(u)
+				}
+			case <-liq:
+				st.Liquidations++
+			case <-funding:
+				st.Funding++
+			default:
+				return
+			}
+		}
+	}
+	err := play(ctx, clk, path, speed, feeds, &st, drain)
+	return st, err
+}
+
+// play feeds the frames in path through the venue parsers into feeds,
+// calling after (if set) once per frame.
+func play(ctx context.Context, clk clock.Clock, path string, speed float64, feeds ws.Feeds, st *Stats, after func()) error {
+	streams := map[string]*ws.BybitStream{"BYBIT": ws.NewBybitStream("", nil, 1)}
+	var firstRecv int64
+	start := clk.Now()
+	return capture.ReadFrames(path, func(fr capture.Frame) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		st.Frames++
+		stream, ok := streams[fr.Source]
+		if !ok {
+			st.Skipped++
+			return nil
+		}
+		if speed > 0 {
+			if firstRecv == 0 {
+				firstRecv = fr.RecvNs
+			}
+			due := start.Add(time.Duration(float64(fr.RecvNs-firstRecv) / speed))
+			if wait := due.Sub(clk.Now()); wait > 0 {
+				select {
+				case <-clk.After(wait):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+		if !stream.HandleFrame(ctx, feeds, fr.RecvNs, fr.Payload()) {
+			st.Skipped++
+		}
+		if after != nil {
+			after()
+		}
+		return nil
+	})
+}
+
+// Connector replays a frame log as if it were a live venue, so the gateway
+// runs its normal pipeline on recorded data. Done is closed when the log
+// is exhausted.
+type Connector struct {
+	Path  string
+	Speed float64
+	// Clock paces the replay; nil is the wall clock.
+	Clock clock.Clock
+
+	done chan struct{}
+	st   Stats
+	err  error
+}
+
+func NewConnector(path string, speed float64) *Connector {
+	return &Connector{Path: path, Speed: speed, done: make(chan struct{})}
+}
+
+// Venue is the recorded source the connector rebuilds books for.
+func (c *Connector) Venue() string { return "BYBIT" }
+
+func (c *Connector) Run(ctx context.Context, out ws.Feeds) {
+	defer close(c.done)
+	c.err = play(ctx, clock.Or(c.Clock), c.Path, c.Speed, out, &c.st, nil)
+}
+
+func (c *Connector) Done() <-chan struct{} { return c.done }
+
+// Result is valid once Done is closed.
+func (c *Connector) Result() (Stats, error) {
+	<-c.done
+	return c.st, c.err
+}
+
+// Main runs "helix replay" with args (without the subcommand name).
+func Main(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	var common app.Common
+	common.Register(fs)
+	in := fs.String("in", "", "Frame log written by gateway -tap, or a raw frame -archive")
+	speed := fs.Float64("speed", 0, "Replay speed relative to the recording (0 = as fast as possible)")
+	publish := fs.Bool("publish", false, "Publish rebuilt depth on the configured transport endpoint")
+	if code := common.Parse(fs, args); code >= 0 {
+		return code
+	}
+	defer common.Close()
+	if *in == "" {
+		fmt.Fprintln(os.Stderr, "replay: -in is required")
+		return 2
+	}
+	cfg, err := common.Config()
+	if err != nil {
+		log.Printf("config: %v", err)
+		return 1
+	}
+	endpoint := "tcp://*:6001"
+	if cfg != nil {
+		endpoint = cfg.Gateway.PublishEndpoint
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	books := orderbook.NewManager()
+	var pub *transport.Publisher
+	if *publish {
+		pub = transport.NewPublisher(endpoint)
+	}
+	st, err := Run(ctx, *in, *speed, func(u transport.DepthUpdate) {
+		books.Apply(u)
+		if pub != nil {
+			pub.PublishDepth(u)
+		}
+	})
+	log.Printf("replayed %s: frames=%d skipped=%d depth=%d liquidations=%d funding=%d",
+		*in, st.Frames, st.Skipped, st.Depth, st.Liquidations, st.Funding)
+	for venue, lvl := range books.Snapshot() {
+		log.Printf("final %s bid=%.2f ask=%.2f", venue, lvl.BestBid, lvl.BestAsk)
+	}
+	if err != nil && ctx.Err() == nil {
+		log.Printf("replay: %v", err)
+		return 1
+	}
+	return 0
+}
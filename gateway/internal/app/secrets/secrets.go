@@ -0,0 +1,125 @@
+// Package secrets implements "helix secrets": create a master key, seal a
+// credentials file, and check that every configured reference resolves.
+package secrets
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/helix-lab/helix/gateway/internal/app"
+	"github.com/helix-lab/helix/gateway/pkg/secrets"
+)
+
+const usage = `usage: helix secrets <keygen|seal|check> [flags]
+
+  keygen   print a new master key for ` + secrets.MasterKeyEnv + `
+  seal     encrypt a JSON file of {"account": {"api_key", "api_secret"}}
+  check    resolve every credential reference in -config (printed redacted)
+`
+
+// Main runs "helix secrets" with args (without the subcommand name).
+func Main(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return app.ExitUsage
+	}
+	switch args[0] {
+	case "keygen":
+		key, err := secrets.NewKey()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return app.ExitFailure
+		}
+		fmt.Println(key)
+		return app.ExitOK
+	case "seal":
+		return seal(args[1:])
+	case "check":
+		return check(args[1:])
+	case "-h", "--help", "help":
+		fmt.Fprint(os.Stderr, usage)
+		return app.ExitOK
+	}
+	fmt.Fprint(os.Stderr, usage)
+	return app.ExitUsage
+}
+
+func seal(args []string) int {
+	fs := flag.NewFlagSet("secrets seal", flag.ContinueOnError)
+	in := fs.String("in", "", "Plain JSON credentials file (delete it after sealing)")
+	out := fs.String("out", "", "Sealed output file")
+	if err := fs.Parse(args); err != nil {
+		return app.ExitUsage
+	}
+	if *in == "" || *out == "" {
+		fmt.Fprintln(os.Stderr, "secrets seal: -in and -out are required")
+		return app.ExitUsage
+	}
+	key, err := secrets.MasterKey()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return app.ExitConfig
+	}
+	plain, err := os.ReadFile(*in)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return app.ExitFailure
+	}
+	sealed, err := secrets.Seal(plain, key)
+	if err == nil {
+		err = os.WriteFile(*out, sealed, 0o600)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return app.ExitFailure
+	}
+	return app.ExitOK
+}
+
+func check(args []string) int {
+	fs := flag.NewFlagSet("secrets check", flag.ContinueOnError)
+	var common app.Common
+	common.Register(fs)
+	if code := common.Parse(fs, args); code >= 0 {
+		return code
+	}
+	defer common.Close()
+	cfg, err := common.Config()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return app.ExitConfig
+	}
+	if cfg == nil {
+		fmt.Fprintln(os.Stderr, "secrets check: -config is required")
+		return app.ExitUsage
+	}
+	code := app.ExitOK
+	for _, v := range cfg.Gateway.Venues {
+		accounts := make([]string, 0, len(v.Accounts)+1)
+		if v.Credentials != "" {
+			accounts = append(accounts, "")
+		}
+		for name := range v.Accounts {
+			accounts = append(accounts, name)
+		}
+		sort.Strings(accounts)
+		for _, acct := range accounts {
+			ref, _ := v.CredentialRef(acct)
+			name := acct
+			if name == "" {
+				name = "default"
+			}
+			c, err := secrets.Resolve(context.Background(), ref, acct)
+			if err != nil {
+				fmt.Printf("%s/%s: %v\n", v.Name, name, err)
+				code = app.ExitConfig
+				continue
+			}
+			fmt.Printf("%s/%s: ok %s\n", v.Name, name, c)
+		}
+	}
+	return code
+}
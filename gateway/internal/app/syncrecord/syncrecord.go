@@ -0,0 +1,182 @@
+// Package syncrecord implements "helix record sync": record one symbol's
+// L2 book from several venues at once, starting and stopping every
+// recorder on the same wall clock, and write a combined manifest with each
+// venue's clock offset for cross-venue latency and lead-lag research.
+package syncrecord
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/internal/app"
+	"github.com/helix-lab/helix/gateway/internal/app/l2recorder"
+	"github.com/helix-lab/helix/gateway/pkg/catalog"
+	"github.com/helix-lab/helix/gateway/pkg/config"
+)
+
+const progVersion = "helix_sync/1.0"
+
+// recorders run one venue's capture by the venue's kind, with the l2
+// recorder's flags.
+var recorders = map[string]func(args []string) int{
+	config.KindBybit: l2recorder.Main,
+}
+
+// columns are what every venue records: the default header plus the
+// receive time the clock offsets are measured from, and the venue.
+const columns = "ts_ms,seq,prev_seq,book_side,price,size,type,recv_ts_ms,venue"
+
+// venue is one capture to run.
+type venue struct {
+	name, kind, endpoint string
+	out                  string
+}
+
+// Main runs "helix record sync" with args (without the subcommand name).
+func Main(args []string) int {
+	fs := flag.NewFlagSet("record sync", flag.ContinueOnError)
+	var common app.Common
+	common.Register(fs)
+	symbol := fs.String("symbol", "BTCUSDT", "Symbol to record on every venue")
+	venues := fs.String("venues", "", "Comma-separated venues: names of -config venues, or NAME=ENDPOINT for a Bybit-protocol endpoint")
+	depth := fs.Int("depth", 1, "Orderbook depth to subscribe on every venue")
+	outDir := fs.String("out_dir", "data/sync", "Directory for the venues' captures (<VENUE>.<SYMBOL>.csv) and the <SYMBOL>.sync.json manifest")
+	startAt := fs.String("start_at", "", "RFC3339 wall time every recorder starts at (empty = the next whole second)")
+	duration := fs.Duration("duration", time.Minute, "How long every recorder records from the start")
+	if code := common.Parse(fs, args); code >= 0 {
+		return code
+	}
+	defer common.Close()
+	if *venues == "" {
+		fmt.Fprintln(os.Stderr, "usage: helix record sync -venues BYBIT,BYBIT_SPOT [-config gateway.yaml] [-symbol BTCUSDT] [-start_at RFC3339] [-duration 1m]")
+		return app.ExitUsage
+	}
+	cfg, err := common.Config()
+	if err != nil {
+		log.Printf("config: %v", err)
+		return app.ExitConfig
+	}
+	list, err := resolve(strings.Split(*venues, ","), cfg, *outDir, *symbol)
+	if err != nil {
+		log.Printf("-venues: %v", err)
+		return app.ExitUsage
+	}
+	start := time.Now().Truncate(time.Second).Add(time.Second)
+	if *startAt != "" {
+		if start, err = time.Parse(time.RFC3339Nano, *startAt); err != nil {
+			log.Printf("-start_at: %v", err)
+			return app.ExitUsage
+		}
+	}
+	stop := start.Add(*duration)
+	if !stop.After(time.Now()) {
+		log.Printf("the window ends at %s, in the past", stop.Format(time.RFC3339))
+		return app.ExitUsage
+	}
+	if err := os.MkdirAll(*outDir, 0o755); err != nil {
+		log.Printf("mkdir: %v", err)
+		return app.ExitStartup
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	log.Printf("recording %s on %d venues from %s to %s", *symbol, len(list), start.Format(time.RFC3339Nano), stop.Format(time.RFC3339Nano))
+	select {
+	case <-time.After(time.Until(start)):
+	case <-ctx.Done():
+		return app.ExitFailure
+	}
+
+	codes := make([]int, len(list))
+	var wg sync.WaitGroup
+	for i, v := range list {
+		wg.Add(1)
+		go func(i int, v venue) {
+			defer wg.Done()
+			codes[i] = recorders[v.kind]([]string{"-endpoint", v.endpoint, "-symbol", *symbol, "-depth", strconv.Itoa(*depth),
+				"-venue", v.name, "-out", v.out, "-columns", columns, "-stop_at", stop.Format(time.RFC3339Nano)})
+		}(i, v)
+	}
+	wg.Wait()
+
+	m := &catalog.SyncManifest{Version: progVersion, Symbol: *symbol,
+		StartTime: start.Format(time.RFC3339Nano), StopTime: stop.Format(time.RFC3339Nano)}
+	code := app.ExitOK
+	for i, v := range list {
+		sv := catalog.SyncVenue{Venue: v.name, Endpoint: v.endpoint, Path: filepath.Base(v.out), Exit: codes[i]}
+		if codes[i] != app.ExitOK {
+			code = app.ExitFailure
+		}
+		if off, err := catalog.MeasureClockOffset(v.out); err != nil {
+			log.Printf("%s: clock offset: %v", v.name, err)
+		} else {
+			sv.Offset = &off
+			log.Printf("%s: %d messages, recv - exchange time min %gms, median %gms", v.name, off.Messages, off.MinMs, off.MedianMs)
+		}
+		if ds, ok, err := catalog.Inspect(v.out); err == nil && ok {
+			sv.Sha256 = ds.Sha256
+		}
+		m.Venues = append(m.Venues, sv)
+	}
+	path := catalog.SyncManifestPath(*outDir, *symbol)
+	if err := catalog.WriteSyncManifest(path, m); err != nil {
+		log.Printf("write manifest: %v", err)
+		return app.ExitFailure
+	}
+	log.Printf("sync manifest: %s", path)
+	return code
+}
+
+// resolve turns the -venues entries into captures under dir.
+func resolve(specs []string, cfg *config.File, dir, symbol string) ([]venue, error) {
+	var out []venue
+	seen := map[string]bool{}
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		name, endpoint, explicit := strings.Cut(spec, "=")
+		v := venue{name: strings.ToUpper(name), kind: config.KindBybit, endpoint: endpoint}
+		if !explicit {
+			if cfg == nil {
+				return nil, fmt.Errorf("venue %s: name one from -config or give NAME=ENDPOINT", name)
+			}
+			cv, ok := findVenue(cfg.Gateway.Venues, v.name)
+			if !ok {
+				return nil, fmt.Errorf("venue %s: not in the config", name)
+			}
+			v.kind, v.endpoint = cv.Kind, cv.WSPublic
+		}
+		switch {
+		case v.name == "" || v.endpoint == "":
+			return nil, fmt.Errorf("%q: want a venue name and endpoint", spec)
+		case seen[v.name]:
+			return nil, fmt.Errorf("venue %s given twice", v.name)
+		case recorders[v.kind] == nil:
+			return nil, fmt.Errorf("venue %s: no recorder for kind %q", v.name, v.kind)
+		}
+		seen[v.name] = true
+		v.out = filepath.Join(dir, v.name+"."+symbol+".csv")
+		out = append(out, v)
+	}
+	if len(out) < 2 {
+		return nil, fmt.Errorf("need at least two venues, got %d", len(out))
+	}
+	return out, nil
+}
+
+func findVenue(venues []config.Venue, name string) (config.Venue, bool) {
+	for _, v := range venues {
+		if strings.EqualFold(v.Name, name) {
+			return v, true
+		}
+	}
+	return config.Venue{}, false
+}
@@ -0,0 +1,200 @@
+package tradesrecorder
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"github.com/helix-lab/helix/gateway/internal/app"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/catalog"
+	"github.com/helix-lab/helix/gateway/pkg/clickhouse"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+	"github.com/helix-lab/helix/gateway/pkg/wsclient"
+)
+
+const (
+	pingInterval = 10 * time.Second
+	readTimeout  = 15 * time.Second
+)
+
+// Message is one publicTrade frame.
+type Message struct {
+	Topic string  `json:"topic"`
+	Type  string  `json:"type"`
+	Ts    int64   `json:"ts"`
+	Data  []Trade `json:"data"`
+}
+
+// Trade is one execution in a Message.
+type Trade struct {
+	Ts     int64  `json:"T"`
+	Symbol string `json:"s"`
+	Side   string `json:"S"`
+	Price  string `json:"p"`
+	Size   string `json:"v"`
+	ID     string `json:"i"`
+}
+
+// Decode parses frame into m, reusing the Data slice of the frame before.
+func (m *Message) Decode(frame []byte) error {
+	// json reuses the elements in place, keeping fields a trade omits
+	clear(m.Data[:cap(m.Data)])
+	*m = Message{Data: m.Data[:0]}
+	return json.Unmarshal(frame, m)
+}
+
+// Main runs "helix record trades" with args (without the subcommand name).
+func Main(args []string) int {
+	fs := flag.NewFlagSet("record trades", flag.ContinueOnError)
+	var common app.Common
+	common.Register(fs)
+	symbol := fs.String("symbol", "BTCUSDT", "Bybit symbol, e.g. BTCUSDT")
+	endpoint := fs.String("endpoint", "wss://stream.bybit.com/v5/public/linear", "Bybit public websocket endpoint")
+	out := fs.String("out", "data/replay/bybit_trades.csv", "CSV file to write trades (ts_ms,side,price,size,trade_id)")
+	duration := fs.Duration("duration", time.Minute, "How long to record before exiting")
+	clickhouseURL := fs.String("clickhouse_url", "", "Also write trades into ClickHouse at this HTTP URL (empty = off)")
+	clickhouseDB := fs.String("clickhouse_db", "helix", "ClickHouse database for -clickhouse_url")
+	progress := fs.Duration("progress", 0, "Print a JSON progress line (rows, msgs/sec, reconnects, lag) to stdout this often (0 = off)")
+	staleAfter := fs.Duration("stale_after", 5*time.Second, "Reconnect when no trades arrived for this long, even if the connection looks alive (0 = off, only the read timeout)")
+	if code := common.Parse(fs, args); code >= 0 {
+		return code
+	}
+	defer common.Close()
+	if err := common.BybitDefaults(fs, endpoint, symbol, nil); err != nil {
+		log.Printf("config: %v", err)
+		return 1
+	}
+
+	debug := os.Getenv("DEBUG_TRADE_RECORDER") != ""
+
+	rootCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	start := time.Now()
+	end := start.Add(*duration)
+
+	if err := os.MkdirAll(filepath.Dir(*out), 0o755); err != nil {
+		log.Fatalf("mkdir output: %v", err)
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatalf("open out: %v", err)
+	}
+	defer f.Close()
+
+	// sidecar so the catalog knows the symbol and source
+	meta, _ := json.MarshalIndent(map[string]string{
+		"version":    "bybit_trades_recorder/1.0",
+		"symbol":     *symbol,
+		"endpoint":   *endpoint,
+		"topic":      "publicTrade." + *symbol,
+		"start_time": start.Format(time.RFC3339Nano),
+		"output_csv": *out,
+	}, "", "  ")
+	if err := os.WriteFile(catalog.MetaPath(*out), meta, 0o644); err != nil {
+		log.Fatalf("write meta: %v", err)
+	}
+
+	bw := bufio.NewWriterSize(f, 1<<20)
+	w := csv.NewWriter(bw)
+	if err := w.Write([]string{"ts_ms", "side", "price", "size", "trade_id"}); err != nil {
+		log.Fatalf("csv header: %v", err)
+	}
+
+	var sink *clickhouse.Sink
+	sinkDone := make(chan struct{})
+	if *clickhouseURL != "" {
+		sink = clickhouse.New(clickhouse.Config{URL: *clickhouseURL, Database: *clickhouseDB, Logf: log.Printf})
+		if err := sink.Migrate(rootCtx); err != nil {
+			log.Printf("clickhouse: %v", err)
+			return app.ExitStartup
+		}
+		go func() {
+			defer close(sinkDone)
+			sink.Run()
+		}()
+	}
+
+	ctx, cancel := context.WithDeadline(rootCtx, end)
+	defer cancel()
+
+	total := 0
+	msgs := 0
+	prog := &app.Progress{Recorder: "trades", Topic: "publicTrade." + *symbol, Out: *out}
+	// one message at a time: msg's slice and rec are reused across frames
+	var msg Message
+	rec := make([]string, 5)
+	handle := func(data []byte) bool {
+		if err := msg.Decode(data); err != nil {
+			return false
+		}
+		if len(msg.Data) == 0 {
+			return false
+		}
+		msgs++
+		prog.Msgs.Add(1)
+		prog.LastTsMs.Store(msg.Ts)
+		for _, t := range msg.Data {
+			rec[0], rec[1], rec[2], rec[3], rec[4] = strconv.FormatInt(t.Ts, 10), t.Side, t.Price, t.Size, t.ID
+			if err := w.Write(rec); err != nil {
+				log.Printf("write err: %v", err)
+			} else {
+				total++
+				prog.Rows.Add(1)
+			}
+			if sink != nil {
+				price, _ := strconv.ParseFloat(t.Price, 64)
+				qty, _ := strconv.ParseFloat(t.Size, 64)
+				sink.Trade(transport.Trade{Venue: "BYBIT", Symbol: t.Symbol, Side: strings.ToUpper(t.Side), Price: price, Qty: qty, TsMs: t.Ts})
+			}
+		}
+		w.Flush()
+		if debug {
+			log.Printf("debug: msg=%d trades_total=%d msg_trades=%d last_ts=%d type=%s", msgs, total, len(msg.Data), msg.Ts, msg.Type)
+		}
+		return true
+	}
+
+	client := wsclient.New(wsclient.Config{
+		Endpoint:     *endpoint,
+		Topics:       []string{"publicTrade." + *symbol},
+		ReadTimeout:  readTimeout,
+		PingInterval: pingInterval,
+		StaleAfter:   *staleAfter,
+		OnMessage:    handle,
+		OnConnect: func(int) {
+			log.Printf("recording trades for %s (%s) until %s", *symbol, *endpoint, end.Format(time.RFC3339))
+		},
+		Logf: log.Printf,
+	})
+	if *progress > 0 {
+		prog.Reconnects = client.Reconnects
+		prog.Stale = client.Stale
+		go prog.Run(ctx, os.Stdout, *progress)
+	}
+	_ = client.Run(ctx)
+
+	w.Flush()
+	bw.Flush()
+	if err := catalog.Seal(*out); err != nil {
+		log.Printf("seal meta: %v", err)
+	}
+	if sink != nil {
+		sink.Close()
+		<-sinkDone
+	}
+	log.Printf("recorded trades=%d, out=%s", total, *out)
+	if *progress > 0 {
+		prog.Emit(os.Stdout, "done")
+	}
+	return 0
+}
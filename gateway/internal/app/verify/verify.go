@@ -0,0 +1,82 @@
+// Package verify implements "helix verify": recompute the SHA-256 of
+// captures and compare it with the checksums sealed into their sidecars,
+// e.g. after copying data between machines or object stores.
+package verify
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/helix-lab/helix/gateway/internal/app"
+	"github.com/helix-lab/helix/gateway/pkg/catalog"
+)
+
+// Main runs "helix verify" with args (without the subcommand name).
+func Main(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	var common app.Common
+	common.Register(fs)
+	dir := fs.String("data", "", "Verify every capture the catalog finds under this directory")
+	seal := fs.Bool("seal", false, "Record checksums for captures that have none instead of reporting them")
+	strict := fs.Bool("strict", false, "Fail captures that have no checksum")
+	if code := common.Parse(fs, args); code >= 0 {
+		return code
+	}
+	defer common.Close()
+	paths := fs.Args()
+	if *dir != "" {
+		cat, err := catalog.Scan(*dir)
+		if err != nil {
+			log.Printf("verify: %v", err)
+			return app.ExitFailure
+		}
+		for _, ds := range cat.Datasets {
+			paths = append(paths, ds.Path)
+		}
+	}
+	if len(paths) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: helix verify [-seal] [-strict] [-data dir] <capture>...")
+		return app.ExitUsage
+	}
+
+	code := app.ExitOK
+	for _, p := range paths {
+		checks, err := catalog.Verify(p)
+		switch {
+		case errors.Is(err, catalog.ErrNoChecksum) && *seal:
+			if err := catalog.Seal(p); err != nil {
+				fmt.Printf("%s: seal failed: %v\n", p, err)
+				code = app.ExitFailure
+				continue
+			}
+			fmt.Printf("%s: sealed\n", p)
+			continue
+		case errors.Is(err, catalog.ErrNoChecksum):
+			fmt.Printf("%s: no checksum\n", p)
+			if *strict {
+				code = app.ExitFailure
+			}
+			continue
+		case err != nil:
+			fmt.Printf("%s: %v\n", p, err)
+			code = app.ExitFailure
+			continue
+		}
+		for _, c := range checks {
+			switch {
+			case c.Err != "":
+				fmt.Printf("%s: %s\n", c.Path, c.Err)
+				code = app.ExitFailure
+			case !c.OK():
+				fmt.Printf("%s: MISMATCH sha256 %s, sidecar says %s\n", c.Path, c.Got, c.Want)
+				code = app.ExitFailure
+			default:
+				fmt.Printf("%s: ok\n", c.Path)
+			}
+		}
+	}
+	return code
+}
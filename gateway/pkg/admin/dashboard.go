@@ -0,0 +1,27 @@
+package admin
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+//go:embed dashboard.html
+var dashboardPage []byte
+
+// Dashboard serves the operator's web page: consolidated books, venue
+// health, recent routes, open orders, positions and latency, polled from
+// the /v1/ API. The page itself holds no data and needs no token; it asks
+// for one and sends it with every API call.
+func (s *Server) Dashboard() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "use GET", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'")
+		_, _ = w.Write(dashboardPage)
+	})
+}
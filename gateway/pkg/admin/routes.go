@@ -0,0 +1,95 @@
+package admin
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// Route is one routing decision as /v1/routes reports it.
+type Route struct {
+	Time    time.Time          `json:"time"`
+	Symbol  string             `json:"symbol"`
+	Side    string             `json:"side"`
+	Size    float64            `json:"size"`
+	Venue   string             `json:"venue"`
+	Price   float64            `json:"price"`
+	Prices  map[string]float64 `json:"prices,omitempty"`
+	DryRun  bool               `json:"dry_run,omitempty"`
+	OrderID string             `json:"order_id,omitempty"`
+	Account string             `json:"account,omitempty"`
+	Error   string             `json:"error,omitempty"`
+}
+
+// RouteLog is an executor.Journal that keeps the last routing decisions,
+// sent or refused, for /v1/routes and the dashboard.
+type RouteLog struct {
+	mu     sync.Mutex
+	routes []Route
+	next   int
+	full   bool
+}
+
+var _ executor.Journal = (*RouteLog)(nil)
+
+// NewRouteLog keeps the last n decisions.
+func NewRouteLog(n int) *RouteLog {
+	return &RouteLog{routes: make([]Route, n)}
+}
+
+func (l *RouteLog) Route(d transport.RouteDecision, err error) {
+	r := Route{Time: time.Unix(0, d.TsNs), Symbol: d.Symbol, Side: d.Side, Size: d.Size, Venue: d.Venue,
+		Price: d.Price, Prices: d.Prices, DryRun: d.DryRun, OrderID: d.OrderID, Account: d.Account}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.routes) == 0 {
+		return
+	}
+	l.routes[l.next] = r
+	l.next = (l.next + 1) % len(l.routes)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+func (l *RouteLog) Order(executor.Order) {}
+
+func (l *RouteLog) Fill(transport.Fill, executor.Position, time.Time) {}
+
+// Recent lists the kept decisions, newest first.
+func (l *RouteLog) Recent() []Route {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := l.next
+	if l.full {
+		n = len(l.routes)
+	}
+	out := make([]Route, 0, n)
+	for i := 1; i <= n; i++ {
+		out = append(out, l.routes[(l.next-i+len(l.routes))%len(l.routes)])
+	}
+	return out
+}
+
+// ForOrder is the kept decision that became the order id.
+func (l *RouteLog) ForOrder(id string) (Route, bool) {
+	for _, r := range l.Recent() {
+		if r.OrderID == id {
+			return r, true
+		}
+	}
+	return Route{}, false
+}
+
+func (s *Server) routes(*http.Request) (any, error) {
+	if s.Routes == nil {
+		return nil, errNotAvailable
+	}
+	return s.Routes.Recent(), nil
+}
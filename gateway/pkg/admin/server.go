@@ -0,0 +1,649 @@
+// Package admin serves the operational HTTP API of a running gateway:
+// books, venue rankings, feed health, orders, positions, risk, the portfolio, recent
+// routing decisions, latency, the kill switch, hosted strategies with
+// their own kill switches, symbol subscriptions and replay controls. Every request needs the bearer token. Dashboard serves
+// a web page over the same API.
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/instruments"
+	"github.com/helix-lab/helix/gateway/pkg/latency"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/portfolio"
+	"github.com/helix-lab/helix/gateway/pkg/risk"
+	"github.com/helix-lab/helix/gateway/pkg/strategy"
+	"github.com/helix-lab/helix/gateway/pkg/ws"
+)
+
+// ReplayController starts and stops a frame-log replay into the gateway.
+type ReplayController interface {
+	StartReplay(path string, speed float64) error
+	StopReplay() error
+	ReplayStatus() ReplayStatus
+}
+
+type ReplayStatus struct {
+	Running   bool      `json:"running"`
+	Path      string    `json:"path,omitempty"`
+	Speed     float64   `json:"speed"`
+	Frames    int       `json:"frames"`
+	Depth     int       `json:"depth"`
+	StartedAt time.Time `json:"started_at,omitempty"`
+	Err       string    `json:"error,omitempty"`
+}
+
+// Server wires the API to the gateway's components; nil components answer
+// 404 on their endpoints.
+type Server struct {
+	Token  string
+	Books  *orderbook.Manager
+	Router *ws.Router
+	Orders *executor.Tracker
+	Sender *executor.OrderSender
+	Risk   *risk.Engine
+	// Portfolio is the cross-venue holdings view.
+	Portfolio *portfolio.Portfolio
+	// Instruments is the venues' instrument specs.
+	Instruments *instruments.Cache
+	Replay      ReplayController
+	// Routes keeps the recent routing decisions; add it to the executor's
+	// journals.
+	Routes *RouteLog
+	// Latency is the registry the gateway's stages record into.
+	Latency *latency.Registry
+	// Strategies is the host the gateway's strategies and clients run in.
+	Strategies *strategy.Host
+}
+
+// Handler serves the API under /v1/.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/books", s.get(s.books))
+	mux.HandleFunc("/v1/books/", s.get(s.books))
+	mux.HandleFunc("/v1/venues/", s.get(s.venues))
+	mux.HandleFunc("/v1/health", s.get(s.health))
+	mux.HandleFunc("/v1/orders", s.get(s.orders))
+	mux.HandleFunc("/v1/orders/", s.get(s.order))
+	mux.HandleFunc("/v1/positions", s.get(s.positions))
+	mux.HandleFunc("/v1/risk", s.get(s.risk))
+	mux.HandleFunc("/v1/portfolio", s.get(s.portfolio))
+	mux.HandleFunc("/v1/reconcile", s.get(s.reconcile))
+	mux.HandleFunc("/v1/instruments", s.get(s.instruments))
+	mux.HandleFunc("/v1/routes", s.get(s.routes))
+	mux.HandleFunc("/v1/latency", s.get(s.latency))
+	mux.HandleFunc("/v1/killswitch", s.get(s.killSwitch))
+	mux.HandleFunc("/v1/killswitch/arm", s.post(s.arm))
+	mux.HandleFunc("/v1/killswitch/disarm", s.post(s.disarm))
+	mux.HandleFunc("/v1/strategies", s.get(s.strategies))
+	mux.HandleFunc("/v1/strategies/", s.post(s.strategyHalt))
+	mux.HandleFunc("/v1/subscriptions", s.get(s.subscriptions))
+	mux.HandleFunc("/v1/subscriptions/", s.post(s.subscribe))
+	mux.HandleFunc("/v1/replay", s.get(s.replayStatus))
+	mux.HandleFunc("/v1/replay/start", s.post(s.replayStart))
+	mux.HandleFunc("/v1/replay/stop", s.post(s.replayStop))
+	return s.auth(mux)
+}
+
+var errNotAvailable = errors.New("not available in this gateway")
+
+type httpError struct {
+	code int
+	err  error
+}
+
+func (e httpError) Error() string { return e.err.Error() }
+
+func badRequest(err error) error { return httpError{code: http.StatusBadRequest, err: err} }
+
+func (s *Server) auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if s.Token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(s.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="helix-admin"`)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) get(fn func(r *http.Request) (any, error)) http.HandlerFunc {
+	return s.method(http.MethodGet, fn)
+}
+
+func (s *Server) post(fn func(r *http.Request) (any, error)) http.HandlerFunc {
+	return s.method(http.MethodPost, fn)
+}
+
+func (s *Server) method(method string, fn func(r *http.Request) (any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "use " + method})
+			return
+		}
+		v, err := fn(r)
+		if err != nil {
+			code := http.StatusInternalServerError
+			var he httpError
+			switch {
+			case errors.As(err, &he):
+				code = he.code
+			case errors.Is(err, errNotAvailable):
+				code = http.StatusNotFound
+			}
+			writeJSON(w, code, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, v)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func decode(r *http.Request, v any) error {
+	if r.ContentLength == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return badRequest(err)
+	}
+	return nil
+}
+
+type Level struct {
+	BestBid float64 `json:"best_bid"`
+	BestAsk float64 `json:"best_ask"`
+	BidSize float64 `json:"bid_size"`
+	AskSize float64 `json:"ask_size"`
+}
+
+// SymbolBooks is one market of a symbol; a symbol traded as spot and as a
+// perpetual has one each.
+type SymbolBooks struct {
+	Symbol   string           `json:"symbol"`
+	Category string           `json:"category"`
+	NBBO     Level            `json:"nbbo"`
+	Venues   map[string]Level `json:"venues"`
+}
+
+func level(l orderbook.Level) Level {
+	return Level{BestBid: l.BestBid, BestAsk: l.BestAsk, BidSize: l.BidSize, AskSize: l.AskSize}
+}
+
+func (s *Server) books(r *http.Request) (any, error) {
+	if s.Books == nil {
+		return nil, errNotAvailable
+	}
+	symbols := s.Books.Symbols()
+	if sym := strings.TrimPrefix(r.URL.Path, "/v1/books/"); sym != r.URL.Path && sym != "" {
+		symbols = []string{strings.ToUpper(sym)}
+	}
+	out := make([]SymbolBooks, 0, len(symbols))
+	for _, sym := range symbols {
+		cats := s.Books.Categories(sym)
+		if len(cats) == 0 {
+			return nil, httpError{code: http.StatusNotFound, err: errors.New("no book for " + sym)}
+		}
+		for _, cat := range cats {
+			venues := s.Books.MarketSnapshot(sym, cat)
+			sb := SymbolBooks{Symbol: sym, Category: cat, NBBO: level(orderbook.MergeBest(venues)), Venues: map[string]Level{}}
+			for v, l := range venues {
+				sb.Venues[v] = level(l)
+			}
+			out = append(out, sb)
+		}
+	}
+	return out, nil
+}
+
+// VenueRank is one venue in /v1/venues/{symbol}, best first.
+type VenueRank struct {
+	Venue      string  `json:"venue"`
+	Category   string  `json:"category,omitempty"`
+	Price      float64 `json:"price"`
+	Effective  float64 `json:"effective_price"`
+	Size       float64 `json:"size"`
+	Sufficient bool    `json:"sufficient"`
+}
+
+// venues ranks the venues for taking ?size (default 0) on ?side (default
+// buy) of the symbol.
+func (s *Server) venues(r *http.Request) (any, error) {
+	if s.Books == nil {
+		return nil, errNotAvailable
+	}
+	sym := strings.ToUpper(strings.TrimPrefix(r.URL.Path, "/v1/venues/"))
+	if sym == "" {
+		return nil, badRequest(errors.New("want /v1/venues/{symbol}"))
+	}
+	side := strings.ToUpper(r.URL.Query().Get("side"))
+	switch side {
+	case "":
+		side = "BUY"
+	case "BUY", "SELL":
+	default:
+		return nil, badRequest(errors.New("side must be buy or sell"))
+	}
+	size := 0.0
+	if v := r.URL.Query().Get("size"); v != "" {
+		var err error
+		if size, err = strconv.ParseFloat(v, 64); err != nil || size < 0 {
+			return nil, badRequest(errors.New("size must be a non-negative number"))
+		}
+	}
+	ranks := s.Books.RankVenues(sym, side, size)
+	if len(ranks) == 0 {
+		return nil, httpError{code: http.StatusNotFound, err: errors.New("no " + strings.ToLower(side) + " side for " + sym)}
+	}
+	out := make([]VenueRank, len(ranks))
+	for i, r := range ranks {
+		out[i] = VenueRank{Venue: r.Venue, Category: r.Category, Price: r.Price, Effective: r.Effective, Size: r.Size, Sufficient: r.Sufficient}
+	}
+	return out, nil
+}
+
+type Health struct {
+	Venue       string    `json:"venue"`
+	Status      string    `json:"status"`
+	LastUpdate  time.Time `json:"last_update"`
+	Reconnects  uint64    `json:"reconnects"`
+	Gaps        uint64    `json:"gaps"`
+	Recovered   uint64    `json:"gaps_recovered"`
+	Conns       int       `json:"conns"`
+	Maintenance string    `json:"maintenance,omitempty"`
+}
+
+func (s *Server) health(*http.Request) (any, error) {
+	if s.Router == nil {
+		return nil, errNotAvailable
+	}
+	var out []Health
+	for _, h := range s.Router.Health() {
+		v := Health{Venue: h.Venue, Status: h.Status, LastUpdate: h.LastUpdate, Reconnects: h.Reconnects,
+			Gaps: h.Gaps.Gaps, Recovered: h.Gaps.Recovered, Conns: len(h.Conns)}
+		if h.Maintenance != nil {
+			v.Maintenance = h.Maintenance.Reason
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// Latency is one latency series, in microseconds.
+type Latency struct {
+	Label  string  `json:"label"`
+	Labels string  `json:"labels,omitempty"`
+	Count  uint64  `json:"count"`
+	P50    float64 `json:"p50_us"`
+	P95    float64 `json:"p95_us"`
+	P99    float64 `json:"p99_us"`
+	Max    float64 `json:"max_us"`
+}
+
+func (s *Server) latency(*http.Request) (any, error) {
+	if s.Latency == nil {
+		return nil, errNotAvailable
+	}
+	us := func(d time.Duration) float64 { return float64(d) / float64(time.Microsecond) }
+	out := []Latency{}
+	for _, sum := range s.Latency.Report() {
+		out = append(out, Latency{Label: sum.Label, Labels: sum.Labels.String(), Count: sum.Count,
+			P50: us(sum.P50), P95: us(sum.P95), P99: us(sum.P99), Max: us(sum.Max)})
+	}
+	return out, nil
+}
+
+type Order struct {
+	ID        string    `json:"id"`
+	Venue     string    `json:"venue"`
+	Symbol    string    `json:"symbol"`
+	Side      string    `json:"side"`
+	Size      float64   `json:"size"`
+	Filled    float64   `json:"filled"`
+	Remaining float64   `json:"remaining"`
+	AvgPrice  float64   `json:"avg_price"`
+	Fills     int       `json:"fills"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func orderView(o executor.Order) Order {
+	return Order{ID: o.ID, Venue: o.Venue, Symbol: o.Symbol, Side: o.Side, Size: o.Size, Filled: o.Filled,
+		Remaining: o.Remaining(), AvgPrice: o.AvgPrice, Fills: o.Fills, Status: o.Status, CreatedAt: o.CreatedAt}
+}
+
+func (s *Server) orders(*http.Request) (any, error) {
+	if s.Orders == nil {
+		return nil, errNotAvailable
+	}
+	out := []Order{}
+	for _, o := range s.Orders.OpenOrders() {
+		out = append(out, orderView(o))
+	}
+	return out, nil
+}
+
+// Execution is /v1/orders/{id}: how one order, open or done, has executed.
+type Execution struct {
+	Order
+	UpdatedAt time.Time `json:"updated_at"`
+	// DurationMs is from creation to the last fill or the cancel.
+	DurationMs int64 `json:"duration_ms"`
+	// RoutePrice is the touch the router sent the order at, and Slippage
+	// how much worse per unit AvgPrice came out; both are left out once
+	// the route log no longer holds the decision.
+	RoutePrice float64 `json:"route_price,omitempty"`
+	Slippage   float64 `json:"slippage,omitempty"`
+}
+
+func (s *Server) order(r *http.Request) (any, error) {
+	if s.Orders == nil {
+		return nil, errNotAvailable
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/v1/orders/")
+	o, ok := s.Orders.Order(id)
+	if !ok {
+		return nil, httpError{code: http.StatusNotFound, err: errors.New("no order " + id)}
+	}
+	ex := Execution{Order: orderView(o), UpdatedAt: o.UpdatedAt, DurationMs: o.UpdatedAt.Sub(o.CreatedAt).Milliseconds()}
+	if s.Routes != nil {
+		if rt, ok := s.Routes.ForOrder(id); ok && rt.Price > 0 {
+			ex.RoutePrice = rt.Price
+			if o.Filled > 0 {
+				ex.Slippage = o.AvgPrice - rt.Price
+				if o.Side == "SELL" {
+					ex.Slippage = -ex.Slippage
+				}
+			}
+		}
+	}
+	return ex, nil
+}
+
+type Position struct {
+	Venue    string  `json:"venue"`
+	Symbol   string  `json:"symbol"`
+	Qty      float64 `json:"qty"`
+	AvgPrice float64 `json:"avg_price"`
+	Realized float64 `json:"realized_pnl"`
+}
+
+func (s *Server) positions(*http.Request) (any, error) {
+	if s.Orders == nil {
+		return nil, errNotAvailable
+	}
+	out := []Position{}
+	for _, p := range s.Orders.Positions() {
+		out = append(out, Position{Venue: p.Venue, Symbol: p.Symbol, Qty: p.Qty, AvgPrice: p.AvgPrice, Realized: p.Realized})
+	}
+	return out, nil
+}
+
+type Risk struct {
+	Base          string  `json:"base,omitempty"`
+	DailyPnL      float64 `json:"daily_pnl"`
+	Realized      float64 `json:"realized_pnl"`
+	Unrealized    float64 `json:"unrealized_pnl"`
+	GrossNotional float64 `json:"gross_notional"`
+	Breach        string  `json:"breach,omitempty"`
+}
+
+func (s *Server) risk(*http.Request) (any, error) {
+	if s.Risk == nil {
+		return nil, errNotAvailable
+	}
+	st := s.Risk.State()
+	return Risk{Base: st.Base, DailyPnL: st.DailyPnL, Realized: st.Realized, Unrealized: st.Unrealized, GrossNotional: st.GrossNotional, Breach: st.Breach}, nil
+}
+
+type Portfolio struct {
+	TsMs      int64               `json:"ts_ms"`
+	Base      string              `json:"base"`
+	Equity    float64             `json:"equity"`
+	Assets    []PortfolioAsset    `json:"assets"`
+	Positions []PortfolioPosition `json:"positions"`
+	Unpriced  []string            `json:"unpriced,omitempty"`
+}
+
+type PortfolioAsset struct {
+	Asset  string             `json:"asset"`
+	Total  float64            `json:"total"`
+	Value  float64            `json:"value"`
+	Venues map[string]float64 `json:"venues"`
+}
+
+type PortfolioPosition struct {
+	Venue      string  `json:"venue"`
+	Symbol     string  `json:"symbol"`
+	Qty        float64 `json:"qty"`
+	AvgPrice   float64 `json:"avg_price"`
+	Mark       float64 `json:"mark"`
+	Unrealized float64 `json:"unrealized_pnl"`
+}
+
+// instruments lists the cached specs, optionally for one ?venue= and
+// ?symbol=.
+func (s *Server) instruments(r *http.Request) (any, error) {
+	if s.Instruments == nil {
+		return nil, errNotAvailable
+	}
+	venue, symbol := r.URL.Query().Get("venue"), r.URL.Query().Get("symbol")
+	out := []instruments.Spec{}
+	for _, sp := range s.Instruments.Specs() {
+		if (venue == "" || sp.Venue == venue) && (symbol == "" || sp.Symbol == symbol) {
+			out = append(out, sp)
+		}
+	}
+	return out, nil
+}
+
+// portfolio revalues holdings from the last poll at current prices.
+func (s *Server) portfolio(*http.Request) (any, error) {
+	if s.Portfolio == nil {
+		return nil, errNotAvailable
+	}
+	v := s.Portfolio.View(time.Now())
+	out := Portfolio{TsMs: v.TsMs, Base: v.Base, Equity: v.Equity, Assets: []PortfolioAsset{}, Positions: []PortfolioPosition{}, Unpriced: v.Unpriced}
+	for _, a := range v.Assets {
+		out.Assets = append(out.Assets, PortfolioAsset{Asset: a.Asset, Total: a.Total, Value: a.Value, Venues: a.Venues})
+	}
+	for _, p := range v.Positions {
+		out.Positions = append(out.Positions, PortfolioPosition{Venue: p.Venue, Symbol: p.Symbol, Qty: p.Qty,
+			AvgPrice: p.AvgPrice, Mark: p.Mark, Unrealized: p.Unrealized})
+	}
+	return out, nil
+}
+
+type Reconciliation struct {
+	Venues []ReconcileVenue `json:"venues"`
+	// Positions line up each venue-reported position with the tracked one.
+	Positions []PositionDrift `json:"positions"`
+	Drifting  int             `json:"drifting"`
+}
+
+type ReconcileVenue struct {
+	Venue     string         `json:"venue"`
+	CheckedAt time.Time      `json:"checked_at"`
+	Spot      bool           `json:"spot,omitempty"`
+	Balances  []VenueBalance `json:"balances"`
+}
+
+type VenueBalance struct {
+	Asset  string  `json:"asset"`
+	Free   float64 `json:"free"`
+	Locked float64 `json:"locked"`
+}
+
+type PositionDrift struct {
+	Venue    string  `json:"venue"`
+	Symbol   string  `json:"symbol"`
+	Reported float64 `json:"reported"`
+	Tracked  float64 `json:"tracked"`
+	Delta    float64 `json:"delta"`
+}
+
+// reconcile shows what each polled venue reported next to the tracker's
+// positions, and the differences.
+func (s *Server) reconcile(*http.Request) (any, error) {
+	if s.Portfolio == nil {
+		return nil, errNotAvailable
+	}
+	out := Reconciliation{Venues: []ReconcileVenue{}, Positions: []PositionDrift{}}
+	for _, h := range s.Portfolio.Holdings() {
+		v := ReconcileVenue{Venue: h.Venue, CheckedAt: h.CheckedAt, Spot: h.Spot, Balances: []VenueBalance{}}
+		for _, b := range h.Balances {
+			v.Balances = append(v.Balances, VenueBalance{Asset: b.Asset, Free: b.Free, Locked: b.Locked})
+		}
+		out.Venues = append(out.Venues, v)
+	}
+	for _, d := range s.Portfolio.Reconcile() {
+		out.Positions = append(out.Positions, PositionDrift{Venue: d.Venue, Symbol: d.Symbol,
+			Reported: d.Reported, Tracked: d.Tracked, Delta: d.Delta})
+		if d.Delta != 0 {
+			out.Drifting++
+		}
+	}
+	return out, nil
+}
+
+type KillSwitch struct {
+	Armed  bool      `json:"armed"`
+	Reason string    `json:"reason,omitempty"`
+	Since  time.Time `json:"since,omitempty"`
+}
+
+func (s *Server) killSwitch(*http.Request) (any, error) {
+	if s.Sender == nil {
+		return nil, errNotAvailable
+	}
+	k := s.Sender.KillSwitch()
+	return KillSwitch{Armed: k.Armed, Reason: k.Reason, Since: k.Since}, nil
+}
+
+func (s *Server) arm(r *http.Request) (any, error) {
+	if s.Sender == nil {
+		return nil, errNotAvailable
+	}
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := decode(r, &req); err != nil {
+		return nil, err
+	}
+	if req.Reason == "" {
+		req.Reason = "admin"
+	}
+	s.Sender.ArmKillSwitch(req.Reason)
+	return s.killSwitch(r)
+}
+
+func (s *Server) disarm(r *http.Request) (any, error) {
+	if s.Sender == nil {
+		return nil, errNotAvailable
+	}
+	s.Sender.DisarmKillSwitch()
+	return s.killSwitch(r)
+}
+
+func (s *Server) subscriptions(*http.Request) (any, error) {
+	if s.Router == nil {
+		return nil, errNotAvailable
+	}
+	subs := s.Router.Subscriptions()
+	for _, syms := range subs {
+		sort.Strings(syms)
+	}
+	return subs, nil
+}
+
+// subscribe handles POST /v1/subscriptions/{venue} with
+// {"subscribe":[...], "unsubscribe":[...]}.
+func (s *Server) subscribe(r *http.Request) (any, error) {
+	if s.Router == nil {
+		return nil, errNotAvailable
+	}
+	venue := strings.ToUpper(strings.TrimPrefix(r.URL.Path, "/v1/subscriptions/"))
+	var req struct {
+		Subscribe   []string `json:"subscribe"`
+		Unsubscribe []string `json:"unsubscribe"`
+	}
+	if err := decode(r, &req); err != nil {
+		return nil, err
+	}
+	if len(req.Subscribe)+len(req.Unsubscribe) == 0 {
+		return nil, badRequest(errors.New("nothing to subscribe or unsubscribe"))
+	}
+	if len(req.Subscribe) > 0 {
+		if err := s.Router.SubscribeSymbols(venue, upper(req.Subscribe)...); err != nil {
+			return nil, badRequest(err)
+		}
+	}
+	if len(req.Unsubscribe) > 0 {
+		if err := s.Router.UnsubscribeSymbols(venue, upper(req.Unsubscribe)...); err != nil {
+			return nil, badRequest(err)
+		}
+	}
+	return s.subscriptions(r)
+}
+
+func upper(in []string) []string {
+	out := make([]string, 0, len(in))
+	for _, s := range in {
+		out = append(out, strings.ToUpper(strings.TrimSpace(s)))
+	}
+	return out
+}
+
+func (s *Server) replayStatus(*http.Request) (any, error) {
+	if s.Replay == nil {
+		return nil, errNotAvailable
+	}
+	return s.Replay.ReplayStatus(), nil
+}
+
+func (s *Server) replayStart(r *http.Request) (any, error) {
+	if s.Replay == nil {
+		return nil, errNotAvailable
+	}
+	var req struct {
+		Path  string  `json:"path"`
+		Speed float64 `json:"speed"`
+	}
+	if err := decode(r, &req); err != nil {
+		return nil, err
+	}
+	if req.Path == "" {
+		return nil, badRequest(errors.New("path is required"))
+	}
+	if err := s.Replay.StartReplay(req.Path, req.Speed); err != nil {
+		return nil, httpError{code: http.StatusConflict, err: err}
+	}
+	return s.Replay.ReplayStatus(), nil
+}
+
+func (s *Server) replayStop(*http.Request) (any, error) {
+	if s.Replay == nil {
+		return nil, errNotAvailable
+	}
+	if err := s.Replay.StopReplay(); err != nil {
+		return nil, httpError{code: http.StatusConflict, err: err}
+	}
+	return s.Replay.ReplayStatus(), nil
+}
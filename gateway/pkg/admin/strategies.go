@@ -0,0 +1,100 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/strategy"
+)
+
+// Strategy is one hosted strategy or client as /v1/strategies reports it.
+type Strategy struct {
+	Name         string     `json:"name"`
+	Running      bool       `json:"running"`
+	Halted       bool       `json:"halted"`
+	HaltReason   string     `json:"halt_reason,omitempty"`
+	HaltedSince  time.Time  `json:"halted_since,omitempty"`
+	Sent         uint64     `json:"sent"`
+	Refused      uint64     `json:"refused"`
+	OpenNotional float64    `json:"open_notional"`
+	Realized     float64    `json:"realized_pnl"`
+	Unrealized   float64    `json:"unrealized_pnl"`
+	Holdings     []Holding  `json:"holdings"`
+	Quota        QuotaLimit `json:"quota"`
+}
+
+type Holding struct {
+	Symbol     string  `json:"symbol"`
+	Qty        float64 `json:"qty"`
+	AvgPrice   float64 `json:"avg_price"`
+	Realized   float64 `json:"realized_pnl"`
+	Unrealized float64 `json:"unrealized_pnl"`
+}
+
+type QuotaLimit struct {
+	MaxOrdersPerSec int     `json:"max_orders_per_sec,omitempty"`
+	MaxOpenNotional float64 `json:"max_open_notional,omitempty"`
+	MaxPosition     float64 `json:"max_position,omitempty"`
+}
+
+func (s *Server) strategies(*http.Request) (any, error) {
+	if s.Strategies == nil {
+		return nil, errNotAvailable
+	}
+	out := []Strategy{}
+	for _, st := range s.Strategies.Status() {
+		out = append(out, strategyView(st))
+	}
+	return out, nil
+}
+
+// strategyHalt handles POST /v1/strategies/{name}/halt with {"reason":...}
+// and POST /v1/strategies/{name}/resume.
+func (s *Server) strategyHalt(r *http.Request) (any, error) {
+	if s.Strategies == nil {
+		return nil, errNotAvailable
+	}
+	name, op, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/strategies/"), "/")
+	var ok bool
+	switch op {
+	case "halt":
+		var req struct {
+			Reason string `json:"reason"`
+		}
+		if err := decode(r, &req); err != nil {
+			return nil, err
+		}
+		if req.Reason == "" {
+			req.Reason = "admin"
+		}
+		ok = s.Strategies.Halt(name, req.Reason)
+	case "resume":
+		ok = s.Strategies.Resume(name)
+	default:
+		return nil, httpError{code: http.StatusNotFound, err: errors.New("want /v1/strategies/{name}/halt or /resume")}
+	}
+	if !ok {
+		return nil, httpError{code: http.StatusNotFound, err: errors.New("no strategy " + name)}
+	}
+	for _, st := range s.Strategies.Status() {
+		if st.Name == name {
+			return strategyView(st), nil
+		}
+	}
+	return nil, httpError{code: http.StatusNotFound, err: errors.New("no strategy " + name)}
+}
+
+func strategyView(st strategy.Status) Strategy {
+	v := Strategy{Name: st.Name, Running: st.Running, Sent: st.Sent, Refused: st.Refused, OpenNotional: st.OpenNotional,
+		Realized: st.Realized, Unrealized: st.Unrealized, Holdings: []Holding{},
+		Quota: QuotaLimit{MaxOrdersPerSec: st.Quota.MaxOrdersPerSec, MaxOpenNotional: st.Quota.MaxOpenNotional, MaxPosition: st.Quota.MaxPosition}}
+	if st.Halt != nil {
+		v.Halted, v.HaltReason, v.HaltedSince = true, st.Halt.Reason, st.Halt.Since
+	}
+	for _, h := range st.Holdings {
+		v.Holdings = append(v.Holdings, Holding{Symbol: h.Symbol, Qty: h.Qty, AvgPrice: h.AvgPrice, Realized: h.Realized, Unrealized: h.Unrealized})
+	}
+	return v
+}
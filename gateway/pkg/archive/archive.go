@@ -0,0 +1,450 @@
+// Package archive downloads the venues' public historical data archives —
+// Bybit's daily trade files and Binance's data.binance.vision trades and
+// klines — and converts one day at a time into Helix's capture formats:
+// trades CSVs as `helix record trades` writes them and bar CSVs as
+// candles.CSVWriter writes them. Bybit publishes no kline archive, so its
+// bars are built from the day's trades.
+package archive
+
+import (
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/candles"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// Kinds of data.
+const (
+	KindTrades = "trades"
+	KindKlines = "klines"
+)
+
+// Archive roots.
+const (
+	BybitURL   = "https://public.bybit.com"
+	BinanceURL = "https://data.binance.vision"
+)
+
+// ErrNotFound is returned for a day the archive does not have, e.g. today
+// or a symbol that did not trade yet.
+var ErrNotFound = errors.New("not in the archive")
+
+// TradesHeader is the trades capture header, as the live recorder's.
+var TradesHeader = []string{"ts_ms", "side", "price", "size", "trade_id"}
+
+// Request is one day of one symbol.
+type Request struct {
+	// Venue is BYBIT or BINANCE; Market is linear, inverse or spot.
+	Venue  string
+	Market string
+	Symbol string
+	Kind   string
+	// Interval is the bar length of klines.
+	Interval time.Duration
+	// Day is the UTC date to fetch.
+	Day time.Time
+}
+
+func (r Request) date() string { return r.Day.UTC().Format("2006-01-02") }
+
+// Downloader fetches archives over HTTP. Zipped archives are spooled to a
+// temporary file, as zip needs random access.
+type Downloader struct {
+	Client *http.Client
+	// BybitURL and BinanceURL are the archive roots; mirrors and tests
+	// override them.
+	BybitURL   string
+	BinanceURL string
+	// TempDir holds the spooled zips (empty = the system's).
+	TempDir string
+}
+
+func New() *Downloader {
+	return &Downloader{Client: &http.Client{Timeout: 10 * time.Minute}, BybitURL: BybitURL, BinanceURL: BinanceURL}
+}
+
+// binanceIntervals are the kline lengths Binance archives.
+var binanceIntervals = map[time.Duration]string{
+	time.Minute: "1m", 3 * time.Minute: "3m", 5 * time.Minute: "5m", 15 * time.Minute: "15m", 30 * time.Minute: "30m",
+	time.Hour: "1h", 2 * time.Hour: "2h", 4 * time.Hour: "4h", 6 * time.Hour: "6h", 8 * time.Hour: "8h",
+	12 * time.Hour: "12h", 24 * time.Hour: "1d",
+}
+
+// URL is the archive file holding r.
+func (d *Downloader) URL(r Request) (string, error) {
+	sym := strings.ToUpper(r.Symbol)
+	switch strings.ToUpper(r.Venue) {
+	case "BYBIT":
+		switch r.Market {
+		case "linear", "inverse":
+			// trades only; klines are built from them
+			return fmt.Sprintf("%s/trading/%s/%s%s.csv.gz", d.BybitURL, sym, sym, r.date()), nil
+		case "spot":
+			return fmt.Sprintf("%s/spot/%s/%s_%s.csv.gz", d.BybitURL, sym, sym, r.date()), nil
+		}
+		return "", fmt.Errorf("bybit: no archive for the %q market", r.Market)
+	case "BINANCE":
+		var root string
+		switch r.Market {
+		case "spot":
+			root = "spot"
+		case "linear":
+			root = "futures/um"
+		case "inverse":
+			root = "futures/cm"
+		default:
+			return "", fmt.Errorf("binance: no archive for the %q market", r.Market)
+		}
+		switch r.Kind {
+		case KindTrades:
+			return fmt.Sprintf("%s/data/%s/daily/trades/%s/%s-trades-%s.zip", d.BinanceURL, root, sym, sym, r.date()), nil
+		case KindKlines:
+			iv, ok := binanceIntervals[r.Interval]
+			if !ok {
+				return "", fmt.Errorf("binance: no %s klines", r.Interval)
+			}
+			return fmt.Sprintf("%s/data/%s/daily/klines/%s/%s/%s-%s-%s.zip", d.BinanceURL, root, sym, iv, sym, iv, r.date()), nil
+		}
+		return "", fmt.Errorf("unknown kind %q", r.Kind)
+	}
+	return "", fmt.Errorf("no archive for venue %q", r.Venue)
+}
+
+// Fetch downloads r and writes it to w in Helix's format, returning the
+// rows written.
+func (d *Downloader) Fetch(ctx context.Context, r Request, w io.Writer) (int, error) {
+	if r.Kind != KindTrades && r.Kind != KindKlines {
+		return 0, fmt.Errorf("unknown kind %q", r.Kind)
+	}
+	if r.Kind == KindKlines && r.Interval <= 0 {
+		return 0, errors.New("klines need an interval")
+	}
+	u, err := d.URL(r)
+	if err != nil {
+		return 0, err
+	}
+	body, err := d.open(ctx, u)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	venue := strings.ToUpper(r.Venue)
+	if r.Kind == KindKlines && venue == "BINANCE" {
+		bars, err := binanceKlines(body, venue, strings.ToUpper(r.Symbol), r.Interval)
+		if err != nil {
+			return 0, err
+		}
+		return writeBars(w, bars)
+	}
+	var trades []Trade
+	if venue == "BYBIT" {
+		trades, err = bybitTrades(body, r.Market == "spot")
+	} else {
+		trades, err = binanceTrades(body)
+	}
+	if err != nil {
+		return 0, err
+	}
+	// older Bybit files run newest first
+	sort.SliceStable(trades, func(i, j int) bool { return trades[i].TsMs < trades[j].TsMs })
+	if r.Kind == KindTrades {
+		return writeTrades(w, trades)
+	}
+	agg := candles.New(r.Interval)
+	var bars []transport.Bar
+	for _, t := range trades {
+		px, _ := strconv.ParseFloat(t.Price, 64)
+		qty, _ := strconv.ParseFloat(t.Size, 64)
+		bars = append(bars, agg.Trade(transport.Trade{Venue: venue, Symbol: strings.ToUpper(r.Symbol),
+			Side: strings.ToUpper(t.Side), Price: px, Qty: qty, TsMs: t.TsMs})...)
+	}
+	bars = append(bars, agg.Flush()...)
+	return writeBars(w, bars)
+}
+
+// open returns the archive's CSV, unpacked.
+func (d *Downloader) open(ctx context.Context, u string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, u)
+	case resp.StatusCode != http.StatusOK:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: status %s", u, resp.Status)
+	}
+	if strings.HasSuffix(u, ".gz") {
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s: %w", u, err)
+		}
+		return readCloser{zr, resp.Body}, nil
+	}
+	defer resp.Body.Close()
+	tmp, err := os.CreateTemp(d.TempDir, "helix-archive-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	spool := spooled{File: tmp}
+	n, err := io.Copy(tmp, resp.Body)
+	if err != nil {
+		spool.Close()
+		return nil, fmt.Errorf("%s: %w", u, err)
+	}
+	zr, err := zip.NewReader(tmp, n)
+	if err != nil {
+		spool.Close()
+		return nil, fmt.Errorf("%s: %w", u, err)
+	}
+	for _, f := range zr.File {
+		if strings.HasSuffix(f.Name, ".csv") {
+			rc, err := f.Open()
+			if err != nil {
+				spool.Close()
+				return nil, err
+			}
+			return readCloser{rc, multiCloser{rc, spool}}, nil
+		}
+	}
+	spool.Close()
+	return nil, fmt.Errorf("%s: no csv in the zip", u)
+}
+
+// spooled is a temporary file removed on Close.
+type spooled struct{ *os.File }
+
+func (s spooled) Close() error {
+	err := s.File.Close()
+	os.Remove(s.Name())
+	return err
+}
+
+type multiCloser []io.Closer
+
+func (m multiCloser) Close() error {
+	var errs []error
+	for _, c := range m {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
+
+type readCloser struct {
+	io.Reader
+	body io.Closer
+}
+
+func (r readCloser) Close() error { return r.body.Close() }
+
+// Trade is one archived execution, with price and size as published.
+type Trade struct {
+	TsMs  int64
+	Side  string
+	Price string
+	Size  string
+	ID    string
+}
+
+// bybitTrades reads a Bybit daily file: derivatives files have a header
+// and fractional-second timestamps, spot ones id,timestamp(ms),price,
+// volume,side.
+func bybitTrades(r io.Reader, spot bool) ([]Trade, error) {
+	cr := csv.NewReader(bufio.NewReader(r))
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	cols := map[string]int{"id": 0, "timestamp": 1, "price": 2, "size": 3, "side": 4}
+	if !spot {
+		header, err := cr.Read()
+		if err != nil {
+			return nil, fmt.Errorf("header: %w", err)
+		}
+		cols = map[string]int{}
+		for i, h := range header {
+			cols[strings.TrimSpace(h)] = i
+		}
+		cols["id"] = cols["trdMatchID"]
+		for _, c := range []string{"timestamp", "side", "size", "price", "trdMatchID"} {
+			if _, ok := cols[c]; !ok {
+				return nil, fmt.Errorf("no %s column", c)
+			}
+		}
+	}
+	var out []Trade
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if spot && line == 1 && !digits(rec[cols["timestamp"]]) {
+			continue // header
+		}
+		ts, err := millis(rec[cols["timestamp"]], !spot)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: timestamp: %w", line, err)
+		}
+		side := rec[cols["side"]]
+		switch strings.ToLower(side) {
+		case "buy":
+			side = "Buy"
+		case "sell":
+			side = "Sell"
+		}
+		out = append(out, Trade{TsMs: ts, Side: side, Price: rec[cols["price"]], Size: rec[cols["size"]], ID: rec[cols["id"]]})
+	}
+}
+
+// binanceTrades reads id,price,qty,quote_qty,time,is_buyer_maker[,...],
+// with or without a header. The buyer being the maker means the taker
+// sold.
+func binanceTrades(r io.Reader) ([]Trade, error) {
+	cr := csv.NewReader(bufio.NewReader(r))
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	var out []Trade
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if len(rec) < 6 {
+			return nil, fmt.Errorf("line %d: %d fields", line, len(rec))
+		}
+		if line == 1 && !digits(rec[0]) {
+			continue
+		}
+		ts, err := strconv.ParseInt(rec[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: time: %w", line, err)
+		}
+		side := "Buy"
+		if strings.EqualFold(rec[5], "true") {
+			side = "Sell"
+		}
+		out = append(out, Trade{TsMs: binanceMillis(ts), Side: side, Price: rec[1], Size: rec[2], ID: rec[0]})
+	}
+}
+
+// binanceKlines reads open_time,open,high,low,close,volume,close_time,
+// quote_volume,count,..., with or without a header.
+func binanceKlines(r io.Reader, venue, symbol string, interval time.Duration) ([]transport.Bar, error) {
+	cr := csv.NewReader(bufio.NewReader(r))
+	cr.FieldsPerRecord = -1
+	var out []transport.Bar
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if len(rec) < 9 {
+			return nil, fmt.Errorf("line %d: %d fields", line, len(rec))
+		}
+		if line == 1 && !digits(rec[0]) {
+			continue
+		}
+		var f [8]float64
+		for i, col := range []int{1, 2, 3, 4, 5, 7} {
+			if f[i], err = strconv.ParseFloat(rec[col], 64); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		start, errT := strconv.ParseInt(rec[0], 10, 64)
+		n, errN := strconv.Atoi(rec[8])
+		if errT != nil || errN != nil {
+			return nil, fmt.Errorf("line %d: open_time or count does not parse", line)
+		}
+		b := transport.Bar{Venue: venue, Symbol: symbol, Interval: interval, StartMs: binanceMillis(start),
+			Open: f[0], High: f[1], Low: f[2], Close: f[3], Volume: f[4], Trades: n}
+		if b.Volume > 0 {
+			b.VWAP = f[5] / b.Volume
+		}
+		out = append(out, b)
+	}
+}
+
+// binanceMillis undoes the microsecond timestamps of Binance's newer spot
+// archives.
+func binanceMillis(ts int64) int64 {
+	if ts > 1e14 {
+		return ts / 1000
+	}
+	return ts
+}
+
+// millis parses a timestamp in milliseconds, or in seconds with a
+// fraction when seconds is set, without going through a float.
+func millis(s string, seconds bool) (int64, error) {
+	if !seconds {
+		return strconv.ParseInt(s, 10, 64)
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	sec, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	frac = (frac + "000")[:3]
+	ms, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return sec*1000 + ms, nil
+}
+
+func digits(s string) bool {
+	_, err := strconv.ParseInt(s, 10, 64)
+	return err == nil
+}
+
+func writeTrades(w io.Writer, trades []Trade) (int, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(TradesHeader); err != nil {
+		return 0, err
+	}
+	for _, t := range trades {
+		if err := cw.Write([]string{strconv.FormatInt(t.TsMs, 10), t.Side, t.Price, t.Size, t.ID}); err != nil {
+			return 0, err
+		}
+	}
+	cw.Flush()
+	return len(trades), cw.Error()
+}
+
+func writeBars(w io.Writer, bars []transport.Bar) (int, error) {
+	cw := candles.NewCSVWriter(w)
+	for _, b := range bars {
+		if err := cw.Write(b); err != nil {
+			return 0, err
+		}
+	}
+	return len(bars), nil
+}
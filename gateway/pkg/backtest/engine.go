@@ -0,0 +1,348 @@
+// Package backtest runs strategies over recorded market data through the
+// same strategy host, SmartRouter and executor limits the gateway uses
+// live, on a simulated clock driven by the recorded timestamps.
+package backtest
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/fx"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/router"
+	"github.com/helix-lab/helix/gateway/pkg/strategy"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// Config is what a run assumes about the world outside the recording.
+type Config struct {
+	Fees   router.FeeModel
+	Limits executor.Limits
+	// Latency is the simulated time from Submit to execution; the order
+	// fills against the book as it is then.
+	Latency time.Duration
+	// VenueLatency overrides Latency for orders to these venues.
+	VenueLatency map[string]time.Duration
+	// CurveEvery spaces the PnL curve samples taken between fills
+	// (default one second of simulated time).
+	CurveEvery time.Duration
+	// Base, if set, is the currency notional, fees and PnL are reported
+	// in, converted from each symbol's quote at the replayed books' rates.
+	Base string
+}
+
+// Fill is one simulated execution; Maker fills pay the maker fee.
+type Fill struct {
+	TsNs     int64
+	Strategy string
+	transport.Fill
+	// Notional and Fee are in the run's base currency.
+	Notional float64
+	Fee      float64
+	// ArrivalMid is the venue mid when the order was submitted (0 when
+	// there was none); SlippageBps is how much worse than it the fill was.
+	ArrivalMid  float64
+	SlippageBps float64
+}
+
+// CurvePoint is the marked-to-market PnL, net of fees, at TsNs.
+type CurvePoint struct {
+	TsNs int64   `json:"ts_ns"`
+	PnL  float64 `json:"pnl"`
+}
+
+// Result is what a run produced.
+type Result struct {
+	Start, End time.Time
+	// Base is the currency of the amounts, empty when each symbol's quote
+	// was summed as is.
+	Base   string
+	Events int
+	// Orders counts every Submit, refused or not.
+	Orders int
+	// Rejected were refused by the kill switch or limits; Unfilled found
+	// no liquidity at execution or were still in flight or resting at the
+	// end.
+	Rejected  int
+	Unfilled  int
+	Fills     []Fill
+	Curve     []CurvePoint
+	Positions []executor.Position
+	Fees      float64
+	Realized  float64
+	// Unrealized marks open positions to the last mid of their venue.
+	Unrealized float64
+}
+
+// PnL is realized plus unrealized, net of fees.
+func (r *Result) PnL() float64 { return r.Realized + r.Unrealized - r.Fees }
+
+// Engine replays events into hosted strategies. Use it once.
+type Engine struct {
+	cfg     Config
+	books   *orderbook.Manager
+	rates   *fx.Rates
+	tracker *executor.Tracker
+	sender  *executor.OrderSender
+	host    *strategy.Host
+	queue   *executor.MakerQueue
+	arrival map[string]float64 // order ID -> venue mid at submit
+
+	now     int64
+	sampled int64
+	pending []pendingOrder
+	res     Result
+}
+
+type pendingOrder struct {
+	due    int64
+	action transport.Action
+}
+
+func New(cfg Config) *Engine {
+	e := &Engine{
+		cfg:     cfg,
+		books:   orderbook.NewManager(),
+		tracker: executor.NewTracker(),
+		queue:   executor.NewMakerQueue(),
+		arrival: make(map[string]float64),
+	}
+	if e.cfg.CurveEvery <= 0 {
+		e.cfg.CurveEvery = time.Second
+	}
+	if cfg.Base != "" {
+		e.rates = fx.New(e.books, cfg.Base)
+		e.res.Base = e.rates.Base
+	}
+	e.sender = executor.NewOrderSender(transport.NewPublisher("backtest"), router.NewSmartRouter(cfg.Fees))
+	e.sender.SetTracker(e.tracker)
+	e.sender.SetLimits(cfg.Limits)
+	e.sender.SetSink(e)
+	e.host = strategy.NewHost(e.books, e.sender, e.tracker)
+	return e
+}
+
+func (e *Engine) Add(cfg strategy.Config, s strategy.Strategy) {
+	e.host.Add(cfg, s)
+}
+
+// Now is the simulated clock.
+func (e *Engine) Now() time.Time { return time.Unix(0, e.now) }
+
+// Submit queues action for execution after its venue's latency; it is the
+// engine's executor.Sink.
+func (e *Engine) Submit(_ context.Context, action transport.Action) error {
+	if b, _ := e.books.Venue(action.Venue, action.Symbol, action.Category); b.BestBid > 0 && b.BestAsk > 0 {
+		e.arrival[action.ID] = (b.BestBid + b.BestAsk) / 2
+	}
+	latency := e.cfg.Latency
+	if d, ok := e.cfg.VenueLatency[action.Venue]; ok {
+		latency = d
+	}
+	e.pending = append(e.pending, pendingOrder{due: e.now + int64(latency), action: action})
+	return nil
+}
+
+// Run feeds events, which must be in time order, to the strategies and
+// returns the outcome. It stops early once every strategy has finished.
+func (e *Engine) Run(ctx context.Context, events []Event) (*Result, error) {
+	if len(events) == 0 {
+		return nil, fmt.Errorf("backtest: no events")
+	}
+	e.now = events[0].TsNs
+	e.res.Start = e.Now()
+	timer := e.host.Interval()
+	nextTimer := e.now + int64(timer)
+	done := e.host.Done()
+	for _, ev := range events {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		select {
+		case <-done:
+			return e.finish(), nil
+		default:
+		}
+		if ev.TsNs < e.now {
+			return nil, fmt.Errorf("backtest: event at %d before clock %d", ev.TsNs, e.now)
+		}
+		// timers up to and orders due before the event see the book
+		// as it was until then
+		for {
+			due := e.nextDue()
+			if timer > 0 && nextTimer <= ev.TsNs && (due < 0 || nextTimer <= due) {
+				e.now = nextTimer
+				e.host.Timer(ctx, e.Now())
+				nextTimer += int64(timer)
+				continue
+			}
+			if due >= 0 && due < ev.TsNs {
+				e.now = due
+				e.execute(ctx)
+				continue
+			}
+			break
+		}
+		e.now = ev.TsNs
+		e.res.Events++
+		switch {
+		case ev.Depth != nil:
+			e.books.Apply(*ev.Depth)
+			e.fill(ctx, e.queue.Depth(*ev.Depth)...)
+			e.host.Book(ctx, *ev.Depth)
+		case ev.Trade != nil:
+			e.fill(ctx, e.queue.Trade(*ev.Trade)...)
+			e.host.Trade(ctx, *ev.Trade)
+		}
+		e.execute(ctx)
+		if e.now-e.sampled >= int64(e.cfg.CurveEvery) {
+			e.sample()
+		}
+	}
+	return e.finish(), nil
+}
+
+// sample appends the current marked-to-market PnL to the curve.
+func (e *Engine) sample() {
+	e.sampled = e.now
+	realized, unrealized := e.mark()
+	pt := CurvePoint{TsNs: e.now, PnL: realized + unrealized - e.res.Fees}
+	if n := len(e.res.Curve); n > 0 && e.res.Curve[n-1].TsNs == e.now {
+		e.res.Curve[n-1] = pt
+		return
+	}
+	e.res.Curve = append(e.res.Curve, pt)
+}
+
+// mark sums realized PnL and marks open positions to their venue's mid.
+func (e *Engine) mark() (realized, unrealized float64) {
+	for _, p := range e.tracker.Positions() {
+		realized += e.value(p.Symbol, p.Realized)
+		if p.Qty == 0 {
+			continue
+		}
+		b, _ := e.books.Venue(p.Venue, p.Symbol, "")
+		if b.BestBid > 0 && b.BestAsk > 0 {
+			unrealized += e.value(p.Symbol, ((b.BestBid+b.BestAsk)/2-p.AvgPrice)*p.Qty)
+		}
+	}
+	return realized, unrealized
+}
+
+// value converts amount from symbol's quote to the base, at par when there
+// is no rate.
+func (e *Engine) value(symbol string, amount float64) float64 {
+	if e.rates == nil {
+		return amount
+	}
+	if v, ok := e.rates.ConvertQuote(symbol, amount); ok {
+		return v
+	}
+	return amount
+}
+
+// nextDue is the earliest pending execution time, or -1.
+func (e *Engine) nextDue() int64 {
+	due := int64(-1)
+	for _, p := range e.pending {
+		if due < 0 || p.due < due {
+			due = p.due
+		}
+	}
+	return due
+}
+
+// execute sends every order due by now to the book: market and
+// marketable limit orders take the touch of their venue, others join the
+// maker queue.
+func (e *Engine) execute(ctx context.Context) {
+	var keep []pendingOrder
+	for i := 0; i < len(e.pending); i++ {
+		p := e.pending[i]
+		if p.due > e.now {
+			keep = append(keep, p)
+			continue
+		}
+		a := p.action
+		b, _ := e.books.Venue(a.Venue, a.Symbol, a.Category)
+		lvl := b.Level
+		price, take := executor.Marketable(a, lvl)
+		switch {
+		case take:
+			// fills may submit more orders, which land in e.pending
+			e.fill(ctx, transport.Fill{OrderID: a.ID, Venue: a.Venue, Symbol: a.Symbol, Category: a.Category, Side: a.Side, Price: price, Qty: a.Size})
+		case a.Price > 0:
+			e.queue.Add(a, lvl)
+		default:
+			e.res.Unfilled++
+			_ = e.tracker.Cancel(a.ID)
+		}
+	}
+	e.pending = keep
+}
+
+func (e *Engine) fill(ctx context.Context, fills ...transport.Fill) {
+	for _, f := range fills {
+		rate := e.cfg.Fees.Taker[f.Venue]
+		if f.Maker {
+			rate = e.cfg.Fees.Maker[f.Venue]
+		}
+		notional := e.value(f.Symbol, f.Price*f.Qty)
+		fill := Fill{TsNs: e.now, Strategy: e.host.Owner(f.OrderID), Fill: f, Notional: notional, Fee: notional * rate}
+		if mid := e.arrival[f.OrderID]; mid > 0 {
+			fill.ArrivalMid = mid
+			fill.SlippageBps = (f.Price - mid) / mid * 1e4
+			if f.Side == "SELL" {
+				fill.SlippageBps = -fill.SlippageBps
+			}
+		}
+		e.res.Fills = append(e.res.Fills, fill)
+		e.res.Fees += fill.Fee
+		_ = e.host.Fill(ctx, f)
+		e.sample()
+	}
+}
+
+func (e *Engine) finish() *Result {
+	e.res.End = e.Now()
+	for _, p := range e.pending {
+		e.res.Unfilled++
+		_ = e.tracker.Cancel(p.action.ID)
+	}
+	e.pending = nil
+	for _, a := range e.queue.Orders() {
+		e.res.Unfilled++
+		e.queue.Cancel(a.ID)
+		_ = e.tracker.Cancel(a.ID)
+	}
+	sent, refused := e.host.Orders()
+	e.res.Orders = int(sent + refused)
+	e.res.Rejected += int(refused)
+	e.res.Positions = e.tracker.Positions()
+	e.res.Realized, e.res.Unrealized = e.mark()
+	e.sample()
+	sort.SliceStable(e.res.Fills, func(i, j int) bool { return e.res.Fills[i].TsNs < e.res.Fills[j].TsNs })
+	res := e.res
+	return &res
+}
+
+// WriteTrades writes the fills as CSV, the run's trade log.
+func (r *Result) WriteTrades(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"ts_ns", "strategy", "order_id", "venue", "symbol", "side", "price", "qty", "fee"})
+	for _, f := range r.Fills {
+		_ = cw.Write([]string{
+			strconv.FormatInt(f.TsNs, 10), f.Strategy, f.OrderID, f.Venue, f.Symbol, f.Side,
+			strconv.FormatFloat(f.Price, 'f', -1, 64), strconv.FormatFloat(f.Qty, 'f', -1, 64),
+			strconv.FormatFloat(f.Fee, 'f', -1, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
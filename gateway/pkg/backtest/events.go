@@ -0,0 +1,217 @@
+package backtest
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/helix-lab/helix/gateway/pkg/capture"
+	"github.com/helix-lab/helix/gateway/pkg/catalog"
+	"github.com/helix-lab/helix/gateway/pkg/decimal"
+	"github.com/helix-lab/helix/gateway/pkg/l2book"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+	"github.com/helix-lab/helix/gateway/pkg/ws"
+)
+
+// Event is one recorded market event at TsNs; exactly one of Depth and
+// Trade is set.
+type Event struct {
+	TsNs  int64
+	Depth *transport.DepthUpdate
+	Trade *transport.Trade
+}
+
+// Merge interleaves event streams by time. Events with equal timestamps
+// keep their stream order, earlier streams first.
+func Merge(streams ...[]Event) []Event {
+	var out []Event
+	for _, s := range streams {
+		out = append(out, s...)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].TsNs < out[j].TsNs })
+	return out
+}
+
+// LoadL2CSV rebuilds top-of-book events from a "helix record l2" CSV
+// (ts_ms,seq,prev_seq,book_side,price,size,type), one per recorded message.
+// Columns are found by name, from the header or else the sidecar's
+// columns; with a recv_ts_ms column events are stamped with the receive
+// time rather than the exchange's.
+func LoadL2CSV(path, venue, symbol string) ([]Event, error) {
+	declared, err := catalog.Columns(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	rows, err := readRows(r, declared, "ts_ms", "seq", "book_side", "price", "size", "type", "recv_ts_ms?")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	bids, asks := l2book.NewLadder(true), l2book.NewLadder(false)
+	var out []Event
+	var curSeq, curTs, curRecv int64 = -1, 0, 0
+	flush := func() {
+		if curSeq < 0 {
+			return
+		}
+		bid, bsz, _ := bids.Best()
+		ask, asz, _ := asks.Best()
+		if bid > 0 && ask > 0 {
+			u := transport.DepthUpdate{Venue: venue, Symbol: symbol, ExchTsMs: curTs, RecvNs: curRecv * 1e6,
+				BestBid: bid.Float(), BidSize: bsz.Float(), BestAsk: ask.Float(), AskSize: asz.Float()}
+			out = append(out, Event{TsNs: u.RecvNs, Depth: &u})
+		}
+	}
+	for _, row := range rows {
+		ts, err1 := strconv.ParseInt(row[0], 10, 64)
+		seq, err2 := strconv.ParseInt(row[1], 10, 64)
+		px, err3 := decimal.Parse(row[3])
+		qty, err4 := decimal.Parse(row[4])
+		if err := errors.Join(err1, err2, err3, err4); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if seq != curSeq {
+			flush()
+			if strings.EqualFold(row[5], "snapshot") {
+				bids.Reset()
+				asks.Reset()
+			}
+			curSeq, curTs, curRecv = seq, ts, ts
+			if row[6] != "" {
+				if curRecv, err = strconv.ParseInt(row[6], 10, 64); err != nil {
+					return nil, fmt.Errorf("%s: recv_ts_ms: %w", path, err)
+				}
+			}
+		}
+		side := bids
+		if strings.HasPrefix(strings.ToLower(row[2]), "a") {
+			side = asks
+		}
+		side.Set(px, qty)
+	}
+	flush()
+	return out, nil
+}
+
+// LoadTradesCSV reads a "helix record trades" CSV
+// (ts_ms,side,price,size,trade_id).
+func LoadTradesCSV(path, venue, symbol string) ([]Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	rows, err := readRows(r, nil, "ts_ms", "side", "price", "size")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	out := make([]Event, 0, len(rows))
+	for _, row := range rows {
+		ts, err1 := strconv.ParseInt(row[0], 10, 64)
+		px, err2 := strconv.ParseFloat(row[2], 64)
+		qty, err3 := strconv.ParseFloat(row[3], 64)
+		if err := errors.Join(err1, err2, err3); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		t := transport.Trade{Venue: venue, Symbol: symbol, Side: strings.ToUpper(row[1]), Price: px, Qty: qty, TsMs: ts}
+		out = append(out, Event{TsNs: ts * 1e6, Trade: &t})
+	}
+	return out, nil
+}
+
+// LoadFrames rebuilds depth and trade events from a gateway -tap frame
+// log, stamped with the frames' receive times.
+func LoadFrames(path string) ([]Event, error) {
+	streams := map[string]*ws.BybitStream{"BYBIT": ws.NewBybitStream("", nil, 1)}
+	depth := make(chan transport.DepthUpdate, 1024)
+	trades := make(chan transport.Trade, 1024)
+	feeds := ws.Feeds{Depth: depth, Trades: trades}
+	var out []Event
+	err := capture.ReadFrames(path, func(fr capture.Frame) error {
+		stream, ok := streams[fr.Source]
+		if !ok {
+			return nil
+		}
+		stream.HandleFrame(context.Background(), feeds, fr.RecvNs, fr.Payload())
+		for len(depth) > 0 {
+			u := <-depth
+			out = append(out, Event{TsNs: fr.RecvNs, Depth: &u})
+		}
+		for len(trades) > 0 {
+			t := <-trades
+			out = append(out, Event{TsNs: fr.RecvNs, Trade: &t})
+		}
+		return nil
+	})
+	return out, err
+}
+
+// readRows returns the named columns of every row after the header; a
+// column named with a trailing "?" is empty when missing. A file whose
+// first record is not a header is read with the declared columns, if any.
+func readRows(r *csv.Reader, declared []string, cols ...string) ([][]string, error) {
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	var first []string
+	if declared != nil && !hasColumn(header, cols[0]) {
+		header, first = declared, header
+	}
+	idx := make([]int, len(cols))
+	for i, c := range cols {
+		name, optional := strings.CutSuffix(c, "?")
+		idx[i] = -1
+		for j, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), name) {
+				idx[i] = j
+			}
+		}
+		if idx[i] < 0 && !optional {
+			return nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+	var out [][]string
+	for {
+		rec := first
+		if first != nil {
+			first = nil
+		} else if rec, err = r.Read(); err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := make([]string, len(cols))
+		for i, j := range idx {
+			if j >= 0 && j < len(rec) {
+				row[i] = strings.TrimSpace(rec[j])
+			}
+		}
+		out = append(out, row)
+	}
+}
+
+func hasColumn(record []string, name string) bool {
+	for _, f := range record {
+		if strings.EqualFold(strings.TrimSpace(f), name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package backtest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/decimal"
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// RestingOrder is a hypothetical passive limit order for InferMakerFill.
+type RestingOrder struct {
+	Venue  string
+	Symbol string
+	Side   string
+	Price  float64
+	Size   float64
+	// ArrivalMs is when the order reaches the venue. It joins the queue
+	// behind everything recorded in that millisecond.
+	ArrivalMs int64
+	// TTL cancels what is left this long after arrival (0 = never).
+	TTL time.Duration
+}
+
+// Maker fill outcomes.
+const (
+	OutcomeFilled     = "filled"
+	OutcomePartial    = "partial"
+	OutcomeUnfilled   = "unfilled"
+	OutcomeMarketable = "marketable"
+)
+
+// MakerFill is one execution of the hypothetical order.
+type MakerFill struct {
+	TsMs int64   `json:"ts_ms"`
+	Qty  float64 `json:"qty"`
+}
+
+// MakerFillReport is what InferMakerFill concluded.
+type MakerFillReport struct {
+	Side      string  `json:"side"`
+	Price     float64 `json:"price"`
+	Size      float64 `json:"size"`
+	ArrivalMs int64   `json:"arrival_ms"`
+	// Outcome is filled, partial, unfilled, or marketable when the order
+	// would have crossed the book on arrival and taken liquidity instead.
+	Outcome string `json:"outcome"`
+	// BestBid and BestAsk are the book the order arrived to.
+	BestBid float64 `json:"best_bid"`
+	BestAsk float64 `json:"best_ask"`
+	// QueueAhead is the displayed size in front of the order on arrival,
+	// -1 when it was behind the touch and its queue not yet visible.
+	QueueAhead  float64     `json:"queue_ahead"`
+	Fills       []MakerFill `json:"fills"`
+	Filled      float64     `json:"filled"`
+	FirstFillMs int64       `json:"first_fill_ms,omitempty"`
+	FullFillMs  int64       `json:"full_fill_ms,omitempty"`
+	// EndMs is when the order stopped resting: its last fill, its TTL or
+	// the end of the capture.
+	EndMs int64 `json:"end_ms"`
+}
+
+// InferMakerFill replays events, which Merge ordered, against a
+// hypothetical order resting in the venue's queue, as the backtester's
+// MakerQueue models it, and reports whether and when it would have filled.
+func InferMakerFill(events []Event, o RestingOrder) (MakerFillReport, error) {
+	o.Side = strings.ToUpper(o.Side)
+	rep := MakerFillReport{Side: o.Side, Price: o.Price, Size: o.Size, ArrivalMs: o.ArrivalMs, Outcome: OutcomeUnfilled, QueueAhead: -1}
+	if o.Side != "BUY" && o.Side != "SELL" {
+		return rep, fmt.Errorf("side %q: want buy or sell", o.Side)
+	}
+	if !(o.Price > 0) || !(o.Size > 0) {
+		return rep, errors.New("price and size must be positive")
+	}
+	action := transport.Action{ID: "probe", Venue: o.Venue, Symbol: o.Symbol, Side: o.Side, Price: o.Price, Size: o.Size}
+	q := executor.NewMakerQueue()
+	var lvl orderbook.Level
+	arrived := false
+	left := o.Size
+	record := func(tsMs int64, fills []transport.Fill) {
+		for _, f := range fills {
+			rep.Fills = append(rep.Fills, MakerFill{TsMs: tsMs, Qty: f.Qty})
+			rep.Filled = decimal.Add(rep.Filled, f.Qty)
+			left = decimal.Add(left, -f.Qty)
+			if rep.FirstFillMs == 0 {
+				rep.FirstFillMs = tsMs
+			}
+			rep.EndMs = tsMs
+		}
+	}
+	for _, ev := range events {
+		ts := ev.TsNs / 1e6
+		if !arrived && ts > o.ArrivalMs {
+			if lvl.BestBid <= 0 || lvl.BestAsk <= 0 {
+				return rep, fmt.Errorf("no two-sided %s %s book by arrival", o.Venue, o.Symbol)
+			}
+			arrived = true
+			rep.BestBid, rep.BestAsk, rep.EndMs = lvl.BestBid, lvl.BestAsk, o.ArrivalMs
+			if _, cross := executor.Marketable(action, lvl); cross {
+				rep.Outcome = OutcomeMarketable
+				return rep, nil
+			}
+			q.Add(action, lvl)
+			if ahead, _ := q.Ahead(action.ID); !math.IsInf(ahead, 1) {
+				rep.QueueAhead = ahead
+			}
+		}
+		if arrived && o.TTL > 0 && ts > o.ArrivalMs+o.TTL.Milliseconds() {
+			rep.EndMs = o.ArrivalMs + o.TTL.Milliseconds()
+			break
+		}
+		switch {
+		case ev.Depth != nil:
+			u := *ev.Depth
+			if u.Venue != o.Venue || u.Symbol != o.Symbol {
+				continue
+			}
+			lvl = orderbook.Level{BestBid: u.BestBid, BestAsk: u.BestAsk, BidSize: u.BidSize, AskSize: u.AskSize}
+			if arrived {
+				record(ts, q.Depth(u))
+			}
+		case ev.Trade != nil && arrived:
+			record(ts, q.Trade(*ev.Trade))
+		}
+		if arrived {
+			rep.EndMs = max(rep.EndMs, ts)
+		}
+		if left <= 0 {
+			rep.FullFillMs = ts
+			break
+		}
+	}
+	if !arrived {
+		return rep, errors.New("the capture ends before the order arrives")
+	}
+	switch {
+	case left <= 0:
+		rep.Outcome = OutcomeFilled
+	case rep.Filled > 0:
+		rep.Outcome = OutcomePartial
+	}
+	return rep, nil
+}
@@ -0,0 +1,58 @@
+package backtest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Profile is a named latency tier: how long our orders take to reach each
+// venue from where the strategies run.
+type Profile struct {
+	Name string
+	// Latency applies to venues not in Venues.
+	Latency time.Duration
+	Venues  map[string]time.Duration
+}
+
+// Profiles are the built-in tiers; a config may add to or redefine them.
+var Profiles = []Profile{
+	{Name: "colo", Latency: 2 * time.Millisecond},
+	{Name: "cloud", Latency: 40 * time.Millisecond},
+	{Name: "retail", Latency: 150 * time.Millisecond},
+}
+
+// Apply returns cfg with the profile's latencies.
+func (p Profile) Apply(cfg Config) Config {
+	cfg.Latency = p.Latency
+	cfg.VenueLatency = p.Venues
+	return cfg
+}
+
+// String is the latency with any per-venue overrides, e.g.
+// "40ms (BYBIT 2ms)".
+func (p Profile) String() string {
+	if len(p.Venues) == 0 {
+		return p.Latency.String()
+	}
+	venues := make([]string, 0, len(p.Venues))
+	for v := range p.Venues {
+		venues = append(venues, v)
+	}
+	sort.Strings(venues)
+	for i, v := range venues {
+		venues[i] = fmt.Sprintf("%s %s", v, p.Venues[v])
+	}
+	return fmt.Sprintf("%s (%s)", p.Latency, strings.Join(venues, ", "))
+}
+
+// FindProfile looks name up in profiles, case-insensitively.
+func FindProfile(profiles []Profile, name string) (Profile, bool) {
+	for _, p := range profiles {
+		if strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+	}
+	return Profile{}, false
+}
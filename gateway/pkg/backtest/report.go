@@ -0,0 +1,227 @@
+package backtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Report summarises a run in a form that compares across runs.
+type Report struct {
+	Start       time.Time    `json:"start"`
+	End         time.Time    `json:"end"`
+	Latency     string       `json:"latency"`
+	Profile     string       `json:"profile,omitempty"`
+	Base        string       `json:"base,omitempty"`
+	Events      int          `json:"events"`
+	Orders      int          `json:"orders"`
+	Fills       int          `json:"fills"`
+	Rejected    int          `json:"rejected"`
+	Unfilled    int          `json:"unfilled"`
+	PnL         float64      `json:"pnl"`
+	Realized    float64      `json:"realized"`
+	Unrealized  float64      `json:"unrealized"`
+	Fees        float64      `json:"fees"`
+	MaxDrawdown float64      `json:"max_drawdown"`
+	Venues      []VenueFills `json:"venues"`
+	Curve       []CurvePoint `json:"curve"`
+	// Sensitivity reruns the same data and strategies under other
+	// latencies or latency profiles.
+	Sensitivity []LatencyRun `json:"sensitivity,omitempty"`
+}
+
+// VenueFills is the fill quality on one venue.
+type VenueFills struct {
+	Venue    string  `json:"venue"`
+	Fills    int     `json:"fills"`
+	Qty      float64 `json:"qty"`
+	Notional float64 `json:"notional"`
+	// MakerShare is the fraction of filled quantity that rested.
+	MakerShare float64 `json:"maker_share"`
+	// SlippageBps is the quantity-weighted slippage against the arrival
+	// mid; negative is price improvement.
+	SlippageBps float64 `json:"slippage_bps"`
+	Fees        float64 `json:"fees"`
+}
+
+// LatencyRun is the outcome of one run in a latency sweep.
+type LatencyRun struct {
+	Latency     string  `json:"latency"`
+	Profile     string  `json:"profile,omitempty"`
+	PnL         float64 `json:"pnl"`
+	Fees        float64 `json:"fees"`
+	Fills       int     `json:"fills"`
+	SlippageBps float64 `json:"slippage_bps"`
+	MaxDrawdown float64 `json:"max_drawdown"`
+}
+
+// NewReport builds the report of a run made with latency.
+func NewReport(res *Result, latency time.Duration) *Report {
+	r := &Report{
+		Start: res.Start, End: res.End, Latency: latency.String(), Base: res.Base,
+		Events: res.Events, Orders: res.Orders, Fills: len(res.Fills), Rejected: res.Rejected, Unfilled: res.Unfilled,
+		PnL: res.PnL(), Realized: res.Realized, Unrealized: res.Unrealized, Fees: res.Fees,
+		MaxDrawdown: MaxDrawdown(res.Curve),
+		Curve:       res.Curve,
+	}
+	byVenue := map[string][]Fill{}
+	for _, f := range res.Fills {
+		byVenue[f.Venue] = append(byVenue[f.Venue], f)
+	}
+	for venue, fills := range byVenue {
+		v := VenueFills{Venue: venue, Fills: len(fills), SlippageBps: avgSlippage(fills)}
+		for _, f := range fills {
+			v.Qty += f.Qty
+			v.Notional += f.Notional
+			v.Fees += f.Fee
+			if f.Maker {
+				v.MakerShare += f.Qty
+			}
+		}
+		if v.Qty > 0 {
+			v.MakerShare /= v.Qty
+		}
+		r.Venues = append(r.Venues, v)
+	}
+	sort.Slice(r.Venues, func(i, j int) bool { return r.Venues[i].Venue < r.Venues[j].Venue })
+	return r
+}
+
+// MaxDrawdown is the largest fall of the curve from a previous high.
+func MaxDrawdown(curve []CurvePoint) float64 {
+	var peak, dd float64
+	for i, p := range curve {
+		if i == 0 || p.PnL > peak {
+			peak = p.PnL
+		}
+		if d := peak - p.PnL; d > dd {
+			dd = d
+		}
+	}
+	return dd
+}
+
+// Sweep reruns events under each latency with an engine from build and
+// adds the outcomes to r.Sensitivity.
+func (r *Report) Sweep(ctx context.Context, events []Event, latencies []time.Duration, build func(latency time.Duration) (*Engine, error)) error {
+	for _, lat := range latencies {
+		eng, err := build(lat)
+		if err != nil {
+			return err
+		}
+		res, err := eng.Run(ctx, events)
+		if err != nil {
+			return fmt.Errorf("latency %s: %w", lat, err)
+		}
+		r.Sensitivity = append(r.Sensitivity, latencyRun(res, lat.String(), ""))
+	}
+	return nil
+}
+
+// SweepProfiles is Sweep over latency profiles, giving a table of the
+// strategies' PnL per tier.
+func (r *Report) SweepProfiles(ctx context.Context, events []Event, profiles []Profile, build func(Profile) (*Engine, error)) error {
+	for _, p := range profiles {
+		eng, err := build(p)
+		if err != nil {
+			return err
+		}
+		res, err := eng.Run(ctx, events)
+		if err != nil {
+			return fmt.Errorf("profile %s: %w", p.Name, err)
+		}
+		r.Sensitivity = append(r.Sensitivity, latencyRun(res, p.String(), p.Name))
+	}
+	return nil
+}
+
+func latencyRun(res *Result, latency, profile string) LatencyRun {
+	return LatencyRun{Latency: latency, Profile: profile, PnL: res.PnL(), Fees: res.Fees,
+		Fills: len(res.Fills), SlippageBps: avgSlippage(res.Fills), MaxDrawdown: MaxDrawdown(res.Curve)}
+}
+
+// avgSlippage is the quantity-weighted slippage of fills with an arrival.
+func avgSlippage(fills []Fill) float64 {
+	var sum, qty float64
+	for _, f := range fills {
+		if f.ArrivalMid > 0 {
+			sum += f.SlippageBps * f.Qty
+			qty += f.Qty
+		}
+	}
+	if qty == 0 {
+		return 0
+	}
+	return sum / qty
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteHTML renders a standalone page with the curve as inline SVG.
+func (r *Report) WriteHTML(w io.Writer) error {
+	return reportPage.Execute(w, struct {
+		*Report
+		Points string
+	}{r, svgPoints(r.Curve, 800, 240)})
+}
+
+// svgPoints scales the curve into a w x h polyline.
+func svgPoints(curve []CurvePoint, w, h float64) string {
+	if len(curve) < 2 {
+		return ""
+	}
+	t0, t1 := curve[0].TsNs, curve[len(curve)-1].TsNs
+	lo, hi := curve[0].PnL, curve[0].PnL
+	for _, p := range curve {
+		lo, hi = min(lo, p.PnL), max(hi, p.PnL)
+	}
+	if hi == lo {
+		hi = lo + 1
+	}
+	var b strings.Builder
+	for _, p := range curve {
+		x := 0.0
+		if t1 > t0 {
+			x = float64(p.TsNs-t0) / float64(t1-t0) * w
+		}
+		y := h - (p.PnL-lo)/(hi-lo)*h
+		fmt.Fprintf(&b, "%.1f,%.1f ", x, y)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+var reportPage = template.Must(template.New("report").Funcs(template.FuncMap{
+	"f": func(v float64) string { return fmt.Sprintf("%.4f", v) },
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Backtest {{.Start.UTC.Format "2006-01-02 15:04:05"}}</title>
+<style>body{font-family:sans-serif;margin:2em}table{border-collapse:collapse;margin:1em 0}td,th{border:1px solid #ccc;padding:4px 8px;text-align:right}th{background:#f4f4f4}</style>
+</head><body>
+<h1>Backtest</h1>
+<p>{{.Start.UTC.Format "2006-01-02 15:04:05"}} .. {{.End.UTC.Format "2006-01-02 15:04:05"}} UTC, latency {{if .Profile}}{{.Profile}} {{end}}{{.Latency}}, {{.Events}} events{{if .Base}}, amounts in {{.Base}}{{end}}</p>
+<table>
+<tr><th>PnL</th><th>Realized</th><th>Unrealized</th><th>Fees</th><th>Max drawdown</th><th>Orders</th><th>Fills</th><th>Rejected</th><th>Unfilled</th></tr>
+<tr><td>{{f .PnL}}</td><td>{{f .Realized}}</td><td>{{f .Unrealized}}</td><td>{{f .Fees}}</td><td>{{f .MaxDrawdown}}</td><td>{{.Orders}}</td><td>{{.Fills}}</td><td>{{.Rejected}}</td><td>{{.Unfilled}}</td></tr>
+</table>
+<h2>PnL</h2>
+{{if .Points}}<svg width="800" height="240" style="border:1px solid #ccc"><polyline fill="none" stroke="#1f77b4" stroke-width="1.5" points="{{.Points}}"/></svg>{{else}}<p>Not enough points.</p>{{end}}
+<h2>Fills by venue</h2>
+<table>
+<tr><th>Venue</th><th>Fills</th><th>Qty</th><th>Notional</th><th>Maker share</th><th>Slippage (bps)</th><th>Fees</th></tr>
+{{range .Venues}}<tr><td>{{.Venue}}</td><td>{{.Fills}}</td><td>{{f .Qty}}</td><td>{{f .Notional}}</td><td>{{f .MakerShare}}</td><td>{{f .SlippageBps}}</td><td>{{f .Fees}}</td></tr>
+{{end}}</table>
+{{if .Sensitivity}}<h2>Latency sensitivity</h2>
+<table>
+<tr><th>Latency</th><th>PnL</th><th>Fees</th><th>Fills</th><th>Slippage (bps)</th><th>Max drawdown</th></tr>
+{{range .Sensitivity}}<tr><td>{{if .Profile}}{{.Profile}} {{end}}{{.Latency}}</td><td>{{f .PnL}}</td><td>{{f .Fees}}</td><td>{{.Fills}}</td><td>{{f .SlippageBps}}</td><td>{{f .MaxDrawdown}}</td></tr>
+{{end}}</table>{{end}}
+</body></html>
+`))
@@ -0,0 +1,192 @@
+// Package basis tracks the premium of perpetuals over spot: each perp
+// venue's mark (its book mid when the venue publishes no mark) against the
+// NBBO mid of the spot venues quoting the same symbol, with rolling
+// statistics and alert thresholds for hedging strategies and risk.
+package basis
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/metrics"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// Alert sides.
+const (
+	AlertAbove = "above"
+	AlertBelow = "below"
+)
+
+var (
+	basisBps = metrics.Default.GaugeVec("helix_basis_bps", "Latest perp basis over spot mid.", "venue", "symbol")
+	meanBps  = metrics.Default.GaugeVec("helix_basis_mean_bps", "Mean perp basis over the rolling window.", "venue", "symbol")
+	alerts   = metrics.Default.CounterVec("helix_basis_alerts_total", "Times a perp basis crossed an alert threshold.", "venue", "symbol", "side")
+)
+
+type Config struct {
+	// Spot names the venues whose books price spot.
+	Spot []string
+	// Window is how far the rolling statistics reach back (default 5m).
+	Window time.Duration
+	// MarkAge ignores venue marks older than this in favour of the book
+	// mid (default 30s).
+	MarkAge time.Duration
+	// AlertAboveBps and AlertBelowBps bound the basis; nil leaves a side
+	// unchecked.
+	AlertAboveBps *float64
+	AlertBelowBps *float64
+}
+
+type key struct{ venue, symbol string }
+
+type sample struct {
+	at  time.Time
+	bps float64
+}
+
+// Monitor samples basis from the books and the marks the funding feeds
+// carry. It is safe for concurrent use.
+type Monitor struct {
+	cfg   Config
+	books *orderbook.Manager
+	spot  map[string]bool
+	alert func(transport.Basis)
+
+	mu      sync.Mutex
+	marks   map[key]transport.FundingRate
+	samples map[key][]sample
+	last    map[key]transport.Basis
+}
+
+func New(cfg Config, books *orderbook.Manager) *Monitor {
+	if cfg.Window <= 0 {
+		cfg.Window = 5 * time.Minute
+	}
+	if cfg.MarkAge <= 0 {
+		cfg.MarkAge = 30 * time.Second
+	}
+	spot := make(map[string]bool, len(cfg.Spot))
+	for _, v := range cfg.Spot {
+		spot[v] = true
+	}
+	return &Monitor{cfg: cfg, books: books, spot: spot, marks: make(map[key]transport.FundingRate),
+		samples: make(map[key][]sample), last: make(map[key]transport.Basis)}
+}
+
+// SetAlert receives a venue's basis when it crosses a threshold; it is not
+// called again until the basis is back inside.
+func (m *Monitor) SetAlert(fn func(transport.Basis)) { m.alert = fn }
+
+// Mark records the mark price a funding update carries.
+func (m *Monitor) Mark(fr transport.FundingRate) {
+	if fr.Mark <= 0 || m.spot[fr.Venue] {
+		return
+	}
+	m.mu.Lock()
+	m.marks[key{fr.Venue, fr.Symbol}] = fr
+	m.mu.Unlock()
+}
+
+// Sample prices every perp venue of every symbol with a spot book and
+// returns the bases sorted by symbol and venue.
+func (m *Monitor) Sample(now time.Time) []transport.Basis {
+	var out []transport.Basis
+	for _, symbol := range m.books.Symbols() {
+		spot, perp := m.split(symbol)
+		best := orderbook.MergeBest(spot)
+		if best.BestBid <= 0 || best.BestAsk <= 0 {
+			continue
+		}
+		spotMid := (best.BestBid + best.BestAsk) / 2
+		perps := map[string]float64{}
+		for venue, l := range perp {
+			if l.BestBid > 0 && l.BestAsk > 0 {
+				perps[venue] = (l.BestBid + l.BestAsk) / 2
+			}
+		}
+		m.mu.Lock()
+		for k, fr := range m.marks {
+			if k.symbol == symbol && now.Sub(time.UnixMilli(fr.TsMs)) <= m.cfg.MarkAge {
+				perps[k.venue] = fr.Mark
+			}
+		}
+		m.mu.Unlock()
+		for venue, mark := range perps {
+			out = append(out, m.record(now, key{venue, symbol}, mark, spotMid))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Symbol != out[j].Symbol {
+			return out[i].Symbol < out[j].Symbol
+		}
+		return out[i].Venue < out[j].Venue
+	})
+	return out
+}
+
+// split sorts symbol's books into spot (the spot market or a Config.Spot
+// venue) and the rest, by venue, without merging the two.
+func (m *Monitor) split(symbol string) (spot, perp map[string]orderbook.Level) {
+	spot, perp = map[string]orderbook.Level{}, map[string]orderbook.Level{}
+	for _, cat := range m.books.Categories(symbol) {
+		for venue, l := range m.books.MarketSnapshot(symbol, cat) {
+			if cat == "spot" || m.spot[venue] {
+				spot[venue] = l
+			} else {
+				perp[venue] = l
+			}
+		}
+	}
+	return spot, perp
+}
+
+func (m *Monitor) record(now time.Time, k key, mark, spotMid float64) transport.Basis {
+	b := transport.Basis{TsMs: now.UnixMilli(), Symbol: k.symbol, Venue: k.venue, Mark: mark, SpotMid: spotMid,
+		BasisBps: (mark - spotMid) / spotMid * 1e4}
+	switch {
+	case m.cfg.AlertAboveBps != nil && b.BasisBps > *m.cfg.AlertAboveBps:
+		b.Alert = AlertAbove
+	case m.cfg.AlertBelowBps != nil && b.BasisBps < *m.cfg.AlertBelowBps:
+		b.Alert = AlertBelow
+	}
+
+	m.mu.Lock()
+	window := append(m.samples[k], sample{now, b.BasisBps})
+	cut := 0
+	for cut < len(window) && now.Sub(window[cut].at) > m.cfg.Window {
+		cut++
+	}
+	window = window[cut:]
+	m.samples[k] = window
+	b.MinBps, b.MaxBps = b.BasisBps, b.BasisBps
+	var sum float64
+	for _, s := range window {
+		sum += s.bps
+		b.MinBps, b.MaxBps = min(b.MinBps, s.bps), max(b.MaxBps, s.bps)
+	}
+	b.MeanBps, b.Samples = sum/float64(len(window)), len(window)
+	prev := m.last[k]
+	m.last[k] = b
+	m.mu.Unlock()
+
+	basisBps.With(k.venue, k.symbol).Set(b.BasisBps)
+	meanBps.With(k.venue, k.symbol).Set(b.MeanBps)
+	if b.Alert != "" && b.Alert != prev.Alert {
+		alerts.With(k.venue, k.symbol, b.Alert).Inc()
+		if m.alert != nil {
+			m.alert(b)
+		}
+	}
+	return b
+}
+
+// Last is the latest basis of symbol on a perp venue.
+func (m *Monitor) Last(venue, symbol string) (transport.Basis, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.last[key{venue, symbol}]
+	return b, ok
+}
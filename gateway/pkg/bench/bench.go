@@ -0,0 +1,239 @@
+package bench
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/l2book"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/router"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+	"github.com/helix-lab/helix/gateway/pkg/ws"
+	"github.com/helix-lab/helix/gateway/pkg/ws/bybitjson"
+)
+
+// Case is one benchmark of the suite. An op is one delta, frame, row or
+// update of the fixture, so ns/op stays comparable across fixtures.
+type Case struct {
+	Name string
+	F    func(b *testing.B)
+}
+
+// Cases returns the suite over fix.
+func Cases(fix *Fixture) []Case {
+	frameBytes := func(b *testing.B) {
+		var n int
+		for _, f := range fix.Frames {
+			n += len(f)
+		}
+		b.SetBytes(int64(n / len(fix.Frames)))
+	}
+	return []Case{
+		{"book_apply", func(b *testing.B) {
+			book := l2book.New()
+			for i := 0; i < b.N; i++ {
+				j := i % len(fix.Deltas)
+				if j == 0 {
+					book = l2book.New()
+				}
+				book.Update(fix.Deltas[j])
+			}
+		}},
+		{"json_decode", func(b *testing.B) {
+			frameBytes(b)
+			var m bybitjson.Book
+			for i := 0; i < b.N; i++ {
+				if err := m.Decode(fix.Frames[i%len(fix.Frames)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}},
+		{"json_decode_std", func(b *testing.B) {
+			frameBytes(b)
+			for i := 0; i < b.N; i++ {
+				var m struct {
+					Topic string `json:"topic"`
+					Type  string `json:"type"`
+					Ts    int64  `json:"ts"`
+					Data  struct {
+						Symbol string     `json:"s"`
+						Seq    int64      `json:"seq"`
+						Bids   [][]string `json:"b"`
+						Asks   [][]string `json:"a"`
+					} `json:"data"`
+				}
+				if err := json.Unmarshal(fix.Frames[i%len(fix.Frames)], &m); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}},
+		{"normalize", func(b *testing.B) {
+			frameBytes(b)
+			s := ws.NewBybitStream("", []string{fix.Symbol}, 50)
+			depth := make(chan transport.DepthUpdate, 1)
+			feeds := ws.Feeds{Depth: depth}
+			ctx := context.Background()
+			for i := 0; i < b.N; i++ {
+				if s.HandleFrame(ctx, feeds, int64(i), fix.Frames[i%len(fix.Frames)]) {
+					select {
+					case <-depth:
+					default:
+					}
+				}
+			}
+		}},
+		{"csv_write", func(b *testing.B) {
+			w := csv.NewWriter(io.Discard)
+			for i := 0; i < b.N; i++ {
+				w.Write(fix.Rows[i%len(fix.Rows)])
+			}
+			w.Flush()
+		}},
+		{"route", func(b *testing.B) {
+			books := orderbook.NewManager()
+			r := router.NewSmartRouter(router.DefaultFees())
+			views := make(map[string]router.BookView, 2)
+			sides := [2]string{"BUY", "SELL"}
+			for i := 0; i < b.N; i++ {
+				u := fix.Tops[i%len(fix.Tops)]
+				books.Apply(u)
+				for venue, lvl := range books.SymbolSnapshot(u.Symbol) {
+					views[venue] = router.BookView{BestBid: lvl.BestBid, BestAsk: lvl.BestAsk}
+				}
+				r.Decide(transport.Action{Symbol: u.Symbol, Side: sides[i%2], Size: 0.01}, views)
+			}
+		}},
+		{"publish", func(b *testing.B) {
+			rt := ws.NewRouterWithConfig(ws.RouterConfig{Buffer: 1024, Policy: ws.PolicyBlock, MaxBacklog: 1024})
+			rt.Add(&loopConnector{tops: fix.Tops})
+			// a lossy second consumer, as the gateway's API feeds are
+			rt.Subscribe("bench", 1024, true)
+			rt.Start()
+			defer rt.Stop()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				<-rt.Updates()
+			}
+		}},
+	}
+}
+
+// loopConnector replays tops forever, as fast as the router takes them.
+type loopConnector struct {
+	tops []transport.DepthUpdate
+}
+
+func (c *loopConnector) Venue() string { return "BENCH" }
+
+func (c *loopConnector) Run(ctx context.Context, out ws.Feeds) {
+	for i := 0; ; i++ {
+		select {
+		case out.Depth <- c.tops[i%len(c.tops)]:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Result is one case's measurement. With Count > 1 it is the run with the
+// median ns/op.
+type Result struct {
+	Name        string  `json:"name"`
+	N           int     `json:"n"`
+	NsPerOp     float64 `json:"ns_per_op"`
+	AllocsPerOp int64   `json:"allocs_per_op"`
+	BytesPerOp  int64   `json:"bytes_per_op"`
+	MBPerSec    float64 `json:"mb_per_sec,omitempty"`
+}
+
+// Report is a suite run with what is needed to judge whether two runs are
+// comparable: same fixture, machine and Go version.
+type Report struct {
+	Time      string   `json:"time"`
+	GoVersion string   `json:"go_version"`
+	GOOS      string   `json:"goos"`
+	GOARCH    string   `json:"goarch"`
+	CPUs      int      `json:"cpus"`
+	Revision  string   `json:"revision,omitempty"`
+	Fixture   string   `json:"fixture"`
+	Deltas    int      `json:"deltas"`
+	Messages  int      `json:"messages"`
+	Count     int      `json:"count"`
+	Results   []Result `json:"results"`
+}
+
+// Run benchmarks the cases whose names match (nil for all), count times
+// each.
+func Run(fix *Fixture, match *regexp.Regexp, count int) Report {
+	if count < 1 {
+		count = 1
+	}
+	rep := Report{
+		Time:      time.Now().UTC().Format(time.RFC3339),
+		GoVersion: runtime.Version(),
+		GOOS:      runtime.GOOS,
+		GOARCH:    runtime.GOARCH,
+		CPUs:      runtime.GOMAXPROCS(0),
+		Fixture:   fix.Name,
+		Deltas:    len(fix.Deltas),
+		Messages:  len(fix.Frames),
+		Count:     count,
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				rep.Revision = s.Value
+			}
+		}
+	}
+	for _, c := range Cases(fix) {
+		if match != nil && !match.MatchString(c.Name) {
+			continue
+		}
+		runs := make([]Result, count)
+		for i := range runs {
+			r := testing.Benchmark(c.F)
+			runs[i] = Result{Name: c.Name, N: r.N, NsPerOp: float64(r.T.Nanoseconds()) / float64(max(r.N, 1)),
+				AllocsPerOp: r.AllocsPerOp(), BytesPerOp: r.AllocedBytesPerOp()}
+			if r.Bytes > 0 && r.T > 0 {
+				runs[i].MBPerSec = float64(r.Bytes) * float64(r.N) / 1e6 / r.T.Seconds()
+			}
+		}
+		sort.Slice(runs, func(i, j int) bool { return runs[i].NsPerOp < runs[j].NsPerOp })
+		rep.Results = append(rep.Results, runs[len(runs)/2])
+	}
+	return rep
+}
+
+// Change is a case's result against a baseline.
+type Change struct {
+	Name          string
+	Base, Current Result
+	// Delta is the relative change in ns/op; positive is slower.
+	Delta float64
+}
+
+// Compare pairs the cases present in both reports.
+func Compare(base, cur Report) []Change {
+	byName := make(map[string]Result, len(base.Results))
+	for _, r := range base.Results {
+		byName[r.Name] = r
+	}
+	var out []Change
+	for _, r := range cur.Results {
+		b, ok := byName[r.Name]
+		if !ok || b.NsPerOp <= 0 {
+			continue
+		}
+		out = append(out, Change{Name: r.Name, Base: b, Current: r, Delta: r.NsPerOp/b.NsPerOp - 1})
+	}
+	return out
+}
@@ -0,0 +1,166 @@
+// Package bench is the performance regression suite: benchmarks of the
+// gateway's hot paths (book apply, JSON decode, CSV write, route,
+// publish) driven by a recorded L2 capture. "helix bench" runs it and
+// writes JSON results that compare across commits; go test -bench runs the
+// same cases.
+package bench
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/helix-lab/helix/gateway/pkg/l2book"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// Fixture is an L2 capture loaded into the forms the cases consume.
+type Fixture struct {
+	Name   string
+	Symbol string
+	// Rows are the capture's CSV records, header excluded.
+	Rows   [][]string
+	Deltas []l2book.Delta
+	// Frames are the capture's messages re-encoded as Bybit orderbook
+	// frames, one per seq.
+	Frames [][]byte
+	// Tops is the book's top after every message, alternately attributed
+	// to two venues so routing has a choice.
+	Tops []transport.DepthUpdate
+}
+
+// LoadFixture reads the L2 CSV capture at path.
+func LoadFixture(path, symbol string) (*Fixture, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewFixture(filepath.Base(path), symbol, b)
+}
+
+// NewFixture builds a fixture from an L2 CSV capture.
+func NewFixture(name, symbol string, capture []byte) (*Fixture, error) {
+	fix := &Fixture{Name: name, Symbol: symbol}
+	cr := csv.NewReader(bytes.NewReader(capture))
+	cr.FieldsPerRecord = -1
+	var parser l2book.Parser
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if errors.Is(err, csv.ErrFieldCount) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		d, ok := parser.Parse(rec)
+		if !ok {
+			continue
+		}
+		fix.Rows = append(fix.Rows, rec)
+		fix.Deltas = append(fix.Deltas, d)
+	}
+	if len(fix.Deltas) == 0 {
+		return nil, fmt.Errorf("%s: no L2 deltas", name)
+	}
+	fix.Frames = frames(symbol, fix.Deltas)
+
+	tops, err := l2book.Tops(bytes.NewReader(capture))
+	if err != nil {
+		return nil, err
+	}
+	venues := [2]string{"BYBIT", "BINANCE"}
+	for i, t := range tops {
+		fix.Tops = append(fix.Tops, transport.DepthUpdate{Venue: venues[i%2], Symbol: symbol,
+			BestBid: t.BestBid, BestAsk: t.BestAsk, BidSize: t.BidSize, AskSize: t.AskSize, ExchTsMs: t.TsMs})
+	}
+	if len(fix.Tops) == 0 {
+		return nil, fmt.Errorf("%s: book never has a valid top", name)
+	}
+	return fix, nil
+}
+
+// frames groups deltas into messages and encodes each as Bybit sends it.
+func frames(symbol string, deltas []l2book.Delta) [][]byte {
+	var out [][]byte
+	var bids, asks []byte
+	flush := func(d l2book.Delta) {
+		typ := "delta"
+		if d.Snapshot {
+			typ = "snapshot"
+		}
+		f := fmt.Sprintf(`{"topic":"orderbook.50.%s","type":%q,"ts":%d,"data":{"s":%q,"b":[%s],"a":[%s],"u":%d,"seq":%d,"pu":%d},"cts":%d}`,
+			symbol, typ, d.TsMs, symbol, bids, asks, d.Seq, d.Seq, d.PrevSeq, d.TsMs)
+		out = append(out, []byte(f))
+		bids, asks = bids[:0], asks[:0]
+	}
+	for i, d := range deltas {
+		if i > 0 && d.Seq != deltas[i-1].Seq {
+			flush(deltas[i-1])
+		}
+		side := &asks
+		if d.Side == 'b' {
+			side = &bids
+		}
+		if len(*side) > 0 {
+			*side = append(*side, ',')
+		}
+		*side = append(*side, `["`...)
+		*side = strconv.AppendFloat(*side, d.Price, 'f', -1, 64)
+		*side = append(*side, `","`...)
+		*side = strconv.AppendFloat(*side, d.Qty, 'f', -1, 64)
+		*side = append(*side, `"]`...)
+	}
+	flush(deltas[len(deltas)-1])
+	return out
+}
+
+// Synthetic generates a seeded capture of messages messages around a
+// random-walk mid: a levels-deep snapshot, then deltas of a few levels
+// each.
+func Synthetic(messages, levels int) *Fixture {
+	rng := rand.New(rand.NewSource(1))
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"ts_ms", "seq", "prev_seq", "book_side", "price", "size", "type"})
+	mid := 65000.0
+	ts, seq := int64(1700000000000), int64(1000)
+	row := func(side string, px, sz float64, typ string) {
+		w.Write([]string{strconv.FormatInt(ts, 10), strconv.FormatInt(seq, 10), strconv.FormatInt(seq-1, 10),
+			side, strconv.FormatFloat(px, 'f', 1, 64), strconv.FormatFloat(sz, 'f', 3, 64), typ})
+	}
+	for i := 1; i <= levels; i++ {
+		row("bid", mid-float64(i)/2, 0.1+rng.Float64(), "snapshot")
+		row("ask", mid+float64(i)/2, 0.1+rng.Float64(), "snapshot")
+	}
+	for m := 1; m < messages; m++ {
+		ts += int64(rng.Intn(20))
+		seq++
+		for k := 0; k < 1+rng.Intn(4); k++ {
+			off := float64(1+rng.Intn(levels)) / 2
+			sz := 0.1 + rng.Float64()
+			if rng.Intn(5) == 0 && off > 0.5 {
+				sz = 0
+			}
+			if rng.Intn(2) == 0 {
+				row("bid", mid-off, sz, "delta")
+			} else {
+				row("ask", mid+off, sz, "delta")
+			}
+		}
+	}
+	w.Flush()
+	fix, err := NewFixture("synthetic", "BTCUSDT", buf.Bytes())
+	if err != nil {
+		panic(err)
+	}
+	return fix
+}
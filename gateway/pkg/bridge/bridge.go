@@ -0,0 +1,303 @@
+// Package bridge lets one gateway feed another. The upstream gateway
+// serves its books at Path; a downstream gateway subscribes with a
+// Connector and sees each bridged venue as one of its own, e.g. a gateway
+// colocated with an exchange feeding a central router.
+//
+// Frames are JSON over one WebSocket, numbered per connection. A client
+// that falls behind loses frames, which it sees as a gap in the numbers,
+// and the server then resends every subscribed book followed by a synced
+// frame. Books are top of book, so the resent books repair the gap. The
+// server also relays the health of the bridged venues every HealthEvery.
+package bridge
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"nhooyr.io/websocket"
+
+	"github.com/helix-lab/helix/gateway/pkg/metrics"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+	"github.com/helix-lab/helix/gateway/pkg/ws"
+)
+
+// Path is where Server serves the feed.
+const Path = "/bridge/v1/feed"
+
+// Frame types.
+const (
+	TypeDepth  = "depth"
+	TypeHealth = "health"
+	// TypeSynced follows the books sent on subscribing and after a gap.
+	TypeSynced = "synced"
+)
+
+// sendBuffer is how many frames may wait for a slow client before the
+// rest are dropped until a resync.
+const sendBuffer = 8192
+
+var (
+	clients = metrics.Default.Gauge("helix_bridge_clients", "Downstream gateways connected to the bridge feed.")
+	resyncs = metrics.Default.Counter("helix_bridge_resyncs_total", "Books resent to bridge clients that fell behind.")
+)
+
+// Frame is one message from server to client; Type says which fields are
+// set. Subscribe acks use the op, req_id and success fields instead.
+type Frame struct {
+	Op      string `json:"op,omitempty"`
+	ReqID   string `json:"req_id,omitempty"`
+	Success *bool  `json:"success,omitempty"`
+	Error   string `json:"error,omitempty"`
+
+	Type   string        `json:"type,omitempty"`
+	Seq    int64         `json:"seq,omitempty"`
+	Depth  *Depth        `json:"depth,omitempty"`
+	Health []VenueHealth `json:"health,omitempty"`
+}
+
+type Depth struct {
+	Venue    string  `json:"venue"`
+	Symbol   string  `json:"symbol"`
+	Category string  `json:"category,omitempty"`
+	BestBid  float64 `json:"bid"`
+	BestAsk  float64 `json:"ask"`
+	BidSize  float64 `json:"bid_size"`
+	AskSize  float64 `json:"ask_size"`
+	ExchTsMs int64   `json:"exch_ts_ms,omitempty"`
+}
+
+// VenueHealth is a bridged venue's status upstream: ok, degraded, down or
+// maintenance.
+type VenueHealth struct {
+	Venue  string `json:"venue"`
+	Status string `json:"status"`
+}
+
+// Topic names one venue's symbol in a subscription.
+func Topic(venue, symbol string) string { return venue + "." + symbol }
+
+// Server fans the gateway's depth out to its bridge clients. Depth may
+// be called from any goroutine.
+type Server struct {
+	Token string
+	// Books seeds new clients and resyncs those that fell behind.
+	Books *orderbook.Manager
+	// Health, if set, is relayed every HealthEvery (default 1s); the
+	// frames also keep quiet feeds from looking stale downstream.
+	Health      func() []ws.ConnectorHealth
+	HealthEvery time.Duration
+
+	mu       sync.Mutex
+	sessions map[*session]bool
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(Path, s.feed)
+	return mux
+}
+
+// Depth sends u to the clients subscribed to its venue and symbol.
+func (s *Server) Depth(u transport.DepthUpdate) {
+	topic := Topic(u.Venue, u.Symbol)
+	d := Depth{Venue: u.Venue, Symbol: u.Symbol, Category: u.Category, BestBid: u.BestBid, BestAsk: u.BestAsk,
+		BidSize: u.BidSize, AskSize: u.AskSize, ExchTsMs: u.ExchTsMs}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sess := range s.sessions {
+		if sess.topics[topic] {
+			sess.send(Frame{Type: TypeDepth, Depth: &d})
+		}
+	}
+}
+
+func (s *Server) feed(w http.ResponseWriter, r *http.Request) {
+	got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if s.Token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(s.Token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="helix-bridge"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var sub struct {
+		Op    string   `json:"op"`
+		ReqID string   `json:"req_id"`
+		Args  []string `json:"args"`
+	}
+	readCtx, readCancel := context.WithTimeout(ctx, 10*time.Second)
+	_, data, err := conn.Read(readCtx)
+	readCancel()
+	if err != nil {
+		return
+	}
+	ok := json.Unmarshal(data, &sub) == nil && sub.Op == "subscribe" && len(sub.Args) > 0
+	ack := Frame{Op: "subscribe", ReqID: sub.ReqID, Success: &ok}
+	if !ok {
+		ack.Error = "expected a subscribe with args"
+	}
+	if b, err := json.Marshal(ack); err == nil {
+		_ = conn.Write(ctx, websocket.MessageText, b)
+	}
+	if !ok {
+		_ = conn.Close(websocket.StatusPolicyViolation, "bad subscribe")
+		return
+	}
+
+	// a new client starts with a resync: every book it asked for, then synced
+	sess := &session{topics: map[string]bool{}, venues: map[string]bool{}, out: make(chan queued, sendBuffer), resync: true}
+	for _, t := range sub.Args {
+		sess.topics[t] = true
+		sess.venues[strings.SplitN(t, ".", 2)[0]] = true
+	}
+	s.mu.Lock()
+	if s.sessions == nil {
+		s.sessions = map[*session]bool{}
+	}
+	s.sessions[sess] = true
+	s.mu.Unlock()
+	clients.Add(1)
+	defer func() {
+		s.mu.Lock()
+		delete(s.sessions, sess)
+		s.mu.Unlock()
+		clients.Add(-1)
+	}()
+
+	ctx = conn.CloseRead(ctx)
+	if s.Health != nil {
+		go s.relayHealth(ctx, sess)
+	}
+	sess.write(ctx, conn, s.Books)
+}
+
+func (s *Server) relayHealth(ctx context.Context, sess *session) {
+	every := s.HealthEvery
+	if every <= 0 {
+		every = time.Second
+	}
+	tick := time.NewTicker(every)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			var hs []VenueHealth
+			for _, h := range s.Health() {
+				if sess.venues[h.Venue] {
+					hs = append(hs, VenueHealth{Venue: h.Venue, Status: h.Status})
+				}
+			}
+			sess.send(Frame{Type: TypeHealth, Health: hs})
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+type queued struct {
+	seq int64
+	b   []byte
+}
+
+// session is one client. Frames are numbered as they are queued, dropped
+// ones included, so the client sees what it missed.
+type session struct {
+	topics map[string]bool
+	venues map[string]bool
+	out    chan queued
+
+	mu     sync.Mutex
+	seq    int64
+	resync bool
+}
+
+func (s *session) send(f Frame) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	f.Seq = s.seq
+	b, err := json.Marshal(f)
+	if err != nil {
+		return
+	}
+	select {
+	case s.out <- queued{seq: f.Seq, b: b}:
+	default:
+		s.resync = true
+	}
+}
+
+// snapshot numbers the subscribed books and a synced frame after
+// everything queued so far, which they supersede.
+func (s *session) snapshot(books *orderbook.Manager) []queued {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resync = false
+	var out []queued
+	add := func(f Frame) {
+		s.seq++
+		f.Seq = s.seq
+		if b, err := json.Marshal(f); err == nil {
+			out = append(out, queued{seq: f.Seq, b: b})
+		}
+	}
+	if books != nil {
+		for _, bk := range books.Books() {
+			if s.topics[Topic(bk.Venue, bk.Symbol)] {
+				add(Frame{Type: TypeDepth, Depth: &Depth{Venue: bk.Venue, Symbol: bk.Symbol, Category: bk.Category,
+					BestBid: bk.BestBid, BestAsk: bk.BestAsk, BidSize: bk.BidSize, AskSize: bk.AskSize}})
+			}
+		}
+	}
+	add(Frame{Type: TypeSynced})
+	return out
+}
+
+func (s *session) write(ctx context.Context, conn *websocket.Conn, books *orderbook.Manager) {
+	var written int64
+	put := func(q queued) bool {
+		wctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		if err := conn.Write(wctx, websocket.MessageText, q.b); err != nil {
+			_ = conn.Close(websocket.StatusInternalError, "write failed")
+			return false
+		}
+		written = q.seq
+		return true
+	}
+	for {
+		s.mu.Lock()
+		resync := s.resync
+		s.mu.Unlock()
+		if resync {
+			if written > 0 {
+				resyncs.Inc()
+			}
+			for _, q := range s.snapshot(books) {
+				if !put(q) {
+					return
+				}
+			}
+		}
+		select {
+		case q := <-s.out:
+			if q.seq > written && !put(q) {
+				return
+			}
+		case <-ctx.Done():
+			_ = conn.Close(websocket.StatusNormalClosure, "")
+			return
+		}
+	}
+}
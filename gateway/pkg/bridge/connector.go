@@ -0,0 +1,171 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+	"github.com/helix-lab/helix/gateway/pkg/ws"
+	"github.com/helix-lab/helix/gateway/pkg/wsclient"
+)
+
+// Connector streams one venue of an upstream gateway's bridge feed as a
+// local venue.
+type Connector struct {
+	// Name is the venue the books are published as; Upstream is the
+	// venue's name on the upstream gateway, Name when empty.
+	Name     string
+	Upstream string
+	Endpoint string
+	Token    string
+	Symbols  []string
+	// StaleAfter reconnects when not even a health frame arrived for this
+	// long (default 10s).
+	StaleAfter time.Duration
+	Logf       func(format string, args ...any)
+
+	mu       sync.Mutex
+	client   *wsclient.Client
+	seq      int64
+	gaps     ws.GapStats
+	pending  *ws.Recovery
+	resent   int
+	upstream string
+}
+
+func NewConnector(name, endpoint, token string, symbols []string) *Connector {
+	return &Connector{Name: name, Endpoint: endpoint, Token: token, Symbols: symbols}
+}
+
+func (c *Connector) Venue() string { return c.Name }
+
+func (c *Connector) upstreamVenue() string {
+	if c.Upstream != "" {
+		return c.Upstream
+	}
+	return c.Name
+}
+
+func (c *Connector) Run(ctx context.Context, out ws.Feeds) {
+	topics := make([]string, 0, len(c.Symbols))
+	for _, sym := range c.Symbols {
+		topics = append(topics, Topic(c.upstreamVenue(), sym))
+	}
+	stale := c.StaleAfter
+	if stale <= 0 {
+		stale = 10 * time.Second
+	}
+	client := wsclient.New(wsclient.Config{
+		Endpoint:   c.Endpoint,
+		Header:     http.Header{"Authorization": {"Bearer " + c.Token}},
+		Topics:     topics,
+		StaleAfter: stale,
+		Logf:       c.Logf,
+		OnMessage:  func(frame []byte) bool { return c.handle(ctx, out, frame) },
+		OnConnect: func(int) {
+			// every connection numbers its frames from 1 and starts synced
+			c.mu.Lock()
+			c.seq = 0
+			c.mu.Unlock()
+		},
+	})
+	c.mu.Lock()
+	c.client = client
+	c.mu.Unlock()
+	_ = client.Run(ctx)
+}
+
+func (c *Connector) handle(ctx context.Context, out ws.Feeds, frame []byte) bool {
+	var f Frame
+	if err := json.Unmarshal(frame, &f); err != nil || f.Seq == 0 {
+		return false
+	}
+	c.mu.Lock()
+	if f.Seq != c.seq+1 {
+		c.gaps.Gaps++
+		if c.pending == nil {
+			c.pending = &ws.Recovery{Venue: c.Name, From: c.seq, To: f.Seq, Attempts: 1, At: time.Now()}
+			c.resent = 0
+		}
+		if c.Logf != nil {
+			c.Logf("bridge %s: frames %d..%d lost, waiting for the resync", c.Name, c.seq+1, f.Seq-1)
+		}
+	}
+	c.seq = f.Seq
+	switch f.Type {
+	case TypeSynced:
+		if c.pending != nil {
+			c.gaps.Recovered++
+			c.pending.Replayed, c.pending.Took = c.resent, time.Since(c.pending.At)
+			c.gaps.Last, c.pending = c.pending, nil
+		}
+	case TypeHealth:
+		for _, h := range f.Health {
+			if h.Venue == c.upstreamVenue() {
+				c.upstream = h.Status
+			}
+		}
+	case TypeDepth:
+		c.resent++
+	}
+	c.mu.Unlock()
+
+	if f.Type == TypeDepth && f.Depth != nil {
+		d := f.Depth
+		send(ctx, out.Depth, transport.DepthUpdate{Venue: c.Name, Symbol: d.Symbol, Category: d.Category,
+			BestBid: d.BestBid, BestAsk: d.BestAsk, BidSize: d.BidSize, AskSize: d.AskSize,
+			ExchTsMs: d.ExchTsMs, RecvNs: time.Now().UnixNano()})
+	}
+	return true
+}
+
+func send(ctx context.Context, ch chan<- transport.DepthUpdate, u transport.DepthUpdate) {
+	select {
+	case ch <- u:
+	case <-ctx.Done():
+	}
+}
+
+// Health is the bridge connection's, marked down when the venue is down
+// upstream and with its topics failed when it is otherwise unwell there.
+func (c *Connector) Health() []wsclient.Health {
+	c.mu.Lock()
+	client, upstream := c.client, c.upstream
+	c.mu.Unlock()
+	if client == nil {
+		return nil
+	}
+	h := client.Health()
+	switch upstream {
+	case "", "ok":
+	case "down":
+		h.Connected = false
+		h.LastError = "upstream " + c.upstreamVenue() + " down"
+	default:
+		for t := range h.Topics {
+			h.Topics[t] = wsclient.TopicFailed
+		}
+		h.LastError = "upstream " + c.upstreamVenue() + " " + upstream
+	}
+	return []wsclient.Health{h}
+}
+
+// Gaps counts the lost frames and the resyncs that repaired them.
+func (c *Connector) Gaps() ws.GapStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	g := c.gaps
+	if g.Last != nil {
+		last := *g.Last
+		g.Last = &last
+	}
+	return g
+}
+
+var (
+	_ ws.GapReporter    = (*Connector)(nil)
+	_ ws.HealthReporter = (*Connector)(nil)
+)
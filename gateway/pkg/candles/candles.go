@@ -0,0 +1,176 @@
+// Package candles builds OHLCV bars with VWAP from a trade stream, per
+// venue and symbol, at any number of intervals.
+package candles
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// ParseIntervals reads a comma-separated interval list such as "1s,1m,5m".
+// Intervals must be whole milliseconds.
+func ParseIntervals(s string) ([]time.Duration, error) {
+	var out []time.Duration
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		d, err := time.ParseDuration(f)
+		if err != nil {
+			return nil, err
+		}
+		if d < time.Millisecond || d%time.Millisecond != 0 {
+			return nil, fmt.Errorf("interval %s is not a whole number of milliseconds", d)
+		}
+		out = append(out, d)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no intervals in %q", s)
+	}
+	return out, nil
+}
+
+type key struct {
+	venue    string
+	symbol   string
+	interval time.Duration
+}
+
+type open struct {
+	bar      transport.Bar
+	notional float64
+	// done is set once the bar was returned; it stays to reject late trades
+	done bool
+}
+
+// Aggregator keeps the latest bar for every venue, symbol and interval.
+// Bars are aligned to the epoch and timed by the trades' own timestamps;
+// an interval with no trades produces no bar, and trades older than the
+// latest bar (or for one already completed) are ignored. It is not safe
+// for concurrent use.
+type Aggregator struct {
+	intervals []time.Duration
+	open      map[key]*open
+}
+
+func New(intervals ...time.Duration) *Aggregator {
+	return &Aggregator{intervals: intervals, open: make(map[key]*open)}
+}
+
+// Trade adds t and returns the bars it completed, i.e. those of its venue
+// and symbol whose interval ended at or before t.
+func (a *Aggregator) Trade(t transport.Trade) []transport.Bar {
+	var done []transport.Bar
+	for _, iv := range a.intervals {
+		k := key{venue: t.Venue, symbol: t.Symbol, interval: iv}
+		ms := iv.Milliseconds()
+		start := t.TsMs - t.TsMs%ms
+		o := a.open[k]
+		if o != nil && (start < o.bar.StartMs || start == o.bar.StartMs && o.done) {
+			continue
+		}
+		if o != nil && start > o.bar.StartMs {
+			if !o.done {
+				done = append(done, o.close())
+			}
+			o = nil
+		}
+		if o == nil {
+			o = &open{bar: transport.Bar{Venue: t.Venue, Symbol: t.Symbol, Interval: iv, StartMs: start,
+				Open: t.Price, High: t.Price, Low: t.Price}}
+			a.open[k] = o
+		}
+		b := &o.bar
+		b.High, b.Low, b.Close = max(b.High, t.Price), min(b.Low, t.Price), t.Price
+		b.Volume += t.Qty
+		b.Trades++
+		o.notional += t.Price * t.Qty
+	}
+	return done
+}
+
+// Advance completes the bars that ended at or before now, for use on a
+// live clock when trades pause; trades arriving later for those bars are
+// ignored, so callers should allow for feed delay. Replays should not call
+// it.
+func (a *Aggregator) Advance(now time.Time) []transport.Bar {
+	ms := now.UnixMilli()
+	var done []transport.Bar
+	for k, o := range a.open {
+		if !o.done && o.bar.StartMs+k.interval.Milliseconds() <= ms {
+			done = append(done, o.close())
+		}
+	}
+	sortBars(done)
+	return done
+}
+
+// Flush completes every bar in progress, e.g. at shutdown.
+func (a *Aggregator) Flush() []transport.Bar {
+	var done []transport.Bar
+	for _, o := range a.open {
+		if !o.done {
+			done = append(done, o.close())
+		}
+	}
+	sortBars(done)
+	return done
+}
+
+func (o *open) close() transport.Bar {
+	o.done = true
+	b := o.bar
+	if b.Volume > 0 {
+		b.VWAP = o.notional / b.Volume
+	}
+	return b
+}
+
+func sortBars(bars []transport.Bar) {
+	sort.Slice(bars, func(i, j int) bool {
+		a, b := bars[i], bars[j]
+		if a.StartMs != b.StartMs {
+			return a.StartMs < b.StartMs
+		}
+		if a.Interval != b.Interval {
+			return a.Interval < b.Interval
+		}
+		if a.Symbol != b.Symbol {
+			return a.Symbol < b.Symbol
+		}
+		return a.Venue < b.Venue
+	})
+}
+
+// CSVWriter writes bars as CSV with a header.
+type CSVWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func NewCSVWriter(w io.Writer) *CSVWriter { return &CSVWriter{w: csv.NewWriter(w)} }
+
+// Write appends b and flushes it through to the underlying writer.
+func (c *CSVWriter) Write(b transport.Bar) error {
+	if !c.header {
+		c.header = true
+		if err := c.w.Write([]string{"start_ms", "interval", "venue", "symbol", "open", "high", "low", "close", "volume", "vwap", "trades"}); err != nil {
+			return err
+		}
+	}
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	if err := c.w.Write([]string{strconv.FormatInt(b.StartMs, 10), b.Interval.String(), b.Venue, b.Symbol,
+		f(b.Open), f(b.High), f(b.Low), f(b.Close), f(b.Volume), f(b.VWAP), strconv.Itoa(b.Trades)}); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
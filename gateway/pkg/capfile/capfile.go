@@ -0,0 +1,167 @@
+// Package capfile rewrites recorded CSV captures: merging the files of
+// redundant recorders, slicing out windows and converting to Parquet.
+// Merging and slicing read captures whole, so they suit the hour-to-day
+// files the recorders rotate, not unbounded streams.
+package capfile
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/helix-lab/helix/gateway/pkg/catalog"
+)
+
+// Table is a CSV capture in memory.
+type Table struct {
+	Header []string
+	Rows   [][]string
+	cols   map[string]int
+}
+
+// Read loads the capture at path.
+func Read(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	t, err := ReadFrom(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+func ReadFrom(r io.Reader) (*Table, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	t := NewTable(header)
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return t, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		t.Rows = append(t.Rows, rec)
+	}
+}
+
+func NewTable(header []string) *Table {
+	t := &Table{Header: header, cols: make(map[string]int, len(header))}
+	for i, h := range header {
+		t.cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	return t
+}
+
+// Col is the index of the named column, or -1.
+func (t *Table) Col(name string) int {
+	if i, ok := t.cols[name]; ok {
+		return i
+	}
+	return -1
+}
+
+// Get is row's value in the named column, "" when absent.
+func (t *Table) Get(row []string, name string) string {
+	i := t.Col(name)
+	if i < 0 || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+// Int parses row's value in the named column.
+func (t *Table) Int(row []string, name string) (int64, error) {
+	return strconv.ParseInt(t.Get(row, name), 10, 64)
+}
+
+// Write writes the header and rows as CSV.
+func (t *Table) Write(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(t.Header); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(t.Rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// WriteFile writes the table to path.
+func (t *Table) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := t.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Kind is the catalog kind the header describes, or "".
+func (t *Table) Kind() string {
+	switch {
+	case t.Col("ts_ms") < 0:
+		return ""
+	case t.Col("seq") >= 0 && t.Col("book_side") >= 0:
+		return catalog.KindL2
+	case t.Col("side") >= 0 && t.Col("price") >= 0 && t.Col("size") >= 0:
+		return catalog.KindTrades
+	}
+	return ""
+}
+
+// Project returns row rearranged into header's columns by name; columns
+// the table lacks are empty.
+func (t *Table) Project(row []string, header []string) []string {
+	out := make([]string, len(header))
+	for i, h := range header {
+		if j := t.Col(strings.ToLower(strings.TrimSpace(h))); j >= 0 && j < len(row) {
+			out[i] = row[j]
+		}
+	}
+	return out
+}
+
+// WriteMeta writes dst's sidecar from src's, with extra fields recording
+// how dst was derived, and seals dst's checksum into it. A source without
+// a sidecar gives one with only the extra fields.
+func WriteMeta(src, dst string, extra map[string]any) error {
+	meta := map[string]any{}
+	if b, err := os.ReadFile(catalog.MetaPath(src)); err == nil {
+		if err := json.Unmarshal(b, &meta); err != nil {
+			return fmt.Errorf("%s: %w", catalog.MetaPath(src), err)
+		}
+	}
+	for k, v := range extra {
+		meta[k] = v
+	}
+	// recorders name the file output_csv
+	delete(meta, "output_csv")
+	delete(meta, "output_meta")
+	delete(meta, "sha256")
+	meta["output_"+strings.TrimPrefix(strings.ToLower(filepath.Ext(dst)), ".")] = dst
+	b, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(catalog.MetaPath(dst), append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+	return catalog.Seal(dst)
+}
@@ -0,0 +1,105 @@
+package capfile
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/helix-lab/helix/gateway/pkg/catalog"
+	"github.com/helix-lab/helix/gateway/pkg/parquet"
+)
+
+// MetaKey is the Parquet footer key holding the source's .meta.json.
+const MetaKey = "helix.meta"
+
+// columnTypes are the typed columns of the recorders' and bookcheck's
+// CSVs; any other column is a string.
+var columnTypes = map[string]parquet.Type{
+	"ts_ms":      parquet.Int64,
+	"recv_ts_ms": parquet.Int64,
+	"seq":        parquet.Int64,
+	"prev_seq":   parquet.Int64,
+	"price":      parquet.Double,
+	"size":       parquet.Double,
+	"best_bid":   parquet.Double,
+	"best_ask":   parquet.Double,
+	"bid_size":   parquet.Double,
+	"ask_size":   parquet.Double,
+}
+
+// Schema types a CSV header's columns.
+func Schema(header []string) []parquet.Column {
+	cols := make([]parquet.Column, len(header))
+	for i, h := range header {
+		name := strings.ToLower(strings.TrimSpace(h))
+		t, ok := columnTypes[name]
+		if !ok {
+			t = parquet.String
+		}
+		cols[i] = parquet.Column{Name: name, Type: t}
+	}
+	return cols
+}
+
+// ToParquet converts the CSV capture at src to dst, streaming, and copies
+// its sidecar into the footer and next to dst. When both share a sidecar
+// path (foo.csv next to foo.parquet) only dst's checksum is added to it.
+// rowGroup sets the rows per row group (0 = the writer's default).
+func ToParquet(src, dst string, codec parquet.Codec, rowGroup int) (rows int, err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	r := csv.NewReader(in)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return 0, fmt.Errorf("%s: header: %w", src, err)
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(dst)
+		}
+	}()
+	w, err := parquet.NewWriter(out, Schema(header), codec)
+	if err != nil {
+		return 0, err
+	}
+	if rowGroup > 0 {
+		w.RowGroupRows = rowGroup
+	}
+	if b, err := os.ReadFile(catalog.MetaPath(src)); err == nil {
+		w.SetMetadata(MetaKey, string(b))
+	}
+	for line := 2; ; line++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return rows, fmt.Errorf("%s: %w", src, err)
+		}
+		if err := w.Append(rec); err != nil {
+			return rows, fmt.Errorf("%s: line %d: %w", src, line, err)
+		}
+		rows++
+	}
+	if err := w.Close(); err != nil {
+		return rows, err
+	}
+	if catalog.MetaPath(src) == catalog.MetaPath(dst) {
+		// foo.csv and foo.parquet share foo.meta.json
+		return rows, catalog.Seal(dst)
+	}
+	return rows, WriteMeta(src, dst, map[string]any{"converted_from": src, "format": "parquet"})
+}
@@ -0,0 +1,246 @@
+package capfile
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/helix-lab/helix/gateway/pkg/catalog"
+)
+
+// SourceStats describes one input of a merge.
+type SourceStats struct {
+	Path string
+	Rows int
+	// Kept counts the rows the merged capture took from this source.
+	Kept int
+	// LatencyMs is the median recv_ts_ms - ts_ms, -1 when the capture has
+	// no receive times.
+	LatencyMs float64
+	// Filled counts the L2 messages within the source's seq range that it
+	// lacked and another source supplied.
+	Filled int
+}
+
+// MergeStats describes a merge. Sources are in preference order.
+type MergeStats struct {
+	Kind       string
+	Sources    []SourceStats
+	Rows       int
+	Duplicates int
+	// Gaps counts the seq breaks no source could fill.
+	Gaps int
+}
+
+// Merge combines overlapping captures of one kind and symbol into one, in
+// the first preferred source's columns. Sources with receive times are
+// preferred by lower median latency, the rest in the order given. Trades
+// are deduplicated by trade_id or exec_id; L2 messages by seq, taking each
+// message from the preferred source that continues the seq chain so one
+// recorder's gaps are filled from another's. A message whose recorder
+// missed the seqs another source filled gets its prev_seq rewritten to the
+// merged chain.
+func Merge(paths []string) (*Table, MergeStats, error) {
+	var st MergeStats
+	if len(paths) < 2 {
+		return nil, st, errors.New("need at least two captures")
+	}
+	type source struct {
+		t     *Table
+		stats SourceStats
+	}
+	var srcs []source
+	symbol := ""
+	for _, p := range paths {
+		ds, ok, err := catalog.Inspect(p)
+		if err != nil {
+			return nil, st, err
+		}
+		if !ok {
+			return nil, st, fmt.Errorf("%s: not an L2 or trades capture", p)
+		}
+		if st.Kind == "" {
+			st.Kind = ds.Kind
+		} else if ds.Kind != st.Kind {
+			return nil, st, fmt.Errorf("%s: %s capture, others are %s", p, ds.Kind, st.Kind)
+		}
+		if symbol != "" && ds.Symbol != "" && ds.Symbol != symbol {
+			return nil, st, fmt.Errorf("%s: symbol %s, others are %s", p, ds.Symbol, symbol)
+		}
+		if ds.Symbol != "" {
+			symbol = ds.Symbol
+		}
+		t, err := Read(p)
+		if err != nil {
+			return nil, st, err
+		}
+		srcs = append(srcs, source{t, SourceStats{Path: p, Rows: len(t.Rows), LatencyMs: medianLatency(t)}})
+	}
+	sort.SliceStable(srcs, func(i, j int) bool {
+		return rank(srcs[i].stats.LatencyMs) < rank(srcs[j].stats.LatencyMs)
+	})
+
+	out := NewTable(srcs[0].t.Header)
+	keep := func(i int, rows ...[]string) {
+		for _, row := range rows {
+			out.Rows = append(out.Rows, srcs[i].t.Project(row, out.Header))
+		}
+		srcs[i].stats.Kept += len(rows)
+	}
+	switch st.Kind {
+	case catalog.KindTrades:
+		seen := map[string]bool{}
+		for i, s := range srcs {
+			id := "trade_id"
+			if s.t.Col(id) < 0 {
+				id = "exec_id"
+			}
+			if s.t.Col(id) < 0 {
+				return nil, st, fmt.Errorf("%s: no trade_id or exec_id column", s.stats.Path)
+			}
+			for _, row := range s.t.Rows {
+				key := s.t.Get(row, id)
+				if seen[key] {
+					st.Duplicates++
+					continue
+				}
+				seen[key] = true
+				keep(i, row)
+			}
+		}
+		ts := out.Col("ts_ms")
+		sort.SliceStable(out.Rows, func(a, b int) bool { return num(out.Rows[a], ts) < num(out.Rows[b], ts) })
+
+	case catalog.KindL2:
+		bySeq := map[int64][]l2Group{}
+		have := make([]map[int64]bool, len(srcs))
+		for i, s := range srcs {
+			groups, err := l2Groups(s.t)
+			if err != nil {
+				return nil, st, fmt.Errorf("%s: %w", s.stats.Path, err)
+			}
+			have[i] = make(map[int64]bool, len(groups))
+			for _, g := range groups {
+				g.src = i
+				bySeq[g.seq] = append(bySeq[g.seq], g)
+				have[i][g.seq] = true
+			}
+		}
+		seqs := make([]int64, 0, len(bySeq))
+		for s := range bySeq {
+			seqs = append(seqs, s)
+		}
+		sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+		prevCol := out.Col("prev_seq")
+		cur := int64(-1)
+		for _, s := range seqs {
+			cands := bySeq[s]
+			// a prev_seq behind cur means the recorder missed seqs that
+			// were emitted from another source
+			pick := -1
+			for i, g := range cands {
+				if cur < 0 || g.snapshot || g.prev <= cur {
+					pick = i
+					break
+				}
+			}
+			if pick < 0 {
+				pick = 0
+				st.Gaps++
+			}
+			for i, g := range cands {
+				if i != pick {
+					st.Duplicates += len(g.rows)
+					continue
+				}
+				from := len(out.Rows)
+				keep(g.src, g.rows...)
+				if cur >= 0 && !g.snapshot && g.prev < cur {
+					for _, row := range out.Rows[from:] {
+						row[prevCol] = strconv.FormatInt(cur, 10)
+					}
+				}
+			}
+			cur = s
+		}
+		for i := range srcs {
+			lo, hi := int64(math.MaxInt64), int64(math.MinInt64)
+			for s := range have[i] {
+				lo, hi = min(lo, s), max(hi, s)
+			}
+			for _, s := range seqs {
+				if s > lo && s < hi && !have[i][s] {
+					srcs[i].stats.Filled++
+				}
+			}
+		}
+	}
+	st.Rows = len(out.Rows)
+	for _, s := range srcs {
+		st.Sources = append(st.Sources, s.stats)
+	}
+	return out, st, nil
+}
+
+// l2Group is the rows of one recorded L2 message.
+type l2Group struct {
+	src       int
+	seq, prev int64
+	snapshot  bool
+	rows      [][]string
+}
+
+// l2Groups splits an L2 capture into messages: consecutive rows sharing a
+// seq.
+func l2Groups(t *Table) ([]l2Group, error) {
+	var out []l2Group
+	for n, row := range t.Rows {
+		seq, err := t.Int(row, "seq")
+		if err != nil {
+			return nil, fmt.Errorf("row %d: seq: %w", n+1, err)
+		}
+		if len(out) > 0 && out[len(out)-1].seq == seq {
+			out[len(out)-1].rows = append(out[len(out)-1].rows, row)
+			continue
+		}
+		prev, err := t.Int(row, "prev_seq")
+		if err != nil {
+			return nil, fmt.Errorf("row %d: prev_seq: %w", n+1, err)
+		}
+		out = append(out, l2Group{seq: seq, prev: prev, snapshot: strings.EqualFold(t.Get(row, "type"), "snapshot"), rows: [][]string{row}})
+	}
+	return out, nil
+}
+
+// medianLatency is the median recv_ts_ms - ts_ms, or -1.
+func medianLatency(t *Table) float64 {
+	recv, ts := t.Col("recv_ts_ms"), t.Col("ts_ms")
+	if recv < 0 || ts < 0 || len(t.Rows) == 0 {
+		return -1
+	}
+	lat := make([]float64, 0, len(t.Rows))
+	for _, row := range t.Rows {
+		lat = append(lat, num(row, recv)-num(row, ts))
+	}
+	sort.Float64s(lat)
+	return lat[len(lat)/2]
+}
+
+func rank(latencyMs float64) float64 {
+	if latencyMs < 0 {
+		return math.Inf(1)
+	}
+	return latencyMs
+}
+
+// num parses row[i] as a number, 0 when it does not parse.
+func num(row []string, i int) float64 {
+	if i < 0 || i >= len(row) {
+		return 0
+	}
+	v, _ := strconv.ParseFloat(strings.TrimSpace(row[i]), 64)
+	return v
+}
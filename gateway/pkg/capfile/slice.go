@@ -0,0 +1,170 @@
+package capfile
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/helix-lab/helix/gateway/pkg/catalog"
+	"github.com/helix-lab/helix/gateway/pkg/l2book"
+)
+
+// Window bounds a slice, inclusive. Zero bounds are open; seq bounds apply
+// to L2 captures and trades captures with a seq column.
+type Window struct {
+	FromMs, ToMs   int64
+	FromSeq, ToSeq int64
+}
+
+func (w Window) before(ts, seq int64) bool {
+	return (w.FromMs > 0 && ts < w.FromMs) || (w.FromSeq > 0 && seq < w.FromSeq)
+}
+
+func (w Window) after(ts, seq int64) bool {
+	return (w.ToMs > 0 && ts > w.ToMs) || (w.ToSeq > 0 && seq > w.ToSeq)
+}
+
+// SliceStats describes a slice.
+type SliceStats struct {
+	Kind string
+	Rows int
+	// Replayed counts the recorded rows before the window that were kept or
+	// folded into a synthesized snapshot so the slice starts from a valid
+	// book.
+	Replayed    int
+	Synthesized bool
+}
+
+// Slice extracts the rows of t within w. An L2 slice always begins with a
+// full book: the window's first message when it is a snapshot, otherwise
+// the nearest preceding recorded snapshot when fromSnapshot is set, or a
+// snapshot synthesized from the book rebuilt up to the window, carrying
+// the seq of the last message before it so the chain continues.
+func Slice(t *Table, w Window, fromSnapshot bool) (*Table, SliceStats, error) {
+	st := SliceStats{Kind: t.Kind()}
+	out := NewTable(t.Header)
+	ts := t.Col("ts_ms")
+	switch st.Kind {
+	case catalog.KindTrades:
+		seq := t.Col("seq")
+		if seq < 0 && (w.FromSeq > 0 || w.ToSeq > 0) {
+			return nil, st, errors.New("seq bounds need a seq column")
+		}
+		for _, row := range t.Rows {
+			r, s := int64(num(row, ts)), int64(num(row, seq))
+			if !w.before(r, s) && !w.after(r, s) {
+				out.Rows = append(out.Rows, row)
+			}
+		}
+
+	case catalog.KindL2:
+		groups, err := l2Groups(t)
+		if err != nil {
+			return nil, st, err
+		}
+		first := len(groups)
+		for i, g := range groups {
+			if !w.before(int64(num(g.rows[0], ts)), g.seq) {
+				first = i
+				break
+			}
+		}
+		if first == len(groups) || w.after(int64(num(groups[first].rows[0], ts)), groups[first].seq) {
+			return nil, st, errors.New("no L2 messages in window")
+		}
+		switch {
+		case groups[first].snapshot || first == 0:
+		case fromSnapshot:
+			i := first - 1
+			for i >= 0 && !groups[i].snapshot {
+				i--
+			}
+			if i < 0 {
+				return nil, st, errors.New("no snapshot before window")
+			}
+			for _, g := range groups[i:first] {
+				out.Rows = append(out.Rows, g.rows...)
+				st.Replayed += len(g.rows)
+			}
+		default:
+			rows, err := synthesize(t, groups[:first], w.FromMs)
+			if err != nil {
+				return nil, st, err
+			}
+			for _, g := range groups[:first] {
+				st.Replayed += len(g.rows)
+			}
+			out.Rows = append(out.Rows, rows...)
+			st.Synthesized = true
+		}
+		for _, g := range groups[first:] {
+			if w.after(int64(num(g.rows[0], ts)), g.seq) {
+				break
+			}
+			out.Rows = append(out.Rows, g.rows...)
+		}
+
+	default:
+		return nil, st, errors.New("not an L2 or trades capture")
+	}
+	st.Rows = len(out.Rows)
+	return out, st, nil
+}
+
+// synthesize rebuilds the book from groups and returns it as snapshot rows
+// stamped atMs, or the last message's time when atMs is 0. Columns other
+// than the L2 ones are copied from the last recorded row.
+func synthesize(t *Table, groups []l2Group, atMs int64) ([][]string, error) {
+	book := l2book.New()
+	var p l2book.Parser
+	p.Parse(t.Header)
+	var last []string
+	for _, g := range groups {
+		for _, row := range g.rows {
+			d, ok := p.Parse(row)
+			if !ok {
+				continue
+			}
+			if err := book.Update(d); err != nil {
+				return nil, err
+			}
+			last = row
+		}
+	}
+	if !book.Ready() {
+		return nil, errors.New("no complete book before window")
+	}
+	if err := book.Check(); err != nil {
+		return nil, fmt.Errorf("book before window at seq %d: %w", book.LastSeq, err)
+	}
+	if atMs == 0 {
+		atMs = book.LastTsMs
+	}
+	g := groups[len(groups)-1]
+	bids, asks := book.Depth(math.MaxInt)
+	var rows [][]string
+	add := func(side string, lv l2book.Level) {
+		row := append([]string(nil), last...)
+		set := func(col, v string) {
+			if i := t.Col(col); i >= 0 && i < len(row) {
+				row[i] = v
+			}
+		}
+		set("ts_ms", strconv.FormatInt(atMs, 10))
+		set("seq", strconv.FormatInt(g.seq, 10))
+		set("prev_seq", strconv.FormatInt(g.prev, 10))
+		set("book_side", side)
+		set("price", strconv.FormatFloat(lv.Price, 'f', -1, 64))
+		set("size", strconv.FormatFloat(lv.Size, 'f', -1, 64))
+		set("type", "snapshot")
+		rows = append(rows, row)
+	}
+	for _, lv := range bids {
+		add("bid", lv)
+	}
+	for _, lv := range asks {
+		add("ask", lv)
+	}
+	return rows, nil
+}
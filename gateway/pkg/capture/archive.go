@@ -0,0 +1,267 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Archive format. The file starts with ArchiveMagic, then one record per
+// frame:
+//
+//	u32 len | i64 mono_ns | i64 recv_ns | u16 src_len | src | frame
+//
+// little endian, where len counts everything after itself. mono_ns is read
+// from the monotonic clock, relative to when the archive was opened, so
+// frame spacing survives wall clock steps; recv_ns is the wall clock the
+// tap reported. The index (path + ".idx") holds a 32-byte entry
+//
+//	u64 frame | u64 offset | i64 mono_ns | i64 recv_ns
+//
+// for the first frame and every IndexEvery frames after it.
+const (
+	ArchiveMagic = "HLXRAW01"
+	IndexEvery   = 1024
+
+	recordHeader = 8 + 8 + 2
+	indexEntry   = 32
+)
+
+// RawFrame is one archived frame.
+type RawFrame struct {
+	MonoNs int64
+	RecvNs int64
+	Source string
+	Data   []byte
+
+	buf *[]byte // the tap's pooled copy, released once written
+}
+
+// Frame converts f for the readers of frame logs.
+func (f RawFrame) Frame() Frame {
+	fr := Frame{RecvNs: f.RecvNs, Source: f.Source}
+	if json.Valid(f.Data) {
+		fr.Raw = f.Data
+	} else {
+		fr.B64 = f.Data
+	}
+	return fr
+}
+
+// Archive writes every tapped frame, exactly as received, to a binary file.
+// Unlike FrameLog it never drops: when the buffer is full the tap waits for
+// the disk, and Stalls counts how often that happened.
+type Archive struct {
+	f, idx  *os.File
+	base    time.Time
+	frames  chan RawFrame
+	done    chan struct{}
+	once    sync.Once
+	err     error
+	written atomic.Uint64
+	stalls  atomic.Uint64
+}
+
+// OpenArchive creates the archive at path and its index.
+func OpenArchive(path string, buffer int) (*Archive, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := os.Create(path + ".idx")
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.WriteString(ArchiveMagic); err != nil {
+		f.Close()
+		idx.Close()
+		return nil, err
+	}
+	if buffer <= 0 {
+		buffer = 8192
+	}
+	a := &Archive{f: f, idx: idx, base: time.Now(), frames: make(chan RawFrame, buffer), done: make(chan struct{})}
+	go a.loop()
+	return a, nil
+}
+
+// Tap returns a wsclient-compatible tap that tags frames with source. The
+// frame is copied, so callers may reuse it once the tap returns.
+func (a *Archive) Tap(source string) func(recvNs int64, frame []byte) {
+	return func(recvNs int64, frame []byte) {
+		buf := copyFrame(frame)
+		fr := RawFrame{MonoNs: int64(time.Since(a.base)), RecvNs: recvNs, Source: source, Data: *buf, buf: buf}
+		select {
+		case a.frames <- fr:
+		default:
+			a.stalls.Add(1)
+			a.frames <- fr
+		}
+	}
+}
+
+func (a *Archive) Written() uint64 { return a.written.Load() }
+func (a *Archive) Stalls() uint64  { return a.stalls.Load() }
+
+// Close drains buffered frames, closes both files and reports the first
+// write error.
+func (a *Archive) Close() error {
+	a.once.Do(func() { close(a.frames) })
+	<-a.done
+	err := a.err
+	for _, c := range []io.Closer{a.f, a.idx} {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (a *Archive) loop() {
+	defer close(a.done)
+	bw := bufio.NewWriterSize(a.f, 1<<20)
+	iw := bufio.NewWriter(a.idx)
+	flush := func() {
+		if err := bw.Flush(); err != nil && a.err == nil {
+			a.err = err
+		}
+		if err := iw.Flush(); err != nil && a.err == nil {
+			a.err = err
+		}
+	}
+	defer flush()
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	off := uint64(len(ArchiveMagic))
+	var n uint64
+	hdr := make([]byte, 4+recordHeader)
+	ent := make([]byte, indexEntry)
+	write := func(fr RawFrame) error {
+		if n%IndexEvery == 0 {
+			binary.LittleEndian.PutUint64(ent[0:], n)
+			binary.LittleEndian.PutUint64(ent[8:], off)
+			binary.LittleEndian.PutUint64(ent[16:], uint64(fr.MonoNs))
+			binary.LittleEndian.PutUint64(ent[24:], uint64(fr.RecvNs))
+			if _, err := iw.Write(ent); err != nil {
+				return err
+			}
+		}
+		size := recordHeader + len(fr.Source) + len(fr.Data)
+		binary.LittleEndian.PutUint32(hdr[0:], uint32(size))
+		binary.LittleEndian.PutUint64(hdr[4:], uint64(fr.MonoNs))
+		binary.LittleEndian.PutUint64(hdr[12:], uint64(fr.RecvNs))
+		binary.LittleEndian.PutUint16(hdr[20:], uint16(len(fr.Source)))
+		bw.Write(hdr)
+		bw.WriteString(fr.Source)
+		if _, err := bw.Write(fr.Data); err != nil {
+			return err
+		}
+		off += uint64(4 + size)
+		n++
+		a.written.Add(1)
+		return nil
+	}
+	for {
+		select {
+		case fr, ok := <-a.frames:
+			if !ok {
+				return
+			}
+			if a.err == nil {
+				a.err = write(fr)
+			}
+			releaseFrame(fr.buf)
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// DecodeArchive calls fn for every frame of an archive read from r, which
+// must be positioned after the magic. A truncated last record, as left by
+// a crash, ends the read without error.
+func DecodeArchive(r io.Reader, fn func(RawFrame) error) error {
+	br := bufio.NewReaderSize(r, 1<<20)
+	hdr := make([]byte, 4+recordHeader)
+	for n := 0; ; n++ {
+		if _, err := io.ReadFull(br, hdr); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil
+			}
+			return err
+		}
+		size := int(binary.LittleEndian.Uint32(hdr[0:]))
+		srcLen := int(binary.LittleEndian.Uint16(hdr[20:]))
+		if size < recordHeader+srcLen {
+			return fmt.Errorf("frame %d: bad record length %d", n, size)
+		}
+		body := make([]byte, size-recordHeader)
+		if _, err := io.ReadFull(br, body); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil
+			}
+			return err
+		}
+		fr := RawFrame{
+			MonoNs: int64(binary.LittleEndian.Uint64(hdr[4:])),
+			RecvNs: int64(binary.LittleEndian.Uint64(hdr[12:])),
+			Source: string(body[:srcLen]),
+			Data:   body[srcLen:],
+		}
+		if err := fn(fr); err != nil {
+			return err
+		}
+	}
+}
+
+// ReadArchive calls fn for every frame in the archive at path, starting
+// at the last indexed frame received at or before fromNs (wall clock; 0
+// reads from the start).
+func ReadArchive(path string, fromNs int64, fn func(RawFrame) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	magic := make([]byte, len(ArchiveMagic))
+	if _, err := io.ReadFull(f, magic); err != nil || string(magic) != ArchiveMagic {
+		return fmt.Errorf("%s: not a raw frame archive", path)
+	}
+	if fromNs > 0 {
+		off, err := seekIndex(path+".idx", fromNs)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Seek(int64(off), io.SeekStart); err != nil {
+			return err
+		}
+	}
+	return DecodeArchive(f, fn)
+}
+
+// seekIndex returns the offset of the last indexed frame received at or
+// before ns, or of the first frame.
+func seekIndex(path string, ns int64) (uint64, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	if len(b)%indexEntry != 0 {
+		b = b[:len(b)-len(b)%indexEntry]
+	}
+	off := uint64(len(ArchiveMagic))
+	for i := 0; i < len(b); i += indexEntry {
+		if int64(binary.LittleEndian.Uint64(b[i+24:])) > ns {
+			break
+		}
+		off = binary.LittleEndian.Uint64(b[i+8:])
+	}
+	return off, nil
+}
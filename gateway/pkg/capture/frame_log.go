@@ -0,0 +1,101 @@
+// Package capture persists what the gateway saw on the wire so incidents can
+// be replayed exactly.
+package capture
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Frame is one raw websocket frame as received.
+type Frame struct {
+	RecvNs int64           `json:"recv_ns"`
+	Source string          `json:"src"`
+	Raw    json.RawMessage `json:"frame,omitempty"`
+	// B64 holds frames that are not valid JSON.
+	B64 []byte `json:"frame_b64,omitempty"`
+
+	buf *[]byte // the tap's pooled copy, released once written
+}
+
+// FrameLog appends frames as JSON lines from a background goroutine so the
+// read loop never waits on disk. Frames are dropped (and counted) when the
+// buffer is full.
+type FrameLog struct {
+	f       *os.File
+	frames  chan Frame
+	done    chan struct{}
+	once    sync.Once
+	written atomic.Uint64
+	dropped atomic.Uint64
+}
+
+func OpenFrameLog(path string, buffer int) (*FrameLog, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if buffer <= 0 {
+		buffer = 8192
+	}
+	l := &FrameLog{f: f, frames: make(chan Frame, buffer), done: make(chan struct{})}
+	go l.loop()
+	return l, nil
+}
+
+// Tap returns a wsclient-compatible tap that tags frames with source.
+// The frame is copied, so callers may reuse it once the tap returns.
+func (l *FrameLog) Tap(source string) func(recvNs int64, frame []byte) {
+	return func(recvNs int64, frame []byte) {
+		buf := copyFrame(frame)
+		fr := Frame{RecvNs: recvNs, Source: source, buf: buf}
+		if json.Valid(*buf) {
+			fr.Raw = *buf
+		} else {
+			fr.B64 = *buf
+		}
+		select {
+		case l.frames <- fr:
+		default:
+			l.dropped.Add(1)
+			releaseFrame(buf)
+		}
+	}
+}
+
+func (l *FrameLog) Written() uint64 { return l.written.Load() }
+func (l *FrameLog) Dropped() uint64 { return l.dropped.Load() }
+
+// Close drains buffered frames and closes the file.
+func (l *FrameLog) Close() error {
+	l.once.Do(func() { close(l.frames) })
+	<-l.done
+	return l.f.Close()
+}
+
+func (l *FrameLog) loop() {
+	defer close(l.done)
+	bw := bufio.NewWriterSize(l.f, 1<<20)
+	defer bw.Flush()
+	enc := json.NewEncoder(bw)
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case fr, ok := <-l.frames:
+			if !ok {
+				return
+			}
+			if err := enc.Encode(fr); err == nil {
+				l.written.Add(1)
+			}
+			releaseFrame(fr.buf)
+		case <-ticker.C:
+			bw.Flush()
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package capture
+
+import "sync"
+
+// maxPooledFrame keeps the occasional huge snapshot from pinning memory in
+// the pool.
+const maxPooledFrame = 1 << 20
+
+// frameBufs recycles the copies taps take: connections reuse their read
+// buffer once the tap returns, and a copy per frame is most of a
+// recording's garbage.
+var frameBufs = sync.Pool{New: func() any { return new([]byte) }}
+
+func copyFrame(frame []byte) *[]byte {
+	p := frameBufs.Get().(*[]byte)
+	*p = append((*p)[:0], frame...)
+	return p
+}
+
+func releaseFrame(p *[]byte) {
+	if p != nil && cap(*p) <= maxPooledFrame {
+		frameBufs.Put(p)
+	}
+}
@@ -0,0 +1,54 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ReadFrames calls fn for every frame in a FrameLog file or raw frame
+// Archive, in order. A non-nil error from fn stops the read and is
+// returned.
+func ReadFrames(path string, fn func(Frame) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	if magic, _ := br.Peek(len(ArchiveMagic)); string(magic) == ArchiveMagic {
+		br.Discard(len(magic))
+		return DecodeArchive(br, func(fr RawFrame) error { return fn(fr.Frame()) })
+	}
+	return DecodeFrames(br, fn)
+}
+
+func DecodeFrames(r io.Reader, fn func(Frame) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 16<<20)
+	line := 0
+	for sc.Scan() {
+		line++
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var fr Frame
+		if err := json.Unmarshal(sc.Bytes(), &fr); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := fn(fr); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// Payload returns the frame bytes as received.
+func (f Frame) Payload() []byte {
+	if f.Raw != nil {
+		return f.Raw
+	}
+	return f.B64
+}
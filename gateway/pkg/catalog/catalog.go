@@ -0,0 +1,305 @@
+// Package catalog indexes recorded captures on disk: which symbol, venue
+// and time range each L2, trades or bars CSV covers, read from the file itself
+// and its .meta.json sidecar, and whether `helix validate` passed it, from
+// its .validation.json sidecar.
+package catalog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dataset kinds.
+const (
+	KindL2     = "l2"
+	KindTrades = "trades"
+	// KindBars are OHLCV bars, e.g. downloaded klines, timed by start_ms.
+	KindBars = "bars"
+)
+
+// Dataset is one capture file.
+type Dataset struct {
+	Path   string `json:"path"`
+	Kind   string `json:"kind"`
+	Venue  string `json:"venue,omitempty"`
+	Symbol string `json:"symbol,omitempty"`
+	// Version, Endpoint and Topic say which recorder wrote it from what,
+	// from the sidecar.
+	Version  string `json:"version,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Topic    string `json:"topic,omitempty"`
+	// StartMs and EndMs are the first and last row timestamps.
+	StartMs int64 `json:"start_ms"`
+	EndMs   int64 `json:"end_ms"`
+	Bytes   int64 `json:"bytes"`
+	// Sha256 is the checksum sealed into the sidecar, if any.
+	Sha256 string `json:"sha256,omitempty"`
+	// Status is one of the Status constants; Validation is the last check.
+	Status     string      `json:"status"`
+	Validation *Validation `json:"validation,omitempty"`
+}
+
+// Overlaps reports whether the dataset has rows in [from, to); zero bounds
+// are open.
+func (d Dataset) Overlaps(from, to time.Time) bool {
+	if !from.IsZero() && d.EndMs < from.UnixMilli() {
+		return false
+	}
+	return to.IsZero() || d.StartMs < to.UnixMilli()
+}
+
+// sidecar is the part of a recorder's .meta.json the catalog uses.
+type sidecar struct {
+	Version  string            `json:"version"`
+	Symbol   string            `json:"symbol"`
+	Venue    string            `json:"venue"`
+	Endpoint string            `json:"endpoint"`
+	Topic    string            `json:"topic"`
+	Sha256   map[string]string `json:"sha256"`
+	Columns  []string          `json:"columns"`
+}
+
+// Columns returns the CSV header path's sidecar declares, nil when it
+// has no sidecar or the recorder predates column selection.
+func Columns(path string) ([]string, error) {
+	b, err := os.ReadFile(MetaPath(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m sidecar
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", MetaPath(path), err)
+	}
+	return m.Columns, nil
+}
+
+// MetaPath is where a capture's sidecar lives: foo.csv -> foo.meta.json.
+func MetaPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".meta.json"
+}
+
+// Catalog is a snapshot of the captures under a directory.
+type Catalog struct {
+	Dir      string
+	Datasets []Dataset
+}
+
+// Scan indexes every recognisable CSV under dir. Files that are not
+// captures are skipped; files without a sidecar get no symbol and only
+// match queries that don't name one.
+func Scan(dir string) (*Catalog, error) {
+	c := &Catalog{Dir: dir}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".csv") {
+			return nil
+		}
+		ds, ok, err := Inspect(path)
+		if err != nil {
+			return err
+		}
+		if ok {
+			c.Datasets = append(c.Datasets, ds)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(c.Datasets, func(i, j int) bool {
+		a, b := c.Datasets[i], c.Datasets[j]
+		if a.StartMs != b.StartMs {
+			return a.StartMs < b.StartMs
+		}
+		return a.Path < b.Path
+	})
+	return c, nil
+}
+
+// Find returns the datasets of kind for symbol that overlap [from, to);
+// empty kind or symbol match any.
+func (c *Catalog) Find(kind, symbol string, from, to time.Time) []Dataset {
+	var out []Dataset
+	for _, d := range c.Datasets {
+		if (kind == "" || d.Kind == kind) && (symbol == "" || strings.EqualFold(d.Symbol, symbol)) && d.Overlaps(from, to) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// WithStatus keeps the datasets whose status is one of statuses.
+func WithStatus(sets []Dataset, statuses ...string) []Dataset {
+	var out []Dataset
+	for _, d := range sets {
+		for _, st := range statuses {
+			if d.Status == st {
+				out = append(out, d)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Inspect reads path's header, first and last rows and sidecar. ok is
+// false when the file is not an L2, trades or bars capture.
+func Inspect(path string) (ds Dataset, ok bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ds, false, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return ds, false, err
+	}
+	br := bufio.NewReader(f)
+	header, err := readRecord(br)
+	if err != nil {
+		return ds, false, nil
+	}
+	cols := map[string]int{}
+	for i, h := range header {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	ts, hasTs := cols["ts_ms"]
+	_, hasSide := cols["side"]
+	_, hasBookSide := cols["book_side"]
+	_, hasSeq := cols["seq"]
+	switch {
+	case !hasTs && hasCols(cols, "start_ms", "open", "high", "low", "close"):
+		ts = cols["start_ms"]
+		ds.Kind = KindBars
+	case !hasTs:
+		return ds, false, nil
+	case hasSeq && hasBookSide:
+		ds.Kind = KindL2
+	case hasSide && hasCols(cols, "price", "size"):
+		ds.Kind = KindTrades
+	default:
+		return ds, false, nil
+	}
+	ds.Path, ds.Bytes = path, st.Size()
+	if err := ds.readSidecars(); err != nil {
+		return ds, false, err
+	}
+	first, err := readRecord(br)
+	if err != nil {
+		// header only: an empty capture
+		return ds, true, nil
+	}
+	if ds.StartMs, err = field(first, ts); err != nil {
+		return ds, false, fmt.Errorf("%s: %w", path, err)
+	}
+	last, err := lastRecord(f, st.Size())
+	if err != nil {
+		return ds, false, fmt.Errorf("%s: %w", path, err)
+	}
+	if ds.EndMs, err = field(last, ts); err != nil {
+		return ds, false, fmt.Errorf("%s: last row: %w", path, err)
+	}
+	return ds, true, nil
+}
+
+// readSidecars fills in lineage and validation status.
+func (ds *Dataset) readSidecars() error {
+	if b, err := os.ReadFile(MetaPath(ds.Path)); err == nil {
+		var m sidecar
+		if err := json.Unmarshal(b, &m); err != nil {
+			return fmt.Errorf("%s: %w", MetaPath(ds.Path), err)
+		}
+		ds.Symbol, ds.Version, ds.Endpoint, ds.Topic = strings.ToUpper(m.Symbol), m.Version, m.Endpoint, m.Topic
+		ds.Venue = m.Venue
+		ds.Sha256 = m.Sha256[filepath.Base(ds.Path)]
+		if ds.Venue == "" {
+			ds.Venue = venueOf(m.Endpoint)
+		}
+	}
+	v, ok, err := ReadValidation(ds.Path)
+	switch {
+	case err != nil:
+		return err
+	case !ok:
+		ds.Status = StatusUnvalidated
+	case v.Bytes != ds.Bytes:
+		ds.Status, ds.Validation = StatusStale, &v
+	default:
+		ds.Status, ds.Validation = v.Status, &v
+	}
+	return nil
+}
+
+func hasCols(cols map[string]int, names ...string) bool {
+	for _, n := range names {
+		if _, ok := cols[n]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func field(rec []string, i int) (int64, error) {
+	if i >= len(rec) {
+		return 0, errors.New("short row")
+	}
+	return strconv.ParseInt(strings.TrimSpace(rec[i]), 10, 64)
+}
+
+func readRecord(br *bufio.Reader) ([]string, error) {
+	line, err := br.ReadString('\n')
+	if line == "" && err != nil {
+		return nil, err
+	}
+	return csv.NewReader(strings.NewReader(line)).Read()
+}
+
+// lastRecord parses the last non-empty line of a file of size bytes.
+func lastRecord(f *os.File, size int64) ([]string, error) {
+	const chunk = 4096
+	var tail []byte
+	for off := size; off > 0; {
+		n := int64(chunk)
+		if off < n {
+			n = off
+		}
+		off -= n
+		buf := make([]byte, n)
+		if _, err := f.ReadAt(buf, off); err != nil && err != io.EOF {
+			return nil, err
+		}
+		tail = append(buf, tail...)
+		trimmed := bytes.TrimRight(tail, "\r\n")
+		if i := bytes.LastIndexByte(trimmed, '\n'); i >= 0 {
+			return csv.NewReader(bytes.NewReader(trimmed[i+1:])).Read()
+		}
+	}
+	return nil, errors.New("no rows")
+}
+
+// venueOf guesses the venue from a recorder endpoint's host.
+func venueOf(endpoint string) string {
+	for _, v := range []string{"bybit", "binance", "okx", "kraken"} {
+		if strings.Contains(strings.ToLower(endpoint), v) {
+			return strings.ToUpper(v)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,138 @@
+package catalog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Checksum is the SHA-256 of the file at path, hex encoded.
+func Checksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Seal records the checksums of path and of extra data files written
+// with it (e.g. a recorder's bookcheck) in path's sidecar, under "sha256"
+// keyed by path relative to path's directory. Other sidecar fields are
+// kept; a missing sidecar is created.
+func Seal(path string, extra ...string) error {
+	meta := map[string]any{}
+	b, err := os.ReadFile(MetaPath(path))
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(b, &meta); err != nil {
+			return fmt.Errorf("%s: %w", MetaPath(path), err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+	sums, _ := meta["sha256"].(map[string]any)
+	if sums == nil {
+		sums = map[string]any{}
+	}
+	for _, p := range append([]string{path}, extra...) {
+		sum, err := Checksum(p)
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(filepath.Dir(path), p)
+		if err != nil {
+			if name, err = filepath.Abs(p); err != nil {
+				return err
+			}
+		}
+		sums[filepath.ToSlash(name)] = sum
+	}
+	meta["sha256"] = sums
+	if b, err = json.MarshalIndent(meta, "", "  "); err != nil {
+		return err
+	}
+	return os.WriteFile(MetaPath(path), append(b, '\n'), 0o644)
+}
+
+// FileCheck is one file's checksum comparison.
+type FileCheck struct {
+	Path string `json:"path"`
+	Want string `json:"want"`
+	Got  string `json:"got,omitempty"`
+	// Err is set when the file could not be read.
+	Err string `json:"error,omitempty"`
+}
+
+func (c FileCheck) OK() bool { return c.Err == "" && c.Got == c.Want }
+
+// ErrNoChecksum is returned by Verify for a capture whose sidecar records
+// no checksums.
+var ErrNoChecksum = errors.New("no checksum in sidecar")
+
+// Verify recomputes every checksum in path's sidecar, which covers path
+// and any files sealed with it, resolved next to path.
+func Verify(path string) ([]FileCheck, error) {
+	b, err := os.ReadFile(MetaPath(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoChecksum
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m struct {
+		Sha256 map[string]string `json:"sha256"`
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", MetaPath(path), err)
+	}
+	if len(m.Sha256) == 0 {
+		return nil, ErrNoChecksum
+	}
+	names := make([]string, 0, len(m.Sha256))
+	for name := range m.Sha256 {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := make([]FileCheck, 0, len(names))
+	for _, name := range names {
+		p := filepath.FromSlash(name)
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(filepath.Dir(path), p)
+		}
+		c := FileCheck{Path: p, Want: m.Sha256[name]}
+		if c.Got, err = Checksum(c.Path); err != nil {
+			c.Err = err.Error()
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// Verified is Verify reduced to an error: nil only when path's sidecar
+// has checksums and every file matches.
+func Verified(path string) error {
+	checks, err := Verify(path)
+	if err != nil {
+		return err
+	}
+	for _, c := range checks {
+		switch {
+		case c.Err != "":
+			return fmt.Errorf("%s: %s", c.Path, c.Err)
+		case !c.OK():
+			return fmt.Errorf("%s: checksum mismatch", c.Path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Segment continuity, relative to the segment before it.
+const (
+	ContinuityFirst = "first"
+	// ContinuityOK: the segment's first message chains to the previous
+	// segment's last seq.
+	ContinuityOK = "ok"
+	// ContinuityResync: the chain breaks but the segment opens with a
+	// snapshot, so the book recovers.
+	ContinuityResync = "resync"
+	ContinuityGap    = "gap"
+)
+
+// Segment is one file of a rotated run.
+type Segment struct {
+	// Path is relative to the manifest's directory.
+	Path    string `json:"path"`
+	StartMs int64  `json:"start_ms"`
+	EndMs   int64  `json:"end_ms"`
+	Rows    int64  `json:"rows"`
+	Bytes   int64  `json:"bytes"`
+	// FirstPrevSeq, FirstSeq and LastSeq are zero for captures without a
+	// seq chain.
+	FirstPrevSeq int64  `json:"first_prev_seq,omitempty"`
+	FirstSeq     int64  `json:"first_seq,omitempty"`
+	LastSeq      int64  `json:"last_seq,omitempty"`
+	Snapshot     bool   `json:"starts_with_snapshot,omitempty"`
+	Continuity   string `json:"continuity"`
+	Sha256       string `json:"sha256,omitempty"`
+}
+
+// Manifest lists the segments of one recording run. Recorders rewrite it
+// after every rotation; Complete is set when the run ends cleanly.
+type Manifest struct {
+	Version   string    `json:"version"`
+	Kind      string    `json:"kind"`
+	Symbol    string    `json:"symbol,omitempty"`
+	Topic     string    `json:"topic,omitempty"`
+	StartTime string    `json:"start_time"`
+	Complete  bool      `json:"complete"`
+	Segments  []Segment `json:"segments"`
+}
+
+// ManifestPath is where a rotated run's manifest lives: the recorder's
+// -out foo.csv -> foo.run.json.
+func ManifestPath(out string) string {
+	return strings.TrimSuffix(out, filepath.Ext(out)) + ".run.json"
+}
+
+// SegmentPath names segment n of the run recorded to out: foo.csv ->
+// foo.0003.csv.
+func SegmentPath(out string, n int) string {
+	ext := filepath.Ext(out)
+	return fmt.Sprintf("%s.%04d%s", strings.TrimSuffix(out, ext), n, ext)
+}
+
+// Add appends s, setting its continuity from the last segment.
+func (m *Manifest) Add(s Segment) {
+	switch {
+	case len(m.Segments) == 0:
+		s.Continuity = ContinuityFirst
+	case s.FirstSeq == 0 || s.FirstPrevSeq == m.Segments[len(m.Segments)-1].LastSeq:
+		s.Continuity = ContinuityOK
+	case s.Snapshot:
+		s.Continuity = ContinuityResync
+	default:
+		s.Continuity = ContinuityGap
+	}
+	m.Segments = append(m.Segments, s)
+}
+
+// WriteManifest stores m at path, replacing it atomically so readers never
+// see a half-written run.
+func WriteManifest(path string, m *Manifest) error {
+	return writeJSON(path, m)
+}
+
+func writeJSON(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func ReadManifest(path string) (*Manifest, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// IsManifest reports whether path names a run manifest.
+func IsManifest(path string) bool { return strings.HasSuffix(path, ".run.json") }
+
+// OpenRun resolves the segments of the run at path, in order. It fails
+// when a segment file is missing or differs in size or checksum from what
+// was recorded, or when the seq chain breaks between segments without a
+// snapshot to resync from, unless allowGaps is set.
+func OpenRun(path string, allowGaps bool) ([]string, *Manifest, error) {
+	m, err := ReadManifest(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(m.Segments) == 0 {
+		return nil, m, fmt.Errorf("%s: no segments", path)
+	}
+	var errs []error
+	paths := make([]string, len(m.Segments))
+	for i, s := range m.Segments {
+		p := filepath.Join(filepath.Dir(path), filepath.FromSlash(s.Path))
+		paths[i] = p
+		st, err := os.Stat(p)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("segment %d missing: %w", i, err))
+			continue
+		}
+		if st.Size() != s.Bytes {
+			errs = append(errs, fmt.Errorf("segment %d (%s): %d bytes, run recorded %d", i, s.Path, st.Size(), s.Bytes))
+		} else if s.Sha256 != "" {
+			if sum, err := Checksum(p); err != nil || sum != s.Sha256 {
+				errs = append(errs, fmt.Errorf("segment %d (%s): checksum mismatch", i, s.Path))
+			}
+		}
+		if s.Continuity == ContinuityGap && !allowGaps {
+			errs = append(errs, fmt.Errorf("segment %d (%s): seq gap from %d to %d", i, s.Path, m.Segments[i-1].LastSeq, s.FirstPrevSeq))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return paths, m, fmt.Errorf("%s: %w", path, err)
+	}
+	return paths, m, nil
+}
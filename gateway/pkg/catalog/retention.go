@@ -0,0 +1,166 @@
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Upload says where a capture was copied off the host, kept in the
+// .upload.json sidecar next to it. It holds for the file whose checksum
+// it names.
+type Upload struct {
+	Dest       string    `json:"dest"`
+	Sha256     string    `json:"sha256"`
+	UploadedAt time.Time `json:"uploaded_at"`
+}
+
+// UploadPath is where a capture's upload record lives: foo.csv ->
+// foo.upload.json.
+func UploadPath(path string) string {
+	return strings.TrimSuffix(MetaPath(path), ".meta.json") + ".upload.json"
+}
+
+// MarkUploaded records that path, as it is now, was copied to dest.
+func MarkUploaded(path, dest string, at time.Time) error {
+	sum, err := Checksum(path)
+	if err != nil {
+		return err
+	}
+	return writeJSON(UploadPath(path), Upload{Dest: dest, Sha256: sum, UploadedAt: at.UTC()})
+}
+
+// ReadUpload loads path's upload record; ok is false when there is none.
+func ReadUpload(path string) (u Upload, ok bool, err error) {
+	b, err := os.ReadFile(UploadPath(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return u, false, nil
+	}
+	if err != nil {
+		return u, false, err
+	}
+	if err := json.Unmarshal(b, &u); err != nil {
+		return u, false, fmt.Errorf("%s: %w", UploadPath(path), err)
+	}
+	return u, true, nil
+}
+
+// Retention is how long captures stay on the host and what they must have
+// been through before they go. Zero KeepFor and MaxBytes keep everything.
+type Retention struct {
+	// KeepFor keeps captures whose last row is younger than this.
+	KeepFor time.Duration
+	// MaxBytes removes the oldest captures, even younger than KeepFor,
+	// until the catalog fits, still only those Validated and Uploaded
+	// allow.
+	MaxBytes int64
+	// Validated removes only captures that passed `helix validate`, and
+	// Uploaded only those with an upload record matching the file.
+	Validated bool
+	Uploaded  bool
+}
+
+// Removal is one capture a retention plan removes, or keeps past its
+// time and why.
+type Removal struct {
+	Dataset Dataset
+	Reason  string
+}
+
+// Plan is what applying a Retention to a catalog would do.
+type Plan struct {
+	Remove []Removal
+	// Held are captures past KeepFor (or over MaxBytes) the policy still
+	// keeps, e.g. not yet uploaded; a host whose disk fills has them to
+	// look at.
+	Held []Removal
+	// Bytes is the size of the catalog, and Freed what Remove takes off it.
+	Bytes, Freed int64
+}
+
+// PlanRetention decides, at now, which of c's captures r removes. Captures
+// without a sealed checksum may still be recording and are never removed.
+func PlanRetention(c *Catalog, r Retention, now time.Time) Plan {
+	var p Plan
+	sets := append([]Dataset(nil), c.Datasets...)
+	sort.SliceStable(sets, func(i, j int) bool { return sets[i].EndMs < sets[j].EndMs })
+	for _, d := range sets {
+		p.Bytes += d.Bytes
+	}
+	cut := now.Add(-r.KeepFor).UnixMilli()
+	size := p.Bytes
+	for _, d := range sets {
+		var reason string
+		switch {
+		case r.KeepFor > 0 && d.EndMs < cut:
+			reason = "older than " + r.KeepFor.String()
+		case r.MaxBytes > 0 && size > r.MaxBytes:
+			reason = fmt.Sprintf("over %d bytes", r.MaxBytes)
+		default:
+			continue
+		}
+		if held := r.blocks(d); held != "" {
+			p.Held = append(p.Held, Removal{Dataset: d, Reason: reason + ", but " + held})
+			continue
+		}
+		p.Remove = append(p.Remove, Removal{Dataset: d, Reason: reason})
+		p.Freed += d.Bytes
+		size -= d.Bytes
+	}
+	return p
+}
+
+// blocks is why r keeps d regardless of age, or "".
+func (r Retention) blocks(d Dataset) string {
+	if d.Sha256 == "" {
+		return "not sealed"
+	}
+	if r.Validated && d.Status != StatusOK {
+		return "validation " + d.Status
+	}
+	if r.Uploaded {
+		u, ok, err := ReadUpload(d.Path)
+		switch {
+		case err != nil:
+			return err.Error()
+		case !ok:
+			return "not uploaded"
+		case u.Sha256 != d.Sha256:
+			return "changed since its upload"
+		}
+	}
+	return ""
+}
+
+// Remove deletes a capture with its sidecars and the data files sealed
+// with it.
+func Remove(d Dataset) error {
+	paths := []string{d.Path}
+	if b, err := os.ReadFile(MetaPath(d.Path)); err == nil {
+		var m sidecar
+		if json.Unmarshal(b, &m) == nil {
+			for name := range m.Sha256 {
+				// files sealed by absolute path live elsewhere; leave them
+				if filepath.IsAbs(name) {
+					continue
+				}
+				if p := filepath.Join(filepath.Dir(d.Path), filepath.FromSlash(name)); p != d.Path {
+					paths = append(paths, p)
+				}
+			}
+		}
+	}
+	paths = append(paths, MetaPath(d.Path), ValidationPath(d.Path), UploadPath(d.Path))
+	var errs []error
+	for _, p := range paths {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,117 @@
+package catalog
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SyncManifest describes one synchronized capture: the same symbol
+// recorded from several venues over one shared wall-clock window, for
+// cross-venue latency and lead-lag research.
+type SyncManifest struct {
+	Version string `json:"version"`
+	Symbol  string `json:"symbol"`
+	// StartTime and StopTime are the shared window every venue's recorder
+	// ran in.
+	StartTime string      `json:"start_time"`
+	StopTime  string      `json:"stop_time"`
+	Venues    []SyncVenue `json:"venues"`
+}
+
+// SyncVenue is one venue's capture in a SyncManifest.
+type SyncVenue struct {
+	Venue    string `json:"venue"`
+	Endpoint string `json:"endpoint"`
+	// Path is relative to the manifest's directory.
+	Path string `json:"path"`
+	// Exit is the venue's recorder's exit code.
+	Exit   int          `json:"exit"`
+	Offset *ClockOffset `json:"clock_offset,omitempty"`
+	Sha256 string       `json:"sha256,omitempty"`
+}
+
+// ClockOffset is recv_ts_ms - ts_ms over a capture's messages: how far the
+// recording host's clock ran ahead of the venue's, plus the one-way
+// latency. MinMs bounds the clock offset most tightly; comparing venues'
+// offsets aligns their exchange timestamps.
+type ClockOffset struct {
+	Messages int     `json:"messages"`
+	MinMs    float64 `json:"min_ms"`
+	MedianMs float64 `json:"median_ms"`
+}
+
+// SyncManifestPath is where the synchronized capture of symbol in dir
+// keeps its manifest: dir/BTCUSDT.sync.json.
+func SyncManifestPath(dir, symbol string) string {
+	return filepath.Join(dir, symbol+".sync.json")
+}
+
+// WriteSyncManifest stores m at path, atomically like WriteManifest.
+func WriteSyncManifest(path string, m *SyncManifest) error {
+	return writeJSON(path, m)
+}
+
+// MeasureClockOffset reads the first row of every message (every row when
+// the capture has no seq) of the capture at path, which must have
+// recv_ts_ms and ts_ms columns.
+func MeasureClockOffset(path string) (ClockOffset, error) {
+	var off ClockOffset
+	f, err := os.Open(path)
+	if err != nil {
+		return off, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = true
+	header, err := r.Read()
+	if err != nil {
+		return off, fmt.Errorf("%s: header: %w", path, err)
+	}
+	cols := map[string]int{}
+	for i, h := range header {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if !hasCols(cols, "recv_ts_ms", "ts_ms") {
+		return off, fmt.Errorf("%s: no recv_ts_ms and ts_ms columns", path)
+	}
+	recvCol, tsCol := cols["recv_ts_ms"], cols["ts_ms"]
+	seqCol, hasSeq := cols["seq"]
+	var diffs []float64
+	lastSeq := ""
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return off, fmt.Errorf("%s: %w", path, err)
+		}
+		if hasSeq {
+			if seqCol < len(rec) && rec[seqCol] == lastSeq {
+				continue
+			}
+			if seqCol < len(rec) {
+				lastSeq = rec[seqCol]
+			}
+		}
+		recv, err1 := field(rec, recvCol)
+		ts, err2 := field(rec, tsCol)
+		if err := errors.Join(err1, err2); err != nil {
+			return off, fmt.Errorf("%s: %w", path, err)
+		}
+		diffs = append(diffs, float64(recv-ts))
+	}
+	if len(diffs) == 0 {
+		return off, fmt.Errorf("%s: no rows", path)
+	}
+	sort.Float64s(diffs)
+	off.Messages, off.MinMs, off.MedianMs = len(diffs), diffs[0], diffs[len(diffs)/2]
+	return off, nil
+}
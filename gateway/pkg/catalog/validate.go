@@ -0,0 +1,180 @@
+package catalog
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Validation statuses. A dataset is stale when its file changed after it
+// was validated.
+const (
+	StatusUnvalidated = "unvalidated"
+	StatusOK          = "ok"
+	StatusFailed      = "failed"
+	StatusStale       = "stale"
+)
+
+// DefaultGap is the row-to-row silence Validate counts as a gap.
+const DefaultGap = 5 * time.Second
+
+// maxErrors caps the problems a Validation lists; the counters keep going.
+const maxErrors = 10
+
+// Validation is what `helix validate` found in a capture, kept in the
+// .validation.json sidecar next to it.
+type Validation struct {
+	Status    string    `json:"status"`
+	CheckedAt time.Time `json:"checked_at"`
+	// Bytes is the file size that was checked.
+	Bytes int64 `json:"bytes"`
+	Rows  int64 `json:"rows"`
+	// Gaps counts row-to-row silences of at least GapMs; GapTotalMs sums
+	// them.
+	GapMs      int64 `json:"gap_ms"`
+	Gaps       int   `json:"gaps"`
+	GapTotalMs int64 `json:"gap_total_ms"`
+	MaxGapMs   int64 `json:"max_gap_ms"`
+	// BadRows, OutOfOrder and SeqGaps fail the capture.
+	BadRows    int      `json:"bad_rows"`
+	OutOfOrder int      `json:"out_of_order"`
+	SeqGaps    int      `json:"seq_gaps"`
+	Errors     []string `json:"errors,omitempty"`
+}
+
+// ValidationPath is where a capture's validation lives: foo.csv ->
+// foo.validation.json.
+func ValidationPath(path string) string {
+	return strings.TrimSuffix(MetaPath(path), ".meta.json") + ".validation.json"
+}
+
+// Validate reads every row of the capture at path: timestamps must parse
+// and never go back, and L2 deltas must chain seq to prev_seq. Silences of
+// gap or more (DefaultGap when 0) are counted but pass.
+func Validate(path string, gap time.Duration) (Validation, error) {
+	if gap <= 0 {
+		gap = DefaultGap
+	}
+	v := Validation{CheckedAt: time.Now().UTC(), GapMs: gap.Milliseconds()}
+	f, err := os.Open(path)
+	if err != nil {
+		return v, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return v, err
+	}
+	v.Bytes = st.Size()
+
+	r := csv.NewReader(bufio.NewReader(f))
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return v, fmt.Errorf("%s: header: %w", path, err)
+	}
+	cols := map[string]int{}
+	for i, h := range header {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	ts, ok := cols["ts_ms"]
+	if !ok {
+		// bars are timed by their start
+		ts, ok = cols["start_ms"]
+	}
+	if !ok {
+		return v, fmt.Errorf("%s: no ts_ms or start_ms column", path)
+	}
+	seq, hasSeq := cols["seq"]
+	prev, hasPrev := cols["prev_seq"]
+	typ, hasType := cols["type"]
+	chain := hasSeq && hasPrev
+
+	fail := func(format string, args ...any) {
+		if len(v.Errors) < maxErrors {
+			v.Errors = append(v.Errors, fmt.Sprintf(format, args...))
+		}
+	}
+	var lastTs, lastSeq int64 = -1, -1
+	for line := 2; ; line++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		v.Rows++
+		if err != nil {
+			v.BadRows++
+			fail("line %d: %v", line, err)
+			continue
+		}
+		t, err := field(rec, ts)
+		if err != nil {
+			v.BadRows++
+			fail("line %d: ts_ms: %v", line, err)
+			continue
+		}
+		switch {
+		case lastTs >= 0 && t < lastTs:
+			v.OutOfOrder++
+			fail("line %d: ts_ms %d before %d", line, t, lastTs)
+		case lastTs >= 0 && t-lastTs >= v.GapMs:
+			v.Gaps++
+			v.GapTotalMs += t - lastTs
+			v.MaxGapMs = max(v.MaxGapMs, t-lastTs)
+		}
+		lastTs = max(lastTs, t)
+		if !chain {
+			continue
+		}
+		s, errS := field(rec, seq)
+		p, errP := field(rec, prev)
+		if errS != nil || errP != nil {
+			v.BadRows++
+			fail("line %d: seq or prev_seq does not parse", line)
+			continue
+		}
+		snapshot := hasType && typ < len(rec) && strings.EqualFold(strings.TrimSpace(rec[typ]), "snapshot")
+		// rows of one message share a seq; a snapshot restarts the chain
+		if s != lastSeq && !snapshot && lastSeq >= 0 && p != lastSeq {
+			v.SeqGaps++
+			fail("line %d: seq %d has prev_seq %d, last seq was %d", line, s, p, lastSeq)
+		}
+		lastSeq = s
+	}
+	v.Status = StatusOK
+	if v.BadRows+v.OutOfOrder+v.SeqGaps > 0 {
+		v.Status = StatusFailed
+	}
+	return v, nil
+}
+
+// WriteValidation stores v in path's validation sidecar.
+func WriteValidation(path string, v Validation) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(ValidationPath(path), append(b, '\n'), 0o644)
+}
+
+// ReadValidation loads path's validation sidecar; ok is false when there
+// is none.
+func ReadValidation(path string) (v Validation, ok bool, err error) {
+	b, err := os.ReadFile(ValidationPath(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return v, false, nil
+	}
+	if err != nil {
+		return v, false, err
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return v, false, fmt.Errorf("%s: %w", ValidationPath(path), err)
+	}
+	return v, true, nil
+}
@@ -0,0 +1,333 @@
+// Package chaos injects faults into a running gateway for resilience
+// testing: connector disconnects, message storms, malformed frames, a slow
+// consumer and transport failures, at random times a scenario file sets.
+// It exercises the reconnect, backpressure and recovery paths on demand
+// instead of waiting for production to.
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/clock"
+	"github.com/helix-lab/helix/gateway/pkg/metrics"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+	"github.com/helix-lab/helix/gateway/pkg/ws"
+)
+
+var injected = metrics.Default.CounterVec("helix_chaos_faults_total", "Faults injected by the chaos scenario, by kind and venue.", "kind", "venue")
+
+// ErrInjected is the transport failure a KindTransport fault returns.
+var ErrInjected = fmt.Errorf("chaos: injected transport failure")
+
+// frameHandler is a connector that parses raw frames, as BybitStream does.
+type frameHandler interface {
+	HandleFrame(ctx context.Context, out ws.Feeds, recvNs int64, frame []byte) bool
+}
+
+// Injector plays a Scenario against the connectors it wraps and the hooks
+// the gateway calls.
+type Injector struct {
+	// Books is where storms take the updates they repeat; without it they
+	// inject nothing.
+	Books *orderbook.Manager
+	Logf  func(format string, args ...any)
+	// Clock times the faults; tests drive a virtual one.
+	Clock clock.Clock
+
+	sc Scenario
+
+	mu        sync.Mutex
+	conns     []*Connector
+	slowUntil time.Time
+	slowDelay time.Duration
+	downUntil time.Time
+	counts    map[string]uint64
+}
+
+func New(sc Scenario) *Injector {
+	return &Injector{sc: sc, Clock: clock.Real, counts: map[string]uint64{}}
+}
+
+// Wrap returns c with disconnects and malformed frames injectable; add the
+// result to the router in c's place.
+func (in *Injector) Wrap(c ws.Connector) ws.Connector {
+	w := &Connector{Connector: c, in: in, ready: make(chan struct{})}
+	in.mu.Lock()
+	in.conns = append(in.conns, w)
+	in.mu.Unlock()
+	return w
+}
+
+// Run injects the scenario's faults until ctx is done.
+func (in *Injector) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i, f := range in.sc.Faults {
+		wg.Add(1)
+		go func(f Fault, rng *rand.Rand) {
+			defer wg.Done()
+			wait := time.Duration(f.After)
+			for {
+				wait += time.Duration(rng.ExpFloat64() * float64(f.Every))
+				select {
+				case <-ctx.Done():
+					return
+				case <-in.Clock.After(wait):
+				}
+				in.Inject(ctx, f, rng)
+				wait = 0
+			}
+		}(f, rand.New(rand.NewSource(in.sc.Seed+int64(i))))
+	}
+	wg.Wait()
+}
+
+// Inject strikes f once now; rng picks the connector when several match.
+func (in *Injector) Inject(ctx context.Context, f Fault, rng *rand.Rand) {
+	switch f.Kind {
+	case KindSlowConsumer:
+		in.mu.Lock()
+		in.slowUntil = later(in.slowUntil, in.Clock.Now().Add(time.Duration(f.For)))
+		in.slowDelay = time.Duration(f.Delay)
+		in.mu.Unlock()
+		in.count(f.Kind, "")
+		in.logf("chaos: slow consumer, %s per update for %s", time.Duration(f.Delay), time.Duration(f.For))
+		return
+	case KindTransport:
+		in.mu.Lock()
+		in.downUntil = later(in.downUntil, in.Clock.Now().Add(time.Duration(f.For)))
+		in.mu.Unlock()
+		in.count(f.Kind, "")
+		in.logf("chaos: transport failing for %s", time.Duration(f.For))
+		return
+	}
+	c := in.pick(f.Venue, rng)
+	if c == nil {
+		return
+	}
+	switch f.Kind {
+	case KindDisconnect:
+		c.disconnect(in.Clock.Now().Add(time.Duration(f.For)))
+		in.logf("chaos: %s disconnected for %s", c.Venue(), time.Duration(f.For))
+	case KindStorm:
+		n := c.storm(ctx, f.Count)
+		in.logf("chaos: %s storm of %d updates", c.Venue(), n)
+	case KindMalformed:
+		if !c.malformed(ctx, f.Count, rng) {
+			in.logf("chaos: %s parses no frames, malformed skipped", c.Venue())
+			return
+		}
+		in.logf("chaos: %s fed %d malformed frames", c.Venue(), f.Count)
+	}
+	in.count(f.Kind, c.Venue())
+}
+
+// pick is a random wrapped connector on venue, any one when venue is empty.
+func (in *Injector) pick(venue string, rng *rand.Rand) *Connector {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	var match []*Connector
+	for _, c := range in.conns {
+		if venue == "" || strings.EqualFold(c.Venue(), venue) {
+			match = append(match, c)
+		}
+	}
+	if len(match) == 0 {
+		return nil
+	}
+	return match[rng.Intn(len(match))]
+}
+
+// Stall is the slow consumer: the gateway calls it for each depth update
+// and it sleeps while a KindSlowConsumer fault lasts.
+func (in *Injector) Stall() {
+	in.mu.Lock()
+	d := in.slowDelay
+	slow := in.Clock.Now().Before(in.slowUntil)
+	in.mu.Unlock()
+	if slow {
+		in.Clock.Sleep(d)
+	}
+}
+
+// Publish is a transport.Publisher Fail hook returning ErrInjected while a
+// KindTransport fault lasts.
+func (in *Injector) Publish(kind string) error {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.Clock.Now().Before(in.downUntil) {
+		return ErrInjected
+	}
+	return nil
+}
+
+// Injected counts the faults injected so far by kind.
+func (in *Injector) Injected() map[string]uint64 {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	out := make(map[string]uint64, len(in.counts))
+	for k, n := range in.counts {
+		out[k] = n
+	}
+	return out
+}
+
+func (in *Injector) count(kind, venue string) {
+	injected.With(kind, venue).Inc()
+	in.mu.Lock()
+	in.counts[kind]++
+	in.mu.Unlock()
+}
+
+func (in *Injector) logf(format string, args ...any) {
+	if in.Logf != nil {
+		in.Logf(format, args...)
+	}
+}
+
+func later(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// Connector is a wrapped connector. Disconnects cancel its Run and start
+// it again once the outage is over; storms and malformed frames go into
+// the feeds it was last run with.
+type Connector struct {
+	ws.Connector
+	in *Injector
+
+	mu        sync.Mutex
+	ctx       context.Context
+	out       ws.Feeds
+	stop      context.CancelFunc
+	downUntil time.Time
+	ready     chan struct{}
+}
+
+func (c *Connector) Unwrap() ws.Connector { return c.Connector }
+
+func (c *Connector) Run(ctx context.Context, out ws.Feeds) {
+	for ctx.Err() == nil {
+		runCtx, cancel := context.WithCancel(ctx)
+		c.mu.Lock()
+		first := c.stop == nil
+		c.ctx, c.out, c.stop = runCtx, out, cancel
+		c.mu.Unlock()
+		if first {
+			close(c.ready)
+		}
+		c.Connector.Run(runCtx, out)
+		cancel()
+		c.mu.Lock()
+		wait := c.downUntil.Sub(c.in.Clock.Now())
+		c.mu.Unlock()
+		if wait > 0 {
+			select {
+			case <-ctx.Done():
+			case <-c.in.Clock.After(wait):
+			}
+		}
+	}
+}
+
+// running is the context and feeds of the current session, false before
+// the first.
+func (c *Connector) running() (context.Context, ws.Feeds, bool) {
+	select {
+	case <-c.ready:
+	default:
+		return nil, ws.Feeds{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ctx, c.out, true
+}
+
+func (c *Connector) disconnect(until time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.downUntil = later(c.downUntil, until)
+	if c.stop != nil {
+		c.stop()
+	}
+}
+
+// storm sends n copies of the venue's books, round robin, as fast as the
+// feed takes them, and reports how many went out.
+func (c *Connector) storm(ctx context.Context, n int) int {
+	runCtx, out, ok := c.running()
+	if !ok || c.in.Books == nil {
+		return 0
+	}
+	var books []orderbook.Book
+	for _, b := range c.in.Books.Books() {
+		if b.Venue == c.Venue() {
+			books = append(books, b)
+		}
+	}
+	if len(books) == 0 {
+		return 0
+	}
+	for i := 0; i < n; i++ {
+		b := books[i%len(books)]
+		now := c.in.Clock.Now()
+		u := transport.DepthUpdate{Venue: b.Venue, Symbol: b.Symbol, Category: b.Category, BestBid: b.BestBid, BestAsk: b.BestAsk,
+			BidSize: b.BidSize, AskSize: b.AskSize, ExchTsMs: now.UnixMilli(), RecvNs: now.UnixNano()}
+		select {
+		case out.DepthFor(b.Symbol) <- u:
+		case <-runCtx.Done():
+			return i
+		case <-ctx.Done():
+			return i
+		}
+	}
+	return n
+}
+
+// malformed feeds n corrupt frames to the connector's parser; false when
+// it has none.
+func (c *Connector) malformed(ctx context.Context, n int, rng *rand.Rand) bool {
+	h, ok := c.Connector.(frameHandler)
+	if !ok {
+		return false
+	}
+	runCtx, out, ok := c.running()
+	if !ok {
+		return false
+	}
+	symbol := "BTCUSDT"
+	if s, ok := c.Connector.(ws.SymbolSubscriber); ok {
+		if subs := s.Subscribed(); len(subs) > 0 {
+			symbol = subs[rng.Intn(len(subs))]
+		}
+	}
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		h.HandleFrame(runCtx, out, c.in.Clock.Now().UnixNano(), corruptFrame(symbol, rng))
+	}
+	return true
+}
+
+// corruptFrame is one of the ways a venue frame arrives broken: cut short,
+// not JSON at all, the wrong shape, or levels that are not numbers.
+func corruptFrame(symbol string, rng *rand.Rand) []byte {
+	switch rng.Intn(4) {
+	case 0:
+		return []byte(`{"topic":"orderbook.50.` + symbol + `","type":"delta","data":{"s":"` + symbol)
+	case 1:
+		junk := make([]byte, 16+rng.Intn(48))
+		rng.Read(junk)
+		return junk
+	case 2:
+		return []byte(`{"topic":"orderbook.50.` + symbol + `","type":"delta","data":"oops"}`)
+	default:
+		return []byte(`{"topic":"orderbook.50.` + symbol + `","type":"delta","ts":0,"data":{"s":"` + symbol + `","b":[["NaN","-1"]],"a":[["x","y"]]}}`)
+	}
+}
@@ -0,0 +1,115 @@
+package chaos
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/config"
+)
+
+// Fault kinds.
+const (
+	// KindDisconnect drops a connector's session for For, then lets it
+	// reconnect.
+	KindDisconnect = "disconnect"
+	// KindStorm floods a venue's feed with Count copies of its books.
+	KindStorm = "storm"
+	// KindMalformed feeds Count corrupt frames to a connector's parser.
+	KindMalformed = "malformed"
+	// KindSlowConsumer makes the gateway take Delay over each depth update
+	// for For, so the router's backpressure engages.
+	KindSlowConsumer = "slow_consumer"
+	// KindTransport fails every publish for For.
+	KindTransport = "transport"
+)
+
+// Scenario is a chaos scenario file:
+//
+//	{"seed": 7, "faults": [
+//	  {"kind": "disconnect", "venue": "BYBIT", "every": "30s", "for": "2s"},
+//	  {"kind": "storm", "every": "10s", "count": 2000},
+//	  {"kind": "malformed", "every": "5s", "count": 3},
+//	  {"kind": "slow_consumer", "after": "1m", "every": "20s", "for": "3s", "delay": "5ms"},
+//	  {"kind": "transport", "every": "45s", "for": "1s"}]}
+type Scenario struct {
+	// Seed makes the fault timing repeatable.
+	Seed   int64   `json:"seed"`
+	Faults []Fault `json:"faults"`
+}
+
+// Fault is one kind of fault and how often it strikes.
+type Fault struct {
+	Kind string `json:"kind"`
+	// Venue limits connector faults to one venue; empty picks any.
+	Venue string `json:"venue,omitempty"`
+	// After holds the fault off for a while from the start.
+	After config.Duration `json:"after,omitempty"`
+	// Every is the mean time between injections, which arrive at random.
+	Every config.Duration `json:"every"`
+	// For is how long a disconnect, slow consumer or transport failure
+	// lasts.
+	For config.Duration `json:"for,omitempty"`
+	// Count is how many updates a storm sends or frames a malformed
+	// injection feeds; it defaults to 1000 and 1.
+	Count int `json:"count,omitempty"`
+	// Delay is what a slow consumer spends on each update.
+	Delay config.Duration `json:"delay,omitempty"`
+}
+
+// Load reads and validates a scenario file. Unknown keys are errors.
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var sc Scenario
+	if err := dec.Decode(&sc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := sc.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &sc, nil
+}
+
+// Validate checks every fault and fills in default counts.
+func (sc *Scenario) Validate() error {
+	if len(sc.Faults) == 0 {
+		return fmt.Errorf("no faults")
+	}
+	for i := range sc.Faults {
+		f := &sc.Faults[i]
+		if time.Duration(f.Every) <= 0 {
+			return fmt.Errorf("fault %d (%s): every must be positive", i, f.Kind)
+		}
+		switch f.Kind {
+		case KindDisconnect, KindTransport:
+			if f.For <= 0 {
+				return fmt.Errorf("fault %d (%s): for must be positive", i, f.Kind)
+			}
+		case KindSlowConsumer:
+			if f.For <= 0 || f.Delay <= 0 {
+				return fmt.Errorf("fault %d (%s): for and delay must be positive", i, f.Kind)
+			}
+		case KindStorm:
+			if f.Count == 0 {
+				f.Count = 1000
+			}
+		case KindMalformed:
+			if f.Count == 0 {
+				f.Count = 1
+			}
+		default:
+			return fmt.Errorf("fault %d: unknown kind %q (want disconnect, storm, malformed, slow_consumer or transport)", i, f.Kind)
+		}
+		if f.Count < 0 {
+			return fmt.Errorf("fault %d (%s): negative count", i, f.Kind)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+// Package clock abstracts the passage of time so tests and the replay
+// engine can drive it. Code that reads the time, waits or ticks takes a
+// Clock; production passes Real, tests a Virtual they advance by hand
+// instead of sleeping.
+package clock
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the time source.
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	// After delivers the time on the channel once d has passed.
+	After(d time.Duration) <-chan time.Time
+	Sleep(d time.Duration)
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers ticks every period, dropping them for a slow reader as
+// time.Ticker does.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Real is the wall clock.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) NewTicker(d time.Duration) Ticker       { return realTicker{time.NewTicker(d)} }
+
+type realTicker struct{ t *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.t.C }
+func (t realTicker) Stop()               { t.t.Stop() }
+
+// Or is c, or Real when c is nil, for optional Clock fields.
+func Or(c Clock) Clock {
+	if c == nil {
+		return Real
+	}
+	return c
+}
+
+// SleepCtx waits d on c and reports false if ctx ended first.
+func SleepCtx(ctx context.Context, c Clock, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-c.After(d):
+		return true
+	}
+}
+
+// Virtual is a Clock that only moves when told to. Timers and tickers fire
+// during Advance, in deadline order, each delivering its own deadline.
+type Virtual struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	now     time.Time
+	waiters []*waiter
+}
+
+type waiter struct {
+	at     time.Time
+	period time.Duration
+	ch     chan time.Time
+}
+
+func NewVirtual(start time.Time) *Virtual {
+	v := &Virtual{now: start}
+	v.changed = sync.NewCond(&v.mu)
+	return v
+}
+
+func (v *Virtual) Now() time.Time {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.now
+}
+
+func (v *Virtual) Since(t time.Time) time.Duration { return v.Now().Sub(t) }
+
+func (v *Virtual) After(d time.Duration) <-chan time.Time {
+	return v.add(d, 0).ch
+}
+
+// Sleep blocks until another goroutine advances the clock past d.
+func (v *Virtual) Sleep(d time.Duration) { <-v.After(d) }
+
+func (v *Virtual) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive ticker period")
+	}
+	return &virtualTicker{v: v, w: v.add(d, d)}
+}
+
+func (v *Virtual) add(d, period time.Duration) *waiter {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	w := &waiter{at: v.now.Add(d), period: period, ch: make(chan time.Time, 1)}
+	if d <= 0 && period == 0 {
+		w.ch <- v.now
+		return w
+	}
+	v.waiters = append(v.waiters, w)
+	v.changed.Broadcast()
+	return w
+}
+
+// Advance moves the clock forward by d, firing what falls due.
+func (v *Virtual) Advance(d time.Duration) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	end := v.now.Add(d)
+	for {
+		sort.SliceStable(v.waiters, func(i, j int) bool { return v.waiters[i].at.Before(v.waiters[j].at) })
+		if len(v.waiters) == 0 || v.waiters[0].at.After(end) {
+			break
+		}
+		w := v.waiters[0]
+		v.now = w.at
+		select {
+		case w.ch <- w.at:
+		default:
+		}
+		if w.period > 0 {
+			w.at = w.at.Add(w.period)
+		} else {
+			v.waiters = v.waiters[1:]
+		}
+	}
+	v.now = end
+	v.changed.Broadcast()
+}
+
+// Waiters is the number of pending timers and tickers.
+func (v *Virtual) Waiters() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return len(v.waiters)
+}
+
+// BlockUntil waits until at least n timers and tickers are pending, so a
+// test advances only once the code under test is waiting.
+func (v *Virtual) BlockUntil(n int) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for len(v.waiters) < n {
+		v.changed.Wait()
+	}
+}
+
+func (v *Virtual) remove(w *waiter) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for i, x := range v.waiters {
+		if x == w {
+			v.waiters = append(v.waiters[:i], v.waiters[i+1:]...)
+			break
+		}
+	}
+	v.changed.Broadcast()
+}
+
+type virtualTicker struct {
+	v *Virtual
+	w *waiter
+}
+
+func (t *virtualTicker) C() <-chan time.Time { return t.w.ch }
+func (t *virtualTicker) Stop()               { t.v.remove(t.w) }
@@ -0,0 +1,699 @@
+// Package config loads the gateway's venue, symbol, fee, transport and risk
+// settings from config/gateway.yaml (or an equivalent JSON file) and
+// validates them before anything connects.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/secrets"
+	"github.com/helix-lab/helix/gateway/pkg/ws"
+)
+
+// File is the on-disk layout; everything lives under the "gateway" key so
+// the file can sit next to engine.yaml and strategy.yaml.
+type File struct {
+	Gateway Gateway `json:"gateway"`
+}
+
+type Gateway struct {
+	// Symbols is the default symbol list for venues that don't set their own.
+	Symbols         []string `json:"symbols"`
+	PublishEndpoint string   `json:"publish_endpoint"`
+	Router          Router   `json:"router"`
+	Venues          []Venue  `json:"venues"`
+	Risk            Risk     `json:"risk"`
+	// Strategies are hosted in the gateway process, in this order.
+	Strategies []Strategy `json:"strategies"`
+	// Shadows run candidate strategy versions in paper mode next to the
+	// production strategies they would replace.
+	Shadows []Shadow `json:"shadows"`
+	// Clients may run strategies in their own processes over the strategy
+	// API (-strategy_api_addr).
+	Clients []Client `json:"clients"`
+	// FIXClients may enter orders over FIX 4.4 (-fix_addr).
+	FIXClients []FIXClient `json:"fix_clients"`
+	// DropCopies receive copies of executions over FIX
+	// (-fix_dropcopy_addr).
+	DropCopies []DropCopy `json:"drop_copies"`
+	// Testnet points bybit venues at Bybit's testnet by default, refuses
+	// any that point elsewhere, and marks every order sent and stored as
+	// testnet. Everything else runs as live.
+	Testnet bool `json:"testnet"`
+	// BaseCurrency is what the portfolio, risk engine and reports value
+	// notional, fees and PnL in, converted at the venues' own spot rates.
+	// It defaults to USDT.
+	BaseCurrency string `json:"base_currency"`
+	// Recorder holds defaults for "helix record" and "helix gc".
+	Recorder Recorder `json:"recorder"`
+	// Backtest holds defaults for "helix backtest".
+	Backtest Backtest `json:"backtest"`
+}
+
+// Backtest settings.
+type Backtest struct {
+	// LatencyProfiles add to or redefine the built-in tiers (colo, cloud,
+	// retail) -profile and -profile_sweep pick from.
+	LatencyProfiles []LatencyProfile `json:"latency_profiles"`
+}
+
+// LatencyProfile is a named latency tier: Latency to every venue but those
+// in Venues.
+type LatencyProfile struct {
+	Name    string              `json:"name"`
+	Latency Duration            `json:"latency"`
+	Venues  map[string]Duration `json:"venues"`
+}
+
+// Recorder settings; flags given on the command line win.
+type Recorder struct {
+	// Columns picks the L2 recorder's CSV columns (see its -columns).
+	Columns []string `json:"columns"`
+	// Retention is the policy "helix gc" ages captures out by.
+	Retention Retention `json:"retention"`
+}
+
+// Retention keeps captures for KeepDays (0 = forever) and within MaxGB
+// (0 = no limit), removing only validated ones and, with Uploaded, only
+// those recorded as uploaded. Fields left out keep gc's flag defaults.
+type Retention struct {
+	KeepDays  *float64 `json:"keep_days"`
+	MaxGB     *float64 `json:"max_gb"`
+	Validated *bool    `json:"validated"`
+	Uploaded  *bool    `json:"uploaded"`
+}
+
+type Router struct {
+	Backpressure string `json:"backpressure"`
+	MaxBacklog   int    `json:"max_backlog"`
+	SimSeed      int64  `json:"sim_seed"`
+	// Shards processes depth for different symbols on this many independent
+	// goroutines, from the router through book apply and strategy callbacks.
+	Shards int `json:"shards"`
+}
+
+// Venue kinds.
+const (
+	KindSim   = "sim"
+	KindBybit = "bybit"
+	// KindHelix bridges a venue from another gateway's -bridge feed at
+	// ws_public, authorized by the api_key of credentials.
+	KindHelix = "helix"
+)
+
+// Bybit's default endpoints; the websocket ones take the category.
+const (
+	BybitWS          = "wss://stream.bybit.com/v5/public/"
+	BybitREST        = "https://api.bybit.com"
+	BybitTestnetWS   = "wss://stream-testnet.bybit.com/v5/public/"
+	BybitTestnetREST = "https://api-testnet.bybit.com"
+)
+
+// Instrument categories, named as Bybit does.
+const (
+	CategorySpot    = "spot"
+	CategoryLinear  = "linear"
+	CategoryInverse = "inverse"
+	CategoryOption  = "option"
+)
+
+var categories = []string{CategorySpot, CategoryLinear, CategoryInverse, CategoryOption}
+
+// CategoryOf guesses the category from a Bybit-style endpoint path
+// (".../v5/public/spot"), defaulting to linear.
+func CategoryOf(endpoint string) string {
+	if c := path.Base(endpoint); contains(categories, c) {
+		return c
+	}
+	return CategoryLinear
+}
+
+// TestnetURL reports whether u is a testnet host, or a local one such as
+// the mock exchange.
+func TestnetURL(u string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	host := parsed.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.IsLoopback()
+	}
+	return host == "localhost" || strings.Contains(host, "testnet")
+}
+
+// Venue is one connector. To trade several categories on one exchange, list
+// it once per category under distinct names, e.g. BYBIT and BYBIT_SPOT.
+type Venue struct {
+	Name string `json:"name"`
+	// Kind selects the connector: "bybit" (live public stream), "helix"
+	// (another gateway's bridge) or "sim".
+	Kind string `json:"kind"`
+	// Upstream is the bridged venue's name on a helix venue's gateway
+	// (default: Name).
+	Upstream string `json:"upstream"`
+	// Category is the market the symbols belong to; for bybit venues it also
+	// picks the default ws_public endpoint.
+	Category string `json:"category"`
+	WSPublic string `json:"ws_public"`
+	// REST is the venue's HTTP API base, used for account polling and to
+	// recover the book from a snapshot after a sequence gap.
+	REST         string   `json:"rest"`
+	Symbols      []string `json:"symbols"`
+	Depth        int      `json:"depth"`
+	Trades       bool     `json:"trades"`
+	Liquidations bool     `json:"liquidations"`
+	Funding      bool     `json:"funding"`
+	// BookEvents derives level added/removed/size-changed and BBO
+	// improved/worsened events from bybit venues' books.
+	BookEvents bool `json:"book_events"`
+	// Credentials names where the default account's API key lives
+	// ("env:PREFIX", "file:/path[#account]" or "cmd:program args"; see
+	// package secrets). Accounts adds named accounts the same way. Secrets
+	// themselves never go in this file.
+	Credentials string                    `json:"credentials"`
+	Accounts    map[string]string         `json:"accounts"`
+	Fees        Fees                      `json:"fees"`
+	PerSymbol   map[string]SymbolSettings `json:"symbol_settings"`
+	// RateLimit paces the venue's REST API until the rate-limit headers
+	// of its responses say otherwise.
+	RateLimit RateLimit `json:"rate_limit"`
+}
+
+// RateLimit is per second; 0 leaves the pace to the venue's headers.
+type RateLimit struct {
+	OrdersPerSec   float64 `json:"orders_per_sec"`
+	RequestsPerSec float64 `json:"requests_per_sec"`
+}
+
+type Fees struct {
+	MakerBps float64 `json:"maker_bps"`
+	TakerBps float64 `json:"taker_bps"`
+}
+
+// SymbolSettings override venue defaults for one symbol.
+type SymbolSettings struct {
+	Depth        int     `json:"depth"`
+	MaxOrderSize float64 `json:"max_order_size"`
+}
+
+// Risk limits; 0 is unlimited. MaxPosition and MaxOrderSize are checked
+// per venue by the executor, the rest by the risk engine, with
+// MaxNotional capping gross notional across venues.
+type Risk struct {
+	MaxPosition       float64 `json:"max_position"`
+	MaxNotional       float64 `json:"max_notional"`
+	MaxOrderSize      float64 `json:"max_order_size"`
+	MaxSymbolPosition float64 `json:"max_symbol_position"`
+	MaxVenueNotional  float64 `json:"max_venue_notional"`
+	MaxDailyLoss      float64 `json:"max_daily_loss"`
+	MaxOrdersPerSec   int     `json:"max_orders_per_sec"`
+	MaxOrdersPerMin   int     `json:"max_orders_per_min"`
+}
+
+// Strategy configures one embedded strategy instance.
+type Strategy struct {
+	Name string `json:"name"`
+	// Kind names a registered strategy implementation, e.g. "demo".
+	Kind string `json:"kind"`
+	// Symbols limits the books the strategy sees (empty = all).
+	Symbols []string `json:"symbols"`
+	// Timer is the OnTimer interval (0 = no timer).
+	Timer  Duration       `json:"timer"`
+	Params map[string]any `json:"params"`
+	Quota  Quota          `json:"quota"`
+}
+
+// Shadow is a candidate strategy: it sees the same market data as the
+// production strategy Of but trades against simulated fills, and both
+// their decisions are compared (-shadow_out, -shadow_report).
+type Shadow struct {
+	Strategy
+	// Of names the gateway.strategies entry the candidate would replace.
+	Of string `json:"of"`
+}
+
+// Client is one external strategy process. It is hosted like an embedded
+// strategy under Name, so names are shared with gateway.strategies.
+type Client struct {
+	Name string `json:"name"`
+	// TokenEnv names the environment variable holding its bearer token.
+	TokenEnv string     `json:"token_env"`
+	Symbols  []string   `json:"symbols"`
+	Risk     ClientRisk `json:"risk"`
+	Quota    Quota      `json:"quota"`
+}
+
+// FIXClient is one FIX order entry counterparty, hosted like a strategy
+// under CompID.
+type FIXClient struct {
+	CompID string `json:"comp_id"`
+	// PasswordEnv names the environment variable holding its logon password.
+	PasswordEnv string     `json:"password_env"`
+	Symbols     []string   `json:"symbols"`
+	Risk        ClientRisk `json:"risk"`
+}
+
+// DropCopy is one FIX drop copy endpoint, e.g. compliance or a
+// reconciliation system.
+type DropCopy struct {
+	CompID      string `json:"comp_id"`
+	PasswordEnv string `json:"password_env"`
+	// Accounts are the strategies and clients whose orders it is copied
+	// (empty = all).
+	Accounts []string `json:"accounts"`
+}
+
+// ClientRisk is checked before gateway.risk; zero is unlimited.
+type ClientRisk struct {
+	MaxPosition     float64 `json:"max_position"`
+	MaxNotional     float64 `json:"max_notional"`
+	MaxOrderSize    float64 `json:"max_order_size"`
+	MaxOrdersPerSec int     `json:"max_orders_per_sec"`
+}
+
+// Quota is one strategy's or client's share of the gateway, measured on
+// its own orders and fills; zero is unlimited.
+type Quota struct {
+	MaxOrdersPerSec int     `json:"max_orders_per_sec"`
+	MaxOpenNotional float64 `json:"max_open_notional"`
+	MaxPosition     float64 `json:"max_position"`
+}
+
+// Duration reads "250ms"-style strings (or nanoseconds as a number).
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch x := v.(type) {
+	case float64:
+		*d = Duration(x)
+	case string:
+		p, err := time.ParseDuration(x)
+		if err != nil {
+			return err
+		}
+		*d = Duration(p)
+	default:
+		return fmt.Errorf("duration must be a string like \"1s\", got %s", b)
+	}
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Default mirrors the built-in demo: two synthetic venues on BTCUSDT.
+func Default() *File {
+	f := &File{Gateway: Gateway{
+		Symbols:         []string{"BTCUSDT"},
+		PublishEndpoint: "tcp://*:6001",
+		Router:          Router{Backpressure: "block", MaxBacklog: 1024},
+		Venues: []Venue{
+			{Name: "BYBIT", Kind: KindSim, Fees: Fees{TakerBps: 6}},
+			{Name: "BINANCE", Kind: KindSim, Fees: Fees{TakerBps: 5}},
+		},
+	}}
+	f.applyDefaults()
+	return f
+}
+
+// Load reads path (YAML, or JSON for *.json), fills defaults and validates.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := Parse(data, strings.EqualFold(filepath.Ext(path), ".json"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Parse decodes a config document; unknown keys are errors so typos don't
+// silently fall back to defaults.
+func Parse(data []byte, isJSON bool) (*File, error) {
+	if !isJSON {
+		tree, err := decodeYAML(data)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = json.Marshal(tree); err != nil {
+			return nil, err
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	cfg := &File{}
+	if err := dec.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	cfg.applyDefaults()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (f *File) applyDefaults() {
+	g := &f.Gateway
+	if g.PublishEndpoint == "" {
+		g.PublishEndpoint = "tcp://*:6001"
+	}
+	if g.Router.Backpressure == "" {
+		g.Router.Backpressure = "block"
+	}
+	if g.Router.MaxBacklog == 0 {
+		g.Router.MaxBacklog = 1024
+	}
+	g.BaseCurrency = strings.ToUpper(g.BaseCurrency)
+	if g.BaseCurrency == "" {
+		g.BaseCurrency = "USDT"
+	}
+	for i := range g.Venues {
+		v := &g.Venues[i]
+		v.Name = strings.ToUpper(v.Name)
+		if v.Kind == "" {
+			v.Kind = KindSim
+		}
+		v.Category = strings.ToLower(v.Category)
+		if v.Category == "" {
+			v.Category = CategoryOf(v.WSPublic)
+		}
+		stream, rest := BybitWS, BybitREST
+		if g.Testnet {
+			stream, rest = BybitTestnetWS, BybitTestnetREST
+		}
+		if v.Kind == KindBybit && v.WSPublic == "" {
+			v.WSPublic = stream + v.Category
+		}
+		if v.Kind == KindBybit && v.REST == "" {
+			v.REST = rest
+		}
+		if v.Kind == KindHelix && v.Upstream == "" {
+			v.Upstream = v.Name
+		}
+		v.Upstream = strings.ToUpper(v.Upstream)
+		if len(v.Symbols) == 0 {
+			v.Symbols = g.Symbols
+		}
+		if v.Depth == 0 {
+			v.Depth = 1
+		}
+	}
+}
+
+// Validate reports every problem at once, each prefixed with its path.
+func (f *File) Validate() error {
+	var errs []error
+	bad := func(path, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+	}
+	g := f.Gateway
+	if _, err := ws.ParsePolicy(g.Router.Backpressure); err != nil {
+		bad("gateway.router.backpressure", "%v (want block, conflate, drop_oldest or grow_bounded)", err)
+	}
+	if g.Router.MaxBacklog < 0 {
+		bad("gateway.router.max_backlog", "must be positive, got %d", g.Router.MaxBacklog)
+	}
+	if g.Router.Shards < 0 {
+		bad("gateway.router.shards", "must be positive, got %d", g.Router.Shards)
+	}
+	if len(g.Venues) == 0 {
+		bad("gateway.venues", "at least one venue is required")
+	}
+	for i, lp := range g.Backtest.LatencyProfiles {
+		p := fmt.Sprintf("gateway.backtest.latency_profiles[%d]", i)
+		if lp.Name == "" {
+			bad(p+".name", "required")
+		}
+		if lp.Latency < 0 {
+			bad(p+".latency", "must be positive, got %s", time.Duration(lp.Latency))
+		}
+		for v, d := range lp.Venues {
+			if d < 0 {
+				bad(p+".venues."+v, "must be positive, got %s", time.Duration(d))
+			}
+		}
+	}
+	seen := map[string]bool{}
+	for i, v := range g.Venues {
+		p := fmt.Sprintf("gateway.venues[%d]", i)
+		if v.Name == "" {
+			bad(p+".name", "required")
+		} else if seen[v.Name] {
+			bad(p+".name", "duplicate venue %q", v.Name)
+		}
+		seen[v.Name] = true
+		if !contains(categories, v.Category) {
+			bad(p+".category", "unknown category %q (want %s)", v.Category, strings.Join(categories, ", "))
+		}
+		switch v.Kind {
+		case KindSim:
+		case KindBybit:
+			if c := path.Base(v.WSPublic); contains(categories, c) && c != v.Category {
+				bad(p+".ws_public", "endpoint serves %s but the venue's category is %s", c, v.Category)
+			}
+			if !strings.HasPrefix(v.WSPublic, "ws://") && !strings.HasPrefix(v.WSPublic, "wss://") {
+				bad(p+".ws_public", "bybit venues need a ws:// or wss:// endpoint, got %q", v.WSPublic)
+			}
+			if g.Testnet && !TestnetURL(v.WSPublic) {
+				bad(p+".ws_public", "gateway.testnet is set but %q is not a testnet endpoint", v.WSPublic)
+			}
+			if g.Testnet && !TestnetURL(v.REST) {
+				bad(p+".rest", "gateway.testnet is set but %q is not a testnet endpoint", v.REST)
+			}
+		case KindHelix:
+			if !strings.HasPrefix(v.WSPublic, "ws://") && !strings.HasPrefix(v.WSPublic, "wss://") {
+				bad(p+".ws_public", "helix venues need the upstream gateway's ws:// or wss:// bridge endpoint, got %q", v.WSPublic)
+			}
+			if v.Credentials == "" {
+				bad(p+".credentials", "helix venues need the upstream gateway's bridge token")
+			}
+		default:
+			bad(p+".kind", "unknown kind %q (want %s, %s or %s)", v.Kind, KindBybit, KindHelix, KindSim)
+		}
+		if len(v.Symbols) == 0 {
+			bad(p+".symbols", "no symbols (set gateway.symbols or the venue's own list)")
+		}
+		for j, s := range v.Symbols {
+			if s == "" || strings.ToUpper(s) != s || strings.ContainsAny(s, " ,") {
+				bad(fmt.Sprintf("%s.symbols[%d]", p, j), "symbols are upper-case without spaces, got %q", s)
+			}
+		}
+		if v.Depth < 0 {
+			bad(p+".depth", "must be positive, got %d", v.Depth)
+		}
+		if v.RateLimit.OrdersPerSec < 0 || v.RateLimit.RequestsPerSec < 0 {
+			bad(p+".rate_limit", "must be positive, got orders_per_sec=%g requests_per_sec=%g", v.RateLimit.OrdersPerSec, v.RateLimit.RequestsPerSec)
+		}
+		if v.Fees.MakerBps < -100 || v.Fees.MakerBps > 100 || v.Fees.TakerBps < 0 || v.Fees.TakerBps > 100 {
+			bad(p+".fees", "bps out of range: maker=%g taker=%g", v.Fees.MakerBps, v.Fees.TakerBps)
+		}
+		if c := v.Credentials; c != "" {
+			if _, err := secrets.Parse(c); err != nil {
+				bad(p+".credentials", "must be a reference (env:PREFIX, file:/path or cmd:program), not a literal secret")
+			}
+		}
+		for name, ref := range v.Accounts {
+			if _, err := secrets.Parse(ref); err != nil {
+				bad(fmt.Sprintf("%s.accounts.%s", p, name), "must be a reference (env:PREFIX, file:/path or cmd:program), not a literal secret")
+			}
+		}
+		for sym, s := range v.PerSymbol {
+			if !contains(v.Symbols, sym) {
+				bad(fmt.Sprintf("%s.symbol_settings.%s", p, sym), "symbol is not subscribed on this venue")
+			}
+			if s.Depth < 0 || s.MaxOrderSize < 0 {
+				bad(fmt.Sprintf("%s.symbol_settings.%s", p, sym), "depth and max_order_size must be positive")
+			}
+		}
+	}
+	names := map[string]bool{}
+	all := g.AllSymbols()
+	for i, st := range g.Strategies {
+		p := fmt.Sprintf("gateway.strategies[%d]", i)
+		if st.Name == "" {
+			bad(p+".name", "required")
+		} else if names[st.Name] {
+			bad(p+".name", "duplicate strategy %q", st.Name)
+		}
+		names[st.Name] = true
+		if st.Kind == "" {
+			bad(p+".kind", "required")
+		}
+		for j, s := range st.Symbols {
+			if !contains(all, s) {
+				bad(fmt.Sprintf("%s.symbols[%d]", p, j), "%q is not subscribed on any venue", s)
+			}
+		}
+		if st.Timer < 0 {
+			bad(p+".timer", "must be positive")
+		}
+	}
+	production := map[string]bool{}
+	for _, st := range g.Strategies {
+		production[st.Name] = true
+	}
+	for i, sh := range g.Shadows {
+		p := fmt.Sprintf("gateway.shadows[%d]", i)
+		if sh.Name == "" {
+			bad(p+".name", "required")
+		} else if names[sh.Name] {
+			bad(p+".name", "duplicate strategy %q", sh.Name)
+		}
+		names[sh.Name] = true
+		if sh.Kind == "" {
+			bad(p+".kind", "required")
+		}
+		if !production[sh.Of] {
+			bad(p+".of", "%q is not one of gateway.strategies", sh.Of)
+		}
+		for j, s := range sh.Symbols {
+			if !contains(all, s) {
+				bad(fmt.Sprintf("%s.symbols[%d]", p, j), "%q is not subscribed on any venue", s)
+			}
+		}
+		if sh.Timer < 0 {
+			bad(p+".timer", "must be positive")
+		}
+	}
+	for i, c := range g.Clients {
+		p := fmt.Sprintf("gateway.clients[%d]", i)
+		if c.Name == "" {
+			bad(p+".name", "required")
+		} else if names[c.Name] {
+			bad(p+".name", "duplicate strategy or client %q", c.Name)
+		}
+		names[c.Name] = true
+		if c.TokenEnv == "" {
+			bad(p+".token_env", "required")
+		}
+		for j, s := range c.Symbols {
+			if !contains(all, s) {
+				bad(fmt.Sprintf("%s.symbols[%d]", p, j), "%q is not subscribed on any venue", s)
+			}
+		}
+		r := c.Risk
+		if r.MaxPosition < 0 || r.MaxNotional < 0 || r.MaxOrderSize < 0 || r.MaxOrdersPerSec < 0 {
+			bad(p+".risk", "limits must be positive (0 = unlimited)")
+		}
+	}
+	for i, c := range g.FIXClients {
+		p := fmt.Sprintf("gateway.fix_clients[%d]", i)
+		if c.CompID == "" {
+			bad(p+".comp_id", "required")
+		} else if names[c.CompID] {
+			bad(p+".comp_id", "duplicate strategy or client %q", c.CompID)
+		}
+		names[c.CompID] = true
+		if c.PasswordEnv == "" {
+			bad(p+".password_env", "required")
+		}
+		for j, s := range c.Symbols {
+			if !contains(all, s) {
+				bad(fmt.Sprintf("%s.symbols[%d]", p, j), "%q is not subscribed on any venue", s)
+			}
+		}
+		r := c.Risk
+		if r.MaxPosition < 0 || r.MaxNotional < 0 || r.MaxOrderSize < 0 || r.MaxOrdersPerSec < 0 {
+			bad(p+".risk", "limits must be positive (0 = unlimited)")
+		}
+	}
+	copies := map[string]bool{}
+	for i, d := range g.DropCopies {
+		p := fmt.Sprintf("gateway.drop_copies[%d]", i)
+		if d.CompID == "" {
+			bad(p+".comp_id", "required")
+		} else if copies[d.CompID] {
+			bad(p+".comp_id", "duplicate drop copy %q", d.CompID)
+		}
+		copies[d.CompID] = true
+		if d.PasswordEnv == "" {
+			bad(p+".password_env", "required")
+		}
+		for j, a := range d.Accounts {
+			if !names[a] {
+				bad(fmt.Sprintf("%s.accounts[%d]", p, j), "%q is not a strategy or client", a)
+			}
+		}
+	}
+	if r := g.Risk; r.MaxPosition < 0 || r.MaxNotional < 0 || r.MaxOrderSize < 0 || r.MaxSymbolPosition < 0 ||
+		r.MaxVenueNotional < 0 || r.MaxDailyLoss < 0 || r.MaxOrdersPerSec < 0 || r.MaxOrdersPerMin < 0 {
+		bad("gateway.risk", "limits must be positive (0 = unlimited)")
+	}
+	return errors.Join(errs...)
+}
+
+// SymbolDepth returns the book depth to subscribe for sym on v.
+func (v Venue) SymbolDepth(sym string) int {
+	if s, ok := v.PerSymbol[sym]; ok && s.Depth > 0 {
+		return s.Depth
+	}
+	return v.Depth
+}
+
+// CredentialRef returns the credential reference for account on v; ""
+// selects the default account.
+func (v Venue) CredentialRef(account string) (string, error) {
+	if account == "" {
+		if v.Credentials == "" {
+			return "", fmt.Errorf("venue %s has no credentials", v.Name)
+		}
+		return v.Credentials, nil
+	}
+	if ref, ok := v.Accounts[account]; ok {
+		return ref, nil
+	}
+	return "", fmt.Errorf("venue %s has no account %q", v.Name, account)
+}
+
+// MaxOrderSize is the order size limit for sym on the named venue: its
+// symbol_settings entry, else the global risk limit (0 = unlimited).
+func (g Gateway) MaxOrderSize(venue, sym string) float64 {
+	for _, v := range g.Venues {
+		if v.Name == venue {
+			if s, ok := v.PerSymbol[sym]; ok && s.MaxOrderSize > 0 {
+				return s.MaxOrderSize
+			}
+		}
+	}
+	return g.Risk.MaxOrderSize
+}
+
+// AllSymbols is the union of every venue's symbols, in first-seen order.
+func (g Gateway) AllSymbols() []string {
+	var out []string
+	for _, v := range g.Venues {
+		for _, s := range v.Symbols {
+			if !contains(out, s) {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
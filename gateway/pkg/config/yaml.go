@@ -0,0 +1,299 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The gateway only needs the block-style subset of YAML that config/*.yaml
+// use: nested maps, "- " sequences (of scalars or maps), [a, b] and
+// {k: v} flow collections of scalars, quoted strings and # comments.
+// Anchors, multi-line scalars and multiple documents are rejected.
+
+type yamlLine struct {
+	indent int
+	text   string
+	no     int
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func decodeYAML(data []byte) (any, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(string(data), "\n") {
+		raw = strings.TrimRight(raw, "\r")
+		text := strings.TrimRight(stripComment(raw), " \t")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.Contains(text[:len(text)-len(strings.TrimLeft(text, " \t"))], "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		for _, bad := range []string{"&", "*", "|", ">", "!"} {
+			if strings.HasPrefix(trimmed, bad) {
+				return nil, fmt.Errorf("line %d: unsupported YAML construct %q", i+1, bad)
+			}
+		}
+		p.lines = append(p.lines, yamlLine{indent: len(text) - len(trimmed), text: trimmed, no: i + 1})
+	}
+	if len(p.lines) == 0 {
+		return map[string]any{}, nil
+	}
+	v, err := p.block(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		return nil, fmt.Errorf("line %d: unexpected indentation", l.no)
+	}
+	return v, nil
+}
+
+func (p *yamlParser) block(indent int) (any, error) {
+	if strings.HasPrefix(p.lines[p.pos].text, "-") && isSeqItem(p.lines[p.pos].text) {
+		return p.seq(indent)
+	}
+	return p.mapping(indent)
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) mapping(indent int) (map[string]any, error) {
+	out := map[string]any{}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent < indent {
+			break
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", l.no)
+		}
+		if isSeqItem(l.text) {
+			break
+		}
+		key, rest, ok := splitKey(l.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\", got %q", l.no, l.text)
+		}
+		if _, dup := out[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", l.no, key)
+		}
+		p.pos++
+		if rest != "" {
+			v, err := scalarOrFlow(rest, l.no)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = v
+			continue
+		}
+		// nested block: deeper indentation, or a sequence at the same level
+		if p.pos < len(p.lines) {
+			next := p.lines[p.pos]
+			if next.indent > indent || (next.indent == indent && isSeqItem(next.text)) {
+				v, err := p.block(next.indent)
+				if err != nil {
+					return nil, err
+				}
+				out[key] = v
+				continue
+			}
+		}
+		out[key] = nil
+	}
+	return out, nil
+}
+
+func (p *yamlParser) seq(indent int) ([]any, error) {
+	var out []any
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent != indent || !isSeqItem(l.text) {
+			if l.indent > indent {
+				return nil, fmt.Errorf("line %d: unexpected indentation", l.no)
+			}
+			break
+		}
+		item := strings.TrimLeft(strings.TrimPrefix(l.text, "-"), " ")
+		if item == "" {
+			p.pos++
+			if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+				v, err := p.block(p.lines[p.pos].indent)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, v)
+			} else {
+				out = append(out, nil)
+			}
+			continue
+		}
+		if _, _, ok := splitKey(item); ok && !strings.HasPrefix(item, "{") && !strings.HasPrefix(item, "[") && !isQuoted(item) {
+			// "- key: value" opens a map whose keys sit after the dash
+			p.lines[p.pos] = yamlLine{indent: indent + len(l.text) - len(item), text: item, no: l.no}
+			v, err := p.mapping(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+			continue
+		}
+		v, err := scalarOrFlow(item, l.no)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+		p.pos++
+	}
+	return out, nil
+}
+
+// splitKey splits "key: rest" on the first ": " (or trailing ":") outside quotes.
+func splitKey(text string) (key, rest string, ok bool) {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ':' && (i == len(text)-1 || text[i+1] == ' '):
+			key = strings.TrimSpace(text[:i])
+			if k, err := unquote(key); err == nil {
+				key = k
+			}
+			return key, strings.TrimSpace(text[i+1:]), key != ""
+		}
+	}
+	return "", "", false
+}
+
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+func isQuoted(s string) bool {
+	return len(s) >= 2 && (s[0] == '"' && s[len(s)-1] == '"' || s[0] == '\'' && s[len(s)-1] == '\'')
+}
+
+func unquote(s string) (string, error) {
+	switch {
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		return strconv.Unquote(s)
+	case len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'':
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	return s, fmt.Errorf("not quoted")
+}
+
+func scalarOrFlow(s string, line int) (any, error) {
+	switch {
+	case strings.HasPrefix(s, "["):
+		if !strings.HasSuffix(s, "]") {
+			return nil, fmt.Errorf("line %d: unterminated flow sequence", line)
+		}
+		out := []any{}
+		for _, part := range splitFlow(s[1 : len(s)-1]) {
+			v, err := scalar(part, line)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	case strings.HasPrefix(s, "{"):
+		if !strings.HasSuffix(s, "}") {
+			return nil, fmt.Errorf("line %d: unterminated flow mapping", line)
+		}
+		out := map[string]any{}
+		for _, part := range splitFlow(s[1 : len(s)-1]) {
+			k, rest, ok := splitKey(part)
+			if !ok {
+				return nil, fmt.Errorf("line %d: expected \"key: value\" in %q", line, part)
+			}
+			v, err := scalar(rest, line)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = v
+		}
+		return out, nil
+	}
+	return scalar(s, line)
+}
+
+func splitFlow(s string) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		parts = append(parts, last)
+	}
+	return parts
+}
+
+func scalar(s string, line int) (any, error) {
+	if isQuoted(s) {
+		v, err := unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad quoted string %s", line, s)
+		}
+		return v, nil
+	}
+	switch s {
+	case "|", ">", "|-", ">-":
+		return nil, fmt.Errorf("line %d: multi-line scalars are not supported", line)
+	case "", "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f, nil
+	}
+	return s, nil
+}
@@ -0,0 +1,226 @@
+// Package decimal is the fixed-point representation of prices and
+// quantities. Venues quote decimal strings; parsed into float64 and used as
+// map keys or compared with ==, they make level deletion and queue
+// matching depend on two computations rounding the same way. A Decimal is
+// an integer count of 1e-8 units, so "0.10" and "0.1" are the same value
+// and comparisons are exact. Convert at the venue boundary and call Float
+// only for arithmetic that is approximate anyway (fees, PnL, averages).
+package decimal
+
+import (
+	"errors"
+	"math"
+	"strconv"
+)
+
+// Places is the number of decimal places kept.
+const Places = 8
+
+// Scale is 10^Places: the Decimal of 1.
+const Scale Decimal = 100_000_000
+
+// Decimal is a fixed-point number with Places decimals. Its range is about
+// ±9.2e10.
+type Decimal int64
+
+var (
+	ErrSyntax    = errors.New("decimal: invalid syntax")
+	ErrRange     = errors.New("decimal: value out of range")
+	ErrPrecision = errors.New("decimal: more than 8 decimal places")
+)
+
+// Parse reads a decimal string such as "65000.5", "-0.001" or "1e-8".
+// Digits beyond Places must be zeros.
+func Parse(s string) (Decimal, error) { return parse(s) }
+
+// ParseBytes is Parse without allocating, for frames being decoded.
+func ParseBytes(b []byte) (Decimal, error) { return parse(b) }
+
+func parse[T string | []byte](s T) (Decimal, error) {
+	i, neg := 0, false
+	if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+		neg = s[0] == '-'
+		i++
+	}
+	var mant uint64
+	// frac counts fraction digits folded into mant; fractional zeros wait
+	// in zeros until a non-zero digit follows, so trailing ones cost nothing
+	frac, zeros := 0, 0
+	dot, digits := false, false
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == '.' && !dot {
+			dot = true
+			continue
+		}
+		if c < '0' || c > '9' {
+			break
+		}
+		digits = true
+		if dot && c == '0' {
+			zeros++
+			continue
+		}
+		for ; zeros > 0; zeros-- {
+			if !mul10(&mant, 0) {
+				return 0, ErrRange
+			}
+			frac++
+		}
+		if !mul10(&mant, uint64(c-'0')) {
+			return 0, ErrRange
+		}
+		if dot {
+			frac++
+		}
+	}
+	if !digits {
+		return 0, ErrSyntax
+	}
+	exp := 0
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		eneg := false
+		if i < len(s) && (s[i] == '-' || s[i] == '+') {
+			eneg = s[i] == '-'
+			i++
+		}
+		start := i
+		for ; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+			if exp = exp*10 + int(s[i]-'0'); exp > 100 {
+				return 0, ErrRange
+			}
+		}
+		if i == start {
+			return 0, ErrSyntax
+		}
+		if eneg {
+			exp = -exp
+		}
+	}
+	if i != len(s) {
+		return 0, ErrSyntax
+	}
+	for shift := Places - frac + exp; shift != 0; {
+		if shift > 0 {
+			if mant != 0 && !mul10(&mant, 0) {
+				return 0, ErrRange
+			}
+			shift--
+			continue
+		}
+		if mant%10 != 0 {
+			return 0, ErrPrecision
+		}
+		mant /= 10
+		shift++
+	}
+	if neg {
+		return -Decimal(mant), nil
+	}
+	return Decimal(mant), nil
+}
+
+// mul10 sets *m = *m*10 + d unless that leaves the int64 range.
+func mul10(m *uint64, d uint64) bool {
+	if *m > (math.MaxInt64-d)/10 {
+		return false
+	}
+	*m = *m*10 + d
+	return true
+}
+
+// FromFloat rounds f to the nearest Decimal. Values out of range saturate.
+func FromFloat(f float64) Decimal {
+	v := math.Round(f * float64(Scale))
+	switch {
+	case v >= math.MaxInt64:
+		return math.MaxInt64
+	case v <= math.MinInt64:
+		return math.MinInt64
+	case v != v:
+		return 0
+	}
+	return Decimal(v)
+}
+
+// Equal reports whether a and b are the same price or quantity once
+// rounded to Places, so float noise from arithmetic does not matter.
+func Equal(a, b float64) bool { return FromFloat(a) == FromFloat(b) }
+
+// Add returns a+b summed as Decimals, so repeated partial quantities add
+// up exactly: Add(0.1, 0.2) == 0.3.
+func Add(a, b float64) float64 { return (FromFloat(a) + FromFloat(b)).Float() }
+
+// Cmp compares a and b as Decimals: -1, 0 or +1.
+func Cmp(a, b float64) int {
+	switch x, y := FromFloat(a), FromFloat(b); {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// Float returns the nearest float64 for values below 2^53 units (about
+// 9e7), and a close one above.
+func (d Decimal) Float() float64 { return float64(d) / float64(Scale) }
+
+// String formats d without trailing zeros: "65000.5", "-0.001", "3".
+func (d Decimal) String() string { return string(d.Append(nil)) }
+
+// Append appends d's String form to dst.
+func (d Decimal) Append(dst []byte) []byte {
+	u := uint64(d)
+	if d < 0 {
+		dst = append(dst, '-')
+		u = uint64(-d)
+	}
+	dst = strconv.AppendUint(dst, u/uint64(Scale), 10)
+	f := u % uint64(Scale)
+	if f == 0 {
+		return dst
+	}
+	var buf [Places]byte
+	for i := Places - 1; i >= 0; i-- {
+		buf[i] = byte('0' + f%10)
+		f /= 10
+	}
+	n := Places
+	for buf[n-1] == '0' {
+		n--
+	}
+	dst = append(dst, '.')
+	return append(dst, buf[:n]...)
+}
+
+// MarshalJSON encodes d as a JSON string, the way venues send decimals.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	b := append([]byte{'"'}, d.Append(nil)...)
+	return append(b, '"'), nil
+}
+
+// UnmarshalJSON accepts a JSON string or number.
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	if len(b) >= 2 && b[0] == '"' && b[len(b)-1] == '"' {
+		b = b[1 : len(b)-1]
+	}
+	v, err := parse(b)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+func (d Decimal) MarshalText() ([]byte, error) { return d.Append(nil), nil }
+
+func (d *Decimal) UnmarshalText(b []byte) error {
+	v, err := parse(b)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
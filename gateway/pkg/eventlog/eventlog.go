@@ -0,0 +1,230 @@
+// Package eventlog is the gateway's event-sourcing journal: every
+// normalised market event it consumed and every decision it took (routes,
+// refusals, orders, cancels and fills), one JSON line each under a gap-free
+// sequence number. Rebuild folds a log back into the gateway's state as of
+// any event, for post-incident reconstruction and deterministic debugging.
+package eventlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/clock"
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/metrics"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// Event types.
+const (
+	TypeDepth       = "depth"
+	TypeTrade       = "trade"
+	TypeLiquidation = "liquidation"
+	TypeFunding     = "funding"
+	// TypeRoute is a routed action that was sent (or would have been, in
+	// dry run); TypeReject one refused after routing, e.g. by risk.
+	TypeRoute  = "route"
+	TypeReject = "reject"
+	// TypeOrder is an order opening or filling, TypeCancel one cancelled.
+	TypeOrder  = "order"
+	TypeCancel = "cancel"
+	TypeFill   = "fill"
+)
+
+var eventsWritten = metrics.Default.CounterVec("helix_eventlog_events_total", "Events appended to the gateway journal, by type.", "type")
+
+// Event is one journal line. Exactly one of the payloads is set, as Type
+// says; Fill events also carry the position the fill left.
+type Event struct {
+	Seq  uint64 `json:"seq"`
+	TsNs int64  `json:"ts_ns"`
+	Type string `json:"type"`
+
+	Depth       *transport.DepthUpdate   `json:"depth,omitempty"`
+	Trade       *transport.Trade         `json:"trade,omitempty"`
+	Liquidation *transport.Liquidation   `json:"liquidation,omitempty"`
+	Funding     *transport.FundingRate   `json:"funding,omitempty"`
+	Route       *transport.RouteDecision `json:"route,omitempty"`
+	Order       *executor.Order          `json:"order,omitempty"`
+	Fill        *transport.Fill          `json:"fill,omitempty"`
+	Position    *executor.Position       `json:"position,omitempty"`
+	// Err is why a rejected action was refused.
+	Err string `json:"err,omitempty"`
+}
+
+// Time is when the event was journaled.
+func (e Event) Time() time.Time { return time.Unix(0, e.TsNs) }
+
+// Log appends events to a file. It is an executor.Journal, so the tracker
+// and order sender report to it directly, and is safe for concurrent use;
+// the sequence is the order events were appended in. Lines are buffered
+// and flushed every FlushEvery and on Close.
+type Log struct {
+	clock clock.Clock
+
+	mu   sync.Mutex
+	f    *os.File
+	w    *bufio.Writer
+	enc  *json.Encoder
+	seq  uint64
+	err  error
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// FlushEvery is how often buffered events reach the file.
+const FlushEvery = 100 * time.Millisecond
+
+// Open appends to the journal at path, continuing its sequence when it
+// already has events.
+func Open(path string) (*Log, error) { return OpenClock(path, clock.Real) }
+
+// OpenClock is Open with clk stamping events and timing flushes.
+func OpenClock(path string, clk clock.Clock) (*Log, error) {
+	last, err := lastSeq(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	l := &Log{clock: clk, f: f, w: bufio.NewWriterSize(f, 1<<16), seq: last, done: make(chan struct{})}
+	l.enc = json.NewEncoder(l.w)
+	l.wg.Add(1)
+	go l.flusher()
+	return l, nil
+}
+
+// lastSeq is the sequence of the last complete line of path, 0 for a new
+// or empty journal.
+func lastSeq(path string) (uint64, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	const tail = 1 << 16
+	off := max(st.Size()-tail, 0)
+	buf := make([]byte, st.Size()-off)
+	if _, err := f.ReadAt(buf, off); err != nil && err != io.EOF {
+		return 0, err
+	}
+	lines := bytes.Split(bytes.TrimRight(buf, "\n"), []byte("\n"))
+	for i := len(lines) - 1; i >= 0; i-- {
+		var ev struct {
+			Seq uint64 `json:"seq"`
+		}
+		if json.Unmarshal(lines[i], &ev) == nil && ev.Seq > 0 {
+			return ev.Seq, nil
+		}
+	}
+	if st.Size() > 0 {
+		return 0, fmt.Errorf("%s: no event in its last %d bytes", path, len(buf))
+	}
+	return 0, nil
+}
+
+func (l *Log) flusher() {
+	defer l.wg.Done()
+	t := l.clock.NewTicker(FlushEvery)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C():
+			l.mu.Lock()
+			if err := l.w.Flush(); err != nil && l.err == nil {
+				l.err = err
+			}
+			l.mu.Unlock()
+		case <-l.done:
+			return
+		}
+	}
+}
+
+// append sequences ev and writes it. The first write error is kept for
+// Close; events after it are dropped.
+func (l *Log) append(ev Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return
+	}
+	l.seq++
+	ev.Seq, ev.TsNs = l.seq, l.clock.Now().UnixNano()
+	if err := l.enc.Encode(ev); err != nil {
+		l.err = err
+		return
+	}
+	eventsWritten.With(ev.Type).Inc()
+}
+
+// Seq is the sequence of the last event appended.
+func (l *Log) Seq() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.seq
+}
+
+func (l *Log) Depth(u transport.DepthUpdate) { l.append(Event{Type: TypeDepth, Depth: &u}) }
+func (l *Log) Trade(t transport.Trade)       { l.append(Event{Type: TypeTrade, Trade: &t}) }
+func (l *Log) Liquidation(q transport.Liquidation) {
+	l.append(Event{Type: TypeLiquidation, Liquidation: &q})
+}
+func (l *Log) Funding(f transport.FundingRate) { l.append(Event{Type: TypeFunding, Funding: &f}) }
+
+// Order records o as an order event, or a cancel once it is cancelled.
+func (l *Log) Order(o executor.Order) {
+	typ := TypeOrder
+	if o.Status == executor.StatusCancelled {
+		typ = TypeCancel
+	}
+	l.append(Event{Type: typ, Order: &o})
+}
+
+func (l *Log) Fill(f transport.Fill, pos executor.Position, at time.Time) {
+	l.append(Event{Type: TypeFill, Fill: &f, Position: &pos})
+}
+
+// Route records d, as a reject when err refused it.
+func (l *Log) Route(d transport.RouteDecision, err error) {
+	ev := Event{Type: TypeRoute, Route: &d}
+	if err != nil {
+		ev.Type, ev.Err = TypeReject, err.Error()
+	}
+	l.append(ev)
+}
+
+// Close flushes and syncs the journal and reports the first write error.
+func (l *Log) Close() error {
+	close(l.done)
+	l.wg.Wait()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	err := l.err
+	if ferr := l.w.Flush(); err == nil {
+		err = ferr
+	}
+	if serr := l.f.Sync(); err == nil {
+		err = serr
+	}
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	l.err = os.ErrClosed
+	return err
+}
@@ -1,13 +1,14 @@
 package executor
 
 import (
-	"fmt"
-
 	"github.com/helix-lab/helix/gateway/pkg/transport"
 )
 
-type FillHandler struct{}
+// FillHandler applies venue fills to the tracker's orders.
+type FillHandler struct {
+	Tracker *Tracker
+}
 
-func (FillHandler) Handle(fill transport.Fill) {
-	fmt.Printf("[FillHandler] fill from %s qty=%.2f price=%.2f\n", fill.Venue, fill.Qty, fill.Price)
+func (h FillHandler) Handle(fill transport.Fill) error {
+	return h.Tracker.Fill(fill.OrderID, fill.Price, fill.Qty)
 }
@@ -0,0 +1,42 @@
+package executor
+
+import (
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// Journal receives the executor's records as they happen, e.g. to keep
+// them for reconciliation. It is called on the trading path, with the
+// tracker locked, so implementations must not block.
+type Journal interface {
+	// Order is called with an order each time it opens, fills or is
+	// cancelled.
+	Order(o Order)
+	// Fill is called with each applied execution and the position it left.
+	Fill(f transport.Fill, pos Position, at time.Time)
+	// Route is called for every routing decision Send makes; err is why the
+	// action was refused once routed, if it was.
+	Route(d transport.RouteDecision, err error)
+}
+
+// Journals passes every record to each journal in turn.
+type Journals []Journal
+
+func (js Journals) Order(o Order) {
+	for _, j := range js {
+		j.Order(o)
+	}
+}
+
+func (js Journals) Fill(f transport.Fill, pos Position, at time.Time) {
+	for _, j := range js {
+		j.Fill(f, pos, at)
+	}
+}
+
+func (js Journals) Route(d transport.RouteDecision, err error) {
+	for _, j := range js {
+		j.Route(d, err)
+	}
+}
@@ -0,0 +1,140 @@
+package executor
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Dist is a latency distribution; the zero Dist is no delay.
+type Dist struct {
+	// Kind is "fixed", "uniform" (between A and B), "normal" (mean A,
+	// standard deviation B, never below zero) or "lognormal" (median A,
+	// shape Sigma).
+	Kind  string
+	A, B  time.Duration
+	Sigma float64
+}
+
+// ParseDist reads a distribution as a flag gives it:
+//
+//	5ms                 fixed
+//	uniform:2ms,8ms     uniform between the two
+//	normal:5ms,1ms      mean and standard deviation
+//	lognormal:5ms,0.5   median and shape, a long right tail
+//
+// An empty string is no delay.
+func ParseDist(s string) (Dist, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Dist{}, nil
+	}
+	kind, args, found := strings.Cut(s, ":")
+	if !found {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return Dist{}, fmt.Errorf("latency %q: want a duration or kind:args", s)
+		}
+		return Dist{Kind: "fixed", A: d}, nil
+	}
+	first, second, _ := strings.Cut(args, ",")
+	a, errA := time.ParseDuration(strings.TrimSpace(first))
+	if errA != nil || a < 0 {
+		return Dist{}, fmt.Errorf("latency %q: bad duration %q", s, first)
+	}
+	dist := Dist{Kind: kind, A: a}
+	switch kind {
+	case "uniform", "normal":
+		b, err := time.ParseDuration(strings.TrimSpace(second))
+		if err != nil || b < 0 || (kind == "uniform" && b < a) {
+			return Dist{}, fmt.Errorf("latency %q: bad second duration %q", s, second)
+		}
+		dist.B = b
+	case "lognormal":
+		sigma, err := strconv.ParseFloat(strings.TrimSpace(second), 64)
+		if err != nil || sigma < 0 {
+			return Dist{}, fmt.Errorf("latency %q: bad shape %q", s, second)
+		}
+		dist.Sigma = sigma
+	default:
+		return Dist{}, fmt.Errorf("latency %q: unknown distribution %q (want uniform, normal or lognormal)", s, kind)
+	}
+	return dist, nil
+}
+
+func (d Dist) String() string {
+	switch d.Kind {
+	case "", "fixed":
+		return d.A.String()
+	case "lognormal":
+		return fmt.Sprintf("lognormal:%s,%g", d.A, d.Sigma)
+	}
+	return fmt.Sprintf("%s:%s,%s", d.Kind, d.A, d.B)
+}
+
+// Sample draws one delay from d.
+func (d Dist) Sample(rng *rand.Rand) time.Duration {
+	var v float64
+	switch d.Kind {
+	case "uniform":
+		v = float64(d.A) + rng.Float64()*float64(d.B-d.A)
+	case "normal":
+		v = float64(d.A) + rng.NormFloat64()*float64(d.B)
+	case "lognormal":
+		v = float64(d.A) * math.Exp(d.Sigma*rng.NormFloat64())
+	default:
+		v = float64(d.A)
+	}
+	return time.Duration(max(v, 0))
+}
+
+// Latency is the delays a simulated order goes through: the strategy
+// deciding, the order travelling to the venue, and the execution report
+// travelling back.
+type Latency struct {
+	Decision Dist
+	Transit  Dist
+	Ack      Dist
+	// Seed makes the draws repeatable.
+	Seed int64
+	// Scale divides every delay, e.g. by a replay's speed so the delays
+	// stay true to the recording's clock.
+	Scale float64
+}
+
+// IsZero reports whether no delay is configured.
+func (l Latency) IsZero() bool {
+	return l.Decision.A == 0 && l.Decision.B == 0 && l.Transit.A == 0 && l.Transit.B == 0 && l.Ack.A == 0 && l.Ack.B == 0
+}
+
+// latencySampler draws from a Latency; safe for concurrent use.
+type latencySampler struct {
+	cfg Latency
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func newLatencySampler(cfg Latency) *latencySampler {
+	return &latencySampler{cfg: cfg, rng: rand.New(rand.NewSource(cfg.Seed))}
+}
+
+func (s *latencySampler) draw(d Dist) time.Duration {
+	s.mu.Lock()
+	v := d.Sample(s.rng)
+	s.mu.Unlock()
+	if s.cfg.Scale > 0 {
+		v = time.Duration(float64(v) / s.cfg.Scale)
+	}
+	return v
+}
+
+// arrival is how long after Submit an order reaches the venue.
+func (s *latencySampler) arrival() time.Duration {
+	return s.draw(s.cfg.Decision) + s.draw(s.cfg.Transit)
+}
+
+func (s *latencySampler) ack() time.Duration { return s.draw(s.cfg.Ack) }
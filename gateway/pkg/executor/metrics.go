@@ -0,0 +1,37 @@
+package executor
+
+import "github.com/helix-lab/helix/gateway/pkg/metrics"
+
+// RegisterMetrics exposes the kill switch.
+func (s *OrderSender) RegisterMetrics(reg *metrics.Registry) {
+	reg.GaugeFunc("helix_executor_kill_switch_armed", "1 while the kill switch blocks all orders.", func() float64 {
+		if s.KillSwitch().Armed {
+			return 1
+		}
+		return 0
+	})
+}
+
+// RegisterMetrics exposes open orders, positions and realized PnL.
+func (t *Tracker) RegisterMetrics(reg *metrics.Registry) {
+	reg.Collect("helix_executor_open_orders", "Open orders per venue and symbol.", "gauge", func(emit metrics.Emit) {
+		type key struct{ venue, symbol string }
+		n := map[key]int{}
+		for _, o := range t.OpenOrders() {
+			n[key{o.Venue, o.Symbol}]++
+		}
+		for k, c := range n {
+			emit("", metrics.L("venue", k.venue, "symbol", k.symbol), float64(c))
+		}
+	})
+	reg.Collect("helix_executor_position", "Net filled position per venue and symbol (negative = short).", "gauge", func(emit metrics.Emit) {
+		for _, p := range t.Positions() {
+			emit("", metrics.L("venue", p.Venue, "symbol", p.Symbol), p.Qty)
+		}
+	})
+	reg.Collect("helix_executor_realized_pnl", "Realized PnL per venue and symbol, in quote currency.", "gauge", func(emit metrics.Emit) {
+		for _, p := range t.Positions() {
+			emit("", metrics.L("venue", p.Venue, "symbol", p.Symbol), p.Realized)
+		}
+	})
+}
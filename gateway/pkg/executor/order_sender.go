@@ -1,24 +1,328 @@
 package executor
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
+	"github.com/helix-lab/helix/gateway/pkg/latency"
+	"github.com/helix-lab/helix/gateway/pkg/metrics"
+	"github.com/helix-lab/helix/gateway/pkg/ratelimit"
 	"github.com/helix-lab/helix/gateway/pkg/router"
+	"github.com/helix-lab/helix/gateway/pkg/tracing"
 	"github.com/helix-lab/helix/gateway/pkg/transport"
 )
 
+var (
+	ordersRouted   = metrics.Default.CounterVec("helix_executor_orders_routed_total", "Actions routed by the OrderSender per venue and symbol.", "venue", "symbol")
+	ordersRejected = metrics.Default.CounterVec("helix_executor_orders_rejected_total", "Actions the OrderSender refused, by reason.", "reason")
+	ordersDryRun   = metrics.Default.CounterVec("helix_executor_orders_dry_run_total", "Actions routed in dry-run mode and not sent, per venue and symbol.", "venue", "symbol")
+)
+
+// Errors returned by Send for actions it refuses.
+var (
+	ErrKillSwitch    = errors.New("kill switch armed")
+	ErrOrderSize     = errors.New("order size over limit")
+	ErrPositionLimit = errors.New("position limit reached")
+	ErrNotCancelable = errors.New("order cannot be canceled")
+)
+
+// Limits are the per-order checks Send applies once the venue is known;
+// zero disables a check.
+type Limits struct {
+	// MaxOrderSize returns the largest order allowed for symbol on venue.
+	MaxOrderSize func(venue, symbol string) float64
+	// MaxPosition caps the absolute exposure per venue and symbol, counting
+	// open orders as filled. It needs a tracker.
+	MaxPosition float64
+	// Instrument vets action against the venue's instrument spec at price,
+	// the limit price or, for market orders, the venue's touch.
+	Instrument func(action transport.Action, price float64) error
+}
+
+// Checker vets a routed action after Limits, e.g. a risk engine; a
+// non-nil error refuses it.
+type Checker interface {
+	Check(action transport.Action) error
+}
+
+// KillSwitch is the sender's trading halt; while Armed nothing is sent.
+type KillSwitch struct {
+	Armed  bool
+	Reason string
+	Since  time.Time
+}
+
+// Sink is where OrderSender hands routed actions: the transport when
+// live, simulated fills in paper mode.
+type Sink interface {
+	Submit(ctx context.Context, action transport.Action) error
+}
+
+// Canceler is implemented by sinks that can pull an order they were
+// given; Cancel fails for orders they cannot cancel.
+type Canceler interface {
+	Cancel(ctx context.Context, o Order) error
+}
+
+type publishSink struct{ pub *transport.Publisher }
+
+func (p publishSink) Submit(ctx context.Context, action transport.Action) error {
+	p.pub.PublishAction(ctx, action)
+	return nil
+}
+
+func (p publishSink) Cancel(ctx context.Context, o Order) error {
+	p.pub.PublishCancel(ctx, transport.Cancel{ID: o.ID, Venue: o.Venue, Symbol: o.Symbol})
+	return nil
+}
+
 type OrderSender struct {
-	pub    *transport.Publisher
-	router *router.SmartRouter
+	sink     Sink
+	pub      *transport.Publisher
+	dryRun   bool
+	testnet  bool
+	router   *router.SmartRouter
+	offset   func(venue string) time.Duration
+	tracker  *Tracker
+	limits   Limits
+	checkers []Checker
+	journal  Journal
+	rates    *ratelimit.Limiter
+
+	mu   sync.RWMutex
+	kill KillSwitch
 }
 
 func NewOrderSender(pub *transport.Publisher, r *router.SmartRouter) *OrderSender {
-	return &OrderSender{pub: pub, router: r}
+	return &OrderSender{sink: publishSink{pub}, pub: pub, router: r}
 }
 
-func (s *OrderSender) Send(action transport.Action, books map[string]router.BookView) {
-	venue := s.router.Route(action, books)
+// SetClockOffset supplies per-venue clock skew (venue minus local) used to
+// correct the exchange leg of tick-to-trade, e.g. ClockMonitor.Offset.
+func (s *OrderSender) SetClockOffset(fn func(venue string) time.Duration) {
+	s.offset = fn
+}
+
+// SetSink replaces the transport as the destination of routed actions.
+func (s *OrderSender) SetSink(sink Sink) {
+	s.sink = sink
+}
+
+// SetDryRun makes Send route, check and publish its RouteDecision but send
+// nothing: no order is tracked and the sink is never called.
+func (s *OrderSender) SetDryRun(on bool) {
+	s.dryRun = on
+}
+
+// SetTestnet marks every sent action Testnet.
+func (s *OrderSender) SetTestnet(on bool) {
+	s.testnet = on
+}
+
+// SetRateLimiter makes Send take a ratelimit.Orders call from the routed
+// venue before sending, refusing the action when none is left. Whatever
+// talks to the venue's REST API feeds its responses back into l.
+func (s *OrderSender) SetRateLimiter(l *ratelimit.Limiter) {
+	s.rates = l
+}
+
+// SetTracker records every sent action as an open order in t.
+func (s *OrderSender) SetTracker(t *Tracker) {
+	s.tracker = t
+}
+
+// SetJournal reports every routing decision, sent or refused, to j.
+func (s *OrderSender) SetJournal(j Journal) {
+	s.journal = j
+}
+
+func (s *OrderSender) SetLimits(l Limits) {
+	s.limits = l
+}
+
+// SetChecker adds c to the checks every action must pass, after those
+// added before it.
+func (s *OrderSender) SetChecker(c Checker) {
+	s.checkers = append(s.checkers, c)
+}
+
+func (s *OrderSender) ArmKillSwitch(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.kill.Armed {
+		s.kill = KillSwitch{Armed: true, Reason: reason, Since: time.Now()}
+	}
+}
+
+func (s *OrderSender) DisarmKillSwitch() {
+	s.mu.Lock()
+	s.kill = KillSwitch{}
+	s.mu.Unlock()
+}
+
+func (s *OrderSender) KillSwitch() KillSwitch {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.kill
+}
+
+// Send routes and publishes action and returns it with its venue and, when
+// tracked, its ID. It refuses with ErrKillSwitch while the kill switch is
+// armed, with ErrOrderSize/ErrPositionLimit over the limits and with the
+// error of the first checker that refuses it.
+func (s *OrderSender) Send(ctx context.Context, action transport.Action, books map[string]router.BookView) (transport.Action, error) {
+	ctx, span := tracing.Start(ctx, "executor.send",
+		tracing.String("symbol", action.Symbol), tracing.String("side", action.Side))
+	defer span.End()
+
+	if s.KillSwitch().Armed {
+		ordersRejected.With("kill_switch").Inc()
+		span.SetError(ErrKillSwitch)
+		return action, ErrKillSwitch
+	}
+
+	_, routeSpan := tracing.Start(ctx, "router.route", tracing.Int("venues", int64(len(books))))
+	routeProf := latency.Start("executor", latency.Labels{Symbol: action.Symbol, Stage: "route"})
+	decision := s.router.Decide(action, books)
+	venue := decision.Venue
+	routeProf.Label(latency.Labels{Venue: venue})
+	routeProf.Stop()
+	routedNs := time.Now().UnixNano()
+	routeSpan.SetAttr(tracing.String("venue", venue))
+	routeSpan.End()
+
 	action.Venue = venue
+	if action.Category == "" {
+		// the market of the book it was routed on
+		action.Category = books[venue].Category
+	}
+	action.Testnet = s.testnet
+	route := transport.RouteDecision{Symbol: action.Symbol, Side: action.Side, Size: action.Size, Account: action.Account,
+		Venue: venue, Price: decision.Price, Prices: decision.Prices, DryRun: s.dryRun, TsNs: routedNs}
+	touch := books[venue].BestAsk
+	if action.Side == "SELL" {
+		touch = books[venue].BestBid
+	}
+	if err := s.check(action, touch); err != nil {
+		s.audit(route, err)
+		span.SetError(err)
+		return action, err
+	}
+	if s.dryRun {
+		s.pub.PublishRoute(route)
+		s.audit(route, nil)
+		ordersDryRun.With(venue, action.Symbol).Inc()
+		fmt.Printf("[OrderSender] dry run: would route %s %s %g to %s at %.4f\n", action.Side, action.Symbol, action.Size, venue, decision.Price)
+		return action, nil
+	}
+	if s.rates != nil && !s.rates.Allow(venue, ratelimit.Orders) {
+		err := fmt.Errorf("%w: %s orders", ratelimit.ErrThrottled, venue)
+		ordersRejected.With("rate_limit").Inc()
+		s.audit(route, err)
+		span.SetError(err)
+		return action, err
+	}
+	if s.tracker != nil {
+		action.ID = s.tracker.Open(action).ID
+		route.OrderID = action.ID
+	}
+	ordersRouted.With(venue, action.Symbol).Inc()
 	fmt.Printf("[OrderSender] routed action to %s\n", venue)
-	s.pub.PublishAction(action)
+	pubProf := latency.Start("executor", latency.Labels{Venue: venue, Symbol: action.Symbol, Stage: "publish"})
+	err := s.sink.Submit(ctx, action)
+	pubProf.Stop()
+	if err != nil {
+		if s.tracker != nil {
+			_ = s.tracker.Cancel(action.ID)
+		}
+		ordersRejected.With("sink").Inc()
+		s.audit(route, err)
+		span.SetError(err)
+		return action, err
+	}
+	s.audit(route, nil)
+	var skew time.Duration
+	if s.offset != nil && action.TickVenue != "" {
+		skew = s.offset(action.TickVenue)
+	}
+	latency.Default.RecordTickToTrade(latency.Labels{Venue: venue, Symbol: action.Symbol}, latency.TickTimes{
+		ExchTsMs:    action.ExchTsMs,
+		ExchOffset:  skew,
+		RecvNs:      action.RecvNs,
+		RoutedNs:    routedNs,
+		PublishedNs: time.Now().UnixNano(),
+	})
+	return action, nil
+}
+
+// Cancel pulls the open order id from the sink and closes it in the
+// tracker, returning it as it stands. It fails with ErrNotCancelable when
+// the order is unknown or no longer open, or the sink cannot cancel.
+func (s *OrderSender) Cancel(ctx context.Context, id string) (Order, error) {
+	if s.tracker == nil {
+		return Order{}, fmt.Errorf("%w: orders are not tracked", ErrNotCancelable)
+	}
+	o, ok := s.tracker.Order(id)
+	switch {
+	case !ok:
+		return Order{}, fmt.Errorf("%w: unknown order %s", ErrNotCancelable, id)
+	case o.Status != StatusOpen:
+		return o, fmt.Errorf("%w: order %s is %s", ErrNotCancelable, id, o.Status)
+	}
+	c, ok := s.sink.(Canceler)
+	if !ok {
+		return o, fmt.Errorf("%w: the sink does not cancel", ErrNotCancelable)
+	}
+	if err := c.Cancel(ctx, o); err != nil {
+		ordersRejected.With("cancel").Inc()
+		return o, fmt.Errorf("%w: %v", ErrNotCancelable, err)
+	}
+	if err := s.tracker.Cancel(id); err != nil {
+		return o, fmt.Errorf("%w: %v", ErrNotCancelable, err)
+	}
+	o, _ = s.tracker.Order(id)
+	return o, nil
+}
+
+func (s *OrderSender) audit(d transport.RouteDecision, err error) {
+	if s.journal != nil {
+		s.journal.Route(d, err)
+	}
+}
+
+func (s *OrderSender) check(action transport.Action, touch float64) error {
+	if s.limits.Instrument != nil {
+		if err := s.limits.Instrument(action, touch); err != nil {
+			ordersRejected.With("instrument").Inc()
+			return err
+		}
+	}
+	if s.limits.MaxOrderSize != nil {
+		if max := s.limits.MaxOrderSize(action.Venue, action.Symbol); max > 0 && action.Size > max {
+			ordersRejected.With("order_size").Inc()
+			return fmt.Errorf("%w: %s %s size %g > %g", ErrOrderSize, action.Venue, action.Symbol, action.Size, max)
+		}
+	}
+	if s.limits.MaxPosition > 0 && s.tracker != nil {
+		next := s.tracker.Exposure(action.Venue, action.Symbol)
+		if action.Side == "SELL" {
+			next -= action.Size
+		} else {
+			next += action.Size
+		}
+		if abs(next) > s.limits.MaxPosition {
+			ordersRejected.With("position").Inc()
+			return fmt.Errorf("%w: %s %s exposure would be %g (max %g)", ErrPositionLimit, action.Venue, action.Symbol, next, s.limits.MaxPosition)
+		}
+	}
+	for _, c := range s.checkers {
+		if err := c.Check(action); err != nil {
+			ordersRejected.With("risk").Inc()
+			return err
+		}
+	}
+	return nil
 }
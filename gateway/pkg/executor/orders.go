@@ -0,0 +1,303 @@
+package executor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/decimal"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+const (
+	StatusOpen      = "open"
+	StatusFilled    = "filled"
+	StatusCancelled = "cancelled"
+)
+
+// Order is an action the gateway has sent, as far as the gateway knows.
+type Order struct {
+	ID       string
+	Venue    string
+	Symbol   string
+	Category string
+	Side     string
+	Size     float64
+	Filled   float64
+	// AvgPrice is the volume-weighted price of the Fills executions.
+	AvgPrice float64
+	Fills    int
+	Status   string
+	// Account is the hosted strategy or client that sent it.
+	Account   string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Remaining is the size still to fill, 0 once the order is done either way.
+func (o Order) Remaining() float64 {
+	if o.Status != StatusOpen {
+		return 0
+	}
+	return decimal.Add(o.Size, -o.Filled)
+}
+
+// Completion is the order-complete event for a done order.
+func (o Order) Completion() transport.OrderComplete {
+	return transport.OrderComplete{OrderID: o.ID, Venue: o.Venue, Symbol: o.Symbol, Category: o.Category, Side: o.Side,
+		Status: o.Status, Size: o.Size, Filled: o.Filled, AvgPrice: o.AvgPrice, Fills: o.Fills,
+		DurationMs: o.UpdatedAt.Sub(o.CreatedAt).Milliseconds()}
+}
+
+// Position is the net fill quantity per (venue, symbol); Qty is negative
+// when short.
+type Position struct {
+	Venue    string
+	Symbol   string
+	Qty      float64
+	AvgPrice float64
+	Realized float64
+}
+
+type positionKey struct {
+	venue  string
+	symbol string
+}
+
+// Tracker keeps open orders and the positions their fills build up.
+type Tracker struct {
+	mu        sync.Mutex
+	seq       uint64
+	orders    map[string]*Order
+	positions map[positionKey]*Position
+	journal   Journal
+	complete  func(Order)
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{orders: make(map[string]*Order), positions: make(map[positionKey]*Position)}
+}
+
+// SetJournal reports every order change and fill to j.
+func (t *Tracker) SetJournal(j Journal) {
+	t.mu.Lock()
+	t.journal = j
+	t.mu.Unlock()
+}
+
+// OnComplete calls fn with each order that fills in full or is cancelled.
+// Like a Journal it runs with the tracker locked and must not block.
+func (t *Tracker) OnComplete(fn func(Order)) {
+	t.mu.Lock()
+	t.complete = fn
+	t.mu.Unlock()
+}
+
+// Open records a routed action and returns the order with its ID.
+func (t *Tracker) Open(action transport.Action) Order {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.seq++
+	now := time.Now()
+	o := &Order{
+		ID:        "hx-" + strconv.FormatUint(t.seq, 10),
+		Venue:     action.Venue,
+		Symbol:    action.Symbol,
+		Category:  action.Category,
+		Side:      action.Side,
+		Size:      action.Size,
+		Status:    StatusOpen,
+		Account:   action.Account,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	t.orders[o.ID] = o
+	if t.journal != nil {
+		t.journal.Order(*o)
+	}
+	return *o
+}
+
+// Fill applies an execution to the order and its position.
+func (t *Tracker) Fill(orderID string, price, qty float64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	o, ok := t.orders[orderID]
+	if !ok {
+		return fmt.Errorf("unknown order %s", orderID)
+	}
+	if o.Status != StatusOpen {
+		return fmt.Errorf("order %s is %s", orderID, o.Status)
+	}
+	// quantities add as decimals so partial fills sum to the order size
+	remaining := decimal.FromFloat(o.Size) - decimal.FromFloat(o.Filled)
+	if qty <= 0 || decimal.FromFloat(qty) > remaining {
+		return fmt.Errorf("fill qty %g invalid for order %s (remaining %g)", qty, orderID, remaining.Float())
+	}
+	if o.Filled == 0 {
+		o.AvgPrice = price
+	} else {
+		o.AvgPrice = (o.AvgPrice*o.Filled + price*qty) / (o.Filled + qty)
+	}
+	o.Filled = decimal.Add(o.Filled, qty)
+	o.Fills++
+	o.UpdatedAt = time.Now()
+	if decimal.FromFloat(o.Filled) >= decimal.FromFloat(o.Size) {
+		o.Status = StatusFilled
+	}
+
+	k := positionKey{venue: o.Venue, symbol: o.Symbol}
+	p, ok := t.positions[k]
+	if !ok {
+		p = &Position{Venue: o.Venue, Symbol: o.Symbol}
+		t.positions[k] = p
+	}
+	signed := qty
+	if o.Side == "SELL" {
+		signed = -qty
+	}
+	switch {
+	case p.Qty == 0 || (p.Qty > 0) == (signed > 0):
+		p.AvgPrice = (p.AvgPrice*abs(p.Qty) + price*qty) / (abs(p.Qty) + qty)
+		p.Qty = decimal.Add(p.Qty, signed)
+	default:
+		closed := qty
+		if closed > abs(p.Qty) {
+			closed = abs(p.Qty)
+		}
+		if p.Qty > 0 {
+			p.Realized += (price - p.AvgPrice) * closed
+		} else {
+			p.Realized += (p.AvgPrice - price) * closed
+		}
+		p.Qty = decimal.Add(p.Qty, signed)
+		switch {
+		case p.Qty == 0:
+			p.Qty, p.AvgPrice = 0, 0
+		case qty > closed:
+			// flipped through flat: the remainder opens at this price
+			p.AvgPrice = price
+		}
+	}
+	if t.journal != nil {
+		t.journal.Order(*o)
+		t.journal.Fill(transport.Fill{OrderID: o.ID, Venue: o.Venue, Symbol: o.Symbol, Category: o.Category, Side: o.Side, Price: price, Qty: qty}, *p, o.UpdatedAt)
+	}
+	if o.Status == StatusFilled && t.complete != nil {
+		t.complete(*o)
+	}
+	return nil
+}
+
+// Cancel closes an open order; fills already applied stay.
+func (t *Tracker) Cancel(orderID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	o, ok := t.orders[orderID]
+	if !ok {
+		return fmt.Errorf("unknown order %s", orderID)
+	}
+	if o.Status != StatusOpen {
+		return fmt.Errorf("order %s is %s", orderID, o.Status)
+	}
+	o.Status = StatusCancelled
+	o.UpdatedAt = time.Now()
+	if t.journal != nil {
+		t.journal.Order(*o)
+	}
+	if t.complete != nil {
+		t.complete(*o)
+	}
+	return nil
+}
+
+// Order returns the order with id as the tracker knows it.
+func (t *Tracker) Order(id string) (Order, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if o, ok := t.orders[id]; ok {
+		return *o, true
+	}
+	return Order{}, false
+}
+
+func (t *Tracker) OpenOrders() []Order {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var out []Order
+	for _, o := range t.orders {
+		if o.Status == StatusOpen {
+			out = append(out, *o)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+func (t *Tracker) Positions() []Position {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]Position, 0, len(t.positions))
+	for _, p := range t.positions {
+		out = append(out, *p)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Symbol != out[j].Symbol {
+			return out[i].Symbol < out[j].Symbol
+		}
+		return out[i].Venue < out[j].Venue
+	})
+	return out
+}
+
+// Exposure is the signed position on venue/symbol plus the unfilled
+// remainder of its open orders, i.e. the position if everything filled.
+func (t *Tracker) Exposure(venue, symbol string) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var q float64
+	if p, ok := t.positions[positionKey{venue: venue, symbol: symbol}]; ok {
+		q = p.Qty
+	}
+	for _, o := range t.orders {
+		if o.Status != StatusOpen || o.Venue != venue || o.Symbol != symbol {
+			continue
+		}
+		if o.Side == "SELL" {
+			q -= o.Size - o.Filled
+		} else {
+			q += o.Size - o.Filled
+		}
+	}
+	return q
+}
+
+// Restore replaces the tracker's orders and positions, e.g. with state
+// saved before a restart. New IDs continue after the highest restored one.
+func (t *Tracker) Restore(orders []Order, positions []Position) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.orders = make(map[string]*Order, len(orders))
+	t.positions = make(map[positionKey]*Position, len(positions))
+	for i := range orders {
+		o := orders[i]
+		t.orders[o.ID] = &o
+		if n, err := strconv.ParseUint(strings.TrimPrefix(o.ID, "hx-"), 10, 64); err == nil && n > t.seq {
+			t.seq = n
+		}
+	}
+	for i := range positions {
+		p := positions[i]
+		t.positions[positionKey{venue: p.Venue, symbol: p.Symbol}] = &p
+	}
+}
+
+func abs(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,207 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/metrics"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+var paperFills = metrics.Default.CounterVec("helix_executor_paper_fills_total", "Simulated fills in paper mode.", "venue", "symbol")
+
+// Paper is a Sink that fills actions instead of sending them anywhere:
+// market and marketable limit actions in full at the routed venue's touch
+// (ask for buys, bid for sells), others once a MakerQueue says their place
+// in the queue came up. Fills queue up until the caller takes them, so
+// submitting from the loop that consumes fills cannot deadlock.
+//
+// With SetLatency, an action reaches the simulated venue only after its
+// decision and transit delays and executes against the book as it is
+// then; fills are reported after the ack delay.
+type Paper struct {
+	books *orderbook.Manager
+
+	mu       sync.Mutex
+	queue    *MakerQueue
+	fills    []transport.Fill
+	ready    chan struct{}
+	lat      *latencySampler
+	inflight map[string]*time.Timer
+}
+
+func NewPaper(books *orderbook.Manager) *Paper {
+	return &Paper{books: books, queue: NewMakerQueue(), ready: make(chan struct{}, 1), inflight: map[string]*time.Timer{}}
+}
+
+// SetLatency delays actions and fills by draws from l; the zero Latency
+// executes and reports at once.
+func (p *Paper) SetLatency(l Latency) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lat = nil
+	if !l.IsZero() {
+		p.lat = newLatencySampler(l)
+	}
+}
+
+// level is action's venue's top of book in action's market.
+func (p *Paper) level(action transport.Action) (orderbook.Level, bool) {
+	b, ok := p.books.Venue(action.Venue, action.Symbol, action.Category)
+	return b.Level, ok
+}
+
+func (p *Paper) Submit(_ context.Context, action transport.Action) error {
+	lvl, ok := p.level(action)
+	if !ok {
+		return fmt.Errorf("paper: no %s book on %s", action.Symbol, action.Venue)
+	}
+	if price, _ := Marketable(action, lvl); price <= 0 && action.Price <= 0 {
+		return fmt.Errorf("paper: %s %s has no %s liquidity", action.Venue, action.Symbol, action.Side)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.lat != nil {
+		if d := p.lat.arrival(); d > 0 {
+			timer := time.AfterFunc(d, func() { p.arrive(action, lvl) })
+			if action.ID != "" {
+				p.inflight[action.ID] = timer
+			}
+			return nil
+		}
+	}
+	p.execute(action, lvl)
+	return nil
+}
+
+// arrive executes a delayed action against the book it finds, or the one
+// it was sent against if that book is gone.
+func (p *Paper) arrive(action transport.Action, sent orderbook.Level) {
+	lvl, ok := p.level(action)
+	if !ok {
+		lvl = sent
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if action.ID != "" {
+		if _, live := p.inflight[action.ID]; !live {
+			return
+		}
+		delete(p.inflight, action.ID)
+	}
+	p.execute(action, lvl)
+}
+
+// execute fills action at lvl or rests it; p.mu must be held.
+func (p *Paper) execute(action transport.Action, lvl orderbook.Level) {
+	price, take := Marketable(action, lvl)
+	if price <= 0 {
+		take = false
+	}
+	if !take {
+		p.queue.Add(action, lvl)
+		return
+	}
+	p.add(transport.Fill{
+		OrderID:  action.ID,
+		Venue:    action.Venue,
+		Symbol:   action.Symbol,
+		Category: action.Category,
+		Side:     action.Side,
+		Price:    price,
+		Qty:      action.Size,
+	})
+}
+
+// Cancel pulls o if it is still resting or on its way to the venue; taker
+// orders fill on arrival and cannot be canceled.
+func (p *Paper) Cancel(_ context.Context, o Order) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if t, ok := p.inflight[o.ID]; ok {
+		t.Stop()
+		delete(p.inflight, o.ID)
+		return nil
+	}
+	if !p.queue.Cancel(o.ID) {
+		return fmt.Errorf("paper: order %s is not resting", o.ID)
+	}
+	return nil
+}
+
+// Depth feeds a book change to the resting orders; call it for every
+// update the book manager applies.
+func (p *Paper) Depth(u transport.DepthUpdate) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.queue.Len() > 0 {
+		p.add(p.queue.Depth(u)...)
+	}
+}
+
+// Trade feeds a public print to the resting orders.
+func (p *Paper) Trade(t transport.Trade) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.queue.Len() > 0 {
+		p.add(p.queue.Trade(t)...)
+	}
+}
+
+// Resting returns the limit actions still waiting, with their unfilled size.
+func (p *Paper) Resting() []transport.Action {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.queue.Orders()
+}
+
+// add reports fills, after the ack delay if there is one; p.mu must be
+// held.
+func (p *Paper) add(fills ...transport.Fill) {
+	if p.lat != nil {
+		var now []transport.Fill
+		for _, f := range fills {
+			if d := p.lat.ack(); d > 0 {
+				f := f
+				time.AfterFunc(d, func() {
+					p.mu.Lock()
+					defer p.mu.Unlock()
+					p.deliver(f)
+				})
+			} else {
+				now = append(now, f)
+			}
+		}
+		fills = now
+	}
+	p.deliver(fills...)
+}
+
+// deliver queues fills and signals Ready; p.mu must be held.
+func (p *Paper) deliver(fills ...transport.Fill) {
+	if len(fills) == 0 {
+		return
+	}
+	for _, f := range fills {
+		paperFills.With(f.Venue, f.Symbol).Inc()
+	}
+	p.fills = append(p.fills, fills...)
+	select {
+	case p.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Ready receives when fills are waiting; call TakeFills then.
+func (p *Paper) Ready() <-chan struct{} { return p.ready }
+
+func (p *Paper) TakeFills() []transport.Fill {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := p.fills
+	p.fills = nil
+	return out
+}
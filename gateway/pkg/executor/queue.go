@@ -0,0 +1,179 @@
+package executor
+
+import (
+	"math"
+
+	"github.com/helix-lab/helix/gateway/pkg/decimal"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// MakerQueue simulates resting limit orders by their place in the venue's
+// queue at their price: the size ahead starts as the displayed size at the
+// level, prints at the level eat it first, and size leaving the level
+// without a print is treated as cancels spread evenly over the queue. An
+// order fills once the size ahead is gone and prints keep coming, or when
+// the opposite side trades or quotes through its price.
+//
+// Only top of book is visible, so an order behind the touch learns its
+// queue when its price becomes the touch, as if it had just joined.
+// MakerQueue is not safe for concurrent use.
+type MakerQueue struct {
+	orders []*resting
+}
+
+type resting struct {
+	action transport.Action
+	left   float64
+	// ahead is the size queued in front of us (+Inf until seen); level is
+	// the displayed size at our price when last at the touch (0 when not).
+	ahead float64
+	level float64
+}
+
+func NewMakerQueue() *MakerQueue { return &MakerQueue{} }
+
+// Marketable reports whether a limit action would take liquidity against
+// lvl, and at what price. Prices compare as decimals, so a limit at the
+// touch is marketable whatever float noise it carries.
+func Marketable(a transport.Action, lvl orderbook.Level) (float64, bool) {
+	if a.Side == "SELL" {
+		return lvl.BestBid, lvl.BestBid > 0 && (a.Price <= 0 || decimal.Cmp(a.Price, lvl.BestBid) <= 0)
+	}
+	return lvl.BestAsk, lvl.BestAsk > 0 && (a.Price <= 0 || decimal.Cmp(a.Price, lvl.BestAsk) >= 0)
+}
+
+// Add rests a non-marketable limit action; lvl is its venue's book now.
+func (q *MakerQueue) Add(a transport.Action, lvl orderbook.Level) {
+	r := &resting{action: a, left: a.Size, ahead: math.Inf(1)}
+	r.touch(lvl)
+	q.orders = append(q.orders, r)
+}
+
+// Len is the number of resting orders.
+func (q *MakerQueue) Len() int { return len(q.orders) }
+
+// Cancel removes the order; false if it is not resting.
+func (q *MakerQueue) Cancel(orderID string) bool {
+	for i, r := range q.orders {
+		if r.action.ID == orderID {
+			q.orders = append(q.orders[:i], q.orders[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Ahead is the size estimated in front of the order, +Inf while its price
+// has not been the touch; false if it is not resting.
+func (q *MakerQueue) Ahead(orderID string) (float64, bool) {
+	for _, r := range q.orders {
+		if r.action.ID == orderID {
+			return r.ahead, true
+		}
+	}
+	return 0, false
+}
+
+// Orders returns the resting actions with their unfilled size.
+func (q *MakerQueue) Orders() []transport.Action {
+	out := make([]transport.Action, 0, len(q.orders))
+	for _, r := range q.orders {
+		a := r.action
+		a.Size = r.left
+		out = append(out, a)
+	}
+	return out
+}
+
+// Depth updates queue positions from a book change and returns orders the
+// book crossed.
+func (q *MakerQueue) Depth(u transport.DepthUpdate) []transport.Fill {
+	lvl := orderbook.Level{BestBid: u.BestBid, BestAsk: u.BestAsk, BidSize: u.BidSize, AskSize: u.AskSize}
+	var fills []transport.Fill
+	for _, r := range q.orders {
+		if r.action.Venue != u.Venue || r.action.Symbol != u.Symbol || (r.action.Category != "" && u.Category != "" && r.action.Category != u.Category) {
+			continue
+		}
+		if _, crossed := Marketable(r.action, lvl); crossed {
+			fills = append(fills, r.fill(r.left))
+			continue
+		}
+		r.touch(lvl)
+	}
+	q.sweep()
+	return fills
+}
+
+// Trade applies a public print and returns what it filled.
+func (q *MakerQueue) Trade(t transport.Trade) []transport.Fill {
+	var fills []transport.Fill
+	for _, r := range q.orders {
+		a := r.action
+		if a.Venue != t.Venue || a.Symbol != t.Symbol || t.Side == a.Side {
+			continue
+		}
+		c := decimal.Cmp(t.Price, a.Price)
+		through := (a.Side == "SELL" && c > 0) || (a.Side != "SELL" && c < 0)
+		if through {
+			fills = append(fills, r.fill(r.left))
+			continue
+		}
+		if c != 0 || math.IsInf(r.ahead, 1) {
+			continue
+		}
+		qty := t.Qty
+		eat := math.Min(qty, r.ahead)
+		r.ahead -= eat
+		r.level = math.Max(r.level-eat, 0)
+		if qty -= eat; qty > 0 {
+			fills = append(fills, r.fill(math.Min(qty, r.left)))
+		}
+	}
+	q.sweep()
+	return fills
+}
+
+// touch refreshes the queue estimate from the book on our side.
+func (r *resting) touch(lvl orderbook.Level) {
+	px, size := lvl.BestBid, lvl.BidSize
+	c := decimal.Cmp(r.action.Price, px)
+	better := c > 0
+	if r.action.Side == "SELL" {
+		px, size = lvl.BestAsk, lvl.AskSize
+		c = decimal.Cmp(r.action.Price, px)
+		better = px == 0 || c < 0
+	}
+	switch {
+	case better:
+		// nothing displayed at our price: we are the touch
+		r.ahead, r.level = 0, 0
+	case c != 0:
+		r.level = 0
+	case r.level == 0:
+		r.ahead = math.Min(r.ahead, size)
+		r.level = size
+	default:
+		if size < r.level && r.level > 0 {
+			r.ahead -= (r.level - size) * r.ahead / r.level
+		}
+		r.ahead = math.Min(r.ahead, size)
+		r.level = size
+	}
+}
+
+func (r *resting) fill(qty float64) transport.Fill {
+	r.left = decimal.Add(r.left, -qty)
+	a := r.action
+	return transport.Fill{OrderID: a.ID, Venue: a.Venue, Symbol: a.Symbol, Category: a.Category, Side: a.Side, Price: a.Price, Qty: qty, Maker: true}
+}
+
+func (q *MakerQueue) sweep() {
+	out := q.orders[:0]
+	for _, r := range q.orders {
+		if r.left > 0 {
+			out = append(out, r)
+		}
+	}
+	q.orders = out
+}
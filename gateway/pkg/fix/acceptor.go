@@ -0,0 +1,168 @@
+package fix
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"net"
+	"sync"
+	"time"
+)
+
+// logonTimeout is how long a new connection has to log on.
+const logonTimeout = 10 * time.Second
+
+// peer is a counterparty that may hold one session at a time.
+type peer interface {
+	// attach makes s its session, queuing the Logon reply before anything
+	// else; false if it already has one.
+	attach(s *session, reply Message) bool
+	detach(s *session)
+}
+
+// acceptor listens for sessions and logs them on; the order entry Server
+// and DropCopy differ only in whom they let on and what a session does.
+type acceptor struct {
+	compID string
+	logf   func(format string, args ...any)
+	// logon returns the peer a Logon authenticates as, nil to refuse it.
+	logon func(compID, password string) peer
+	// serve runs a logged-on session until it ends.
+	serve func(p peer, s *session)
+
+	mu       sync.Mutex
+	ln       net.Listener
+	sessions map[*session]bool
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+func (a *acceptor) listen(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.ln = ln
+	a.mu.Unlock()
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			a.wg.Add(1)
+			go func() {
+				defer a.wg.Done()
+				a.handle(conn)
+			}()
+		}
+	}()
+	return nil
+}
+
+func (a *acceptor) addr() net.Addr {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.ln == nil {
+		return nil
+	}
+	return a.ln.Addr()
+}
+
+func (a *acceptor) close() error {
+	a.mu.Lock()
+	a.closed = true
+	var err error
+	if a.ln != nil {
+		err = a.ln.Close()
+	}
+	for sess := range a.sessions {
+		sess.logout("gateway shutting down")
+	}
+	a.mu.Unlock()
+	a.wg.Wait()
+	return err
+}
+
+func (a *acceptor) track(sess *session, on bool) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if on && a.closed {
+		return false
+	}
+	if a.sessions == nil {
+		a.sessions = map[*session]bool{}
+	}
+	if on {
+		a.sessions[sess] = true
+	} else {
+		delete(a.sessions, sess)
+	}
+	return true
+}
+
+func (a *acceptor) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	_ = conn.SetReadDeadline(time.Now().Add(logonTimeout))
+	m, err := Read(r)
+	if err != nil || m.Type() != MsgLogon || m.Get(TagTargetCompID) != a.compID {
+		return
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+	remote := m.Get(TagSenderCompID)
+	hb := m.Int(TagHeartBtInt)
+	sess := newSession(conn, r, a.compID, remote, time.Duration(max(hb, 1))*time.Second, a.logf)
+	if !a.track(sess, true) {
+		return
+	}
+	defer a.track(sess, false)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sess.write()
+	}()
+	// a queued Logout is written before the writer disconnects
+	defer func() {
+		if !sess.loggedOut.Load() {
+			sess.close()
+		}
+		<-done
+	}()
+
+	p := a.logon(remote, m.Get(TagPassword))
+	reply := New(MsgLogon).Add(TagEncryptMethod, 0).Add(TagHeartBtInt, hb)
+	if m.Get(TagResetSeqNumFlag) == "Y" {
+		reply = reply.Add(TagResetSeqNumFlag, true)
+	}
+	switch {
+	case p == nil:
+		a.logf("fix: logon from %s refused", remote)
+		sess.logout("logon refused")
+		return
+	case hb < 1 || hb > 300:
+		sess.logout("HeartBtInt must be 1 to 300 seconds")
+		return
+	case m.Int(TagMsgSeqNum) != 1 && m.Get(TagResetSeqNumFlag) != "Y":
+		sess.logout("Logon must have MsgSeqNum 1 or ResetSeqNumFlag")
+		return
+	}
+	sess.inSeq = m.Int(TagMsgSeqNum) + 1
+	if !p.attach(sess, reply) {
+		sess.logout("already logged on")
+		return
+	}
+	defer p.detach(sess)
+	a.logf("fix: %s logged on to %s", remote, a.compID)
+	a.serve(p, sess)
+}
+
+// equal compares passwords in constant time.
+func equal(got, want string) bool { return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1 }
+
+// reject answers an application message a session does not take.
+func reject(s *session, m Message, text string) {
+	s.send(New(MsgReject).Add(TagRefSeqNum, m.Get(TagMsgSeqNum)).Add(TagSessionRejReason, 11).Add(TagText, text))
+}
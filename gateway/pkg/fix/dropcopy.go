@@ -0,0 +1,232 @@
+package fix
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/decimal"
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/metrics"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+var (
+	copyConnected = metrics.Default.GaugeVec("helix_fix_dropcopy_connected",
+		"1 while the drop copy client is logged on.", "client")
+	copyDropped = metrics.Default.CounterVec("helix_fix_dropcopy_dropped_total",
+		"Drop copy reports dropped because the client stayed logged off past the backlog.", "client")
+)
+
+// DropCopyClient is a compliance or reconciliation endpoint allowed to log
+// on for copies of executions.
+type DropCopyClient struct {
+	CompID   string
+	Password string
+	// Accounts limits the copies to orders sent by these hosted strategies
+	// and clients (empty = all).
+	Accounts []string
+}
+
+// DropCopy is an executor.Journal that copies every order opening,
+// cancel and fill to its logged-on clients as execution reports marked
+// CopyMsgIndicator. Reports for a client that is logged off wait, up to
+// sendBuffer of them, and go out after its next Logon; sessions take no
+// application messages.
+type DropCopy struct {
+	acc     *acceptor
+	clients []*copyClient
+	start   string
+
+	mu     sync.Mutex
+	orders map[string]executor.Order
+	execs  int
+
+	// Logf reports session events; it defaults to discarding them.
+	Logf func(format string, args ...any)
+}
+
+func NewDropCopy(compID string, clients []DropCopyClient) (*DropCopy, error) {
+	if compID == "" {
+		compID = DefaultCompID
+	}
+	d := &DropCopy{start: strconv.FormatInt(time.Now().Unix(), 36), orders: map[string]executor.Order{},
+		Logf: func(string, ...any) {}}
+	d.acc = &acceptor{compID: compID, logf: func(format string, args ...any) { d.Logf(format, args...) },
+		logon: d.logon, serve: d.serve}
+	for _, c := range clients {
+		switch {
+		case c.CompID == "":
+			return nil, errors.New("fix drop copy: client without a CompID")
+		case c.CompID == compID:
+			return nil, fmt.Errorf("fix drop copy: client CompID %s is the gateway's own", c.CompID)
+		case d.find(c.CompID) != nil:
+			return nil, fmt.Errorf("fix drop copy: duplicate client %s", c.CompID)
+		case c.Password == "":
+			return nil, fmt.Errorf("fix drop copy: client %s has no password", c.CompID)
+		}
+		d.clients = append(d.clients, &copyClient{DropCopyClient: c, d: d})
+	}
+	return d, nil
+}
+
+func (d *DropCopy) find(compID string) *copyClient {
+	for _, c := range d.clients {
+		if c.CompID == compID {
+			return c
+		}
+	}
+	return nil
+}
+
+// Listen accepts connections on addr until Close.
+func (d *DropCopy) Listen(addr string) error { return d.acc.listen(addr) }
+
+// Addr is the listening address, once Listen has succeeded.
+func (d *DropCopy) Addr() net.Addr { return d.acc.addr() }
+
+// Close stops accepting, logs every session out and waits for them to end.
+func (d *DropCopy) Close() error { return d.acc.close() }
+
+func (d *DropCopy) logon(compID, password string) peer {
+	c := d.find(compID)
+	if c == nil || !equal(password, c.Password) {
+		return nil
+	}
+	return c
+}
+
+func (d *DropCopy) serve(p peer, sess *session) {
+	for {
+		m, err := sess.next()
+		if err != nil {
+			if !errors.Is(err, errLoggedOut) {
+				d.Logf("fix drop copy: %s: %v", sess.remote, err)
+			}
+			return
+		}
+		reject(sess, m, "drop copy sessions take no application messages")
+	}
+}
+
+// Order reports an order opening (New) or being canceled (Canceled);
+// fills are reported by Fill, which follows.
+func (d *DropCopy) Order(o executor.Order) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, seen := d.orders[o.ID]
+	switch {
+	case o.Status == executor.StatusCancelled:
+		delete(d.orders, o.ID)
+		d.send(o, d.report(o, ExecCanceled, StatusCanceled, 0))
+	case !seen && o.Status == executor.StatusOpen && o.Filled == 0:
+		d.orders[o.ID] = o
+		d.send(o, d.report(o, ExecNew, StatusNew, o.Size))
+	default:
+		// a fill, or an order restored at startup and first seen filling
+		d.orders[o.ID] = o
+	}
+}
+
+func (d *DropCopy) Fill(f transport.Fill, _ executor.Position, at time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	o, ok := d.orders[f.OrderID]
+	if !ok {
+		return
+	}
+	status, leaves := StatusPartiallyFilled, (decimal.FromFloat(o.Size) - decimal.FromFloat(o.Filled)).Float()
+	if o.Status == executor.StatusFilled {
+		status, leaves = StatusFilled, 0
+		delete(d.orders, o.ID)
+	}
+	d.send(o, d.report(o, ExecTrade, status, leaves).Set(TagTransactTime, at).
+		Add(TagLastPx, f.Price).Add(TagLastQty, f.Qty).Add(TagLastMkt, f.Venue))
+}
+
+func (d *DropCopy) Route(transport.RouteDecision, error) {}
+
+// report is a copied ExecutionReport for o; the order ID stands in for the
+// ClOrdID, which only the sending client knows.
+func (d *DropCopy) report(o executor.Order, execType, status string, leaves float64) Message {
+	d.execs++
+	m := New(MsgExecutionReport).Add(TagOrderID, o.ID).Add(TagClOrdID, o.ID).
+		Add(TagExecID, "D"+d.start+"-"+strconv.Itoa(d.execs)).Add(TagExecType, execType).Add(TagOrdStatus, status)
+	if o.Account != "" {
+		m = m.Add(TagAccount, o.Account)
+	}
+	side := SideBuy
+	if o.Side == "SELL" {
+		side = SideSell
+	}
+	return m.Add(TagSymbol, o.Symbol).Add(TagSide, side).Add(TagOrderQty, o.Size).Add(TagCumQty, o.Filled).
+		Add(TagLeavesQty, leaves).Add(TagAvgPx, o.AvgPrice).Add(TagExDestination, o.Venue).
+		Add(TagTransactTime, o.UpdatedAt).Add(TagCopyMsgIndicator, true)
+}
+
+// send copies m, about o, to every client following o's account.
+func (d *DropCopy) send(o executor.Order, m Message) {
+	for _, c := range d.clients {
+		if len(c.Accounts) == 0 || containsString(c.Accounts, o.Account) {
+			c.deliver(m)
+		}
+	}
+}
+
+// copyClient is a drop copy client; its session and backlog are guarded
+// by the DropCopy's mutex.
+type copyClient struct {
+	DropCopyClient
+	d       *DropCopy
+	sess    *session
+	backlog []Message
+}
+
+func (c *copyClient) attach(s *session, reply Message) bool {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	if c.sess != nil {
+		return false
+	}
+	s.send(reply)
+	for _, m := range c.backlog {
+		s.send(m)
+	}
+	c.backlog = nil
+	c.sess = s
+	copyConnected.With(c.CompID).Set(1)
+	return true
+}
+
+func (c *copyClient) detach(s *session) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	if c.sess == s {
+		c.sess = nil
+		copyConnected.With(c.CompID).Set(0)
+	}
+}
+
+// deliver sends m, or keeps it for the next session.
+func (c *copyClient) deliver(m Message) {
+	if c.sess != nil && c.sess.send(m) {
+		return
+	}
+	if len(c.backlog) == sendBuffer {
+		c.backlog = c.backlog[1:]
+		copyDropped.With(c.CompID).Inc()
+	}
+	c.backlog = append(c.backlog, m)
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
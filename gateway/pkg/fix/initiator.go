@@ -0,0 +1,142 @@
+package fix
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// InitiatorConfig is how an initiator logs on.
+type InitiatorConfig struct {
+	SenderCompID string
+	// TargetCompID defaults to DefaultCompID.
+	TargetCompID string
+	Password     string
+	// Heartbeat defaults to 30s; whole seconds are sent.
+	Heartbeat time.Duration
+	// Logf reports session events; it defaults to discarding them.
+	Logf func(format string, args ...any)
+}
+
+// Initiator is the client end of a session, for tools and tests that
+// enter orders over FIX.
+type Initiator struct {
+	sess *session
+	msgs chan Message
+	err  error
+	done chan struct{}
+}
+
+// Dial connects to addr and logs on; it fails if the logon is refused.
+func Dial(ctx context.Context, addr string, cfg InitiatorConfig) (*Initiator, error) {
+	if cfg.TargetCompID == "" {
+		cfg.TargetCompID = DefaultCompID
+	}
+	if cfg.Heartbeat < time.Second {
+		cfg.Heartbeat = 30 * time.Second
+	}
+	if cfg.Logf == nil {
+		cfg.Logf = func(string, ...any) {}
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+	sess := newSession(conn, r, cfg.SenderCompID, cfg.TargetCompID, cfg.Heartbeat.Truncate(time.Second), cfg.Logf)
+	i := &Initiator{sess: sess, msgs: make(chan Message, sendBuffer), done: make(chan struct{})}
+	go sess.write()
+	sess.send(New(MsgLogon).Add(TagEncryptMethod, 0).Add(TagHeartBtInt, int(cfg.Heartbeat/time.Second)).
+		Add(TagResetSeqNumFlag, true).Add(TagPassword, cfg.Password))
+
+	deadline := time.Now().Add(logonTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetReadDeadline(deadline)
+	m, err := Read(r)
+	if err != nil {
+		sess.close()
+		return nil, fmt.Errorf("fix: logon: %w", err)
+	}
+	if m.Type() != MsgLogon {
+		sess.close()
+		if m.Type() == MsgLogout {
+			return nil, fmt.Errorf("fix: logon refused: %s", m.Get(TagText))
+		}
+		return nil, fmt.Errorf("fix: logon answered with MsgType %s", m.Type())
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+	sess.inSeq = m.Int(TagMsgSeqNum) + 1
+	go i.read()
+	return i, nil
+}
+
+func (i *Initiator) read() {
+	defer close(i.done)
+	defer close(i.msgs)
+	for {
+		m, err := i.sess.next()
+		if err != nil {
+			if !errors.Is(err, errLoggedOut) {
+				i.err = err
+			}
+			i.sess.close()
+			return
+		}
+		select {
+		case i.msgs <- m:
+		default:
+			i.err = fmt.Errorf("fix: %d messages unread", sendBuffer)
+			i.sess.close()
+			return
+		}
+	}
+}
+
+// Messages delivers the application messages received: execution
+// reports, cancel rejects and session rejects. It is closed when the
+// session ends.
+func (i *Initiator) Messages() <-chan Message { return i.msgs }
+
+// Send queues an application message; header fields are added.
+func (i *Initiator) Send(m Message) error {
+	if !i.sess.send(m) {
+		return errors.New("fix: session is over")
+	}
+	return nil
+}
+
+// Logout logs out and waits for the session to end; the error is why it
+// ended, if not by logging out.
+func (i *Initiator) Logout() error {
+	i.sess.logout("")
+	<-i.done
+	return i.err
+}
+
+// NewOrderSingle is an order for qty of symbol; price 0 makes it a market
+// order. venue, if set, is sent as ExDestination.
+func NewOrderSingle(clOrdID, symbol, side string, qty, price float64, venue string) Message {
+	m := New(MsgNewOrderSingle).Add(TagClOrdID, clOrdID).Add(TagSymbol, symbol).Add(TagSide, side).
+		Add(TagTransactTime, time.Now()).Add(TagOrderQty, qty)
+	if price > 0 {
+		m = m.Add(TagOrdType, OrdTypeLimit).Add(TagPrice, price)
+	} else {
+		m = m.Add(TagOrdType, OrdTypeMarket)
+	}
+	if venue != "" {
+		m = m.Add(TagExDestination, venue)
+	}
+	return m
+}
+
+// OrderCancelRequest cancels the order sent as origClOrdID.
+func OrderCancelRequest(clOrdID, origClOrdID, symbol, side string) Message {
+	return New(MsgOrderCancelRequest).Add(TagClOrdID, clOrdID).Add(TagOrigClOrdID, origClOrdID).
+		Add(TagSymbol, symbol).Add(TagSide, side).Add(TagTransactTime, time.Now())
+}
@@ -0,0 +1,288 @@
+// Package fix is a FIX 4.4 order-entry gateway: an acceptor that lets
+// institutional clients and legacy OMSes send NewOrderSingle and
+// OrderCancelRequest messages into the executor, and an initiator to
+// connect to one. Only what order entry needs is implemented: logon with
+// a password, heartbeats and test requests, sequence checks, and
+// execution reports. Nothing is persisted, so sequence numbers restart at
+// 1 on every logon and a ResendRequest is answered with a gap fill;
+// clients reconcile from the execution reports they already hold.
+package fix
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BeginString is the only version spoken.
+const BeginString = "FIX.4.4"
+
+// SOH separates fields.
+const SOH = '\x01'
+
+// Tags used by the gateway.
+const (
+	TagAccount          = 1
+	TagAvgPx            = 6
+	TagBeginSeqNo       = 7
+	TagBeginString      = 8
+	TagBodyLength       = 9
+	TagCheckSum         = 10
+	TagClOrdID          = 11
+	TagCumQty           = 14
+	TagEndSeqNo         = 16
+	TagExecID           = 17
+	TagLastPx           = 31
+	TagLastQty          = 32
+	TagLastMkt          = 30
+	TagMsgSeqNum        = 34
+	TagMsgType          = 35
+	TagNewSeqNo         = 36
+	TagOrderID          = 37
+	TagOrderQty         = 38
+	TagOrdStatus        = 39
+	TagOrdType          = 40
+	TagOrigClOrdID      = 41
+	TagPossDupFlag      = 43
+	TagPrice            = 44
+	TagRefSeqNum        = 45
+	TagSenderCompID     = 49
+	TagSendingTime      = 52
+	TagSide             = 54
+	TagSymbol           = 55
+	TagTargetCompID     = 56
+	TagText             = 58
+	TagTransactTime     = 60
+	TagEncryptMethod    = 98
+	TagCxlRejReason     = 102
+	TagExDestination    = 100
+	TagOrdRejReason     = 103
+	TagHeartBtInt       = 108
+	TagTestReqID        = 112
+	TagGapFillFlag      = 123
+	TagResetSeqNumFlag  = 141
+	TagExecType         = 150
+	TagLeavesQty        = 151
+	TagSessionRejReason = 373
+	TagCxlRejResponseTo = 434
+	TagUsername         = 553
+	TagPassword         = 554
+	TagCopyMsgIndicator = 797
+)
+
+// Message types.
+const (
+	MsgHeartbeat          = "0"
+	MsgTestRequest        = "1"
+	MsgResendRequest      = "2"
+	MsgReject             = "3"
+	MsgSequenceReset      = "4"
+	MsgLogout             = "5"
+	MsgExecutionReport    = "8"
+	MsgOrderCancelReject  = "9"
+	MsgLogon              = "A"
+	MsgNewOrderSingle     = "D"
+	MsgOrderCancelRequest = "F"
+)
+
+// Field values.
+const (
+	SideBuy  = "1"
+	SideSell = "2"
+
+	OrdTypeMarket = "1"
+	OrdTypeLimit  = "2"
+
+	ExecNew      = "0"
+	ExecCanceled = "4"
+	ExecRejected = "8"
+	ExecTrade    = "F"
+
+	StatusNew             = "0"
+	StatusPartiallyFilled = "1"
+	StatusFilled          = "2"
+	StatusCanceled        = "4"
+	StatusRejected        = "8"
+)
+
+// timeFormat is UTCTimestamp with milliseconds.
+const timeFormat = "20060102-15:04:05.000"
+
+// maxBody bounds a message body; order entry messages are far smaller.
+const maxBody = 64 << 10
+
+// ErrGarbled is returned for bytes that are not a well-formed message.
+var ErrGarbled = errors.New("fix: garbled message")
+
+// Field is one tag=value pair.
+type Field struct {
+	Tag   int
+	Value string
+}
+
+// Message is a message's fields in order, without BeginString,
+// BodyLength and CheckSum, which Encode adds and Read strips.
+type Message []Field
+
+// New starts a message of type msgType.
+func New(msgType string) Message { return Message{{TagMsgType, msgType}} }
+
+// Type is the MsgType.
+func (m Message) Type() string { return m.Get(TagMsgType) }
+
+// Get returns tag's first value, or "".
+func (m Message) Get(tag int) string {
+	v, _ := m.Lookup(tag)
+	return v
+}
+
+// Lookup returns tag's first value and whether it is present.
+func (m Message) Lookup(tag int) (string, bool) {
+	for _, f := range m {
+		if f.Tag == tag {
+			return f.Value, true
+		}
+	}
+	return "", false
+}
+
+// Int returns tag's value as an integer, 0 when absent or not one.
+func (m Message) Int(tag int) int {
+	n, _ := strconv.Atoi(m.Get(tag))
+	return n
+}
+
+// Add appends tag=value, formatting value as FIX does for strings,
+// integers, floats, booleans (Y/N) and times (UTC with milliseconds).
+func (m Message) Add(tag int, value any) Message {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case int:
+		s = strconv.Itoa(v)
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		s = "N"
+		if v {
+			s = "Y"
+		}
+	case time.Time:
+		s = v.UTC().Format(timeFormat)
+	default:
+		s = fmt.Sprint(v)
+	}
+	return append(m, Field{tag, s})
+}
+
+// Set replaces tag's first value, or appends it when absent.
+func (m Message) Set(tag int, value any) Message {
+	v := New("").Add(tag, value)[1]
+	for i := range m {
+		if m[i].Tag == tag {
+			m[i] = v
+			return m
+		}
+	}
+	return append(m, v)
+}
+
+// Encode frames m: BeginString and BodyLength before it, CheckSum after.
+func (m Message) Encode() []byte {
+	var body bytes.Buffer
+	for _, f := range m {
+		body.WriteString(strconv.Itoa(f.Tag))
+		body.WriteByte('=')
+		body.WriteString(f.Value)
+		body.WriteByte(SOH)
+	}
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "8=%s%c9=%d%c", BeginString, SOH, body.Len(), SOH)
+	out.Write(body.Bytes())
+	fmt.Fprintf(&out, "10=%03d%c", checksum(out.Bytes()), SOH)
+	return out.Bytes()
+}
+
+// String shows m with | for SOH, for logs.
+func (m Message) String() string {
+	return string(bytes.ReplaceAll(m.Encode(), []byte{SOH}, []byte{'|'}))
+}
+
+func checksum(b []byte) int {
+	sum := 0
+	for _, c := range b {
+		sum += int(c)
+	}
+	return sum % 256
+}
+
+// Read reads the next message from r, checking its BeginString,
+// BodyLength and CheckSum.
+func Read(r *bufio.Reader) (Message, error) {
+	begin, err := r.ReadString(SOH)
+	if err != nil {
+		return nil, err
+	}
+	if begin != "8="+BeginString+string(SOH) {
+		return nil, fmt.Errorf("%w: begins %q", ErrGarbled, begin)
+	}
+	length, err := r.ReadString(SOH)
+	if err != nil {
+		return nil, err
+	}
+	n, ok := field(length, "9=")
+	if !ok || n <= 0 || n > maxBody {
+		return nil, fmt.Errorf("%w: body length %q", ErrGarbled, length)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	trailer, err := r.ReadString(SOH)
+	if err != nil {
+		return nil, err
+	}
+	want := (checksum([]byte(begin+length)) + checksum(body)) % 256
+	if sum, ok := field(trailer, "10="); !ok || len(trailer) != 7 || sum != want {
+		return nil, fmt.Errorf("%w: checksum %q, want %03d", ErrGarbled, trailer, want)
+	}
+	return parseBody(body)
+}
+
+// field reads the integer in a "<prefix><digits>SOH" header or trailer
+// field.
+func field(s, prefix string) (int, bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(s[len(prefix):], string(SOH)))
+	return n, err == nil
+}
+
+func parseBody(body []byte) (Message, error) {
+	if len(body) == 0 || body[len(body)-1] != SOH {
+		return nil, fmt.Errorf("%w: body does not end in SOH", ErrGarbled)
+	}
+	var m Message
+	for _, f := range bytes.Split(body[:len(body)-1], []byte{SOH}) {
+		eq := bytes.IndexByte(f, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("%w: field %q", ErrGarbled, f)
+		}
+		tag, err := strconv.Atoi(string(f[:eq]))
+		if err != nil || tag <= 0 {
+			return nil, fmt.Errorf("%w: tag %q", ErrGarbled, f[:eq])
+		}
+		m = append(m, Field{tag, string(f[eq+1:])})
+	}
+	if len(m) == 0 || m[0].Tag != TagMsgType {
+		return nil, fmt.Errorf("%w: MsgType is not the first body field", ErrGarbled)
+	}
+	return m, nil
+}
@@ -0,0 +1,363 @@
+package fix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/decimal"
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/metrics"
+	"github.com/helix-lab/helix/gateway/pkg/stratapi"
+	"github.com/helix-lab/helix/gateway/pkg/strategy"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// DefaultCompID is the gateway's CompID unless configured.
+const DefaultCompID = "HELIX"
+
+var (
+	connected = metrics.Default.GaugeVec("helix_fix_connected",
+		"1 while the FIX client is logged on.", "client")
+	requests = metrics.Default.CounterVec("helix_fix_requests_total",
+		"FIX order entry requests, by type (new, cancel) and outcome (accepted, rejected).", "client", "type", "outcome")
+)
+
+// Client is one counterparty allowed to log on.
+type Client struct {
+	CompID   string
+	Password string
+	// Symbols limits what it trades (empty = all).
+	Symbols []string
+	Limits  stratapi.Limits
+}
+
+// Server is the acceptor. Each client is a hosted strategy named after its
+// CompID; its orders run on the gateway loop through Exec and pass the
+// client's own limits before the executor's, and its fills come back as
+// execution reports.
+type Server struct {
+	compID  string
+	tracker *executor.Tracker
+	sender  *executor.OrderSender
+	clients []*client
+	reqs    chan Request
+	start   string
+	execs   int
+
+	acc *acceptor
+
+	// Logf reports session events; it defaults to discarding them.
+	Logf func(format string, args ...any)
+}
+
+// NewServer builds a server answering as compID; orders are looked up in
+// tracker and canceled through sender.
+func NewServer(compID string, clients []Client, tracker *executor.Tracker, sender *executor.OrderSender) (*Server, error) {
+	if compID == "" {
+		compID = DefaultCompID
+	}
+	s := &Server{compID: compID, tracker: tracker, sender: sender, reqs: make(chan Request, 256),
+		start: strconv.FormatInt(time.Now().Unix(), 36), Logf: func(string, ...any) {}}
+	s.acc = &acceptor{compID: compID, logf: func(format string, args ...any) { s.Logf(format, args...) },
+		logon: s.logon, serve: s.serve}
+	for _, c := range clients {
+		switch {
+		case c.CompID == "":
+			return nil, errors.New("fix: client without a CompID")
+		case c.CompID == compID:
+			return nil, fmt.Errorf("fix: client CompID %s is the gateway's own", c.CompID)
+		case s.find(c.CompID) != nil:
+			return nil, fmt.Errorf("fix: duplicate client %s", c.CompID)
+		case c.Password == "":
+			return nil, fmt.Errorf("fix: client %s has no password", c.CompID)
+		}
+		s.clients = append(s.clients, &client{Client: c, srv: s, guard: stratapi.NewGuard(c.CompID, c.Symbols, c.Limits),
+			orders: map[string]*order{}, byClOrd: map[string]*order{}})
+	}
+	return s, nil
+}
+
+func (s *Server) find(compID string) *client {
+	for _, c := range s.clients {
+		if c.CompID == compID {
+			return c
+		}
+	}
+	return nil
+}
+
+// Strategies adds every client to a strategy host under its CompID.
+func (s *Server) Strategies(add func(strategy.Config, strategy.Strategy)) {
+	for _, c := range s.clients {
+		add(strategy.Config{Name: c.CompID, Symbols: c.Symbols}, c)
+	}
+}
+
+// Listen accepts connections on addr until Close.
+func (s *Server) Listen(addr string) error { return s.acc.listen(addr) }
+
+// Addr is the listening address, once Listen has succeeded.
+func (s *Server) Addr() net.Addr { return s.acc.addr() }
+
+// Close stops accepting, logs every session out and waits for them to end.
+func (s *Server) Close() error { return s.acc.close() }
+
+// logon authenticates a client; nil means it is refused.
+func (s *Server) logon(compID, password string) peer {
+	c := s.find(compID)
+	if c == nil || !equal(password, c.Password) {
+		return nil
+	}
+	return c
+}
+
+func (s *Server) serve(p peer, sess *session) {
+	c := p.(*client)
+	for {
+		m, err := sess.next()
+		if err != nil {
+			if !errors.Is(err, errLoggedOut) {
+				s.Logf("fix: %s: %v", c.CompID, err)
+			}
+			return
+		}
+		switch m.Type() {
+		case MsgNewOrderSingle, MsgOrderCancelRequest:
+			select {
+			case s.reqs <- Request{c: c, msg: m}:
+			case <-sess.Done():
+				return
+			}
+		default:
+			reject(sess, m, "unsupported MsgType "+m.Type())
+		}
+	}
+}
+
+// Request is a client's NewOrderSingle or OrderCancelRequest waiting for
+// Exec.
+type Request struct {
+	c   *client
+	msg Message
+}
+
+// Requests delivers client requests; pass each to Exec on the gateway
+// loop.
+func (s *Server) Requests() <-chan Request { return s.reqs }
+
+// Exec runs req through the client's strategy handle and answers with an
+// execution report or a cancel reject.
+func (s *Server) Exec(ctx context.Context, req Request, host *strategy.Host) {
+	switch req.msg.Type() {
+	case MsgNewOrderSingle:
+		s.newOrder(ctx, req.c, req.msg, host)
+	case MsgOrderCancelRequest:
+		s.cancel(ctx, req.c, req.msg)
+	}
+}
+
+func (s *Server) execID() string {
+	s.execs++
+	return s.start + "-" + strconv.Itoa(s.execs)
+}
+
+// order is a client order as the server reports it.
+type order struct {
+	id      string
+	clOrdID string
+	symbol  string
+	side    string
+	ordType string
+	qty     float64
+	price   float64
+}
+
+func (s *Server) newOrder(ctx context.Context, c *client, m Message, host *strategy.Host) {
+	o := &order{clOrdID: m.Get(TagClOrdID), symbol: strings.ToUpper(m.Get(TagSymbol)), side: m.Get(TagSide),
+		ordType: m.Get(TagOrdType), id: "NONE"}
+	err := func() error {
+		switch {
+		case o.clOrdID == "":
+			return errors.New("ClOrdID missing")
+		case c.byClOrd[o.clOrdID] != nil:
+			return fmt.Errorf("duplicate ClOrdID %s", o.clOrdID)
+		case o.symbol == "":
+			return errors.New("Symbol missing")
+		case o.side != SideBuy && o.side != SideSell:
+			return fmt.Errorf("Side must be 1 (buy) or 2 (sell), got %q", o.side)
+		}
+		qty, err := decimal.Parse(m.Get(TagOrderQty))
+		if err != nil || qty <= 0 {
+			return fmt.Errorf("OrderQty %q is not a positive number", m.Get(TagOrderQty))
+		}
+		o.qty = qty.Float()
+		switch o.ordType {
+		case OrdTypeMarket:
+		case OrdTypeLimit:
+			px, err := decimal.Parse(m.Get(TagPrice))
+			if err != nil || px <= 0 {
+				return fmt.Errorf("Price %q is not a positive number", m.Get(TagPrice))
+			}
+			o.price = px.Float()
+		default:
+			return fmt.Errorf("OrdType must be 1 (market) or 2 (limit), got %q", o.ordType)
+		}
+		h, ok := host.Handle(c.CompID)
+		if !ok {
+			return fmt.Errorf("client %s is not hosted", c.CompID)
+		}
+		a := transport.Action{Symbol: o.symbol, Side: side(o.side), Size: o.qty, Price: o.price,
+			Venue: strings.ToUpper(m.Get(TagExDestination))}
+		if err := c.guard.Check(a, h, host.OpenOrders(c.CompID), time.Now()); err != nil {
+			return err
+		}
+		id, err := h.Submit(ctx, a)
+		if err != nil {
+			return err
+		}
+		if id == "" {
+			return errors.New("dry run: routed but not sent")
+		}
+		o.id = id
+		return nil
+	}()
+	if err != nil {
+		requests.With(c.CompID, "new", "rejected").Inc()
+		c.send(s.report(o, ExecRejected, StatusRejected, 0, 0, 0).Add(TagOrdRejReason, 99).Add(TagText, err.Error()))
+		return
+	}
+	requests.With(c.CompID, "new", "accepted").Inc()
+	c.orders[o.id] = o
+	c.byClOrd[o.clOrdID] = o
+	c.send(s.report(o, ExecNew, StatusNew, 0, o.qty, 0))
+}
+
+func (s *Server) cancel(ctx context.Context, c *client, m Message) {
+	clOrdID, orig := m.Get(TagClOrdID), m.Get(TagOrigClOrdID)
+	reject := func(o *order, status string, reason int, text string) {
+		requests.With(c.CompID, "cancel", "rejected").Inc()
+		id := "NONE"
+		if o != nil {
+			id = o.id
+		}
+		c.send(New(MsgOrderCancelReject).Add(TagOrderID, id).Add(TagClOrdID, clOrdID).Add(TagOrigClOrdID, orig).
+			Add(TagOrdStatus, status).Add(TagCxlRejResponseTo, 1).Add(TagCxlRejReason, reason).Add(TagText, text))
+	}
+	o := c.byClOrd[orig]
+	switch {
+	case clOrdID == "":
+		reject(o, StatusRejected, 99, "ClOrdID missing")
+		return
+	case o == nil:
+		reject(nil, StatusRejected, 1, "unknown order "+orig)
+		return
+	}
+	st, err := s.sender.Cancel(ctx, o.id)
+	if err != nil {
+		status, reason := StatusNew, 99
+		switch {
+		case st.Status == executor.StatusFilled:
+			status, reason = StatusFilled, 0
+		case st.Status == executor.StatusCancelled:
+			status, reason = StatusCanceled, 0
+		case st.Filled > 0:
+			status = StatusPartiallyFilled
+		}
+		reject(o, status, reason, err.Error())
+		return
+	}
+	requests.With(c.CompID, "cancel", "accepted").Inc()
+	c.send(s.report(o, ExecCanceled, StatusCanceled, st.Filled, 0, st.AvgPrice).Add(TagOrigClOrdID, orig).
+		Set(TagClOrdID, clOrdID))
+}
+
+// report is an ExecutionReport for o.
+func (s *Server) report(o *order, execType, status string, cum, leaves, avg float64) Message {
+	m := New(MsgExecutionReport).Add(TagOrderID, o.id).Add(TagClOrdID, o.clOrdID).Add(TagExecID, s.execID()).
+		Add(TagExecType, execType).Add(TagOrdStatus, status).Add(TagSymbol, o.symbol).Add(TagSide, o.side).
+		Add(TagOrdType, o.ordType).Add(TagOrderQty, o.qty)
+	if o.price > 0 {
+		m = m.Add(TagPrice, o.price)
+	}
+	return m.Add(TagCumQty, cum).Add(TagLeavesQty, leaves).Add(TagAvgPx, avg).Add(TagTransactTime, time.Now())
+}
+
+// client is a counterparty as the host sees it. Callbacks and Exec run on
+// the gateway loop; sess is also swapped by connection handlers.
+type client struct {
+	strategy.Base
+	Client
+	srv   *Server
+	guard *stratapi.Guard
+
+	// gateway loop only
+	orders  map[string]*order
+	byClOrd map[string]*order
+
+	mu   sync.Mutex
+	sess *session
+}
+
+func (c *client) attach(s *session, reply Message) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sess != nil {
+		return false
+	}
+	s.send(reply)
+	c.sess = s
+	connected.With(c.CompID).Set(1)
+	return true
+}
+
+func (c *client) detach(s *session) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sess == s {
+		c.sess = nil
+		connected.With(c.CompID).Set(0)
+	}
+}
+
+// send queues m to the logged-on session; with none it is lost, as
+// nothing is stored for a resend.
+func (c *client) send(m Message) {
+	c.mu.Lock()
+	s := c.sess
+	c.mu.Unlock()
+	if s == nil || !s.send(m) {
+		c.srv.Logf("fix: %s is not logged on, %s report for %s lost", c.CompID, m.Get(TagExecType), m.Get(TagOrderID))
+	}
+}
+
+func (c *client) OnFill(_ context.Context, _ strategy.Handle, f transport.Fill) {
+	c.guard.Fill(f)
+	o := c.orders[f.OrderID]
+	if o == nil {
+		return
+	}
+	st, ok := c.srv.tracker.Order(f.OrderID)
+	if !ok {
+		return
+	}
+	status := StatusPartiallyFilled
+	leaves := (decimal.FromFloat(st.Size) - decimal.FromFloat(st.Filled)).Float()
+	if st.Status == executor.StatusFilled {
+		status, leaves = StatusFilled, 0
+	}
+	c.send(c.srv.report(o, ExecTrade, status, st.Filled, leaves, st.AvgPrice).
+		Add(TagLastPx, f.Price).Add(TagLastQty, f.Qty).Add(TagLastMkt, f.Venue))
+}
+
+func side(s string) string {
+	if s == SideSell {
+		return "SELL"
+	}
+	return "BUY"
+}
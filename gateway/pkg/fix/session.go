@@ -0,0 +1,247 @@
+package fix
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// sendBuffer is how many messages may wait for a slow peer. Execution
+// reports cannot be dropped, so a session whose queue overflows ends.
+const sendBuffer = 4096
+
+// writeTimeout bounds one write to the peer.
+const writeTimeout = 5 * time.Second
+
+// errLoggedOut ends a session that logged out cleanly.
+var errLoggedOut = errors.New("fix: logged out")
+
+// outMsg is a queued message; gapFrom > 0 makes it the gap fill answering
+// a ResendRequest from that sequence number.
+type outMsg struct {
+	m       Message
+	gapFrom int
+}
+
+// session is one logged-on connection, either end: header fields,
+// sequence numbers, heartbeats, test requests, resend requests and logout.
+// The writer goroutine owns the outbound sequence; next, on the reading
+// goroutine, owns the inbound one.
+type session struct {
+	conn     net.Conn
+	r        *bufio.Reader
+	local    string
+	remote   string
+	interval time.Duration
+	logf     func(format string, args ...any)
+
+	out       chan outMsg
+	closed    chan struct{}
+	once      sync.Once
+	lastIn    atomic.Int64
+	loggedOut atomic.Bool
+
+	// reading goroutine only
+	inSeq    int
+	resendTo int
+}
+
+func newSession(conn net.Conn, r *bufio.Reader, local, remote string, interval time.Duration, logf func(string, ...any)) *session {
+	s := &session{conn: conn, r: r, local: local, remote: remote, interval: interval, logf: logf,
+		out: make(chan outMsg, sendBuffer), closed: make(chan struct{}), inSeq: 1}
+	s.lastIn.Store(time.Now().UnixNano())
+	return s
+}
+
+// send queues m. It reports false once the session is over; a full queue
+// ends the session.
+func (s *session) send(m Message) bool { return s.enqueue(outMsg{m: m}) }
+
+func (s *session) enqueue(o outMsg) bool {
+	select {
+	case <-s.closed:
+		return false
+	default:
+	}
+	select {
+	case s.out <- o:
+		return true
+	default:
+		s.logf("fix: %s fell %d messages behind, disconnecting", s.remote, sendBuffer)
+		s.close()
+		return false
+	}
+}
+
+// logout queues a Logout; the writer disconnects once it is written.
+func (s *session) logout(text string) {
+	if s.loggedOut.Swap(true) {
+		return
+	}
+	m := New(MsgLogout)
+	if text != "" {
+		m = m.Add(TagText, text)
+	}
+	s.send(m)
+}
+
+func (s *session) close() {
+	s.once.Do(func() {
+		close(s.closed)
+		_ = s.conn.Close()
+	})
+}
+
+// Done is closed when the session is over.
+func (s *session) Done() <-chan struct{} { return s.closed }
+
+// write sends queued messages and keeps the connection alive: a Heartbeat
+// after interval without sending, a TestRequest after 1.2 intervals
+// without receiving, and a disconnect after two more.
+func (s *session) write() {
+	defer s.close()
+	seq := 1
+	lastOut := time.Now()
+	var testSent time.Time
+	tick := time.NewTicker(max(s.interval/4, 10*time.Millisecond))
+	defer tick.Stop()
+	put := func(m Message, num int, possDup bool) bool {
+		now := time.Now()
+		full := Message{m[0], {TagSenderCompID, s.local}, {TagTargetCompID, s.remote},
+			{TagMsgSeqNum, strconv.Itoa(num)}, {TagSendingTime, now.UTC().Format(timeFormat)}}
+		if possDup {
+			full = full.Add(TagPossDupFlag, true)
+		}
+		full = append(full, m[1:]...)
+		_ = s.conn.SetWriteDeadline(now.Add(writeTimeout))
+		if _, err := s.conn.Write(full.Encode()); err != nil {
+			return false
+		}
+		lastOut = now
+		return true
+	}
+	tests := 0
+	for {
+		select {
+		case o := <-s.out:
+			if o.gapFrom > 0 {
+				m := New(MsgSequenceReset).Add(TagGapFillFlag, true).Add(TagNewSeqNo, seq)
+				if !put(m, o.gapFrom, true) {
+					return
+				}
+				continue
+			}
+			if !put(o.m, seq, false) {
+				return
+			}
+			seq++
+			if o.m.Type() == MsgLogout {
+				return
+			}
+		case now := <-tick.C:
+			silent := now.Sub(time.Unix(0, s.lastIn.Load()))
+			switch {
+			case silent > 2*s.interval+s.interval/5:
+				s.logf("fix: no messages from %s for %s, disconnecting", s.remote, silent.Truncate(time.Millisecond))
+				return
+			case silent > s.interval+s.interval/5 && testSent.IsZero():
+				tests++
+				testSent = now
+				if !put(New(MsgTestRequest).Add(TagTestReqID, "TEST"+strconv.Itoa(tests)), seq, false) {
+					return
+				}
+				seq++
+			case silent <= s.interval:
+				testSent = time.Time{}
+			}
+			if now.Sub(lastOut) >= s.interval {
+				if !put(New(MsgHeartbeat), seq, false) {
+					return
+				}
+				seq++
+			}
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+// next returns the next application message, handling session-level ones
+// on the way. It fails once the session is over.
+func (s *session) next() (Message, error) {
+	for {
+		m, err := Read(s.r)
+		if err != nil {
+			if s.loggedOut.Load() {
+				return nil, errLoggedOut
+			}
+			return nil, err
+		}
+		s.lastIn.Store(time.Now().UnixNano())
+		if m.Get(TagSenderCompID) != s.remote || m.Get(TagTargetCompID) != s.local {
+			s.logout("CompID problem")
+			return nil, fmt.Errorf("fix: message from %s to %s on the %s session", m.Get(TagSenderCompID), m.Get(TagTargetCompID), s.remote)
+		}
+		// a reset ignores MsgSeqNum
+		if m.Type() == MsgSequenceReset && m.Get(TagGapFillFlag) != "Y" {
+			if n := m.Int(TagNewSeqNo); n > s.inSeq {
+				s.inSeq = n
+			}
+			continue
+		}
+		seq := m.Int(TagMsgSeqNum)
+		switch {
+		case seq <= 0:
+			s.logout("MsgSeqNum missing")
+			return nil, fmt.Errorf("fix: %s sent no MsgSeqNum", s.remote)
+		case seq < s.inSeq:
+			if m.Get(TagPossDupFlag) == "Y" {
+				continue
+			}
+			s.logout(fmt.Sprintf("MsgSeqNum too low, expecting %d but received %d", s.inSeq, seq))
+			return nil, fmt.Errorf("fix: %s sequence went back to %d, expected %d", s.remote, seq, s.inSeq)
+		case seq > s.inSeq:
+			// the resent messages include this one, so it is dropped
+			if s.resendTo == 0 {
+				s.send(New(MsgResendRequest).Add(TagBeginSeqNo, s.inSeq).Add(TagEndSeqNo, 0))
+			}
+			s.resendTo = max(s.resendTo, seq)
+			if m.Type() != MsgLogout {
+				continue
+			}
+		default:
+			s.inSeq++
+			if s.inSeq > s.resendTo {
+				s.resendTo = 0
+			}
+		}
+		switch m.Type() {
+		case MsgSequenceReset:
+			if n := m.Int(TagNewSeqNo); n > s.inSeq {
+				s.inSeq = n
+			}
+		case MsgHeartbeat:
+		case MsgTestRequest:
+			s.send(New(MsgHeartbeat).Add(TagTestReqID, m.Get(TagTestReqID)))
+		case MsgResendRequest:
+			// nothing is stored to resend
+			s.enqueue(outMsg{gapFrom: max(m.Int(TagBeginSeqNo), 1)})
+		case MsgLogout:
+			if text := m.Get(TagText); text != "" {
+				s.logf("fix: %s logged out: %s", s.remote, text)
+			}
+			s.logout("")
+			return nil, errLoggedOut
+		case MsgLogon:
+			s.logout("already logged on")
+			return nil, fmt.Errorf("fix: %s sent a second Logon", s.remote)
+		default:
+			return m, nil
+		}
+	}
+}
@@ -0,0 +1,190 @@
+// Package fundarb ranks funding-rate trades across venues: shorting the
+// perp that pays the most funding against a long in a cheaper perp
+// (funding spread) or in spot (cash and carry). Opportunities are priced
+// at the touch of the consolidated books and net of round-trip taker fees.
+//
+// Rates are compared per funding period, so venues are assumed to settle
+// on the same schedule.
+package fundarb
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/metrics"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/router"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// Opportunity kinds.
+const (
+	KindFundingSpread = "funding_spread"
+	KindCashCarry     = "cash_carry"
+)
+
+var bestNet = metrics.Default.GaugeVec("helix_arb_best_net_bps", "Net bps of the best funding opportunity at the last evaluation.", "kind", "symbol")
+
+type Config struct {
+	// Symbols limits the monitor (empty = every symbol with a funding rate).
+	Symbols []string
+	// Spot names the spot venues cash-and-carry trades may buy on.
+	Spot []string
+	Fees router.FeeModel
+	// Periods is how many funding periods a trade is held (default 3).
+	Periods int
+	// MinNetBps drops opportunities that net less.
+	MinNetBps float64
+	// MaxAge ignores funding rates older than this (default 15m).
+	MaxAge time.Duration
+	// Top caps how many opportunities Evaluate returns (default 5).
+	Top int
+}
+
+type rateKey struct{ venue, symbol string }
+
+// Monitor keeps the latest funding rate per venue and symbol. It is safe
+// for concurrent use.
+type Monitor struct {
+	cfg   Config
+	books *orderbook.Manager
+
+	mu    sync.Mutex
+	rates map[rateKey]transport.FundingRate
+}
+
+func New(cfg Config, books *orderbook.Manager) *Monitor {
+	if cfg.Periods <= 0 {
+		cfg.Periods = 3
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = 15 * time.Minute
+	}
+	if cfg.Top <= 0 {
+		cfg.Top = 5
+	}
+	return &Monitor{cfg: cfg, books: books, rates: make(map[rateKey]transport.FundingRate)}
+}
+
+func (m *Monitor) Funding(fr transport.FundingRate) {
+	if len(m.cfg.Symbols) > 0 && !contains(m.cfg.Symbols, fr.Symbol) {
+		return
+	}
+	m.mu.Lock()
+	m.rates[rateKey{fr.Venue, fr.Symbol}] = fr
+	m.mu.Unlock()
+}
+
+// Evaluate prices every pair at now's books and returns the best
+// opportunities, highest net first, ranked from 1.
+func (m *Monitor) Evaluate(now time.Time) []transport.Opportunity {
+	bySymbol := map[string][]transport.FundingRate{}
+	m.mu.Lock()
+	for k, fr := range m.rates {
+		if fr.TsMs > 0 && now.Sub(time.UnixMilli(fr.TsMs)) > m.cfg.MaxAge {
+			continue
+		}
+		bySymbol[k.symbol] = append(bySymbol[k.symbol], fr)
+	}
+	m.mu.Unlock()
+
+	var all []transport.Opportunity
+	for symbol, rates := range bySymbol {
+		spot, perp := m.split(symbol)
+		best := map[string]float64{}
+		add := func(o transport.Opportunity, ok bool) {
+			if !ok {
+				return
+			}
+			o.TsMs, o.Symbol, o.Periods = now.UnixMilli(), symbol, m.cfg.Periods
+			if n, seen := best[o.Kind]; !seen || o.NetBps > n {
+				best[o.Kind] = o.NetBps
+			}
+			if o.NetBps >= m.cfg.MinNetBps {
+				all = append(all, o)
+			}
+		}
+		for i, a := range rates {
+			for _, b := range rates[i+1:] {
+				short, long := a, b
+				if b.Rate > a.Rate {
+					short, long = b, a
+				}
+				add(m.price(KindFundingSpread, short.Rate-long.Rate, short.Venue, long.Venue, perp, perp))
+			}
+			if a.Rate <= 0 {
+				continue
+			}
+			for _, venue := range m.cfg.Spot {
+				add(m.price(KindCashCarry, a.Rate, a.Venue, venue, perp, spot))
+			}
+		}
+		for kind, n := range best {
+			bestNet.With(kind, symbol).Set(n)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].NetBps != all[j].NetBps {
+			return all[i].NetBps > all[j].NetBps
+		}
+		if all[i].Symbol != all[j].Symbol {
+			return all[i].Symbol < all[j].Symbol
+		}
+		return all[i].LongVenue < all[j].LongVenue
+	})
+	if len(all) > m.cfg.Top {
+		all = all[:m.cfg.Top]
+	}
+	for i := range all {
+		all[i].Rank = i + 1
+	}
+	return all
+}
+
+// split sorts symbol's books by venue into spot (the spot market or a
+// Config.Spot venue) and perpetuals, so one venue's two markets stay apart.
+func (m *Monitor) split(symbol string) (spot, perp map[string]orderbook.Level) {
+	spot, perp = map[string]orderbook.Level{}, map[string]orderbook.Level{}
+	for _, cat := range m.books.Categories(symbol) {
+		for venue, l := range m.books.MarketSnapshot(symbol, cat) {
+			if cat == "spot" || contains(m.cfg.Spot, venue) {
+				spot[venue] = l
+			} else {
+				perp[venue] = l
+			}
+		}
+	}
+	return spot, perp
+}
+
+// price sells short's bid in shorts and buys long's ask in longs; ok is
+// false without both books.
+func (m *Monitor) price(kind string, rate float64, short, long string, shorts, longs map[string]orderbook.Level) (transport.Opportunity, bool) {
+	s, okS := shorts[short]
+	l, okL := longs[long]
+	if !okS || !okL || s.BestBid <= 0 || l.BestAsk <= 0 {
+		return transport.Opportunity{}, false
+	}
+	mid := (s.BestBid + l.BestAsk) / 2
+	o := transport.Opportunity{
+		Kind:       kind,
+		LongVenue:  long,
+		ShortVenue: short,
+		FundingBps: rate * 1e4,
+		BasisBps:   (s.BestBid - l.BestAsk) / mid * 1e4,
+		// in and out on both legs
+		FeesBps: 2 * (m.cfg.Fees.Taker[short] + m.cfg.Fees.Taker[long]) * 1e4,
+	}
+	o.NetBps = float64(m.cfg.Periods)*o.FundingBps + o.BasisBps - o.FeesBps
+	return o, true
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
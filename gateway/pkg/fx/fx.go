@@ -0,0 +1,116 @@
+// Package fx converts amounts between currencies at rates taken from the
+// venues' own books, so that notional, fees and PnL in USDT, USDC or BTC
+// quoted symbols add up in one base currency wherever the gateway values
+// them: the portfolio, the risk engine and backtest reports.
+package fx
+
+import (
+	"strings"
+
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+)
+
+// quotes are the quote currencies SplitSymbol recognises, longest first.
+var quotes = []string{"FDUSD", "USDT", "USDC", "BUSD", "USD", "EUR", "BTC", "ETH"}
+
+// SplitSymbol splits "BTCUSDT" into its base and quote assets; ok is false
+// for symbols with an unknown quote.
+func SplitSymbol(symbol string) (base, quote string, ok bool) {
+	for _, q := range quotes {
+		if b, found := strings.CutSuffix(symbol, q); found && b != "" {
+			return b, q, true
+		}
+	}
+	return "", "", false
+}
+
+// Rates values assets in Base at the NBBO mid of their books: ASSETBASE,
+// the inverse of BASEASSET, or a cross through one of Via. Spot books are
+// preferred; other categories are used for symbols with no spot book, as
+// perpetuals track it closely. Stables with no book at all are taken at
+// par with each other.
+type Rates struct {
+	Base    string
+	Via     []string
+	Stables []string
+
+	books *orderbook.Manager
+}
+
+func New(books *orderbook.Manager, base string) *Rates {
+	return &Rates{Base: strings.ToUpper(base), Via: []string{"USDT", "USDC", "BTC"},
+		Stables: []string{"USDT", "USDC", "BUSD", "FDUSD", "DAI", "USD"}, books: books}
+}
+
+// Rate is the value of one unit of asset in Base.
+func (r *Rates) Rate(asset string) (float64, bool) {
+	if asset == r.Base {
+		return 1, true
+	}
+	if px, ok := r.pair(asset, r.Base); ok {
+		return px, true
+	}
+	for _, via := range r.Via {
+		if via == asset || via == r.Base {
+			continue
+		}
+		a, okA := r.pair(asset, via)
+		b, okB := r.pair(via, r.Base)
+		if okA && okB {
+			return a * b, true
+		}
+	}
+	if r.stable(asset) && r.stable(r.Base) {
+		return 1, true
+	}
+	return 0, false
+}
+
+// Convert values amount of asset in Base.
+func (r *Rates) Convert(amount float64, asset string) (float64, bool) {
+	px, ok := r.Rate(asset)
+	return amount * px, ok
+}
+
+// ConvertQuote values amount in symbol's quote currency, e.g. the notional
+// or PnL of a position in it, in Base.
+func (r *Rates) ConvertQuote(symbol string, amount float64) (float64, bool) {
+	_, quote, ok := SplitSymbol(symbol)
+	if !ok {
+		return 0, false
+	}
+	return r.Convert(amount, quote)
+}
+
+// pair is the price of one from in to.
+func (r *Rates) pair(from, to string) (float64, bool) {
+	if px, ok := r.mid(from + to); ok {
+		return px, true
+	}
+	if px, ok := r.mid(to + from); ok {
+		return 1 / px, true
+	}
+	return 0, false
+}
+
+// mid prices symbol on its spot market, or its first other one.
+func (r *Rates) mid(symbol string) (float64, bool) {
+	venues := r.books.MarketSnapshot(symbol, "spot")
+	if cats := r.books.Categories(symbol); len(venues) == 0 && len(cats) > 0 {
+		venues = r.books.MarketSnapshot(symbol, cats[0])
+	}
+	l := orderbook.MergeBest(venues)
+	if l.BestBid <= 0 || l.BestAsk <= 0 {
+		return 0, false
+	}
+	return (l.BestBid + l.BestAsk) / 2, true
+}
+
+func (r *Rates) stable(asset string) bool {
+	for _, s := range r.Stables {
+		if s == asset {
+			return true
+		}
+	}
+	return false
+}
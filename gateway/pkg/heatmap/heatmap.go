@@ -0,0 +1,262 @@
+// Package heatmap turns L2 books sampled over time into a time×price
+// liquidity matrix: resting bid and ask size per price bucket per time
+// bucket, written as CSV or Parquet rows for analysis or as a PNG to look
+// at how liquidity moves.
+package heatmap
+
+import (
+	"encoding/csv"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/l2book"
+	"github.com/helix-lab/helix/gateway/pkg/parquet"
+)
+
+// Config sets the bucketing.
+type Config struct {
+	// Every is the time bucket: one column per Every.
+	Every time.Duration
+	// Tick is the price bucket; 0 takes the smallest gap between the levels
+	// of the first sample, which is the venue's tick in practice.
+	Tick float64
+	// Levels is how many levels per side each sample keeps (default 50).
+	Levels int
+}
+
+// Cell is the size resting in one price bucket, from Price up to the next
+// bucket.
+type Cell struct {
+	Price float64
+	Bid   float64
+	Ask   float64
+}
+
+// Column is one time bucket, cells in ascending price.
+type Column struct {
+	TsMs  int64
+	Cells []Cell
+}
+
+// Heatmap is the matrix so far, one column per sample. It is not safe for
+// concurrent use.
+type Heatmap struct {
+	Config
+	Columns []Column
+}
+
+func New(cfg Config) *Heatmap {
+	if cfg.Levels <= 0 {
+		cfg.Levels = 50
+	}
+	return &Heatmap{Config: cfg}
+}
+
+// Header is the CSV header of the rows WriteCSV writes.
+var Header = []string{"ts_ms", "price", "bid_size", "ask_size"}
+
+// FromCapture samples the L2 capture on r every cfg.Every.
+func FromCapture(r io.Reader, cfg Config) (*Heatmap, l2book.SampleStats, error) {
+	h := New(cfg)
+	st, err := l2book.Sample(r, cfg.Every, func(ts int64, b *l2book.Book) error {
+		bids, asks := b.Depth(h.Levels)
+		h.Add(ts, bids, asks)
+		return nil
+	})
+	return h, st, err
+}
+
+// Add samples a book at tsMs, best level first on each side. Until the tick
+// is known, samples it cannot be told from are dropped.
+func (h *Heatmap) Add(tsMs int64, bids, asks []l2book.Level) {
+	bids, asks = bids[:min(len(bids), h.Levels)], asks[:min(len(asks), h.Levels)]
+	if h.Tick <= 0 {
+		h.Tick = smallestGap(bids, asks)
+		if h.Tick <= 0 {
+			return
+		}
+	}
+	cells := map[int64]*Cell{}
+	cell := func(px float64) *Cell {
+		// the epsilon keeps prices on a bucket edge out of the one below
+		k := int64(math.Floor(px/h.Tick + 1e-9))
+		c := cells[k]
+		if c == nil {
+			c = &Cell{Price: float64(k) * h.Tick}
+			cells[k] = c
+		}
+		return c
+	}
+	for _, l := range bids {
+		cell(l.Price).Bid += l.Size
+	}
+	for _, l := range asks {
+		cell(l.Price).Ask += l.Size
+	}
+	col := Column{TsMs: tsMs, Cells: make([]Cell, 0, len(cells))}
+	for _, c := range cells {
+		col.Cells = append(col.Cells, *c)
+	}
+	sort.Slice(col.Cells, func(i, j int) bool { return col.Cells[i].Price < col.Cells[j].Price })
+	h.Columns = append(h.Columns, col)
+}
+
+func smallestGap(bids, asks []l2book.Level) float64 {
+	var px []float64
+	for _, l := range bids {
+		px = append(px, l.Price)
+	}
+	for _, l := range asks {
+		px = append(px, l.Price)
+	}
+	sort.Float64s(px)
+	gap := 0.0
+	for i := 1; i < len(px); i++ {
+		if d := px[i] - px[i-1]; d > 0 && (gap == 0 || d < gap) {
+			gap = d
+		}
+	}
+	// float noise off prices like 0.1 steps
+	return math.Round(gap*1e9) / 1e9
+}
+
+func (h *Heatmap) rows(fn func(row []string) error) error {
+	f := func(v float64) string { return strconv.FormatFloat(v, 'g', 10, 64) }
+	for _, col := range h.Columns {
+		ts := strconv.FormatInt(col.TsMs, 10)
+		for _, c := range col.Cells {
+			if err := fn([]string{ts, f(c.Price), f(c.Bid), f(c.Ask)}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteCSV writes one row per non-empty cell, in time then price order.
+func (h *Heatmap) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(Header); err != nil {
+		return err
+	}
+	if err := h.rows(cw.Write); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteParquet writes the rows of WriteCSV, with the bucketing in the
+// footer.
+func (h *Heatmap) WriteParquet(w io.Writer, codec parquet.Codec) error {
+	pw, err := parquet.NewWriter(w, []parquet.Column{
+		{Name: "ts_ms", Type: parquet.Int64},
+		{Name: "price", Type: parquet.Double},
+		{Name: "bid_size", Type: parquet.Double},
+		{Name: "ask_size", Type: parquet.Double},
+	}, codec)
+	if err != nil {
+		return err
+	}
+	pw.SetMetadata("helix.heatmap.every_ms", strconv.FormatInt(h.Every.Milliseconds(), 10))
+	pw.SetMetadata("helix.heatmap.tick", strconv.FormatFloat(h.Tick, 'g', -1, 64))
+	if err := h.rows(pw.Append); err != nil {
+		return err
+	}
+	return pw.Close()
+}
+
+// WriteFile writes the matrix to path in the format its extension names:
+// .csv, .parquet or .png.
+func (h *Heatmap) WriteFile(path string, codec parquet.Codec) error {
+	var write func(io.Writer) error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		write = h.WriteCSV
+	case ".parquet":
+		write = func(w io.Writer) error { return h.WriteParquet(w, codec) }
+	case ".png":
+		write = h.WritePNG
+	default:
+		return fmt.Errorf("heatmap: %s: not .csv, .parquet or .png", path)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+// MaxImageSide bounds the PNG: longer spans merge adjacent buckets.
+const MaxImageSide = 4096
+
+// WritePNG renders the matrix time left to right and price bottom to top,
+// bids green and asks red, brighter for more size on a log scale.
+func (h *Heatmap) WritePNG(w io.Writer) error {
+	if len(h.Columns) == 0 {
+		return fmt.Errorf("heatmap: no samples")
+	}
+	lo, hi := int64(math.MaxInt64), int64(math.MinInt64)
+	for _, col := range h.Columns {
+		for _, c := range col.Cells {
+			k := h.bucket(c.Price)
+			lo, hi = min(lo, k), max(hi, k)
+		}
+	}
+	if lo > hi {
+		return fmt.Errorf("heatmap: no liquidity")
+	}
+	xStep := (len(h.Columns) + MaxImageSide - 1) / MaxImageSide
+	yStep := int((hi - lo + MaxImageSide) / MaxImageSide)
+	width := (len(h.Columns) + xStep - 1) / xStep
+	height := int(hi-lo)/yStep + 1
+
+	bid := make([]float64, width*height)
+	ask := make([]float64, width*height)
+	for i, col := range h.Columns {
+		x := i / xStep
+		for _, c := range col.Cells {
+			y := height - 1 - int(h.bucket(c.Price)-lo)/yStep
+			// merged buckets keep their deepest
+			bid[y*width+x] = max(bid[y*width+x], c.Bid)
+			ask[y*width+x] = max(ask[y*width+x], c.Ask)
+		}
+	}
+	top := 0.0
+	for i := range bid {
+		top = max(top, bid[i], ask[i])
+	}
+	scale := func(v float64) uint8 {
+		if v <= 0 || top <= 0 {
+			return 0
+		}
+		return uint8(32 + 223*math.Log1p(v)/math.Log1p(top))
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			img.SetRGBA(x, y, color.RGBA{R: scale(ask[i]), G: scale(bid[i]), A: 255})
+		}
+	}
+	return png.Encode(w, img)
+}
+
+func (h *Heatmap) bucket(px float64) int64 { return int64(math.Round(px / h.Tick)) }
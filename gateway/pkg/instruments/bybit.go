@@ -0,0 +1,147 @@
+package instruments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/ratelimit"
+)
+
+// Bybit lists a Bybit v5 category from GET /v5/market/instruments-info,
+// following the cursor across pages.
+type Bybit struct {
+	Name     string
+	URL      string
+	Category string
+	Client   *http.Client
+	// Limits, if set, paces the requests and learns from their rate-limit
+	// headers.
+	Limits *ratelimit.Limiter
+}
+
+func NewBybit(venue, url, category string) *Bybit {
+	return &Bybit{Name: venue, URL: strings.TrimRight(url, "/"), Category: category,
+		Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (b *Bybit) Venue() string { return b.Name }
+
+func (b *Bybit) Instruments(ctx context.Context) ([]Spec, error) {
+	var out []Spec
+	cursor := ""
+	for page := 0; page < 100; page++ {
+		q := url.Values{"category": {b.Category}, "limit": {"1000"}}
+		if cursor != "" {
+			q.Set("cursor", cursor)
+		}
+		var body struct {
+			RetCode int    `json:"retCode"`
+			RetMsg  string `json:"retMsg"`
+			Result  struct {
+				NextPageCursor string `json:"nextPageCursor"`
+				List           []struct {
+					Symbol      string `json:"symbol"`
+					Status      string `json:"status"`
+					BaseCoin    string `json:"baseCoin"`
+					QuoteCoin   string `json:"quoteCoin"`
+					PriceFilter struct {
+						TickSize string `json:"tickSize"`
+					} `json:"priceFilter"`
+					LotSizeFilter struct {
+						// spot steps by basePrecision, derivatives by qtyStep
+						BasePrecision string `json:"basePrecision"`
+						QtyStep       string `json:"qtyStep"`
+						MinOrderQty   string `json:"minOrderQty"`
+						MaxOrderQty   string `json:"maxOrderQty"`
+						// spot's minimum notional is minOrderAmt, linear's minNotionalValue
+						MinOrderAmt      string `json:"minOrderAmt"`
+						MinNotionalValue string `json:"minNotionalValue"`
+					} `json:"lotSizeFilter"`
+				} `json:"list"`
+			} `json:"result"`
+		}
+		if err := b.get(ctx, "/v5/market/instruments-info?"+q.Encode(), &body); err != nil {
+			return nil, err
+		}
+		if body.RetCode != 0 {
+			return nil, fmt.Errorf("retCode %d retMsg %s", body.RetCode, body.RetMsg)
+		}
+		for _, it := range body.Result.List {
+			lot := it.LotSizeFilter
+			out = append(out, Spec{
+				Venue: b.Name, Symbol: it.Symbol, Category: b.Category, Base: it.BaseCoin, Quote: it.QuoteCoin,
+				Status:      strings.ToLower(it.Status),
+				TickSize:    num(it.PriceFilter.TickSize),
+				LotSize:     first(num(lot.QtyStep), num(lot.BasePrecision)),
+				MinQty:      num(lot.MinOrderQty),
+				MaxQty:      num(lot.MaxOrderQty),
+				MinNotional: first(num(lot.MinNotionalValue), num(lot.MinOrderAmt)),
+				Multiplier:  multiplier(it.Symbol, it.BaseCoin),
+			})
+		}
+		cursor = body.Result.NextPageCursor
+		if cursor == "" {
+			return out, nil
+		}
+	}
+	return nil, fmt.Errorf("instruments-info still paging after 100 pages")
+}
+
+// multiplier reads the 10^n prefix Bybit puts on contracts for small-priced
+// coins, e.g. 1000PEPEUSDT or 10000LADYSUSDT; it is 1 otherwise.
+func multiplier(symbol, base string) float64 {
+	digits := strings.TrimSuffix(base, strings.TrimLeft(base, "0123456789"))
+	if digits == "" {
+		digits = strings.TrimSuffix(symbol, strings.TrimLeft(symbol, "0123456789"))
+	}
+	if len(digits) < 2 || digits[0] != '1' || strings.Trim(digits[1:], "0") != "" {
+		return 1
+	}
+	n, _ := strconv.ParseFloat(digits, 64)
+	return n
+}
+
+func first(vals ...float64) float64 {
+	for _, v := range vals {
+		if v > 0 {
+			return v
+		}
+	}
+	return 0
+}
+
+func num(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+func (b *Bybit) get(ctx context.Context, pathQuery string, v any) error {
+	path, _, _ := strings.Cut(pathQuery, "?")
+	if b.Limits != nil {
+		if err := b.Limits.Wait(ctx, b.Name, path); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.URL+pathQuery, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := b.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if b.Limits != nil {
+		b.Limits.ObserveResponse(b.Name, path, resp)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
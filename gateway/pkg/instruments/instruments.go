@@ -0,0 +1,314 @@
+// Package instruments keeps each venue's instrument specs — tick size, lot
+// size, minimums, contract multiplier and trading status — fetched from
+// the venues' REST APIs at startup and periodically, and cached on disk so
+// a restart without REST access still has the last known specs. The router
+// skips venues whose instrument cannot take an order and the executor
+// refuses orders that break a spec.
+package instruments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/decimal"
+	"github.com/helix-lab/helix/gateway/pkg/metrics"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// StatusTrading is the one status that takes orders; venues' other
+// statuses (pre-launch, settling, closed...) are kept lowercased.
+const StatusTrading = "trading"
+
+// ErrSpec is wrapped by every refusal Check makes.
+var ErrSpec = errors.New("instrument spec")
+
+var (
+	refreshes = metrics.Default.CounterVec("helix_instruments_refresh_total",
+		"Instrument spec fetches per venue, by result.", "venue", "result")
+	listed = metrics.Default.GaugeVec("helix_instruments_listed",
+		"Instruments known per venue.", "venue")
+)
+
+// Spec is one instrument on one venue; zero fields are unknown and not
+// checked.
+type Spec struct {
+	Venue    string  `json:"venue"`
+	Symbol   string  `json:"symbol"`
+	Category string  `json:"category,omitempty"`
+	Base     string  `json:"base,omitempty"`
+	Quote    string  `json:"quote,omitempty"`
+	Status   string  `json:"status"`
+	TickSize float64 `json:"tick_size,omitempty"`
+	// LotSize is the quantity step; MinQty and MaxQty bound one order.
+	LotSize     float64 `json:"lot_size,omitempty"`
+	MinQty      float64 `json:"min_qty,omitempty"`
+	MaxQty      float64 `json:"max_qty,omitempty"`
+	MinNotional float64 `json:"min_notional,omitempty"`
+	// Multiplier is how many units of Base one quantity unit is, e.g. 1000
+	// for 1000PEPEUSDT.
+	Multiplier float64 `json:"multiplier,omitempty"`
+}
+
+func (s Spec) Trading() bool { return s.Status == StatusTrading }
+
+// Check vets an order for size at price; price 0 skips the price checks.
+func (s Spec) Check(size, price float64) error {
+	if !s.Trading() {
+		return fmt.Errorf("%w: %s %s is %s", ErrSpec, s.Venue, s.Symbol, s.Status)
+	}
+	if s.MinQty > 0 && decimal.Cmp(size, s.MinQty) < 0 {
+		return fmt.Errorf("%w: %s %s size %g under the minimum %g", ErrSpec, s.Venue, s.Symbol, size, s.MinQty)
+	}
+	if s.MaxQty > 0 && decimal.Cmp(size, s.MaxQty) > 0 {
+		return fmt.Errorf("%w: %s %s size %g over the maximum %g", ErrSpec, s.Venue, s.Symbol, size, s.MaxQty)
+	}
+	if !multiple(size, s.LotSize) {
+		return fmt.Errorf("%w: %s %s size %g is not a multiple of the lot size %g", ErrSpec, s.Venue, s.Symbol, size, s.LotSize)
+	}
+	if price <= 0 {
+		return nil
+	}
+	if s.MinNotional > 0 && size*price < s.MinNotional {
+		return fmt.Errorf("%w: %s %s notional %g under the minimum %g", ErrSpec, s.Venue, s.Symbol, size*price, s.MinNotional)
+	}
+	return nil
+}
+
+// CheckPrice vets a limit price against the tick size.
+func (s Spec) CheckPrice(price float64) error {
+	if price > 0 && !multiple(price, s.TickSize) {
+		return fmt.Errorf("%w: %s %s price %g is not a multiple of the tick size %g", ErrSpec, s.Venue, s.Symbol, price, s.TickSize)
+	}
+	return nil
+}
+
+// multiple reports whether v is a whole number of steps, as decimals; a
+// zero step allows anything.
+func multiple(v, step float64) bool {
+	st := decimal.FromFloat(step)
+	return st <= 0 || decimal.FromFloat(v)%st == 0
+}
+
+// Source lists one venue's instruments, typically over its public REST
+// API.
+type Source interface {
+	Venue() string
+	Instruments(ctx context.Context) ([]Spec, error)
+}
+
+type key struct{ venue, symbol string }
+
+// Cache holds the latest specs per venue. A failed fetch keeps the
+// venue's previous specs.
+type Cache struct {
+	// Interval is how often Run refreshes; it defaults to an hour.
+	Interval time.Duration
+	// Logf reports failed fetches; it defaults to discarding them.
+	Logf func(format string, args ...any)
+
+	path    string
+	sources []Source
+
+	mu      sync.RWMutex
+	specs   map[key]Spec
+	fetched map[string]time.Time
+}
+
+// NewCache fetches from sources and persists to path (empty = memory
+// only).
+func NewCache(path string, sources ...Source) *Cache {
+	return &Cache{Interval: time.Hour, Logf: func(string, ...any) {}, path: path, sources: sources,
+		specs: map[key]Spec{}, fetched: map[string]time.Time{}}
+}
+
+// file is the on-disk cache.
+type file struct {
+	Fetched map[string]time.Time `json:"fetched"`
+	Specs   []Spec               `json:"instruments"`
+}
+
+// Load reads the on-disk cache; a missing file is not an error.
+func (c *Cache) Load() error {
+	if c.path == "" {
+		return nil
+	}
+	raw, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var f file
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return fmt.Errorf("instruments cache %s: %w", c.path, err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range f.Specs {
+		c.specs[key{s.Venue, s.Symbol}] = s
+	}
+	for v, t := range f.Fetched {
+		c.fetched[v] = t
+	}
+	return nil
+}
+
+// Refresh fetches every source once and saves the cache; the error joins
+// the sources that failed.
+func (c *Cache) Refresh(ctx context.Context) error {
+	var errs []error
+	changed := false
+	for _, src := range c.sources {
+		venue := src.Venue()
+		specs, err := src.Instruments(ctx)
+		if err == nil && len(specs) == 0 {
+			err = errors.New("no instruments listed")
+		}
+		if err != nil {
+			refreshes.With(venue, "error").Inc()
+			errs = append(errs, fmt.Errorf("%s: %w", venue, err))
+			continue
+		}
+		refreshes.With(venue, "ok").Inc()
+		c.mu.Lock()
+		for k := range c.specs {
+			if k.venue == venue {
+				delete(c.specs, k)
+			}
+		}
+		for _, s := range specs {
+			s.Venue = venue
+			c.specs[key{venue, s.Symbol}] = s
+		}
+		c.fetched[venue] = time.Now()
+		c.mu.Unlock()
+		listed.With(venue).Set(float64(len(specs)))
+		changed = true
+	}
+	if changed {
+		if err := c.save(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run refreshes every Interval until ctx ends; call Refresh first for the
+// startup fetch.
+func (c *Cache) Run(ctx context.Context) {
+	t := time.NewTicker(c.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := c.Refresh(ctx); err != nil {
+				c.Logf("instruments: %v", err)
+			}
+		}
+	}
+}
+
+// save writes the cache through a temporary file and a rename.
+func (c *Cache) save() error {
+	if c.path == "" {
+		return nil
+	}
+	c.mu.RLock()
+	f := file{Fetched: make(map[string]time.Time, len(c.fetched)), Specs: c.list()}
+	for v, t := range c.fetched {
+		f.Fetched[v] = t
+	}
+	c.mu.RUnlock()
+	raw, err := json.MarshalIndent(f, "", " ")
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// Spec returns symbol's spec on venue.
+func (c *Cache) Spec(venue, symbol string) (Spec, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	s, ok := c.specs[key{venue, symbol}]
+	return s, ok
+}
+
+// Specs lists every spec by venue and symbol.
+func (c *Cache) Specs() []Spec {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.list()
+}
+
+func (c *Cache) list() []Spec {
+	out := make([]Spec, 0, len(c.specs))
+	for _, s := range c.specs {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Venue != out[j].Venue {
+			return out[i].Venue < out[j].Venue
+		}
+		return out[i].Symbol < out[j].Symbol
+	})
+	return out
+}
+
+// Fetched is when venue's specs were last fetched, zero if never.
+func (c *Cache) Fetched(venue string) time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.fetched[venue]
+}
+
+// Tradable is the router's check: false when venue lists action's symbol
+// but the order would break its spec at price. Instruments a venue does
+// not list, or venues without specs, are left to the venue.
+func (c *Cache) Tradable(venue string, action transport.Action, price float64) bool {
+	s, ok := c.Spec(venue, action.Symbol)
+	if !ok {
+		return true
+	}
+	if action.Price > 0 {
+		price = action.Price
+	}
+	if math.IsInf(price, 0) || math.IsNaN(price) {
+		price = 0
+	}
+	return s.Check(action.Size, price) == nil
+}
+
+// Check is the executor's check of a routed action at price, the limit
+// price or the routed touch for market orders.
+func (c *Cache) Check(action transport.Action, price float64) error {
+	s, ok := c.Spec(action.Venue, action.Symbol)
+	if !ok {
+		return nil
+	}
+	if err := s.CheckPrice(action.Price); err != nil {
+		return err
+	}
+	if action.Price > 0 {
+		price = action.Price
+	}
+	return s.Check(action.Size, price)
+}
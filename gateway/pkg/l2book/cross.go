@@ -0,0 +1,114 @@
+package l2book
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Top is the top of book after one complete message.
+type Top struct {
+	TsMs             int64
+	Seq              int64
+	BestBid, BestAsk float64
+	BidSize, AskSize float64
+}
+
+// Tops rebuilds the book from the L2 capture on r and returns its top after
+// every message that leaves a valid two-sided book.
+func Tops(r io.Reader) ([]Top, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	var parser Parser
+	book := New()
+	var out []Top
+	end := func() {
+		if book.Ready() && book.Check() == nil {
+			out = append(out, Top{book.LastTsMs, book.LastSeq, book.BestBid, book.BestAsk, book.BidSize, book.AskSize})
+		}
+	}
+	for {
+		fields, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if errors.Is(err, csv.ErrFieldCount) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		d, ok := parser.Parse(fields)
+		if !ok {
+			continue
+		}
+		if book.LastSeq >= 0 && d.Seq != book.LastSeq {
+			end()
+		}
+		if err := book.Update(d); err != nil {
+			return nil, err
+		}
+	}
+	end()
+	return out, nil
+}
+
+// Mismatch is an aligned pair of tops that disagree.
+type Mismatch struct {
+	Shallow, Deep Top
+}
+
+func (m Mismatch) String() string {
+	s, d := m.Shallow, m.Deep
+	return fmt.Sprintf("ts=%d seq %d/%d: bid %g x %g vs %g x %g, ask %g x %g vs %g x %g",
+		s.TsMs, s.Seq, d.Seq, s.BestBid, s.BidSize, d.BestBid, d.BidSize, s.BestAsk, s.AskSize, d.BestAsk, d.AskSize)
+}
+
+// CrossStats is the result of CrossCheck.
+type CrossStats struct {
+	Shallow, Deep int
+	// Aligned counts shallow tops with a deep top at the same ts_ms.
+	Aligned int
+	Matched int
+	// Mismatches holds the first few disagreements.
+	Mismatches []Mismatch
+}
+
+// maxMismatches caps CrossStats.Mismatches.
+const maxMismatches = 20
+
+// CrossCheck compares the native top of a shallow feed (e.g. Bybit's
+// orderbook.1) with the top rebuilt from a deeper feed of the same symbol
+// recorded on the same connection. The feeds publish at different rates, so
+// only tops with equal exchange timestamps are compared; prices and sizes
+// must agree within tol.
+func CrossCheck(shallow, deep []Top, tol float64) CrossStats {
+	st := CrossStats{Shallow: len(shallow), Deep: len(deep)}
+	j := 0
+	for _, s := range shallow {
+		for j < len(deep) && deep[j].TsMs < s.TsMs {
+			j++
+		}
+		// the last deep top at this timestamp is the book as of it
+		k := -1
+		for i := j; i < len(deep) && deep[i].TsMs == s.TsMs; i++ {
+			k = i
+		}
+		if k < 0 {
+			continue
+		}
+		st.Aligned++
+		d := deep[k]
+		if near(s.BestBid, d.BestBid, tol) && near(s.BestAsk, d.BestAsk, tol) &&
+			near(s.BidSize, d.BidSize, tol) && near(s.AskSize, d.AskSize, tol) {
+			st.Matched++
+		} else if len(st.Mismatches) < maxMismatches {
+			st.Mismatches = append(st.Mismatches, Mismatch{s, d})
+		}
+	}
+	return st
+}
+
+func near(a, b, tol float64) bool { return math.Abs(a-b) <= tol }
@@ -0,0 +1,175 @@
+package l2book
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/helix-lab/helix/gateway/pkg/decimal"
+)
+
+// Number formats of bookcheck prices and sizes.
+const (
+	// FormatFixed writes Digits decimals, as %.<Digits>f.
+	FormatFixed = "fixed"
+	// FormatSig writes Digits significant digits, as %.<Digits>g.
+	FormatSig = "sig"
+	// FormatRaw echoes the string the level was quoted with, or the
+	// shortest exact form of the number when there is none.
+	FormatRaw = "raw"
+)
+
+// DefaultDigits is the precision both bookcheck writers default to.
+const DefaultDigits = 10
+
+// BookcheckHeader is the header of a bookcheck CSV.
+var BookcheckHeader = []string{"ts_ms", "seq", "best_bid", "best_ask", "bid_size", "ask_size"}
+
+// NumFormat is how bookcheck writes prices and sizes. Writers that agree on
+// it write byte-identical files for identical books.
+type NumFormat struct {
+	Mode   string
+	Digits int
+}
+
+// ParseNumFormat checks mode (one of the Format constants) and digits.
+func ParseNumFormat(mode string, digits int) (NumFormat, error) {
+	switch mode {
+	case FormatFixed, FormatSig, FormatRaw:
+	default:
+		return NumFormat{}, fmt.Errorf("unknown number format %q (want %s, %s or %s)", mode, FormatFixed, FormatSig, FormatRaw)
+	}
+	if digits < 0 || (mode == FormatSig && digits == 0) {
+		return NumFormat{}, fmt.Errorf("%d digits is out of range for %s", digits, mode)
+	}
+	return NumFormat{Mode: mode, Digits: digits}, nil
+}
+
+// Format writes v, or raw as it is in FormatRaw when it is set.
+func (f NumFormat) Format(v float64, raw string) string {
+	switch f.Mode {
+	case FormatSig:
+		return strconv.FormatFloat(v, 'g', f.Digits, 64)
+	case FormatRaw:
+		if raw != "" {
+			return raw
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(v, 'f', f.Digits, 64)
+}
+
+// Normalize rewrites a number written in any format in f; s is returned
+// as it is when it is not a number.
+func (f NumFormat) Normalize(s string) string {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return s
+	}
+	return f.Format(v, "")
+}
+
+// Raw keeps the strings a book's levels were last set from, so its top can
+// be written exactly as the venue quoted it.
+type Raw struct {
+	sides [2]map[decimal.Decimal][2]string
+}
+
+func NewRaw() *Raw {
+	return &Raw{sides: [2]map[decimal.Decimal][2]string{{}, {}}}
+}
+
+func (r *Raw) side(bid bool) map[decimal.Decimal][2]string {
+	if bid {
+		return r.sides[0]
+	}
+	return r.sides[1]
+}
+
+// Set records the strings of the level at px; a size of zero or less
+// forgets it, as Ladder.Set removes it.
+func (r *Raw) Set(bid bool, px, sz decimal.Decimal, rawPx, rawSz string) {
+	if sz <= 0 {
+		delete(r.side(bid), px)
+		return
+	}
+	r.side(bid)[px] = [2]string{rawPx, rawSz}
+}
+
+// Reset forgets every level.
+func (r *Raw) Reset() {
+	clear(r.sides[0])
+	clear(r.sides[1])
+}
+
+// Top returns the strings of the best levels of bids and asks, empty for
+// a side without any.
+func (r *Raw) Top(bids, asks *Ladder) (bid, bidSz, ask, askSz string) {
+	if px, _, ok := bids.Best(); ok {
+		s := r.sides[0][px]
+		bid, bidSz = s[0], s[1]
+	}
+	if px, _, ok := asks.Best(); ok {
+		s := r.sides[1][px]
+		ask, askSz = s[0], s[1]
+	}
+	return bid, bidSz, ask, askSz
+}
+
+// BookcheckDiff is the result of CompareBookchecks.
+type BookcheckDiff struct {
+	Rows [2]int
+	// Mismatches holds the first few rows that differ, as "row N: ...".
+	Mismatches []string
+}
+
+// CompareBookchecks compares two bookcheck CSVs field by field as text.
+// With normalize, prices and sizes are first rewritten in norm, so files
+// written with different formats compare by value.
+func CompareBookchecks(a, b io.Reader, normalize bool, norm NumFormat) (BookcheckDiff, error) {
+	var d BookcheckDiff
+	var rows [2][][]string
+	for i, r := range []io.Reader{a, b} {
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		all, err := cr.ReadAll()
+		if err != nil {
+			return d, err
+		}
+		if len(all) > 0 && len(all[0]) > 0 && all[0][0] == BookcheckHeader[0] {
+			all = all[1:]
+		}
+		rows[i] = all
+		d.Rows[i] = len(all)
+	}
+	field := func(row []string, j int) string {
+		if j >= len(row) {
+			return ""
+		}
+		// ts_ms and seq are integers in every format
+		if normalize && j >= 2 {
+			return norm.Normalize(row[j])
+		}
+		return row[j]
+	}
+	for i := 0; i < min(d.Rows[0], d.Rows[1]) && len(d.Mismatches) < maxMismatches; i++ {
+		x, y := rows[0][i], rows[1][i]
+		for j := 0; j < max(len(x), len(y)); j++ {
+			if fx, fy := field(x, j), field(y, j); fx != fy {
+				name := fmt.Sprint(j)
+				if j < len(BookcheckHeader) {
+					name = BookcheckHeader[j]
+				}
+				d.Mismatches = append(d.Mismatches, fmt.Sprintf("row %d: %s %s vs %s", i+1, name, fx, fy))
+				break
+			}
+		}
+	}
+	return d, nil
+}
+
+// Equal reports whether the files had the same rows.
+func (d BookcheckDiff) Equal() bool {
+	return d.Rows[0] == d.Rows[1] && len(d.Mismatches) == 0
+}
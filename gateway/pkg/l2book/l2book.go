@@ -0,0 +1,272 @@
+// Package l2book rebuilds an order book from recorded L2 deltas
+// (ts_ms,seq,prev_seq,book_side,price,size,type), checking the seq chain
+// and the book's sanity as it goes. It is the engine behind helix
+// bookcheck and helix downsample.
+package l2book
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/helix-lab/helix/gateway/pkg/decimal"
+)
+
+// Delta is one recorded level change.
+type Delta struct {
+	Seq      int64
+	PrevSeq  int64
+	Snapshot bool
+	TsMs     int64
+	Side     rune // 'b' or 'a'
+	Price    float64
+	Qty      float64
+	// RawPrice and RawQty are the fields as recorded.
+	RawPrice, RawQty string
+}
+
+// Parser reads deltas from CSV records. The first record containing
+// letters is taken as the header; without one, columns are positional
+// unless SetColumns named them. Without a prev_seq column each message is
+// taken to follow the one before.
+type Parser struct {
+	header map[string]int
+	known  bool
+	// seq and prev are the last message's, for captures without prev_seq.
+	seq, prev int64
+}
+
+// SetColumns names the columns, e.g. from the capture's sidecar, for
+// records that come without a header. A header record still replaces
+// them.
+func (p *Parser) SetColumns(cols []string) {
+	p.setHeader(cols)
+}
+
+func (p *Parser) setHeader(fields []string) {
+	p.known = true
+	p.header = make(map[string]int, len(fields))
+	for i, name := range fields {
+		p.header[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+}
+
+// isHeader reports whether fields are all column names of the header set.
+func (p *Parser) isHeader(fields []string) bool {
+	for _, f := range fields {
+		if _, ok := p.header[strings.ToLower(strings.TrimSpace(f))]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse returns the record's delta; ok is false for the header and rows
+// without a valid side, a positive price or a finite size.
+func (p *Parser) Parse(fields []string) (d Delta, ok bool) {
+	if (!p.known && containsAlpha(fields)) || (p.known && p.isHeader(fields)) {
+		p.setHeader(fields)
+		return d, false
+	}
+	if !p.known && len(fields) <= 1 {
+		return d, false
+	}
+	idx := func(name string, pos int) int {
+		if !p.known {
+			return pos
+		}
+		if i, ok := p.header[name]; ok {
+			return i
+		}
+		return -1
+	}
+	side := idx("book_side", 4)
+	if side < 0 {
+		side = idx("side", -1)
+	}
+	d.TsMs = getInt(fields, idx("ts_ms", 0), 0)
+	d.Seq = getInt(fields, idx("seq", 1), 0)
+	if i := idx("prev_seq", 2); i >= 0 {
+		d.PrevSeq = getInt(fields, i, -1)
+	} else {
+		if d.Seq != p.seq {
+			p.prev, p.seq = p.seq, d.Seq
+		}
+		d.PrevSeq = p.prev
+	}
+	t := strings.ToLower(get(fields, idx("type", 3)))
+	d.Snapshot = t == "snapshot" || t == "snap" || t == "full"
+	if s := strings.ToLower(get(fields, side)); s != "" && (s[0] == 'b' || s[0] == 'a') {
+		d.Side = rune(s[0])
+	}
+	var errPx, errQty error
+	d.Price, errPx = getFloat(fields, idx("price", 5))
+	d.Qty, errQty = getFloat(fields, idx("size", 6))
+	d.RawPrice, d.RawQty = get(fields, idx("price", 5)), get(fields, idx("size", 6))
+	if errPx != nil || errQty != nil || !(d.Price > 0) {
+		return d, false
+	}
+	return d, d.Side == 'b' || d.Side == 'a'
+}
+
+func containsAlpha(fields []string) bool {
+	for _, f := range fields {
+		for _, c := range f {
+			if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func get(fields []string, i int) string {
+	if i < 0 || i >= len(fields) {
+		return ""
+	}
+	return strings.TrimSpace(fields[i])
+}
+
+func getInt(fields []string, i int, def int64) int64 {
+	v, err := strconv.ParseInt(get(fields, i), 10, 64)
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+func getFloat(fields []string, i int) (float64, error) {
+	v, err := strconv.ParseFloat(get(fields, i), 64)
+	if err == nil && (math.IsNaN(v) || math.IsInf(v, 0)) {
+		err = fmt.Errorf("non-finite %q", get(fields, i))
+	}
+	return v, err
+}
+
+// Level is one price level.
+type Level struct {
+	Price float64
+	Size  float64
+}
+
+// Book is the book the deltas built so far. Levels are keyed by exact
+// decimal price.
+type Book struct {
+	bids, asks *Ladder
+	raw        *Raw
+	// LastSeq is -1 before the first delta.
+	LastSeq  int64
+	LastTsMs int64
+	syncing  bool
+
+	BestBid, BestAsk float64
+	BidSize, AskSize float64
+}
+
+func New() *Book {
+	return &Book{bids: NewLadder(true), asks: NewLadder(false), LastSeq: -1}
+}
+
+// KeepRaw makes the book remember the recorded strings of its levels for
+// RawTop.
+func (b *Book) KeepRaw() { b.raw = NewRaw() }
+
+// RawTop is the top as recorded; empty without KeepRaw.
+func (b *Book) RawTop() (bid, bidSz, ask, askSz string) {
+	if b.raw == nil {
+		return "", "", "", ""
+	}
+	return b.raw.Top(b.bids, b.asks)
+}
+
+// Ready reports whether the book has a two-sided top: deltas have been
+// applied and no snapshot is still arriving.
+func (b *Book) Ready() bool { return !b.syncing && b.LastSeq >= 0 }
+
+// Apply updates the book with d and checks it.
+func (b *Book) Apply(d Delta) error {
+	if err := b.Update(d); err != nil {
+		return err
+	}
+	return b.Check()
+}
+
+// Update applies d. Deltas sharing a seq are one message; the first row
+// of a snapshot (or of a message with prev_seq 0) clears the book. It fails
+// on seq gaps and rollbacks and negative sizes.
+func (b *Book) Update(d Delta) error {
+	if (d.Snapshot || d.PrevSeq == 0) && d.Seq != b.LastSeq {
+		b.bids.Reset()
+		b.asks.Reset()
+		if b.raw != nil {
+			b.raw.Reset()
+		}
+		b.syncing = true
+	}
+	if b.LastSeq >= 0 && d.Seq != b.LastSeq {
+		if d.PrevSeq != b.LastSeq {
+			return fmt.Errorf("seq gap: prev=%d next_prev=%d", b.LastSeq, d.PrevSeq)
+		}
+		if d.Seq <= b.LastSeq {
+			return fmt.Errorf("seq rollback: prev=%d next_seq=%d", b.LastSeq, d.Seq)
+		}
+	}
+	b.LastSeq = d.Seq
+	if d.TsMs > 0 {
+		b.LastTsMs = d.TsMs
+	} else {
+		b.LastTsMs++
+	}
+	if d.Qty < 0 {
+		return fmt.Errorf("negative qty delta at seq=%d", d.Seq)
+	}
+
+	side := b.asks
+	if d.Side == 'b' {
+		side = b.bids
+	}
+	px, qty := decimal.FromFloat(d.Price), decimal.FromFloat(d.Qty)
+	side.Set(px, qty)
+	if b.raw != nil {
+		b.raw.Set(d.Side == 'b', px, qty, d.RawPrice, d.RawQty)
+	}
+	b.rebuild()
+
+	if b.syncing && b.BestBid > 0 && b.BestAsk > 0 {
+		b.syncing = false
+	}
+	return nil
+}
+
+// Check fails on a crossed or one-sided book outside a snapshot. Within a
+// message the book may cross transiently, so callers that apply whole
+// messages check after the last delta.
+func (b *Book) Check() error {
+	if b.syncing {
+		return nil
+	}
+	if !(b.BestBid > 0 && b.BestAsk > 0 && b.BestBid < b.BestAsk) {
+		return errors.New("best_bid/best_ask invalid")
+	}
+	if !(b.BidSize > 0 && b.AskSize > 0) {
+		return errors.New("top sizes non-positive")
+	}
+	if mid := (b.BestBid + b.BestAsk) / 2; !(mid > 0) || math.IsNaN(mid) || math.IsInf(mid, 0) {
+		return errors.New("mid invalid")
+	}
+	return nil
+}
+
+func (b *Book) rebuild() {
+	bid, bsz, _ := b.bids.Best()
+	ask, asz, _ := b.asks.Best()
+	b.BestBid, b.BidSize = bid.Float(), bsz.Float()
+	b.BestAsk, b.AskSize = ask.Float(), asz.Float()
+}
+
+// Depth returns the best n levels of each side, best first.
+func (b *Book) Depth(n int) (bids, asks []Level) {
+	return b.bids.Top(n), b.asks.Top(n)
+}
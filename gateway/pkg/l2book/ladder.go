@@ -0,0 +1,81 @@
+package l2book
+
+import (
+	"sort"
+
+	"github.com/helix-lab/helix/gateway/pkg/decimal"
+)
+
+// Ladder is one side of a book: its levels in a slice sorted best first,
+// so the best level is at index 0 and the top n are a walk. Updates find
+// their level by binary search; inserts and deletes shift the levels
+// behind them, which at exchange depths (≤ 1000) is cheaper than any tree.
+type Ladder struct {
+	bids   bool
+	levels []rung
+}
+
+type rung struct {
+	px, sz decimal.Decimal
+}
+
+// NewLadder returns an empty bid (descending) or ask (ascending) ladder.
+func NewLadder(bids bool) *Ladder { return &Ladder{bids: bids} }
+
+// search returns the index of px or where it would be inserted.
+func (l *Ladder) search(px decimal.Decimal) int {
+	if l.bids {
+		return sort.Search(len(l.levels), func(i int) bool { return l.levels[i].px <= px })
+	}
+	return sort.Search(len(l.levels), func(i int) bool { return l.levels[i].px >= px })
+}
+
+// Set puts sz at px; a size of zero or less removes the level.
+func (l *Ladder) Set(px, sz decimal.Decimal) {
+	i := l.search(px)
+	found := i < len(l.levels) && l.levels[i].px == px
+	switch {
+	case sz <= 0:
+		if found {
+			l.levels = append(l.levels[:i], l.levels[i+1:]...)
+		}
+	case found:
+		l.levels[i].sz = sz
+	default:
+		l.levels = append(l.levels, rung{})
+		copy(l.levels[i+1:], l.levels[i:])
+		l.levels[i] = rung{px, sz}
+	}
+}
+
+// Get returns the size at px, zero when there is no level.
+func (l *Ladder) Get(px decimal.Decimal) decimal.Decimal {
+	if i := l.search(px); i < len(l.levels) && l.levels[i].px == px {
+		return l.levels[i].sz
+	}
+	return 0
+}
+
+// Best returns the best level; ok is false when the side is empty.
+func (l *Ladder) Best() (px, sz decimal.Decimal, ok bool) {
+	if len(l.levels) == 0 {
+		return 0, 0, false
+	}
+	return l.levels[0].px, l.levels[0].sz, true
+}
+
+// Len is the number of levels.
+func (l *Ladder) Len() int { return len(l.levels) }
+
+// Reset empties the ladder, keeping its storage.
+func (l *Ladder) Reset() { l.levels = l.levels[:0] }
+
+// Top returns the best n levels, best first.
+func (l *Ladder) Top(n int) []Level {
+	n = min(n, len(l.levels))
+	out := make([]Level, n)
+	for i := range out {
+		out[i] = Level{l.levels[i].px.Float(), l.levels[i].sz.Float()}
+	}
+	return out
+}
@@ -0,0 +1,140 @@
+package l2book
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// SampleStats describes a Sample or Downsample run.
+type SampleStats struct {
+	Deltas   int
+	Messages int
+	Samples  int
+	// Skipped counts grid points where the book was crossed or one-sided.
+	Skipped int
+}
+
+// SampleHeader is the CSV header Downsample writes for levels per side:
+// bookcheck's columns for one level, bid_px_1,bid_sz_1,ask_px_1,ask_sz_1,...
+// for more.
+func SampleHeader(levels int) []string {
+	if levels <= 1 {
+		return []string{"ts_ms", "seq", "best_bid", "best_ask", "bid_size", "ask_size"}
+	}
+	h := []string{"ts_ms", "seq"}
+	for i := 1; i <= levels; i++ {
+		n := strconv.Itoa(i)
+		h = append(h, "bid_px_"+n, "bid_sz_"+n, "ask_px_"+n, "ask_sz_"+n)
+	}
+	return h
+}
+
+// Downsample rebuilds the book from the L2 capture on r and writes its top
+// levels every interval, on ts_ms multiples of every. Each row is the book
+// as of the last complete message at or before its timestamp; grid points
+// before the first snapshot completes are not written. Seq breaks are
+// fatal, as in bookcheck.
+func Downsample(r io.Reader, w io.Writer, every time.Duration, levels int) (SampleStats, error) {
+	levels = max(levels, 1)
+	cw := csv.NewWriter(w)
+	if err := cw.Write(SampleHeader(levels)); err != nil {
+		return SampleStats{}, err
+	}
+	st, err := Sample(r, every, func(ts int64, b *Book) error {
+		return cw.Write(sampleRow(b, ts, levels))
+	})
+	if err != nil {
+		return st, err
+	}
+	cw.Flush()
+	return st, cw.Error()
+}
+
+// Sample rebuilds the book from the L2 capture on r and calls fn with it at
+// every grid point Downsample writes a row for.
+func Sample(r io.Reader, every time.Duration, fn func(tsMs int64, b *Book) error) (SampleStats, error) {
+	var st SampleStats
+	step := every.Milliseconds()
+	if step <= 0 {
+		return st, errors.New("interval must be at least 1ms")
+	}
+	book := New()
+	next := int64(-1)
+	// flush samples the grid points before until from the current book.
+	flush := func(until int64) error {
+		if !book.Ready() {
+			return nil
+		}
+		if next < 0 {
+			next = (book.LastTsMs + step - 1) / step * step
+		}
+		valid := book.Check() == nil
+		for ; next < until; next += step {
+			if !valid {
+				st.Skipped++
+				continue
+			}
+			if err := fn(next, book); err != nil {
+				return err
+			}
+			st.Samples++
+		}
+		return nil
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	var parser Parser
+	for {
+		fields, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if errors.Is(err, csv.ErrFieldCount) {
+			continue
+		}
+		if err != nil {
+			return st, err
+		}
+		d, ok := parser.Parse(fields)
+		if !ok {
+			continue
+		}
+		if book.LastSeq < 0 || d.Seq != book.LastSeq {
+			if err := flush(d.TsMs); err != nil {
+				return st, err
+			}
+			st.Messages++
+		}
+		if err := book.Update(d); err != nil {
+			return st, err
+		}
+		st.Deltas++
+	}
+	return st, flush(book.LastTsMs + 1)
+}
+
+func sampleRow(b *Book, ts int64, levels int) []string {
+	f := func(v float64) string { return fmt.Sprintf("%.10g", v) }
+	row := []string{strconv.FormatInt(ts, 10), strconv.FormatInt(b.LastSeq, 10)}
+	if levels == 1 {
+		return append(row, f(b.BestBid), f(b.BestAsk), f(b.BidSize), f(b.AskSize))
+	}
+	bids, asks := b.Depth(levels)
+	for i := 0; i < levels; i++ {
+		cell := func(side []Level) (string, string) {
+			if i >= len(side) {
+				return "", ""
+			}
+			return f(side[i].Price), f(side[i].Size)
+		}
+		bp, bs := cell(bids)
+		ap, as := cell(asks)
+		row = append(row, bp, bs, ap, as)
+	}
+	return row
+}
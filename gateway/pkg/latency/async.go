@@ -0,0 +1,86 @@
+package latency
+
+import (
+	"sync"
+	"time"
+)
+
+type sample struct {
+	key seriesKey
+	d   time.Duration
+	// ack, when set, marks a Sync barrier rather than a measurement.
+	ack chan struct{}
+}
+
+type asyncSink struct {
+	ch   chan sample
+	done chan struct{}
+	// mu keeps Sync from sending on a closed channel.
+	mu     sync.RWMutex
+	closed bool
+}
+
+// StartAsync moves histogram updates off the caller's goroutine: Stop only
+// enqueues the sample and a background aggregator records it. Samples that
+// find the buffer full are dropped and counted in Overflow. The returned
+// func drains the queue and reverts to synchronous recording.
+func (r *Registry) StartAsync(buffer int) (stop func()) {
+	if buffer <= 0 {
+		buffer = 8192
+	}
+	s := &asyncSink{ch: make(chan sample, buffer), done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		for smp := range s.ch {
+			if smp.ack != nil {
+				close(smp.ack)
+				continue
+			}
+			r.series(smp.key).Record(smp.d)
+		}
+	}()
+	r.async.Store(s)
+	return func() {
+		if r.async.CompareAndSwap(s, nil) {
+			s.mu.Lock()
+			s.closed = true
+			close(s.ch)
+			s.mu.Unlock()
+			<-s.done
+		}
+	}
+}
+
+// Sync waits until every sample enqueued before the call is aggregated.
+func (r *Registry) Sync() {
+	s := r.async.Load()
+	if s == nil {
+		return
+	}
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return
+	}
+	ack := make(chan struct{})
+	s.ch <- sample{ack: ack}
+	s.mu.RUnlock()
+	<-ack
+}
+
+// Overflow counts samples dropped because the async buffer was full.
+func (r *Registry) Overflow() uint64 {
+	return r.overflow.Load()
+}
+
+func (r *Registry) record(key seriesKey, d time.Duration) {
+	if s := r.async.Load(); s != nil {
+		select {
+		case s.ch <- sample{key: key, d: d}:
+		default:
+			r.overflow.Add(1)
+		}
+		return
+	}
+	r.series(key).Record(d)
+}
@@ -0,0 +1,203 @@
+package latency
+
+import (
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// Log-linear buckets: values below 32ns are exact, above that each power of
+// two is split into 16 linear sub-buckets (<= 6.25% relative error).
+const (
+	subBits    = 4
+	subBuckets = 1 << subBits
+	numBuckets = (64-subBits-1)*subBuckets + 2*subBuckets
+)
+
+// Histogram records durations without locks; safe for concurrent use.
+type Histogram struct {
+	counts [numBuckets]atomic.Uint64
+	count  atomic.Uint64
+	sum    atomic.Uint64
+	max    atomic.Uint64
+}
+
+func bucketOf(v uint64) int {
+	if v < 2*subBuckets {
+		return int(v)
+	}
+	shift := bits.Len64(v) - (subBits + 1)
+	return shift*subBuckets + int(v>>uint(shift))
+}
+
+// bucketUpper is the largest value that maps to bucket i.
+func bucketUpper(i int) uint64 {
+	if i < 2*subBuckets {
+		return uint64(i)
+	}
+	shift := i/subBuckets - 1
+	m := uint64(i - shift*subBuckets)
+	return (m+1)<<uint(shift) - 1
+}
+
+func (h *Histogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	v := uint64(d)
+	h.counts[bucketOf(v)].Add(1)
+	h.count.Add(1)
+	h.sum.Add(v)
+	for {
+		cur := h.max.Load()
+		if v <= cur || h.max.CompareAndSwap(cur, v) {
+			return
+		}
+	}
+}
+
+func (h *Histogram) Count() uint64 { return h.count.Load() }
+
+func (h *Histogram) Max() time.Duration { return time.Duration(h.max.Load()) }
+
+func (h *Histogram) Mean() time.Duration {
+	n := h.count.Load()
+	if n == 0 {
+		return 0
+	}
+	return time.Duration(h.sum.Load() / n)
+}
+
+// Percentile returns the upper bound of the bucket holding quantile q (0..1),
+// capped at the observed max.
+func (h *Histogram) Percentile(q float64) time.Duration {
+	n := h.count.Load()
+	if n == 0 {
+		return 0
+	}
+	if q <= 0 {
+		q = 0
+	}
+	if q > 1 {
+		q = 1
+	}
+	rank := uint64(q*float64(n) + 0.5)
+	if rank == 0 {
+		rank = 1
+	}
+	var seen uint64
+	for i := range h.counts {
+		seen += h.counts[i].Load()
+		if seen >= rank {
+			v := bucketUpper(i)
+			if m := h.max.Load(); v > m {
+				v = m
+			}
+			return time.Duration(v)
+		}
+	}
+	return h.Max()
+}
+
+// Summary is a point-in-time digest of a histogram.
+type Summary struct {
+	Label  string
+	Labels Labels
+	Count  uint64
+	Sum    time.Duration
+	Mean   time.Duration
+	P50    time.Duration
+	P95    time.Duration
+	P99    time.Duration
+	Max    time.Duration
+}
+
+func (h *Histogram) Summary(label string) Summary {
+	return Summary{
+		Label: label,
+		Count: h.Count(),
+		Sum:   time.Duration(h.sum.Load()),
+		Mean:  h.Mean(),
+		P50:   h.Percentile(0.50),
+		P95:   h.Percentile(0.95),
+		P99:   h.Percentile(0.99),
+		Max:   h.Max(),
+	}
+}
+
+// snapshot is a plain copy of a histogram, used to report per-interval
+// distributions by subtracting the previous copy.
+type snapshot struct {
+	counts [numBuckets]uint64
+	count  uint64
+	sum    uint64
+	max    uint64
+}
+
+func (h *Histogram) snapshot() snapshot {
+	var s snapshot
+	for i := range h.counts {
+		s.counts[i] = h.counts[i].Load()
+	}
+	s.count = h.count.Load()
+	s.sum = h.sum.Load()
+	s.max = h.max.Load()
+	return s
+}
+
+// sub returns the samples recorded since prev. The interval max is not
+// tracked, so it is the upper bound of the highest non-empty bucket.
+func (s snapshot) sub(prev snapshot) snapshot {
+	var d snapshot
+	for i := range s.counts {
+		d.counts[i] = s.counts[i] - prev.counts[i]
+		if d.counts[i] > 0 {
+			d.max = bucketUpper(i)
+		}
+	}
+	if d.max > s.max {
+		d.max = s.max
+	}
+	d.count = s.count - prev.count
+	d.sum = s.sum - prev.sum
+	return d
+}
+
+func (s snapshot) percentile(q float64) time.Duration {
+	if s.count == 0 {
+		return 0
+	}
+	rank := uint64(q*float64(s.count) + 0.5)
+	if rank == 0 {
+		rank = 1
+	}
+	var seen uint64
+	for i, c := range s.counts {
+		seen += c
+		if seen >= rank {
+			v := bucketUpper(i)
+			if v > s.max {
+				v = s.max
+			}
+			return time.Duration(v)
+		}
+	}
+	return time.Duration(s.max)
+}
+
+func (s snapshot) summary(label string, labels Labels) Summary {
+	out := Summary{
+		Label:  label,
+		Labels: labels,
+		Count:  s.count,
+		Sum:    time.Duration(s.sum),
+		P50:    s.percentile(0.50),
+		P95:    s.percentile(0.95),
+		P99:    s.percentile(0.99),
+		Max:    time.Duration(s.max),
+	}
+	if s.count > 0 {
+		out.Mean = time.Duration(s.sum / s.count)
+	}
+	return out
+}
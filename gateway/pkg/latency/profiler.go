@@ -1,20 +1,48 @@
 package latency
 
 import (
-	"fmt"
 	"time"
 )
 
 type Profiler struct {
-	start time.Time
-	label string
+	start  time.Time
+	label  string
+	labels Labels
+	reg    *Registry
 }
 
-func Start(label string) Profiler {
-	return Profiler{start: time.Now(), label: label}
+// Start begins a measurement; an optional Labels value sets its dimensions.
+func Start(label string, labels ...Labels) Profiler {
+	return StartIn(Default, label, labels...)
 }
 
-func (p Profiler) Stop() {
+// StartIn measures into reg instead of the Default registry.
+func StartIn(reg *Registry, label string, labels ...Labels) Profiler {
+	p := Profiler{start: time.Now(), label: label, reg: reg}
+	if len(labels) > 0 {
+		p.labels = labels[0]
+	}
+	return p
+}
+
+// Label fills in dimensions only known mid-measurement (e.g. the venue a
+// route picked); empty fields leave the current value alone.
+func (p *Profiler) Label(l Labels) {
+	if l.Venue != "" {
+		p.labels.Venue = l.Venue
+	}
+	if l.Symbol != "" {
+		p.labels.Symbol = l.Symbol
+	}
+	if l.Stage != "" {
+		p.labels.Stage = l.Stage
+	}
+}
+
+// Stop records the elapsed time; use Registry.Report to read percentiles.
+// With Registry.StartAsync active this only enqueues the sample.
+func (p Profiler) Stop() time.Duration {
 	elapsed := time.Since(p.start)
-	fmt.Printf("[Profiler] %s took %s\n", p.label, elapsed)
+	p.reg.record(seriesKey{name: p.label, labels: p.labels}, elapsed)
+	return elapsed
 }
@@ -0,0 +1,158 @@
+package latency
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Labels are the dimensions a measurement is aggregated by. Empty fields
+// are simply an unlabelled dimension.
+type Labels struct {
+	Venue  string
+	Symbol string
+	Stage  string
+}
+
+func (l Labels) String() string {
+	var parts []string
+	if l.Venue != "" {
+		parts = append(parts, "venue="+l.Venue)
+	}
+	if l.Symbol != "" {
+		parts = append(parts, "symbol="+l.Symbol)
+	}
+	if l.Stage != "" {
+		parts = append(parts, "stage="+l.Stage)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+type seriesKey struct {
+	name   string
+	labels Labels
+}
+
+// Registry holds one histogram per (label, Labels) series.
+type Registry struct {
+	mu    sync.RWMutex
+	hists map[seriesKey]*Histogram
+
+	async    atomic.Pointer[asyncSink]
+	overflow atomic.Uint64
+}
+
+func NewRegistry() *Registry {
+	return &Registry{hists: make(map[seriesKey]*Histogram)}
+}
+
+// Default is the registry Profiler records into.
+var Default = NewRegistry()
+
+// Histogram returns the unlabelled histogram for label.
+func (r *Registry) Histogram(label string) *Histogram {
+	return r.Series(label, Labels{})
+}
+
+// Series returns the histogram for label and labels, creating it on first use.
+func (r *Registry) Series(label string, labels Labels) *Histogram {
+	return r.series(seriesKey{name: label, labels: labels})
+}
+
+// Observe records d under label and labels, through the async sink if one
+// is running.
+func (r *Registry) Observe(label string, labels Labels, d time.Duration) {
+	r.record(seriesKey{name: label, labels: labels}, d)
+}
+
+func (r *Registry) series(key seriesKey) *Histogram {
+	r.mu.RLock()
+	h, ok := r.hists[key]
+	r.mu.RUnlock()
+	if ok {
+		return h
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if h, ok = r.hists[key]; !ok {
+		h = &Histogram{}
+		r.hists[key] = h
+	}
+	return h
+}
+
+// Report summarises every series, sorted by label then labels.
+func (r *Registry) Report() []Summary {
+	r.mu.RLock()
+	out := make([]Summary, 0, len(r.hists))
+	for key, h := range r.hists {
+		s := h.Summary(key.name)
+		s.Labels = key.labels
+		out = append(out, s)
+	}
+	r.mu.RUnlock()
+	sortSummaries(out)
+	return out
+}
+
+func (r *Registry) snapshots() map[seriesKey]snapshot {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make(map[seriesKey]snapshot, len(r.hists))
+	for key, h := range r.hists {
+		out[key] = h.snapshot()
+	}
+	return out
+}
+
+// Window summarises the samples recorded between calls to Next.
+type Window struct {
+	reg  *Registry
+	prev map[seriesKey]snapshot
+}
+
+// Window starts a window at the registry's current state.
+func (r *Registry) Window() *Window {
+	return &Window{reg: r, prev: r.snapshots()}
+}
+
+// Next returns the series with samples since the previous call, sorted
+// like Report, and starts the next window.
+func (w *Window) Next() []Summary {
+	cur := w.reg.snapshots()
+	out := make([]Summary, 0, len(cur))
+	for key, s := range cur {
+		d := s.sub(w.prev[key])
+		if d.count == 0 {
+			continue
+		}
+		out = append(out, d.summary(key.name, key.labels))
+	}
+	w.prev = cur
+	sortSummaries(out)
+	return out
+}
+
+func sortSummaries(out []Summary) {
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Label != out[j].Label {
+			return out[i].Label < out[j].Label
+		}
+		return out[i].Labels.String() < out[j].Labels.String()
+	})
+}
+
+// WriteReport prints one line per series with count and percentiles.
+func (r *Registry) WriteReport(w io.Writer) {
+	for _, s := range r.Report() {
+		fmt.Fprintf(w, "[Latency] %s%s n=%d mean=%s p50=%s p95=%s p99=%s max=%s\n",
+			s.Label, s.Labels, s.Count, s.Mean, s.P50, s.P95, s.P99, s.Max)
+	}
+}
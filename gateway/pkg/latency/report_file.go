@@ -0,0 +1,196 @@
+package latency
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IntervalReport is one line of a FileReporter's JSON output: every series
+// that recorded samples during the interval.
+type IntervalReport struct {
+	Time     time.Time       `json:"ts"`
+	Interval float64         `json:"interval_s"`
+	Series   []IntervalEntry `json:"series"`
+}
+
+type IntervalEntry struct {
+	Op     string `json:"op"`
+	Venue  string `json:"venue,omitempty"`
+	Symbol string `json:"symbol,omitempty"`
+	Stage  string `json:"stage,omitempty"`
+	Count  uint64 `json:"count"`
+	MeanNs int64  `json:"mean_ns"`
+	P50Ns  int64  `json:"p50_ns"`
+	P95Ns  int64  `json:"p95_ns"`
+	P99Ns  int64  `json:"p99_ns"`
+	MaxNs  int64  `json:"max_ns"`
+}
+
+var csvHeader = []string{"ts", "interval_s", "op", "venue", "symbol", "stage", "count", "mean_ns", "p50_ns", "p95_ns", "p99_ns", "max_ns"}
+
+// FileReporter appends per-interval latency summaries to a file, rolling it
+// over at MaxBytes and keeping MaxFiles old copies (path.1 is the newest).
+// Format is "json" (one IntervalReport per line) or "csv"; NewFileReporter
+// picks it from the extension.
+type FileReporter struct {
+	Registry *Registry
+	Path     string
+	Interval time.Duration
+	Format   string
+	MaxBytes int64
+	MaxFiles int
+
+	f      *os.File
+	size   int64
+	window *Window
+	last   time.Time
+}
+
+func NewFileReporter(reg *Registry, path string, interval time.Duration) *FileReporter {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	format := "json"
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		format = "csv"
+	}
+	return &FileReporter{
+		Registry: reg,
+		Path:     path,
+		Interval: interval,
+		Format:   format,
+		MaxBytes: 64 << 20,
+		MaxFiles: 5,
+	}
+}
+
+// Run writes a report every Interval and a final partial one when ctx ends.
+func (r *FileReporter) Run(ctx context.Context) error {
+	r.Mark(time.Now())
+	t := time.NewTicker(r.Interval)
+	defer t.Stop()
+	defer r.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return r.Flush(time.Now())
+		case now := <-t.C:
+			if err := r.Flush(now); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Mark starts an interval at now without writing anything.
+func (r *FileReporter) Mark(now time.Time) {
+	r.window = r.Registry.Window()
+	r.last = now
+}
+
+// Flush writes the samples recorded since the previous Flush (or Mark).
+// Series without new samples are omitted.
+func (r *FileReporter) Flush(now time.Time) error {
+	if r.window == nil {
+		r.window = &Window{reg: r.Registry}
+	}
+	rep := IntervalReport{Time: now.UTC(), Interval: now.Sub(r.last).Seconds()}
+	sums := r.window.Next()
+	r.last = now
+	if len(sums) == 0 {
+		return nil
+	}
+	for _, s := range sums {
+		rep.Series = append(rep.Series, IntervalEntry{
+			Op: s.Label, Venue: s.Labels.Venue, Symbol: s.Labels.Symbol, Stage: s.Labels.Stage,
+			Count: s.Count, MeanNs: int64(s.Mean), P50Ns: int64(s.P50), P95Ns: int64(s.P95),
+			P99Ns: int64(s.P99), MaxNs: int64(s.Max),
+		})
+	}
+	return r.write(rep)
+}
+
+func (r *FileReporter) write(rep IntervalReport) error {
+	if err := r.open(); err != nil {
+		return err
+	}
+	var b strings.Builder
+	switch r.Format {
+	case "csv":
+		w := csv.NewWriter(&b)
+		if r.size == 0 {
+			_ = w.Write(csvHeader)
+		}
+		ts := rep.Time.Format(time.RFC3339Nano)
+		iv := strconv.FormatFloat(rep.Interval, 'f', 3, 64)
+		for _, e := range rep.Series {
+			_ = w.Write([]string{ts, iv, e.Op, e.Venue, e.Symbol, e.Stage,
+				strconv.FormatUint(e.Count, 10), strconv.FormatInt(e.MeanNs, 10),
+				strconv.FormatInt(e.P50Ns, 10), strconv.FormatInt(e.P95Ns, 10),
+				strconv.FormatInt(e.P99Ns, 10), strconv.FormatInt(e.MaxNs, 10)})
+		}
+		w.Flush()
+	default:
+		line, err := json.Marshal(rep)
+		if err != nil {
+			return err
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	n, err := r.f.WriteString(b.String())
+	r.size += int64(n)
+	if err != nil {
+		return err
+	}
+	if r.MaxBytes > 0 && r.size >= r.MaxBytes {
+		return r.rotate()
+	}
+	return nil
+}
+
+func (r *FileReporter) open() error {
+	if r.f != nil {
+		return nil
+	}
+	f, err := os.OpenFile(r.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, st.Size()
+	return nil
+}
+
+func (r *FileReporter) rotate() error {
+	if err := r.Close(); err != nil {
+		return err
+	}
+	if r.MaxFiles <= 0 {
+		return os.Remove(r.Path)
+	}
+	for i := r.MaxFiles - 1; i >= 1; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", r.Path, i), fmt.Sprintf("%s.%d", r.Path, i+1))
+	}
+	return os.Rename(r.Path, r.Path+".1")
+}
+
+func (r *FileReporter) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f, r.size = nil, 0
+	return err
+}
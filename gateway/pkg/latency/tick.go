@@ -0,0 +1,40 @@
+package latency
+
+import "time"
+
+// TickToTrade is the op name the end-to-end stages are recorded under.
+const TickToTrade = "tick_to_trade"
+
+// TickTimes are the timestamps an action accumulates from the market data
+// tick that triggered it to the order leaving the gateway. Zero fields are
+// unknown and their stages are skipped.
+type TickTimes struct {
+	// ExchTsMs is the venue's event time, in its own clock; ExchOffset is
+	// that clock minus ours, when known.
+	ExchTsMs   int64
+	ExchOffset time.Duration
+	// RecvNs is when the frame carrying the tick was read off the socket.
+	RecvNs      int64
+	RoutedNs    int64
+	PublishedNs int64
+}
+
+// RecordTickToTrade records exch_to_recv, recv_to_route, route_to_publish
+// and total stages for one action. exch_to_recv is corrected by ExchOffset;
+// without an estimate it includes the skew to the venue.
+func (r *Registry) RecordTickToTrade(labels Labels, t TickTimes) {
+	stage := func(name string, from, to int64) {
+		if from == 0 || to == 0 {
+			return
+		}
+		l := labels
+		l.Stage = name
+		r.Observe(TickToTrade, l, time.Duration(to-from))
+	}
+	if t.ExchTsMs != 0 {
+		stage("exch_to_recv", t.ExchTsMs*int64(time.Millisecond)-int64(t.ExchOffset), t.RecvNs)
+	}
+	stage("recv_to_route", t.RecvNs, t.RoutedNs)
+	stage("route_to_publish", t.RoutedNs, t.PublishedNs)
+	stage("total", t.RecvNs, t.PublishedNs)
+}
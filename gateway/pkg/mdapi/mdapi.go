@@ -0,0 +1,447 @@
+// Package mdapi serves market data from a running gateway as JSON over
+// HTTP for tools that don't stream: current books, recent trades and
+// candles, and queries over recorded captures found through the catalog.
+package mdapi
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/backtest"
+	"github.com/helix-lab/helix/gateway/pkg/catalog"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// Recent keeps the latest trades and completed bars per symbol. The
+// gateway loop adds; HTTP handlers read.
+type Recent struct {
+	max int
+
+	mu     sync.RWMutex
+	trades map[string][]transport.Trade
+	bars   map[string][]transport.Bar
+}
+
+// NewRecent keeps up to max trades and max bars per symbol.
+func NewRecent(max int) *Recent {
+	if max <= 0 {
+		max = 1000
+	}
+	return &Recent{max: max, trades: map[string][]transport.Trade{}, bars: map[string][]transport.Bar{}}
+}
+
+func (r *Recent) AddTrade(t transport.Trade) {
+	r.mu.Lock()
+	r.trades[t.Symbol] = keep(append(r.trades[t.Symbol], t), r.max)
+	r.mu.Unlock()
+}
+
+func (r *Recent) AddBar(b transport.Bar) {
+	r.mu.Lock()
+	r.bars[b.Symbol] = keep(append(r.bars[b.Symbol], b), r.max)
+	r.mu.Unlock()
+}
+
+// keep drops the oldest entries beyond max, reusing the backing array.
+func keep[T any](s []T, max int) []T {
+	if len(s) <= max {
+		return s
+	}
+	n := copy(s, s[len(s)-max:])
+	return s[:n]
+}
+
+// Server wires the API to the gateway; nil components answer 404 on their
+// endpoints.
+type Server struct {
+	// Token, when set, is required as a bearer token.
+	Token  string
+	Books  *orderbook.Manager
+	Recent *Recent
+	// DataDir is scanned for captures on every recorded-data request.
+	DataDir string
+	// MaxRows caps the rows one history query returns (default 100000).
+	MaxRows int
+}
+
+// Handler serves the API under /md/v1/.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/md/v1/books", s.get(s.books))
+	mux.HandleFunc("/md/v1/books/", s.get(s.books))
+	mux.HandleFunc("/md/v1/trades", s.get(s.trades))
+	mux.HandleFunc("/md/v1/candles", s.get(s.candles))
+	mux.HandleFunc("/md/v1/datasets", s.get(s.datasets))
+	mux.HandleFunc("/md/v1/history/trades", s.get(s.historyTrades))
+	mux.HandleFunc("/md/v1/history/quotes", s.get(s.historyQuotes))
+	return s.auth(mux)
+}
+
+var errNotAvailable = errors.New("not available in this gateway")
+
+type httpError struct {
+	code int
+	err  error
+}
+
+func (e httpError) Error() string { return e.err.Error() }
+
+func badRequest(format string, args ...any) error {
+	return httpError{code: http.StatusBadRequest, err: fmt.Errorf(format, args...)}
+}
+
+func (s *Server) auth(next http.Handler) http.Handler {
+	if s.Token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(s.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="helix-md"`)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) get(fn func(r *http.Request) (any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "use GET"})
+			return
+		}
+		v, err := fn(r)
+		if err != nil {
+			code := http.StatusInternalServerError
+			var he httpError
+			switch {
+			case errors.As(err, &he):
+				code = he.code
+			case errors.Is(err, errNotAvailable):
+				code = http.StatusNotFound
+			}
+			writeJSON(w, code, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, v)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+type Level struct {
+	BestBid float64 `json:"best_bid"`
+	BestAsk float64 `json:"best_ask"`
+	BidSize float64 `json:"bid_size"`
+	AskSize float64 `json:"ask_size"`
+}
+
+// SymbolBooks is one market of a symbol; a symbol traded as spot and as a
+// perpetual has one each.
+type SymbolBooks struct {
+	Symbol   string           `json:"symbol"`
+	Category string           `json:"category"`
+	NBBO     Level            `json:"nbbo"`
+	Venues   map[string]Level `json:"venues"`
+}
+
+// LevelOf, TradeOf and BarOf convert to the wire types, which the
+// strategy API shares.
+func LevelOf(l orderbook.Level) Level {
+	return Level{BestBid: l.BestBid, BestAsk: l.BestAsk, BidSize: l.BidSize, AskSize: l.AskSize}
+}
+
+func (s *Server) books(r *http.Request) (any, error) {
+	if s.Books == nil {
+		return nil, errNotAvailable
+	}
+	symbols := s.Books.Symbols()
+	if sym := strings.TrimPrefix(r.URL.Path, "/md/v1/books/"); sym != r.URL.Path && sym != "" {
+		symbols = []string{strings.ToUpper(sym)}
+	}
+	out := make([]SymbolBooks, 0, len(symbols))
+	for _, sym := range symbols {
+		cats := s.Books.Categories(sym)
+		if len(cats) == 0 {
+			return nil, httpError{code: http.StatusNotFound, err: errors.New("no book for " + sym)}
+		}
+		for _, cat := range cats {
+			venues := s.Books.MarketSnapshot(sym, cat)
+			sb := SymbolBooks{Symbol: sym, Category: cat, NBBO: LevelOf(orderbook.MergeBest(venues)), Venues: map[string]Level{}}
+			for v, l := range venues {
+				sb.Venues[v] = LevelOf(l)
+			}
+			out = append(out, sb)
+		}
+	}
+	return out, nil
+}
+
+type Trade struct {
+	TsMs   int64   `json:"ts_ms"`
+	Venue  string  `json:"venue"`
+	Symbol string  `json:"symbol"`
+	Side   string  `json:"side"`
+	Price  float64 `json:"price"`
+	Qty    float64 `json:"qty"`
+}
+
+func TradeOf(t transport.Trade) Trade {
+	return Trade{TsMs: t.TsMs, Venue: t.Venue, Symbol: t.Symbol, Side: t.Side, Price: t.Price, Qty: t.Qty}
+}
+
+type Bar struct {
+	StartMs  int64   `json:"start_ms"`
+	Interval string  `json:"interval"`
+	Venue    string  `json:"venue"`
+	Symbol   string  `json:"symbol"`
+	Open     float64 `json:"open"`
+	High     float64 `json:"high"`
+	Low      float64 `json:"low"`
+	Close    float64 `json:"close"`
+	Volume   float64 `json:"volume"`
+	VWAP     float64 `json:"vwap"`
+	Trades   int     `json:"trades"`
+}
+
+func BarOf(b transport.Bar) Bar {
+	return Bar{StartMs: b.StartMs, Interval: b.Interval.String(), Venue: b.Venue, Symbol: b.Symbol,
+		Open: b.Open, High: b.High, Low: b.Low, Close: b.Close, Volume: b.Volume, VWAP: b.VWAP, Trades: b.Trades}
+}
+
+// params are the query parameters every listing shares.
+type params struct {
+	symbol string
+	venue  string
+	limit  int
+	from   time.Time
+	to     time.Time
+}
+
+func parseParams(r *http.Request, needSymbol bool, defLimit, maxLimit int) (params, error) {
+	q := r.URL.Query()
+	p := params{symbol: strings.ToUpper(q.Get("symbol")), venue: strings.ToUpper(q.Get("venue")), limit: defLimit}
+	if needSymbol && p.symbol == "" {
+		return p, badRequest("symbol is required")
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return p, badRequest("limit must be a positive integer")
+		}
+		p.limit = min(n, maxLimit)
+	}
+	var err error
+	if p.from, err = parseTime(q.Get("from")); err != nil {
+		return p, badRequest("from: %v", err)
+	}
+	if p.to, err = parseTime(q.Get("to")); err != nil {
+		return p, badRequest("to: %v", err)
+	}
+	return p, nil
+}
+
+// parseTime accepts RFC 3339 or Unix milliseconds; empty is the zero time.
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	return time.Parse(time.RFC3339Nano, v)
+}
+
+func (p params) inRange(ms int64) bool {
+	return (p.from.IsZero() || ms >= p.from.UnixMilli()) && (p.to.IsZero() || ms < p.to.UnixMilli())
+}
+
+// trades returns the latest trades of symbol, oldest first.
+func (s *Server) trades(r *http.Request) (any, error) {
+	if s.Recent == nil {
+		return nil, errNotAvailable
+	}
+	p, err := parseParams(r, true, 100, s.Recent.max)
+	if err != nil {
+		return nil, err
+	}
+	s.Recent.mu.RLock()
+	defer s.Recent.mu.RUnlock()
+	out := []Trade{}
+	list := s.Recent.trades[p.symbol]
+	for i := len(list) - 1; i >= 0 && len(out) < p.limit; i-- {
+		if t := list[i]; (p.venue == "" || t.Venue == p.venue) && p.inRange(t.TsMs) {
+			out = append(out, TradeOf(t))
+		}
+	}
+	reverse(out)
+	return out, nil
+}
+
+// candles returns the latest completed bars of symbol, oldest first.
+func (s *Server) candles(r *http.Request) (any, error) {
+	if s.Recent == nil {
+		return nil, errNotAvailable
+	}
+	p, err := parseParams(r, true, 100, s.Recent.max)
+	if err != nil {
+		return nil, err
+	}
+	var interval time.Duration
+	if v := r.URL.Query().Get("interval"); v != "" {
+		if interval, err = time.ParseDuration(v); err != nil {
+			return nil, badRequest("interval: %v", err)
+		}
+	}
+	s.Recent.mu.RLock()
+	defer s.Recent.mu.RUnlock()
+	out := []Bar{}
+	list := s.Recent.bars[p.symbol]
+	for i := len(list) - 1; i >= 0 && len(out) < p.limit; i-- {
+		b := list[i]
+		if (p.venue == "" || b.Venue == p.venue) && (interval == 0 || b.Interval == interval) && p.inRange(b.StartMs) {
+			out = append(out, BarOf(b))
+		}
+	}
+	reverse(out)
+	return out, nil
+}
+
+func (s *Server) catalog() (*catalog.Catalog, error) {
+	if s.DataDir == "" {
+		return nil, errNotAvailable
+	}
+	return catalog.Scan(s.DataDir)
+}
+
+func (s *Server) maxRows() int {
+	if s.MaxRows > 0 {
+		return s.MaxRows
+	}
+	return 100000
+}
+
+func (s *Server) datasets(r *http.Request) (any, error) {
+	cat, err := s.catalog()
+	if err != nil {
+		return nil, err
+	}
+	p, err := parseParams(r, false, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	out := withStatus(r, cat.Find(r.URL.Query().Get("kind"), p.symbol, p.from, p.to))
+	if out == nil {
+		out = []catalog.Dataset{}
+	}
+	return out, nil
+}
+
+// withStatus applies the status query parameter, a comma-separated list of
+// validation statuses, so jobs can refuse unvalidated captures.
+func withStatus(r *http.Request, sets []catalog.Dataset) []catalog.Dataset {
+	v := r.URL.Query().Get("status")
+	if v == "" {
+		return sets
+	}
+	return catalog.WithStatus(sets, strings.Split(v, ",")...)
+}
+
+// Quote is the top of book after one recorded L2 message.
+type Quote struct {
+	TsMs int64 `json:"ts_ms"`
+	Level
+}
+
+// History is a recorded-data query result. Truncated is set when more
+// rows matched than the limit.
+type History[T any] struct {
+	Symbol    string   `json:"symbol"`
+	Datasets  []string `json:"datasets"`
+	Rows      []T      `json:"rows"`
+	Truncated bool     `json:"truncated"`
+}
+
+func (s *Server) historyTrades(r *http.Request) (any, error) {
+	return history(s, r, catalog.KindTrades, backtest.LoadTradesCSV, func(ev backtest.Event) (Trade, bool) {
+		if ev.Trade == nil {
+			return Trade{}, false
+		}
+		return TradeOf(*ev.Trade), true
+	})
+}
+
+func (s *Server) historyQuotes(r *http.Request) (any, error) {
+	return history(s, r, catalog.KindL2, backtest.LoadL2CSV, func(ev backtest.Event) (Quote, bool) {
+		if ev.Depth == nil {
+			return Quote{}, false
+		}
+		d := ev.Depth
+		return Quote{TsMs: d.ExchTsMs, Level: Level{BestBid: d.BestBid, BestAsk: d.BestAsk, BidSize: d.BidSize, AskSize: d.AskSize}}, true
+	})
+}
+
+// history loads the symbol's captures of kind overlapping the requested
+// range and returns their rows in time order, up to the limit.
+func history[T any](s *Server, r *http.Request, kind string, load func(path, venue, symbol string) ([]backtest.Event, error), row func(backtest.Event) (T, bool)) (any, error) {
+	cat, err := s.catalog()
+	if err != nil {
+		return nil, err
+	}
+	p, err := parseParams(r, true, 1000, s.maxRows())
+	if err != nil {
+		return nil, err
+	}
+	sets := withStatus(r, cat.Find(kind, p.symbol, p.from, p.to))
+	out := History[T]{Symbol: p.symbol, Datasets: []string{}, Rows: []T{}}
+	var streams [][]backtest.Event
+	for _, d := range sets {
+		if p.venue != "" && d.Venue != p.venue {
+			continue
+		}
+		evs, err := load(d.Path, d.Venue, d.Symbol)
+		if err != nil {
+			return nil, err
+		}
+		out.Datasets = append(out.Datasets, d.Path)
+		streams = append(streams, evs)
+	}
+	for _, ev := range backtest.Merge(streams...) {
+		ms := ev.TsNs / 1e6
+		if !p.inRange(ms) {
+			continue
+		}
+		v, ok := row(ev)
+		if !ok {
+			continue
+		}
+		if len(out.Rows) == p.limit {
+			out.Truncated = true
+			break
+		}
+		out.Rows = append(out.Rows, v)
+	}
+	sort.Strings(out.Datasets)
+	return out, nil
+}
+
+func reverse[T any](s []T) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
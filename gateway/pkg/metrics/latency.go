@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/helix-lab/helix/gateway/pkg/latency"
+)
+
+// RegisterLatency exports every profiler series in lat as a summary
+// (p50/p95/p99 plus _sum and _count) named helix_latency_seconds, labelled
+// by op, venue, symbol and stage.
+func RegisterLatency(reg *Registry, lat *latency.Registry) {
+	reg.Collect("helix_latency_seconds", "Profiled latency by operation.", "summary", func(emit Emit) {
+		for _, s := range lat.Report() {
+			for _, q := range []struct {
+				q float64
+				v float64
+			}{{0.5, s.P50.Seconds()}, {0.95, s.P95.Seconds()}, {0.99, s.P99.Seconds()}} {
+				emit("", append(latencyLabels(s), Label{"quantile", strconv.FormatFloat(q.q, 'g', -1, 64)}), q.v)
+			}
+			emit("_sum", latencyLabels(s), s.Sum.Seconds())
+			emit("_count", latencyLabels(s), float64(s.Count))
+		}
+	})
+	reg.Collect("helix_latency_max_seconds", "Largest profiled latency by operation.", "gauge", func(emit Emit) {
+		for _, s := range lat.Report() {
+			emit("", latencyLabels(s), s.Max.Seconds())
+		}
+	})
+	reg.CounterFunc("helix_latency_samples_dropped_total", "Profiler samples dropped because the async sink was full.", func() float64 {
+		return float64(lat.Overflow())
+	})
+}
+
+func latencyLabels(s latency.Summary) Labels {
+	return L("op", s.Label, "venue", s.Labels.Venue, "symbol", s.Labels.Symbol, "stage", s.Labels.Stage)
+}
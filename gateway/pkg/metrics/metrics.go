@@ -0,0 +1,297 @@
+// Package metrics is a small Prometheus-compatible registry. Instruments are
+// get-or-create by name so packages can declare them at init time, and
+// collectors let subsystems expose state they already track without
+// double-counting.
+//
+// Names follow helix_<subsystem>_<what>[_<unit>][_total], with subsystems
+// feed (connectors), router (market-data fan-out), book, routing (venue
+// selection), executor, risk, portfolio, arb (funding arbitrage), basis, strategy, transport, sink (external stores),
+// clock, latency and gateway;
+// Go runtime families keep their usual go_ names.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Labels is an ordered set of label pairs.
+type Labels []Label
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+// L builds Labels from alternating name/value strings.
+func L(kv ...string) Labels {
+	out := make(Labels, 0, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		out = append(out, Label{Name: kv[i], Value: kv[i+1]})
+	}
+	return out
+}
+
+// Emit receives one sample from a collector. suffix is appended to the
+// family name (e.g. "_sum"); labels may be nil.
+type Emit func(suffix string, labels Labels, value float64)
+
+type family struct {
+	name    string
+	help    string
+	typ     string
+	collect func(emit Emit)
+}
+
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+	counters map[string]*CounterVec
+	gauges   map[string]*GaugeVec
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		families: make(map[string]*family),
+		counters: make(map[string]*CounterVec),
+		gauges:   make(map[string]*GaugeVec),
+	}
+}
+
+// Default is the process-wide registry served by the gateway's /metrics.
+var Default = NewRegistry()
+
+// Collect registers (or replaces) a family whose samples are produced on
+// scrape. typ is a Prometheus type: counter, gauge, summary or untyped.
+func (r *Registry) Collect(name, help, typ string, collect func(emit Emit)) {
+	r.mu.Lock()
+	r.families[name] = &family{name: name, help: help, typ: typ, collect: collect}
+	r.mu.Unlock()
+}
+
+// CounterFunc exposes a monotonically increasing value read on scrape.
+func (r *Registry) CounterFunc(name, help string, fn func() float64) {
+	r.Collect(name, help, "counter", func(emit Emit) { emit("", nil, fn()) })
+}
+
+// GaugeFunc exposes a value read on scrape.
+func (r *Registry) GaugeFunc(name, help string, fn func() float64) {
+	r.Collect(name, help, "gauge", func(emit Emit) { emit("", nil, fn()) })
+}
+
+// Counter returns the unlabelled counter name.
+func (r *Registry) Counter(name, help string) *Counter {
+	return r.CounterVec(name, help).With()
+}
+
+// CounterVec returns the counter family name keyed by labelNames.
+func (r *Registry) CounterVec(name, help string, labelNames ...string) *CounterVec {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if v, ok := r.counters[name]; ok {
+		return v
+	}
+	v := &CounterVec{vec: newVec[Counter](labelNames)}
+	r.counters[name] = v
+	r.families[name] = &family{name: name, help: help, typ: "counter", collect: func(emit Emit) {
+		v.vec.each(func(l Labels, c *Counter) { emit("", l, c.Value()) })
+	}}
+	return v
+}
+
+// Gauge returns the unlabelled gauge name.
+func (r *Registry) Gauge(name, help string) *Gauge {
+	return r.GaugeVec(name, help).With()
+}
+
+// GaugeVec returns the gauge family name keyed by labelNames.
+func (r *Registry) GaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if v, ok := r.gauges[name]; ok {
+		return v
+	}
+	v := &GaugeVec{vec: newVec[Gauge](labelNames)}
+	r.gauges[name] = v
+	r.families[name] = &family{name: name, help: help, typ: "gauge", collect: func(emit Emit) {
+		v.vec.each(func(l Labels, g *Gauge) { emit("", l, g.Value()) })
+	}}
+	return v
+}
+
+// WriteText renders every family in the Prometheus text exposition format.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	fams := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		fams = append(fams, f)
+	}
+	r.mu.Unlock()
+	sort.Slice(fams, func(i, j int) bool { return fams[i].name < fams[j].name })
+
+	var b strings.Builder
+	for _, f := range fams {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.typ)
+		f.collect(func(suffix string, labels Labels, v float64) {
+			b.WriteString(f.name)
+			b.WriteString(suffix)
+			writeLabels(&b, labels)
+			b.WriteByte(' ')
+			b.WriteString(formatValue(v))
+			b.WriteByte('\n')
+		})
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Handler serves the registry on /metrics.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = r.WriteText(w)
+	})
+}
+
+type Counter struct{ bits atomic.Uint64 }
+
+func (c *Counter) Inc() { c.Add(1) }
+
+// Add increases the counter; negative deltas are ignored.
+func (c *Counter) Add(d float64) {
+	if d <= 0 {
+		return
+	}
+	for {
+		old := c.bits.Load()
+		if c.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+d)) {
+			return
+		}
+	}
+}
+
+func (c *Counter) Value() float64 { return math.Float64frombits(c.bits.Load()) }
+
+type Gauge struct{ bits atomic.Uint64 }
+
+func (g *Gauge) Set(v float64) { g.bits.Store(math.Float64bits(v)) }
+
+func (g *Gauge) Add(d float64) {
+	for {
+		old := g.bits.Load()
+		if g.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+d)) {
+			return
+		}
+	}
+}
+
+func (g *Gauge) Value() float64 { return math.Float64frombits(g.bits.Load()) }
+
+type CounterVec struct{ vec *vec[Counter] }
+
+// With returns the counter for the given label values, in labelNames order.
+func (v *CounterVec) With(values ...string) *Counter { return v.vec.get(values) }
+
+type GaugeVec struct{ vec *vec[Gauge] }
+
+func (v *GaugeVec) With(values ...string) *Gauge { return v.vec.get(values) }
+
+type vec[T any] struct {
+	names []string
+	mu    sync.RWMutex
+	items map[string]*vecItem[T]
+}
+
+type vecItem[T any] struct {
+	labels Labels
+	inst   *T
+}
+
+func newVec[T any](names []string) *vec[T] {
+	return &vec[T]{names: names, items: make(map[string]*vecItem[T])}
+}
+
+func (v *vec[T]) get(values []string) *T {
+	key := strings.Join(values, "\xff")
+	v.mu.RLock()
+	it, ok := v.items[key]
+	v.mu.RUnlock()
+	if ok {
+		return it.inst
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if it, ok = v.items[key]; ok {
+		return it.inst
+	}
+	labels := make(Labels, 0, len(v.names))
+	for i, n := range v.names {
+		val := ""
+		if i < len(values) {
+			val = values[i]
+		}
+		labels = append(labels, Label{Name: n, Value: val})
+	}
+	it = &vecItem[T]{labels: labels, inst: new(T)}
+	v.items[key] = it
+	return it.inst
+}
+
+func (v *vec[T]) each(fn func(Labels, *T)) {
+	v.mu.RLock()
+	keys := make([]string, 0, len(v.items))
+	for k := range v.items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	items := make([]*vecItem[T], 0, len(keys))
+	for _, k := range keys {
+		items = append(items, v.items[k])
+	}
+	v.mu.RUnlock()
+	for _, it := range items {
+		fn(it.labels, it.inst)
+	}
+}
+
+func writeLabels(b *strings.Builder, labels Labels) {
+	if len(labels) == 0 {
+		return
+	}
+	b.WriteByte('{')
+	for i, l := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l.Name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(l.Value))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
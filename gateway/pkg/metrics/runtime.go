@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RegisterRuntime exports goroutine, heap and GC state. MemStats is read at
+// most once per scrape interval (ReadMemStats briefly stops the world), so
+// several families scraped together share one read.
+func RegisterRuntime(reg *Registry) {
+	ms := &memStatsCache{}
+	reg.GaugeFunc("go_goroutines", "Number of goroutines that currently exist.", func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+	reg.GaugeFunc("go_gomaxprocs", "GOMAXPROCS.", func() float64 {
+		return float64(runtime.GOMAXPROCS(0))
+	})
+	reg.GaugeFunc("go_memstats_heap_alloc_bytes", "Bytes of allocated heap objects.", func() float64 {
+		return float64(ms.get().HeapAlloc)
+	})
+	reg.GaugeFunc("go_memstats_heap_inuse_bytes", "Bytes in in-use heap spans.", func() float64 {
+		return float64(ms.get().HeapInuse)
+	})
+	reg.GaugeFunc("go_memstats_heap_objects", "Number of allocated heap objects.", func() float64 {
+		return float64(ms.get().HeapObjects)
+	})
+	reg.GaugeFunc("go_memstats_sys_bytes", "Bytes obtained from the OS.", func() float64 {
+		return float64(ms.get().Sys)
+	})
+	reg.CounterFunc("go_memstats_mallocs_total", "Heap objects allocated.", func() float64 {
+		return float64(ms.get().Mallocs)
+	})
+	reg.GaugeFunc("go_memstats_next_gc_bytes", "Heap size target of the next GC cycle.", func() float64 {
+		return float64(ms.get().NextGC)
+	})
+	reg.Collect("go_gc_duration_seconds", "Stop-the-world GC pauses over the last 256 cycles.", "summary", func(emit Emit) {
+		m := ms.get()
+		n := int(m.NumGC)
+		if n > len(m.PauseNs) {
+			n = len(m.PauseNs)
+		}
+		pauses := make([]float64, 0, n)
+		for i := 0; i < n; i++ {
+			pauses = append(pauses, time.Duration(m.PauseNs[i]).Seconds())
+		}
+		sort.Float64s(pauses)
+		for _, q := range []float64{0, 0.5, 0.99, 1} {
+			v := 0.0
+			if len(pauses) > 0 {
+				v = pauses[int(q*float64(len(pauses)-1))]
+			}
+			emit("", L("quantile", strconv.FormatFloat(q, 'g', -1, 64)), v)
+		}
+		emit("_sum", nil, time.Duration(m.PauseTotalNs).Seconds())
+		emit("_count", nil, float64(m.NumGC))
+	})
+}
+
+type memStatsCache struct {
+	mu   sync.Mutex
+	at   time.Time
+	last runtime.MemStats
+}
+
+func (c *memStatsCache) get() *runtime.MemStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.at) > time.Second {
+		runtime.ReadMemStats(&c.last)
+		c.at = time.Now()
+	}
+	m := c.last
+	return &m
+}
+
+// HandlePprof mounts net/http/pprof under /debug/pprof/ on mux, without
+// touching http.DefaultServeMux.
+func HandlePprof(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
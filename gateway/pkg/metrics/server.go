@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+)
+
+// Source is a subsystem that exposes its own state on a registry.
+// ws.Router, ws.ClockMonitor, orderbook.Manager, executor.OrderSender,
+// executor.Tracker and strategy.Host implement it.
+type Source interface {
+	RegisterMetrics(reg *Registry)
+}
+
+// SourceFunc adapts a registration function such as RegisterRuntime.
+type SourceFunc func(reg *Registry)
+
+func (f SourceFunc) RegisterMetrics(reg *Registry) { f(reg) }
+
+// Server is the process's one exposition endpoint: /metrics for every
+// registered source, /debug/pprof/ when enabled, and whatever else the
+// process mounts with Handle (the gateway's admin API).
+type Server struct {
+	Registry *Registry
+
+	mux *http.ServeMux
+	srv *http.Server
+	ln  net.Listener
+}
+
+// NewServer serves reg with the Go runtime metrics already registered.
+func NewServer(reg *Registry, pprof bool) *Server {
+	s := &Server{Registry: reg, mux: http.NewServeMux()}
+	RegisterRuntime(reg)
+	s.mux.Handle("/metrics", reg.Handler())
+	if pprof {
+		HandlePprof(s.mux)
+	}
+	return s
+}
+
+func (s *Server) Register(sources ...Source) {
+	for _, src := range sources {
+		src.RegisterMetrics(s.Registry)
+	}
+}
+
+func (s *Server) Handle(pattern string, h http.Handler) {
+	s.mux.Handle(pattern, h)
+}
+
+// Start listens on addr (so a busy port fails here) and serves in the
+// background.
+func (s *Server) Start(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.ln = ln
+	s.srv = &http.Server{Handler: s.mux}
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("metrics server: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Addr is the listening address once started.
+func (s *Server) Addr() string {
+	if s.ln == nil {
+		return ""
+	}
+	return s.ln.Addr().String()
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.srv == nil {
+		return nil
+	}
+	return s.srv.Shutdown(ctx)
+}
@@ -0,0 +1,279 @@
+package mockexchange
+
+import (
+	"fmt"
+	"hash/crc32"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/capture"
+)
+
+// Level is a [price, size] pair as the venue's decimal strings; a size of
+// "0" deletes the level.
+type Level [2]string
+
+// Book is one orderbook message.
+type Book struct {
+	Symbol     string
+	Depth      int
+	Snapshot   bool
+	Seq        int64
+	Ts         int64
+	Bids, Asks []Level
+}
+
+// Frame encodes b in protocol p's wire format: a Bybit orderbook.<depth>
+// message, a Binance futures depthUpdate or an OKX books-l2-tbt push. OKX
+// pushes carry a checksum of the whole book, which only Frames knows;
+// here it is 0. Kraken deltas carry one level each, so only Frames
+// encodes Kraken books.
+func (b Book) Frame(p Protocol) []byte {
+	if p == OKX {
+		return b.okxFrame(0)
+	}
+	var w strings.Builder
+	levels := func(ls []Level) {
+		w.WriteByte('[')
+		for i, l := range ls {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(&w, "[%q,%q]", l[0], l[1])
+		}
+		w.WriteByte(']')
+	}
+	if p == Binance {
+		fmt.Fprintf(&w, `{"e":"depthUpdate","E":%d,"T":%d,"s":%q,"U":%d,"u":%d,"pu":%d,"b":`, b.Ts, b.Ts, b.Symbol, b.Seq, b.Seq, b.Seq-1)
+		levels(b.Bids)
+		w.WriteString(`,"a":`)
+		levels(b.Asks)
+		w.WriteByte('}')
+		return []byte(w.String())
+	}
+	typ := "delta"
+	if b.Snapshot {
+		typ = "snapshot"
+	}
+	fmt.Fprintf(&w, `{"topic":"orderbook.%d.%s","type":%q,"ts":%d,"data":{"s":%q,"b":`, b.Depth, b.Symbol, typ, b.Ts, b.Symbol)
+	levels(b.Bids)
+	w.WriteString(`,"a":`)
+	levels(b.Asks)
+	fmt.Fprintf(&w, `,"u":%d,"seq":%d},"cts":%d}`, b.Seq, b.Seq, b.Ts)
+	return []byte(w.String())
+}
+
+// Walk is a seeded random book: a snapshot of depth levels a side around
+// 100, then n-1 deltas each setting or deleting a few levels, with Seq
+// 1..n and Ts a millisecond apart. Dropping an element makes a gap.
+func Walk(symbol string, depth, n int, seed int64) []Book {
+	rng := rand.New(rand.NewSource(seed))
+	px := func(ticks int) string { return strconv.FormatFloat(float64(ticks)/10, 'f', 1, 64) }
+	size := func() string { return strconv.FormatFloat(float64(1+rng.Intn(5000))/1000, 'f', 3, 64) }
+	const mid = 1000 // ticks of 0.1
+	books := make([]Book, n)
+	for i := range books {
+		b := Book{Symbol: symbol, Depth: depth, Seq: int64(i + 1), Ts: 1700000000000 + int64(i)}
+		if i == 0 {
+			b.Snapshot = true
+			for j := 0; j < depth; j++ {
+				b.Bids = append(b.Bids, Level{px(mid - 1 - j), size()})
+				b.Asks = append(b.Asks, Level{px(mid + 1 + j), size()})
+			}
+		} else {
+			for k := rng.Intn(4); k >= 0; k-- {
+				j := rng.Intn(depth)
+				sz := size()
+				if rng.Intn(4) == 0 {
+					sz = "0"
+				}
+				if rng.Intn(2) == 0 {
+					b.Bids = append(b.Bids, Level{px(mid - 1 - j), sz})
+				} else {
+					b.Asks = append(b.Asks, Level{px(mid + 1 + j), sz})
+				}
+			}
+		}
+		books[i] = b
+	}
+	return books
+}
+
+// Frames encodes books for protocol p, OKX ones with the checksum of the
+// book they leave. A Kraken book is a book_snapshot or one book frame per
+// level, numbered on from the first book's Seq times 1000, so the frames
+// of a later Snapshot follow on.
+func Frames(p Protocol, books []Book) [][]byte {
+	if p == Kraken {
+		return krakenFrames(books)
+	}
+	out := make([][]byte, len(books))
+	var l ladder
+	for i, b := range books {
+		if p == OKX {
+			l.apply(b)
+			out[i] = b.okxFrame(l.checksum())
+			continue
+		}
+		out[i] = b.Frame(p)
+	}
+	return out
+}
+
+// Snapshot is the book books leave, as one snapshot message at the last
+// one's Seq and Ts.
+func Snapshot(books []Book) Book {
+	var l ladder
+	for _, b := range books {
+		l.apply(b)
+	}
+	last := books[len(books)-1]
+	bids, asks := l.sorted()
+	return Book{Symbol: last.Symbol, Depth: last.Depth, Snapshot: true, Seq: last.Seq, Ts: last.Ts, Bids: bids, Asks: asks}
+}
+
+func krakenFrames(books []Book) [][]byte {
+	var out [][]byte
+	var seq int64
+	for i, b := range books {
+		if i == 0 {
+			seq = b.Seq * 1000
+		}
+		if b.Snapshot {
+			var w strings.Builder
+			levels := func(ls []Level) {
+				w.WriteByte('[')
+				for i, l := range ls {
+					if i > 0 {
+						w.WriteByte(',')
+					}
+					fmt.Fprintf(&w, `{"price":%s,"qty":%s}`, l[0], l[1])
+				}
+				w.WriteByte(']')
+			}
+			fmt.Fprintf(&w, `{"feed":"book_snapshot","product_id":%q,"timestamp":%d,"seq":%d,"tickSize":null,"bids":`, b.Symbol, b.Ts, seq)
+			levels(b.Bids)
+			w.WriteString(`,"asks":`)
+			levels(b.Asks)
+			w.WriteByte('}')
+			out = append(out, []byte(w.String()))
+			seq++
+			continue
+		}
+		for _, side := range []struct {
+			name   string
+			levels []Level
+		}{{"buy", b.Bids}, {"sell", b.Asks}} {
+			for _, l := range side.levels {
+				out = append(out, []byte(fmt.Sprintf(`{"feed":"book","product_id":%q,"side":%q,"seq":%d,"price":%s,"qty":%s,"timestamp":%d}`,
+					b.Symbol, side.name, seq, l[0], l[1], b.Ts)))
+				seq++
+			}
+		}
+	}
+	return out
+}
+
+func (b Book) okxFrame(checksum int32) []byte {
+	var w strings.Builder
+	levels := func(ls []Level) {
+		w.WriteByte('[')
+		for i, l := range ls {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(&w, `[%q,%q,"0","1"]`, l[0], l[1])
+		}
+		w.WriteByte(']')
+	}
+	action, prev := "update", b.Seq-1
+	if b.Snapshot {
+		action, prev = "snapshot", -1
+	}
+	fmt.Fprintf(&w, `{"arg":{"channel":"books-l2-tbt","instId":%q},"action":%q,"data":[{"asks":`, b.Symbol, action)
+	levels(b.Asks)
+	w.WriteString(`,"bids":`)
+	levels(b.Bids)
+	fmt.Fprintf(&w, `,"ts":"%d","checksum":%d,"prevSeqId":%d,"seqId":%d}]}`, b.Ts, checksum, prev, b.Seq)
+	return []byte(w.String())
+}
+
+// ladder is the book messages build up, by price string.
+type ladder struct {
+	bids, asks map[string]string
+}
+
+func (l *ladder) apply(b Book) {
+	if b.Snapshot || l.bids == nil {
+		l.bids, l.asks = map[string]string{}, map[string]string{}
+	}
+	for _, side := range []struct {
+		m      map[string]string
+		levels []Level
+	}{{l.bids, b.Bids}, {l.asks, b.Asks}} {
+		for _, lv := range side.levels {
+			if lv[1] == "0" {
+				delete(side.m, lv[0])
+			} else {
+				side.m[lv[0]] = lv[1]
+			}
+		}
+	}
+}
+
+// sorted is the book best first.
+func (l *ladder) sorted() (bids, asks []Level) {
+	for px, sz := range l.bids {
+		bids = append(bids, Level{px, sz})
+	}
+	for px, sz := range l.asks {
+		asks = append(asks, Level{px, sz})
+	}
+	price := func(l Level) float64 { p, _ := strconv.ParseFloat(l[0], 64); return p }
+	sort.Slice(bids, func(i, j int) bool { return price(bids[i]) > price(bids[j]) })
+	sort.Slice(asks, func(i, j int) bool { return price(asks[i]) < price(asks[j]) })
+	return bids, asks
+}
+
+// checksum is OKX's: CRC32 of the top 25 bids and asks interleaved as
+// bidPx:bidSz:askPx:askSz:...
+func (l *ladder) checksum() int32 {
+	bids, asks := l.sorted()
+	var parts []string
+	for i := 0; i < 25; i++ {
+		if i < len(bids) {
+			parts = append(parts, bids[i][0], bids[i][1])
+		}
+		if i < len(asks) {
+			parts = append(parts, asks[i][0], asks[i][1])
+		}
+	}
+	return int32(crc32.ChecksumIEEE([]byte(strings.Join(parts, ":"))))
+}
+
+// Recorded replays a frame log or archive (see capture.ReadFrames) with
+// its recorded spacing divided by speed; speed 0 sends as fast as
+// possible.
+func Recorded(path string, speed float64) ([]Step, error) {
+	var steps []Step
+	var first int64
+	err := capture.ReadFrames(path, func(fr capture.Frame) error {
+		data := []byte(fr.Raw)
+		if fr.Raw == nil {
+			data = fr.B64
+		}
+		st := Step{Frame: append([]byte(nil), data...)}
+		if len(steps) == 0 {
+			first = fr.RecvNs
+		}
+		if speed > 0 {
+			st.At = time.Duration(float64(fr.RecvNs-first) / speed)
+		}
+		steps = append(steps, st)
+		return nil
+	})
+	return steps, err
+}
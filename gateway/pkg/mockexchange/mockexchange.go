@@ -0,0 +1,311 @@
+// Package mockexchange is a fake venue websocket server for integration
+// tests. It speaks the Bybit v5, Binance, OKX or Kraken Futures subscribe
+// protocol, acks
+// every subscribe and then plays a script to the connection: scripted or
+// recorded frames, pauses, malformed messages, sequence gaps and dropped
+// connections. Each connection gets the next script, so a test can make
+// the first session fail and check what the client does on the second.
+package mockexchange
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"nhooyr.io/websocket"
+)
+
+// Protocol is the subscribe handshake the server speaks.
+type Protocol int
+
+const (
+	// Bybit: {"op":"subscribe","req_id":..,"args":[..]}, acked with
+	// {"success":true,"op":"subscribe","req_id":..}.
+	Bybit Protocol = iota
+	// Binance: {"method":"SUBSCRIBE","params":[..],"id":..}, acked with
+	// {"result":null,"id":..}.
+	Binance
+	// OKX: {"op":"subscribe","id":..,"args":[{"channel":..,"instId":..}]},
+	// acked with {"event":"subscribe","arg":{..},"id":..}; topics are
+	// "channel:instId". OKX clients resubscribe on a live connection, so
+	// every subscribe, not every connection, starts the next script.
+	OKX
+	// Kraken: {"event":"subscribe","feed":..,"product_ids":[..]}, acked
+	// with {"event":"subscribed",...}; topics are "feed:product_id". Like
+	// OKX clients, Kraken ones resubscribe on a live connection: every book
+	// subscribe starts the next script.
+	Kraken
+)
+
+// Step is one scripted action.
+type Step struct {
+	// At is when to act, from the start of playback; a step whose time
+	// has passed runs right after the one before it.
+	At time.Duration
+	// Frame is sent as a text message.
+	Frame []byte
+	// Disconnect drops the connection without a close handshake.
+	Disconnect bool
+}
+
+// Send is a step sending frame.
+func Send(frame []byte) Step { return Step{Frame: frame} }
+
+// Malformed is a step sending a truncated JSON frame.
+func Malformed() Step { return Step{Frame: []byte(`{"topic":"orderbook.1.BTC`)} }
+
+// Disconnect is a step dropping the connection.
+func Disconnect() Step { return Step{Disconnect: true} }
+
+// Paced spaces frames out at rate messages a second; rate 0 sends them as
+// fast as the client reads.
+func Paced(frames [][]byte, rate int) []Step {
+	steps := make([]Step, len(frames))
+	for i, f := range frames {
+		steps[i] = Step{Frame: f}
+		if rate > 0 {
+			steps[i].At = time.Duration(i) * time.Second / time.Duration(rate)
+		}
+	}
+	return steps
+}
+
+// Server is a running mock venue.
+type Server struct {
+	protocol Protocol
+	scripts  [][]Step
+	srv      *httptest.Server
+
+	mu    sync.Mutex
+	conns int
+	plays int
+	subs  [][]string
+	sent  int
+}
+
+// Start serves scripts[i] to the i-th connection; connections past the
+// last script get the last one again. Playback starts at the first
+// subscribe; once the script ends the connection stays open and silent.
+func Start(p Protocol, scripts ...[]Step) *Server {
+	s := &Server{protocol: p, scripts: scripts}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// URL is the server's ws:// endpoint.
+func (s *Server) URL() string { return "ws" + strings.TrimPrefix(s.srv.URL, "http") }
+
+// Close drops every connection and stops the server.
+func (s *Server) Close() {
+	s.srv.CloseClientConnections()
+	s.srv.Close()
+}
+
+// Connections is how many clients have connected.
+func (s *Server) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
+
+// Subscriptions are the topics of every subscribe request, in order.
+func (s *Server) Subscriptions() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]string(nil), s.subs...)
+}
+
+// Sent is how many scripted frames were written.
+func (s *Server) Sent() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sent
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	c, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer c.CloseNow()
+	c.SetReadLimit(1 << 24)
+	s.mu.Lock()
+	n := s.conns
+	s.conns++
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	subscribed := make(chan struct{}, 1)
+	go func() {
+		defer cancel()
+		for {
+			_, data, err := c.Read(ctx)
+			if err != nil {
+				return
+			}
+			topics, ack, ok := s.subscribe(data)
+			if !ok {
+				continue
+			}
+			// an OKX or Kraken unsubscribe is acked and nothing else
+			if topics != nil {
+				s.mu.Lock()
+				s.subs = append(s.subs, topics)
+				s.mu.Unlock()
+			}
+			if c.Write(ctx, websocket.MessageText, ack) != nil {
+				return
+			}
+			if topics != nil && (s.protocol != Kraken || strings.HasPrefix(topics[0], "book:")) {
+				select {
+				case subscribed <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	select {
+	case <-subscribed:
+	case <-ctx.Done():
+		return
+	}
+	var resub <-chan struct{}
+	if s.protocol == OKX || s.protocol == Kraken {
+		resub = subscribed
+	}
+	for s.play(ctx, c, s.script(n), resub) {
+	}
+}
+
+// script is what the n-th connection plays, or for OKX and Kraken the
+// next subscribe's.
+func (s *Server) script(n int) []Step {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.protocol == OKX || s.protocol == Kraken {
+		n = s.plays
+		s.plays++
+	}
+	if len(s.scripts) == 0 {
+		return nil
+	}
+	return s.scripts[min(n, len(s.scripts)-1)]
+}
+
+// play sends script to c; it reports true when a subscribe on resub cut
+// it short or came after it, to play the next one.
+func (s *Server) play(ctx context.Context, c *websocket.Conn, script []Step, resub <-chan struct{}) bool {
+	start := time.Now()
+	for _, st := range script {
+		if d := time.Until(start.Add(st.At)); d > 0 {
+			select {
+			case <-time.After(d):
+			case <-resub:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		if st.Disconnect {
+			return false
+		}
+		if st.Frame == nil {
+			continue
+		}
+		if c.Write(ctx, websocket.MessageText, st.Frame) != nil {
+			return false
+		}
+		s.mu.Lock()
+		s.sent++
+		s.mu.Unlock()
+	}
+	select {
+	case <-resub:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// subscribe parses a subscribe request and builds its ack.
+func (s *Server) subscribe(data []byte) (topics []string, ack []byte, ok bool) {
+	switch s.protocol {
+	case Binance:
+		var req struct {
+			Method string          `json:"method"`
+			Params []string        `json:"params"`
+			ID     json.RawMessage `json:"id"`
+		}
+		if json.Unmarshal(data, &req) != nil || req.Method != "SUBSCRIBE" {
+			return nil, nil, false
+		}
+		if len(req.ID) == 0 {
+			req.ID = json.RawMessage("null")
+		}
+		return req.Params, []byte(fmt.Sprintf(`{"result":null,"id":%s}`, req.ID)), true
+	case OKX:
+		var req struct {
+			Op   string            `json:"op"`
+			ID   string            `json:"id"`
+			Args []json.RawMessage `json:"args"`
+		}
+		if json.Unmarshal(data, &req) != nil || (req.Op != "subscribe" && req.Op != "unsubscribe") {
+			return nil, nil, false
+		}
+		arg := json.RawMessage("{}")
+		topics = []string{}
+		for i, a := range req.Args {
+			var t struct {
+				Channel string `json:"channel"`
+				InstID  string `json:"instId"`
+			}
+			if json.Unmarshal(a, &t) == nil {
+				topics = append(topics, t.Channel+":"+t.InstID)
+			}
+			if i == 0 {
+				arg = a
+			}
+		}
+		ack = []byte(fmt.Sprintf(`{"event":%q,"arg":%s,"id":%q,"connId":"mock"}`, req.Op, arg, req.ID))
+		if req.Op == "unsubscribe" {
+			topics = nil
+		}
+		return topics, ack, true
+	case Kraken:
+		var req struct {
+			Event      string   `json:"event"`
+			Feed       string   `json:"feed"`
+			ProductIDs []string `json:"product_ids"`
+		}
+		if json.Unmarshal(data, &req) != nil || (req.Event != "subscribe" && req.Event != "unsubscribe") {
+			return nil, nil, false
+		}
+		ids, _ := json.Marshal(req.ProductIDs)
+		ack = []byte(fmt.Sprintf(`{"event":"%sd","feed":%q,"product_ids":%s}`, req.Event, req.Feed, ids))
+		if req.Event == "unsubscribe" {
+			return nil, ack, true
+		}
+		topics = []string{}
+		for _, id := range req.ProductIDs {
+			topics = append(topics, req.Feed+":"+id)
+		}
+		return topics, ack, true
+	default:
+		var req struct {
+			Op    string   `json:"op"`
+			ReqID string   `json:"req_id"`
+			Args  []string `json:"args"`
+		}
+		if json.Unmarshal(data, &req) != nil || req.Op != "subscribe" {
+			return nil, nil, false
+		}
+		return req.Args, []byte(fmt.Sprintf(`{"success":true,"ret_msg":"","op":"subscribe","req_id":%q}`, req.ReqID)), true
+	}
+}
@@ -1,5 +1,12 @@
 package orderbook
 
+import (
+	"sort"
+	"sync"
+
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
 // MergeBest consolidates multiple venue levels into a synthetic NBBO-like level.
 func MergeBest(levels map[string]Level) Level {
 	best := Level{}
@@ -15,3 +22,75 @@ func MergeBest(levels map[string]Level) Level {
 	}
 	return best
 }
+
+// MergeNBBO is MergeBest with attribution: the sizes of every venue at the
+// best price add up, and the venues quoting it are listed.
+func MergeNBBO(symbol, category string, levels map[string]Level) transport.NBBO {
+	best := MergeBest(levels)
+	n := transport.NBBO{Symbol: symbol, Category: category, BestBid: best.BestBid, BestAsk: best.BestAsk}
+	for venue, lvl := range levels {
+		if lvl.BestBid > 0 && lvl.BestBid == best.BestBid {
+			n.BidSize += lvl.BidSize
+			n.BidVenues = append(n.BidVenues, venue)
+		}
+		if lvl.BestAsk > 0 && lvl.BestAsk == best.BestAsk {
+			n.AskSize += lvl.AskSize
+			n.AskVenues = append(n.AskVenues, venue)
+		}
+	}
+	sort.Strings(n.BidVenues)
+	sort.Strings(n.AskVenues)
+	return n
+}
+
+// NBBOTracker keeps the NBBO of every market the books hold and reports
+// when an update changes it. It is safe for concurrent use.
+type NBBOTracker struct {
+	books *Manager
+	mu    sync.Mutex
+	last  map[Market]transport.NBBO
+}
+
+func NewNBBOTracker(books *Manager) *NBBOTracker {
+	return &NBBOTracker{books: books, last: map[Market]transport.NBBO{}}
+}
+
+// Update recomputes the NBBO of u's market once u is applied to the books;
+// ok is false when it did not change.
+func (t *NBBOTracker) Update(u transport.DepthUpdate) (n transport.NBBO, ok bool) {
+	n = MergeNBBO(u.Symbol, u.Category, t.books.MarketSnapshot(u.Symbol, u.Category))
+	n.ExchTsMs, n.RecvNs = u.ExchTsMs, u.RecvNs
+	mk := Market{Symbol: u.Symbol, Category: u.Category}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if prev, seen := t.last[mk]; seen && sameNBBO(prev, n) {
+		return n, false
+	}
+	t.last[mk] = n
+	return n, true
+}
+
+// Last is the last NBBO reported for symbol in category.
+func (t *NBBOTracker) Last(symbol, category string) (transport.NBBO, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n, ok := t.last[Market{Symbol: symbol, Category: category}]
+	return n, ok
+}
+
+func sameNBBO(a, b transport.NBBO) bool {
+	return a.BestBid == b.BestBid && a.BestAsk == b.BestAsk && a.BidSize == b.BidSize && a.AskSize == b.AskSize &&
+		equalStrings(a.BidVenues, b.BidVenues) && equalStrings(a.AskVenues, b.AskVenues)
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
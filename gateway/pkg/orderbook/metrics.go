@@ -0,0 +1,27 @@
+package orderbook
+
+import "github.com/helix-lab/helix/gateway/pkg/metrics"
+
+// RegisterMetrics exposes top of book per venue and the NBBO per market.
+func (m *Manager) RegisterMetrics(reg *metrics.Registry) {
+	reg.Collect("helix_book_best_bid", "Best bid per venue and market.", "gauge", func(emit metrics.Emit) {
+		for _, b := range m.Books() {
+			emit("", metrics.L("venue", b.Venue, "symbol", b.Symbol, "category", b.Category), b.BestBid)
+		}
+	})
+	reg.Collect("helix_book_best_ask", "Best ask per venue and market.", "gauge", func(emit metrics.Emit) {
+		for _, b := range m.Books() {
+			emit("", metrics.L("venue", b.Venue, "symbol", b.Symbol, "category", b.Category), b.BestAsk)
+		}
+	})
+	reg.Collect("helix_book_nbbo_spread_bps", "NBBO spread per market in basis points of the mid; negative when venues cross.", "gauge", func(emit metrics.Emit) {
+		for _, sym := range m.Symbols() {
+			for _, cat := range m.Categories(sym) {
+				n := MergeBest(m.MarketSnapshot(sym, cat))
+				if mid := (n.BestBid + n.BestAsk) / 2; mid > 0 {
+					emit("", metrics.L("symbol", sym, "category", cat), (n.BestAsk-n.BestBid)/mid*1e4)
+				}
+			}
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package orderbook
 
 import (
+	"sort"
 	"sync"
 
 	"github.com/helix-lab/helix/gateway/pkg/transport"
@@ -13,24 +14,169 @@ type Level struct {
 	AskSize float64
 }
 
+// Market is a symbol on one kind of market (spot, linear, ...); the same
+// symbol string on two markets is two sets of books.
+type Market struct {
+	Symbol   string
+	Category string
+}
+
 type Manager struct {
 	mu    sync.RWMutex
 	books map[string]Level
+	// byMarket keeps every (market, venue) book; books is the latest
+	// update per venue regardless of symbol. markets lists each symbol's
+	// markets.
+	byMarket map[Market]map[string]Level
+	markets  map[string][]Market
+	// fees are the taker rates RankVenues prices with.
+	fees map[string]float64
 }
 
 func NewManager() *Manager {
-	return &Manager{books: make(map[string]Level)}
+	return &Manager{books: make(map[string]Level), byMarket: make(map[Market]map[string]Level), markets: make(map[string][]Market)}
 }
 
 func (m *Manager) Apply(update transport.DepthUpdate) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.books[update.Venue] = Level{
+	lvl := Level{
 		BestBid: update.BestBid,
 		BestAsk: update.BestAsk,
 		BidSize: update.BidSize,
 		AskSize: update.AskSize,
 	}
+	m.books[update.Venue] = lvl
+	mk := Market{Symbol: update.Symbol, Category: update.Category}
+	venues, ok := m.byMarket[mk]
+	if !ok {
+		venues = make(map[string]Level)
+		m.byMarket[mk] = venues
+		m.markets[mk.Symbol] = append(m.markets[mk.Symbol], mk)
+	}
+	venues[update.Venue] = lvl
+}
+
+// SymbolSnapshot returns the per-venue books for one symbol in every
+// category. Venues are named per category (BYBIT, BYBIT_SPOT), so
+// categories only meet here when asked for together.
+func (m *Manager) SymbolSnapshot(symbol string) map[string]Level {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	cp := make(map[string]Level)
+	for _, mk := range m.markets[symbol] {
+		for k, v := range m.byMarket[mk] {
+			cp[k] = v
+		}
+	}
+	return cp
+}
+
+// MarketSnapshot returns the per-venue books for symbol in category only.
+func (m *Manager) MarketSnapshot(symbol, category string) map[string]Level {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	venues := m.byMarket[Market{Symbol: symbol, Category: category}]
+	cp := make(map[string]Level, len(venues))
+	for k, v := range venues {
+		cp[k] = v
+	}
+	return cp
+}
+
+// Venue is venue's book for symbol in category. An empty category takes
+// the one market venue quotes symbol in; ok is false if it quotes two.
+func (m *Manager) Venue(venue, symbol, category string) (b Book, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, mk := range m.markets[symbol] {
+		if category != "" && mk.Category != category {
+			continue
+		}
+		lvl, quoted := m.byMarket[mk][venue]
+		if !quoted {
+			continue
+		}
+		if ok {
+			return Book{}, false
+		}
+		b, ok = Book{Venue: venue, Symbol: symbol, Category: mk.Category, Level: lvl}, true
+	}
+	return b, ok
+}
+
+// Categories lists the categories symbol has books in.
+func (m *Manager) Categories(symbol string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]string, 0, len(m.markets[symbol]))
+	for _, mk := range m.markets[symbol] {
+		out = append(out, mk.Category)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Cold returns the symbols in symbols that have no two-sided book on venue.
+func (m *Manager) Cold(venue string, symbols []string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []string
+	for _, sym := range symbols {
+		warm := false
+		for _, mk := range m.markets[sym] {
+			if l, ok := m.byMarket[mk][venue]; ok && l.BestBid > 0 && l.BestAsk > 0 {
+				warm = true
+			}
+		}
+		if !warm {
+			out = append(out, sym)
+		}
+	}
+	return out
+}
+
+// Symbols lists every symbol seen so far.
+func (m *Manager) Symbols() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]string, 0, len(m.markets))
+	for sym := range m.markets {
+		out = append(out, sym)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Book is one venue's top of book for a symbol.
+type Book struct {
+	Venue    string
+	Symbol   string
+	Category string
+	Level
+}
+
+// Books lists every (market, venue) book, sorted by symbol, venue then
+// category.
+func (m *Manager) Books() []Book {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []Book
+	for mk, venues := range m.byMarket {
+		for venue, lvl := range venues {
+			out = append(out, Book{Venue: venue, Symbol: mk.Symbol, Category: mk.Category, Level: lvl})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Symbol != out[j].Symbol {
+			return out[i].Symbol < out[j].Symbol
+		}
+		if out[i].Venue != out[j].Venue {
+			return out[i].Venue < out[j].Venue
+		}
+		return out[i].Category < out[j].Category
+	})
+	return out
 }
 
 func (m *Manager) Snapshot() map[string]Level {
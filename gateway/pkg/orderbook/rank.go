@@ -0,0 +1,77 @@
+package orderbook
+
+import (
+	"sort"
+
+	"github.com/helix-lab/helix/gateway/pkg/decimal"
+)
+
+// VenueRank is one venue's standing for taking size on one side of a
+// symbol.
+type VenueRank struct {
+	Venue    string
+	Category string
+	// Price is the touch taken: the ask for a buy, the bid for a sell.
+	Price float64
+	// Effective is Price after the venue's taker fee.
+	Effective float64
+	// Size is what the touch displays; Sufficient when it covers the size
+	// asked for.
+	Size       float64
+	Sufficient bool
+}
+
+// SetTakerFees sets the fee rate by venue that RankVenues prices with.
+func (m *Manager) SetTakerFees(fees map[string]float64) {
+	cp := make(map[string]float64, len(fees))
+	for k, v := range fees {
+		cp[k] = v
+	}
+	m.mu.Lock()
+	m.fees = cp
+	m.mu.Unlock()
+}
+
+// RankVenues orders the venues quoting symbol, in every category, by
+// fee-adjusted price for taking size on side (BUY or SELL), best first.
+// Ties go to the venue whose touch covers size, then the deeper touch, then
+// the venue name. Venues without a price on that side are left out.
+func (m *Manager) RankVenues(symbol, side string, size float64) []VenueRank {
+	m.mu.RLock()
+	var out []VenueRank
+	for _, mk := range m.markets[symbol] {
+		for venue, lvl := range m.byMarket[mk] {
+			r := VenueRank{Venue: venue, Category: mk.Category}
+			fee := m.fees[venue]
+			switch side {
+			case "BUY":
+				r.Price, r.Size, r.Effective = lvl.BestAsk, lvl.AskSize, lvl.BestAsk*(1+fee)
+			case "SELL":
+				r.Price, r.Size, r.Effective = lvl.BestBid, lvl.BidSize, lvl.BestBid*(1-fee)
+			}
+			if r.Price <= 0 {
+				continue
+			}
+			r.Sufficient = decimal.Cmp(r.Size, size) >= 0
+			out = append(out, r)
+		}
+	}
+	m.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if c := decimal.Cmp(a.Effective, b.Effective); c != 0 {
+			return (c < 0) == (side == "BUY")
+		}
+		if a.Sufficient != b.Sufficient {
+			return a.Sufficient
+		}
+		if a.Size != b.Size {
+			return a.Size > b.Size
+		}
+		if a.Venue != b.Venue {
+			return a.Venue < b.Venue
+		}
+		return a.Category < b.Category
+	})
+	return out
+}
@@ -0,0 +1,510 @@
+// Package orderstore keeps the executor's orders, fills, positions and
+// routing decisions in a relational database (SQLite or Postgres) for
+// end-of-day reconciliation and reporting.
+//
+// It goes through database/sql and imports no driver itself; the gateway
+// links modernc.org/sqlite ("sqlite") and pgx ("pgx", for Postgres).
+// Writes are queued and applied in batches by Run, so a Store is safe to
+// use as an executor.Journal on the trading path: a full queue holds the
+// caller for at most Config.Wait, then drops the record and counts it.
+package orderstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/metrics"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+var (
+	rowsWritten = metrics.Default.CounterVec("helix_sink_rows_total", "Rows written by external sinks, per sink and table.", "sink", "table")
+	rowsDropped = metrics.Default.CounterVec("helix_sink_dropped_total", "Rows external sinks dropped (full buffer or failed write), per sink and table.", "sink", "table")
+	sinkErrors  = metrics.Default.CounterVec("helix_sink_errors_total", "Failed writes to external sinks.", "sink")
+)
+
+// Config says which database to use and how to write to it.
+type Config struct {
+	// Driver is the database/sql driver name; "postgres" and "pgx" use
+	// Postgres placeholders, anything else SQLite's.
+	Driver string
+	DSN    string
+	// Prefix is prepended to the table names (default "helix_").
+	Prefix string
+	// Writes are committed once BatchSize are queued or FlushEvery passed.
+	BatchSize  int
+	FlushEvery time.Duration
+	// Buffer bounds the queued writes. A write that finds it full waits up
+	// to Wait (default 2ms) for Run to make room, then is dropped, counted
+	// and logged.
+	Buffer int
+	Wait   time.Duration
+	// Testnet tags every row written as testnet and limits reads to
+	// testnet rows; otherwise reads see live rows only.
+	Testnet bool
+	// Logf, if set, reports failed writes.
+	Logf func(format string, args ...any)
+}
+
+func (c *Config) defaults() {
+	if c.Prefix == "" {
+		c.Prefix = "helix_"
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 500
+	}
+	if c.FlushEvery <= 0 {
+		c.FlushEvery = time.Second
+	}
+	if c.Buffer <= 0 {
+		c.Buffer = 65536
+	}
+	if c.Wait <= 0 {
+		c.Wait = 2 * time.Millisecond
+	}
+}
+
+// Fill is a stored execution.
+type Fill struct {
+	Time    time.Time
+	OrderID string
+	Venue   string
+	Symbol  string
+	Side    string
+	Price   float64
+	Qty     float64
+}
+
+// Route is a stored routing decision; Refused is why the action was not
+// sent, if it was not.
+type Route struct {
+	transport.RouteDecision
+	Refused string
+}
+
+// Total sums the fills of one venue, symbol and side.
+type Total struct {
+	Venue    string
+	Symbol   string
+	Side     string
+	Fills    int
+	Qty      float64
+	Notional float64
+}
+
+// AvgPrice is the volume-weighted fill price.
+func (t Total) AvgPrice() float64 {
+	if t.Qty == 0 {
+		return 0
+	}
+	return t.Notional / t.Qty
+}
+
+// Query narrows a read; zero fields match everything. From is inclusive
+// and To exclusive.
+type Query struct {
+	Venue  string
+	Symbol string
+	From   time.Time
+	To     time.Time
+}
+
+// Store is an order and fill store. Order, Fill and Route queue writes,
+// waiting only while the queue is full; the read methods go to the
+// database directly.
+type Store struct {
+	db       *sql.DB
+	cfg      Config
+	postgres bool
+	writes   chan write
+	dropped  atomic.Uint64
+
+	mu     sync.RWMutex
+	closed bool
+	// waiting counts writes waiting for room; Close lets them finish
+	// before it closes writes
+	waiting sync.WaitGroup
+}
+
+type write struct {
+	table string
+	query string
+	args  []any
+}
+
+// Open connects with cfg.Driver; the database is not touched until
+// Migrate.
+func Open(cfg Config) (*Store, error) {
+	db, err := sql.Open(cfg.Driver, cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	return New(db, cfg), nil
+}
+
+// New wraps an open database; cfg.Driver only picks the SQL dialect.
+func New(db *sql.DB, cfg Config) *Store {
+	cfg.defaults()
+	return &Store{
+		db:       db,
+		cfg:      cfg,
+		postgres: cfg.Driver == "postgres" || cfg.Driver == "pgx",
+		writes:   make(chan write, cfg.Buffer),
+	}
+}
+
+func (s *Store) table(name string) string { return s.cfg.Prefix + name }
+
+// testnet is the value of the testnet column for this store's rows.
+func (s *Store) testnet() int {
+	if s.cfg.Testnet {
+		return 1
+	}
+	return 0
+}
+
+// Migrate creates the tables and indexes that don't exist yet, and adds
+// the columns older tables lack. A positions table from before testnet
+// keeps (venue, symbol) as its key, so live and testnet gateways sharing
+// it collide on the same symbol; give testnet its own database.
+func (s *Store) Migrate(ctx context.Context) error {
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS ` + s.table("orders") + ` (id TEXT PRIMARY KEY, venue TEXT NOT NULL, symbol TEXT NOT NULL, side TEXT NOT NULL, ` +
+			`size DOUBLE PRECISION NOT NULL, filled DOUBLE PRECISION NOT NULL, avg_price DOUBLE PRECISION NOT NULL, status TEXT NOT NULL, ` +
+			`created_ns BIGINT NOT NULL, updated_ns BIGINT NOT NULL, testnet INTEGER NOT NULL DEFAULT 0, ` +
+			`remaining DOUBLE PRECISION NOT NULL DEFAULT 0, fills INTEGER NOT NULL DEFAULT 0)`,
+		`CREATE INDEX IF NOT EXISTS ` + s.table("orders_created") + ` ON ` + s.table("orders") + ` (created_ns)`,
+		`CREATE TABLE IF NOT EXISTS ` + s.table("fills") + ` (ts_ns BIGINT NOT NULL, order_id TEXT NOT NULL, venue TEXT NOT NULL, symbol TEXT NOT NULL, ` +
+			`side TEXT NOT NULL, price DOUBLE PRECISION NOT NULL, qty DOUBLE PRECISION NOT NULL, testnet INTEGER NOT NULL DEFAULT 0)`,
+		`CREATE INDEX IF NOT EXISTS ` + s.table("fills_ts") + ` ON ` + s.table("fills") + ` (ts_ns)`,
+		`CREATE TABLE IF NOT EXISTS ` + s.table("positions") + ` (venue TEXT NOT NULL, symbol TEXT NOT NULL, qty DOUBLE PRECISION NOT NULL, ` +
+			`avg_price DOUBLE PRECISION NOT NULL, realized DOUBLE PRECISION NOT NULL, updated_ns BIGINT NOT NULL, testnet INTEGER NOT NULL DEFAULT 0, ` +
+			`PRIMARY KEY (venue, symbol, testnet))`,
+		`CREATE TABLE IF NOT EXISTS ` + s.table("routes") + ` (ts_ns BIGINT NOT NULL, order_id TEXT NOT NULL, symbol TEXT NOT NULL, side TEXT NOT NULL, ` +
+			`size DOUBLE PRECISION NOT NULL, venue TEXT NOT NULL, price DOUBLE PRECISION NOT NULL, prices TEXT NOT NULL, dry_run INTEGER NOT NULL, refused TEXT NOT NULL, ` +
+			`testnet INTEGER NOT NULL DEFAULT 0, account TEXT NOT NULL DEFAULT '')`,
+		`CREATE INDEX IF NOT EXISTS ` + s.table("routes_ts") + ` ON ` + s.table("routes") + ` (ts_ns)`,
+	} {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("orderstore: migrate: %w", err)
+		}
+	}
+	for _, c := range []struct{ table, column, def string }{
+		{"orders", "testnet", "INTEGER NOT NULL DEFAULT 0"},
+		{"fills", "testnet", "INTEGER NOT NULL DEFAULT 0"},
+		{"positions", "testnet", "INTEGER NOT NULL DEFAULT 0"},
+		{"routes", "testnet", "INTEGER NOT NULL DEFAULT 0"},
+		{"orders", "remaining", "DOUBLE PRECISION NOT NULL DEFAULT 0"},
+		{"orders", "fills", "INTEGER NOT NULL DEFAULT 0"},
+		{"routes", "account", "TEXT NOT NULL DEFAULT ''"},
+	} {
+		rows, err := s.db.QueryContext(ctx, `SELECT `+c.column+` FROM `+s.table(c.table)+` WHERE 1 = 0`)
+		if err == nil {
+			rows.Close()
+			continue
+		}
+		if _, err := s.db.ExecContext(ctx, `ALTER TABLE `+s.table(c.table)+` ADD COLUMN `+c.column+` `+c.def); err != nil {
+			return fmt.Errorf("orderstore: migrate: %s %s column: %w", c.table, c.column, err)
+		}
+	}
+	_, err := s.db.ExecContext(ctx, `CREATE UNIQUE INDEX IF NOT EXISTS `+s.table("positions_key")+` ON `+s.table("positions")+` (venue, symbol, testnet)`)
+	if err != nil {
+		return fmt.Errorf("orderstore: migrate: %w", err)
+	}
+	return nil
+}
+
+// Order queues an upsert of o.
+func (s *Store) Order(o executor.Order) {
+	s.enqueue("orders", `INSERT INTO `+s.table("orders")+` (id, venue, symbol, side, size, filled, avg_price, status, created_ns, updated_ns, remaining, fills, testnet) `+
+		`VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (id) DO UPDATE SET filled = excluded.filled, avg_price = excluded.avg_price, `+
+		`status = excluded.status, updated_ns = excluded.updated_ns, remaining = excluded.remaining, fills = excluded.fills`,
+		o.ID, o.Venue, o.Symbol, o.Side, o.Size, o.Filled, o.AvgPrice, o.Status, o.CreatedAt.UnixNano(), o.UpdatedAt.UnixNano(), o.Remaining(), o.Fills, s.testnet())
+}
+
+// Fill queues the execution and an upsert of the position it left.
+func (s *Store) Fill(f transport.Fill, pos executor.Position, at time.Time) {
+	s.enqueue("fills", `INSERT INTO `+s.table("fills")+` (ts_ns, order_id, venue, symbol, side, price, qty, testnet) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		at.UnixNano(), f.OrderID, f.Venue, f.Symbol, f.Side, f.Price, f.Qty, s.testnet())
+	s.enqueue("positions", `INSERT INTO `+s.table("positions")+` (venue, symbol, qty, avg_price, realized, updated_ns, testnet) VALUES (?, ?, ?, ?, ?, ?, ?) `+
+		`ON CONFLICT (venue, symbol, testnet) DO UPDATE SET qty = excluded.qty, avg_price = excluded.avg_price, realized = excluded.realized, updated_ns = excluded.updated_ns`,
+		pos.Venue, pos.Symbol, pos.Qty, pos.AvgPrice, pos.Realized, at.UnixNano(), s.testnet())
+}
+
+// Route queues the decision with the reason it was refused, if any.
+func (s *Store) Route(d transport.RouteDecision, err error) {
+	refused := ""
+	if err != nil {
+		refused = err.Error()
+	}
+	prices, _ := json.Marshal(d.Prices)
+	dry := 0
+	if d.DryRun {
+		dry = 1
+	}
+	s.enqueue("routes", `INSERT INTO `+s.table("routes")+` (ts_ns, order_id, symbol, side, size, venue, price, prices, dry_run, refused, account, testnet) `+
+		`VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		d.TsNs, d.OrderID, d.Symbol, d.Side, d.Size, d.Venue, d.Price, string(prices), dry, refused, d.Account, s.testnet())
+}
+
+func (s *Store) enqueue(table, query string, args ...any) {
+	w := write{table: table, query: s.rebind(query), args: args}
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		rowsDropped.With("orderstore", table).Inc()
+		return
+	}
+	select {
+	case s.writes <- w:
+		s.mu.RUnlock()
+		return
+	default:
+	}
+	// wait without the lock so Close is not held up behind the database
+	s.waiting.Add(1)
+	s.mu.RUnlock()
+	defer s.waiting.Done()
+	wait := time.NewTimer(s.cfg.Wait)
+	defer wait.Stop()
+	select {
+	case s.writes <- w:
+	case <-wait.C:
+		rowsDropped.With("orderstore", table).Inc()
+		if s.dropped.Add(1) == 1 && s.cfg.Logf != nil {
+			s.cfg.Logf("orderstore: write queue full for %v, dropping %s records", s.cfg.Wait, table)
+		}
+	}
+}
+
+// Dropped is how many records found the queue full past Config.Wait.
+func (s *Store) Dropped() uint64 { return s.dropped.Load() }
+
+// Run applies queued writes in transactions until Close, then applies
+// what is left.
+func (s *Store) Run() {
+	var batch []write
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.apply(batch); err != nil {
+			sinkErrors.With("orderstore").Inc()
+			if s.cfg.Logf != nil {
+				s.cfg.Logf("orderstore: write %d records: %v", len(batch), err)
+			}
+			for _, w := range batch {
+				rowsDropped.With("orderstore", w.table).Inc()
+			}
+		} else {
+			for _, w := range batch {
+				rowsWritten.With("orderstore", w.table).Inc()
+			}
+		}
+		batch = batch[:0]
+	}
+	tick := time.NewTicker(s.cfg.FlushEvery)
+	defer tick.Stop()
+	for {
+		select {
+		case w, ok := <-s.writes:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, w)
+			if len(batch) >= s.cfg.BatchSize {
+				flush()
+			}
+		case <-tick.C:
+			flush()
+		}
+	}
+}
+
+func (s *Store) apply(batch []write) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, w := range batch {
+		if _, err := tx.ExecContext(ctx, w.query, w.args...); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("%s: %w", w.table, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Close stops accepting writes; Run returns once the rest is applied.
+// The database stays open for reads until CloseDB.
+func (s *Store) Close() {
+	s.mu.Lock()
+	closing := !s.closed
+	s.closed = true
+	s.mu.Unlock()
+	if closing {
+		s.waiting.Wait()
+		close(s.writes)
+	}
+}
+
+func (s *Store) CloseDB() error { return s.db.Close() }
+
+// Orders returns the orders created in q's window, oldest first.
+func (s *Store) Orders(ctx context.Context, q Query) ([]executor.Order, error) {
+	where, args := q.where("created_ns", s.testnet())
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT id, venue, symbol, side, size, filled, avg_price, status, created_ns, updated_ns, fills FROM `+
+		s.table("orders")+where+` ORDER BY created_ns, id`), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []executor.Order
+	for rows.Next() {
+		var o executor.Order
+		var created, updated int64
+		if err := rows.Scan(&o.ID, &o.Venue, &o.Symbol, &o.Side, &o.Size, &o.Filled, &o.AvgPrice, &o.Status, &created, &updated, &o.Fills); err != nil {
+			return nil, err
+		}
+		o.CreatedAt, o.UpdatedAt = time.Unix(0, created), time.Unix(0, updated)
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
+
+// Fills returns the executions in q's window, oldest first.
+func (s *Store) Fills(ctx context.Context, q Query) ([]Fill, error) {
+	where, args := q.where("ts_ns", s.testnet())
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT ts_ns, order_id, venue, symbol, side, price, qty FROM `+
+		s.table("fills")+where+` ORDER BY ts_ns`), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Fill
+	for rows.Next() {
+		var f Fill
+		var ts int64
+		if err := rows.Scan(&ts, &f.OrderID, &f.Venue, &f.Symbol, &f.Side, &f.Price, &f.Qty); err != nil {
+			return nil, err
+		}
+		f.Time = time.Unix(0, ts)
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
+
+// Totals sums the fills in q's window per venue, symbol and side.
+func (s *Store) Totals(ctx context.Context, q Query) ([]Total, error) {
+	where, args := q.where("ts_ns", s.testnet())
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT venue, symbol, side, COUNT(*), SUM(qty), SUM(price * qty) FROM `+
+		s.table("fills")+where+` GROUP BY venue, symbol, side ORDER BY symbol, venue, side`), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Total
+	for rows.Next() {
+		var t Total
+		if err := rows.Scan(&t.Venue, &t.Symbol, &t.Side, &t.Fills, &t.Qty, &t.Notional); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// Positions returns the last stored position per venue and symbol.
+func (s *Store) Positions(ctx context.Context) ([]executor.Position, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT venue, symbol, qty, avg_price, realized FROM `+s.table("positions")+
+		` WHERE testnet = ? ORDER BY symbol, venue`), s.testnet())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []executor.Position
+	for rows.Next() {
+		var p executor.Position
+		if err := rows.Scan(&p.Venue, &p.Symbol, &p.Qty, &p.AvgPrice, &p.Realized); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// Routes returns the routing decisions in q's window, oldest first.
+func (s *Store) Routes(ctx context.Context, q Query) ([]Route, error) {
+	where, args := q.where("ts_ns", s.testnet())
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT ts_ns, order_id, symbol, side, size, venue, price, prices, dry_run, refused, account FROM `+
+		s.table("routes")+where+` ORDER BY ts_ns`), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Route
+	for rows.Next() {
+		var r Route
+		var prices string
+		var dry int
+		if err := rows.Scan(&r.TsNs, &r.OrderID, &r.Symbol, &r.Side, &r.Size, &r.Venue, &r.Price, &prices, &dry, &r.Refused, &r.Account); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(prices), &r.Prices); err != nil {
+			return nil, fmt.Errorf("route prices: %w", err)
+		}
+		r.DryRun = dry != 0
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// where builds the WHERE clause for q with ts as the time column, for
+// rows with the given testnet tag.
+func (q Query) where(ts string, testnet int) (string, []any) {
+	var conds []string
+	var args []any
+	if q.Venue != "" {
+		conds, args = append(conds, "venue = ?"), append(args, q.Venue)
+	}
+	if q.Symbol != "" {
+		conds, args = append(conds, "symbol = ?"), append(args, q.Symbol)
+	}
+	if !q.From.IsZero() {
+		conds, args = append(conds, ts+" >= ?"), append(args, q.From.UnixNano())
+	}
+	if !q.To.IsZero() {
+		conds, args = append(conds, ts+" < ?"), append(args, q.To.UnixNano())
+	}
+	conds, args = append(conds, "testnet = ?"), append(args, testnet)
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// rebind turns ? placeholders into $n for Postgres.
+func (s *Store) rebind(query string) string {
+	if !s.postgres || !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,589 @@
+// Package parquet writes and reads the Parquet files the capture tools
+// produce: flat schemas of required INT64, DOUBLE and UTF-8 string columns,
+// PLAIN-encoded in one data page per column and row group, uncompressed or
+// gzip. It is not a general Parquet reader; files from other writers may
+// use encodings it does not know.
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Type is a column's physical type.
+type Type int32
+
+const (
+	Int64  Type = 2
+	Double Type = 5
+	// String is BYTE_ARRAY annotated UTF8.
+	String Type = 6
+)
+
+func (t Type) String() string {
+	switch t {
+	case Int64:
+		return "int64"
+	case Double:
+		return "double"
+	case String:
+		return "string"
+	}
+	return "type(" + strconv.Itoa(int(t)) + ")"
+}
+
+// Codec is a page compression codec.
+type Codec int32
+
+const (
+	Uncompressed Codec = 0
+	Gzip         Codec = 2
+)
+
+// ParseCodec accepts "none" and "gzip".
+func ParseCodec(s string) (Codec, error) {
+	switch strings.ToLower(s) {
+	case "none", "uncompressed", "":
+		return Uncompressed, nil
+	case "gzip":
+		return Gzip, nil
+	}
+	return 0, fmt.Errorf("unsupported codec %q (none or gzip)", s)
+}
+
+type Column struct {
+	Name string
+	Type Type
+}
+
+var magic = []byte("PAR1")
+
+const (
+	encPlain       = 0
+	encRLE         = 3
+	pageData       = 0
+	repRequired    = 0
+	convertedUTF8  = 0
+	formatVersion  = 1
+	defaultRGRows  = 128 << 10
+	createdBy      = "helix parquet"
+	fieldStatsMin  = 6
+	fieldStatsMax  = 5
+	maxFooterBytes = 64 << 20
+)
+
+// Writer buffers rows into row groups and writes the footer on Close.
+type Writer struct {
+	// RowGroupRows is how many rows a row group holds (default 131072).
+	RowGroupRows int
+
+	w      io.Writer
+	off    int64
+	cols   []Column
+	codec  Codec
+	meta   map[string]string
+	buf    []column
+	n      int
+	rows   int64
+	groups []rowGroup
+}
+
+type column struct {
+	data     []byte
+	min, max float64
+	imin     int64
+	imax     int64
+}
+
+type rowGroup struct {
+	rows   int64
+	size   int64
+	chunks []chunk
+}
+
+type chunk struct {
+	offset             int64
+	compressed, uncomp int64
+	stats              [2][]byte
+}
+
+// NewWriter writes the file header to w.
+func NewWriter(w io.Writer, cols []Column, codec Codec) (*Writer, error) {
+	if len(cols) == 0 {
+		return nil, errors.New("parquet: no columns")
+	}
+	pw := &Writer{RowGroupRows: defaultRGRows, w: w, cols: cols, codec: codec, meta: map[string]string{}}
+	pw.reset()
+	return pw, pw.write(magic)
+}
+
+// SetMetadata adds a key-value pair to the footer.
+func (w *Writer) SetMetadata(key, value string) { w.meta[key] = value }
+
+func (w *Writer) reset() {
+	w.buf = make([]column, len(w.cols))
+	w.n = 0
+}
+
+func (w *Writer) write(b []byte) error {
+	n, err := w.w.Write(b)
+	w.off += int64(n)
+	return err
+}
+
+// Append adds a row of text values, parsed by column type.
+func (w *Writer) Append(row []string) error {
+	if len(row) != len(w.cols) {
+		return fmt.Errorf("parquet: row has %d values, schema %d", len(row), len(w.cols))
+	}
+	for i, c := range w.cols {
+		b := &w.buf[i]
+		v := strings.TrimSpace(row[i])
+		switch c.Type {
+		case Int64:
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return fmt.Errorf("parquet: %s: %w", c.Name, err)
+			}
+			if w.n == 0 || n < b.imin {
+				b.imin = n
+			}
+			if w.n == 0 || n > b.imax {
+				b.imax = n
+			}
+			b.data = binary.LittleEndian.AppendUint64(b.data, uint64(n))
+		case Double:
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("parquet: %s: %w", c.Name, err)
+			}
+			if w.n == 0 || f < b.min {
+				b.min = f
+			}
+			if w.n == 0 || f > b.max {
+				b.max = f
+			}
+			b.data = binary.LittleEndian.AppendUint64(b.data, math.Float64bits(f))
+		default:
+			b.data = binary.LittleEndian.AppendUint32(b.data, uint32(len(row[i])))
+			b.data = append(b.data, row[i]...)
+		}
+	}
+	w.n++
+	if w.n >= w.RowGroupRows {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *Writer) flush() error {
+	if w.n == 0 {
+		return nil
+	}
+	rg := rowGroup{rows: int64(w.n)}
+	for i, c := range w.cols {
+		b := w.buf[i]
+		page := b.data
+		if w.codec == Gzip {
+			var z bytes.Buffer
+			zw := gzip.NewWriter(&z)
+			zw.Write(b.data)
+			if err := zw.Close(); err != nil {
+				return err
+			}
+			page = z.Bytes()
+		}
+		var h encoder
+		h.i32(1, pageData)
+		h.i32(2, int32(len(b.data)))
+		h.i32(3, int32(len(page)))
+		h.begin(5)
+		h.i32(1, int32(w.n))
+		h.i32(2, encPlain)
+		h.i32(3, encRLE)
+		h.i32(4, encRLE)
+		h.end()
+		h.buf = append(h.buf, 0)
+
+		ch := chunk{offset: w.off, compressed: int64(len(h.buf) + len(page)), uncomp: int64(len(h.buf) + len(b.data))}
+		switch c.Type {
+		case Int64:
+			ch.stats = [2][]byte{binary.LittleEndian.AppendUint64(nil, uint64(b.imin)), binary.LittleEndian.AppendUint64(nil, uint64(b.imax))}
+		case Double:
+			ch.stats = [2][]byte{binary.LittleEndian.AppendUint64(nil, math.Float64bits(b.min)), binary.LittleEndian.AppendUint64(nil, math.Float64bits(b.max))}
+		}
+		if err := w.write(h.buf); err != nil {
+			return err
+		}
+		if err := w.write(page); err != nil {
+			return err
+		}
+		rg.size += ch.uncomp
+		rg.chunks = append(rg.chunks, ch)
+	}
+	w.groups = append(w.groups, rg)
+	w.rows += rg.rows
+	w.reset()
+	return nil
+}
+
+// Close flushes the last row group and writes the footer; it does not
+// close the underlying writer.
+func (w *Writer) Close() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	var e encoder
+	e.i32(1, formatVersion)
+	e.list(2, tStruct, len(w.cols)+1)
+	e.begin(0)
+	e.binary(4, []byte("schema"))
+	e.i32(5, int32(len(w.cols)))
+	e.end()
+	for _, c := range w.cols {
+		e.begin(0)
+		e.i32(1, int32(c.Type))
+		e.i32(3, repRequired)
+		e.binary(4, []byte(c.Name))
+		if c.Type == String {
+			e.i32(6, convertedUTF8)
+		}
+		e.end()
+	}
+	e.i64(3, w.rows)
+	e.list(4, tStruct, len(w.groups))
+	for _, rg := range w.groups {
+		e.begin(0)
+		e.list(1, tStruct, len(rg.chunks))
+		for i, ch := range rg.chunks {
+			c := w.cols[i]
+			e.begin(0)
+			e.i64(2, ch.offset)
+			e.begin(3)
+			e.i32(1, int32(c.Type))
+			e.list(2, tI32, 2)
+			e.varint(encPlain)
+			e.varint(encRLE)
+			e.list(3, tBinary, 1)
+			e.bytes([]byte(c.Name))
+			e.i32(4, int32(w.codec))
+			e.i64(5, rg.rows)
+			e.i64(6, ch.uncomp)
+			e.i64(7, ch.compressed)
+			e.i64(9, ch.offset)
+			if ch.stats[0] != nil {
+				e.begin(12)
+				e.binary(fieldStatsMax, ch.stats[1])
+				e.binary(fieldStatsMin, ch.stats[0])
+				e.end()
+			}
+			e.end()
+			e.end()
+		}
+		e.i64(2, rg.size)
+		e.i64(3, rg.rows)
+		e.end()
+	}
+	if len(w.meta) > 0 {
+		keys := make([]string, 0, len(w.meta))
+		for k := range w.meta {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		e.list(5, tStruct, len(keys))
+		for _, k := range keys {
+			e.begin(0)
+			e.binary(1, []byte(k))
+			e.binary(2, []byte(w.meta[k]))
+			e.end()
+		}
+	}
+	e.binary(6, []byte(createdBy))
+	e.buf = append(e.buf, 0)
+
+	if err := w.write(e.buf); err != nil {
+		return err
+	}
+	if err := w.write(binary.LittleEndian.AppendUint32(nil, uint32(len(e.buf)))); err != nil {
+		return err
+	}
+	return w.write(magic)
+}
+
+// File is a Parquet file read back as text rows.
+type File struct {
+	Columns  []Column
+	Rows     [][]string
+	Metadata map[string]string
+}
+
+// ReadFile reads a file this package wrote.
+func ReadFile(path string) (*File, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := Decode(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+type chunkMeta struct {
+	typ    Type
+	codec  Codec
+	values int64
+	offset int64
+}
+
+// Decode parses a whole file held in memory.
+func Decode(b []byte) (*File, error) {
+	if len(b) < 12 || !bytes.Equal(b[:4], magic) || !bytes.Equal(b[len(b)-4:], magic) {
+		return nil, errors.New("parquet: not a Parquet file")
+	}
+	n := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	if n <= 0 || n > maxFooterBytes || n > len(b)-12 {
+		return nil, errors.New("parquet: bad footer length")
+	}
+	d := &decoder{buf: b[len(b)-8-n : len(b)-8]}
+	f := &File{Metadata: map[string]string{}}
+	var groups [][]chunkMeta
+	err := d.structFields(func(id int16, typ byte) error {
+		switch id {
+		case 2:
+			_, n, err := d.listHeader()
+			if err != nil {
+				return err
+			}
+			for i := 0; i < n; i++ {
+				var c Column
+				if err := d.structFields(func(id int16, typ byte) error {
+					switch id {
+					case 1:
+						v, err := d.varint()
+						c.Type = Type(v)
+						return err
+					case 4:
+						name, err := d.bytes()
+						c.Name = string(name)
+						return err
+					}
+					return d.skip(typ)
+				}); err != nil {
+					return err
+				}
+				// the first element is the root
+				if i > 0 {
+					f.Columns = append(f.Columns, c)
+				}
+			}
+			return nil
+		case 4:
+			_, n, err := d.listHeader()
+			if err != nil {
+				return err
+			}
+			for i := 0; i < n; i++ {
+				var chunks []chunkMeta
+				if err := d.structFields(func(id int16, typ byte) error {
+					if id != 1 {
+						return d.skip(typ)
+					}
+					_, n, err := d.listHeader()
+					if err != nil {
+						return err
+					}
+					for j := 0; j < n; j++ {
+						var cm chunkMeta
+						if err := d.structFields(func(id int16, typ byte) error {
+							if id != 3 {
+								return d.skip(typ)
+							}
+							return d.structFields(func(id int16, typ byte) error {
+								var err error
+								var v int64
+								switch id {
+								case 1:
+									v, err = d.varint()
+									cm.typ = Type(v)
+								case 4:
+									v, err = d.varint()
+									cm.codec = Codec(v)
+								case 5:
+									cm.values, err = d.varint()
+								case 9:
+									cm.offset, err = d.varint()
+								default:
+									err = d.skip(typ)
+								}
+								return err
+							})
+						}); err != nil {
+							return err
+						}
+						chunks = append(chunks, cm)
+					}
+					return nil
+				}); err != nil {
+					return err
+				}
+				groups = append(groups, chunks)
+			}
+			return nil
+		case 5:
+			_, n, err := d.listHeader()
+			if err != nil {
+				return err
+			}
+			for i := 0; i < n; i++ {
+				var k, v []byte
+				if err := d.structFields(func(id int16, typ byte) error {
+					var err error
+					switch id {
+					case 1:
+						k, err = d.bytes()
+					case 2:
+						v, err = d.bytes()
+					default:
+						err = d.skip(typ)
+					}
+					return err
+				}); err != nil {
+					return err
+				}
+				f.Metadata[string(k)] = string(v)
+			}
+			return nil
+		}
+		return d.skip(typ)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, chunks := range groups {
+		if len(chunks) != len(f.Columns) {
+			return nil, errors.New("parquet: row group does not match the schema")
+		}
+		var cols [][]string
+		for _, cm := range chunks {
+			vals, err := readChunk(b, cm)
+			if err != nil {
+				return nil, err
+			}
+			if len(cols) > 0 && len(vals) != len(cols[0]) {
+				return nil, errors.New("parquet: columns of a row group differ in length")
+			}
+			cols = append(cols, vals)
+		}
+		for r := range cols[0] {
+			row := make([]string, len(cols))
+			for c := range cols {
+				row[c] = cols[c][r]
+			}
+			f.Rows = append(f.Rows, row)
+		}
+	}
+	return f, nil
+}
+
+// readChunk decodes the data pages of one column chunk.
+func readChunk(b []byte, cm chunkMeta) ([]string, error) {
+	var out []string
+	off := cm.offset
+	for int64(len(out)) < cm.values {
+		if off < 4 || off >= int64(len(b)) {
+			return nil, errors.New("parquet: page offset out of range")
+		}
+		d := &decoder{buf: b[off:]}
+		var typ, uncomp, comp, count, enc int64 = -1, 0, 0, 0, -1
+		err := d.structFields(func(id int16, t byte) error {
+			var err error
+			switch id {
+			case 1:
+				typ, err = d.varint()
+			case 2:
+				uncomp, err = d.varint()
+			case 3:
+				comp, err = d.varint()
+			case 5:
+				err = d.structFields(func(id int16, t byte) error {
+					var err error
+					switch id {
+					case 1:
+						count, err = d.varint()
+					case 2:
+						enc, err = d.varint()
+					default:
+						err = d.skip(t)
+					}
+					return err
+				})
+			default:
+				err = d.skip(t)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if typ != pageData || enc != encPlain {
+			return nil, fmt.Errorf("parquet: unsupported page (type %d, encoding %d)", typ, enc)
+		}
+		start := off + int64(d.off)
+		if comp < 0 || start+comp > int64(len(b)) {
+			return nil, errors.New("parquet: page out of range")
+		}
+		page := b[start : start+comp]
+		if cm.codec == Gzip {
+			zr, err := gzip.NewReader(bytes.NewReader(page))
+			if err != nil {
+				return nil, err
+			}
+			if page, err = io.ReadAll(io.LimitReader(zr, uncomp+1)); err != nil {
+				return nil, err
+			}
+		} else if cm.codec != Uncompressed {
+			return nil, fmt.Errorf("parquet: unsupported codec %d", cm.codec)
+		}
+		for i := int64(0); i < count; i++ {
+			switch cm.typ {
+			case Int64, Double:
+				if len(page) < 8 {
+					return nil, errors.New("parquet: short page")
+				}
+				u := binary.LittleEndian.Uint64(page)
+				if cm.typ == Int64 {
+					out = append(out, strconv.FormatInt(int64(u), 10))
+				} else {
+					out = append(out, strconv.FormatFloat(math.Float64frombits(u), 'f', -1, 64))
+				}
+				page = page[8:]
+			case String:
+				if len(page) < 4 {
+					return nil, errors.New("parquet: short page")
+				}
+				n := int(binary.LittleEndian.Uint32(page))
+				if n > len(page)-4 {
+					return nil, errors.New("parquet: short page")
+				}
+				out = append(out, string(page[4:4+n]))
+				page = page[4+n:]
+			default:
+				return nil, fmt.Errorf("parquet: unsupported type %d", cm.typ)
+			}
+		}
+		off = start + comp
+	}
+	return out, nil
+}
@@ -0,0 +1,229 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Thrift compact protocol, the encoding of Parquet's page headers and
+// footer. Only what the format needs is implemented.
+
+const (
+	tBoolTrue  = 1
+	tBoolFalse = 2
+	tByte      = 3
+	tI16       = 4
+	tI32       = 5
+	tI64       = 6
+	tDouble    = 7
+	tBinary    = 8
+	tList      = 9
+	tSet       = 10
+	tMap       = 11
+	tStruct    = 12
+)
+
+type encoder struct {
+	buf   []byte
+	last  int16
+	stack []int16
+}
+
+func (e *encoder) uvarint(v uint64) { e.buf = binary.AppendUvarint(e.buf, v) }
+func (e *encoder) varint(v int64)   { e.buf = binary.AppendVarint(e.buf, v) }
+
+func (e *encoder) field(id int16, typ byte) {
+	if d := id - e.last; d > 0 && d <= 15 {
+		e.buf = append(e.buf, byte(d)<<4|typ)
+	} else {
+		e.buf = append(e.buf, typ)
+		e.varint(int64(id))
+	}
+	e.last = id
+}
+
+func (e *encoder) i32(id int16, v int32) { e.field(id, tI32); e.varint(int64(v)) }
+func (e *encoder) i64(id int16, v int64) { e.field(id, tI64); e.varint(v) }
+
+func (e *encoder) binary(id int16, b []byte) {
+	e.field(id, tBinary)
+	e.bytes(b)
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.uvarint(uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// begin opens a nested struct: a field of the current one, or a list
+// element when id is 0.
+func (e *encoder) begin(id int16) {
+	if id != 0 {
+		e.field(id, tStruct)
+	}
+	e.stack = append(e.stack, e.last)
+	e.last = 0
+}
+
+func (e *encoder) end() {
+	e.buf = append(e.buf, 0)
+	e.last = e.stack[len(e.stack)-1]
+	e.stack = e.stack[:len(e.stack)-1]
+}
+
+func (e *encoder) list(id int16, elem byte, n int) {
+	e.field(id, tList)
+	if n < 15 {
+		e.buf = append(e.buf, byte(n)<<4|elem)
+	} else {
+		e.buf = append(e.buf, 0xf0|elem)
+		e.uvarint(uint64(n))
+	}
+}
+
+var errThrift = errors.New("parquet: malformed thrift")
+
+type decoder struct {
+	buf []byte
+	off int
+}
+
+func (d *decoder) byte() (byte, error) {
+	if d.off >= len(d.buf) {
+		return 0, errThrift
+	}
+	b := d.buf[d.off]
+	d.off++
+	return b, nil
+}
+
+func (d *decoder) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(d.buf[d.off:])
+	if n <= 0 {
+		return 0, errThrift
+	}
+	d.off += n
+	return v, nil
+}
+
+func (d *decoder) varint() (int64, error) {
+	v, n := binary.Varint(d.buf[d.off:])
+	if n <= 0 {
+		return 0, errThrift
+	}
+	d.off += n
+	return v, nil
+}
+
+func (d *decoder) bytes() ([]byte, error) {
+	n, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.buf)-d.off) {
+		return nil, errThrift
+	}
+	b := d.buf[d.off : d.off+int(n)]
+	d.off += int(n)
+	return b, nil
+}
+
+// structFields calls fn for every field of the struct at the cursor; fn
+// must consume the value or call skip.
+func (d *decoder) structFields(fn func(id int16, typ byte) error) error {
+	var last int16
+	for {
+		h, err := d.byte()
+		if err != nil {
+			return err
+		}
+		if h == 0 {
+			return nil
+		}
+		typ := h & 0x0f
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			v, err := d.varint()
+			if err != nil {
+				return err
+			}
+			id = int16(v)
+		}
+		last = id
+		if err := fn(id, typ); err != nil {
+			return err
+		}
+	}
+}
+
+func (d *decoder) listHeader() (elem byte, n int, err error) {
+	h, err := d.byte()
+	if err != nil {
+		return 0, 0, err
+	}
+	elem, size := h&0x0f, uint64(h>>4)
+	if size == 15 {
+		if size, err = d.uvarint(); err != nil {
+			return 0, 0, err
+		}
+	}
+	if size > uint64(len(d.buf)) {
+		return 0, 0, errThrift
+	}
+	return elem, int(size), nil
+}
+
+func (d *decoder) skip(typ byte) error {
+	switch typ {
+	case tBoolTrue, tBoolFalse:
+		return nil
+	case tByte:
+		_, err := d.byte()
+		return err
+	case tI16, tI32, tI64:
+		_, err := d.varint()
+		return err
+	case tDouble:
+		if d.off+8 > len(d.buf) {
+			return errThrift
+		}
+		d.off += 8
+		return nil
+	case tBinary:
+		_, err := d.bytes()
+		return err
+	case tList, tSet:
+		elem, n, err := d.listHeader()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			if err := d.skip(elem); err != nil {
+				return err
+			}
+		}
+		return nil
+	case tMap:
+		n, err := d.uvarint()
+		if err != nil || n == 0 {
+			return err
+		}
+		kv, err := d.byte()
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < n; i++ {
+			if err := d.skip(kv >> 4); err != nil {
+				return err
+			}
+			if err := d.skip(kv & 0x0f); err != nil {
+				return err
+			}
+		}
+		return nil
+	case tStruct:
+		return d.structFields(func(_ int16, typ byte) error { return d.skip(typ) })
+	}
+	return fmt.Errorf("%w: type %d", errThrift, typ)
+}
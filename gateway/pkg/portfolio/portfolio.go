@@ -0,0 +1,279 @@
+// Package portfolio aggregates balances and positions across venues into
+// one view valued in a base currency. Venues with an account Source report
+// their own holdings; the rest contribute the positions the gateway's
+// fills built in the tracker.
+package portfolio
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/fx"
+	"github.com/helix-lab/helix/gateway/pkg/metrics"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+var pollErrors = metrics.Default.CounterVec("helix_portfolio_poll_errors_total", "Failed account polls per venue.", "venue")
+
+// Balance is one asset on one venue.
+type Balance struct {
+	Asset  string
+	Free   float64
+	Locked float64
+}
+
+// Holdings is what a venue's account reported.
+type Holdings struct {
+	Venue     string
+	Balances  []Balance
+	Positions []executor.Position
+	CheckedAt time.Time
+	// Spot accounts hold balances only and report no positions.
+	Spot bool
+}
+
+// Source reads one venue account, typically over its authenticated REST
+// API.
+type Source interface {
+	Venue() string
+	Holdings(ctx context.Context) (Holdings, error)
+}
+
+// Prices values one unit of asset in the base currency.
+type Prices func(asset string) (float64, bool)
+
+// BookPrices prices assets at the books' fx rates into base.
+func BookPrices(m *orderbook.Manager, base string) Prices {
+	return fx.New(m, base).Rate
+}
+
+// SplitSymbol splits "BTCUSDT" into its base and quote assets, as
+// fx.SplitSymbol.
+func SplitSymbol(symbol string) (base, quote string, ok bool) {
+	return fx.SplitSymbol(symbol)
+}
+
+// Portfolio polls its sources and keeps the latest holdings per venue. A
+// failed poll keeps the venue's previous holdings.
+type Portfolio struct {
+	Interval time.Duration
+	Base     string
+	Logf     func(format string, args ...any)
+
+	prices  Prices
+	tracker *executor.Tracker
+	sources []Source
+	pub     func(transport.Portfolio)
+
+	mu       sync.RWMutex
+	holdings map[string]Holdings
+	last     transport.Portfolio
+	// drift is the last logged drift per "venue symbol"; Poll owns it.
+	drift map[string]float64
+}
+
+// New values holdings in base with prices; tracker (optional) supplies
+// positions for venues without a source.
+func New(base string, prices Prices, tracker *executor.Tracker, sources ...Source) *Portfolio {
+	return &Portfolio{Interval: 30 * time.Second, Base: base, prices: prices, tracker: tracker,
+		sources: sources, holdings: make(map[string]Holdings), drift: make(map[string]float64)}
+}
+
+// SetPublisher receives the view after every poll.
+func (p *Portfolio) SetPublisher(fn func(transport.Portfolio)) { p.pub = fn }
+
+func (p *Portfolio) Run(ctx context.Context) {
+	p.Poll(ctx)
+	t := time.NewTicker(p.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			p.Poll(ctx)
+		}
+	}
+}
+
+// Poll reads every source once and publishes the new view.
+func (p *Portfolio) Poll(ctx context.Context) {
+	for _, src := range p.sources {
+		h, err := src.Holdings(ctx)
+		if err != nil {
+			pollErrors.With(src.Venue()).Inc()
+			if p.Logf != nil {
+				p.Logf("portfolio: %s: %v", src.Venue(), err)
+			}
+			continue
+		}
+		h.Venue = src.Venue()
+		p.mu.Lock()
+		p.holdings[h.Venue] = h
+		p.mu.Unlock()
+	}
+	if p.Logf != nil {
+		p.logDrift()
+	}
+	v := p.View(time.Now())
+	if p.pub != nil {
+		p.pub(v)
+	}
+}
+
+// Positions are the reported positions of sourced venues plus the
+// tracker's for every other venue.
+func (p *Portfolio) Positions() []executor.Position {
+	p.mu.RLock()
+	var out []executor.Position
+	for _, h := range p.holdings {
+		out = append(out, h.Positions...)
+	}
+	sourced := make(map[string]bool, len(p.holdings))
+	for v := range p.holdings {
+		sourced[v] = true
+	}
+	p.mu.RUnlock()
+	if p.tracker != nil {
+		for _, pos := range p.tracker.Positions() {
+			if !sourced[pos.Venue] {
+				out = append(out, pos)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Symbol != out[j].Symbol {
+			return out[i].Symbol < out[j].Symbol
+		}
+		return out[i].Venue < out[j].Venue
+	})
+	return out
+}
+
+// View values the current holdings at now's prices.
+func (p *Portfolio) View(now time.Time) transport.Portfolio {
+	v := transport.Portfolio{TsMs: now.UnixMilli(), Base: p.Base}
+	assets := map[string]*transport.PortfolioAsset{}
+	p.mu.RLock()
+	for venue, h := range p.holdings {
+		for _, b := range h.Balances {
+			a, ok := assets[b.Asset]
+			if !ok {
+				a = &transport.PortfolioAsset{Asset: b.Asset, Venues: map[string]float64{}}
+				assets[b.Asset] = a
+			}
+			a.Total += b.Free + b.Locked
+			a.Venues[venue] += b.Free + b.Locked
+		}
+	}
+	p.mu.RUnlock()
+
+	unpriced := map[string]bool{}
+	for _, a := range assets {
+		if px, ok := p.prices(a.Asset); ok {
+			a.Value = a.Total * px
+			v.Equity += a.Value
+		} else if a.Total != 0 {
+			unpriced[a.Asset] = true
+		}
+		v.Assets = append(v.Assets, *a)
+	}
+	sort.Slice(v.Assets, func(i, j int) bool { return v.Assets[i].Value > v.Assets[j].Value })
+
+	for _, pos := range p.Positions() {
+		if pos.Qty == 0 {
+			continue
+		}
+		pp := transport.PortfolioPosition{Venue: pos.Venue, Symbol: pos.Symbol, Qty: pos.Qty, AvgPrice: pos.AvgPrice}
+		base, quote, ok := SplitSymbol(pos.Symbol)
+		if !ok {
+			unpriced[pos.Symbol] = true
+			v.Positions = append(v.Positions, pp)
+			continue
+		}
+		mark, okMark := p.prices(base)
+		qpx, okQuote := p.prices(quote)
+		if okMark && okQuote && qpx > 0 {
+			// marks are in base currency; PnL accrues in the quote
+			pp.Mark = mark / qpx
+			pp.Unrealized = (pp.Mark - pos.AvgPrice) * pos.Qty * qpx
+			v.Equity += pp.Unrealized
+		} else {
+			unpriced[pos.Symbol] = true
+		}
+		v.Positions = append(v.Positions, pp)
+	}
+	for a := range unpriced {
+		v.Unpriced = append(v.Unpriced, a)
+	}
+	sort.Strings(v.Unpriced)
+
+	p.mu.Lock()
+	p.last = v
+	p.mu.Unlock()
+	return v
+}
+
+// Last is the view of the latest poll.
+func (p *Portfolio) Last() transport.Portfolio {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.last
+}
+
+// Free is the unlocked balance of asset on venue; ok is false when the
+// venue has no source or has not been polled yet.
+func (p *Portfolio) Free(venue, asset string) (free float64, ok bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	h, ok := p.holdings[venue]
+	if !ok {
+		return 0, false
+	}
+	for _, b := range h.Balances {
+		if b.Asset == asset {
+			return b.Free, true
+		}
+	}
+	return 0, true
+}
+
+// Covers reports whether venue's balances can settle a spot action at
+// price: the base asset for a sell, the quote for a buy. Venues without
+// holdings, unknown symbols and market orders without a price pass.
+func (p *Portfolio) Covers(venue string, action transport.Action, price float64) bool {
+	base, quote, ok := SplitSymbol(action.Symbol)
+	if !ok {
+		return true
+	}
+	if action.Side == "SELL" {
+		free, known := p.Free(venue, base)
+		return !known || free >= action.Size
+	}
+	free, known := p.Free(venue, quote)
+	return !known || price <= 0 || free >= action.Size*price
+}
+
+// RegisterMetrics exposes equity and per-asset value of the latest view,
+// and position drift.
+func (p *Portfolio) RegisterMetrics(reg *metrics.Registry) {
+	reg.Collect("helix_portfolio_equity", "Portfolio equity in the base currency, balances plus unrealized PnL.", "gauge", func(emit metrics.Emit) {
+		v := p.Last()
+		emit("", metrics.L("base", v.Base), v.Equity)
+	})
+	reg.Collect("helix_portfolio_asset_value", "Holdings of each asset across venues, in the base currency.", "gauge", func(emit metrics.Emit) {
+		v := p.Last()
+		for _, a := range v.Assets {
+			emit("", metrics.L("asset", a.Asset, "base", v.Base), a.Value)
+		}
+	})
+	reg.Collect("helix_portfolio_position_drift", "Venue-reported minus tracked position per sourced venue and symbol.", "gauge", func(emit metrics.Emit) {
+		for _, d := range p.Reconcile() {
+			emit("", metrics.L("venue", d.Venue, "symbol", d.Symbol), d.Delta)
+		}
+	})
+}
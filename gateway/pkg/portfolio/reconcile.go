@@ -0,0 +1,98 @@
+package portfolio
+
+import (
+	"sort"
+
+	"github.com/helix-lab/helix/gateway/pkg/decimal"
+)
+
+// Drift compares one position on a sourced venue as the venue reports it
+// and as the gateway's fills built it in the tracker.
+type Drift struct {
+	Venue    string
+	Symbol   string
+	Reported float64
+	Tracked  float64
+	// Delta is Reported - Tracked: position the gateway does not know
+	// about, or fills it booked that the venue did not.
+	Delta float64
+}
+
+// Holdings are the latest holdings of every sourced venue, by venue.
+func (p *Portfolio) Holdings() []Holdings {
+	p.mu.RLock()
+	out := make([]Holdings, 0, len(p.holdings))
+	for _, h := range p.holdings {
+		out = append(out, h)
+	}
+	p.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Venue < out[j].Venue })
+	return out
+}
+
+// Reconcile lines up every position a sourced derivatives venue reported
+// at its last poll with the tracker's on that venue, by venue and symbol.
+// Spot venues hold balances, not positions, and are left out.
+func (p *Portfolio) Reconcile() []Drift {
+	type key struct{ venue, symbol string }
+	rows := map[key]*Drift{}
+	row := func(venue, symbol string) *Drift {
+		k := key{venue, symbol}
+		if rows[k] == nil {
+			rows[k] = &Drift{Venue: venue, Symbol: symbol}
+		}
+		return rows[k]
+	}
+	sourced := map[string]bool{}
+	for _, h := range p.Holdings() {
+		if h.Spot {
+			continue
+		}
+		sourced[h.Venue] = true
+		for _, pos := range h.Positions {
+			row(h.Venue, pos.Symbol).Reported += pos.Qty
+		}
+	}
+	if p.tracker != nil {
+		for _, pos := range p.tracker.Positions() {
+			if sourced[pos.Venue] && pos.Qty != 0 {
+				row(pos.Venue, pos.Symbol).Tracked += pos.Qty
+			}
+		}
+	}
+	out := make([]Drift, 0, len(rows))
+	for _, d := range rows {
+		d.Delta = (decimal.FromFloat(d.Reported) - decimal.FromFloat(d.Tracked)).Float()
+		out = append(out, *d)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Venue != out[j].Venue {
+			return out[i].Venue < out[j].Venue
+		}
+		return out[i].Symbol < out[j].Symbol
+	})
+	return out
+}
+
+// logDrift reports positions whose drift appeared, changed or cleared
+// since the previous poll.
+func (p *Portfolio) logDrift() {
+	seen := map[string]bool{}
+	for _, d := range p.Reconcile() {
+		if d.Delta == 0 {
+			continue
+		}
+		k := d.Venue + " " + d.Symbol
+		seen[k] = true
+		if p.drift[k] != d.Delta {
+			p.Logf("portfolio: %s position drift %g (venue %g, tracked %g)", k, d.Delta, d.Reported, d.Tracked)
+			p.drift[k] = d.Delta
+		}
+	}
+	for k := range p.drift {
+		if !seen[k] {
+			p.Logf("portfolio: %s position reconciled", k)
+			delete(p.drift, k)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Package probe serves liveness and readiness for orchestrators: /live
+// fails when the event loop stops beating, /ready until every readiness
+// check passes and again once the process starts draining.
+package probe
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Probes holds the loop heartbeat and the readiness checks.
+type Probes struct {
+	// StallAfter is how long the loop may go without a Beat before /live
+	// fails.
+	StallAfter time.Duration
+
+	beat     atomic.Int64
+	draining atomic.Bool
+
+	mu     sync.Mutex
+	checks map[string]func() error
+}
+
+func New(stallAfter time.Duration) *Probes {
+	p := &Probes{StallAfter: stallAfter, checks: make(map[string]func() error)}
+	p.Beat()
+	return p
+}
+
+// Beat records that the event loop is turning; call it every iteration.
+func (p *Probes) Beat() { p.beat.Store(time.Now().UnixNano()) }
+
+// AddCheck adds a named readiness condition; nil error means satisfied.
+func (p *Probes) AddCheck(name string, fn func() error) {
+	p.mu.Lock()
+	p.checks[name] = fn
+	p.mu.Unlock()
+}
+
+// Drain makes /ready fail from now on so traffic moves elsewhere during
+// shutdown; /live keeps passing.
+func (p *Probes) Drain() { p.draining.Store(true) }
+
+func (p *Probes) Live() error {
+	since := time.Since(time.Unix(0, p.beat.Load()))
+	if p.StallAfter > 0 && since > p.StallAfter {
+		return errors.New("event loop stalled for " + since.Truncate(time.Millisecond).String())
+	}
+	return nil
+}
+
+// Ready runs every check and returns the failures by name.
+func (p *Probes) Ready() map[string]error {
+	failed := map[string]error{}
+	if p.draining.Load() {
+		failed["draining"] = errors.New("shutting down")
+	}
+	p.mu.Lock()
+	checks := make(map[string]func() error, len(p.checks))
+	for name, fn := range p.checks {
+		checks[name] = fn
+	}
+	p.mu.Unlock()
+	for name, fn := range checks {
+		if err := fn(); err != nil {
+			failed[name] = err
+		}
+	}
+	return failed
+}
+
+type result struct {
+	Status string            `json:"status"`
+	Failed map[string]string `json:"failed,omitempty"`
+}
+
+// Handle mounts /live and /ready; they answer 200 or 503 with a JSON body.
+func (p *Probes) Handle(mount func(pattern string, h http.Handler)) {
+	mount("/live", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if err := p.Live(); err != nil {
+			write(w, map[string]error{"loop": err})
+			return
+		}
+		write(w, nil)
+	}))
+	mount("/ready", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		write(w, p.Ready())
+	}))
+}
+
+func write(w http.ResponseWriter, failed map[string]error) {
+	w.Header().Set("Content-Type", "application/json")
+	res := result{Status: "ok"}
+	code := http.StatusOK
+	if len(failed) > 0 {
+		res.Status, code = "fail", http.StatusServiceUnavailable
+		res.Failed = make(map[string]string, len(failed))
+		for name, err := range failed {
+			res.Failed[name] = err.Error()
+		}
+	}
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(res)
+}
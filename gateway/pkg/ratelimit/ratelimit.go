@@ -0,0 +1,285 @@
+// Package ratelimit paces the gateway's calls to venue REST APIs. Each
+// venue and endpoint group gets a token bucket started from static config,
+// and every response's rate-limit headers (Bybit's X-Bapi-Limit-*,
+// Binance's X-MBX-USED-WEIGHT-*, Retry-After) are fed back so the bucket
+// follows what the venue says is left, even after its limits change.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/metrics"
+)
+
+// Orders is the group the executor takes from before sending an order.
+const Orders = "orders"
+
+// ErrThrottled is returned by Wait when ctx would end before a call is
+// allowed.
+var ErrThrottled = errors.New("rate limited")
+
+var (
+	throttled = metrics.Default.CounterVec("helix_ratelimit_throttled_total",
+		"Calls held back or refused by the rate limiter, per venue and group.", "venue", "group")
+	remainingGauge = metrics.Default.GaugeVec("helix_ratelimit_remaining",
+		"Calls the venue last said were left in its window, per venue and group.", "venue", "group")
+)
+
+// Status is one response's rate-limit headers; zero fields were absent.
+type Status struct {
+	// Limit calls are allowed per window, Remaining of them left until
+	// Reset.
+	Limit     int
+	Remaining int
+	Reset     time.Time
+	// Used is Binance's weight used in the current Window, which has no
+	// limit header.
+	Used   int
+	Window time.Duration
+	// RetryAfter is how long the venue asked to back off (429 and 418).
+	RetryAfter time.Duration
+}
+
+// Parse reads the headers of a Bybit or Binance response received at now;
+// ok is false when there are none.
+func Parse(resp *http.Response, now time.Time) (s Status, ok bool) {
+	h := resp.Header
+	if v := h.Get("X-Bapi-Limit-Status"); v != "" {
+		s.Remaining, _ = strconv.Atoi(v)
+		s.Limit, _ = strconv.Atoi(h.Get("X-Bapi-Limit"))
+		if ms, err := strconv.ParseInt(h.Get("X-Bapi-Limit-Reset-Timestamp"), 10, 64); err == nil {
+			s.Reset = time.UnixMilli(ms)
+		}
+		ok = true
+	}
+	// Binance reports X-MBX-USED-WEIGHT-<n><unit> for each window; the
+	// minute one is the limit that matters for REST polling
+	for name, vals := range h {
+		interval, found := strings.CutPrefix(strings.ToUpper(name), "X-MBX-USED-WEIGHT-")
+		if !found || len(vals) == 0 {
+			continue
+		}
+		w, err := binanceWindow(interval)
+		if err != nil || (s.Window != 0 && w < s.Window) {
+			continue
+		}
+		s.Used, _ = strconv.Atoi(vals[0])
+		s.Window = w
+		s.Reset = now.Truncate(w).Add(w)
+		ok = true
+	}
+	if v := h.Get("Retry-After"); v != "" && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == 418) {
+		if sec, err := strconv.Atoi(v); err == nil {
+			s.RetryAfter = time.Duration(sec) * time.Second
+		} else if at, err := http.ParseTime(v); err == nil {
+			s.RetryAfter = at.Sub(now)
+		}
+		ok = true
+	}
+	return s, ok
+}
+
+// binanceWindow parses the "1M" of X-MBX-USED-WEIGHT-1M.
+func binanceWindow(s string) (time.Duration, error) {
+	if len(s) < 2 {
+		return 0, fmt.Errorf("window %q", s)
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("window %q", s)
+	}
+	unit := map[byte]time.Duration{'S': time.Second, 'M': time.Minute, 'H': time.Hour, 'D': 24 * time.Hour}[s[len(s)-1]]
+	if unit == 0 {
+		return 0, fmt.Errorf("window %q", s)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+type key struct{ venue, group string }
+
+// bucket is a token bucket at rate per second; once the venue has reported
+// its window, calls also stop at Reserve remaining until the reset.
+type bucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+
+	limit     int
+	remaining int
+	reset     time.Time
+	until     time.Time
+}
+
+// Limiter is safe for concurrent use. Groups with no rate of their own
+// take their venue's default; a venue with neither is paced by its
+// headers alone.
+type Limiter struct {
+	// Reserve is the headroom left for other callers sharing the venue's
+	// limit, e.g. the executor while pollers wait; it defaults to 1.
+	Reserve int
+	// Now is the clock; tests pin it.
+	Now func() time.Time
+
+	mu      sync.Mutex
+	rates   map[key]float64
+	buckets map[key]*bucket
+}
+
+func New() *Limiter {
+	return &Limiter{Reserve: 1, Now: time.Now, rates: map[key]float64{}, buckets: map[key]*bucket{}}
+}
+
+// SetRate is the static rate per second for venue's group, or for every
+// group without its own when group is empty; 0 leaves it to the headers.
+func (l *Limiter) SetRate(venue, group string, perSec float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rates[key{venue, group}] = perSec
+	for k, b := range l.buckets {
+		if k.venue == venue && (k.group == group || group == "") {
+			b.rate = l.rate(k)
+		}
+	}
+}
+
+func (l *Limiter) rate(k key) float64 {
+	if r, ok := l.rates[k]; ok {
+		return r
+	}
+	return l.rates[key{k.venue, ""}]
+}
+
+func (l *Limiter) bucket(k key, now time.Time) *bucket {
+	b := l.buckets[k]
+	if b == nil {
+		b = &bucket{rate: l.rate(k), last: now}
+		b.tokens = max(b.rate, 1)
+		l.buckets[k] = b
+	}
+	return b
+}
+
+// delay is how long a call must wait, after refilling the bucket to now.
+func (l *Limiter) delay(b *bucket, now time.Time) time.Duration {
+	if now.Before(b.until) {
+		return b.until.Sub(now)
+	}
+	if b.rate > 0 {
+		b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*b.rate, max(b.rate, 1))
+		b.last = now
+		if b.tokens < 1 {
+			return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		}
+	}
+	if now.Before(b.reset) && b.remaining <= l.Reserve {
+		return b.reset.Sub(now)
+	}
+	return 0
+}
+
+func (b *bucket) take(now time.Time) {
+	if b.rate > 0 {
+		b.tokens--
+	}
+	if now.Before(b.reset) {
+		b.remaining--
+	}
+}
+
+// Allow takes a call for venue's group if one is available now.
+func (l *Limiter) Allow(venue, group string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.Now()
+	b := l.bucket(key{venue, group}, now)
+	if l.delay(b, now) > 0 {
+		throttled.With(venue, group).Inc()
+		return false
+	}
+	b.take(now)
+	return true
+}
+
+// Available reports whether Allow would succeed, without taking a call.
+func (l *Limiter) Available(venue, group string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.Now()
+	return l.delay(l.bucket(key{venue, group}, now), now) == 0
+}
+
+// Wait blocks until a call for venue's group is allowed and takes it.
+func (l *Limiter) Wait(ctx context.Context, venue, group string) error {
+	for {
+		l.mu.Lock()
+		now := l.Now()
+		b := l.bucket(key{venue, group}, now)
+		d := l.delay(b, now)
+		if d == 0 {
+			b.take(now)
+			l.mu.Unlock()
+			return nil
+		}
+		l.mu.Unlock()
+		throttled.With(venue, group).Inc()
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+			return fmt.Errorf("%w: %s %s for %v", ErrThrottled, venue, group, d.Round(time.Millisecond))
+		}
+		t := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Observe feeds a response's status back into venue's group. A reported
+// limit also becomes the bucket's rate, over Window or Bybit's one second.
+func (l *Limiter) Observe(venue, group string, s Status) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.Now()
+	b := l.bucket(key{venue, group}, now)
+	if s.RetryAfter > 0 {
+		b.until = now.Add(s.RetryAfter)
+	}
+	window := s.Window
+	if window == 0 {
+		window = time.Second
+	}
+	if s.Limit > 0 {
+		b.limit = s.Limit
+		b.rate = float64(s.Limit) / window.Seconds()
+	}
+	if s.Reset.After(now) {
+		switch {
+		case s.Used > 0 && b.limit > 0:
+			b.remaining = b.limit - s.Used
+		case s.Used > 0 && b.rate > 0:
+			// Binance: the limit is the static rate over the window
+			b.remaining = int(b.rate*window.Seconds()) - s.Used
+		case s.Used > 0:
+			return
+		default:
+			b.remaining = s.Remaining
+		}
+		b.reset = s.Reset
+		remainingGauge.With(venue, group).Set(float64(b.remaining))
+	}
+}
+
+// ObserveResponse parses resp's headers into Observe.
+func (l *Limiter) ObserveResponse(venue, group string, resp *http.Response) {
+	if s, ok := Parse(resp, l.Now()); ok {
+		l.Observe(venue, group, s)
+	}
+}
@@ -0,0 +1,341 @@
+// Package risk is the gateway's risk engine. It vets every routed action
+// against symbol, venue and account exposure and order-rate limits, marks
+// the tracker's positions to the books for PnL, and arms the kill switch
+// when the day's loss passes its limit. Each evaluation can be published
+// as a transport.RiskState.
+package risk
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/fx"
+	"github.com/helix-lab/helix/gateway/pkg/metrics"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// ErrLimit wraps every refusal from Check.
+var ErrLimit = errors.New("risk limit")
+
+// Breach reasons.
+const (
+	BreachDailyLoss = "daily_loss"
+)
+
+// Limits are the engine's checks; zero disables one.
+type Limits struct {
+	// MaxSymbolPosition caps the absolute exposure in a symbol summed over
+	// venues, counting open orders as filled.
+	MaxSymbolPosition float64
+	// MaxVenueNotional caps the gross notional (|exposure| * mark) on one
+	// venue, and MaxAccountNotional across all of them.
+	MaxVenueNotional   float64
+	MaxAccountNotional float64
+	// MaxDailyLoss arms the kill switch once PnL since the start of the
+	// UTC day falls below -MaxDailyLoss.
+	MaxDailyLoss    float64
+	MaxOrdersPerSec int
+	MaxOrdersPerMin int
+}
+
+// Marks prices symbol as traded on venue for notional and unrealized PnL;
+// ok is false when it has no price.
+type Marks func(venue, symbol string) (price float64, ok bool)
+
+// BookMarks marks at the NBBO mid of the market venue quotes symbol in, so
+// spot and perpetual positions in one symbol mark apart. A venue without a
+// book marks at symbol's market if it has only one.
+func BookMarks(m *orderbook.Manager) Marks {
+	return func(venue, symbol string) (float64, bool) {
+		var category string
+		if b, ok := m.Venue(venue, symbol, ""); ok {
+			category = b.Category
+		} else if cats := m.Categories(symbol); len(cats) == 1 {
+			category = cats[0]
+		} else {
+			return 0, false
+		}
+		l := orderbook.MergeBest(m.MarketSnapshot(symbol, category))
+		if l.BestBid <= 0 || l.BestAsk <= 0 {
+			return 0, false
+		}
+		return (l.BestBid + l.BestAsk) / 2, true
+	}
+}
+
+// KillSwitch is what the engine arms, e.g. the OrderSender.
+type KillSwitch interface {
+	ArmKillSwitch(reason string)
+}
+
+// Publisher receives risk-state events, e.g. the transport publisher.
+type Publisher interface {
+	PublishRisk(transport.RiskState)
+}
+
+// Engine is safe for concurrent use.
+type Engine struct {
+	limits  Limits
+	tracker *executor.Tracker
+	marks   Marks
+	kill    KillSwitch
+	pub     Publisher
+	held    func() []executor.Position
+	rates   *fx.Rates
+
+	mu       sync.Mutex
+	sent     []time.Time
+	day      time.Time
+	dayStart float64
+	state    transport.RiskState
+	refused  map[string]uint64
+}
+
+func New(limits Limits, tracker *executor.Tracker, marks Marks) *Engine {
+	return &Engine{limits: limits, tracker: tracker, marks: marks, refused: make(map[string]uint64)}
+}
+
+// SetPositions replaces the tracker as the source of filled positions,
+// e.g. with a portfolio that has the venues' own. Open orders still come
+// from the tracker.
+func (e *Engine) SetPositions(fn func() []executor.Position) { e.held = fn }
+
+func (e *Engine) positions() []executor.Position {
+	if e.held != nil {
+		return e.held()
+	}
+	return e.tracker.Positions()
+}
+
+// SetRates values notional and PnL in r's base currency; without it they
+// are summed in whatever currency each symbol is quoted in. Amounts in a
+// quote r has no rate for count at par.
+func (e *Engine) SetRates(r *fx.Rates) { e.rates = r }
+
+// value converts amount, in symbol's quote currency, to the base.
+func (e *Engine) value(symbol string, amount float64) float64 {
+	if e.rates == nil {
+		return amount
+	}
+	if v, ok := e.rates.ConvertQuote(symbol, amount); ok {
+		return v
+	}
+	return amount
+}
+
+// SetKillSwitch lets the engine halt trading on a loss breach.
+func (e *Engine) SetKillSwitch(k KillSwitch) { e.kill = k }
+
+// SetPublisher sends Publish's evaluations, and those that change the
+// breach, to p.
+func (e *Engine) SetPublisher(p Publisher) { e.pub = p }
+
+type exposureKey struct{ venue, symbol string }
+
+// exposures is every venue/symbol exposure with open orders counted as
+// filled, like Tracker.Exposure.
+func (e *Engine) exposures() map[exposureKey]float64 {
+	out := map[exposureKey]float64{}
+	for _, p := range e.positions() {
+		out[exposureKey{p.Venue, p.Symbol}] += p.Qty
+	}
+	for _, o := range e.tracker.OpenOrders() {
+		out[exposureKey{o.Venue, o.Symbol}] += signed(o.Side, o.Size-o.Filled)
+	}
+	return out
+}
+
+// Check implements executor.Checker; action must have its venue. An
+// action that only reduces exposure passes the exposure limits.
+func (e *Engine) Check(action transport.Action) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	l := e.limits
+	now := time.Now()
+	if err := e.checkRate(now); err != nil {
+		return e.refuse("rate", err)
+	}
+	exp := e.exposures()
+	delta := signed(action.Side, action.Size)
+	if l.MaxSymbolPosition > 0 {
+		var cur float64
+		for k, q := range exp {
+			if k.symbol == action.Symbol {
+				cur += q
+			}
+		}
+		if next := cur + delta; math.Abs(next) > l.MaxSymbolPosition && math.Abs(next) > math.Abs(cur) {
+			return e.refuse("symbol_position", fmt.Errorf("%w: %s position would be %g (max %g)", ErrLimit, action.Symbol, next, l.MaxSymbolPosition))
+		}
+	}
+	if l.MaxVenueNotional > 0 || l.MaxAccountNotional > 0 {
+		k := exposureKey{action.Venue, action.Symbol}
+		cur := exp[k]
+		exp[k] = cur + delta
+		if math.Abs(exp[k]) > math.Abs(cur) {
+			venue, account, err := e.notional(exp, action)
+			if err != nil {
+				return e.refuse("no_mark", err)
+			}
+			if l.MaxVenueNotional > 0 && venue > l.MaxVenueNotional {
+				return e.refuse("venue_notional", fmt.Errorf("%w: %s notional would be %.2f (max %g)", ErrLimit, action.Venue, venue, l.MaxVenueNotional))
+			}
+			if l.MaxAccountNotional > 0 && account > l.MaxAccountNotional {
+				return e.refuse("account_notional", fmt.Errorf("%w: gross notional would be %.2f (max %g)", ErrLimit, account, l.MaxAccountNotional))
+			}
+		}
+	}
+	e.sent = append(e.sent, now)
+	return nil
+}
+
+// notional sums gross notional on action's venue and overall. Symbols
+// without a mark fall back to the action's limit price for its own symbol
+// and are an error otherwise.
+func (e *Engine) notional(exp map[exposureKey]float64, action transport.Action) (venue, account float64, err error) {
+	for k, q := range exp {
+		if q == 0 {
+			continue
+		}
+		px, ok := e.marks(k.venue, k.symbol)
+		if !ok && k.symbol == action.Symbol && action.Price > 0 {
+			px, ok = action.Price, true
+		}
+		if !ok {
+			return 0, 0, fmt.Errorf("%w: no mark for %s", ErrLimit, k.symbol)
+		}
+		n := e.value(k.symbol, math.Abs(q)*px)
+		account += n
+		if k.venue == action.Venue {
+			venue += n
+		}
+	}
+	return venue, account, nil
+}
+
+func (e *Engine) checkRate(now time.Time) error {
+	l := e.limits
+	cut := now.Add(-time.Minute)
+	i := 0
+	for i < len(e.sent) && !e.sent[i].After(cut) {
+		i++
+	}
+	e.sent = e.sent[i:]
+	if l.MaxOrdersPerMin > 0 && len(e.sent) >= l.MaxOrdersPerMin {
+		return fmt.Errorf("%w: %d orders in the last minute (max %d)", ErrLimit, len(e.sent), l.MaxOrdersPerMin)
+	}
+	if l.MaxOrdersPerSec > 0 {
+		n := 0
+		for _, t := range e.sent {
+			if t.After(now.Add(-time.Second)) {
+				n++
+			}
+		}
+		if n >= l.MaxOrdersPerSec {
+			return fmt.Errorf("%w: %d orders in the last second (max %d)", ErrLimit, n, l.MaxOrdersPerSec)
+		}
+	}
+	return nil
+}
+
+func (e *Engine) refuse(reason string, err error) error {
+	e.refused[reason]++
+	return err
+}
+
+// Evaluate marks positions to now, rolls the day at UTC midnight and arms
+// the kill switch when the day's loss passes its limit. It publishes only
+// when the breach changes; call it periodically.
+func (e *Engine) Evaluate(now time.Time) transport.RiskState {
+	return e.evaluate(now, false)
+}
+
+// Publish evaluates and always publishes; call it after fills.
+func (e *Engine) Publish(now time.Time) transport.RiskState {
+	return e.evaluate(now, true)
+}
+
+func (e *Engine) evaluate(now time.Time, publish bool) transport.RiskState {
+	e.mu.Lock()
+	prev := e.state
+	st := transport.RiskState{TsMs: now.UnixMilli(), Breach: prev.Breach}
+	if e.rates != nil {
+		st.Base = e.rates.Base
+	}
+	for _, p := range e.positions() {
+		st.Realized += e.value(p.Symbol, p.Realized)
+		if p.Qty == 0 {
+			continue
+		}
+		if px, ok := e.marks(p.Venue, p.Symbol); ok {
+			st.Unrealized += e.value(p.Symbol, (px-p.AvgPrice)*p.Qty)
+			st.GrossNotional += e.value(p.Symbol, math.Abs(p.Qty)*px)
+		} else {
+			st.GrossNotional += e.value(p.Symbol, math.Abs(p.Qty)*p.AvgPrice)
+		}
+	}
+	pnl := st.Realized + st.Unrealized
+	if day := now.UTC().Truncate(24 * time.Hour); !day.Equal(e.day) {
+		e.day, e.dayStart = day, pnl
+		st.Breach = ""
+	}
+	st.DailyPnL = pnl - e.dayStart
+	trip := false
+	if e.limits.MaxDailyLoss > 0 && st.Breach == "" && st.DailyPnL < -e.limits.MaxDailyLoss {
+		st.Breach = BreachDailyLoss
+		trip = true
+	}
+	e.state = st
+	e.mu.Unlock()
+
+	if trip && e.kill != nil {
+		e.kill.ArmKillSwitch(fmt.Sprintf("risk: daily PnL %.2f below -%g", st.DailyPnL, e.limits.MaxDailyLoss))
+	}
+	if e.pub != nil && (publish || st.Breach != prev.Breach) {
+		e.pub.PublishRisk(st)
+	}
+	return st
+}
+
+// State is the last evaluation.
+func (e *Engine) State() transport.RiskState {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.state
+}
+
+// RegisterMetrics exposes PnL, gross notional, the breach flag and
+// refusals by limit.
+func (e *Engine) RegisterMetrics(reg *metrics.Registry) {
+	reg.GaugeFunc("helix_risk_daily_pnl", "PnL since the start of the UTC day, realized plus marked, in the base currency.", func() float64 {
+		return e.State().DailyPnL
+	})
+	reg.GaugeFunc("helix_risk_gross_notional", "Gross notional of filled positions at their marks.", func() float64 {
+		return e.State().GrossNotional
+	})
+	reg.GaugeFunc("helix_risk_breached", "1 once a risk limit armed the kill switch today.", func() float64 {
+		if e.State().Breach != "" {
+			return 1
+		}
+		return 0
+	})
+	reg.Collect("helix_risk_refused_total", "Actions the risk engine refused, by limit.", "counter", func(emit metrics.Emit) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		for reason, n := range e.refused {
+			emit("", metrics.L("limit", reason), float64(n))
+		}
+	})
+}
+
+func signed(side string, qty float64) float64 {
+	if side == "SELL" {
+		return -qty
+	}
+	return qty
+}
@@ -2,6 +2,9 @@ package router
 
 type FeeModel struct {
 	Taker map[string]float64
+	// Maker fees only apply to simulated passive fills; routing always
+	// prices at the taker fee.
+	Maker map[string]float64
 }
 
 func DefaultFees() FeeModel {
@@ -10,6 +13,10 @@ func DefaultFees() FeeModel {
 			"BYBIT":   0.0006,
 			"BINANCE": 0.0005,
 		},
+		Maker: map[string]float64{
+			"BYBIT":   0.0002,
+			"BINANCE": 0.0002,
+		},
 	}
 }
 
@@ -3,58 +3,113 @@ package router
 import (
 	"math"
 
+	"github.com/helix-lab/helix/gateway/pkg/decimal"
+	"github.com/helix-lab/helix/gateway/pkg/metrics"
 	"github.com/helix-lab/helix/gateway/pkg/transport"
 )
 
+var decisions = metrics.Default.CounterVec("helix_routing_decisions_total", "Venue selections by chosen venue and side.", "venue", "side")
+
 type BookView struct {
 	BestBid float64
 	BestAsk float64
+	// Category is the book's market; an action with a Category only
+	// routes to views in the same one, or views that leave it unset.
+	Category string
 }
 
 type SmartRouter struct {
-	fees FeeModel
+	fees      FeeModel
+	available func(venue string) bool
+	inventory func(venue string, action transport.Action, price float64) bool
+	specs     func(venue string, action transport.Action, price float64) bool
 }
 
 func NewSmartRouter(fees FeeModel) *SmartRouter {
 	return &SmartRouter{fees: fees}
 }
 
+// SetAvailability installs a venue filter; venues it rejects (for example
+// ones in announced maintenance) are never routed to.
+func (r *SmartRouter) SetAvailability(f func(venue string) bool) {
+	r.available = f
+}
+
+// SetInventory installs a holdings check: a venue is skipped for action
+// when f says its balances can't cover it at price (the book's raw side).
+func (r *SmartRouter) SetInventory(f func(venue string, action transport.Action, price float64) bool) {
+	r.inventory = f
+}
+
+// SetInstruments installs an instrument check: a venue is skipped for
+// action when f says its instrument is not trading or action breaks its
+// spec at price (the book's raw side).
+func (r *SmartRouter) SetInstruments(f func(venue string, action transport.Action, price float64) bool) {
+	r.specs = f
+}
+
+func (r *SmartRouter) usable(venue string) bool {
+	return r.available == nil || r.available(venue)
+}
+
+func (r *SmartRouter) covers(venue string, action transport.Action, price float64) bool {
+	return r.inventory == nil || r.inventory(venue, action, price)
+}
+
+func (r *SmartRouter) fits(venue string, action transport.Action, price float64) bool {
+	return r.specs == nil || r.specs(venue, action, price)
+}
+
+func compatible(action transport.Action, book BookView) bool {
+	return action.Category == "" || book.Category == "" || action.Category == book.Category
+}
+
+// Decision is a routing outcome with the fee-adjusted price of every
+// usable venue that was considered.
+type Decision struct {
+	Venue  string
+	Price  float64
+	Prices map[string]float64
+}
+
 // Route selects the venue with the best adjusted price for the desired side.
 func (r *SmartRouter) Route(action transport.Action, books map[string]BookView) string {
-	if len(books) == 0 {
-		return "SIM"
-	}
+	return r.Decide(action, books).Venue
+}
 
+// Decide is Route with its reasoning. With no usable venue, or an unknown
+// side, the venue is "SIM". Prices compare as decimals and ties go to the
+// first venue by name, so equal books route the same way every time.
+func (r *SmartRouter) Decide(action transport.Action, books map[string]BookView) Decision {
+	d := Decision{Venue: "SIM", Prices: make(map[string]float64, len(books))}
 	switch action.Side {
 	case "BUY":
-		bestVenue := ""
-		bestPrice := math.MaxFloat64
+		best := math.MaxFloat64
 		for venue, book := range books {
+			if !compatible(action, book) || !r.usable(venue) || !r.covers(venue, action, book.BestAsk) || !r.fits(venue, action, book.BestAsk) {
+				continue
+			}
 			ask := r.fees.ApplyAsk(venue, book.BestAsk)
-			if ask < bestPrice {
-				bestPrice = ask
-				bestVenue = venue
+			d.Prices[venue] = ask
+			if c := decimal.Cmp(ask, best); c < 0 || (c == 0 && venue < d.Venue) {
+				best = ask
+				d.Venue, d.Price = venue, ask
 			}
 		}
-		if bestVenue == "" {
-			bestVenue = "SIM"
-		}
-		return bestVenue
 	case "SELL":
-		bestVenue := ""
-		bestPrice := 0.0
+		best := 0.0
 		for venue, book := range books {
+			if !compatible(action, book) || !r.usable(venue) || !r.covers(venue, action, book.BestBid) || !r.fits(venue, action, book.BestBid) {
+				continue
+			}
 			bid := r.fees.ApplyBid(venue, book.BestBid)
-			if bid > bestPrice {
-				bestPrice = bid
-				bestVenue = venue
+			d.Prices[venue] = bid
+			if c := decimal.Cmp(bid, best); c > 0 || (c == 0 && best > 0 && venue < d.Venue) {
+				best = bid
+				d.Venue, d.Price = venue, bid
 			}
 		}
-		if bestVenue == "" {
-			bestVenue = "SIM"
-		}
-		return bestVenue
-	default:
-		return "SIM"
 	}
+	decisions.With(d.Venue, action.Side).Inc()
+	return d
 }
@@ -0,0 +1,101 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// MasterKeyEnv holds the base64 AES-256 key for sealed credential files;
+// MasterKeyFileEnv names a file containing it instead.
+const (
+	MasterKeyEnv     = "HELIX_SECRETS_KEY"
+	MasterKeyFileEnv = "HELIX_SECRETS_KEY_FILE"
+)
+
+type sealedFile struct {
+	Version    int    `json:"version"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// NewKey returns a random master key, base64-encoded.
+func NewKey() (string, error) {
+	k := make([]byte, 32)
+	if _, err := rand.Read(k); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(k), nil
+}
+
+// MasterKey reads the master key from the environment.
+func MasterKey() ([]byte, error) {
+	enc := os.Getenv(MasterKeyEnv)
+	if enc == "" {
+		if path := os.Getenv(MasterKeyFileEnv); path != "" {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			enc = string(b)
+		}
+	}
+	if enc == "" {
+		return nil, fmt.Errorf("no master key: set %s or %s", MasterKeyEnv, MasterKeyFileEnv)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(enc))
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("master key must be 32 bytes, base64-encoded")
+	}
+	return key, nil
+}
+
+// Seal encrypts plain with AES-256-GCM under key.
+func Seal(plain, key []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(sealedFile{Version: 1, Nonce: nonce, Ciphertext: gcm.Seal(nil, nonce, plain, nil)}, "", "  ")
+}
+
+// Open decrypts data produced by Seal.
+func Open(data, key []byte) ([]byte, error) {
+	var f sealedFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("not a sealed credentials file: %w", err)
+	}
+	if f.Version != 1 {
+		return nil, fmt.Errorf("unsupported sealed file version %d", f.Version)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(f.Nonce) != gcm.NonceSize() {
+		return nil, errors.New("bad nonce")
+	}
+	plain, err := gcm.Open(nil, f.Nonce, f.Ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("decryption failed (wrong master key?)")
+	}
+	return plain, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,203 @@
+// Package secrets resolves API credentials from references such as
+// "env:HELIX_BYBIT", "file:/etc/helix/keys.enc#main" or
+// "cmd:pass show helix/bybit", so keys never sit in config files or on the
+// command line.
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Credentials are one account's API key pair. They print redacted.
+type Credentials struct {
+	Key        string `json:"api_key"`
+	Secret     string `json:"api_secret"`
+	Passphrase string `json:"passphrase,omitempty"`
+}
+
+func (c Credentials) String() string {
+	if c.Key == "" {
+		return "<no credentials>"
+	}
+	k := c.Key
+	if len(k) > 4 {
+		k = k[:4]
+	}
+	return fmt.Sprintf("key=%s… secret=<redacted>", k)
+}
+
+func (c Credentials) GoString() string { return "secrets.Credentials{" + c.String() + "}" }
+
+func (c Credentials) validate() error {
+	if c.Key == "" || c.Secret == "" {
+		return errors.New("key or secret is empty")
+	}
+	return nil
+}
+
+// Provider looks up the credentials for an account ("" = default).
+type Provider interface {
+	Credentials(ctx context.Context, account string) (Credentials, error)
+}
+
+// Parse turns a reference into its provider:
+//
+//	env:PREFIX          PREFIX_API_KEY, PREFIX_API_SECRET, PREFIX_API_PASSPHRASE
+//	file:/path[#acct]   encrypted file sealed with Seal; acct overrides the account
+//	cmd:prog args...    prog prints {"api_key":..,"api_secret":..} on stdout
+func Parse(ref string) (Provider, error) {
+	scheme, rest, ok := strings.Cut(ref, ":")
+	if !ok || rest == "" {
+		return nil, fmt.Errorf("credential reference %q: want env:, file: or cmd:", ref)
+	}
+	switch scheme {
+	case "env":
+		return Env{Prefix: rest}, nil
+	case "file":
+		path, acct, _ := strings.Cut(rest, "#")
+		return &File{Path: path, Account: acct}, nil
+	case "cmd":
+		argv := strings.Fields(rest)
+		return &Command{Argv: argv}, nil
+	}
+	return nil, fmt.Errorf("credential reference %q: unknown scheme %q", ref, scheme)
+}
+
+// Resolve parses ref and fetches account's credentials from it.
+func Resolve(ctx context.Context, ref, account string) (Credentials, error) {
+	p, err := Parse(ref)
+	if err != nil {
+		return Credentials{}, err
+	}
+	c, err := p.Credentials(ctx, account)
+	if err != nil {
+		scheme, _, _ := strings.Cut(ref, ":")
+		return Credentials{}, fmt.Errorf("%s credentials: %w", scheme, err)
+	}
+	return c, nil
+}
+
+// Env reads PREFIX_API_KEY and PREFIX_API_SECRET. A non-default account
+// reads PREFIX_<ACCOUNT>_API_KEY and so on.
+type Env struct {
+	Prefix string
+}
+
+func (e Env) Credentials(_ context.Context, account string) (Credentials, error) {
+	p := e.Prefix
+	if account != "" {
+		p += "_" + strings.ToUpper(account)
+	}
+	c := Credentials{
+		Key:        os.Getenv(p + "_API_KEY"),
+		Secret:     os.Getenv(p + "_API_SECRET"),
+		Passphrase: os.Getenv(p + "_API_PASSPHRASE"),
+	}
+	if err := c.validate(); err != nil {
+		return c, fmt.Errorf("%s_API_KEY/%s_API_SECRET: %w", p, p, err)
+	}
+	return c, nil
+}
+
+// File is an encrypted JSON file mapping account names to credentials,
+// sealed with the master key from MasterKey (HELIX_SECRETS_KEY).
+type File struct {
+	Path string
+	// Account, when set, is used instead of the requested one.
+	Account string
+	// Key is the master key; nil reads it with MasterKey.
+	Key []byte
+}
+
+func (f *File) Credentials(_ context.Context, account string) (Credentials, error) {
+	key := f.Key
+	if key == nil {
+		var err error
+		if key, err = MasterKey(); err != nil {
+			return Credentials{}, err
+		}
+	}
+	sealed, err := os.ReadFile(f.Path)
+	if err != nil {
+		return Credentials{}, err
+	}
+	plain, err := Open(sealed, key)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("%s: %w", f.Path, err)
+	}
+	var accounts map[string]Credentials
+	if err := json.Unmarshal(plain, &accounts); err != nil {
+		return Credentials{}, fmt.Errorf("%s: %w", f.Path, err)
+	}
+	if f.Account != "" {
+		account = f.Account
+	}
+	if account == "" {
+		account = "default"
+	}
+	c, ok := accounts[account]
+	if !ok {
+		return Credentials{}, fmt.Errorf("%s: no account %q", f.Path, account)
+	}
+	if err := c.validate(); err != nil {
+		return c, fmt.Errorf("%s account %s: %w", f.Path, account, err)
+	}
+	return c, nil
+}
+
+// Command runs an external program (a password manager, vault CLI...) and
+// reads credentials as JSON from its stdout. The account is passed in
+// HELIX_ACCOUNT, never as an argument.
+type Command struct {
+	Argv    []string
+	Timeout time.Duration
+}
+
+func (c *Command) Credentials(ctx context.Context, account string) (Credentials, error) {
+	if len(c.Argv) == 0 {
+		return Credentials{}, errors.New("empty command")
+	}
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, c.Argv[0], c.Argv[1:]...)
+	cmd.Env = append(os.Environ(), "HELIX_ACCOUNT="+account)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return Credentials{}, fmt.Errorf("%s: %v: %s", c.Argv[0], err, strings.TrimSpace(stderr.String()))
+	}
+	var cred Credentials
+	if err := json.Unmarshal(stdout.Bytes(), &cred); err != nil {
+		return Credentials{}, fmt.Errorf("%s: output is not credentials JSON", c.Argv[0])
+	}
+	if err := cred.validate(); err != nil {
+		return cred, fmt.Errorf("%s: %w", c.Argv[0], err)
+	}
+	return cred, nil
+}
+
+// TokenFile reads a bearer token from path, trimmed of surrounding
+// whitespace, so it stays off the command line.
+func TokenFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	tok := strings.TrimSpace(string(b))
+	if tok == "" {
+		return "", fmt.Errorf("%s: token is empty", path)
+	}
+	return tok, nil
+}
@@ -0,0 +1,310 @@
+// Package shadow runs candidate strategy versions alongside the production
+// strategies they would replace: on the same live market data, but filled
+// by a paper executor of their own. Every decision of both is recorded, and
+// Report compares each candidate with its production strategy.
+package shadow
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/router"
+	"github.com/helix-lab/helix/gateway/pkg/strategy"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// Runs a Decision can come from.
+const (
+	RunProduction = "production"
+	RunShadow     = "shadow"
+)
+
+// DefaultWindow is how far apart a production and a candidate decision
+// may be and still match.
+const DefaultWindow = time.Second
+
+// Decision is one routed action of a production strategy or a candidate;
+// Err is why it was refused, if it was.
+type Decision struct {
+	TsNs     int64  `json:"ts_ns"`
+	Run      string `json:"run"`
+	Strategy string `json:"strategy"`
+	// Pair is the production strategy the decision is compared under.
+	Pair    string  `json:"pair"`
+	Symbol  string  `json:"symbol"`
+	Side    string  `json:"side"`
+	Size    float64 `json:"size"`
+	Venue   string  `json:"venue"`
+	Price   float64 `json:"price"`
+	OrderID string  `json:"order_id,omitempty"`
+	Err     string  `json:"err,omitempty"`
+}
+
+// Divergence compares one candidate with its production strategy. A
+// decision matches one of the other side's on the same symbol and side
+// within the runner's Window; refused decisions are not matched.
+type Divergence struct {
+	Production        string `json:"production"`
+	Candidate         string `json:"candidate"`
+	ProductionOrders  int    `json:"production_orders"`
+	CandidateOrders   int    `json:"candidate_orders"`
+	ProductionRefused int    `json:"production_refused"`
+	CandidateRefused  int    `json:"candidate_refused"`
+	Matched           int    `json:"matched"`
+	OnlyProduction    int    `json:"only_production"`
+	OnlyCandidate     int    `json:"only_candidate"`
+	// MatchRate is Matched over the orders of whichever side sent more
+	// (1 when neither sent any).
+	MatchRate float64 `json:"match_rate"`
+	// SizeDiff totals the size differences of matched decisions; MeanLagMs
+	// is how much later than production the candidate decided, on average.
+	SizeDiff  float64 `json:"size_diff"`
+	MeanLagMs float64 `json:"mean_lag_ms"`
+	// FirstDivergenceNs is when the first unmatched decision was made.
+	FirstDivergenceNs int64 `json:"first_divergence_ns,omitempty"`
+	// The PnLs are realized plus unrealized, the production one from its
+	// live fills and the candidate's from paper fills.
+	ProductionPnL float64 `json:"production_pnl"`
+	CandidatePnL  float64 `json:"candidate_pnl"`
+}
+
+// Runner hosts the candidates. Feed it the market data the production
+// host gets, and add it to the production sender's journals so it sees
+// production's decisions; its own sender journals to it too.
+type Runner struct {
+	// Window is how far apart matching decisions may be (DefaultWindow
+	// when zero). Set it before the first decision.
+	Window time.Duration
+
+	host  *strategy.Host
+	paper *executor.Paper
+
+	mu    sync.Mutex
+	out   *bufio.Writer
+	enc   *json.Encoder
+	pairs []*pair
+	// by strategy name, production and candidate alike
+	byName map[string][]*pair
+}
+
+type pair struct {
+	div Divergence
+	// unmatched decisions still inside the window, production first
+	pending [2][]Decision
+	lagNs   int64
+}
+
+// New builds a runner whose candidates trade against books through a
+// paper executor, routed with fees and checked against limits.
+func New(books *orderbook.Manager, fees router.FeeModel, limits executor.Limits) *Runner {
+	r := &Runner{paper: executor.NewPaper(books), byName: make(map[string][]*pair)}
+	tracker := executor.NewTracker()
+	sender := executor.NewOrderSender(transport.NewPublisher("shadow"), router.NewSmartRouter(fees))
+	sender.SetTracker(tracker)
+	sender.SetLimits(limits)
+	sender.SetSink(r.paper)
+	sender.SetJournal(r)
+	r.host = strategy.NewHost(books, sender, tracker)
+	return r
+}
+
+// Record writes every decision, as a JSON line, to w.
+func (r *Runner) Record(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.out = bufio.NewWriter(w)
+	r.enc = json.NewEncoder(r.out)
+}
+
+// Add hosts candidate s, shadowing the production strategy named of.
+func (r *Runner) Add(of string, cfg strategy.Config, s strategy.Strategy) {
+	r.host.Add(cfg, s)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p := &pair{div: Divergence{Production: of, Candidate: cfg.Name}}
+	r.pairs = append(r.pairs, p)
+	r.byName[of] = append(r.byName[of], p)
+	r.byName[cfg.Name] = append(r.byName[cfg.Name], p)
+}
+
+// Host is the candidates' strategy host.
+func (r *Runner) Host() *strategy.Host { return r.host }
+
+// Interval is how often Timer wants calling; 0 for never.
+func (r *Runner) Interval() time.Duration { return r.host.Interval() }
+
+func (r *Runner) Depth(ctx context.Context, u transport.DepthUpdate) {
+	r.paper.Depth(u)
+	r.host.Book(ctx, u)
+}
+
+func (r *Runner) Trade(ctx context.Context, t transport.Trade) {
+	r.paper.Trade(t)
+	r.host.Trade(ctx, t)
+}
+
+func (r *Runner) Bar(ctx context.Context, b transport.Bar) { r.host.Bar(ctx, b) }
+
+func (r *Runner) BookEvent(ctx context.Context, ev transport.BookEvent) {
+	r.host.BookEvent(ctx, ev)
+}
+
+func (r *Runner) Timer(ctx context.Context, now time.Time) { r.host.Timer(ctx, now) }
+
+// Ready signals paper fills waiting for Fills.
+func (r *Runner) Ready() <-chan struct{} { return r.paper.Ready() }
+
+// Fills hands the paper executor's fills to the candidates.
+func (r *Runner) Fills(ctx context.Context) {
+	for _, f := range r.paper.TakeFills() {
+		_ = r.host.Fill(ctx, f)
+	}
+}
+
+// Order and Fill make the runner an executor.Journal; only routes matter.
+func (r *Runner) Order(executor.Order)                              {}
+func (r *Runner) Fill(transport.Fill, executor.Position, time.Time) {}
+
+// Route records d if it is a production strategy's or a candidate's.
+func (r *Runner) Route(d transport.RouteDecision, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pairs := r.byName[d.Account]
+	if len(pairs) == 0 {
+		return
+	}
+	dec := Decision{TsNs: d.TsNs, Run: RunShadow, Strategy: d.Account, Pair: pairs[0].div.Production, Symbol: d.Symbol,
+		Side: d.Side, Size: d.Size, Venue: d.Venue, Price: d.Price, OrderID: d.OrderID}
+	if d.Account == dec.Pair {
+		dec.Run = RunProduction
+	}
+	if err != nil {
+		dec.Err = err.Error()
+	}
+	if r.enc != nil {
+		_ = r.enc.Encode(dec)
+	}
+	for _, p := range pairs {
+		p.add(dec, r.window())
+	}
+}
+
+func (r *Runner) window() time.Duration {
+	if r.Window > 0 {
+		return r.Window
+	}
+	return DefaultWindow
+}
+
+// Report compares each candidate with its production strategy, whose
+// statuses prod supplies the production PnL. Decisions still waiting for
+// a match count as unmatched.
+func (r *Runner) Report(prod []strategy.Status) []Divergence {
+	pnl := map[string]float64{}
+	for _, list := range [][]strategy.Status{prod, r.host.Status()} {
+		for _, st := range list {
+			pnl[st.Name] = st.Realized + st.Unrealized
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]Divergence, 0, len(r.pairs))
+	for _, p := range r.pairs {
+		d := p.div
+		for run, pending := range p.pending {
+			for _, dec := range pending {
+				p.unmatched(&d, run, dec)
+			}
+		}
+		if most := max(d.ProductionOrders, d.CandidateOrders); most > 0 {
+			d.MatchRate = float64(d.Matched) / float64(most)
+		} else {
+			d.MatchRate = 1
+		}
+		if d.Matched > 0 {
+			d.MeanLagMs = float64(p.lagNs) / float64(d.Matched) / 1e6
+		}
+		d.ProductionPnL, d.CandidatePnL = pnl[d.Production], pnl[d.Candidate]
+		out = append(out, d)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Production < out[j].Production })
+	return out
+}
+
+// Close flushes the recorded decisions.
+func (r *Runner) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.out == nil {
+		return nil
+	}
+	return r.out.Flush()
+}
+
+// add matches dec against the other run's pending decisions, after
+// expiring those too old to match it.
+func (p *pair) add(dec Decision, window time.Duration) {
+	run := 0
+	if dec.Run == RunShadow {
+		run = 1
+	}
+	if dec.Err != "" {
+		if run == 0 {
+			p.div.ProductionRefused++
+		} else {
+			p.div.CandidateRefused++
+		}
+		return
+	}
+	if run == 0 {
+		p.div.ProductionOrders++
+	} else {
+		p.div.CandidateOrders++
+	}
+	cut := dec.TsNs - int64(window)
+	for r := range p.pending {
+		keep := p.pending[r][:0]
+		for _, old := range p.pending[r] {
+			if old.TsNs < cut {
+				p.unmatched(&p.div, r, old)
+			} else {
+				keep = append(keep, old)
+			}
+		}
+		p.pending[r] = keep
+	}
+	other := p.pending[1-run]
+	for i, o := range other {
+		if o.Symbol == dec.Symbol && o.Side == dec.Side {
+			p.div.Matched++
+			p.div.SizeDiff += math.Abs(o.Size - dec.Size)
+			if run == 0 {
+				p.lagNs += o.TsNs - dec.TsNs
+			} else {
+				p.lagNs += dec.TsNs - o.TsNs
+			}
+			p.pending[1-run] = append(other[:i], other[i+1:]...)
+			return
+		}
+	}
+	p.pending[run] = append(p.pending[run], dec)
+}
+
+func (p *pair) unmatched(d *Divergence, run int, dec Decision) {
+	if run == 0 {
+		d.OnlyProduction++
+	} else {
+		d.OnlyCandidate++
+	}
+	if d.FirstDivergenceNs == 0 || dec.TsNs < d.FirstDivergenceNs {
+		d.FirstDivergenceNs = dec.TsNs
+	}
+}
@@ -0,0 +1,110 @@
+// Package state persists what the gateway needs for a warm restart: the
+// last books, open orders, positions and symbol subscriptions. Files are
+// replaced atomically so a crash mid-write leaves the previous state.
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/metrics"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+)
+
+// Version is bumped whenever State changes incompatibly.
+const Version = 1
+
+var (
+	saves      = metrics.Default.CounterVec("helix_gateway_state_saves_total", "State snapshots written, by result.", "result")
+	lastSaveTs = metrics.Default.Gauge("helix_gateway_state_last_save_timestamp_seconds", "Unix time of the last state snapshot written.")
+)
+
+type State struct {
+	Version       int                 `json:"version"`
+	SavedAt       time.Time           `json:"saved_at"`
+	Books         []orderbook.Book    `json:"books"`
+	Orders        []executor.Order    `json:"orders"`
+	Positions     []executor.Position `json:"positions"`
+	Subscriptions map[string][]string `json:"subscriptions,omitempty"`
+}
+
+// Save writes st to path through a synced temporary file and a rename.
+func Save(path string, st State) error {
+	st.Version = Version
+	if st.SavedAt.IsZero() {
+		st.SavedAt = time.Now()
+	}
+	raw, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load reads a state file written by Save. A missing file returns an error
+// matching fs.ErrNotExist.
+func Load(path string) (State, error) {
+	var st State
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return st, err
+	}
+	if err := json.Unmarshal(raw, &st); err != nil {
+		return st, fmt.Errorf("%s: %w", path, err)
+	}
+	if st.Version != Version {
+		return st, fmt.Errorf("%s: state version %d, want %d", path, st.Version, Version)
+	}
+	return st, nil
+}
+
+// Age is how long ago st was saved.
+func (st State) Age() time.Duration { return time.Since(st.SavedAt) }
+
+// Run saves capture() to path every interval until ctx is done, then once
+// more so a clean shutdown leaves the latest state.
+func Run(ctx context.Context, path string, every time.Duration, capture func() State, logf func(string, ...any)) {
+	save := func() {
+		if err := Save(path, capture()); err != nil {
+			saves.With("error").Inc()
+			if logf != nil {
+				logf("state: save %s: %v", path, err)
+			}
+			return
+		}
+		saves.With("ok").Inc()
+		lastSaveTs.Set(float64(time.Now().Unix()))
+	}
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			save()
+			return
+		case <-t.C:
+			save()
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package stratapi
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/strategy"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// Guard applies one external client's symbol list and Limits to its
+// orders and keeps the filled position they are measured against, with its
+// open orders on top. Other
+// client-facing APIs (e.g. FIX) share it; it is used from the gateway loop
+// only.
+type Guard struct {
+	name    string
+	symbols []string
+	limits  Limits
+	pos     map[string]float64
+	sent    []time.Time
+}
+
+func NewGuard(name string, symbols []string, limits Limits) *Guard {
+	return &Guard{name: name, symbols: symbols, limits: limits, pos: map[string]float64{}}
+}
+
+// Fill adds f to the client's position and returns the position in
+// f.Symbol afterwards.
+func (g *Guard) Fill(f transport.Fill) float64 {
+	g.pos[f.Symbol] += signed(f.Side, f.Qty)
+	return g.pos[f.Symbol]
+}
+
+// Check vets a at now; an action it passes counts toward the order rate.
+// h prices market orders for MaxNotional; open is the client's open orders,
+// whose remaining size counts toward MaxPosition.
+func (g *Guard) Check(a transport.Action, h strategy.Handle, open []executor.Order, now time.Time) error {
+	if a.Side != "BUY" && a.Side != "SELL" {
+		return fmt.Errorf("side must be BUY or SELL, got %q", a.Side)
+	}
+	if !(a.Size > 0) || a.Price < 0 {
+		return fmt.Errorf("size must be positive and price not negative, got %g @ %g", a.Size, a.Price)
+	}
+	if len(g.symbols) > 0 && !contains(g.symbols, a.Symbol) {
+		return fmt.Errorf("client %s may not trade %s", g.name, a.Symbol)
+	}
+	lim := g.limits
+	if lim.MaxOrderSize > 0 && a.Size > lim.MaxOrderSize {
+		return fmt.Errorf("size %g over the client's max order size %g", a.Size, lim.MaxOrderSize)
+	}
+	if lim.MaxNotional > 0 {
+		px := a.Price
+		if px == 0 {
+			b, ok := h.Book(a.Symbol, a.Category)
+			if !ok {
+				return fmt.Errorf("no book for %s to price a market order", a.Symbol)
+			}
+			px = b.NBBO.BestAsk
+			if a.Side == "SELL" {
+				px = b.NBBO.BestBid
+			}
+		}
+		if n := px * a.Size; n > lim.MaxNotional {
+			return fmt.Errorf("notional %g over the client's max notional %g", n, lim.MaxNotional)
+		}
+	}
+	if lim.MaxPosition > 0 {
+		cur := g.pos[a.Symbol]
+		for _, o := range open {
+			if o.Symbol == a.Symbol {
+				cur += signed(o.Side, o.Remaining())
+			}
+		}
+		next := cur + signed(a.Side, a.Size)
+		if math.Abs(next) > lim.MaxPosition && math.Abs(next) > math.Abs(cur) {
+			return fmt.Errorf("position %g in %s would exceed the client's max position %g", next, a.Symbol, lim.MaxPosition)
+		}
+	}
+	if lim.MaxOrdersPerSec > 0 {
+		cut := now.Add(-time.Second)
+		i := 0
+		for i < len(g.sent) && !g.sent[i].After(cut) {
+			i++
+		}
+		g.sent = g.sent[i:]
+		if len(g.sent) >= lim.MaxOrdersPerSec {
+			return fmt.Errorf("over the client's %d orders per second", lim.MaxOrdersPerSec)
+		}
+	}
+	g.sent = append(g.sent, now)
+	return nil
+}
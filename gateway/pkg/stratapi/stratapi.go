@@ -0,0 +1,467 @@
+// Package stratapi lets strategies run in their own processes over gRPC.
+// A client opens the bidirectional Strategy.Stream of strategy.proto with
+// its bearer token and gets books, trades, bars and its own fills out;
+// its order submissions go in, each answered with an ack or a reject.
+//
+// Inside the gateway each client is a hosted strategy, so it sees the same
+// callbacks in the same order as embedded ones. Its submissions run on the
+// gateway loop and pass the client's own limits before the executor's.
+package stratapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/helix-lab/helix/gateway/pkg/mdapi"
+	"github.com/helix-lab/helix/gateway/pkg/metrics"
+	"github.com/helix-lab/helix/gateway/pkg/strategy"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// Method is the full name of the stream RPC.
+const Method = "/helix.strategy.v1.Strategy/Stream"
+
+// Message types.
+const (
+	TypeHello  = "hello"
+	TypeBook   = "book"
+	TypeTrade  = "trade"
+	TypeBar    = "bar"
+	TypeFill   = "fill"
+	TypeSubmit = "submit"
+	TypeAck    = "ack"
+	TypeReject = "reject"
+)
+
+// sendBuffer is how many frames may wait for a slow client. Market data
+// beyond it is dropped; a fill, ack or reject beyond it disconnects the
+// client, since it could not be replayed.
+const sendBuffer = 4096
+
+var (
+	connected = metrics.Default.GaugeVec("helix_strategy_api_connected",
+		"1 while the strategy API client is connected.", "client")
+	orders = metrics.Default.CounterVec("helix_strategy_api_orders_total",
+		"Strategy API submissions, by outcome (sent, rejected).", "client", "outcome")
+	dropped = metrics.Default.CounterVec("helix_strategy_api_dropped_total",
+		"Market data frames dropped because the strategy API client fell behind.", "client")
+)
+
+// Limits are checked per client before the executor's own; zero is
+// unlimited.
+type Limits struct {
+	MaxOrderSize float64
+	// MaxNotional caps size*price per order; market orders are priced at
+	// the NBBO side they would take.
+	MaxNotional float64
+	// MaxPosition caps the client's filled position per symbol; orders
+	// that reduce it are always allowed.
+	MaxPosition     float64
+	MaxOrdersPerSec int
+}
+
+// Client is one external strategy allowed to connect.
+type Client struct {
+	Name  string
+	Token string
+	// Symbols limits what it sees and trades (empty = all).
+	Symbols []string
+	Limits  Limits
+	// Quota is applied by the strategy host, like an embedded strategy's.
+	Quota strategy.Quota
+}
+
+// Message is one frame in either direction, strategy.proto's Message;
+// Type says which fields are set.
+type Message struct {
+	Type string
+	// Ref is the client's tag on a submit, echoed on its ack or reject.
+	Ref     string
+	OrderID string
+	Error   string
+
+	Client  string
+	Symbols []string
+	Order   *Order
+	Book    *mdapi.SymbolBooks
+	Trade   *mdapi.Trade
+	Bar     *mdapi.Bar
+	Fill    *Fill
+}
+
+// Order is a submission; Price 0 takes liquidity at market, an empty
+// Venue lets the router choose and Category keeps it to one market.
+type Order struct {
+	Symbol   string
+	Side     string
+	Size     float64
+	Price    float64
+	Venue    string
+	Category string
+}
+
+type Fill struct {
+	OrderID  string
+	Venue    string
+	Symbol   string
+	Category string
+	Side     string
+	Price    float64
+	Qty      float64
+	Maker    bool
+	// Position is the client's filled position in Symbol afterwards.
+	Position float64
+}
+
+// Server accepts client streams and queues their submissions for the
+// gateway loop.
+type Server struct {
+	remotes []*remote
+	submits chan Submit
+
+	grpc *grpc.Server
+	ln   net.Listener
+}
+
+// streamer is what serviceDesc is registered against.
+type streamer interface {
+	stream(ss grpc.ServerStream) error
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "helix.strategy.v1.Strategy",
+	HandlerType: (*streamer)(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Stream",
+		ServerStreams: true,
+		ClientStreams: true,
+		Handler: func(srv any, ss grpc.ServerStream) error {
+			return srv.(streamer).stream(ss)
+		},
+	}},
+	Metadata: "strategy.proto",
+}
+
+func New(clients []Client) (*Server, error) {
+	s := &Server{submits: make(chan Submit, 256)}
+	names := map[string]bool{}
+	for _, c := range clients {
+		switch {
+		case c.Name == "":
+			return nil, errors.New("strategy api: client without a name")
+		case names[c.Name]:
+			return nil, fmt.Errorf("strategy api: duplicate client %q", c.Name)
+		case c.Token == "":
+			return nil, fmt.Errorf("strategy api: client %s has no token", c.Name)
+		}
+		names[c.Name] = true
+		for _, r := range s.remotes {
+			if r.Token == c.Token {
+				return nil, fmt.Errorf("strategy api: clients %s and %s share a token", r.Name, c.Name)
+			}
+		}
+		s.remotes = append(s.remotes, &remote{Client: c, guard: NewGuard(c.Name, c.Symbols, c.Limits)})
+	}
+	return s, nil
+}
+
+// Strategies adds every client to a strategy host under its own name.
+func (s *Server) Strategies(add func(strategy.Config, strategy.Strategy)) {
+	for _, r := range s.remotes {
+		add(strategy.Config{Name: r.Name, Symbols: r.Symbols, Quota: r.Quota}, r)
+	}
+}
+
+// Submit is a client order waiting for Exec.
+type Submit struct {
+	r     *remote
+	sess  *session
+	ref   string
+	order Order
+}
+
+// Submits delivers client orders; pass each to Exec on the gateway loop.
+func (s *Server) Submits() <-chan Submit { return s.submits }
+
+// Exec checks sub against its client's limits, submits it through the
+// client's strategy handle and answers the client.
+func (s *Server) Exec(ctx context.Context, sub Submit, host *strategy.Host) {
+	id, err := sub.r.submit(ctx, sub.order, host, time.Now())
+	if err != nil {
+		orders.With(sub.r.Name, "rejected").Inc()
+		sub.sess.send(Message{Type: TypeReject, Ref: sub.ref, Error: err.Error()}, true)
+		return
+	}
+	orders.With(sub.r.Name, "sent").Inc()
+	sub.sess.send(Message{Type: TypeAck, Ref: sub.ref, OrderID: id}, true)
+}
+
+// Listen serves the API on addr, e.g. ":9879", until Close.
+func (s *Server) Listen(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("strategy api: %w", err)
+	}
+	s.ln = ln
+	s.grpc = grpc.NewServer(grpc.ForceServerCodec(codec{}))
+	s.grpc.RegisterService(&serviceDesc, s)
+	go func() { _ = s.grpc.Serve(ln) }()
+	return nil
+}
+
+// Addr is the listening address, once Listen has succeeded.
+func (s *Server) Addr() net.Addr {
+	if s.ln == nil {
+		return nil
+	}
+	return s.ln.Addr()
+}
+
+// Close ends every stream and stops listening.
+func (s *Server) Close() {
+	if s.grpc != nil {
+		s.grpc.Stop()
+	}
+}
+
+func (s *Server) client(ctx context.Context) *remote {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var got string
+	if v := md.Get("authorization"); len(v) > 0 {
+		got = strings.TrimPrefix(v[0], "Bearer ")
+	}
+	for _, rm := range s.remotes {
+		if subtle.ConstantTimeCompare([]byte(got), []byte(rm.Token)) == 1 {
+			return rm
+		}
+	}
+	return nil
+}
+
+func (s *Server) stream(ss grpc.ServerStream) error {
+	rm := s.client(ss.Context())
+	if rm == nil {
+		return status.Error(codes.Unauthenticated, "unknown token")
+	}
+	sess := &session{client: rm.Name, out: make(chan Message, sendBuffer), kicked: make(chan struct{})}
+	if !rm.attach(sess) {
+		return status.Errorf(codes.AlreadyExists, "client %s is already connected", rm.Name)
+	}
+	defer rm.detach(sess)
+	ctx, cancel := context.WithCancel(ss.Context())
+	defer cancel()
+	read := make(chan error, 1)
+	go func() { read <- s.read(ctx, ss, rm, sess) }()
+
+	sess.send(Message{Type: TypeHello, Client: rm.Name, Symbols: rm.Symbols}, true)
+	for {
+		select {
+		case m := <-sess.out:
+			if err := ss.SendMsg(&m); err != nil {
+				return err
+			}
+		case <-sess.kicked:
+			return status.Error(codes.ResourceExhausted, "slow consumer")
+		case err := <-read:
+			if err != nil {
+				return err
+			}
+			// the client is done submitting but may still listen
+			read = nil
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+// read queues the client's submissions until it closes its side.
+func (s *Server) read(ctx context.Context, ss grpc.ServerStream, rm *remote, sess *session) error {
+	for {
+		var m Message
+		if err := ss.RecvMsg(&m); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if m.Type != TypeSubmit || m.Order == nil {
+			sess.send(Message{Type: TypeReject, Ref: m.Ref, Error: fmt.Sprintf("expected a %s with an order, got %q", TypeSubmit, m.Type)}, true)
+			continue
+		}
+		select {
+		case s.submits <- Submit{r: rm, sess: sess, ref: m.Ref, order: *m.Order}:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// session is one stream. Frames are queued by the gateway loop and sent
+// by the stream's handler.
+type session struct {
+	client string
+	out    chan Message
+	kicked chan struct{}
+	once   sync.Once
+}
+
+// send queues m; must frames that don't fit disconnect the client.
+func (s *session) send(m Message, must bool) {
+	select {
+	case s.out <- m:
+		return
+	default:
+	}
+	if must {
+		s.once.Do(func() { close(s.kicked) })
+	} else {
+		dropped.With(s.client).Inc()
+	}
+}
+
+// Conn is a Go client's stream.
+type Conn struct {
+	cc     *grpc.ClientConn
+	stream grpc.ClientStream
+	cancel context.CancelFunc
+}
+
+// Dial opens a stream to the API at addr as the client token belongs to.
+// A refused token shows as the error of the first Recv.
+func Dial(ctx context.Context, addr, token string) (*Conn, error) {
+	cc, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(codec{})))
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token))
+	stream, err := cc.NewStream(ctx, &serviceDesc.Streams[0], Method)
+	if err != nil {
+		cancel()
+		cc.Close()
+		return nil, err
+	}
+	return &Conn{cc: cc, stream: stream, cancel: cancel}, nil
+}
+
+// Send writes m, e.g. a TypeSubmit with an Order.
+func (c *Conn) Send(m Message) error { return c.stream.SendMsg(&m) }
+
+// Recv returns the next frame from the gateway.
+func (c *Conn) Recv() (Message, error) {
+	var m Message
+	err := c.stream.RecvMsg(&m)
+	return m, err
+}
+
+func (c *Conn) Close() error {
+	c.cancel()
+	return c.cc.Close()
+}
+
+// remote is a client as the host sees it. Callbacks and submit run on the
+// gateway loop; sess is also swapped by connection handlers.
+type remote struct {
+	Client
+	guard *Guard
+
+	mu   sync.Mutex
+	sess *session
+}
+
+func (r *remote) attach(s *session) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sess != nil {
+		return false
+	}
+	r.sess = s
+	connected.With(r.Name).Set(1)
+	return true
+}
+
+func (r *remote) detach(s *session) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sess == s {
+		r.sess = nil
+		connected.With(r.Name).Set(0)
+	}
+}
+
+func (r *remote) send(m Message, must bool) {
+	r.mu.Lock()
+	s := r.sess
+	r.mu.Unlock()
+	if s != nil {
+		s.send(m, must)
+	}
+}
+
+func (r *remote) OnBook(_ context.Context, _ strategy.Handle, b strategy.Book) {
+	sb := mdapi.SymbolBooks{Symbol: b.Symbol, Category: b.Category, NBBO: mdapi.LevelOf(b.NBBO), Venues: make(map[string]mdapi.Level, len(b.Venues))}
+	for v, l := range b.Venues {
+		sb.Venues[v] = mdapi.LevelOf(l)
+	}
+	r.send(Message{Type: TypeBook, Book: &sb}, false)
+}
+
+func (r *remote) OnTrade(_ context.Context, _ strategy.Handle, t transport.Trade) {
+	mt := mdapi.TradeOf(t)
+	r.send(Message{Type: TypeTrade, Trade: &mt}, false)
+}
+
+func (r *remote) OnBar(_ context.Context, _ strategy.Handle, b transport.Bar) {
+	mb := mdapi.BarOf(b)
+	r.send(Message{Type: TypeBar, Bar: &mb}, false)
+}
+
+func (r *remote) OnFill(_ context.Context, _ strategy.Handle, f transport.Fill) {
+	pos := r.guard.Fill(f)
+	r.send(Message{Type: TypeFill, Fill: &Fill{OrderID: f.OrderID, Venue: f.Venue, Symbol: f.Symbol, Category: f.Category, Side: f.Side,
+		Price: f.Price, Qty: f.Qty, Maker: f.Maker, Position: pos}}, true)
+}
+
+func (r *remote) OnTimer(context.Context, strategy.Handle, time.Time) {}
+
+func (r *remote) submit(ctx context.Context, o Order, host *strategy.Host, now time.Time) (string, error) {
+	h, ok := host.Handle(r.Name)
+	if !ok {
+		return "", fmt.Errorf("client %s is not hosted", r.Name)
+	}
+	a := transport.Action{Symbol: strings.ToUpper(o.Symbol), Side: strings.ToUpper(o.Side), Size: o.Size,
+		Price: o.Price, Venue: strings.ToUpper(o.Venue), Category: strings.ToLower(o.Category)}
+	if err := r.guard.Check(a, h, host.OpenOrders(r.Name), now); err != nil {
+		return "", err
+	}
+	return h.Submit(ctx, a)
+}
+
+func signed(side string, qty float64) float64 {
+	if side == "SELL" {
+		return -qty
+	}
+	return qty
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
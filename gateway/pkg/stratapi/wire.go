@@ -0,0 +1,408 @@
+package stratapi
+
+import (
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/helix-lab/helix/gateway/pkg/mdapi"
+)
+
+// codec encodes Messages in the protobuf wire format of strategy.proto,
+// so stubs generated from it interoperate without generated Go code here.
+type codec struct{}
+
+func (codec) Name() string { return "proto" }
+
+func (codec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(*Message)
+	if !ok {
+		return nil, fmt.Errorf("stratapi: cannot encode %T", v)
+	}
+	var e enc
+	m.encode(&e)
+	return e.b, nil
+}
+
+func (codec) Unmarshal(b []byte, v any) error {
+	m, ok := v.(*Message)
+	if !ok {
+		return fmt.Errorf("stratapi: cannot decode into %T", v)
+	}
+	*m = Message{}
+	return m.decode(b)
+}
+
+// enc appends fields, leaving out zero values as proto3 does.
+type enc struct{ b []byte }
+
+func (e *enc) str(n protowire.Number, s string) {
+	if s != "" {
+		e.b = protowire.AppendTag(e.b, n, protowire.BytesType)
+		e.b = protowire.AppendString(e.b, s)
+	}
+}
+
+func (e *enc) f64(n protowire.Number, v float64) {
+	if v != 0 {
+		e.b = protowire.AppendTag(e.b, n, protowire.Fixed64Type)
+		e.b = protowire.AppendFixed64(e.b, math.Float64bits(v))
+	}
+}
+
+func (e *enc) i64(n protowire.Number, v int64) {
+	if v != 0 {
+		e.b = protowire.AppendTag(e.b, n, protowire.VarintType)
+		e.b = protowire.AppendVarint(e.b, uint64(v))
+	}
+}
+
+func (e *enc) boolean(n protowire.Number, v bool) {
+	if v {
+		e.b = protowire.AppendTag(e.b, n, protowire.VarintType)
+		e.b = protowire.AppendVarint(e.b, 1)
+	}
+}
+
+// msg appends the message body writes as field n, even when it is empty.
+func (e *enc) msg(n protowire.Number, body func(*enc)) {
+	var sub enc
+	body(&sub)
+	e.b = protowire.AppendTag(e.b, n, protowire.BytesType)
+	e.b = protowire.AppendBytes(e.b, sub.b)
+}
+
+// value is one decoded field. The accessors give the zero value for a
+// field sent with another wire type than strategy.proto's.
+type value struct {
+	typ protowire.Type
+	u   uint64
+	b   []byte
+}
+
+func (v value) str() string {
+	if v.typ != protowire.BytesType {
+		return ""
+	}
+	return string(v.b)
+}
+
+func (v value) f64() float64 {
+	if v.typ != protowire.Fixed64Type {
+		return 0
+	}
+	return math.Float64frombits(v.u)
+}
+
+func (v value) i64() int64 {
+	if v.typ != protowire.VarintType {
+		return 0
+	}
+	return int64(v.u)
+}
+
+func (v value) boolean() bool { return v.typ == protowire.VarintType && v.u != 0 }
+
+// walk calls field for each field of b in order; unknown fields are the
+// callback's to skip.
+func walk(b []byte, field func(protowire.Number, value) error) error {
+	for len(b) > 0 {
+		n, typ, k := protowire.ConsumeTag(b)
+		if k < 0 {
+			return protowire.ParseError(k)
+		}
+		b = b[k:]
+		v := value{typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			v.u, k = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			v.u, k = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			v.b, k = protowire.ConsumeBytes(b)
+		default:
+			k = protowire.ConsumeFieldValue(n, typ, b)
+		}
+		if k < 0 {
+			return protowire.ParseError(k)
+		}
+		b = b[k:]
+		if err := field(n, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Message) encode(e *enc) {
+	e.str(1, m.Type)
+	e.str(2, m.Ref)
+	e.str(3, m.OrderID)
+	e.str(4, m.Error)
+	e.str(5, m.Client)
+	for _, s := range m.Symbols {
+		e.b = protowire.AppendTag(e.b, 6, protowire.BytesType)
+		e.b = protowire.AppendString(e.b, s)
+	}
+	if o := m.Order; o != nil {
+		e.msg(7, func(e *enc) {
+			e.str(1, o.Symbol)
+			e.str(2, o.Side)
+			e.f64(3, o.Size)
+			e.f64(4, o.Price)
+			e.str(5, o.Venue)
+			e.str(6, o.Category)
+		})
+	}
+	if b := m.Book; b != nil {
+		e.msg(8, func(e *enc) {
+			e.str(1, b.Symbol)
+			e.msg(2, func(e *enc) { encodeLevel(e, b.NBBO) })
+			for venue, l := range b.Venues {
+				e.msg(3, func(e *enc) {
+					e.b = protowire.AppendTag(e.b, 1, protowire.BytesType)
+					e.b = protowire.AppendString(e.b, venue)
+					e.msg(2, func(e *enc) { encodeLevel(e, l) })
+				})
+			}
+			e.str(4, b.Category)
+		})
+	}
+	if t := m.Trade; t != nil {
+		e.msg(9, func(e *enc) {
+			e.i64(1, t.TsMs)
+			e.str(2, t.Venue)
+			e.str(3, t.Symbol)
+			e.str(4, t.Side)
+			e.f64(5, t.Price)
+			e.f64(6, t.Qty)
+		})
+	}
+	if b := m.Bar; b != nil {
+		e.msg(10, func(e *enc) {
+			e.i64(1, b.StartMs)
+			e.str(2, b.Interval)
+			e.str(3, b.Venue)
+			e.str(4, b.Symbol)
+			e.f64(5, b.Open)
+			e.f64(6, b.High)
+			e.f64(7, b.Low)
+			e.f64(8, b.Close)
+			e.f64(9, b.Volume)
+			e.f64(10, b.VWAP)
+			e.i64(11, int64(b.Trades))
+		})
+	}
+	if f := m.Fill; f != nil {
+		e.msg(11, func(e *enc) {
+			e.str(1, f.OrderID)
+			e.str(2, f.Venue)
+			e.str(3, f.Symbol)
+			e.str(4, f.Category)
+			e.str(5, f.Side)
+			e.f64(6, f.Price)
+			e.f64(7, f.Qty)
+			e.boolean(8, f.Maker)
+			e.f64(9, f.Position)
+		})
+	}
+}
+
+func encodeLevel(e *enc, l mdapi.Level) {
+	e.f64(1, l.BestBid)
+	e.f64(2, l.BestAsk)
+	e.f64(3, l.BidSize)
+	e.f64(4, l.AskSize)
+}
+
+func (m *Message) decode(b []byte) error {
+	return walk(b, func(n protowire.Number, v value) error {
+		switch n {
+		case 1:
+			m.Type = v.str()
+		case 2:
+			m.Ref = v.str()
+		case 3:
+			m.OrderID = v.str()
+		case 4:
+			m.Error = v.str()
+		case 5:
+			m.Client = v.str()
+		case 6:
+			m.Symbols = append(m.Symbols, v.str())
+		case 7:
+			if m.Order == nil {
+				m.Order = &Order{}
+			}
+			return decodeOrder(v.b, m.Order)
+		case 8:
+			if m.Book == nil {
+				m.Book = &mdapi.SymbolBooks{}
+			}
+			return decodeBook(v.b, m.Book)
+		case 9:
+			if m.Trade == nil {
+				m.Trade = &mdapi.Trade{}
+			}
+			return decodeTrade(v.b, m.Trade)
+		case 10:
+			if m.Bar == nil {
+				m.Bar = &mdapi.Bar{}
+			}
+			return decodeBar(v.b, m.Bar)
+		case 11:
+			if m.Fill == nil {
+				m.Fill = &Fill{}
+			}
+			return decodeFill(v.b, m.Fill)
+		}
+		return nil
+	})
+}
+
+func decodeOrder(b []byte, o *Order) error {
+	return walk(b, func(n protowire.Number, v value) error {
+		switch n {
+		case 1:
+			o.Symbol = v.str()
+		case 2:
+			o.Side = v.str()
+		case 3:
+			o.Size = v.f64()
+		case 4:
+			o.Price = v.f64()
+		case 5:
+			o.Venue = v.str()
+		case 6:
+			o.Category = v.str()
+		}
+		return nil
+	})
+}
+
+func decodeLevel(b []byte, l *mdapi.Level) error {
+	return walk(b, func(n protowire.Number, v value) error {
+		switch n {
+		case 1:
+			l.BestBid = v.f64()
+		case 2:
+			l.BestAsk = v.f64()
+		case 3:
+			l.BidSize = v.f64()
+		case 4:
+			l.AskSize = v.f64()
+		}
+		return nil
+	})
+}
+
+func decodeBook(b []byte, sb *mdapi.SymbolBooks) error {
+	return walk(b, func(n protowire.Number, v value) error {
+		switch n {
+		case 1:
+			sb.Symbol = v.str()
+		case 2:
+			return decodeLevel(v.b, &sb.NBBO)
+		case 3:
+			// a map entry: key 1, value 2
+			var venue string
+			var l mdapi.Level
+			err := walk(v.b, func(n protowire.Number, v value) error {
+				switch n {
+				case 1:
+					venue = v.str()
+				case 2:
+					return decodeLevel(v.b, &l)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if sb.Venues == nil {
+				sb.Venues = map[string]mdapi.Level{}
+			}
+			sb.Venues[venue] = l
+		case 4:
+			sb.Category = v.str()
+		}
+		return nil
+	})
+}
+
+func decodeTrade(b []byte, t *mdapi.Trade) error {
+	return walk(b, func(n protowire.Number, v value) error {
+		switch n {
+		case 1:
+			t.TsMs = v.i64()
+		case 2:
+			t.Venue = v.str()
+		case 3:
+			t.Symbol = v.str()
+		case 4:
+			t.Side = v.str()
+		case 5:
+			t.Price = v.f64()
+		case 6:
+			t.Qty = v.f64()
+		}
+		return nil
+	})
+}
+
+func decodeBar(b []byte, bar *mdapi.Bar) error {
+	return walk(b, func(n protowire.Number, v value) error {
+		switch n {
+		case 1:
+			bar.StartMs = v.i64()
+		case 2:
+			bar.Interval = v.str()
+		case 3:
+			bar.Venue = v.str()
+		case 4:
+			bar.Symbol = v.str()
+		case 5:
+			bar.Open = v.f64()
+		case 6:
+			bar.High = v.f64()
+		case 7:
+			bar.Low = v.f64()
+		case 8:
+			bar.Close = v.f64()
+		case 9:
+			bar.Volume = v.f64()
+		case 10:
+			bar.VWAP = v.f64()
+		case 11:
+			bar.Trades = int(v.i64())
+		}
+		return nil
+	})
+}
+
+func decodeFill(b []byte, f *Fill) error {
+	return walk(b, func(n protowire.Number, v value) error {
+		switch n {
+		case 1:
+			f.OrderID = v.str()
+		case 2:
+			f.Venue = v.str()
+		case 3:
+			f.Symbol = v.str()
+		case 4:
+			f.Category = v.str()
+		case 5:
+			f.Side = v.str()
+		case 6:
+			f.Price = v.f64()
+		case 7:
+			f.Qty = v.f64()
+		case 8:
+			f.Maker = v.boolean()
+		case 9:
+			f.Position = v.f64()
+		}
+		return nil
+	})
+}
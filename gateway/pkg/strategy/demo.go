@@ -0,0 +1,81 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+func init() {
+	Register("demo", func(cfg Config) (Strategy, error) {
+		d := &Demo{}
+		var err error
+		if d.Size, err = cfg.Float("size", 0.01); err != nil {
+			return nil, err
+		}
+		if d.Side, err = cfg.String("side", "BUY"); err != nil {
+			return nil, err
+		}
+		rounds, err := cfg.Float("rounds", 0)
+		if err != nil {
+			return nil, err
+		}
+		d.Rounds = int(rounds)
+		if d.Side != "BUY" && d.Side != "SELL" {
+			return nil, fmt.Errorf("strategy %s: side must be BUY or SELL", cfg.Name)
+		}
+		if d.Size <= 0 {
+			return nil, errors.New("strategy " + cfg.Name + ": size must be positive")
+		}
+		d.symbols = cfg.Symbols
+		return d, nil
+	})
+}
+
+// Demo sends a fixed-size order on every symbol with a book each timer
+// tick; it is the gateway's built-in demo.
+type Demo struct {
+	Base
+	Size float64
+	Side string
+	// Rounds stops the strategy after that many ticks (0 = never).
+	Rounds int
+
+	symbols []string
+	seen    []string
+	done    int
+}
+
+func (d *Demo) OnBook(_ context.Context, _ Handle, b Book) {
+	if len(d.symbols) == 0 && !contains(d.seen, b.Symbol) {
+		d.seen = append(d.seen, b.Symbol)
+	}
+}
+
+func (d *Demo) OnTimer(ctx context.Context, h Handle, _ time.Time) {
+	symbols := d.symbols
+	if len(symbols) == 0 {
+		symbols = d.seen
+	}
+	acted := false
+	for _, sym := range symbols {
+		b, ok := h.Book(sym, "")
+		if !ok {
+			continue
+		}
+		if _, err := h.Submit(ctx, transport.Action{Symbol: sym, Side: d.Side, Size: d.Size}); err != nil {
+			h.Logf("send %s: %v", sym, err)
+		}
+		fmt.Printf("[Gateway] %s NBBO bid=%.2f ask=%.2f\n", sym, b.NBBO.BestBid, b.NBBO.BestAsk)
+		acted = true
+	}
+	if acted {
+		d.done++
+	}
+	if d.Rounds > 0 && d.done >= d.Rounds {
+		h.Finish()
+	}
+}
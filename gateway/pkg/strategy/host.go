@@ -0,0 +1,366 @@
+package strategy
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/latency"
+	"github.com/helix-lab/helix/gateway/pkg/metrics"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/router"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// Host runs strategies against the gateway's books and executor. Add
+// strategies before the first callback. Callbacks may come from several
+// goroutines (the gateway loop and its depth shards); each strategy's
+// callbacks are serialized, so a strategy never runs on two at once.
+type Host struct {
+	books   *orderbook.Manager
+	sender  *executor.OrderSender
+	tracker *executor.Tracker
+
+	entries []*entry
+
+	mu       sync.Mutex
+	owners   map[string]*entry
+	lastTick map[orderbook.Market]transport.DepthUpdate
+	// latest is the category of each symbol's last update.
+	latest  map[string]string
+	running int
+	done    chan struct{}
+}
+
+type entry struct {
+	host *Host
+	cfg  Config
+	s    Strategy
+	next time.Time
+
+	// held across callbacks; guards next and finished
+	mu       sync.Mutex
+	finished bool
+
+	// read by metrics scrapes from other goroutines
+	running   atomic.Bool
+	submitted atomic.Uint64
+	refused   atomic.Uint64
+
+	// quota state, also used from the sender's checks and admin requests
+	quotaMu sync.Mutex
+	sent    []time.Time
+	held    map[string]*Holding
+	halt    *Halt
+}
+
+// NewHost wires strategies to books and sender, whose checks it joins to
+// enforce each strategy's Quota; tracker (optional) is used for positions,
+// open orders and to apply fills.
+func NewHost(books *orderbook.Manager, sender *executor.OrderSender, tracker *executor.Tracker) *Host {
+	h := &Host{
+		books:    books,
+		sender:   sender,
+		tracker:  tracker,
+		owners:   make(map[string]*entry),
+		lastTick: make(map[orderbook.Market]transport.DepthUpdate),
+		latest:   make(map[string]string),
+		done:     make(chan struct{}),
+	}
+	if sender != nil {
+		sender.SetChecker(h)
+	}
+	return h
+}
+
+func (h *Host) Add(cfg Config, s Strategy) {
+	e := &entry{host: h, cfg: cfg, s: s, held: make(map[string]*Holding)}
+	e.running.Store(true)
+	h.entries = append(h.entries, e)
+	h.running++
+}
+
+func (h *Host) Len() int { return len(h.entries) }
+
+// Done is closed once every strategy has called Finish.
+func (h *Host) Done() <-chan struct{} { return h.done }
+
+// Interval is the shortest strategy timer, i.e. how often the caller should
+// call Timer; 0 when no strategy wants one.
+func (h *Host) Interval() time.Duration {
+	var min time.Duration
+	for _, e := range h.entries {
+		if t := e.cfg.Timer; t > 0 && (min == 0 || t < min) {
+			min = t
+		}
+	}
+	return min
+}
+
+// Book hands u's market to every strategy trading its symbol; call it
+// after the book manager has applied u.
+func (h *Host) Book(ctx context.Context, u transport.DepthUpdate) {
+	h.mu.Lock()
+	h.lastTick[orderbook.Market{Symbol: u.Symbol, Category: u.Category}] = u
+	h.latest[u.Symbol] = u.Category
+	h.mu.Unlock()
+	var b Book
+	built := false
+	for _, e := range h.entries {
+		if !e.trades(u.Symbol) {
+			continue
+		}
+		if !built {
+			b, _ = h.book(u.Symbol, u.Category)
+			built = true
+		}
+		e.call(func() { e.s.OnBook(ctx, e, b) })
+	}
+}
+
+func (h *Host) Trade(ctx context.Context, t transport.Trade) {
+	for _, e := range h.entries {
+		if e.trades(t.Symbol) {
+			e.call(func() { e.s.OnTrade(ctx, e, t) })
+		}
+	}
+}
+
+// Bar hands a completed candle to every BarHandler trading its symbol.
+func (h *Host) Bar(ctx context.Context, b transport.Bar) {
+	for _, e := range h.entries {
+		if bh, ok := e.s.(BarHandler); ok && e.trades(b.Symbol) {
+			e.call(func() { bh.OnBar(ctx, e, b) })
+		}
+	}
+}
+
+// BookEvent hands e to every BookEventHandler trading its symbol.
+func (h *Host) BookEvent(ctx context.Context, ev transport.BookEvent) {
+	for _, e := range h.entries {
+		if bh, ok := e.s.(BookEventHandler); ok && e.trades(ev.Symbol) {
+			e.call(func() { bh.OnBookEvent(ctx, e, ev) })
+		}
+	}
+}
+
+// Fill applies f to the tracker and passes it to the strategy that sent
+// the order.
+func (h *Host) Fill(ctx context.Context, f transport.Fill) error {
+	if h.tracker != nil {
+		if err := h.tracker.Fill(f.OrderID, f.Price, f.Qty); err != nil {
+			return err
+		}
+	}
+	h.mu.Lock()
+	e, ok := h.owners[f.OrderID]
+	h.mu.Unlock()
+	if ok {
+		e.fill(f)
+		e.call(func() { e.s.OnFill(ctx, e, f) })
+	}
+	return nil
+}
+
+// Timer calls OnTimer on every strategy whose interval has elapsed.
+func (h *Host) Timer(ctx context.Context, now time.Time) {
+	for _, e := range h.entries {
+		if e.cfg.Timer <= 0 {
+			continue
+		}
+		e.call(func() {
+			if now.Before(e.next) {
+				return
+			}
+			e.next = now.Add(e.cfg.Timer)
+			e.s.OnTimer(ctx, e, now)
+		})
+	}
+}
+
+// Handle returns the named strategy's handle, for acting on its behalf
+// outside its callbacks; it must still be used from the gateway loop.
+func (h *Host) Handle(name string) (Handle, bool) {
+	if e, ok := h.entry(name); ok {
+		return e, true
+	}
+	return nil, false
+}
+
+// OpenOrders is the open orders the strategy called name has in the
+// tracker, if the host has one.
+func (h *Host) OpenOrders(name string) []executor.Order {
+	if e, ok := h.entry(name); ok {
+		return e.openOrders()
+	}
+	return nil
+}
+
+// Owner is the name of the strategy that sent orderID, or "".
+func (h *Host) Owner(orderID string) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if e, ok := h.owners[orderID]; ok {
+		return e.cfg.Name
+	}
+	return ""
+}
+
+// Orders sums the actions every strategy submitted and had refused.
+func (h *Host) Orders() (sent, refused uint64) {
+	for _, e := range h.entries {
+		sent += e.submitted.Load()
+		refused += e.refused.Load()
+	}
+	return sent, refused
+}
+
+// book is symbol's book in category; an empty category is the market of
+// symbol's last update, or its first market before any.
+func (h *Host) book(symbol, category string) (Book, bool) {
+	h.mu.Lock()
+	if category == "" {
+		category = h.latest[symbol]
+	}
+	tick, seen := h.lastTick[orderbook.Market{Symbol: symbol, Category: category}]
+	h.mu.Unlock()
+	if !seen && category == "" {
+		if cats := h.books.Categories(symbol); len(cats) > 0 {
+			category = cats[0]
+		}
+	}
+	venues := h.books.MarketSnapshot(symbol, category)
+	if len(venues) == 0 {
+		return Book{}, false
+	}
+	return Book{Symbol: symbol, Category: category, NBBO: orderbook.MergeBest(venues), Venues: venues, Tick: tick}, true
+}
+
+// call runs fn as e's callback unless e has finished.
+func (e *entry) call(fn func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.finished {
+		fn()
+	}
+}
+
+func (e *entry) trades(symbol string) bool {
+	if len(e.cfg.Symbols) == 0 {
+		return true
+	}
+	return contains(e.cfg.Symbols, symbol)
+}
+
+func (e *entry) Submit(ctx context.Context, action transport.Action) (string, error) {
+	b, _ := e.host.book(action.Symbol, action.Category)
+	// without a category the router may pick a venue in any market
+	views := make(map[string]router.BookView)
+	for _, cat := range e.host.books.Categories(action.Symbol) {
+		if action.Category != "" && cat != action.Category {
+			continue
+		}
+		for venue, lvl := range e.host.books.MarketSnapshot(action.Symbol, cat) {
+			views[venue] = router.BookView{BestBid: lvl.BestBid, BestAsk: lvl.BestAsk, Category: cat}
+		}
+	}
+	action.TickVenue, action.ExchTsMs, action.RecvNs = b.Tick.Venue, b.Tick.ExchTsMs, b.Tick.RecvNs
+	action.Account = e.cfg.Name
+	prof := latency.Start("route_and_send", latency.Labels{Symbol: action.Symbol})
+	sent, err := e.host.sender.Send(ctx, action, views)
+	prof.Stop()
+	if err != nil {
+		e.refused.Add(1)
+		return "", err
+	}
+	e.submitted.Add(1)
+	if sent.ID != "" {
+		e.host.mu.Lock()
+		e.host.owners[sent.ID] = e
+		e.host.mu.Unlock()
+	}
+	return sent.ID, nil
+}
+
+func (e *entry) Book(symbol, category string) (Book, bool) { return e.host.book(symbol, category) }
+
+func (e *entry) Positions() []executor.Position {
+	if e.host.tracker == nil {
+		return nil
+	}
+	return e.host.tracker.Positions()
+}
+
+// Finish is called from e's own callbacks, with e.mu held.
+func (e *entry) Finish() {
+	if e.finished {
+		return
+	}
+	e.finished = true
+	e.running.Store(false)
+	e.host.mu.Lock()
+	defer e.host.mu.Unlock()
+	if e.host.running--; e.host.running == 0 {
+		close(e.host.done)
+	}
+}
+
+func (e *entry) Logf(format string, args ...any) {
+	log.Printf("strategy %s: "+format, append([]any{e.cfg.Name}, args...)...)
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// RegisterMetrics exposes per-strategy state and order counts. Strategies
+// must be added before registering.
+func (h *Host) RegisterMetrics(reg *metrics.Registry) {
+	entries := append([]*entry(nil), h.entries...)
+	reg.Collect("helix_strategy_running", "1 while the strategy gets callbacks.", "gauge", func(emit metrics.Emit) {
+		for _, e := range entries {
+			v := 0.0
+			if e.running.Load() {
+				v = 1
+			}
+			emit("", metrics.L("strategy", e.cfg.Name), v)
+		}
+	})
+	reg.Collect("helix_strategy_orders_total", "Actions submitted per strategy, by outcome.", "counter", func(emit metrics.Emit) {
+		for _, e := range entries {
+			emit("", metrics.L("strategy", e.cfg.Name, "outcome", "sent"), float64(e.submitted.Load()))
+			emit("", metrics.L("strategy", e.cfg.Name, "outcome", "refused"), float64(e.refused.Load()))
+		}
+	})
+	reg.Collect("helix_strategy_halted", "1 while the strategy's own kill switch is armed.", "gauge", func(emit metrics.Emit) {
+		for _, e := range entries {
+			e.quotaMu.Lock()
+			v := 0.0
+			if e.halt != nil {
+				v = 1
+			}
+			e.quotaMu.Unlock()
+			emit("", metrics.L("strategy", e.cfg.Name), v)
+		}
+	})
+	reg.Collect("helix_strategy_pnl", "Strategy PnL from its own fills, realized and marked to the NBBO mid.", "gauge", func(emit metrics.Emit) {
+		for _, e := range entries {
+			st := e.status()
+			emit("", metrics.L("strategy", e.cfg.Name, "kind", "realized"), st.Realized)
+			emit("", metrics.L("strategy", e.cfg.Name, "kind", "unrealized"), st.Unrealized)
+		}
+	})
+	reg.Collect("helix_strategy_open_notional", "Unfilled notional of the strategy's open orders at the NBBO mid.", "gauge", func(emit metrics.Emit) {
+		for _, e := range entries {
+			emit("", metrics.L("strategy", e.cfg.Name), e.status().OpenNotional)
+		}
+	})
+}
@@ -0,0 +1,265 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// Errors the host's Check refuses a strategy's actions with.
+var (
+	ErrHalted = errors.New("strategy halted")
+	ErrQuota  = errors.New("strategy quota")
+)
+
+// Quota is one strategy's share of a gateway that several strategies
+// trade through; zero disables a limit. Everything is measured on the
+// strategy's own orders and fills, not the gateway's.
+type Quota struct {
+	MaxOrdersPerSec int
+	// MaxOpenNotional caps the unfilled notional of the strategy's open
+	// orders, the new one included, priced at the NBBO mid.
+	MaxOpenNotional float64
+	// MaxPosition caps the strategy's position per symbol, counting its
+	// open orders as filled; orders that reduce it are always allowed.
+	MaxPosition float64
+}
+
+// Halt is a strategy's own kill switch; while set the host refuses its
+// actions and leaves every other strategy trading.
+type Halt struct {
+	Reason string
+	Since  time.Time
+}
+
+// Holding is a strategy's position in one symbol, built from its own
+// fills; Unrealized is marked to the NBBO mid.
+type Holding struct {
+	Symbol     string
+	Qty        float64
+	AvgPrice   float64
+	Realized   float64
+	Unrealized float64
+}
+
+// Status is one hosted strategy's orders, quota use and PnL.
+type Status struct {
+	Name         string
+	Running      bool
+	Halt         *Halt
+	Sent         uint64
+	Refused      uint64
+	OpenNotional float64
+	Realized     float64
+	Unrealized   float64
+	Holdings     []Holding
+	Quota        Quota
+}
+
+// Check implements executor.Checker: it applies the quota and halt of the
+// strategy named by action.Account. Actions from no hosted strategy pass.
+// NewHost adds the host to its sender's checks.
+func (h *Host) Check(action transport.Action) error {
+	e, ok := h.entry(action.Account)
+	if !ok {
+		return nil
+	}
+	e.quotaMu.Lock()
+	defer e.quotaMu.Unlock()
+	if e.halt != nil {
+		return fmt.Errorf("%w: %s: %s", ErrHalted, e.cfg.Name, e.halt.Reason)
+	}
+	q := e.cfg.Quota
+	now := time.Now()
+	if q.MaxOrdersPerSec > 0 {
+		cut := now.Add(-time.Second)
+		i := 0
+		for i < len(e.sent) && !e.sent[i].After(cut) {
+			i++
+		}
+		e.sent = e.sent[i:]
+		if len(e.sent) >= q.MaxOrdersPerSec {
+			return fmt.Errorf("%w: %s over %d orders per second", ErrQuota, e.cfg.Name, q.MaxOrdersPerSec)
+		}
+	}
+	if q.MaxPosition > 0 || q.MaxOpenNotional > 0 {
+		open := e.openOrders()
+		if q.MaxPosition > 0 {
+			var cur float64
+			if p, ok := e.held[action.Symbol]; ok {
+				cur = p.Qty
+			}
+			for _, o := range open {
+				if o.Symbol == action.Symbol {
+					cur += signed(o.Side, o.Remaining())
+				}
+			}
+			if next := cur + signed(action.Side, action.Size); math.Abs(next) > q.MaxPosition && math.Abs(next) > math.Abs(cur) {
+				return fmt.Errorf("%w: %s position in %s would be %g (max %g)", ErrQuota, e.cfg.Name, action.Symbol, next, q.MaxPosition)
+			}
+		}
+		if q.MaxOpenNotional > 0 {
+			px, ok := h.mid(action.Symbol, action.Category)
+			if action.Price > 0 {
+				px, ok = action.Price, true
+			}
+			if !ok {
+				return fmt.Errorf("%w: no mark for %s", ErrQuota, action.Symbol)
+			}
+			n := action.Size * px
+			for _, o := range open {
+				m, ok := h.mid(o.Symbol, o.Category)
+				if !ok {
+					return fmt.Errorf("%w: no mark for %s", ErrQuota, o.Symbol)
+				}
+				n += o.Remaining() * m
+			}
+			if n > q.MaxOpenNotional {
+				return fmt.Errorf("%w: %s open notional would be %.2f (max %g)", ErrQuota, e.cfg.Name, n, q.MaxOpenNotional)
+			}
+		}
+	}
+	e.sent = append(e.sent, now)
+	return nil
+}
+
+// Halt arms the named strategy's own kill switch; false when no strategy
+// has that name.
+func (h *Host) Halt(name, reason string) bool {
+	e, ok := h.entry(name)
+	if !ok {
+		return false
+	}
+	e.quotaMu.Lock()
+	if e.halt == nil {
+		e.halt = &Halt{Reason: reason, Since: time.Now()}
+	}
+	e.quotaMu.Unlock()
+	return true
+}
+
+// Resume disarms the named strategy's kill switch.
+func (h *Host) Resume(name string) bool {
+	e, ok := h.entry(name)
+	if !ok {
+		return false
+	}
+	e.quotaMu.Lock()
+	e.halt = nil
+	e.quotaMu.Unlock()
+	return true
+}
+
+// Status reports every strategy, in the order they were added.
+func (h *Host) Status() []Status {
+	out := make([]Status, 0, len(h.entries))
+	for _, e := range h.entries {
+		out = append(out, e.status())
+	}
+	return out
+}
+
+func (e *entry) status() Status {
+	st := Status{Name: e.cfg.Name, Running: e.running.Load(), Sent: e.submitted.Load(),
+		Refused: e.refused.Load(), Quota: e.cfg.Quota}
+	for _, o := range e.openOrders() {
+		if m, ok := e.host.mid(o.Symbol, o.Category); ok {
+			st.OpenNotional += o.Remaining() * m
+		}
+	}
+	e.quotaMu.Lock()
+	defer e.quotaMu.Unlock()
+	if e.halt != nil {
+		hc := *e.halt
+		st.Halt = &hc
+	}
+	for _, p := range e.held {
+		hd := *p
+		if m, ok := e.host.mid(p.Symbol, ""); ok && p.Qty != 0 {
+			hd.Unrealized = (m - p.AvgPrice) * p.Qty
+		}
+		st.Realized += hd.Realized
+		st.Unrealized += hd.Unrealized
+		st.Holdings = append(st.Holdings, hd)
+	}
+	sort.Slice(st.Holdings, func(i, j int) bool { return st.Holdings[i].Symbol < st.Holdings[j].Symbol })
+	return st
+}
+
+// fill adds f to the strategy's own position in f.Symbol, like the
+// tracker does per venue.
+func (e *entry) fill(f transport.Fill) {
+	e.quotaMu.Lock()
+	defer e.quotaMu.Unlock()
+	p, ok := e.held[f.Symbol]
+	if !ok {
+		p = &Holding{Symbol: f.Symbol}
+		e.held[f.Symbol] = p
+	}
+	qty := signed(f.Side, f.Qty)
+	switch {
+	case p.Qty == 0 || (p.Qty > 0) == (qty > 0):
+		p.AvgPrice = (p.AvgPrice*math.Abs(p.Qty) + f.Price*f.Qty) / (math.Abs(p.Qty) + f.Qty)
+		p.Qty += qty
+	default:
+		closed := math.Min(f.Qty, math.Abs(p.Qty))
+		if p.Qty > 0 {
+			p.Realized += (f.Price - p.AvgPrice) * closed
+		} else {
+			p.Realized += (p.AvgPrice - f.Price) * closed
+		}
+		p.Qty += qty
+		switch {
+		case math.Abs(p.Qty) < 1e-12:
+			p.Qty, p.AvgPrice = 0, 0
+		case f.Qty > closed:
+			p.AvgPrice = f.Price
+		}
+	}
+}
+
+// openOrders are the tracker's open orders the strategy sent.
+func (e *entry) openOrders() []executor.Order {
+	if e.host.tracker == nil {
+		return nil
+	}
+	var out []executor.Order
+	for _, o := range e.host.tracker.OpenOrders() {
+		if o.Account == e.cfg.Name {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+func (h *Host) entry(name string) (*entry, bool) {
+	if name == "" {
+		return nil, false
+	}
+	for _, e := range h.entries {
+		if e.cfg.Name == name {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+func (h *Host) mid(symbol, category string) (float64, bool) {
+	b, ok := h.book(symbol, category)
+	if !ok || b.NBBO.BestBid <= 0 || b.NBBO.BestAsk <= 0 {
+		return 0, false
+	}
+	return (b.NBBO.BestBid + b.NBBO.BestAsk) / 2, true
+}
+
+func signed(side string, qty float64) float64 {
+	if side == "SELL" {
+		return -qty
+	}
+	return qty
+}
@@ -0,0 +1,138 @@
+// Package strategy hosts trading strategies inside the gateway process.
+// Callbacks run on the gateway loop, one at a time, so a strategy needs no
+// locking of its own.
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// Book is one market's books across venues after an update.
+type Book struct {
+	Symbol string
+	// Category is the book's market; NBBO and Venues cover it alone.
+	Category string
+	NBBO     orderbook.Level
+	Venues   map[string]orderbook.Level
+	// Tick is the update that produced this view.
+	Tick transport.DepthUpdate
+}
+
+// Handle is what a strategy acts through. Submit goes through the
+// executor's kill switch and limits, so a refused action is an error.
+type Handle interface {
+	// Submit routes action for its symbol and returns the order ID.
+	Submit(ctx context.Context, action transport.Action) (string, error)
+	// Book is symbol's book in category, "" for the market of its last
+	// update.
+	Book(symbol, category string) (Book, bool)
+	Positions() []executor.Position
+	// Finish marks the strategy done; it gets no further callbacks.
+	Finish()
+	Logf(format string, args ...any)
+}
+
+type Strategy interface {
+	OnBook(ctx context.Context, h Handle, b Book)
+	OnTrade(ctx context.Context, h Handle, t transport.Trade)
+	OnFill(ctx context.Context, h Handle, f transport.Fill)
+	OnTimer(ctx context.Context, h Handle, now time.Time)
+}
+
+// BarHandler is implemented by strategies that want completed candles;
+// the gateway sends them when it aggregates bars (-candles).
+type BarHandler interface {
+	OnBar(ctx context.Context, h Handle, b transport.Bar)
+}
+
+// BookEventHandler is implemented by strategies that want level and BBO
+// events; the gateway sends them when its venues derive them
+// (-book_events).
+type BookEventHandler interface {
+	OnBookEvent(ctx context.Context, h Handle, e transport.BookEvent)
+}
+
+// Base implements every callback as a no-op; embed it and override the
+// ones a strategy needs.
+type Base struct{}
+
+func (Base) OnBook(context.Context, Handle, Book)             {}
+func (Base) OnTrade(context.Context, Handle, transport.Trade) {}
+func (Base) OnFill(context.Context, Handle, transport.Fill)   {}
+func (Base) OnTimer(context.Context, Handle, time.Time)       {}
+
+// Config is one strategy instance's settings.
+type Config struct {
+	Name    string
+	Symbols []string
+	Timer   time.Duration
+	Params  map[string]any
+	Quota   Quota
+}
+
+// Float returns the numeric param key, or def when unset.
+func (c Config) Float(key string, def float64) (float64, error) {
+	v, ok := c.Params[key]
+	if !ok {
+		return def, nil
+	}
+	switch x := v.(type) {
+	case float64:
+		return x, nil
+	case int:
+		return float64(x), nil
+	case int64:
+		return float64(x), nil
+	}
+	return 0, fmt.Errorf("strategy %s: param %s must be a number, got %v", c.Name, key, v)
+}
+
+// String returns the string param key, or def when unset.
+func (c Config) String(key, def string) (string, error) {
+	v, ok := c.Params[key]
+	if !ok {
+		return def, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("strategy %s: param %s must be a string, got %v", c.Name, key, v)
+	}
+	return s, nil
+}
+
+// Factory builds a strategy from its config.
+type Factory func(cfg Config) (Strategy, error)
+
+var registry = map[string]Factory{}
+
+// Register makes a strategy kind available to New; call it from init.
+func Register(kind string, f Factory) {
+	if _, dup := registry[kind]; dup {
+		panic("strategy: duplicate kind " + kind)
+	}
+	registry[kind] = f
+}
+
+func New(kind string, cfg Config) (Strategy, error) {
+	f, ok := registry[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy kind %q (have %v)", kind, Kinds())
+	}
+	return f(cfg)
+}
+
+func Kinds() []string {
+	out := make([]string, 0, len(registry))
+	for k := range registry {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
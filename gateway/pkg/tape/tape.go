@@ -0,0 +1,181 @@
+// Package tape aggregates the trade stream into rolling flow statistics
+// per symbol: buy and sell volume and counts, imbalance and VWAP over any
+// number of trailing windows, and flags prints far larger than usual.
+package tape
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// Config sets the windows and what counts as a large print.
+type Config struct {
+	Windows []time.Duration
+	// LargeQty flags trades of at least this size; 0 flags trades of
+	// LargeMultiple times the mean size over the longest window instead.
+	LargeQty      float64
+	LargeMultiple float64
+	// MinTrades is how many trades the longest window needs before a mean
+	// size is trusted for LargeMultiple.
+	MinTrades int
+}
+
+type entry struct {
+	tsMs     int64
+	qty      float64
+	notional float64
+	buy      bool
+	large    bool
+}
+
+type window struct {
+	ms int64
+	// head is the oldest entry still inside the window
+	head        int
+	buyVol      float64
+	sellVol     float64
+	notional    float64
+	buys, sells int
+	large       int
+}
+
+type symbol struct {
+	entries []entry
+	windows []window
+	nowMs   int64
+}
+
+// Tape keeps the trailing trades of every symbol. Windows are timed by the
+// trades' own timestamps, and by Advance when the market goes quiet;
+// trades arriving out of order leave a little late. It is not safe for
+// concurrent use.
+type Tape struct {
+	cfg     Config
+	longest int
+	symbols map[string]*symbol
+}
+
+func New(cfg Config) *Tape {
+	if cfg.LargeMultiple <= 0 {
+		cfg.LargeMultiple = 10
+	}
+	if cfg.MinTrades <= 0 {
+		cfg.MinTrades = 20
+	}
+	t := &Tape{cfg: cfg, symbols: map[string]*symbol{}}
+	for i, w := range cfg.Windows {
+		if w > cfg.Windows[t.longest] {
+			t.longest = i
+		}
+	}
+	return t
+}
+
+// Trade adds tr and reports it as a large print when it is one.
+func (t *Tape) Trade(tr transport.Trade) (transport.LargePrint, bool) {
+	s := t.symbols[tr.Symbol]
+	if s == nil {
+		s = &symbol{windows: make([]window, len(t.cfg.Windows))}
+		for i, w := range t.cfg.Windows {
+			s.windows[i].ms = w.Milliseconds()
+		}
+		t.symbols[tr.Symbol] = s
+	}
+	t.advance(s, tr.TsMs)
+	lp := transport.LargePrint{Trade: tr}
+	large := false
+	if len(s.windows) > 0 {
+		w := s.windows[t.longest]
+		n := w.buys + w.sells
+		if n > 0 {
+			lp.Multiple = tr.Qty / ((w.buyVol + w.sellVol) / float64(n))
+		}
+		if t.cfg.LargeQty > 0 {
+			large = tr.Qty >= t.cfg.LargeQty
+		} else {
+			large = n >= t.cfg.MinTrades && lp.Multiple >= t.cfg.LargeMultiple
+		}
+	}
+	e := entry{tsMs: tr.TsMs, qty: tr.Qty, notional: tr.Qty * tr.Price, buy: strings.EqualFold(tr.Side, "buy"), large: large}
+	s.entries = append(s.entries, e)
+	for i := range s.windows {
+		s.windows[i].add(e, 1)
+	}
+	return lp, large
+}
+
+// Advance rolls every symbol's windows forward to nowMs.
+func (t *Tape) Advance(nowMs int64) {
+	for _, s := range t.symbols {
+		t.advance(s, nowMs)
+	}
+}
+
+func (t *Tape) advance(s *symbol, nowMs int64) {
+	if nowMs <= s.nowMs {
+		return
+	}
+	s.nowMs = nowMs
+	oldest := len(s.entries)
+	for i := range s.windows {
+		w := &s.windows[i]
+		for w.head < len(s.entries) && s.entries[w.head].tsMs <= nowMs-w.ms {
+			w.add(s.entries[w.head], -1)
+			w.head++
+		}
+		oldest = min(oldest, w.head)
+	}
+	if oldest > 0 && oldest >= len(s.entries)/2 {
+		s.entries = s.entries[:copy(s.entries, s.entries[oldest:])]
+		for i := range s.windows {
+			s.windows[i].head -= oldest
+		}
+	}
+}
+
+// add counts e into w, or out of it with sign -1.
+func (w *window) add(e entry, sign int) {
+	f := float64(sign)
+	if e.buy {
+		w.buyVol += f * e.qty
+		w.buys += sign
+	} else {
+		w.sellVol += f * e.qty
+		w.sells += sign
+	}
+	w.notional += f * e.notional
+	if e.large {
+		w.large += sign
+	}
+	if w.buys+w.sells == 0 {
+		// no float drift once the window empties
+		*w = window{ms: w.ms, head: w.head}
+	}
+}
+
+// Stats is every symbol's flow over every window, sorted by symbol then
+// window.
+func (t *Tape) Stats() []transport.TapeStats {
+	var out []transport.TapeStats
+	for name, s := range t.symbols {
+		for i, w := range s.windows {
+			st := transport.TapeStats{Symbol: name, Window: t.cfg.Windows[i], TsMs: s.nowMs,
+				BuyVolume: w.buyVol, SellVolume: w.sellVol, BuyTrades: w.buys, SellTrades: w.sells, LargePrints: w.large}
+			if vol := w.buyVol + w.sellVol; vol > 0 {
+				st.Imbalance = (w.buyVol - w.sellVol) / vol
+				st.VWAP = w.notional / vol
+			}
+			out = append(out, st)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Symbol != out[j].Symbol {
+			return out[i].Symbol < out[j].Symbol
+		}
+		return out[i].Window < out[j].Window
+	})
+	return out
+}
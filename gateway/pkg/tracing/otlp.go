@@ -0,0 +1,186 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OTLPExporter batches spans and POSTs them as OTLP/HTTP JSON to an
+// OpenTelemetry collector or Jaeger (e.g. http://localhost:4318/v1/traces).
+type OTLPExporter struct {
+	Endpoint    string
+	ServiceName string
+	Client      *http.Client
+
+	spans   chan SpanData
+	done    chan struct{}
+	once    sync.Once
+	batch   int
+	every   time.Duration
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+}
+
+func NewOTLPExporter(endpoint, service string) *OTLPExporter {
+	e := &OTLPExporter{
+		Endpoint:    endpoint,
+		ServiceName: service,
+		Client:      &http.Client{Timeout: 5 * time.Second},
+		spans:       make(chan SpanData, 4096),
+		done:        make(chan struct{}),
+		batch:       256,
+		every:       time.Second,
+	}
+	go e.loop()
+	return e
+}
+
+// Export queues a span; it drops (and counts) when the queue is full.
+func (e *OTLPExporter) Export(s SpanData) {
+	select {
+	case e.spans <- s:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+func (e *OTLPExporter) Dropped() uint64 { return e.dropped.Load() }
+
+// Failed counts spans lost to failed POSTs.
+func (e *OTLPExporter) Failed() uint64 { return e.failed.Load() }
+
+// Shutdown flushes queued spans.
+func (e *OTLPExporter) Shutdown() {
+	e.once.Do(func() { close(e.spans) })
+	<-e.done
+}
+
+func (e *OTLPExporter) loop() {
+	defer close(e.done)
+	t := time.NewTicker(e.every)
+	defer t.Stop()
+	buf := make([]SpanData, 0, e.batch)
+	flush := func() {
+		if len(buf) == 0 {
+			return
+		}
+		if err := e.post(buf); err != nil {
+			e.failed.Add(uint64(len(buf)))
+		}
+		buf = buf[:0]
+	}
+	for {
+		select {
+		case s, ok := <-e.spans:
+			if !ok {
+				flush()
+				return
+			}
+			buf = append(buf, s)
+			if len(buf) >= e.batch {
+				flush()
+			}
+		case <-t.C:
+			flush()
+		}
+	}
+}
+
+func (e *OTLPExporter) post(spans []SpanData) error {
+	body, err := json.Marshal(e.payload(spans))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp status %s", resp.Status)
+	}
+	return nil
+}
+
+type otlpKV struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKV       `json:"attributes,omitempty"`
+	Status            map[string]any `json:"status,omitempty"`
+}
+
+func (e *OTLPExporter) payload(spans []SpanData) map[string]any {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		sp := otlpSpan{
+			TraceID:           s.TraceID.String(),
+			SpanID:            s.SpanID.String(),
+			Name:              s.Name,
+			Kind:              1, // SPAN_KIND_INTERNAL
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        otlpAttrs(s.Attrs),
+		}
+		if s.ParentID != (SpanID{}) {
+			sp.ParentSpanID = s.ParentID.String()
+		}
+		if s.Error != "" {
+			sp.Status = map[string]any{"code": 2, "message": s.Error}
+		}
+		out = append(out, sp)
+	}
+	return map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": otlpAttrs([]Attr{String("service.name", e.ServiceName)})},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "helix/gateway"},
+				"spans": out,
+			}},
+		}},
+	}
+}
+
+func otlpAttrs(attrs []Attr) []otlpKV {
+	out := make([]otlpKV, 0, len(attrs))
+	for _, a := range attrs {
+		var v map[string]any
+		switch x := a.Value.(type) {
+		case string:
+			v = map[string]any{"stringValue": x}
+		case bool:
+			v = map[string]any{"boolValue": x}
+		case int64:
+			v = map[string]any{"intValue": strconv.FormatInt(x, 10)}
+		case float64:
+			v = map[string]any{"doubleValue": x}
+		default:
+			v = map[string]any{"stringValue": fmt.Sprint(x)}
+		}
+		out = append(out, otlpKV{Key: a.Key, Value: v})
+	}
+	return out
+}
@@ -0,0 +1,151 @@
+// Package tracing provides lightweight spans with context propagation and an
+// OTLP/HTTP exporter, so the order path can be inspected in Jaeger or any
+// OpenTelemetry collector without pulling the full SDK into the gateway.
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+type TraceID [16]byte
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+// Attr is a span attribute; Value is a string, bool, int64 or float64.
+type Attr struct {
+	Key   string
+	Value any
+}
+
+func String(k, v string) Attr        { return Attr{Key: k, Value: v} }
+func Int(k string, v int64) Attr     { return Attr{Key: k, Value: v} }
+func Float(k string, v float64) Attr { return Attr{Key: k, Value: v} }
+func Bool(k string, v bool) Attr     { return Attr{Key: k, Value: v} }
+
+// SpanData is a finished span handed to the exporter.
+type SpanData struct {
+	TraceID  TraceID
+	SpanID   SpanID
+	ParentID SpanID
+	Name     string
+	Start    time.Time
+	End      time.Time
+	Attrs    []Attr
+	Error    string
+}
+
+// Span is a live span. A nil *Span is a valid no-op, which is what Start
+// returns when tracing is disabled or the trace was not sampled.
+type Span struct {
+	tracer *Tracer
+	data   SpanData
+}
+
+// unsampled marks a context whose root was not sampled, so children are
+// skipped too instead of starting fresh roots.
+var unsampled = &Span{}
+
+func (s *Span) SetAttr(attrs ...Attr) {
+	if s == nil || s.tracer == nil {
+		return
+	}
+	s.data.Attrs = append(s.data.Attrs, attrs...)
+}
+
+// SetError marks the span as failed.
+func (s *Span) SetError(err error) {
+	if s == nil || s.tracer == nil || err == nil {
+		return
+	}
+	s.data.Error = err.Error()
+}
+
+func (s *Span) End() {
+	if s == nil || s.tracer == nil {
+		return
+	}
+	s.data.End = time.Now()
+	s.tracer.exporter.Export(s.data)
+}
+
+func (s *Span) TraceID() TraceID {
+	if s == nil {
+		return TraceID{}
+	}
+	return s.data.TraceID
+}
+
+// Exporter receives finished spans; it must not block.
+type Exporter interface {
+	Export(SpanData)
+}
+
+// Tracer samples root spans at SampleRatio; children follow their parent.
+type Tracer struct {
+	exporter    Exporter
+	sampleRatio float64
+}
+
+func NewTracer(exp Exporter, sampleRatio float64) *Tracer {
+	return &Tracer{exporter: exp, sampleRatio: sampleRatio}
+}
+
+var global atomic.Pointer[Tracer]
+
+// SetTracer installs the process-wide tracer; nil disables tracing.
+func SetTracer(t *Tracer) { global.Store(t) }
+
+type spanKey struct{}
+
+// Start opens a span on the process-wide tracer, see Tracer.Start.
+func Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	return global.Load().Start(ctx, name, attrs...)
+}
+
+// Start opens a span as a child of the span in ctx, or a new root sampled
+// at t's ratio. A nil Tracer never traces.
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	parent, _ := ctx.Value(spanKey{}).(*Span)
+	if parent == unsampled {
+		return ctx, nil
+	}
+	s := &Span{tracer: t, data: SpanData{Name: name, Start: time.Now(), Attrs: attrs}}
+	if parent != nil {
+		s.data.TraceID = parent.data.TraceID
+		s.data.ParentID = parent.data.SpanID
+	} else {
+		if t.sampleRatio < 1 && rand.Float64() >= t.sampleRatio {
+			return context.WithValue(ctx, spanKey{}, unsampled), nil
+		}
+		fillRandom(s.data.TraceID[:])
+	}
+	fillRandom(s.data.SpanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// FromContext returns the active span, or nil.
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	if s == unsampled {
+		return nil
+	}
+	return s
+}
+
+func fillRandom(b []byte) {
+	for i := 0; i < len(b); i += 8 {
+		v := rand.Uint64()
+		for j := 0; j < 8 && i+j < len(b); j++ {
+			b[i+j] = byte(v >> (8 * j))
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package transport
+
+import (
+	"sync"
+
+	"github.com/helix-lab/helix/gateway/pkg/metrics"
+)
+
+var conflatedDepth = metrics.Default.Counter("helix_transport_depth_conflated_total", "Depth updates replaced by a newer one for the same book before publishing.")
+
+// Conflater coalesces depth updates per (venue, symbol) between flushes, so
+// a deep book emitting a delta per level publishes at most one update per
+// book per window. Only depth goes through it; trades and fills are
+// published directly and never wait. The owner calls Flush every window.
+type Conflater struct {
+	publish func(DepthUpdate)
+
+	mu        sync.Mutex
+	index     map[bookKey]int
+	pending   []DepthUpdate
+	spare     []DepthUpdate
+	conflated uint64
+}
+
+type bookKey struct{ venue, symbol string }
+
+// NewConflater returns a Conflater flushing into publish.
+func NewConflater(publish func(DepthUpdate)) *Conflater {
+	return &Conflater{publish: publish, index: make(map[bookKey]int)}
+}
+
+// Depth queues u, replacing a pending update for the same book in place so
+// books keep the order of their first update in the window.
+func (c *Conflater) Depth(u DepthUpdate) {
+	k := bookKey{u.Venue, u.Symbol}
+	c.mu.Lock()
+	if i, ok := c.index[k]; ok {
+		c.pending[i] = u
+		c.conflated++
+		c.mu.Unlock()
+		conflatedDepth.Inc()
+		return
+	}
+	c.index[k] = len(c.pending)
+	c.pending = append(c.pending, u)
+	c.mu.Unlock()
+}
+
+// Flush publishes the pending updates and returns how many there were.
+func (c *Conflater) Flush() int {
+	c.mu.Lock()
+	out := c.pending
+	c.pending = c.spare[:0]
+	clear(c.index)
+	c.mu.Unlock()
+	for _, u := range out {
+		c.publish(u)
+	}
+	clear(out)
+	c.mu.Lock()
+	c.spare = out[:0]
+	c.mu.Unlock()
+	return len(out)
+}
+
+// Conflated is how many updates were replaced before being published.
+func (c *Conflater) Conflated() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conflated
+}
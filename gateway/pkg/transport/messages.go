@@ -1,24 +1,315 @@
 package transport
 
+import "time"
+
 // DepthUpdate represents a top-of-book change from an exchange.
 type DepthUpdate struct {
-	Venue   string
-	Symbol  string
-	BestBid float64
-	BestAsk float64
-	BidSize float64
-	AskSize float64
+	Venue  string
+	Symbol string
+	// Category is the venue's market for Symbol (spot, linear, inverse,
+	// option); the same symbol on two markets is two books.
+	Category string
+	BestBid  float64
+	BestAsk  float64
+	BidSize  float64
+	AskSize  float64
+	// ExchTsMs is the venue's event time; RecvNs is when the gateway read
+	// the frame (Unix ns). Either may be zero when unknown.
+	ExchTsMs int64
+	RecvNs   int64
+}
+
+// NBBO is the consolidated best bid and offer of one market across
+// venues. Sizes sum every venue at the best price, and BidVenues and
+// AskVenues name them, sorted.
+type NBBO struct {
+	Symbol    string
+	Category  string
+	BestBid   float64
+	BestAsk   float64
+	BidSize   float64
+	AskSize   float64
+	BidVenues []string
+	AskVenues []string
+	// ExchTsMs and RecvNs are those of the update that moved it.
+	ExchTsMs int64
+	RecvNs   int64
+}
+
+// BookSnapshot is the top of one venue's book in full, best level first,
+// for consumers that render books without applying deltas. TsMs is when it
+// was taken, ExchTsMs the venue time of the last update in it.
+type BookSnapshot struct {
+	Venue    string
+	Symbol   string
+	Category string
+	Bids     []BookLevel
+	Asks     []BookLevel
+	ExchTsMs int64
+	TsMs     int64
+}
+
+type BookLevel struct {
+	Price float64
+	Size  float64
 }
 
 type Action struct {
+	// ID is the client order id, assigned once the executor tracks it.
+	ID     string
 	Symbol string
 	Side   string
 	Size   float64
 	Venue  string
+	// Category, if set, restricts routing to venues quoting Symbol in that
+	// market.
+	Category string
+	// Price is the limit price; 0 takes liquidity at market.
+	Price float64
+	// TickVenue, ExchTsMs and RecvNs are copied from the tick that
+	// triggered the action, for tick-to-trade accounting.
+	TickVenue string
+	ExchTsMs  int64
+	RecvNs    int64
+	// Account is the hosted strategy or client the action is for.
+	Account string
+	// Testnet marks actions from a gateway.testnet gateway, for executors
+	// to send to the venue's testnet.
+	Testnet bool
+}
+
+// Cancel asks the venue to pull a sent action.
+type Cancel struct {
+	ID     string
+	Venue  string
+	Symbol string
 }
 
+// RouteDecision is what the router chose for an action and the
+// fee-adjusted price it saw on each venue; DryRun marks one that was not
+// sent.
+type RouteDecision struct {
+	Symbol string
+	Side   string
+	Size   float64
+	Venue  string
+	Price  float64
+	Prices map[string]float64
+	DryRun bool
+	TsNs   int64
+	// OrderID is the tracked order the decision became, if any.
+	OrderID string
+	// Account is the hosted strategy or client the action came from.
+	Account string
+}
+
+// Fill is an execution against one of the gateway's orders.
 type Fill struct {
-	Venue string
-	Price float64
-	Qty   float64
+	OrderID  string
+	Venue    string
+	Symbol   string
+	Category string
+	Side     string
+	Price    float64
+	Qty      float64
+	// Maker is set when the order rested on the book rather than taking.
+	Maker bool
+}
+
+// OrderComplete is an order that is done: filled in full or cancelled with
+// Filled of Size executed over Fills fills at an average of AvgPrice.
+type OrderComplete struct {
+	OrderID  string
+	Venue    string
+	Symbol   string
+	Category string
+	Side     string
+	Status   string
+	Size     float64
+	Filled   float64
+	AvgPrice float64
+	Fills    int
+	// DurationMs is from the order's creation to its completion.
+	DurationMs int64
+}
+
+// Trade is a public print on a venue; Side is the taker's.
+type Trade struct {
+	Venue  string
+	Symbol string
+	Side   string
+	Price  float64
+	Qty    float64
+	TsMs   int64
+}
+
+// Liquidation is a forced close printed by the venue.
+type Liquidation struct {
+	Venue  string
+	Symbol string
+	Side   string
+	Price  float64
+	Qty    float64
+	TsMs   int64
+}
+
+// Book event kinds.
+const (
+	LevelAdded   = "level_added"
+	LevelRemoved = "level_removed"
+	LevelChanged = "size_changed"
+	BBOImproved  = "bbo_improved"
+	BBOWorsened  = "bbo_worsened"
+)
+
+// BookEvent is one meaningful change to a venue's book, derived from its
+// deltas. Level events give the level's size before and after (0 when it
+// did not or no longer exists); BBO events give the side's best price
+// before and after, and Size the new best's size.
+type BookEvent struct {
+	Venue     string
+	Symbol    string
+	Category  string
+	Kind      string
+	Side      string // "bid" or "ask"
+	Price     float64
+	Size      float64
+	PrevPrice float64
+	PrevSize  float64
+	ExchTsMs  int64
+	RecvNs    int64
+}
+
+// Bar is an OHLCV candle of one venue's trades in a symbol over
+// [StartMs, StartMs+Interval). VWAP is the volume-weighted price.
+type Bar struct {
+	Venue    string
+	Symbol   string
+	Interval time.Duration
+	StartMs  int64
+	Open     float64
+	High     float64
+	Low      float64
+	Close    float64
+	Volume   float64
+	VWAP     float64
+	Trades   int
+}
+
+// TapeStats is one symbol's trade flow across venues over the trailing
+// Window, as of TsMs. Imbalance is (buy-sell)/(buy+sell) volume.
+type TapeStats struct {
+	Symbol      string
+	Window      time.Duration
+	TsMs        int64
+	BuyVolume   float64
+	SellVolume  float64
+	BuyTrades   int
+	SellTrades  int
+	Imbalance   float64
+	VWAP        float64
+	LargePrints int
+}
+
+// LargePrint is a trade far larger than its symbol's usual size; Multiple
+// is its size over the mean trade size before it.
+type LargePrint struct {
+	Trade
+	Multiple float64
+}
+
+// FundingRate is the current funding rate of a perpetual and when it
+// settles. Mark is the venue's mark price, 0 when the feed has none.
+type FundingRate struct {
+	Venue         string
+	Symbol        string
+	Rate          float64
+	Mark          float64
+	NextFundingMs int64
+	TsMs          int64
+}
+
+// RiskState is the risk engine's view of the book: PnL since the start of
+// the UTC day and gross notional. Breach names the limit that armed the
+// kill switch today, or is empty.
+type RiskState struct {
+	TsMs int64
+	// Base is the currency the amounts are in; empty when each symbol's
+	// quote was summed as is.
+	Base          string
+	Realized      float64
+	Unrealized    float64
+	DailyPnL      float64
+	GrossNotional float64
+	Breach        string
+}
+
+// Portfolio is the holdings of every venue valued in Base: balances per
+// asset and open positions. Unpriced lists assets with no conversion to
+// Base, which are left out of Equity.
+type Portfolio struct {
+	TsMs      int64
+	Base      string
+	Equity    float64
+	Assets    []PortfolioAsset
+	Positions []PortfolioPosition
+	Unpriced  []string
+}
+
+// PortfolioAsset is one asset across venues; Value is Total in the base
+// currency.
+type PortfolioAsset struct {
+	Asset  string
+	Total  float64
+	Value  float64
+	Venues map[string]float64
+}
+
+// PortfolioPosition is a derivatives position as its venue reports it, or
+// as the gateway's fills built it for venues without an account source.
+// Unrealized is in the base currency.
+type PortfolioPosition struct {
+	Venue      string
+	Symbol     string
+	Qty        float64
+	AvgPrice   float64
+	Mark       float64
+	Unrealized float64
+}
+
+// Opportunity is a funding trade the arbitrage monitor ranked: long one
+// venue, short another, held for Periods funding periods. All figures are
+// in bps of notional; NetBps is the carry plus entry basis minus round-trip
+// taker fees over the hold.
+type Opportunity struct {
+	TsMs       int64
+	Kind       string
+	Rank       int
+	Symbol     string
+	LongVenue  string
+	ShortVenue string
+	// FundingBps is what the pair collects per period.
+	FundingBps float64
+	BasisBps   float64
+	FeesBps    float64
+	NetBps     float64
+	Periods    int
+}
+
+// Basis is a perpetual's premium over spot: its mark (or book mid when the
+// venue publishes no mark) against the spot NBBO mid. MeanBps, MinBps and
+// MaxBps cover the monitor's rolling window; Alert is "above" or "below"
+// while BasisBps is outside the configured thresholds.
+type Basis struct {
+	TsMs     int64
+	Symbol   string
+	Venue    string
+	Mark     float64
+	SpotMid  float64
+	BasisBps float64
+	MeanBps  float64
+	MinBps   float64
+	MaxBps   float64
+	Samples  int
+	Alert    string
 }
@@ -1,21 +1,180 @@
 package transport
 
 import (
+	"context"
 	"fmt"
+
+	"github.com/helix-lab/helix/gateway/pkg/metrics"
+	"github.com/helix-lab/helix/gateway/pkg/tracing"
+)
+
+var (
+	published     = metrics.Default.CounterVec("helix_transport_published_total", "Messages published on the transport by kind.", "kind")
+	publishFailed = metrics.Default.CounterVec("helix_transport_publish_failures_total", "Messages the transport failed to publish, by kind.", "kind")
 )
 
 type Publisher struct {
 	Endpoint string
+	// Fail, when set, is asked before each message; an error drops the
+	// message as a failed publish. Chaos testing injects failures here.
+	Fail func(kind string) error
 }
 
 func NewPublisher(endpoint string) *Publisher {
 	return &Publisher{Endpoint: endpoint}
 }
 
+// ok counts a message of kind as published, or as failed when Fail says so.
+func (p *Publisher) ok(kind string) bool {
+	if p.Fail != nil && p.Fail(kind) != nil {
+		publishFailed.With(kind).Inc()
+		return false
+	}
+	published.With(kind).Inc()
+	return true
+}
+
 func (p *Publisher) PublishDepth(update DepthUpdate) {
+	if !p.ok("depth") {
+		return
+	}
 	fmt.Printf("[ZMQ pub %s] depth %s bid=%.2f ask=%.2f\n", p.Endpoint, update.Venue, update.BestBid, update.BestAsk)
 }
 
-func (p *Publisher) PublishAction(action Action) {
+func (p *Publisher) PublishNBBO(n NBBO) {
+	if !p.ok("nbbo") {
+		return
+	}
+	fmt.Printf("[ZMQ pub %s] nbbo %s bid=%.2f %v ask=%.2f %v\n", p.Endpoint, n.Symbol, n.BestBid, n.BidVenues, n.BestAsk, n.AskVenues)
+}
+
+func (p *Publisher) PublishBookSnapshot(b BookSnapshot) {
+	if !p.ok("book_snapshot") {
+		return
+	}
+	var bid, ask float64
+	if len(b.Bids) > 0 {
+		bid = b.Bids[0].Price
+	}
+	if len(b.Asks) > 0 {
+		ask = b.Asks[0].Price
+	}
+	fmt.Printf("[ZMQ pub %s] book %s %s levels=%d/%d bid=%.2f ask=%.2f\n", p.Endpoint, b.Venue, b.Symbol, len(b.Bids), len(b.Asks), bid, ask)
+}
+
+func (p *Publisher) PublishAction(ctx context.Context, action Action) {
+	_, span := tracing.Start(ctx, "transport.publish_action", tracing.String("endpoint", p.Endpoint))
+	defer span.End()
+	if !p.ok("action") {
+		return
+	}
 	fmt.Printf("[ZMQ pub %s] action %+v\n", p.Endpoint, action)
 }
+
+func (p *Publisher) PublishCancel(ctx context.Context, c Cancel) {
+	_, span := tracing.Start(ctx, "transport.publish_cancel", tracing.String("endpoint", p.Endpoint))
+	defer span.End()
+	if !p.ok("cancel") {
+		return
+	}
+	fmt.Printf("[ZMQ pub %s] cancel %+v\n", p.Endpoint, c)
+}
+
+func (p *Publisher) PublishRoute(d RouteDecision) {
+	if !p.ok("route") {
+		return
+	}
+	fmt.Printf("[ZMQ pub %s] route %s %s %g -> %s price=%.4f candidates=%v dry_run=%t\n",
+		p.Endpoint, d.Symbol, d.Side, d.Size, d.Venue, d.Price, d.Prices, d.DryRun)
+}
+
+func (p *Publisher) PublishOrderComplete(c OrderComplete) {
+	if !p.ok("order_complete") {
+		return
+	}
+	fmt.Printf("[ZMQ pub %s] order %s %s %s %s %s filled=%.4f/%.4f avg=%.2f fills=%d in %dms\n",
+		p.Endpoint, c.OrderID, c.Status, c.Venue, c.Symbol, c.Side, c.Filled, c.Size, c.AvgPrice, c.Fills, c.DurationMs)
+}
+
+func (p *Publisher) PublishTrade(t Trade) {
+	if !p.ok("trade") {
+		return
+	}
+	fmt.Printf("[ZMQ pub %s] trade %s %s %s qty=%.4f price=%.2f\n", p.Endpoint, t.Venue, t.Symbol, t.Side, t.Qty, t.Price)
+}
+
+func (p *Publisher) PublishBar(b Bar) {
+	if !p.ok("bar") {
+		return
+	}
+	fmt.Printf("[ZMQ pub %s] bar %s %s %s o=%.2f h=%.2f l=%.2f c=%.2f v=%.4f vwap=%.2f n=%d\n",
+		p.Endpoint, b.Venue, b.Symbol, b.Interval, b.Open, b.High, b.Low, b.Close, b.Volume, b.VWAP, b.Trades)
+}
+
+func (p *Publisher) PublishTapeStats(s TapeStats) {
+	if !p.ok("tape") {
+		return
+	}
+	fmt.Printf("[ZMQ pub %s] tape %s %s buy=%.4f/%d sell=%.4f/%d imbalance=%.3f vwap=%.2f large=%d\n",
+		p.Endpoint, s.Symbol, s.Window, s.BuyVolume, s.BuyTrades, s.SellVolume, s.SellTrades, s.Imbalance, s.VWAP, s.LargePrints)
+}
+
+func (p *Publisher) PublishLargePrint(l LargePrint) {
+	if !p.ok("large_print") {
+		return
+	}
+	fmt.Printf("[ZMQ pub %s] large print %s %s %s qty=%.4f price=%.2f x%.1f\n", p.Endpoint, l.Venue, l.Symbol, l.Side, l.Qty, l.Price, l.Multiple)
+}
+
+func (p *Publisher) PublishLiquidation(liq Liquidation) {
+	if !p.ok("liquidation") {
+		return
+	}
+	fmt.Printf("[ZMQ pub %s] liquidation %s %s %s qty=%.4f price=%.2f\n", p.Endpoint, liq.Venue, liq.Symbol, liq.Side, liq.Qty, liq.Price)
+}
+
+func (p *Publisher) PublishBookEvent(e BookEvent) {
+	if !p.ok("book_event") {
+		return
+	}
+	fmt.Printf("[ZMQ pub %s] book %s %s %s %s price=%g size=%g\n", p.Endpoint, e.Kind, e.Venue, e.Symbol, e.Side, e.Price, e.Size)
+}
+
+func (p *Publisher) PublishFunding(f FundingRate) {
+	if !p.ok("funding") {
+		return
+	}
+	fmt.Printf("[ZMQ pub %s] funding %s %s rate=%.6f next=%d\n", p.Endpoint, f.Venue, f.Symbol, f.Rate, f.NextFundingMs)
+}
+
+func (p *Publisher) PublishRisk(r RiskState) {
+	if !p.ok("risk") {
+		return
+	}
+	fmt.Printf("[ZMQ pub %s] risk daily_pnl=%.2f realized=%.2f unrealized=%.2f gross=%.2f breach=%q\n",
+		p.Endpoint, r.DailyPnL, r.Realized, r.Unrealized, r.GrossNotional, r.Breach)
+}
+
+func (p *Publisher) PublishPortfolio(pf Portfolio) {
+	if !p.ok("portfolio") {
+		return
+	}
+	fmt.Printf("[ZMQ pub %s] portfolio equity=%.2f %s assets=%d positions=%d unpriced=%v\n",
+		p.Endpoint, pf.Equity, pf.Base, len(pf.Assets), len(pf.Positions), pf.Unpriced)
+}
+
+func (p *Publisher) PublishOpportunity(o Opportunity) {
+	if !p.ok("opportunity") {
+		return
+	}
+	fmt.Printf("[ZMQ pub %s] opportunity #%d %s %s long=%s short=%s funding=%.2fbps basis=%.2fbps fees=%.2fbps net=%.2fbps periods=%d\n",
+		p.Endpoint, o.Rank, o.Kind, o.Symbol, o.LongVenue, o.ShortVenue, o.FundingBps, o.BasisBps, o.FeesBps, o.NetBps, o.Periods)
+}
+
+func (p *Publisher) PublishBasis(b Basis) {
+	if !p.ok("basis") {
+		return
+	}
+	fmt.Printf("[ZMQ pub %s] basis %s %s mark=%.4f spot=%.4f basis=%.2fbps mean=%.2fbps alert=%q\n",
+		p.Endpoint, b.Venue, b.Symbol, b.Mark, b.SpotMid, b.BasisBps, b.MeanBps, b.Alert)
+}
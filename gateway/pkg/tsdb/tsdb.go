@@ -0,0 +1,213 @@
+// Package tsdb pushes book and latency series into a time-series database
+// at a fixed cadence, for dashboards over longer horizons than Prometheus
+// keeps. InfluxDB is written in line protocol over HTTP; TimescaleDB goes
+// through database/sql and needs a Postgres driver linked into the binary.
+package tsdb
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/latency"
+	"github.com/helix-lab/helix/gateway/pkg/metrics"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+)
+
+var (
+	rowsWritten = metrics.Default.CounterVec("helix_sink_rows_total", "Rows written by external sinks, per sink and table.", "sink", "table")
+	rowsDropped = metrics.Default.CounterVec("helix_sink_dropped_total", "Rows external sinks dropped (full buffer or failed write), per sink and table.", "sink", "table")
+	sinkErrors  = metrics.Default.CounterVec("helix_sink_errors_total", "Failed writes to external sinks.", "sink")
+)
+
+// Point is one sample of a series: a measurement, its identifying tags and
+// the values at Time.
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]float64
+	Time        time.Time
+}
+
+// Writer stores points in a database.
+type Writer interface {
+	Write(ctx context.Context, points []Point) error
+}
+
+// Source produces the points to push at now.
+type Source func(now time.Time) []Point
+
+// Pusher collects its sources every Every and writes the points.
+type Pusher struct {
+	Writer  Writer
+	Every   time.Duration
+	Sources []Source
+	// Logf, if set, reports failed writes.
+	Logf func(format string, args ...any)
+}
+
+// Run pushes until ctx ends. A failed write is counted and its points
+// dropped; the next tick carries on.
+func (p *Pusher) Run(ctx context.Context) {
+	every := p.Every
+	if every <= 0 {
+		every = 10 * time.Second
+	}
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			p.Push(ctx, now)
+		}
+	}
+}
+
+// Push collects and writes one round at now.
+func (p *Pusher) Push(ctx context.Context, now time.Time) {
+	var points []Point
+	for _, src := range p.Sources {
+		points = append(points, src(now)...)
+	}
+	if len(points) == 0 {
+		return
+	}
+	wctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	err := p.Writer.Write(wctx, points)
+	cancel()
+	counts := map[string]int{}
+	for _, pt := range points {
+		counts[pt.Measurement]++
+	}
+	if err != nil {
+		sinkErrors.With("tsdb").Inc()
+		if p.Logf != nil {
+			p.Logf("tsdb: write %d points: %v", len(points), err)
+		}
+	}
+	for m, n := range counts {
+		if err != nil {
+			rowsDropped.With("tsdb", m).Add(float64(n))
+		} else {
+			rowsWritten.With("tsdb", m).Add(float64(n))
+		}
+	}
+}
+
+// Books samples each venue's top of book and each market's NBBO (venue
+// "NBBO") into the helix_book measurement: bid, ask, mid, spread_bps and
+// imbalance, the bid share of the size at the touch scaled to [-1, 1].
+// Books with a category carry it as a tag.
+func Books(m *orderbook.Manager) Source {
+	return func(now time.Time) []Point {
+		var out []Point
+		for _, sym := range m.Symbols() {
+			for _, cat := range m.Categories(sym) {
+				snap := m.MarketSnapshot(sym, cat)
+				venues := make([]string, 0, len(snap))
+				for v := range snap {
+					venues = append(venues, v)
+				}
+				sort.Strings(venues)
+				for _, v := range venues {
+					out = append(out, bookPoint(sym, cat, v, snap[v], now))
+				}
+				out = append(out, bookPoint(sym, cat, "NBBO", orderbook.MergeBest(snap), now))
+			}
+		}
+		return out
+	}
+}
+
+func bookPoint(symbol, category, venue string, l orderbook.Level, now time.Time) Point {
+	f := map[string]float64{"bid": l.BestBid, "ask": l.BestAsk}
+	if l.BestBid > 0 && l.BestAsk > 0 {
+		mid := (l.BestBid + l.BestAsk) / 2
+		f["mid"] = mid
+		f["spread_bps"] = (l.BestAsk - l.BestBid) / mid * 1e4
+	}
+	if total := l.BidSize + l.AskSize; total > 0 {
+		f["imbalance"] = (l.BidSize - l.AskSize) / total
+	}
+	tags := map[string]string{"symbol": symbol, "venue": venue}
+	if category != "" {
+		tags["category"] = category
+	}
+	return Point{Measurement: "helix_book", Tags: tags, Fields: f, Time: now}
+}
+
+// Latency samples the latency series that recorded anything since the
+// previous call into the helix_latency measurement, in nanoseconds.
+func Latency(reg *latency.Registry) Source {
+	w := reg.Window()
+	return func(now time.Time) []Point {
+		var out []Point
+		for _, s := range w.Next() {
+			tags := map[string]string{"op": s.Label}
+			for k, v := range map[string]string{"venue": s.Labels.Venue, "symbol": s.Labels.Symbol, "stage": s.Labels.Stage} {
+				if v != "" {
+					tags[k] = v
+				}
+			}
+			out = append(out, Point{Measurement: "helix_latency", Tags: tags, Time: now, Fields: map[string]float64{
+				"count": float64(s.Count), "mean_ns": float64(s.Mean), "p50_ns": float64(s.P50),
+				"p99_ns": float64(s.P99), "max_ns": float64(s.Max),
+			}})
+		}
+		return out
+	}
+}
+
+// AppendLine appends p in InfluxDB line protocol, tags and fields sorted
+// by key, with a nanosecond timestamp and a trailing newline.
+func AppendLine(b []byte, p Point) []byte {
+	b = appendEscaped(b, p.Measurement, ", ")
+	for _, k := range sortedKeys(p.Tags) {
+		if p.Tags[k] == "" {
+			continue
+		}
+		b = append(b, ',')
+		b = appendEscaped(b, k, ",= ")
+		b = append(b, '=')
+		b = appendEscaped(b, p.Tags[k], ",= ")
+	}
+	for i, k := range sortedKeys(p.Fields) {
+		if i == 0 {
+			b = append(b, ' ')
+		} else {
+			b = append(b, ',')
+		}
+		b = appendEscaped(b, k, ",= ")
+		b = append(b, '=')
+		b = strconv.AppendFloat(b, p.Fields[k], 'g', -1, 64)
+	}
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, p.Time.UnixNano(), 10)
+	return append(b, '\n')
+}
+
+func appendEscaped(b []byte, s, special string) []byte {
+	if !strings.ContainsAny(s, special) {
+		return append(b, s...)
+	}
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(special, s[i]) >= 0 {
+			b = append(b, '\\')
+		}
+		b = append(b, s[i])
+	}
+	return b
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
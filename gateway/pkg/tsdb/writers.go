@@ -0,0 +1,111 @@
+package tsdb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Influx writes line protocol to an InfluxDB write endpoint: v1
+// (http://host:8086/write?db=helix) or v2
+// (http://host:8086/api/v2/write?org=o&bucket=b, with Token).
+type Influx struct {
+	URL   string
+	Token string
+	// Client defaults to one with a 30s timeout.
+	Client *http.Client
+}
+
+func (w *Influx) Write(ctx context.Context, points []Point) error {
+	u, err := url.Parse(w.URL)
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	q.Set("precision", "ns")
+	u.RawQuery = q.Encode()
+	var body []byte
+	for _, p := range points {
+		body = AppendLine(body, p)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if w.Token != "" {
+		req.Header.Set("Authorization", "Token "+w.Token)
+	}
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("influx: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// Timescale writes points into one narrow hypertable, a row per field:
+// (time, measurement, tags JSONB, field, value).
+type Timescale struct {
+	DB    *sql.DB
+	Table string // default "helix_series"
+}
+
+func (w *Timescale) table() string {
+	if w.Table == "" {
+		return "helix_series"
+	}
+	return w.Table
+}
+
+// Migrate creates the table and makes it a hypertable if it isn't one.
+func (w *Timescale) Migrate(ctx context.Context) error {
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS ` + w.table() + ` (time TIMESTAMPTZ NOT NULL, measurement TEXT NOT NULL, tags JSONB NOT NULL, field TEXT NOT NULL, value DOUBLE PRECISION NOT NULL)`,
+		`SELECT create_hypertable('` + w.table() + `', 'time', if_not_exists => TRUE)`,
+		`CREATE INDEX IF NOT EXISTS ` + w.table() + `_series ON ` + w.table() + ` (measurement, field, time DESC)`,
+	} {
+		if _, err := w.DB.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("timescale: migrate: %w", err)
+		}
+	}
+	return nil
+}
+
+func (w *Timescale) Write(ctx context.Context, points []Point) error {
+	tx, err := w.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO `+w.table()+` (time, measurement, tags, field, value) VALUES ($1, $2, $3, $4, $5)`)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, p := range points {
+		tags, _ := json.Marshal(p.Tags)
+		for _, k := range sortedKeys(p.Fields) {
+			if _, err := stmt.ExecContext(ctx, p.Time.UTC(), p.Measurement, string(tags), k, p.Fields[k]); err != nil {
+				_ = tx.Rollback()
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,174 @@
+package ws
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// Policy decides what the Router does with connector updates while the
+// consumer of Updates() is not keeping up.
+type Policy int
+
+const (
+	// PolicyBlock stalls connectors until the consumer catches up.
+	PolicyBlock Policy = iota
+	// PolicyConflate keeps only the latest pending update per (venue,symbol).
+	PolicyConflate
+	// PolicyDropOldest keeps a fixed backlog and discards the oldest entry when full.
+	PolicyDropOldest
+	// PolicyGrowBounded buffers up to MaxBacklog updates, then blocks.
+	PolicyGrowBounded
+)
+
+func (p Policy) String() string {
+	switch p {
+	case PolicyBlock:
+		return "block"
+	case PolicyConflate:
+		return "conflate"
+	case PolicyDropOldest:
+		return "drop_oldest"
+	case PolicyGrowBounded:
+		return "grow_bounded"
+	default:
+		return fmt.Sprintf("policy(%d)", int(p))
+	}
+}
+
+// ParsePolicy maps a config/flag string onto a Policy.
+func ParsePolicy(s string) (Policy, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "block":
+		return PolicyBlock, nil
+	case "conflate":
+		return PolicyConflate, nil
+	case "drop_oldest", "drop-oldest":
+		return PolicyDropOldest, nil
+	case "grow_bounded", "grow-bounded":
+		return PolicyGrowBounded, nil
+	default:
+		return PolicyBlock, fmt.Errorf("unknown backpressure policy %q", s)
+	}
+}
+
+// BackpressureStats reports how often the policy had to engage.
+type BackpressureStats struct {
+	Policy       string
+	Backlog      int
+	HighWater    int
+	Conflated    uint64
+	Dropped      uint64
+	BlockedTimes uint64
+	// DroppedEvents counts trade/liquidation/funding events nobody was reading.
+	DroppedEvents uint64
+}
+
+type bookKey struct {
+	venue  string
+	symbol string
+}
+
+// pending is the Router-side backlog between connectors and Updates().
+// It is owned by the pump goroutine; counters are read concurrently.
+type pending struct {
+	policy Policy
+	limit  int
+
+	items []transport.DepthUpdate
+	// conflate only: position of the pending entry per book, counted from
+	// the first update ever queued so pops need not renumber it
+	index  map[bookKey]int
+	popped int
+
+	backlog      atomic.Int64
+	highWater    atomic.Int64
+	conflated    atomic.Uint64
+	dropped      atomic.Uint64
+	blockedTimes atomic.Uint64
+}
+
+func newPending(policy Policy, limit int) *pending {
+	if limit <= 0 {
+		limit = 1
+	}
+	if policy == PolicyBlock {
+		limit = 1
+	}
+	p := &pending{policy: policy, limit: limit}
+	if policy == PolicyConflate {
+		p.index = make(map[bookKey]int)
+	}
+	return p
+}
+
+func (p *pending) len() int { return len(p.items) }
+
+// full reports whether the pump must stop reading from connectors.
+func (p *pending) full() bool {
+	switch p.policy {
+	case PolicyBlock, PolicyGrowBounded:
+		return len(p.items) >= p.limit
+	default:
+		return false
+	}
+}
+
+func (p *pending) push(u transport.DepthUpdate) {
+	switch p.policy {
+	case PolicyConflate:
+		k := bookKey{venue: u.Venue, symbol: u.Symbol}
+		if i, ok := p.index[k]; ok {
+			p.items[i-p.popped] = u
+			p.conflated.Add(1)
+			return
+		}
+		p.index[k] = p.popped + len(p.items)
+		p.items = append(p.items, u)
+	case PolicyDropOldest:
+		if len(p.items) >= p.limit {
+			p.items = p.items[1:]
+			p.dropped.Add(1)
+		}
+		p.items = append(p.items, u)
+	default:
+		p.items = append(p.items, u)
+	}
+	p.track()
+}
+
+func (p *pending) peek() transport.DepthUpdate {
+	return p.items[0]
+}
+
+func (p *pending) pop() {
+	head := p.items[0]
+	p.items[0] = transport.DepthUpdate{}
+	p.items = p.items[1:]
+	if p.index != nil {
+		delete(p.index, bookKey{venue: head.Venue, symbol: head.Symbol})
+		p.popped++
+	}
+	p.track()
+}
+
+func (p *pending) track() {
+	n := int64(len(p.items))
+	p.backlog.Store(n)
+	if n > p.highWater.Load() {
+		p.highWater.Store(n)
+	}
+}
+
+func (p *pending) stats() BackpressureStats {
+	return BackpressureStats{
+		Policy:       p.policy.String(),
+		Backlog:      int(p.backlog.Load()),
+		HighWater:    int(p.highWater.Load()),
+		Conflated:    p.conflated.Load(),
+		Dropped:      p.dropped.Load(),
+		BlockedTimes: p.blockedTimes.Load(),
+	}
+}
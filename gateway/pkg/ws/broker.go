@@ -0,0 +1,119 @@
+package ws
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Subscription is one consumer's view of a broker stream.
+type Subscription[T any] struct {
+	name      string
+	ch        chan T
+	lossy     bool
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// C is the subscriber's channel.
+func (s *Subscription[T]) C() <-chan T { return s.ch }
+
+func (s *Subscription[T]) Name() string { return s.name }
+
+// SubscriberStats describes one subscriber's buffer and losses.
+type SubscriberStats struct {
+	Name      string
+	Lossy     bool
+	Buffered  int
+	Capacity  int
+	Delivered uint64
+	Dropped   uint64
+}
+
+func (s *Subscription[T]) stats() SubscriberStats {
+	return SubscriberStats{
+		Name:      s.name,
+		Lossy:     s.lossy,
+		Buffered:  len(s.ch),
+		Capacity:  cap(s.ch),
+		Delivered: s.delivered.Load(),
+		Dropped:   s.dropped.Load(),
+	}
+}
+
+// broker fans one stream out to many subscribers, each with its own buffer.
+// Lossless subscribers are sent to blocking, so a stalled one pushes back on
+// the Router's backpressure policy; lossy ones drop when their buffer is full.
+type broker[T any] struct {
+	mu   sync.Mutex
+	subs atomic.Pointer[[]*Subscription[T]]
+}
+
+func (b *broker[T]) subscribe(name string, buffer int, lossy bool) *Subscription[T] {
+	if buffer < 0 {
+		buffer = 0
+	}
+	s := &Subscription[T]{name: name, ch: make(chan T, buffer), lossy: lossy}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var next []*Subscription[T]
+	if cur := b.subs.Load(); cur != nil {
+		next = append(next, *cur...)
+	}
+	next = append(next, s)
+	b.subs.Store(&next)
+	return s
+}
+
+func (b *broker[T]) unsubscribe(s *Subscription[T]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	cur := b.subs.Load()
+	if cur == nil {
+		return
+	}
+	next := make([]*Subscription[T], 0, len(*cur))
+	for _, o := range *cur {
+		if o != s {
+			next = append(next, o)
+		}
+	}
+	b.subs.Store(&next)
+}
+
+// publish delivers v to every subscriber; done aborts a blocking send.
+func (b *broker[T]) publish(v T, done <-chan struct{}) bool {
+	cur := b.subs.Load()
+	if cur == nil {
+		return true
+	}
+	for _, s := range *cur {
+		if s.lossy {
+			select {
+			case s.ch <- v:
+				s.delivered.Add(1)
+			default:
+				s.dropped.Add(1)
+			}
+			continue
+		}
+		select {
+		case s.ch <- v:
+			s.delivered.Add(1)
+		case <-done:
+			return false
+		}
+	}
+	return true
+}
+
+func (b *broker[T]) stats() []SubscriberStats {
+	cur := b.subs.Load()
+	if cur == nil {
+		return nil
+	}
+	out := make([]SubscriberStats, 0, len(*cur))
+	for _, s := range *cur {
+		out = append(out, s.stats())
+	}
+	return out
+}
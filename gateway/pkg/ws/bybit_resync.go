@@ -0,0 +1,260 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+	"github.com/helix-lab/helix/gateway/pkg/ws/bybitjson"
+)
+
+const (
+	// bybitResyncAttempts is how many REST snapshots a gap recovery takes
+	// before it falls back to reconnecting.
+	bybitResyncAttempts = 3
+	// bybitMaxPending caps the deltas buffered while a snapshot is fetched.
+	bybitMaxPending    = 10000
+	bybitOrderbookPath = "/v5/market/orderbook"
+)
+
+// Recovery is one gap in a book's update ids and how it was repaired.
+type Recovery struct {
+	Venue    string
+	Symbol   string
+	Category string
+	// From is the last update applied before the gap, To the first one
+	// after it.
+	From, To int64
+	// SnapshotU is the update id of the REST snapshot the book was rebuilt
+	// from, Replayed the buffered deltas applied on top of it.
+	SnapshotU int64
+	Replayed  int
+	Attempts  int
+	At        time.Time
+	Took      time.Duration
+	// Err is why the recovery failed and the stream reconnected; empty
+	// when it succeeded.
+	Err string
+}
+
+// GapStats counts a connector's sequence gaps and their recoveries.
+type GapStats struct {
+	Gaps      uint64
+	Recovered uint64
+	Failed    uint64
+	Last      *Recovery
+}
+
+// GapReporter is implemented by connectors that check update sequences.
+type GapReporter interface {
+	Gaps() GapStats
+}
+
+// bookDelta is a buffered delta, copied out of the reused frame.
+type bookDelta struct {
+	u          int64
+	bids, asks []bybitjson.Level
+}
+
+func copyLevels(levels []bybitjson.Level) []bybitjson.Level {
+	out := make([]bybitjson.Level, len(levels))
+	for i, l := range levels {
+		out[i] = bybitjson.Level{Price: append([]byte(nil), l.Price...), Size: append([]byte(nil), l.Size...)}
+	}
+	return out
+}
+
+// bybitSnapshot is the result of GET /v5/market/orderbook.
+type bybitSnapshot struct {
+	Symbol string      `json:"s"`
+	Bids   [][2]string `json:"b"`
+	Asks   [][2]string `json:"a"`
+	U      int64       `json:"u"`
+}
+
+func levelsOf(pairs [][2]string) []bybitjson.Level {
+	out := make([]bybitjson.Level, len(pairs))
+	for i, p := range pairs {
+		out[i] = bybitjson.Level{Price: []byte(p[0]), Size: []byte(p[1])}
+	}
+	return out
+}
+
+// snapshotLimit is the REST depth matching the stream's, within what
+// Bybit serves for the category.
+func (b *BybitStream) snapshotLimit(symbol string) int {
+	depth := b.Depth
+	if d, ok := b.SymbolDepth[symbol]; ok && d > 0 {
+		depth = d
+	}
+	limit := 500
+	switch b.Category {
+	case "spot":
+		limit = 200
+	case "option":
+		limit = 25
+	}
+	return max(1, min(depth, limit))
+}
+
+// fetchSnapshot reads symbol's book from the REST API.
+func (b *BybitStream) fetchSnapshot(ctx context.Context, symbol string) (bybitSnapshot, error) {
+	var snap bybitSnapshot
+	b.mu.Lock()
+	limit := b.snapshotLimit(symbol)
+	b.mu.Unlock()
+	category := b.Category
+	if category == "" {
+		category = "linear"
+	}
+	q := url.Values{"category": {category}, "symbol": {symbol}, "limit": {strconv.Itoa(limit)}}
+	if b.Limits != nil {
+		if err := b.Limits.Wait(ctx, b.Name, bybitOrderbookPath); err != nil {
+			return snap, err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.REST+bybitOrderbookPath+"?"+q.Encode(), nil)
+	if err != nil {
+		return snap, err
+	}
+	client := b.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return snap, err
+	}
+	defer resp.Body.Close()
+	if b.Limits != nil {
+		b.Limits.ObserveResponse(b.Name, bybitOrderbookPath, resp)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return snap, fmt.Errorf("status %s", resp.Status)
+	}
+	var body struct {
+		RetCode int           `json:"retCode"`
+		RetMsg  string        `json:"retMsg"`
+		Result  bybitSnapshot `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return snap, err
+	}
+	if body.RetCode != 0 {
+		return snap, fmt.Errorf("retCode %d retMsg %s", body.RetCode, body.RetMsg)
+	}
+	return body.Result, nil
+}
+
+var errSuperseded = errors.New("superseded by a stream snapshot")
+
+// rebuild replaces symbol's book with snap and replays the deltas buffered
+// after it. It fails, leaving the book recovering, while the deltas do not
+// continue from the snapshot.
+func (b *BybitStream) rebuild(symbol string, snap bybitSnapshot, rec *Recovery) (transport.DepthUpdate, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	book := b.books[symbol]
+	if book == nil || !book.resyncing {
+		return transport.DepthUpdate{}, false, errSuperseded
+	}
+	pending := book.pending
+	for len(pending) > 0 && pending[0].u <= snap.U {
+		pending = pending[1:]
+	}
+	next := snap.U + 1
+	for _, d := range pending {
+		if d.u != next {
+			return transport.DepthUpdate{}, false, fmt.Errorf("snapshot at u=%d, deltas resume at u=%d", snap.U, d.u)
+		}
+		next++
+	}
+	book.reset()
+	book.apply(levelsOf(snap.Bids), true, nil)
+	book.apply(levelsOf(snap.Asks), false, nil)
+	for _, d := range pending {
+		book.apply(d.bids, true, nil)
+		book.apply(d.asks, false, nil)
+	}
+	book.lastU = next - 1
+	book.resyncing, book.pending = false, nil
+	rec.SnapshotU, rec.Replayed = snap.U, len(pending)
+	update, ok := b.top(book)
+	return update, ok, nil
+}
+
+// resync repairs symbol's book after a gap from REST snapshots, and
+// reconnects the stream when that keeps failing.
+func (b *BybitStream) resync(ctx context.Context, out Feeds, rec Recovery) {
+	var err error
+	for rec.Attempts < bybitResyncAttempts && ctx.Err() == nil {
+		rec.Attempts++
+		var snap bybitSnapshot
+		if snap, err = b.fetchSnapshot(ctx, rec.Symbol); err == nil {
+			var update transport.DepthUpdate
+			var ok bool
+			update, ok, err = b.rebuild(rec.Symbol, snap, &rec)
+			if errors.Is(err, errSuperseded) {
+				return
+			}
+			if err == nil {
+				rec.Took = time.Since(rec.At)
+				b.recovered(rec)
+				if ok {
+					update.RecvNs = time.Now().UnixNano()
+					send(ctx, out.DepthFor(rec.Symbol), update)
+				}
+				return
+			}
+		}
+		// the next snapshot is fresher
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Duration(rec.Attempts) * 200 * time.Millisecond):
+		}
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	rec.Err, rec.Took = err.Error(), time.Since(rec.At)
+	b.recovered(rec)
+	// the book stays recovering until the new connection's snapshot
+	b.mu.Lock()
+	if b.restart != nil {
+		b.restart()
+	}
+	b.mu.Unlock()
+}
+
+func (b *BybitStream) recovered(rec Recovery) {
+	b.mu.Lock()
+	if rec.Err == "" {
+		b.gaps.Recovered++
+	} else {
+		b.gaps.Failed++
+	}
+	b.gaps.Last = &rec
+	b.mu.Unlock()
+	if b.Logf == nil {
+		return
+	}
+	if rec.Err != "" {
+		b.Logf("%s %s: gap after u=%d not recovered in %d attempts, reconnecting: %s", rec.Venue, rec.Symbol, rec.From, rec.Attempts, rec.Err)
+		return
+	}
+	b.Logf("%s %s: gap u=%d..%d recovered from a REST snapshot at u=%d, %d deltas replayed in %v",
+		rec.Venue, rec.Symbol, rec.From, rec.To, rec.SnapshotU, rec.Replayed, rec.Took.Round(time.Millisecond))
+}
+
+// Gaps reports the update-id gaps seen and how they were recovered.
+func (b *BybitStream) Gaps() GapStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.gaps
+}
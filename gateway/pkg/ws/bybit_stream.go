@@ -0,0 +1,421 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/decimal"
+	"github.com/helix-lab/helix/gateway/pkg/ratelimit"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+	"github.com/helix-lab/helix/gateway/pkg/ws/bybitjson"
+	"github.com/helix-lab/helix/gateway/pkg/wsclient"
+)
+
+const (
+	// BybitMaxArgsPerRequest is Bybit's limit on args in one subscribe request.
+	BybitMaxArgsPerRequest = 10
+	// BybitMaxTopicsPerConn is how many topics we multiplex on one connection
+	// before sharding onto another.
+	BybitMaxTopicsPerConn = 100
+)
+
+type bybitEnvelope struct {
+	Topic string          `json:"topic"`
+	Type  string          `json:"type"`
+	Ts    int64           `json:"ts"`
+	Data  json.RawMessage `json:"data"`
+}
+
+type bybitTrade struct {
+	Ts     int64  `json:"T"`
+	Symbol string `json:"s"`
+	Side   string `json:"S"`
+	Size   string `json:"v"`
+	Price  string `json:"p"`
+}
+
+type bybitLiquidation struct {
+	Ts     int64  `json:"T"`
+	Symbol string `json:"s"`
+	Side   string `json:"S"`
+	Size   string `json:"v"`
+	Price  string `json:"p"`
+}
+
+type bybitTicker struct {
+	Symbol          string `json:"symbol"`
+	FundingRate     string `json:"fundingRate"`
+	MarkPrice       string `json:"markPrice"`
+	NextFundingTime string `json:"nextFundingTime"`
+}
+
+// BybitStream is a live Bybit v5 public connector. All symbols share as few
+// connections as the venue limits allow. Trade, liquidation and funding
+// topics are opt-in because they multiply the subscription count.
+type BybitStream struct {
+	// Name is the venue the stream publishes as, BYBIT unless a gateway
+	// runs more than one Bybit market.
+	Name string
+	// Category stamps every book; NewBybitStream takes it from the
+	// endpoint's path (…/v5/public/spot).
+	Category string
+	Endpoint string
+	Symbols  []string
+	Depth    int
+	// SymbolDepth overrides Depth per symbol.
+	SymbolDepth  map[string]int
+	Trades       bool
+	Liquidations bool
+	Funding      bool
+	// BookEvents derives level and BBO events from the books onto
+	// Feeds.BookEvents.
+	BookEvents       bool
+	MaxTopicsPerConn int
+	Logf             func(format string, args ...any)
+	// Tap tees raw frames to the recording subsystem, see capture.FrameLog.
+	Tap func(recvNs int64, frame []byte)
+	// REST is the venue's HTTP API base. When set, a gap in a book's
+	// update ids is repaired from a REST snapshot, with the deltas that
+	// arrive meanwhile replayed on top; otherwise the gap is only counted.
+	REST   string
+	Client *http.Client
+	// Limits, if set, paces the snapshot requests.
+	Limits *ratelimit.Limiter
+
+	mu    sync.Mutex
+	gaps  GapStats
+	books map[string]*l2Book
+	// tickers merges ticker deltas, which only carry changed fields.
+	tickers map[string]transport.FundingRate
+	pool    *wsclient.Pool
+	restart context.CancelFunc
+}
+
+func NewBybitStream(endpoint string, symbols []string, depth int) *BybitStream {
+	category := path.Base(endpoint)
+	switch category {
+	case "spot", "linear", "inverse", "option":
+	default:
+		category = ""
+	}
+	return &BybitStream{
+		Name:             "BYBIT",
+		Category:         category,
+		Endpoint:         endpoint,
+		Symbols:          symbols,
+		Depth:            depth,
+		MaxTopicsPerConn: BybitMaxTopicsPerConn,
+		books:            make(map[string]*l2Book),
+		tickers:          make(map[string]transport.FundingRate),
+	}
+}
+
+func (b *BybitStream) Venue() string { return b.Name }
+
+// bookFrames recycles decoded orderbook frames across HandleFrame calls,
+// which run concurrently on the pool's connections.
+var bookFrames = sync.Pool{New: func() any { return new(bybitjson.Book) }}
+
+func (b *BybitStream) topics() []string {
+	topics := make([]string, 0, len(b.Symbols))
+	for _, sym := range b.Symbols {
+		depth := b.Depth
+		if d, ok := b.SymbolDepth[sym]; ok && d > 0 {
+			depth = d
+		}
+		topics = append(topics, fmt.Sprintf("orderbook.%d.%s", depth, sym))
+		if b.Trades {
+			topics = append(topics, "publicTrade."+sym)
+		}
+		if b.Liquidations {
+			topics = append(topics, "allLiquidation."+sym)
+		}
+		if b.Funding {
+			topics = append(topics, "tickers."+sym)
+		}
+	}
+	return topics
+}
+
+// HandleFrame parses one public-stream frame received at recvNs and
+// forwards what it carries to out. It reports whether the frame carried
+// market data; Run uses it live and replay tools feed it recorded frames.
+func (b *BybitStream) HandleFrame(ctx context.Context, out Feeds, recvNs int64, frame []byte) bool {
+	// orderbook frames, the bulk of the stream, take the allocation-free
+	// decoder; the rest go through encoding/json
+	book := bookFrames.Get().(*bybitjson.Book)
+	defer bookFrames.Put(book)
+	if err := book.Decode(frame); err != nil || len(book.Topic) == 0 {
+		return false
+	}
+	if book.IsOrderbook() {
+		if len(book.Symbol) == 0 {
+			return false
+		}
+		update, ok, gap, events := b.apply(book)
+		if gap != nil {
+			go b.resync(ctx, out, *gap)
+		}
+		for _, ev := range events {
+			ev.Venue, ev.Category, ev.ExchTsMs, ev.RecvNs = b.Venue(), b.Category, book.Ts, recvNs
+			send(ctx, out.BookEvents, ev)
+		}
+		if ok {
+			update.ExchTsMs = book.Ts
+			update.RecvNs = recvNs
+			send(ctx, out.DepthFor(update.Symbol), update)
+		}
+		return true
+	}
+	var msg bybitEnvelope
+	if err := json.Unmarshal(frame, &msg); err != nil || msg.Topic == "" || len(msg.Data) == 0 {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(msg.Topic, "publicTrade."):
+		var data []bybitTrade
+		if err := json.Unmarshal(msg.Data, &data); err != nil {
+			return false
+		}
+		for _, t := range data {
+			px, qty, ok := priceQty(t.Price, t.Size)
+			if !ok {
+				continue
+			}
+			send(ctx, out.Trades, transport.Trade{
+				Venue:  b.Venue(),
+				Symbol: t.Symbol,
+				Side:   strings.ToUpper(t.Side),
+				Price:  px,
+				Qty:    qty,
+				TsMs:   t.Ts,
+			})
+		}
+	case strings.HasPrefix(msg.Topic, "allLiquidation."):
+		var data []bybitLiquidation
+		if err := json.Unmarshal(msg.Data, &data); err != nil {
+			return false
+		}
+		for _, l := range data {
+			px, qty, ok := priceQty(l.Price, l.Size)
+			if !ok {
+				continue
+			}
+			send(ctx, out.Liquidations, transport.Liquidation{
+				Venue:  b.Venue(),
+				Symbol: l.Symbol,
+				Side:   l.Side,
+				Price:  px,
+				Qty:    qty,
+				TsMs:   l.Ts,
+			})
+		}
+	case strings.HasPrefix(msg.Topic, "tickers."):
+		var data bybitTicker
+		if err := json.Unmarshal(msg.Data, &data); err != nil {
+			return false
+		}
+		rate, errRate := strconv.ParseFloat(data.FundingRate, 64)
+		mark, errMark := strconv.ParseFloat(data.MarkPrice, 64)
+		if errRate != nil && errMark != nil {
+			return true
+		}
+		b.mu.Lock()
+		fr, seen := b.tickers[data.Symbol]
+		// a mark before the first rate would publish a zero rate
+		if errRate != nil && !seen {
+			b.mu.Unlock()
+			return true
+		}
+		if errRate == nil {
+			fr.Rate = rate
+		}
+		if errMark == nil {
+			fr.Mark = mark
+		}
+		if next, err := strconv.ParseInt(data.NextFundingTime, 10, 64); err == nil {
+			fr.NextFundingMs = next
+		}
+		fr.Venue, fr.Symbol, fr.TsMs = b.Venue(), data.Symbol, msg.Ts
+		b.tickers[data.Symbol] = fr
+		b.mu.Unlock()
+		send(ctx, out.Funding, fr)
+	default:
+		return false
+	}
+	return true
+}
+
+func (b *BybitStream) Run(ctx context.Context, out Feeds) {
+	handle := func(frame []byte) bool {
+		return b.HandleFrame(ctx, out, time.Now().UnixNano(), frame)
+	}
+
+	// SetSymbols cancels the current pool; it is rebuilt with the new topics
+	for ctx.Err() == nil {
+		poolCtx, cancel := context.WithCancel(ctx)
+		b.mu.Lock()
+		topics := b.topics()
+		pool := wsclient.NewPool(wsclient.Config{
+			Endpoint:          b.Endpoint,
+			Topics:            topics,
+			MaxArgsPerRequest: BybitMaxArgsPerRequest,
+			Logf:              b.Logf,
+			Tap:               b.Tap,
+			OnMessage:         handle,
+		}, b.MaxTopicsPerConn)
+		b.pool = pool
+		b.restart = cancel
+		b.mu.Unlock()
+		if len(topics) == 0 {
+			<-poolCtx.Done()
+		} else {
+			_ = pool.Run(poolCtx)
+		}
+		cancel()
+	}
+}
+
+// Subscribed returns the symbols currently streamed.
+func (b *BybitStream) Subscribed() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.Symbols...)
+}
+
+// SetSymbols replaces the symbol set. A running stream reconnects with the
+// new subscriptions; books of dropped symbols are discarded.
+func (b *BybitStream) SetSymbols(symbols []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.Symbols = append([]string(nil), symbols...)
+	for sym := range b.books {
+		if !containsSymbol(symbols, sym) {
+			delete(b.books, sym)
+		}
+	}
+	if b.restart != nil {
+		b.restart()
+	}
+}
+
+// BookSnapshots returns the top depth levels of every book, skipping
+// books that are empty on a side or recovering from a gap.
+func (b *BybitStream) BookSnapshots(depth int) []transport.BookSnapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now().UnixMilli()
+	out := make([]transport.BookSnapshot, 0, len(b.books))
+	for _, book := range b.books {
+		if book.resyncing || book.bids.Len() == 0 || book.asks.Len() == 0 {
+			continue
+		}
+		out = append(out, transport.BookSnapshot{Venue: b.Venue(), Symbol: book.symbol, Category: b.Category,
+			Bids: book.levels(true, depth), Asks: book.levels(false, depth), ExchTsMs: book.tsMs, TsMs: now})
+	}
+	return out
+}
+
+// Health reports each shard connection; empty until Run has started.
+func (b *BybitStream) Health() []wsclient.Health {
+	b.mu.Lock()
+	pool := b.pool
+	b.mu.Unlock()
+	if pool == nil {
+		return nil
+	}
+	return pool.Health()
+}
+
+// apply merges one orderbook frame into its book. A delta whose update id
+// does not follow the last one applied starts a recovery, returned as gap
+// when the stream has a REST API to recover from; deltas arriving until
+// it completes are buffered. With BookEvents on it returns the frame's
+// book events: a snapshot or resync yields only BBO moves, not the
+// rebuilt levels.
+func (b *BybitStream) apply(data *bybitjson.Book) (update transport.DepthUpdate, ok bool, gap *Recovery, events []transport.BookEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	book, found := b.books[string(data.Symbol)]
+	if !found {
+		book = newL2Book(string(data.Symbol))
+		b.books[book.symbol] = book
+	}
+	switch {
+	case data.IsSnapshot():
+		book.reset()
+		book.resyncing, book.pending = false, nil
+	case book.resyncing:
+		if len(book.pending) < bybitMaxPending {
+			book.pending = append(book.pending, bookDelta{u: data.U, bids: copyLevels(data.Bids), asks: copyLevels(data.Asks)})
+		}
+		return transport.DepthUpdate{}, false, nil, nil
+	case book.lastU > 0 && data.U > 0 && data.U != book.lastU+1:
+		b.gaps.Gaps++
+		if b.REST != "" {
+			book.resyncing = true
+			book.pending = append(book.pending[:0], bookDelta{u: data.U, bids: copyLevels(data.Bids), asks: copyLevels(data.Asks)})
+			return transport.DepthUpdate{}, false, &Recovery{Venue: b.Venue(), Symbol: book.symbol, Category: b.Category,
+				From: book.lastU, To: data.U, At: time.Now()}, nil
+		}
+	}
+	var levelEvents *[]transport.BookEvent
+	if b.BookEvents && !data.IsSnapshot() {
+		levelEvents = &events
+	}
+	book.apply(data.Bids, true, levelEvents)
+	book.apply(data.Asks, false, levelEvents)
+	book.lastU, book.tsMs = data.U, data.Ts
+	if b.BookEvents {
+		events = book.bboEvents(events, book.bidBefore, book.askBefore)
+		book.bidBefore, _, book.askBefore, _ = book.top()
+	}
+	update, ok = b.top(book)
+	return update, ok, nil, events
+}
+
+// top is book's top of book, not ok while a side is empty.
+func (b *BybitStream) top(book *l2Book) (transport.DepthUpdate, bool) {
+	bestBid, bidSz, bestAsk, askSz := book.top()
+	if bestBid == 0 || bestAsk == 0 {
+		return transport.DepthUpdate{}, false
+	}
+	return transport.DepthUpdate{
+		Venue:    b.Venue(),
+		Symbol:   book.symbol,
+		Category: b.Category,
+		BestBid:  bestBid,
+		BestAsk:  bestAsk,
+		BidSize:  bidSz,
+		AskSize:  askSz,
+	}, true
+}
+
+// priceQty converts an execution's price and size; ok is false unless
+// both are positive decimals.
+func priceQty(price, size string) (px, qty float64, ok bool) {
+	p, errP := decimal.Parse(price)
+	q, errQ := decimal.Parse(size)
+	if errP != nil || errQ != nil || p <= 0 || q <= 0 {
+		return 0, 0, false
+	}
+	return p.Float(), q.Float(), true
+}
+
+// send delivers v unless the feed is disabled (nil) or ctx is done.
+func send[T any](ctx context.Context, ch chan<- T, v T) {
+	if ch == nil {
+		return
+	}
+	select {
+	case ch <- v:
+	case <-ctx.Done():
+	}
+}
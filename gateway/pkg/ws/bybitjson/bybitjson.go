@@ -0,0 +1,275 @@
+// Package bybitjson decodes Bybit orderbook frames without allocating. It
+// is the hot path under the venue normalization layer: encoding/json
+// allocates for every level of every message, which dominates CPU at deep
+// books across many symbols. Decoded fields alias the frame, so a Book is
+// only valid until the frame's buffer is reused.
+package bybitjson
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+)
+
+// ErrSyntax is returned for frames that are not a JSON object this decoder
+// can walk.
+var ErrSyntax = errors.New("bybitjson: malformed frame")
+
+// Level is one [price, size] pair, as the exchange's decimal strings;
+// decimal.ParseBytes converts them without allocating.
+type Level struct {
+	Price, Size []byte
+}
+
+// Book is one orderbook frame:
+//
+//	{"topic":..,"type":..,"ts":..,"data":{"s":..,"b":[[px,sz],..],"a":[..],"u":..,"seq":..,"pu":..}}
+//
+// Unknown keys are skipped. Reuse one Book per connection; Decode keeps the
+// level slices' capacity.
+type Book struct {
+	Topic  []byte
+	Type   []byte
+	Ts     int64
+	Symbol []byte
+	// Seq is Bybit's cross sequence, U the update id and Pu the previous
+	// update id (when the venue sends one).
+	Seq, U, Pu int64
+	Bids, Asks []Level
+}
+
+// IsSnapshot reports whether the frame replaces the book.
+func (m *Book) IsSnapshot() bool { return string(m.Type) == "snapshot" }
+
+// IsOrderbook reports whether the frame is on an orderbook topic.
+func (m *Book) IsOrderbook() bool { return bytes.HasPrefix(m.Topic, []byte("orderbook.")) }
+
+// Decode parses frame into m. A "data" value that is not an object (trade
+// and liquidation frames) is skipped, leaving the book fields empty.
+func (m *Book) Decode(frame []byte) error {
+	*m = Book{Bids: m.Bids[:0], Asks: m.Asks[:0]}
+	s := scanner{b: frame}
+	ok := s.object(func(key []byte) bool {
+		switch string(key) {
+		case "topic":
+			m.Topic, _ = s.str()
+			return m.Topic != nil
+		case "type":
+			m.Type, _ = s.str()
+			return m.Type != nil
+		case "ts":
+			return s.int(&m.Ts)
+		case "data":
+			if s.peek() != '{' {
+				return s.skip()
+			}
+			return s.object(func(key []byte) bool {
+				switch string(key) {
+				case "s":
+					m.Symbol, _ = s.str()
+					return m.Symbol != nil
+				case "seq":
+					return s.int(&m.Seq)
+				case "u":
+					return s.int(&m.U)
+				case "pu":
+					return s.int(&m.Pu)
+				case "b":
+					return s.levels(&m.Bids)
+				case "a":
+					return s.levels(&m.Asks)
+				}
+				return s.skip()
+			})
+		}
+		return s.skip()
+	})
+	if !ok {
+		return ErrSyntax
+	}
+	return nil
+}
+
+type scanner struct {
+	b []byte
+	i int
+}
+
+func (s *scanner) peek() byte {
+	for s.i < len(s.b) {
+		switch c := s.b[s.i]; c {
+		case ' ', '\t', '\n', '\r':
+			s.i++
+		default:
+			return c
+		}
+	}
+	return 0
+}
+
+func (s *scanner) consume(c byte) bool {
+	if s.peek() != c {
+		return false
+	}
+	s.i++
+	return true
+}
+
+// object walks an object, calling fn with the scanner on each key's value;
+// fn must consume the value.
+func (s *scanner) object(fn func(key []byte) bool) bool {
+	if !s.consume('{') {
+		return false
+	}
+	if s.consume('}') {
+		return true
+	}
+	for {
+		key, ok := s.str()
+		if !ok || !s.consume(':') || !fn(key) {
+			return false
+		}
+		if s.consume(',') {
+			continue
+		}
+		return s.consume('}')
+	}
+}
+
+// str returns a string's raw contents; escapes are left as they are.
+func (s *scanner) str() ([]byte, bool) {
+	if !s.consume('"') {
+		return nil, false
+	}
+	start := s.i
+	for s.i < len(s.b) {
+		switch s.b[s.i] {
+		case '"':
+			v := s.b[start:s.i:s.i]
+			s.i++
+			return v, true
+		case '\\':
+			s.i++
+		}
+		s.i++
+	}
+	return nil, false
+}
+
+// scalar returns a string's contents or a bare literal's bytes.
+func (s *scanner) scalar() ([]byte, bool) {
+	if s.peek() == '"' {
+		return s.str()
+	}
+	start := s.i
+	for s.i < len(s.b) {
+		switch s.b[s.i] {
+		case ',', '}', ']', ' ', '\t', '\n', '\r':
+			return s.b[start:s.i:s.i], s.i > start
+		}
+		s.i++
+	}
+	return s.b[start:s.i:s.i], s.i > start
+}
+
+// int parses an integer; a quoted one is accepted too.
+func (s *scanner) int(v *int64) bool {
+	b, ok := s.scalar()
+	if !ok {
+		return false
+	}
+	if string(b) == "null" {
+		return true
+	}
+	n, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return false
+	}
+	*v = n
+	return true
+}
+
+// levels sets dst to the [price, size, ...] arrays; extra elements are
+// ignored and pairs with fewer than two are dropped. A repeated key
+// replaces the levels, as with encoding/json.
+func (s *scanner) levels(dst *[]Level) bool {
+	*dst = (*dst)[:0]
+	if s.peek() == 'n' {
+		return s.skip()
+	}
+	if !s.consume('[') {
+		return false
+	}
+	if s.consume(']') {
+		return true
+	}
+	for {
+		if !s.consume('[') {
+			return false
+		}
+		var lvl Level
+		n := 0
+		if !s.consume(']') {
+			for {
+				v, ok := s.scalar()
+				if !ok {
+					return false
+				}
+				switch n {
+				case 0:
+					lvl.Price = v
+				case 1:
+					lvl.Size = v
+				}
+				n++
+				if s.consume(',') {
+					continue
+				}
+				if !s.consume(']') {
+					return false
+				}
+				break
+			}
+		}
+		if n >= 2 {
+			*dst = append(*dst, lvl)
+		}
+		if s.consume(',') {
+			continue
+		}
+		return s.consume(']')
+	}
+}
+
+// skip consumes any value.
+func (s *scanner) skip() bool {
+	switch s.peek() {
+	case '"':
+		_, ok := s.str()
+		return ok
+	case '{', '[':
+	default:
+		_, ok := s.scalar()
+		return ok
+	}
+	depth := 0
+	for s.i < len(s.b) {
+		switch s.b[s.i] {
+		case '"':
+			if _, ok := s.str(); !ok {
+				return false
+			}
+			continue
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				s.i++
+				return true
+			}
+		}
+		s.i++
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TimeSource reads one venue's server clock.
+type TimeSource interface {
+	Venue() string
+	ServerTime(ctx context.Context) (time.Time, error)
+}
+
+// BybitTime reads GET /v5/market/time.
+type BybitTime struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewBybitTime() *BybitTime {
+	return &BybitTime{URL: "https://api.bybit.com/v5/market/time", Client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (b *BybitTime) Venue() string { return "BYBIT" }
+
+func (b *BybitTime) ServerTime(ctx context.Context) (time.Time, error) {
+	var body struct {
+		RetCode int    `json:"retCode"`
+		RetMsg  string `json:"retMsg"`
+		Result  struct {
+			TimeNano string `json:"timeNano"`
+		} `json:"result"`
+		Time int64 `json:"time"`
+	}
+	if err := getJSON(ctx, b.Client, b.URL, &body); err != nil {
+		return time.Time{}, err
+	}
+	if body.RetCode != 0 {
+		return time.Time{}, fmt.Errorf("retCode %d retMsg %s", body.RetCode, body.RetMsg)
+	}
+	if ns, err := strconv.ParseInt(body.Result.TimeNano, 10, 64); err == nil && ns > 0 {
+		return time.Unix(0, ns), nil
+	}
+	if body.Time <= 0 {
+		return time.Time{}, fmt.Errorf("no server time in response")
+	}
+	return time.UnixMilli(body.Time), nil
+}
+
+// BinanceTime reads GET /api/v3/time ({"serverTime":ms}).
+type BinanceTime struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewBinanceTime() *BinanceTime {
+	return &BinanceTime{URL: "https://api.binance.com/api/v3/time", Client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (b *BinanceTime) Venue() string { return "BINANCE" }
+
+func (b *BinanceTime) ServerTime(ctx context.Context) (time.Time, error) {
+	var body struct {
+		ServerTime int64 `json:"serverTime"`
+	}
+	if err := getJSON(ctx, b.Client, b.URL, &body); err != nil {
+		return time.Time{}, err
+	}
+	if body.ServerTime <= 0 {
+		return time.Time{}, fmt.Errorf("no server time in response")
+	}
+	return time.UnixMilli(body.ServerTime), nil
+}
+
+// ClockOffset is the current skew estimate for one venue. Offset is venue
+// clock minus local clock; subtract it from a venue timestamp to express it
+// in local time.
+type ClockOffset struct {
+	Venue     string
+	Offset    time.Duration
+	RTT       time.Duration
+	Samples   int
+	CheckedAt time.Time
+	// Drift is set while |Offset| exceeds the monitor's MaxSkew.
+	Drift bool
+	Err   string
+}
+
+type clockSample struct {
+	offset time.Duration
+	rtt    time.Duration
+}
+
+// ClockMonitor polls every source NTP-style: the server time is assumed to
+// be taken halfway through the request, and the estimate is the sample with
+// the smallest RTT in the last Window polls, since that one has the least
+// room for asymmetric delay.
+type ClockMonitor struct {
+	Interval time.Duration
+	Window   int
+	// MaxSkew raises the Drift flag; 0 disables drift alerts.
+	MaxSkew time.Duration
+	Logf    func(format string, args ...any)
+
+	sources []TimeSource
+	mu      sync.RWMutex
+	samples map[string][]clockSample
+	current map[string]ClockOffset
+}
+
+func NewClockMonitor(interval time.Duration, sources ...TimeSource) *ClockMonitor {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &ClockMonitor{
+		Interval: interval,
+		Window:   8,
+		sources:  sources,
+		samples:  make(map[string][]clockSample),
+		current:  make(map[string]ClockOffset),
+	}
+}
+
+func (m *ClockMonitor) Run(ctx context.Context) {
+	m.Poll(ctx)
+	t := time.NewTicker(m.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			m.Poll(ctx)
+		}
+	}
+}
+
+// Poll samples every source once.
+func (m *ClockMonitor) Poll(ctx context.Context) {
+	for _, src := range m.sources {
+		t0 := time.Now()
+		server, err := src.ServerTime(ctx)
+		t1 := time.Now()
+		venue := src.Venue()
+
+		m.mu.Lock()
+		cur := m.current[venue]
+		cur.Venue = venue
+		cur.CheckedAt = t1
+		if err != nil {
+			cur.Err = err.Error()
+			m.current[venue] = cur
+			m.mu.Unlock()
+			continue
+		}
+		rtt := t1.Sub(t0)
+		s := append(m.samples[venue], clockSample{offset: server.Sub(t0.Add(rtt / 2)), rtt: rtt})
+		if w := m.Window; w > 0 && len(s) > w {
+			s = s[len(s)-w:]
+		}
+		m.samples[venue] = s
+		best := s[0]
+		for _, c := range s[1:] {
+			if c.rtt < best.rtt {
+				best = c
+			}
+		}
+		wasDrift := cur.Drift
+		cur.Offset, cur.RTT, cur.Samples, cur.Err = best.offset, best.rtt, len(s), ""
+		cur.Drift = m.MaxSkew > 0 && (best.offset > m.MaxSkew || best.offset < -m.MaxSkew)
+		m.current[venue] = cur
+		m.mu.Unlock()
+
+		if cur.Drift != wasDrift && m.Logf != nil {
+			if cur.Drift {
+				m.Logf("clock %s drift: offset=%s exceeds %s (rtt=%s)", venue, cur.Offset, m.MaxSkew, cur.RTT)
+			} else {
+				m.Logf("clock %s back within %s: offset=%s", venue, m.MaxSkew, cur.Offset)
+			}
+		}
+	}
+}
+
+func (m *ClockMonitor) Estimate(venue string) (ClockOffset, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	c, ok := m.current[venue]
+	return c, ok && c.Samples > 0
+}
+
+// Offset returns the venue's skew, or 0 before the first good sample.
+func (m *ClockMonitor) Offset(venue string) time.Duration {
+	c, _ := m.Estimate(venue)
+	return c.Offset
+}
+
+// Estimates returns every venue's latest estimate.
+func (m *ClockMonitor) Estimates() []ClockOffset {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]ClockOffset, 0, len(m.sources))
+	for _, src := range m.sources {
+		if c, ok := m.current[src.Venue()]; ok {
+			out = append(out, c)
+		}
+	}
+	return out
+}
@@ -0,0 +1,99 @@
+package ws
+
+import (
+	"context"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// Feeds are the channels a connector publishes into. Trades, Liquidations
+// and Funding may be nil when the consumer does not want them.
+type Feeds struct {
+	Depth        chan<- transport.DepthUpdate
+	Trades       chan<- transport.Trade
+	Liquidations chan<- transport.Liquidation
+	Funding      chan<- transport.FundingRate
+	BookEvents   chan<- transport.BookEvent
+
+	// the sharded Router's per-shard intakes, for DepthFor
+	lanes []chan<- transport.DepthUpdate
+}
+
+// Connector streams market data for one venue until ctx is done.
+type Connector interface {
+	Venue() string
+	Run(ctx context.Context, out Feeds)
+}
+
+// SymbolSubscriber is implemented by connectors whose symbols can change
+// while they run.
+type SymbolSubscriber interface {
+	Subscribed() []string
+	SetSymbols(symbols []string)
+}
+
+// BookSnapshotter is implemented by connectors that keep full books.
+type BookSnapshotter interface {
+	// BookSnapshots returns the top depth levels of every book that has
+	// both sides.
+	BookSnapshots(depth int) []transport.BookSnapshot
+}
+
+// Wrapper is implemented by connectors that decorate another, e.g. to
+// inject faults. The Router looks through wrappers for the optional
+// interfaces above.
+type Wrapper interface {
+	Unwrap() Connector
+}
+
+// Unwrap is the connector innermost in c's wrappers.
+func Unwrap(c Connector) Connector {
+	for {
+		w, ok := c.(Wrapper)
+		if !ok {
+			return c
+		}
+		c = w.Unwrap()
+	}
+}
+
+func containsSymbol(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// SyntheticConnectors returns the simulated Bybit/Binance feeds used when no
+// live connector is registered. seed 0 keeps the built-in seeds.
+func SyntheticConnectors(seed int64) []Connector {
+	var out []Connector
+	for _, cfg := range SyntheticConfigs(seed) {
+		out = append(out, NewSimConnector(cfg))
+	}
+	return out
+}
+
+// SyntheticConfigs are the demo BYBIT and BINANCE models; a non-zero seed
+// replaces the built-in ones.
+func SyntheticConfigs(seed int64) []SimConfig {
+	bybit := DefaultSimConfig("BYBIT")
+	bybit.Seed = 1
+	bybit.StartPrice = 100.0
+	bybit.SpreadBps = 40
+
+	binance := DefaultSimConfig("BINANCE")
+	binance.Seed = 2
+	binance.Interval = 220 * time.Millisecond
+	binance.StartPrice = 99.8
+	binance.SpreadBps = 35
+
+	if seed != 0 {
+		bybit.Seed = seed
+		binance.Seed = seed + 1
+	}
+	return []SimConfig{bybit, binance}
+}
@@ -0,0 +1,105 @@
+package ws
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/wsclient"
+)
+
+// HealthReporter is implemented by connectors backed by real connections.
+type HealthReporter interface {
+	Health() []wsclient.Health
+}
+
+// ConnectorHealth summarises one venue feed for operators.
+type ConnectorHealth struct {
+	Venue string
+	// Status is "ok", "degraded", "down" or "maintenance".
+	Status      string
+	Maintenance *VenueStatus
+	LastUpdate  time.Time
+	Reconnects  uint64
+	// Gaps are the feed's sequence gaps and their recoveries, for
+	// connectors that check sequences.
+	Gaps  GapStats
+	Conns []wsclient.Health
+}
+
+// venueClock records when the Router last saw an update per venue.
+type venueClock struct {
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func (v *venueClock) touch(venue string, at time.Time) {
+	v.mu.Lock()
+	if v.last == nil {
+		v.last = make(map[string]time.Time)
+	}
+	v.last[venue] = at
+	v.mu.Unlock()
+}
+
+func (v *venueClock) get(venue string) time.Time {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.last[venue]
+}
+
+// Health reports every registered connector, sorted by venue.
+func (r *Router) Health() []ConnectorHealth {
+	out := make([]ConnectorHealth, 0, len(r.connectors))
+	for _, c := range r.connectors {
+		h := ConnectorHealth{Venue: c.Venue(), Status: "ok", LastUpdate: r.seen.get(c.Venue())}
+		if rep, ok := Unwrap(c).(HealthReporter); ok {
+			h.Conns = rep.Health()
+			down := len(h.Conns) > 0
+			for _, ch := range h.Conns {
+				h.Reconnects += ch.Reconnects
+				if ch.Connected {
+					down = false
+				}
+				if ch.Degraded() {
+					h.Status = "degraded"
+				}
+			}
+			if down {
+				h.Status = "down"
+			}
+		}
+		if rep, ok := Unwrap(c).(GapReporter); ok {
+			h.Gaps = rep.Gaps()
+		}
+		if h.LastUpdate.IsZero() && h.Status == "ok" {
+			h.Status = "degraded"
+		}
+		if r.status != nil {
+			if st, ok := r.status.Status(h.Venue); ok && st.Maintenance {
+				h.Maintenance = &st
+				h.Status = "maintenance"
+			}
+		}
+		out = append(out, h)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Venue < out[j].Venue })
+	return out
+}
+
+// FeedsReady fails while any feed is down or degraded (including one that
+// has not delivered data yet). Venues in announced maintenance don't count.
+func (r *Router) FeedsReady() error {
+	var bad []string
+	for _, h := range r.Health() {
+		if h.Status != "ok" && h.Status != "maintenance" {
+			bad = append(bad, h.Venue+" "+h.Status)
+		}
+	}
+	if len(bad) > 0 {
+		return fmt.Errorf("feeds not ready: %s", strings.Join(bad, ", "))
+	}
+	return nil
+}
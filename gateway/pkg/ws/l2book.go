@@ -0,0 +1,115 @@
+package ws
+
+import (
+	"github.com/helix-lab/helix/gateway/pkg/decimal"
+	"github.com/helix-lab/helix/gateway/pkg/l2book"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+	"github.com/helix-lab/helix/gateway/pkg/ws/bybitjson"
+)
+
+// l2Book keeps one symbol's price levels so live connectors can derive
+// top-of-book from incremental feeds. Levels are keyed by exact decimal
+// price, so a delete always finds the level it names.
+type l2Book struct {
+	symbol     string
+	bids, asks *l2book.Ladder
+	// lastU is the last update id applied; while resyncing after a gap,
+	// deltas are held in pending instead.
+	lastU     int64
+	tsMs      int64
+	resyncing bool
+	pending   []bookDelta
+	// bidBefore and askBefore are the best prices BBO events were last
+	// derived against; they survive snapshots and resyncs.
+	bidBefore, askBefore float64
+}
+
+func newL2Book(symbol string) *l2Book {
+	return &l2Book{symbol: symbol, bids: l2book.NewLadder(true), asks: l2book.NewLadder(false)}
+}
+
+// reset empties the book, keeping the ladders' storage for the snapshot.
+func (b *l2Book) reset() {
+	b.bids.Reset()
+	b.asks.Reset()
+}
+
+// apply merges [price, size] pairs; size 0 removes the level. Pairs that
+// are not decimals, or have a price at or below zero, are skipped. With
+// events set, the levels added, removed or resized are appended to it.
+func (b *l2Book) apply(levels []bybitjson.Level, bid bool, events *[]transport.BookEvent) {
+	side, name := b.asks, "ask"
+	if bid {
+		side, name = b.bids, "bid"
+	}
+	for _, lvl := range levels {
+		px, err := decimal.ParseBytes(lvl.Price)
+		if err != nil || px <= 0 {
+			continue
+		}
+		qty, err := decimal.ParseBytes(lvl.Size)
+		if err != nil {
+			continue
+		}
+		if events == nil {
+			side.Set(px, qty)
+			continue
+		}
+		prev := side.Get(px)
+		side.Set(px, qty)
+		qty = max(qty, 0)
+		var kind string
+		switch {
+		case prev == 0 && qty > 0:
+			kind = transport.LevelAdded
+		case prev > 0 && qty == 0:
+			kind = transport.LevelRemoved
+		case prev != qty:
+			kind = transport.LevelChanged
+		default:
+			continue
+		}
+		*events = append(*events, transport.BookEvent{Symbol: b.symbol, Kind: kind, Side: name,
+			Price: px.Float(), Size: qty.Float(), PrevSize: prev.Float()})
+	}
+}
+
+// bboEvents appends the moves of each side's best price from before to
+// the book's current top, for sides that had and still have a level.
+func (b *l2Book) bboEvents(events []transport.BookEvent, bidBefore, askBefore float64) []transport.BookEvent {
+	bid, bidSz, ask, askSz := b.top()
+	move := func(side string, before, after, size float64, improved bool) {
+		if before == 0 || after == 0 || before == after {
+			return
+		}
+		kind := transport.BBOWorsened
+		if improved {
+			kind = transport.BBOImproved
+		}
+		events = append(events, transport.BookEvent{Symbol: b.symbol, Kind: kind, Side: side,
+			Price: after, Size: size, PrevPrice: before})
+	}
+	move("bid", bidBefore, bid, bidSz, bid > bidBefore)
+	move("ask", askBefore, ask, askSz, ask < askBefore)
+	return events
+}
+
+// levels returns the best n levels of a side.
+func (b *l2Book) levels(bid bool, n int) []transport.BookLevel {
+	side := b.asks
+	if bid {
+		side = b.bids
+	}
+	top := side.Top(n)
+	out := make([]transport.BookLevel, len(top))
+	for i, l := range top {
+		out[i] = transport.BookLevel{Price: l.Price, Size: l.Size}
+	}
+	return out
+}
+
+func (b *l2Book) top() (bestBid, bidSz, bestAsk, askSz float64) {
+	bid, bsz, _ := b.bids.Best()
+	ask, asz, _ := b.asks.Best()
+	return bid.Float(), bsz.Float(), ask.Float(), asz.Float()
+}
@@ -0,0 +1,94 @@
+package ws
+
+import (
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/metrics"
+)
+
+// RegisterMetrics exposes backpressure, subscriber and feed-health state.
+// Registering a second Router replaces the first one's collectors.
+func (r *Router) RegisterMetrics(reg *metrics.Registry) {
+	reg.Collect("helix_router_backpressure_events_total", "Router backpressure interventions by kind.", "counter", func(emit metrics.Emit) {
+		st := r.Backpressure()
+		emit("", metrics.L("kind", "conflated"), float64(st.Conflated))
+		emit("", metrics.L("kind", "dropped"), float64(st.Dropped))
+		emit("", metrics.L("kind", "blocked"), float64(st.BlockedTimes))
+		emit("", metrics.L("kind", "dropped_events"), float64(st.DroppedEvents))
+	})
+	reg.Collect("helix_router_backlog", "Updates waiting in the Router backlog.", "gauge", func(emit metrics.Emit) {
+		st := r.Backpressure()
+		emit("", metrics.L("policy", st.Policy), float64(st.Backlog))
+	})
+	reg.Collect("helix_router_subscriber_delivered_total", "Depth updates delivered per subscriber.", "counter", func(emit metrics.Emit) {
+		for _, s := range r.Subscribers() {
+			emit("", metrics.L("subscriber", s.Name), float64(s.Delivered))
+		}
+	})
+	reg.Collect("helix_router_subscriber_dropped_total", "Depth updates dropped per lossy subscriber.", "counter", func(emit metrics.Emit) {
+		for _, s := range r.Subscribers() {
+			emit("", metrics.L("subscriber", s.Name), float64(s.Dropped))
+		}
+	})
+	reg.Collect("helix_router_subscriber_buffered", "Depth updates buffered per subscriber.", "gauge", func(emit metrics.Emit) {
+		for _, s := range r.Subscribers() {
+			emit("", metrics.L("subscriber", s.Name), float64(s.Buffered))
+		}
+	})
+	reg.Collect("helix_feed_healthy", "1 when the venue feed status is ok.", "gauge", func(emit metrics.Emit) {
+		for _, h := range r.Health() {
+			v := 0.0
+			if h.Status == "ok" {
+				v = 1
+			}
+			emit("", metrics.L("venue", h.Venue, "status", h.Status), v)
+		}
+	})
+	reg.Collect("helix_feed_last_update_age_seconds", "Seconds since the Router last saw an update from the venue.", "gauge", func(emit metrics.Emit) {
+		now := time.Now()
+		for _, h := range r.Health() {
+			if h.LastUpdate.IsZero() {
+				continue
+			}
+			emit("", metrics.L("venue", h.Venue), now.Sub(h.LastUpdate).Seconds())
+		}
+	})
+	reg.Collect("helix_feed_reconnects_total", "Websocket reconnects per venue.", "counter", func(emit metrics.Emit) {
+		for _, h := range r.Health() {
+			emit("", metrics.L("venue", h.Venue), float64(h.Reconnects))
+		}
+	})
+	reg.Collect("helix_feed_gaps_total", "Book sequence gaps per venue, by how they ended.", "counter", func(emit metrics.Emit) {
+		for _, h := range r.Health() {
+			if h.Gaps.Gaps == 0 {
+				continue
+			}
+			emit("", metrics.L("venue", h.Venue, "result", "recovered"), float64(h.Gaps.Recovered))
+			emit("", metrics.L("venue", h.Venue, "result", "failed"), float64(h.Gaps.Failed))
+			emit("", metrics.L("venue", h.Venue, "result", "unrecovered"), float64(h.Gaps.Gaps-h.Gaps.Recovered-h.Gaps.Failed))
+		}
+	})
+}
+
+// RegisterMetrics exposes the per-venue clock skew estimate and drift flag.
+func (m *ClockMonitor) RegisterMetrics(reg *metrics.Registry) {
+	reg.Collect("helix_clock_offset_seconds", "Venue clock minus local clock.", "gauge", func(emit metrics.Emit) {
+		for _, c := range m.Estimates() {
+			emit("", metrics.L("venue", c.Venue), c.Offset.Seconds())
+		}
+	})
+	reg.Collect("helix_clock_rtt_seconds", "Round trip of the sample the offset was taken from.", "gauge", func(emit metrics.Emit) {
+		for _, c := range m.Estimates() {
+			emit("", metrics.L("venue", c.Venue), c.RTT.Seconds())
+		}
+	})
+	reg.Collect("helix_clock_drift", "1 while the venue skew exceeds the configured bound.", "gauge", func(emit metrics.Emit) {
+		for _, c := range m.Estimates() {
+			v := 0.0
+			if c.Drift {
+				v = 1
+			}
+			emit("", metrics.L("venue", c.Venue), v)
+		}
+	})
+}
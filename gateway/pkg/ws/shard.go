@@ -0,0 +1,74 @@
+package ws
+
+import (
+	"fmt"
+
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// ShardOf maps symbol onto one of n shards (FNV-1a), the same way on every
+// run, so a symbol's updates always take the same lane.
+func ShardOf(symbol string, n int) int {
+	if n <= 1 {
+		return 0
+	}
+	h := uint32(2166136261)
+	for i := 0; i < len(symbol); i++ {
+		h ^= uint32(symbol[i])
+		h *= 16777619
+	}
+	return int(h % uint32(n))
+}
+
+// DepthFor is the channel for symbol's depth updates: its shard's lane when
+// the Router is sharded, otherwise Depth. Connectors carrying several
+// symbols send through it so a stalled lane only holds up its own symbols.
+func (f Feeds) DepthFor(symbol string) chan<- transport.DepthUpdate {
+	if len(f.lanes) == 0 {
+		return f.Depth
+	}
+	return f.lanes[ShardOf(symbol, len(f.lanes))]
+}
+
+// lane is one shard of the depth path: its own backlog under the Router's
+// policy and its own lossless consumer, so lanes never wait on each other.
+type lane struct {
+	intake  chan transport.DepthUpdate
+	fanIn   chan transport.DepthUpdate
+	pending *pending
+	out     *Subscription[transport.DepthUpdate]
+}
+
+func newLanes(cfg RouterConfig) []*lane {
+	n := max(cfg.Shards, 1)
+	lanes := make([]*lane, n)
+	for i := range lanes {
+		name := "primary"
+		if n > 1 {
+			name = fmt.Sprintf("shard%d", i)
+		}
+		lanes[i] = &lane{
+			intake:  make(chan transport.DepthUpdate),
+			fanIn:   make(chan transport.DepthUpdate),
+			pending: newPending(cfg.Policy, cfg.MaxBacklog),
+			out:     &Subscription[transport.DepthUpdate]{name: name, ch: make(chan transport.DepthUpdate, cfg.Buffer)},
+		}
+	}
+	return lanes
+}
+
+// dispatch hands updates sent to Feeds.Depth to their symbol's lane.
+func (r *Router) dispatch() {
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case u := <-r.intake:
+			select {
+			case r.lanes[ShardOf(u.Symbol, len(r.lanes))].intake <- u:
+			case <-r.ctx.Done():
+				return
+			}
+		}
+	}
+}
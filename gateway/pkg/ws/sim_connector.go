@@ -0,0 +1,234 @@
+package ws
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// SimConfig parameterises a synthetic venue. All randomness comes from Seed, so
+// the sequence of updates (not their wall-clock timing) is reproducible.
+type SimConfig struct {
+	Venue string
+	// Category stamps every book, e.g. linear; empty leaves it unset.
+	Category string
+	Symbols  []string
+	Seed     int64
+	Interval time.Duration
+
+	// Process is "gbm" (geometric Brownian motion) or "ou" (Ornstein-Uhlenbeck).
+	Process    string
+	StartPrice float64
+	// Drift and Vol are per second (GBM: relative, OU: absolute).
+	Drift float64
+	Vol   float64
+	// MeanReversion and LongRunMean only apply to OU; LongRunMean defaults to StartPrice.
+	MeanReversion float64
+	LongRunMean   float64
+
+	SpreadBps    float64
+	SpreadJitter float64
+	TickSize     float64
+	SizeMean     float64
+	SizeVol      float64
+
+	// BurstProb is the per-step chance of emitting BurstLen updates at once.
+	BurstProb float64
+	BurstLen  int
+	// OutageProb is the per-step chance of going silent for OutageSteps steps.
+	OutageProb  float64
+	OutageSteps int
+}
+
+func DefaultSimConfig(venue string) SimConfig {
+	return SimConfig{
+		Venue:      venue,
+		Symbols:    []string{"BTCUSDT"},
+		Seed:       1,
+		Interval:   200 * time.Millisecond,
+		Process:    "gbm",
+		StartPrice: 100,
+		Vol:        0.001,
+		SpreadBps:  4,
+		SizeMean:   10,
+		SizeVol:    0.2,
+		BurstLen:   5,
+	}
+}
+
+type simSymbol struct {
+	symbol string
+	mid    float64
+}
+
+// SimConnector is a Connector driven by a seeded stochastic model; it backs
+// demos and integration tests where no exchange is reachable.
+type SimConnector struct {
+	cfg SimConfig
+	rng *rand.Rand
+	// mu guards state against SetSymbols while Run steps the model.
+	mu      sync.Mutex
+	state   []simSymbol
+	outage  int
+	dtSecs  float64
+	outages int
+	bursts  int
+}
+
+func NewSimConnector(cfg SimConfig) *SimConnector {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 200 * time.Millisecond
+	}
+	if len(cfg.Symbols) == 0 {
+		cfg.Symbols = []string{"BTCUSDT"}
+	}
+	if cfg.LongRunMean == 0 {
+		cfg.LongRunMean = cfg.StartPrice
+	}
+	if cfg.BurstLen <= 0 {
+		cfg.BurstLen = 1
+	}
+	s := &SimConnector{
+		cfg:    cfg,
+		rng:    rand.New(rand.NewSource(cfg.Seed)),
+		dtSecs: cfg.Interval.Seconds(),
+	}
+	for _, sym := range cfg.Symbols {
+		s.state = append(s.state, simSymbol{symbol: sym, mid: cfg.StartPrice})
+	}
+	return s
+}
+
+func (s *SimConnector) Venue() string { return s.cfg.Venue }
+
+// Outages and Bursts count injected faults. They are not synchronised with
+// Run; read them when driving Step directly.
+func (s *SimConnector) Outages() int { return s.outages }
+func (s *SimConnector) Bursts() int  { return s.bursts }
+
+func (s *SimConnector) Run(ctx context.Context, out Feeds) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, u := range s.Step() {
+				u.ExchTsMs = now.UnixMilli()
+				u.RecvNs = time.Now().UnixNano()
+				select {
+				case out.DepthFor(u.Symbol) <- u:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
+
+// Step advances the model by one interval and returns the updates for it;
+// an outage step returns nothing.
+func (s *SimConnector) Step() []transport.DepthUpdate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.outage > 0 {
+		s.outage--
+		return nil
+	}
+	if s.cfg.OutageProb > 0 && s.rng.Float64() < s.cfg.OutageProb {
+		s.outage = s.cfg.OutageSteps
+		s.outages++
+		return nil
+	}
+	n := 1
+	if s.cfg.BurstProb > 0 && s.rng.Float64() < s.cfg.BurstProb {
+		n = s.cfg.BurstLen
+		s.bursts++
+	}
+	dt := s.dtSecs / float64(n)
+	out := make([]transport.DepthUpdate, 0, n*len(s.state))
+	for i := 0; i < n; i++ {
+		for j := range s.state {
+			st := &s.state[j]
+			st.mid = s.evolve(st.mid, dt)
+			out = append(out, s.quote(st))
+		}
+	}
+	return out
+}
+
+func (s *SimConnector) Subscribed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]string, 0, len(s.state))
+	for _, st := range s.state {
+		out = append(out, st.symbol)
+	}
+	return out
+}
+
+// SetSymbols keeps the price path of symbols that stay and starts new ones
+// at StartPrice.
+func (s *SimConnector) SetSymbols(symbols []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := make([]simSymbol, 0, len(symbols))
+	for _, sym := range symbols {
+		st := simSymbol{symbol: sym, mid: s.cfg.StartPrice}
+		for _, old := range s.state {
+			if old.symbol == sym {
+				st = old
+			}
+		}
+		next = append(next, st)
+	}
+	s.state = next
+}
+
+func (s *SimConnector) evolve(mid, dt float64) float64 {
+	z := s.rng.NormFloat64()
+	switch strings.ToLower(s.cfg.Process) {
+	case "ou":
+		next := mid + s.cfg.MeanReversion*(s.cfg.LongRunMean-mid)*dt + s.cfg.Vol*math.Sqrt(dt)*z
+		if next <= 0 {
+			return mid
+		}
+		return next
+	default:
+		v := s.cfg.Vol
+		return mid * math.Exp((s.cfg.Drift-0.5*v*v)*dt+v*math.Sqrt(dt)*z)
+	}
+}
+
+func (s *SimConnector) quote(st *simSymbol) transport.DepthUpdate {
+	spreadBps := s.cfg.SpreadBps * (1 + s.cfg.SpreadJitter*math.Abs(s.rng.NormFloat64()))
+	half := st.mid * spreadBps / 1e4 / 2
+	bid, ask := st.mid-half, st.mid+half
+	if tick := s.cfg.TickSize; tick > 0 {
+		bid = math.Floor(bid/tick) * tick
+		ask = math.Ceil(ask/tick) * tick
+		if ask <= bid {
+			ask = bid + tick
+		}
+	}
+	return transport.DepthUpdate{
+		Venue:    s.cfg.Venue,
+		Symbol:   st.symbol,
+		Category: s.cfg.Category,
+		BestBid:  bid,
+		BestAsk:  ask,
+		BidSize:  s.size(),
+		AskSize:  s.size(),
+	}
+}
+
+func (s *SimConnector) size() float64 {
+	v := s.cfg.SizeVol
+	return s.cfg.SizeMean * math.Exp(v*s.rng.NormFloat64()-0.5*v*v)
+}
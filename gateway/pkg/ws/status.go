@@ -0,0 +1,197 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// VenueStatus is the latest system-status verdict for one venue.
+type VenueStatus struct {
+	Venue       string
+	Maintenance bool
+	Reason      string
+	// Until is the announced end of the maintenance window, if known.
+	Until     time.Time
+	CheckedAt time.Time
+	Err       string
+}
+
+// StatusSource polls one venue's system-status endpoint. lead widens the
+// window so scheduled maintenance starting within it already counts.
+type StatusSource interface {
+	Venue() string
+	Check(ctx context.Context, lead time.Duration) (VenueStatus, error)
+}
+
+// BybitStatus reads GET /v5/system/status.
+type BybitStatus struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewBybitStatus() *BybitStatus {
+	return &BybitStatus{URL: "https://api.bybit.com/v5/system/status", Client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (b *BybitStatus) Venue() string { return "BYBIT" }
+
+func (b *BybitStatus) Check(ctx context.Context, lead time.Duration) (VenueStatus, error) {
+	var body struct {
+		RetCode int    `json:"retCode"`
+		RetMsg  string `json:"retMsg"`
+		Result  struct {
+			List []struct {
+				Title string `json:"title"`
+				State string `json:"state"`
+				Begin string `json:"begin"`
+				End   string `json:"end"`
+			} `json:"list"`
+		} `json:"result"`
+	}
+	st := VenueStatus{Venue: b.Venue(), CheckedAt: time.Now()}
+	if err := getJSON(ctx, b.Client, b.URL, &body); err != nil {
+		return st, err
+	}
+	if body.RetCode != 0 {
+		return st, fmt.Errorf("retCode %d retMsg %s", body.RetCode, body.RetMsg)
+	}
+	now := st.CheckedAt
+	for _, ev := range body.Result.List {
+		begin := msTime(ev.Begin)
+		end := msTime(ev.End)
+		active := ev.State == "ongoing"
+		if ev.State == "scheduled" && !begin.IsZero() && now.Add(lead).After(begin) && (end.IsZero() || now.Before(end)) {
+			active = true
+		}
+		if active {
+			st.Maintenance = true
+			st.Reason = ev.Title
+			if end.After(st.Until) {
+				st.Until = end
+			}
+		}
+	}
+	return st, nil
+}
+
+// BinanceStatus reads GET /sapi/v1/system/status ({"status":0|1,"msg":...}).
+type BinanceStatus struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewBinanceStatus() *BinanceStatus {
+	return &BinanceStatus{URL: "https://api.binance.com/sapi/v1/system/status", Client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (b *BinanceStatus) Venue() string { return "BINANCE" }
+
+func (b *BinanceStatus) Check(ctx context.Context, _ time.Duration) (VenueStatus, error) {
+	var body struct {
+		Status int    `json:"status"`
+		Msg    string `json:"msg"`
+	}
+	st := VenueStatus{Venue: b.Venue(), CheckedAt: time.Now()}
+	if err := getJSON(ctx, b.Client, b.URL, &body); err != nil {
+		return st, err
+	}
+	if body.Status != 0 {
+		st.Maintenance = true
+		st.Reason = body.Msg
+	}
+	return st, nil
+}
+
+// StatusMonitor polls every source and keeps the latest verdict per venue.
+// A failed poll keeps the previous maintenance flag and records the error.
+type StatusMonitor struct {
+	Interval time.Duration
+	Lead     time.Duration
+
+	sources []StatusSource
+	mu      sync.RWMutex
+	status  map[string]VenueStatus
+}
+
+func NewStatusMonitor(interval time.Duration, sources ...StatusSource) *StatusMonitor {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &StatusMonitor{Interval: interval, sources: sources, status: make(map[string]VenueStatus)}
+}
+
+func (m *StatusMonitor) Run(ctx context.Context) {
+	m.Poll(ctx)
+	t := time.NewTicker(m.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			m.Poll(ctx)
+		}
+	}
+}
+
+// Poll checks every source once.
+func (m *StatusMonitor) Poll(ctx context.Context) {
+	for _, src := range m.sources {
+		st, err := src.Check(ctx, m.Lead)
+		m.mu.Lock()
+		if err != nil {
+			prev := m.status[src.Venue()]
+			prev.Venue = src.Venue()
+			prev.CheckedAt = st.CheckedAt
+			prev.Err = err.Error()
+			st = prev
+		}
+		m.status[src.Venue()] = st
+		m.mu.Unlock()
+	}
+}
+
+func (m *StatusMonitor) Status(venue string) (VenueStatus, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	st, ok := m.status[venue]
+	return st, ok
+}
+
+// InMaintenance reports whether venue has announced (or is in) downtime.
+func (m *StatusMonitor) InMaintenance(venue string) bool {
+	st, _ := m.Status(venue)
+	return st.Maintenance
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func msTime(s string) time.Time {
+	ms, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || ms <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
@@ -2,34 +2,353 @@ package ws
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
 
 	"github.com/helix-lab/helix/gateway/pkg/transport"
 )
 
+// RouterConfig controls buffering between connectors and the consumer.
+type RouterConfig struct {
+	// Buffer is the capacity of the channel returned by Updates().
+	Buffer int
+	// Policy applies once a lossless subscriber (Updates() included) is full.
+	Policy Policy
+	// MaxBacklog bounds the router-side backlog for drop_oldest and grow_bounded.
+	MaxBacklog int
+	// SimSeed seeds the synthetic connectors used when none are added.
+	SimSeed int64
+	// Shards splits depth into this many per-symbol lanes, each with its
+	// own backlog and consumer (Shards()); 0 or 1 keeps a single lane.
+	Shards int
+}
+
+// eventBuffer sizes the trade, liquidation, funding and book event
+// subscriber channels.
+const eventBuffer = 256
+
+func DefaultRouterConfig() RouterConfig {
+	return RouterConfig{Buffer: 32, Policy: PolicyBlock, MaxBacklog: 1024}
+}
+
 type Router struct {
-	updates chan transport.DepthUpdate
-	quit    context.CancelFunc
-	ctx     context.Context
+	connectors []Connector
+	intake     chan transport.DepthUpdate
+	lanes      []*lane
+	depth      broker[transport.DepthUpdate]
+	seen       venueClock
+	simSeed    int64
+	status     *StatusMonitor
+
+	tradeIntake   chan transport.Trade
+	trades        chan transport.Trade
+	liqIntake     chan transport.Liquidation
+	liquidations  chan transport.Liquidation
+	fundingIntake chan transport.FundingRate
+	funding       chan transport.FundingRate
+	bookIntake    chan transport.BookEvent
+	bookEvents    chan transport.BookEvent
+	droppedEvents atomic.Uint64
+	quit          context.CancelFunc
+	ctx           context.Context
 }
 
 func NewRouter() *Router {
+	return NewRouterWithConfig(DefaultRouterConfig())
+}
+
+func NewRouterWithConfig(cfg RouterConfig) *Router {
+	if cfg.Buffer <= 0 {
+		cfg.Buffer = DefaultRouterConfig().Buffer
+	}
 	ctx, cancel := context.WithCancel(context.Background())
-	return &Router{
-		updates: make(chan transport.DepthUpdate, 32),
-		quit:    cancel,
-		ctx:     ctx,
+	r := &Router{
+		lanes:         newLanes(cfg),
+		simSeed:       cfg.SimSeed,
+		tradeIntake:   make(chan transport.Trade),
+		trades:        make(chan transport.Trade, eventBuffer),
+		liqIntake:     make(chan transport.Liquidation),
+		liquidations:  make(chan transport.Liquidation, eventBuffer),
+		fundingIntake: make(chan transport.FundingRate),
+		funding:       make(chan transport.FundingRate, eventBuffer),
+		bookIntake:    make(chan transport.BookEvent),
+		bookEvents:    make(chan transport.BookEvent, eventBuffer),
+		quit:          cancel,
+		ctx:           ctx,
+	}
+	r.intake = r.lanes[0].intake
+	if len(r.lanes) > 1 {
+		r.intake = make(chan transport.DepthUpdate)
 	}
+	return r
+}
+
+// SetStatusMonitor lets Health() report venues in announced maintenance.
+// The monitor's Run loop is owned by the caller.
+func (r *Router) SetStatusMonitor(m *StatusMonitor) {
+	r.status = m
+}
+
+// Add registers a connector; call before Start. Without any, Start runs the
+// synthetic connectors.
+func (r *Router) Add(c Connector) {
+	r.connectors = append(r.connectors, c)
 }
 
 func (r *Router) Start() {
-	go StartBybitPublic(r.updates, r.ctx.Done())
-	go StartBinancePublic(r.updates, r.ctx.Done())
+	if len(r.connectors) == 0 {
+		r.connectors = SyntheticConnectors(r.simSeed)
+	}
+	lanes := make([]chan<- transport.DepthUpdate, 0, len(r.lanes))
+	for _, l := range r.lanes {
+		go r.pump(l)
+		go r.fanOut(l)
+		lanes = append(lanes, l.intake)
+	}
+	if len(r.lanes) > 1 {
+		go r.dispatch()
+	} else {
+		lanes = nil
+	}
+	go forward(r.ctx, r.tradeIntake, r.trades, &r.droppedEvents)
+	go forward(r.ctx, r.liqIntake, r.liquidations, &r.droppedEvents)
+	go forward(r.ctx, r.fundingIntake, r.funding, &r.droppedEvents)
+	go forward(r.ctx, r.bookIntake, r.bookEvents, &r.droppedEvents)
+	feeds := Feeds{Depth: r.intake, Trades: r.tradeIntake, Liquidations: r.liqIntake, Funding: r.fundingIntake,
+		BookEvents: r.bookIntake, lanes: lanes}
+	for _, c := range r.connectors {
+		go c.Run(r.ctx, feeds)
+	}
 }
 
+// Updates is the primary lossless subscription; its consumer must keep
+// draining it or the backpressure policy engages. A sharded Router's
+// Updates is its first shard only.
 func (r *Router) Updates() <-chan transport.DepthUpdate {
-	return r.updates
+	return r.lanes[0].out.C()
+}
+
+// Shards returns one lossless channel per shard; symbol s arrives only on
+// Shards()[ShardOf(s, n)]. Each must be drained, by its own goroutine so a
+// slow symbol holds up only its shard.
+func (r *Router) Shards() []<-chan transport.DepthUpdate {
+	out := make([]<-chan transport.DepthUpdate, len(r.lanes))
+	for i, l := range r.lanes {
+		out[i] = l.out.C()
+	}
+	return out
+}
+
+// Subscribe adds another depth consumer with its own buffer. Lossy
+// subscribers drop on overflow instead of slowing everyone else down.
+func (r *Router) Subscribe(name string, buffer int, lossy bool) *Subscription[transport.DepthUpdate] {
+	return r.depth.subscribe(name, buffer, lossy)
+}
+
+// Unsubscribe stops delivery to s. Its channel is left open and simply
+// stops receiving.
+func (r *Router) Unsubscribe(s *Subscription[transport.DepthUpdate]) {
+	r.depth.unsubscribe(s)
+}
+
+// Subscribers reports per-subscriber buffering and drops, shards first.
+func (r *Router) Subscribers() []SubscriberStats {
+	out := make([]SubscriberStats, 0, len(r.lanes))
+	for _, l := range r.lanes {
+		out = append(out, l.out.stats())
+	}
+	return append(out, r.depth.stats()...)
+}
+
+// Trades delivers public trades from every connector.
+func (r *Router) Trades() <-chan transport.Trade {
+	return r.trades
+}
+
+// Liquidations delivers liquidation prints from every connector.
+func (r *Router) Liquidations() <-chan transport.Liquidation {
+	return r.liquidations
+}
+
+// Funding delivers funding-rate updates from every connector.
+func (r *Router) Funding() <-chan transport.FundingRate {
+	return r.funding
+}
+
+// BookEvents delivers the level and BBO events of connectors that derive
+// them.
+func (r *Router) BookEvents() <-chan transport.BookEvent {
+	return r.bookEvents
+}
+
+// Backpressure returns counters describing how often the policy engaged,
+// summed over shards; HighWater is the deepest shard's.
+func (r *Router) Backpressure() BackpressureStats {
+	var st BackpressureStats
+	for _, l := range r.lanes {
+		ls := l.pending.stats()
+		st.Policy = ls.Policy
+		st.Backlog += ls.Backlog
+		st.HighWater = max(st.HighWater, ls.HighWater)
+		st.Conflated += ls.Conflated
+		st.Dropped += ls.Dropped
+		st.BlockedTimes += ls.BlockedTimes
+	}
+	st.DroppedEvents = r.droppedEvents.Load()
+	return st
 }
 
 func (r *Router) Stop() {
 	r.quit()
 }
+
+// forward hands trade/liquidation/funding/book events to subscribers without ever
+// stalling a connector; events are dropped (and counted) when nobody reads.
+func forward[T any](ctx context.Context, in <-chan T, out chan<- T, dropped *atomic.Uint64) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-in:
+			select {
+			case out <- ev:
+			default:
+				dropped.Add(1)
+			}
+		}
+	}
+}
+
+// fanOut delivers l's updates to its consumer, then to the subscribers.
+func (r *Router) fanOut(l *lane) {
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case u := <-l.fanIn:
+			select {
+			case l.out.ch <- u:
+				l.out.delivered.Add(1)
+			case <-r.ctx.Done():
+				return
+			}
+			if !r.depth.publish(u, r.ctx.Done()) {
+				return
+			}
+		}
+	}
+}
+
+// pump moves connector updates towards l's consumer, applying the
+// configured policy whenever a lossless consumer falls behind.
+func (r *Router) pump(l *lane) {
+	q := l.pending
+	wasFull := false
+	for {
+		var out chan<- transport.DepthUpdate
+		var head transport.DepthUpdate
+		if q.len() > 0 {
+			out = l.fanIn
+			head = q.peek()
+		}
+		in := l.intake
+		if q.full() {
+			// only count a stall when the consumer really is not ready
+			select {
+			case out <- head:
+				q.pop()
+				continue
+			default:
+			}
+			in = nil
+			if !wasFull {
+				q.blockedTimes.Add(1)
+			}
+			wasFull = true
+		} else {
+			wasFull = false
+		}
+
+		select {
+		case <-r.ctx.Done():
+			return
+		case u := <-in:
+			r.seen.touch(u.Venue, time.Now())
+			q.push(u)
+		case out <- head:
+			q.pop()
+		}
+	}
+}
+
+// Subscriptions returns the symbols of every connector that supports
+// changing them, keyed by venue.
+func (r *Router) Subscriptions() map[string][]string {
+	out := make(map[string][]string)
+	for _, c := range r.connectors {
+		if sub, ok := Unwrap(c).(SymbolSubscriber); ok {
+			out[c.Venue()] = sub.Subscribed()
+		}
+	}
+	return out
+}
+
+// BookSnapshots returns the top depth levels of every book the connectors
+// keep in full, sorted by symbol then venue.
+func (r *Router) BookSnapshots(depth int) []transport.BookSnapshot {
+	var out []transport.BookSnapshot
+	for _, c := range r.connectors {
+		if bs, ok := Unwrap(c).(BookSnapshotter); ok {
+			out = append(out, bs.BookSnapshots(depth)...)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Symbol != out[j].Symbol {
+			return out[i].Symbol < out[j].Symbol
+		}
+		return out[i].Venue < out[j].Venue
+	})
+	return out
+}
+
+// SubscribeSymbols adds symbols to venue's connector.
+func (r *Router) SubscribeSymbols(venue string, symbols ...string) error {
+	return r.updateSymbols(venue, func(cur []string) []string {
+		for _, sym := range symbols {
+			if !containsSymbol(cur, sym) {
+				cur = append(cur, sym)
+			}
+		}
+		return cur
+	})
+}
+
+// UnsubscribeSymbols removes symbols from venue's connector.
+func (r *Router) UnsubscribeSymbols(venue string, symbols ...string) error {
+	return r.updateSymbols(venue, func(cur []string) []string {
+		out := cur[:0]
+		for _, sym := range cur {
+			if !containsSymbol(symbols, sym) {
+				out = append(out, sym)
+			}
+		}
+		return out
+	})
+}
+
+func (r *Router) updateSymbols(venue string, fn func([]string) []string) error {
+	for _, c := range r.connectors {
+		if c.Venue() != venue {
+			continue
+		}
+		sub, ok := Unwrap(c).(SymbolSubscriber)
+		if !ok {
+			return fmt.Errorf("%s connector does not support changing symbols", venue)
+		}
+		sub.SetSymbols(fn(sub.Subscribed()))
+		return nil
+	}
+	return fmt.Errorf("unknown venue %q", venue)
+}
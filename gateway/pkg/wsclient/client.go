@@ -0,0 +1,427 @@
+// Package wsclient is the websocket client shared by venue connectors and
+// recorders: dial with backoff, subscribe, heartbeat pings, stale-stream
+// detection and resubscribe-on-reconnect. Callers supply frames handling
+// through OnMessage and hear about reconnects through OnReconnect.
+package wsclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"nhooyr.io/websocket"
+
+	"github.com/helix-lab/helix/gateway/pkg/clock"
+	"github.com/helix-lab/helix/gateway/pkg/metrics"
+)
+
+const (
+	closeReasonDone  = "done"
+	closeReasonRetry = "reconnect"
+)
+
+// ErrStale is returned from a session when no data frame arrived within StaleAfter.
+var ErrStale = errors.New("stale stream")
+
+// ErrResubscribe ends a session Resubscribe asked to start over when there
+// is no UnsubscribeRequest to do it on the live connection.
+var ErrResubscribe = errors.New("resubscribe requested")
+
+var staleReconnects = metrics.Default.CounterVec("helix_wsclient_stale_reconnects_total", "Reconnects forced by stale-stream detection, by endpoint.", "endpoint")
+
+// Handler processes one text/binary frame. It returns true when the frame
+// carried market data; only those frames reset stale detection, so subscribe
+// acks and pongs do not keep a dead stream alive.
+type Handler func(frame []byte) bool
+
+type Config struct {
+	Endpoint string
+	// Header is sent with the handshake, e.g. to authorize it.
+	Header http.Header
+	Topics []string
+	// OnMessage handles every frame that is not a subscribe ack; it is
+	// required.
+	OnMessage Handler
+	// SubscribeRequest builds the payload for a batch of topics, tagged with
+	// reqID so the ack can be matched. Defaults to the Bybit v5 shape
+	// {"op":"subscribe","req_id":...,"args":[...]}.
+	SubscribeRequest func(reqID string, topics []string) any
+	// UnsubscribeRequest builds the payload that drops a batch of topics,
+	// letting Resubscribe start them over without reconnecting.
+	UnsubscribeRequest func(reqID string, topics []string) any
+	// ParseAck matches subscribe acks to requests for health reporting.
+	// Defaults to BybitAck.
+	ParseAck AckParser
+	// MaxArgsPerRequest splits Topics over several subscribe frames
+	// (Bybit allows 10 args per request). Zero sends them all at once.
+	MaxArgsPerRequest int
+
+	DialTimeout  time.Duration
+	WriteTimeout time.Duration
+	ReadTimeout  time.Duration
+	PingInterval time.Duration
+	PingTimeout  time.Duration
+	// StaleAfter forces a reconnect when no data frame arrived for this long.
+	// Zero disables the check (ReadTimeout still applies).
+	StaleAfter time.Duration
+
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+
+	// Clock times backoff, pings and stale detection; nil is the wall
+	// clock.
+	Clock clock.Clock
+
+	// Tap, if set, sees every raw frame with its local receive time (unix ns)
+	// before the handler does. Frames are read into one buffer per
+	// connection: neither Tap nor the handler may keep the slice.
+	Tap func(recvNs int64, frame []byte)
+
+	// OnConnect runs after every successful dial+subscribe, before reading.
+	OnConnect func(attempt int)
+	// OnReconnect runs when a session ends with err and the client is about
+	// to redial; Reconnects already counts it.
+	OnReconnect func(err error)
+	// Logf receives reconnect diagnostics; nil keeps the client silent.
+	Logf func(format string, args ...any)
+}
+
+func (c *Config) defaults() {
+	c.Clock = clock.Or(c.Clock)
+	if c.SubscribeRequest == nil {
+		c.SubscribeRequest = BybitSubscribe
+	}
+	if c.ParseAck == nil {
+		c.ParseAck = BybitAck
+	}
+	if c.DialTimeout <= 0 {
+		c.DialTimeout = 10 * time.Second
+	}
+	if c.WriteTimeout <= 0 {
+		c.WriteTimeout = 5 * time.Second
+	}
+	if c.ReadTimeout <= 0 {
+		c.ReadTimeout = 30 * time.Second
+	}
+	if c.PingInterval <= 0 {
+		c.PingInterval = 15 * time.Second
+	}
+	if c.PingTimeout <= 0 {
+		c.PingTimeout = 5 * time.Second
+	}
+	if c.BackoffBase <= 0 {
+		c.BackoffBase = 250 * time.Millisecond
+	}
+	if c.BackoffMax <= 0 {
+		c.BackoffMax = 8 * time.Second
+	}
+}
+
+// BybitSubscribe is the default subscribe payload.
+func BybitSubscribe(reqID string, topics []string) any {
+	return map[string]any{"op": "subscribe", "req_id": reqID, "args": topics}
+}
+
+// BinanceSubscribe is the Binance subscribe payload, with reqID as its
+// numeric id.
+func BinanceSubscribe(reqID string, topics []string) any {
+	id, _ := strconv.Atoi(reqID)
+	return map[string]any{"method": "SUBSCRIBE", "params": topics, "id": id}
+}
+
+// OKXSubscribe is the OKX v5 subscribe payload; topics are
+// "channel:instId", e.g. "books-l2-tbt:BTC-USDT-SWAP".
+func OKXSubscribe(reqID string, topics []string) any {
+	return okxRequest("subscribe", reqID, topics)
+}
+
+// OKXUnsubscribe drops topics given as for OKXSubscribe.
+func OKXUnsubscribe(reqID string, topics []string) any {
+	return okxRequest("unsubscribe", reqID, topics)
+}
+
+func okxRequest(op, reqID string, topics []string) any {
+	args := make([]map[string]string, 0, len(topics))
+	for _, t := range topics {
+		channel, inst, _ := strings.Cut(t, ":")
+		args = append(args, map[string]string{"channel": channel, "instId": inst})
+	}
+	return map[string]any{"op": op, "id": reqID, "args": args}
+}
+
+// KrakenSubscribe is the Kraken Futures subscribe payload; topics are
+// "feed:product_id", e.g. "book:PF_XBTUSD", all of one feed. Kraken has
+// no request IDs, so reqID goes unsent.
+func KrakenSubscribe(reqID string, topics []string) any {
+	return krakenRequest("subscribe", topics)
+}
+
+// KrakenUnsubscribe drops topics given as for KrakenSubscribe.
+func KrakenUnsubscribe(reqID string, topics []string) any {
+	return krakenRequest("unsubscribe", topics)
+}
+
+func krakenRequest(event string, topics []string) any {
+	var feed string
+	products := make([]string, 0, len(topics))
+	for _, t := range topics {
+		f, product, _ := strings.Cut(t, ":")
+		feed = f
+		products = append(products, product)
+	}
+	return map[string]any{"event": event, "feed": feed, "product_ids": products}
+}
+
+type Client struct {
+	cfg    Config
+	rng    *rand.Rand
+	health *healthState
+	resub  atomic.Bool
+}
+
+func New(cfg Config) *Client {
+	cfg.defaults()
+	return &Client{
+		cfg:    cfg,
+		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		health: newHealthState(cfg.Endpoint, cfg.Topics, cfg.Clock),
+	}
+}
+
+// Reconnects counts sessions that ended with an error and were redialled.
+func (c *Client) Reconnects() uint64 {
+	return c.health.snapshot().Reconnects
+}
+
+// Stale counts the reconnects forced by StaleAfter.
+func (c *Client) Stale() uint64 {
+	return c.health.snapshot().Stale
+}
+
+// Health returns the current connection, backoff and subscription state.
+func (c *Client) Health() Health {
+	return c.health.snapshot()
+}
+
+// Resubscribe subscribes every topic afresh once the frame being handled
+// is done, so the venue sends new snapshots; callers use it when they find
+// the stream corrupt. Without an UnsubscribeRequest it reconnects.
+func (c *Client) Resubscribe() {
+	c.resub.Store(true)
+}
+
+// Run keeps a session alive until ctx is done, redialling with backoff and
+// resubscribing every configured topic after each reconnect.
+func (c *Client) Run(ctx context.Context) error {
+	attempt := 0
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if attempt > 0 {
+			delay := Backoff(attempt, c.cfg.BackoffBase, c.cfg.BackoffMax, c.rng)
+			c.health.backingOff(attempt, delay)
+			if !clock.SleepCtx(ctx, c.cfg.Clock, delay) {
+				return nil
+			}
+		}
+
+		conn, err := c.dialAndSubscribe(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			c.logf("dial %s: %v", c.cfg.Endpoint, err)
+			c.health.disconnected(err, false)
+			attempt++
+			continue
+		}
+		if c.cfg.OnConnect != nil {
+			c.cfg.OnConnect(attempt)
+		}
+		attempt = 0
+
+		err = c.session(ctx, conn)
+		if ctx.Err() != nil {
+			_ = conn.Close(websocket.StatusNormalClosure, closeReasonDone)
+			c.health.disconnected(nil, false)
+			return nil
+		}
+		_ = conn.Close(websocket.StatusNormalClosure, closeReasonRetry)
+		c.logf("session %s ended, reconnecting: %v", c.cfg.Endpoint, err)
+		c.health.disconnected(err, true)
+		if errors.Is(err, ErrStale) {
+			staleReconnects.With(c.cfg.Endpoint).Inc()
+		}
+		if c.cfg.OnReconnect != nil {
+			c.cfg.OnReconnect(err)
+		}
+		attempt++
+	}
+}
+
+func (c *Client) dialAndSubscribe(ctx context.Context) (*websocket.Conn, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, c.cfg.DialTimeout)
+	defer cancel()
+
+	conn, _, err := websocket.Dial(dialCtx, c.cfg.Endpoint, &websocket.DialOptions{HTTPHeader: c.cfg.Header})
+	if err != nil {
+		return nil, fmt.Errorf("dial: %w", err)
+	}
+	conn.SetReadLimit(1 << 24)
+	c.health.connected()
+	c.resub.Store(false)
+
+	if err := c.subscribe(ctx, conn); err != nil {
+		_ = conn.Close(websocket.StatusNormalClosure, "subscribe failed")
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (c *Client) subscribe(ctx context.Context, conn *websocket.Conn) error {
+	for i, batch := range Chunk(c.cfg.Topics, c.cfg.MaxArgsPerRequest) {
+		reqID := strconv.Itoa(i + 1)
+		c.health.subscribing(reqID, batch)
+		if err := WriteJSON(ctx, conn, c.cfg.SubscribeRequest(reqID, batch), c.cfg.WriteTimeout); err != nil {
+			return fmt.Errorf("subscribe write: %w", err)
+		}
+	}
+	return nil
+}
+
+// resubscribe drops and subscribes every topic on conn.
+func (c *Client) resubscribe(ctx context.Context, conn *websocket.Conn) error {
+	if c.cfg.UnsubscribeRequest == nil {
+		return ErrResubscribe
+	}
+	for i, batch := range Chunk(c.cfg.Topics, c.cfg.MaxArgsPerRequest) {
+		if err := WriteJSON(ctx, conn, c.cfg.UnsubscribeRequest("u"+strconv.Itoa(i+1), batch), c.cfg.WriteTimeout); err != nil {
+			return fmt.Errorf("unsubscribe write: %w", err)
+		}
+	}
+	return c.subscribe(ctx, conn)
+}
+
+func (c *Client) session(ctx context.Context, conn *websocket.Conn) error {
+	pingCtx, pingCancel := context.WithCancel(ctx)
+	defer pingCancel()
+	go pingLoop(pingCtx, conn, c.cfg.Clock, c.cfg.PingInterval, c.cfg.PingTimeout)
+
+	clk := c.cfg.Clock
+	lastData := clk.Now()
+	var buf bytes.Buffer
+	for {
+		timeout := c.cfg.ReadTimeout
+		if c.cfg.StaleAfter > 0 {
+			left := c.cfg.StaleAfter - clk.Since(lastData)
+			if left <= 0 {
+				return fmt.Errorf("%w: no data for %v", ErrStale, clk.Since(lastData).Truncate(time.Millisecond))
+			}
+			if left < timeout {
+				timeout = left
+			}
+		}
+
+		readCtx, cancel := context.WithTimeout(ctx, timeout)
+		data, err := readFrame(readCtx, conn, &buf)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil && c.cfg.StaleAfter > 0 && clk.Since(lastData) >= c.cfg.StaleAfter {
+				return fmt.Errorf("%w: no data for %v", ErrStale, clk.Since(lastData).Truncate(time.Millisecond))
+			}
+			return err
+		}
+		if c.cfg.Tap != nil {
+			c.cfg.Tap(clk.Now().UnixNano(), data)
+		}
+		c.health.message()
+		if reqID, ok, isAck := c.cfg.ParseAck(data); isAck {
+			c.health.ack(reqID, ok)
+			continue
+		}
+		if c.cfg.OnMessage(data) {
+			lastData = clk.Now()
+		}
+		if c.resub.Swap(false) {
+			c.logf("resubscribing %s", c.cfg.Endpoint)
+			if err := c.resubscribe(ctx, conn); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readFrame reads the next message into buf, replacing its contents.
+func readFrame(ctx context.Context, conn *websocket.Conn, buf *bytes.Buffer) ([]byte, error) {
+	_, r, err := conn.Reader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	buf.Reset()
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *Client) logf(format string, args ...any) {
+	if c.cfg.Logf != nil {
+		c.cfg.Logf(format, args...)
+	}
+}
+
+// WriteJSON marshals v and writes it as a single text frame.
+func WriteJSON(ctx context.Context, conn *websocket.Conn, v any, timeout time.Duration) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	wctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return conn.Write(wctx, websocket.MessageText, payload)
+}
+
+func pingLoop(ctx context.Context, conn *websocket.Conn, clk clock.Clock, interval, timeout time.Duration) {
+	t := clk.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C():
+			pctx, cancel := context.WithTimeout(ctx, timeout)
+			err := conn.Ping(pctx)
+			cancel()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Backoff returns the capped exponential delay before redial attempt n (n>=1),
+// plus up to 150ms of jitter.
+func Backoff(attempt int, base, max time.Duration, rng *rand.Rand) time.Duration {
+	exp := attempt - 1
+	if exp < 0 {
+		exp = 0
+	}
+	if exp > 10 {
+		exp = 10
+	}
+	delay := base * time.Duration(1<<exp)
+	if delay > max {
+		delay = max
+	}
+	jitter := time.Duration(rng.Intn(150)) * time.Millisecond
+	return delay + jitter
+}
@@ -0,0 +1,246 @@
+package wsclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/clock"
+)
+
+// TopicState is the subscription status of one topic on one connection.
+type TopicState string
+
+const (
+	TopicIdle       TopicState = "idle"
+	TopicPending    TopicState = "pending"
+	TopicSubscribed TopicState = "subscribed"
+	TopicFailed     TopicState = "failed"
+)
+
+// Health is a point-in-time view of one connection.
+type Health struct {
+	Endpoint    string
+	Connected   bool
+	ConnectedAt time.Time
+	LastMessage time.Time
+	Reconnects  uint64
+	// Stale counts the reconnects StaleAfter forced.
+	Stale uint64
+	// Attempt is the number of consecutive failed dials/sessions; non-zero
+	// means the client is currently backing off.
+	Attempt   int
+	Backoff   time.Duration
+	LastError string
+	Topics    map[string]TopicState
+}
+
+// Degraded reports whether the connection is down, retrying, or missing a topic.
+func (h Health) Degraded() bool {
+	if !h.Connected || h.Attempt > 0 {
+		return true
+	}
+	for _, st := range h.Topics {
+		if st != TopicSubscribed && st != TopicPending {
+			return true
+		}
+	}
+	return false
+}
+
+// AckParser recognises a subscribe ack. ok is false for any other frame.
+type AckParser func(frame []byte) (reqID string, success bool, ok bool)
+
+// BybitAck parses {"op":"subscribe","success":true,"req_id":"..."}.
+func BybitAck(frame []byte) (string, bool, bool) {
+	if !bytes.Contains(frame, []byte(`"op"`)) {
+		return "", false, false
+	}
+	var ack struct {
+		Op      string `json:"op"`
+		Success *bool  `json:"success"`
+		ReqID   string `json:"req_id"`
+	}
+	if err := json.Unmarshal(frame, &ack); err != nil || ack.Op != "subscribe" || ack.Success == nil {
+		return "", false, false
+	}
+	return ack.ReqID, *ack.Success, true
+}
+
+// BinanceAck parses {"result":null,"id":1}, or {"error":{...},"id":1} for
+// a refused subscribe.
+func BinanceAck(frame []byte) (string, bool, bool) {
+	if !bytes.Contains(frame, []byte(`"id"`)) || bytes.Contains(frame, []byte(`"e"`)) {
+		return "", false, false
+	}
+	var ack struct {
+		Result json.RawMessage `json:"result"`
+		Error  json.RawMessage `json:"error"`
+		ID     json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(frame, &ack); err != nil || len(ack.ID) == 0 || (ack.Result == nil && ack.Error == nil) {
+		return "", false, false
+	}
+	return string(bytes.Trim(ack.ID, `"`)), ack.Error == nil, true
+}
+
+// OKXAck parses {"event":"subscribe","arg":{...},"id":"1"}, or
+// {"event":"error",...} for a refused one. Unsubscribe acks are acks too,
+// for no request health tracks.
+func OKXAck(frame []byte) (string, bool, bool) {
+	if !bytes.Contains(frame, []byte(`"event"`)) {
+		return "", false, false
+	}
+	var ack struct {
+		Event string `json:"event"`
+		ID    string `json:"id"`
+	}
+	if err := json.Unmarshal(frame, &ack); err != nil {
+		return "", false, false
+	}
+	switch ack.Event {
+	case "subscribe", "unsubscribe":
+		return ack.ID, true, true
+	case "error":
+		return ack.ID, false, true
+	}
+	return "", false, false
+}
+
+// KrakenAck parses Kraken Futures' {"event":"subscribed","feed":..,
+// "product_ids":[..]}, or {"event":"error",...} for a refused subscribe.
+// The acks echo no request ID, so the parser finds the request by its
+// topic: topics must be subscribed one per request (MaxArgsPerRequest 1).
+// Unsubscribe acks are acks too, for no request health tracks.
+func KrakenAck(topics []string) AckParser {
+	reqIDs := make(map[string]string, len(topics))
+	for i, t := range topics {
+		reqIDs[t] = strconv.Itoa(i + 1)
+	}
+	return func(frame []byte) (string, bool, bool) {
+		if !bytes.Contains(frame, []byte(`"event"`)) {
+			return "", false, false
+		}
+		var ack struct {
+			Event      string   `json:"event"`
+			Feed       string   `json:"feed"`
+			ProductIDs []string `json:"product_ids"`
+		}
+		if err := json.Unmarshal(frame, &ack); err != nil {
+			return "", false, false
+		}
+		switch ack.Event {
+		case "subscribed":
+			var reqID string
+			if len(ack.ProductIDs) > 0 {
+				reqID = reqIDs[ack.Feed+":"+ack.ProductIDs[0]]
+			}
+			return reqID, true, true
+		case "unsubscribed":
+			return "", true, true
+		case "error", "subscribed_failed":
+			return "", false, true
+		}
+		return "", false, false
+	}
+}
+
+type healthState struct {
+	clock    clock.Clock
+	mu       sync.Mutex
+	h        Health
+	requests map[string][]string
+}
+
+func newHealthState(endpoint string, topics []string, clk clock.Clock) *healthState {
+	s := &healthState{
+		clock:    clk,
+		h:        Health{Endpoint: endpoint, Topics: make(map[string]TopicState, len(topics))},
+		requests: make(map[string][]string),
+	}
+	for _, t := range topics {
+		s.h.Topics[t] = TopicIdle
+	}
+	return s
+}
+
+func (s *healthState) snapshot() Health {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := s.h
+	cp.Topics = make(map[string]TopicState, len(s.h.Topics))
+	for k, v := range s.h.Topics {
+		cp.Topics[k] = v
+	}
+	return cp
+}
+
+func (s *healthState) backingOff(attempt int, delay time.Duration) {
+	s.mu.Lock()
+	s.h.Attempt = attempt
+	s.h.Backoff = delay
+	s.mu.Unlock()
+}
+
+func (s *healthState) subscribing(reqID string, topics []string) {
+	s.mu.Lock()
+	s.requests[reqID] = topics
+	for _, t := range topics {
+		s.h.Topics[t] = TopicPending
+	}
+	s.mu.Unlock()
+}
+
+func (s *healthState) ack(reqID string, success bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	topics, ok := s.requests[reqID]
+	if !ok {
+		return
+	}
+	delete(s.requests, reqID)
+	st := TopicSubscribed
+	if !success {
+		st = TopicFailed
+	}
+	for _, t := range topics {
+		s.h.Topics[t] = st
+	}
+}
+
+func (s *healthState) connected() {
+	s.mu.Lock()
+	s.h.Connected = true
+	s.h.ConnectedAt = s.clock.Now()
+	s.h.Attempt = 0
+	s.h.Backoff = 0
+	s.mu.Unlock()
+}
+
+func (s *healthState) disconnected(err error, reconnect bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.h.Connected = false
+	if err != nil {
+		s.h.LastError = err.Error()
+	}
+	if reconnect {
+		s.h.Reconnects++
+		if errors.Is(err, ErrStale) {
+			s.h.Stale++
+		}
+	}
+	s.requests = make(map[string][]string)
+	for t := range s.h.Topics {
+		s.h.Topics[t] = TopicIdle
+	}
+}
+
+func (s *healthState) message() {
+	s.mu.Lock()
+	s.h.LastMessage = s.clock.Now()
+	s.mu.Unlock()
+}
@@ -0,0 +1,81 @@
+package wsclient
+
+import (
+	"context"
+	"sync"
+)
+
+// Chunk splits topics into consecutive batches of at most n; n <= 0 yields a
+// single batch.
+func Chunk(topics []string, n int) [][]string {
+	if len(topics) == 0 {
+		return nil
+	}
+	if n <= 0 || len(topics) <= n {
+		return [][]string{topics}
+	}
+	out := make([][]string, 0, (len(topics)+n-1)/n)
+	for len(topics) > n {
+		out = append(out, topics[:n:n])
+		topics = topics[n:]
+	}
+	return append(out, topics)
+}
+
+// Pool multiplexes cfg.Topics over as few connections as the venue allows,
+// opening an extra connection every maxTopicsPerConn topics.
+type Pool struct {
+	clients []*Client
+}
+
+// NewPool shards cfg.Topics across connections. cfg.OnMessage is shared by
+// every shard and is called concurrently, so it must guard its own state.
+func NewPool(cfg Config, maxTopicsPerConn int) *Pool {
+	shards := Chunk(cfg.Topics, maxTopicsPerConn)
+	if len(shards) == 0 {
+		shards = [][]string{nil}
+	}
+	p := &Pool{clients: make([]*Client, 0, len(shards))}
+	for _, topics := range shards {
+		shardCfg := cfg
+		shardCfg.Topics = topics
+		p.clients = append(p.clients, New(shardCfg))
+	}
+	return p
+}
+
+// Conns is the number of connections the pool keeps open.
+func (p *Pool) Conns() int {
+	return len(p.clients)
+}
+
+func (p *Pool) Reconnects() uint64 {
+	var n uint64
+	for _, c := range p.clients {
+		n += c.Reconnects()
+	}
+	return n
+}
+
+// Health returns one entry per shard connection.
+func (p *Pool) Health() []Health {
+	out := make([]Health, 0, len(p.clients))
+	for _, c := range p.clients {
+		out = append(out, c.Health())
+	}
+	return out
+}
+
+// Run blocks until ctx is done and every shard has shut down.
+func (p *Pool) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, c := range p.clients {
+		wg.Add(1)
+		go func(c *Client) {
+			defer wg.Done()
+			_ = c.Run(ctx)
+		}(c)
+	}
+	wg.Wait()
+	return nil
+}
@@ -0,0 +1,251 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/admin"
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/latency"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/router"
+	"github.com/helix-lab/helix/gateway/pkg/strategy"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+	"github.com/helix-lab/helix/gateway/pkg/ws"
+)
+
+func adminCall(t *testing.T, h http.Handler, method, path, token, body string, out any) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if out != nil && rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return rec.Code
+}
+
+func TestAdminServer(t *testing.T) {
+	books := orderbook.NewManager()
+	books.Apply(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 100, BestAsk: 101, BidSize: 1, AskSize: 1})
+	books.Apply(transport.DepthUpdate{Venue: "BINANCE", Symbol: "BTCUSDT", BestBid: 100.5, BestAsk: 101.5, BidSize: 2, AskSize: 2})
+
+	wsRouter := ws.NewRouter()
+	wsRouter.Add(ws.NewSimConnector(ws.DefaultSimConfig("SIM")))
+	tracker := executor.NewTracker()
+	sender := executor.NewOrderSender(transport.NewPublisher("inproc://admin"), router.NewSmartRouter(router.DefaultFees()))
+	sender.SetTracker(tracker)
+	h := (&admin.Server{Token: "secret", Books: books, Router: wsRouter, Orders: tracker, Sender: sender}).Handler()
+
+	if code := adminCall(t, h, "GET", "/v1/books", "", "", nil); code != http.StatusUnauthorized {
+		t.Fatalf("no token: %d", code)
+	}
+	if code := adminCall(t, h, "GET", "/v1/books", "wrong", "", nil); code != http.StatusUnauthorized {
+		t.Fatalf("bad token: %d", code)
+	}
+
+	var got []admin.SymbolBooks
+	if code := adminCall(t, h, "GET", "/v1/books/btcusdt", "secret", "", &got); code != http.StatusOK {
+		t.Fatalf("books: %d", code)
+	}
+	if len(got) != 1 || got[0].NBBO.BestBid != 100.5 || got[0].NBBO.BestAsk != 101 || len(got[0].Venues) != 2 {
+		t.Fatalf("books = %+v", got)
+	}
+	if code := adminCall(t, h, "GET", "/v1/books/ETHUSDT", "secret", "", nil); code != http.StatusNotFound {
+		t.Fatalf("unknown symbol: %d", code)
+	}
+	var ranks []admin.VenueRank
+	if code := adminCall(t, h, "GET", "/v1/venues/btcusdt?side=sell&size=1.5", "secret", "", &ranks); code != http.StatusOK {
+		t.Fatalf("venues: %d", code)
+	}
+	if len(ranks) != 2 || ranks[0].Venue != "BINANCE" || !ranks[0].Sufficient || ranks[1].Sufficient {
+		t.Fatalf("venues = %+v", ranks)
+	}
+	if code := adminCall(t, h, "GET", "/v1/venues/BTCUSDT?side=hold", "secret", "", nil); code != http.StatusBadRequest {
+		t.Fatalf("bad side: %d", code)
+	}
+	if code := adminCall(t, h, "POST", "/v1/books", "secret", "", nil); code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST books: %d", code)
+	}
+
+	views := map[string]router.BookView{"BYBIT": {BestBid: 100, BestAsk: 101}}
+	if _, err := sender.Send(context.Background(), transport.Action{Symbol: "BTCUSDT", Side: "BUY", Size: 1}, views); err != nil {
+		t.Fatal(err)
+	}
+	var orders []admin.Order
+	adminCall(t, h, "GET", "/v1/orders", "secret", "", &orders)
+	if len(orders) != 1 || orders[0].Venue != "BYBIT" {
+		t.Fatalf("orders = %+v", orders)
+	}
+	if err := tracker.Fill(orders[0].ID, 101, 0.4); err != nil {
+		t.Fatal(err)
+	}
+	var ex admin.Execution
+	if code := adminCall(t, h, "GET", "/v1/orders/"+orders[0].ID, "secret", "", &ex); code != http.StatusOK {
+		t.Fatalf("order: %d", code)
+	}
+	if ex.Fills != 1 || ex.Filled != 0.4 || ex.Remaining != 0.6 || ex.AvgPrice != 101 || ex.Status != executor.StatusOpen {
+		t.Fatalf("execution = %+v", ex)
+	}
+	if code := adminCall(t, h, "GET", "/v1/orders/hx-none", "secret", "", nil); code != http.StatusNotFound {
+		t.Fatalf("unknown order: %d", code)
+	}
+
+	var ks admin.KillSwitch
+	adminCall(t, h, "POST", "/v1/killswitch/arm", "secret", `{"reason":"test"}`, &ks)
+	if !ks.Armed || ks.Reason != "test" {
+		t.Fatalf("arm = %+v", ks)
+	}
+	_, err := sender.Send(context.Background(), transport.Action{Symbol: "BTCUSDT", Side: "BUY", Size: 1}, views)
+	if !errors.Is(err, executor.ErrKillSwitch) {
+		t.Fatalf("send while armed: %v", err)
+	}
+	adminCall(t, h, "POST", "/v1/killswitch/disarm", "secret", "", &ks)
+	if ks.Armed {
+		t.Fatal("still armed")
+	}
+
+	var subs map[string][]string
+	adminCall(t, h, "POST", "/v1/subscriptions/sim", "secret", `{"subscribe":["ethusdt"],"unsubscribe":["BTCUSDT"]}`, &subs)
+	if len(subs["SIM"]) != 1 || subs["SIM"][0] != "ETHUSDT" {
+		t.Fatalf("subscriptions = %v", subs)
+	}
+	if code := adminCall(t, h, "POST", "/v1/subscriptions/NOPE", "secret", `{"subscribe":["X"]}`, nil); code != http.StatusBadRequest {
+		t.Fatalf("unknown venue: %d", code)
+	}
+	if code := adminCall(t, h, "GET", "/v1/replay", "secret", "", nil); code != http.StatusNotFound {
+		t.Fatalf("replay without controller: %d", code)
+	}
+}
+
+func TestAdminDashboard(t *testing.T) {
+	books := orderbook.NewManager()
+	books.Apply(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 100, BestAsk: 101, BidSize: 1, AskSize: 1})
+	routes := admin.NewRouteLog(2)
+	sender := executor.NewOrderSender(transport.NewPublisher("inproc://dashboard"), router.NewSmartRouter(router.DefaultFees()))
+	sender.SetTracker(executor.NewTracker())
+	sender.SetJournal(routes)
+	sender.SetLimits(executor.Limits{MaxOrderSize: func(string, string) float64 { return 2 }})
+	lat := latency.NewRegistry()
+	lat.Observe("route", latency.Labels{Venue: "BYBIT"}, 40*time.Microsecond)
+	srv := &admin.Server{Token: "secret", Books: books, Routes: routes, Latency: lat}
+
+	views := map[string]router.BookView{"BYBIT": {BestBid: 100, BestAsk: 101}}
+	for _, size := range []float64{1, 3, 0.5} {
+		_, _ = sender.Send(context.Background(), transport.Action{Symbol: "BTCUSDT", Side: "BUY", Size: size}, views)
+	}
+	var got []admin.Route
+	if code := adminCall(t, srv.Handler(), "GET", "/v1/routes", "secret", "", &got); code != http.StatusOK {
+		t.Fatalf("routes: %d", code)
+	}
+	// the oldest decision fell out; the refused one kept its reason
+	if len(got) != 2 || got[0].Size != 0.5 || got[0].OrderID == "" || got[1].Size != 3 || !strings.Contains(got[1].Error, "size") {
+		t.Fatalf("routes = %+v", got)
+	}
+	var series []admin.Latency
+	adminCall(t, srv.Handler(), "GET", "/v1/latency", "secret", "", &series)
+	if len(series) != 1 || series[0].Labels != "{venue=BYBIT}" || series[0].Count != 1 || series[0].Max < 40 {
+		t.Fatalf("latency = %+v", series)
+	}
+
+	// the page is public and only talks to the token-protected API
+	rec := httptest.NewRecorder()
+	srv.Dashboard().ServeHTTP(rec, httptest.NewRequest("GET", "/dashboard", nil))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("dashboard: %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	for _, path := range []string{"/v1/books", "/v1/health", "/v1/routes", "/v1/orders", "/v1/positions", "/v1/latency"} {
+		if !strings.Contains(body, `"`+path+`"`) {
+			t.Errorf("dashboard does not poll %s", path)
+		}
+	}
+}
+
+func TestAdminStrategyQuotas(t *testing.T) {
+	books := orderbook.NewManager()
+	books.Apply(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 100, BestAsk: 102, BidSize: 1, AskSize: 1})
+	tracker := executor.NewTracker()
+	routes := admin.NewRouteLog(16)
+	sender := executor.NewOrderSender(transport.NewPublisher("inproc://quotas"), router.NewSmartRouter(router.DefaultFees()))
+	sender.SetTracker(tracker)
+	sender.SetJournal(routes)
+	host := strategy.NewHost(books, sender, tracker)
+	host.Add(strategy.Config{Name: "maker", Quota: strategy.Quota{MaxPosition: 2, MaxOpenNotional: 250, MaxOrdersPerSec: 3}}, strategy.Base{})
+	host.Add(strategy.Config{Name: "taker"}, strategy.Base{})
+	srv := (&admin.Server{Token: "secret", Routes: routes, Strategies: host}).Handler()
+
+	ctx := context.Background()
+	maker, _ := host.Handle("maker")
+	taker, _ := host.Handle("taker")
+	buy := transport.Action{Symbol: "BTCUSDT", Side: "BUY", Size: 1}
+	id, err := maker.Submit(ctx, buy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a second open order would take the open notional to 2*101
+	if _, err := maker.Submit(ctx, transport.Action{Symbol: "BTCUSDT", Side: "BUY", Size: 1.5}); !errors.Is(err, strategy.ErrQuota) {
+		t.Fatalf("open notional: err = %v", err)
+	}
+	if err := host.Fill(ctx, transport.Fill{OrderID: id, Venue: "BYBIT", Symbol: "BTCUSDT", Side: "BUY", Price: 100, Qty: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := maker.Submit(ctx, transport.Action{Symbol: "BTCUSDT", Side: "BUY", Size: 1.5}); !errors.Is(err, strategy.ErrQuota) {
+		t.Fatalf("position: err = %v", err)
+	}
+	if _, err := maker.Submit(ctx, transport.Action{Symbol: "BTCUSDT", Side: "BUY", Size: 1}); err != nil {
+		t.Fatalf("within quota: %v", err)
+	}
+	if _, err := maker.Submit(ctx, transport.Action{Symbol: "BTCUSDT", Side: "SELL", Size: 0.5}); err != nil {
+		t.Fatalf("reducing: %v", err)
+	}
+	if _, err := maker.Submit(ctx, transport.Action{Symbol: "BTCUSDT", Side: "SELL", Size: 0.5}); !errors.Is(err, strategy.ErrQuota) {
+		t.Fatalf("rate: err = %v", err)
+	}
+
+	// halting one strategy leaves the other trading
+	var st admin.Strategy
+	if code := adminCall(t, srv, "POST", "/v1/strategies/taker/halt", "secret", `{"reason":"drawdown"}`, &st); code != http.StatusOK || !st.Halted || st.HaltReason != "drawdown" {
+		t.Fatalf("halt: %d %+v", code, st)
+	}
+	if _, err := taker.Submit(ctx, buy); !errors.Is(err, strategy.ErrHalted) {
+		t.Fatalf("halted: err = %v", err)
+	}
+	if sender.KillSwitch().Armed {
+		t.Fatal("a strategy halt armed the gateway kill switch")
+	}
+	if code := adminCall(t, srv, "POST", "/v1/strategies/nope/halt", "secret", "", nil); code != http.StatusNotFound {
+		t.Fatalf("unknown strategy: %d", code)
+	}
+	if code := adminCall(t, srv, "POST", "/v1/strategies/taker/resume", "secret", "", &st); code != http.StatusOK || st.Halted {
+		t.Fatalf("resume: %d %+v", code, st)
+	}
+	if _, err := taker.Submit(ctx, buy); err != nil {
+		t.Fatalf("resumed: %v", err)
+	}
+
+	var list []admin.Strategy
+	adminCall(t, srv, "GET", "/v1/strategies", "secret", "", &list)
+	if len(list) != 2 || list[0].Name != "maker" || list[0].Sent != 3 || list[0].Refused != 3 ||
+		len(list[0].Holdings) != 1 || list[0].Holdings[0].Qty != 1 || list[0].Unrealized != 1 || list[1].Sent != 1 {
+		t.Fatalf("strategies = %+v", list)
+	}
+	accounts := map[string]int{}
+	for _, r := range routes.Recent() {
+		accounts[r.Account]++
+	}
+	if accounts["maker"] != 6 || accounts["taker"] != 2 {
+		t.Fatalf("route accounts = %v", accounts)
+	}
+}
@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/internal/app/download"
+	"github.com/helix-lab/helix/gateway/pkg/archive"
+	"github.com/helix-lab/helix/gateway/pkg/catalog"
+)
+
+func TestArchiveDownload(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	// newest first, as older Bybit files are
+	zw.Write([]byte(`timestamp,symbol,side,size,price,tickDirection,trdMatchID,grossValue,homeNotional,foreignNotional
+1704067260.5,BTCUSDT,Sell,0.2,42010.5,MinusTick,b,0,0.2,0
+1704067200.25,BTCUSDT,Buy,0.1,42000,PlusTick,a,0,0.1,0
+`))
+	zw.Close()
+	var zipped bytes.Buffer
+	z := zip.NewWriter(&zipped)
+	f, _ := z.Create("BTCUSDT-1m-2024-01-01.csv")
+	f.Write([]byte(`open_time,open,high,low,close,volume,close_time,quote_volume,count,taker_buy_volume,taker_buy_quote_volume,ignore
+1704067200000,42000,42100,41900,42050,2,1704067259999,84100,10,1,42050,0
+1704067260000,42050,42060,42000,42010,1,1704067319999,42030,5,0.5,21015,0
+`))
+	z.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/trading/BTCUSDT/BTCUSDT2024-01-01.csv.gz":
+			w.Write(gz.Bytes())
+		case "/data/futures/um/daily/klines/BTCUSDT/1m/BTCUSDT-1m-2024-01-01.zip":
+			w.Write(zipped.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	d := archive.New()
+	d.BybitURL, d.BinanceURL = srv.URL, srv.URL
+	dir := t.TempDir()
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	trades := archive.Request{Venue: "BYBIT", Market: "linear", Symbol: "BTCUSDT", Kind: archive.KindTrades, Day: day}
+	path := filepath.Join(dir, download.Name(trades))
+	if n, err := download.Day(ctx, d, trades, path); err != nil || n != 2 {
+		t.Fatalf("trades: %d rows, %v", n, err)
+	}
+	b, _ := os.ReadFile(path)
+	if want := "ts_ms,side,price,size,trade_id\n1704067200250,Buy,42000,0.1,a\n1704067260500,Sell,42010.5,0.2,b\n"; string(b) != want {
+		t.Fatalf("trades =\n%s", b)
+	}
+
+	klines := archive.Request{Venue: "BINANCE", Market: "linear", Symbol: "BTCUSDT", Kind: archive.KindKlines, Interval: time.Minute, Day: day}
+	bars := filepath.Join(dir, download.Name(klines))
+	if !strings.HasSuffix(bars, "binance_linear_BTCUSDT_klines_1m_2024-01-01.csv") {
+		t.Fatalf("name = %s", bars)
+	}
+	if n, err := download.Day(ctx, d, klines, bars); err != nil || n != 2 {
+		t.Fatalf("klines: %d rows, %v", n, err)
+	}
+
+	missing := trades
+	missing.Day = day.AddDate(0, 0, 1)
+	next := filepath.Join(dir, download.Name(missing))
+	if _, err := download.Day(ctx, d, missing, next); !errors.Is(err, archive.ErrNotFound) {
+		t.Fatalf("missing day: %v", err)
+	}
+	if _, err := os.Stat(next + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temporary file left behind: %v", err)
+	}
+
+	c, err := catalog.Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Datasets) != 2 {
+		t.Fatalf("datasets = %+v", c.Datasets)
+	}
+	for _, ds := range c.Datasets {
+		if ds.Symbol != "BTCUSDT" || ds.Status != catalog.StatusOK || ds.StartMs < day.UnixMilli() {
+			t.Fatalf("dataset = %+v", ds)
+		}
+	}
+	if got := c.Find(catalog.KindBars, "BTCUSDT", time.Time{}, time.Time{}); len(got) != 1 || got[0].Venue != "BINANCE" || got[0].Topic != "klines.1m" {
+		t.Fatalf("bars = %+v", got)
+	}
+	if got := c.Find(catalog.KindTrades, "BTCUSDT", time.Time{}, time.Time{}); len(got) != 1 || got[0].Venue != "BYBIT" {
+		t.Fatalf("trades = %+v", got)
+	}
+}
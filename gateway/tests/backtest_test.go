@@ -0,0 +1,334 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/backtest"
+	"github.com/helix-lab/helix/gateway/pkg/router"
+	"github.com/helix-lab/helix/gateway/pkg/strategy"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+const btL2 = `ts_ms,seq,prev_seq,book_side,price,size,type
+1000,1,0,bid,100,1,snapshot
+1000,1,0,bid,99,2,snapshot
+1000,1,0,ask,101,1,snapshot
+1000,1,0,ask,102,3,snapshot
+2000,2,1,ask,101,0,delta
+3000,3,2,bid,100,0,delta
+3000,3,2,bid,103,1,delta
+3000,3,2,ask,104,1,delta
+`
+
+const btTrades = `ts_ms,side,price,size,trade_id
+1500,Buy,101,0.5,t1
+2500,Sell,100,1,t2
+`
+
+// roundTrip buys on the first timer and sells once the buy fills.
+type roundTrip struct {
+	strategy.Base
+	trades int
+	timers []time.Time
+	fills  []transport.Fill
+}
+
+func (r *roundTrip) OnTrade(context.Context, strategy.Handle, transport.Trade) { r.trades++ }
+
+func (r *roundTrip) OnTimer(ctx context.Context, h strategy.Handle, now time.Time) {
+	r.timers = append(r.timers, now)
+	if len(r.timers) == 1 {
+		_, _ = h.Submit(ctx, transport.Action{Symbol: "BTCUSDT", Side: "BUY", Size: 1})
+	}
+}
+
+func (r *roundTrip) OnFill(ctx context.Context, h strategy.Handle, f transport.Fill) {
+	r.fills = append(r.fills, f)
+	if f.Side == "BUY" {
+		_, _ = h.Submit(ctx, transport.Action{Symbol: "BTCUSDT", Side: "SELL", Size: 1})
+	}
+}
+
+func TestBacktestLoaders(t *testing.T) {
+	dir := t.TempDir()
+	l2 := filepath.Join(dir, "l2.csv")
+	trades := filepath.Join(dir, "trades.csv")
+	if err := os.WriteFile(l2, []byte(btL2), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(trades, []byte(btTrades), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	depth, err := backtest.LoadL2CSV(l2, "BYBIT", "BTCUSDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(depth) != 3 {
+		t.Fatalf("depth events = %d, want one per message", len(depth))
+	}
+	want := []transport.DepthUpdate{
+		{BestBid: 100, BestAsk: 101, BidSize: 1, AskSize: 1},
+		{BestBid: 100, BestAsk: 102, BidSize: 1, AskSize: 3},
+		{BestBid: 103, BestAsk: 102, BidSize: 1, AskSize: 3},
+	}
+	for i, w := range want {
+		d := depth[i].Depth
+		if d.BestBid != w.BestBid || d.BestAsk != w.BestAsk || d.BidSize != w.BidSize || d.AskSize != w.AskSize {
+			t.Fatalf("event %d = %+v, want %+v", i, d, w)
+		}
+	}
+
+	tr, err := backtest.LoadTradesCSV(trades, "BYBIT", "BTCUSDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged := backtest.Merge(depth, tr)
+	var kinds []string
+	for _, ev := range merged {
+		if ev.Trade != nil {
+			kinds = append(kinds, "t"+ev.Trade.Side)
+		} else {
+			kinds = append(kinds, "d")
+		}
+	}
+	if got := strings.Join(kinds, " "); got != "d tBUY d tSELL d" {
+		t.Fatalf("merged order = %s", got)
+	}
+}
+
+func TestBacktestEngine(t *testing.T) {
+	events := []backtest.Event{
+		depthAt(1000, 100, 101),
+		depthAt(1500, 100, 101),
+		{TsNs: 1600 * 1e6, Trade: &transport.Trade{Venue: "BYBIT", Symbol: "BTCUSDT", Side: "BUY", Price: 101, Qty: 1}},
+		depthAt(2050, 104, 105),
+		depthAt(3000, 110, 111),
+	}
+	fees := router.FeeModel{Taker: map[string]float64{"BYBIT": 0.001}}
+	// the buy sent at the 2s timer executes 100ms later, after the book
+	// moved to 104/105; the sell on its fill goes out another 100ms later
+	eng := backtest.New(backtest.Config{Fees: fees, Latency: 100 * time.Millisecond})
+	rt := &roundTrip{}
+	eng.Add(strategy.Config{Name: "rt", Timer: time.Second}, rt)
+	res, err := eng.Run(context.Background(), events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rt.timers) != 2 || !rt.timers[0].Equal(time.Unix(2, 0)) {
+		t.Fatalf("timers = %v, want simulated 2s and 3s", rt.timers)
+	}
+	if rt.trades != 1 {
+		t.Fatalf("trades seen = %d", rt.trades)
+	}
+	if len(res.Fills) != 2 || res.Orders != 2 {
+		t.Fatalf("orders=%d fills=%+v", res.Orders, res.Fills)
+	}
+	buy, sell := res.Fills[0], res.Fills[1]
+	if buy.Price != 105 || buy.TsNs != 2100*1e6 || buy.Strategy != "rt" {
+		t.Fatalf("buy = %+v, want 105 at 2.1s", buy)
+	}
+	if sell.Price != 104 || sell.TsNs != 2200*1e6 {
+		t.Fatalf("sell = %+v, want 104 at 2.2s", sell)
+	}
+	if res.Realized != -1 || res.Unrealized != 0 {
+		t.Fatalf("realized=%g unrealized=%g", res.Realized, res.Unrealized)
+	}
+	if wantFees := 0.105 + 0.104; res.Fees < wantFees-1e-9 || res.Fees > wantFees+1e-9 || res.PnL() != res.Realized-res.Fees {
+		t.Fatalf("fees=%g pnl=%g", res.Fees, res.PnL())
+	}
+
+	var buf bytes.Buffer
+	if err := res.WriteTrades(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 3 || !strings.HasPrefix(lines[1], "2100000000,rt,hx-1,BYBIT,BTCUSDT,BUY,105,1,") {
+		t.Fatalf("trade log:\n%s", buf.String())
+	}
+}
+
+func depthAt(ms int64, bid, ask float64) backtest.Event {
+	return backtest.Event{TsNs: ms * 1e6, Depth: &transport.DepthUpdate{
+		Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: bid, BestAsk: ask, BidSize: 1, AskSize: 1, ExchTsMs: ms}}
+}
+
+// joinBid quotes one lot at the bid on the first book.
+type joinBid struct {
+	strategy.Base
+	sent bool
+}
+
+func (j *joinBid) OnBook(ctx context.Context, h strategy.Handle, b strategy.Book) {
+	if !j.sent {
+		j.sent = true
+		_, _ = h.Submit(ctx, transport.Action{Symbol: b.Symbol, Side: "BUY", Size: 1, Price: b.NBBO.BestBid})
+	}
+}
+
+func TestBacktestMakerFills(t *testing.T) {
+	trade := func(ms int64, qty float64) backtest.Event {
+		return backtest.Event{TsNs: ms * 1e6, Trade: &transport.Trade{Venue: "BYBIT", Symbol: "BTCUSDT", Side: "SELL", Price: 100, Qty: qty}}
+	}
+	events := []backtest.Event{
+		depthAt(1000, 100, 101), // one lot ahead of us
+		trade(1100, 1),
+		trade(1200, 0.4),
+		depthAt(1300, 100, 101),
+	}
+	fees := router.FeeModel{Taker: map[string]float64{"BYBIT": 0.001}, Maker: map[string]float64{"BYBIT": -0.0001}}
+	eng := backtest.New(backtest.Config{Fees: fees})
+	eng.Add(strategy.Config{Name: "mm"}, &joinBid{})
+	res, err := eng.Run(context.Background(), events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Fills) != 1 || !res.Fills[0].Maker || res.Fills[0].Qty != 0.4 || res.Fills[0].TsNs != 1200*1e6 {
+		t.Fatalf("fills = %+v, want 0.4 maker at 1.2s", res.Fills)
+	}
+	if res.Fees > -0.004+1e-9 || res.Fees < -0.004-1e-9 {
+		t.Fatalf("fees = %g, want the maker rebate", res.Fees)
+	}
+	if res.Unfilled != 1 {
+		t.Fatalf("unfilled = %d, want the resting remainder", res.Unfilled)
+	}
+}
+
+func TestInferMakerFill(t *testing.T) {
+	trade := func(ms int64, px, qty float64) backtest.Event {
+		return backtest.Event{TsNs: ms * 1e6, Trade: &transport.Trade{Venue: "BYBIT", Symbol: "BTCUSDT", Side: "SELL", Price: px, Qty: qty}}
+	}
+	events := []backtest.Event{
+		depthAt(1000, 100, 101), // one lot at the bid
+		trade(1000, 100, 0.5),   // before our arrival in the same ms
+		trade(1500, 100, 1.5),
+		trade(2000, 99, 0.1),
+	}
+	o := backtest.RestingOrder{Venue: "BYBIT", Symbol: "BTCUSDT", Side: "buy", Price: 100, Size: 1, ArrivalMs: 1000}
+	rep, err := backtest.InferMakerFill(events, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Outcome != backtest.OutcomeFilled || rep.QueueAhead != 1 || len(rep.Fills) != 2 ||
+		rep.Fills[0].Qty != 0.5 || rep.FirstFillMs != 1500 || rep.FullFillMs != 2000 || rep.Filled != 1 {
+		t.Fatalf("report = %+v", rep)
+	}
+
+	o.Price = 101
+	if rep, _ := backtest.InferMakerFill(events, o); rep.Outcome != backtest.OutcomeMarketable {
+		t.Fatalf("a buy at the ask = %+v", rep)
+	}
+	// behind the touch nothing is visible and nothing trades down to it
+	o.Price, o.TTL = 99.5, 700*time.Millisecond
+	rep, _ = backtest.InferMakerFill(events, o)
+	if rep.Outcome != backtest.OutcomeUnfilled || rep.QueueAhead != -1 || rep.EndMs != 1700 {
+		t.Fatalf("behind the touch = %+v", rep)
+	}
+	o.ArrivalMs = 5000
+	if _, err := backtest.InferMakerFill(events, o); err == nil {
+		t.Fatal("arrival after the capture accepted")
+	}
+}
+
+func TestBacktestReport(t *testing.T) {
+	if dd := backtest.MaxDrawdown([]backtest.CurvePoint{{PnL: 0}, {PnL: 5}, {PnL: 1}, {PnL: 7}, {PnL: 4}}); dd != 4 {
+		t.Fatalf("max drawdown = %g, want 4", dd)
+	}
+
+	events := []backtest.Event{
+		depthAt(1000, 100, 101),
+		depthAt(2050, 104, 105),
+		depthAt(3000, 110, 111),
+		depthAt(5000, 110, 111),
+	}
+	fees := router.FeeModel{Taker: map[string]float64{"BYBIT": 0.001}}
+	build := func(latency time.Duration) (*backtest.Engine, error) {
+		eng := backtest.New(backtest.Config{Fees: fees, Latency: latency})
+		eng.Add(strategy.Config{Name: "rt", Timer: time.Second}, &roundTrip{})
+		return eng, nil
+	}
+	eng, _ := build(0)
+	res, err := eng.Run(context.Background(), events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep := backtest.NewReport(res, 0)
+	// bought and sold at 2s against 100/101 with arrival mid 100.5
+	if len(rep.Venues) != 1 || rep.Venues[0].Fills != 2 || rep.Venues[0].SlippageBps <= 0 {
+		t.Fatalf("venues = %+v", rep.Venues)
+	}
+	if len(rep.Curve) < 2 || rep.Curve[len(rep.Curve)-1].PnL != rep.PnL {
+		t.Fatalf("curve = %+v, pnl %g", rep.Curve, rep.PnL)
+	}
+	if err := rep.Sweep(context.Background(), events, []time.Duration{0, 100 * time.Millisecond}, build); err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Sensitivity) != 2 || rep.Sensitivity[0].PnL != rep.PnL || rep.Sensitivity[1].PnL >= rep.PnL {
+		t.Fatalf("sensitivity = %+v, want the slower run to do worse than %g", rep.Sensitivity, rep.PnL)
+	}
+
+	var js, page bytes.Buffer
+	if err := rep.WriteJSON(&js); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{`"max_drawdown"`, `"slippage_bps"`, `"curve"`, `"latency": "100ms"`} {
+		if !strings.Contains(js.String(), key) {
+			t.Fatalf("JSON report lacks %s:\n%s", key, js.String())
+		}
+	}
+	if err := rep.WriteHTML(&page); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(page.String(), "<polyline") || !strings.Contains(page.String(), "Latency sensitivity") {
+		t.Fatalf("HTML report:\n%s", page.String())
+	}
+}
+
+func TestBacktestLatencyProfiles(t *testing.T) {
+	events := []backtest.Event{
+		depthAt(1000, 100, 101),
+		depthAt(2050, 104, 105),
+		depthAt(3000, 110, 111),
+		depthAt(5000, 110, 111),
+	}
+	build := func(p backtest.Profile) (*backtest.Engine, error) {
+		eng := backtest.New(p.Apply(backtest.Config{}))
+		eng.Add(strategy.Config{Name: "rt", Timer: time.Second}, &roundTrip{})
+		return eng, nil
+	}
+	colo, ok := backtest.FindProfile(backtest.Profiles, "COLO")
+	if !ok || colo.Latency != 2*time.Millisecond {
+		t.Fatalf("colo = %+v %t", colo, ok)
+	}
+	eng, _ := build(colo)
+	res, err := eng.Run(context.Background(), events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep := backtest.NewReport(res, colo.Latency)
+	// a slow link everywhere but a fast one to BYBIT trades like cloud
+	split := backtest.Profile{Name: "split", Latency: 150 * time.Millisecond, Venues: map[string]time.Duration{"BYBIT": 40 * time.Millisecond}}
+	if err := rep.SweepProfiles(context.Background(), events, append(backtest.Profiles, split), build); err != nil {
+		t.Fatal(err)
+	}
+	pnl := map[string]float64{}
+	for _, r := range rep.Sensitivity {
+		if r.Fills != 2 {
+			t.Fatalf("%s fills = %d", r.Profile, r.Fills)
+		}
+		pnl[r.Profile] = r.PnL
+	}
+	// colo buys 101 and sells 100 before the jump; cloud buys before it
+	// and sells after at 104; retail buys and sells after it
+	if pnl["colo"] != -1 || pnl["cloud"] != 3 || pnl["retail"] != -1 || pnl["split"] != 3 || rep.PnL != -1 {
+		t.Fatalf("pnl by profile = %v, run %g", pnl, rep.PnL)
+	}
+	if last := rep.Sensitivity[3]; last.Latency != "150ms (BYBIT 40ms)" {
+		t.Fatalf("split latency = %q", last.Latency)
+	}
+}
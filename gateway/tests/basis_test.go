@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/basis"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+func TestBasisMonitor(t *testing.T) {
+	books := orderbook.NewManager()
+	for _, u := range []transport.DepthUpdate{
+		{Venue: "BYBIT_SPOT", Symbol: "BTCUSDT", BestBid: 9999, BestAsk: 10001, BidSize: 1, AskSize: 1},
+		{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 10009, BestAsk: 10011, BidSize: 1, AskSize: 1},
+		{Venue: "BINANCE", Symbol: "BTCUSDT", BestBid: 9989, BestAsk: 9991, BidSize: 1, AskSize: 1},
+		// no spot book, no basis
+		{Venue: "BYBIT", Symbol: "ETHUSDT", BestBid: 500, BestAsk: 501, BidSize: 1, AskSize: 1},
+	} {
+		books.Apply(u)
+	}
+	above, below := 15.0, -5.0
+	m := basis.New(basis.Config{Spot: []string{"BYBIT_SPOT"}, Window: time.Minute, AlertAboveBps: &above, AlertBelowBps: &below}, books)
+	var alerted []transport.Basis
+	m.SetAlert(func(b transport.Basis) { alerted = append(alerted, b) })
+
+	now := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	got := m.Sample(now)
+	if len(got) != 2 || got[0].Venue != "BINANCE" || !near(got[0].BasisBps, -10) || got[1].Venue != "BYBIT" || !near(got[1].BasisBps, 10) {
+		t.Fatalf("book mids = %+v", got)
+	}
+	if got[0].Alert != basis.AlertBelow || got[1].Alert != "" || len(alerted) != 1 || alerted[0].Venue != "BINANCE" {
+		t.Fatalf("alerts = %+v, callbacks %+v", got, alerted)
+	}
+
+	// a fresh venue mark wins over the book mid; a stale one does not
+	m.Mark(transport.FundingRate{Venue: "BYBIT", Symbol: "BTCUSDT", Rate: 0.0001, Mark: 10020, TsMs: now.Add(30 * time.Second).UnixMilli()})
+	m.Mark(transport.FundingRate{Venue: "BINANCE", Symbol: "BTCUSDT", Rate: 0.0001, Mark: 9000, TsMs: now.Add(-time.Hour).UnixMilli()})
+	got = m.Sample(now.Add(30 * time.Second))
+	b := got[1]
+	if b.Mark != 10020 || !near(b.BasisBps, 20) || !near(b.MeanBps, 15) || b.MinBps != 10 || !near(b.MaxBps, 20) || b.Samples != 2 || b.Alert != basis.AlertAbove {
+		t.Fatalf("with the mark = %+v", b)
+	}
+	if got[0].Mark != 9990 || len(alerted) != 2 {
+		t.Fatalf("stale mark used or alert repeated: %+v, callbacks %d", got[0], len(alerted))
+	}
+
+	// the window drops the first sample
+	got = m.Sample(now.Add(90 * time.Second))
+	if b := got[0]; b.Samples != 2 || !near(b.MeanBps, -10) {
+		t.Fatalf("rolled window = %+v", b)
+	}
+	if last, ok := m.Last("BYBIT", "BTCUSDT"); !ok || last.TsMs != now.Add(90*time.Second).UnixMilli() || last.Mark != 10010 {
+		t.Fatalf("last = %+v, %t", last, ok)
+	}
+}
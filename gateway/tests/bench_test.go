@@ -0,0 +1,40 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/helix-lab/helix/gateway/pkg/bench"
+	"github.com/helix-lab/helix/gateway/pkg/ws/bybitjson"
+)
+
+func TestBenchFixtureAndCompare(t *testing.T) {
+	fix := bench.Synthetic(200, 20)
+	if len(fix.Frames) != 200 || len(fix.Tops) != 200 || len(fix.Rows) != len(fix.Deltas) {
+		t.Fatalf("fixture: %d frames, %d tops, %d rows, %d deltas", len(fix.Frames), len(fix.Tops), len(fix.Rows), len(fix.Deltas))
+	}
+	// the frames carry every delta
+	var m bybitjson.Book
+	levels := 0
+	for _, f := range fix.Frames {
+		if err := m.Decode(f); err != nil {
+			t.Fatal(err)
+		}
+		levels += len(m.Bids) + len(m.Asks)
+	}
+	if levels != len(fix.Deltas) {
+		t.Fatalf("frames carry %d levels, capture has %d deltas", levels, len(fix.Deltas))
+	}
+
+	base := bench.Report{Results: []bench.Result{{Name: "a", NsPerOp: 100}, {Name: "b", NsPerOp: 100}}}
+	cur := bench.Report{Results: []bench.Result{{Name: "a", NsPerOp: 150}, {Name: "c", NsPerOp: 1}}}
+	ch := bench.Compare(base, cur)
+	if len(ch) != 1 || ch[0].Name != "a" || ch[0].Delta != 0.5 {
+		t.Fatalf("compare: %+v", ch)
+	}
+}
+
+func BenchmarkSuite(b *testing.B) {
+	for _, c := range bench.Cases(bench.Synthetic(5000, 200)) {
+		b.Run(c.Name, c.F)
+	}
+}
@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"nhooyr.io/websocket"
+
+	"github.com/helix-lab/helix/gateway/pkg/bridge"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+	"github.com/helix-lab/helix/gateway/pkg/ws"
+)
+
+func TestBridgeFeed(t *testing.T) {
+	books := orderbook.NewManager()
+	books.Apply(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", Category: "linear", BestBid: 100, BestAsk: 101, BidSize: 1, AskSize: 2})
+	books.Apply(transport.DepthUpdate{Venue: "BINANCE", Symbol: "BTCUSDT", BestBid: 99, BestAsk: 102})
+	status := make(chan string, 1)
+	status <- "ok"
+	up := &bridge.Server{Token: "secret", Books: books, HealthEvery: 10 * time.Millisecond, Health: func() []ws.ConnectorHealth {
+		s := <-status
+		status <- s
+		return []ws.ConnectorHealth{{Venue: "BYBIT", Status: s}, {Venue: "BINANCE", Status: "ok"}}
+	}}
+	srv := httptest.NewServer(up.Handler())
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	depth := make(chan transport.DepthUpdate, 16)
+	c := bridge.NewConnector("COLO", wsURL(srv)+bridge.Path, "secret", []string{"BTCUSDT"})
+	c.Upstream = "BYBIT"
+	go c.Run(ctx, ws.Feeds{Depth: depth})
+	next := func() transport.DepthUpdate {
+		select {
+		case u := <-depth:
+			return u
+		case <-ctx.Done():
+			t.Fatal("no depth over the bridge")
+		}
+		return transport.DepthUpdate{}
+	}
+
+	// the subscribed book on connecting, as the local venue
+	if u := next(); u.Venue != "COLO" || u.Symbol != "BTCUSDT" || u.Category != "linear" || u.BestAsk != 101 || u.AskSize != 2 {
+		t.Fatalf("snapshot = %+v", u)
+	}
+	up.Depth(transport.DepthUpdate{Venue: "BINANCE", Symbol: "BTCUSDT", BestBid: 1, BestAsk: 2})
+	up.Depth(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 100.5, BestAsk: 101, ExchTsMs: 7})
+	if u := next(); u.BestBid != 100.5 || u.ExchTsMs != 7 || u.RecvNs == 0 {
+		t.Fatalf("update = %+v", u)
+	}
+
+	<-status
+	status <- "down"
+	for {
+		if h := c.Health(); len(h) == 1 && !h[0].Connected && h[0].LastError == "upstream BYBIT down" {
+			break
+		}
+		if ctx.Err() != nil {
+			t.Fatalf("upstream down not propagated: %+v", c.Health())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if g := c.Gaps(); g.Gaps != 0 {
+		t.Fatalf("gaps on a healthy bridge = %+v", g)
+	}
+
+	// a connection that loses frames counts the gap until the resync
+	mock := &mockVenue{onSession: func(ctx context.Context, conn *websocket.Conn, _ int) {
+		for _, f := range []string{
+			`{"type":"depth","seq":1,"depth":{"venue":"BYBIT","symbol":"ETHUSDT","bid":10,"ask":11}}`,
+			`{"type":"health","seq":4}`,
+			`{"type":"depth","seq":5,"depth":{"venue":"BYBIT","symbol":"ETHUSDT","bid":10.5,"ask":11}}`,
+			`{"type":"synced","seq":6}`,
+		} {
+			_ = conn.Write(ctx, websocket.MessageText, []byte(f))
+		}
+		<-ctx.Done()
+	}}
+	lossy := httptest.NewServer(mock)
+	defer lossy.Close()
+	gc := bridge.NewConnector("BYBIT", wsURL(lossy), "", []string{"ETHUSDT"})
+	go gc.Run(ctx, ws.Feeds{Depth: depth})
+	next()
+	if u := next(); u.BestBid != 10.5 {
+		t.Fatalf("after the gap = %+v", u)
+	}
+	for gc.Gaps().Recovered == 0 && ctx.Err() == nil {
+		time.Sleep(5 * time.Millisecond)
+	}
+	g := gc.Gaps()
+	if g.Gaps != 1 || g.Recovered != 1 || g.Last == nil || g.Last.From != 1 || g.Last.To != 4 || g.Last.Venue != "BYBIT" {
+		t.Fatalf("gaps = %+v last %+v", g, g.Last)
+	}
+	if subs := mock.subscriptions(); len(subs) != 1 || len(subs[0]) != 1 || subs[0][0] != "BYBIT.ETHUSDT" {
+		t.Fatalf("subscriptions = %v", subs)
+	}
+}
@@ -0,0 +1,468 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"nhooyr.io/websocket"
+
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+	"github.com/helix-lab/helix/gateway/pkg/ws"
+	"github.com/helix-lab/helix/gateway/pkg/ws/bybitjson"
+	"github.com/helix-lab/helix/gateway/pkg/wsclient"
+)
+
+func TestBybitStreamShardsSubscriptions(t *testing.T) {
+	var mu sync.Mutex
+	var requests [][]string
+	conns := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.CloseNow()
+		mu.Lock()
+		conns++
+		mu.Unlock()
+		for {
+			_, data, err := c.Read(r.Context())
+			if err != nil {
+				return
+			}
+			var req struct {
+				ReqID string   `json:"req_id"`
+				Args  []string `json:"args"`
+			}
+			if json.Unmarshal(data, &req) != nil {
+				continue
+			}
+			mu.Lock()
+			requests = append(requests, req.Args)
+			mu.Unlock()
+			ack := fmt.Sprintf(`{"success":true,"ret_msg":"","op":"subscribe","req_id":%q}`, req.ReqID)
+			if err := c.Write(r.Context(), websocket.MessageText, []byte(ack)); err != nil {
+				return
+			}
+			for _, topic := range req.Args {
+				sym := topic[strings.LastIndex(topic, ".")+1:]
+				msg := fmt.Sprintf(`{"topic":%q,"type":"snapshot","ts":1,"data":{"s":%q,"b":[["100","1"]],"a":[["101","2"]]}}`, topic, sym)
+				if err := c.Write(r.Context(), websocket.MessageText, []byte(msg)); err != nil {
+					return
+				}
+			}
+		}
+	}))
+	defer srv.Close()
+
+	symbols := make([]string, 25)
+	for i := range symbols {
+		symbols[i] = fmt.Sprintf("SYM%dUSDT", i)
+	}
+	stream := ws.NewBybitStream("ws"+strings.TrimPrefix(srv.URL, "http"), symbols, 1)
+	stream.MaxTopicsPerConn = 20
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	out := make(chan transport.DepthUpdate, len(symbols))
+	go stream.Run(ctx, ws.Feeds{Depth: out})
+
+	seen := map[string]bool{}
+	for len(seen) < len(symbols) {
+		select {
+		case u := <-out:
+			if u.BestBid != 100 || u.BestAsk != 101 {
+				t.Fatalf("bad top of book: %+v", u)
+			}
+			seen[u.Symbol] = true
+		case <-ctx.Done():
+			t.Fatalf("only %d/%d symbols streamed", len(seen), len(symbols))
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if conns != 2 {
+		t.Fatalf("expected 2 connections for 25 topics at 20/conn, got %d", conns)
+	}
+	for _, args := range requests {
+		if len(args) > ws.BybitMaxArgsPerRequest {
+			t.Fatalf("subscribe request with %d args exceeds limit", len(args))
+		}
+	}
+	if len(requests) != 3 {
+		t.Fatalf("expected 3 subscribe requests (10+10, 5), got %d", len(requests))
+	}
+
+	health := stream.Health()
+	if len(health) != 2 {
+		t.Fatalf("expected health for 2 connections, got %d", len(health))
+	}
+	for _, h := range health {
+		if !h.Connected || h.LastMessage.IsZero() {
+			t.Fatalf("connection not reported live: %+v", h)
+		}
+		for topic, st := range h.Topics {
+			if st != wsclient.TopicSubscribed {
+				t.Fatalf("topic %s state %s, want subscribed", topic, st)
+			}
+		}
+	}
+}
+
+func TestRouterDeliversTradesLiquidationAndFunding(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.CloseNow()
+		if _, _, err := c.Read(r.Context()); err != nil {
+			return
+		}
+		frames := []string{
+			`{"topic":"publicTrade.BTCUSDT","type":"snapshot","ts":4,"data":[{"T":4,"s":"BTCUSDT","S":"Buy","v":"0.01","p":"43000","i":"t1"}]}`,
+			`{"topic":"allLiquidation.BTCUSDT","type":"snapshot","ts":5,"data":[{"T":5,"s":"BTCUSDT","S":"Sell","v":"0.5","p":"43000.5"}]}`,
+			`{"topic":"tickers.BTCUSDT","type":"snapshot","ts":6,"data":{"symbol":"BTCUSDT","fundingRate":"0.0001","nextFundingTime":"1700000000000"}}`,
+			`{"topic":"tickers.BTCUSDT","type":"delta","ts":7,"data":{"symbol":"BTCUSDT","lastPrice":"43001"}}`,
+			`{"topic":"tickers.BTCUSDT","type":"delta","ts":8,"data":{"symbol":"BTCUSDT","markPrice":"43002.5"}}`,
+		}
+		for _, f := range frames {
+			if err := c.Write(r.Context(), websocket.MessageText, []byte(f)); err != nil {
+				return
+			}
+		}
+		<-c.CloseRead(r.Context()).Done()
+	}))
+	defer srv.Close()
+
+	stream := ws.NewBybitStream("ws"+strings.TrimPrefix(srv.URL, "http"), []string{"BTCUSDT"}, 1)
+	stream.Trades = true
+	stream.Liquidations = true
+	stream.Funding = true
+	router := ws.NewRouter()
+	router.Add(stream)
+	router.Start()
+	defer router.Stop()
+
+	select {
+	case tr := <-router.Trades():
+		if tr.Venue != "BYBIT" || tr.Side != "BUY" || tr.Qty != 0.01 || tr.Price != 43000 || tr.TsMs != 4 {
+			t.Fatalf("unexpected trade: %+v", tr)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("no trade delivered")
+	}
+
+	select {
+	case liq := <-router.Liquidations():
+		if liq.Venue != "BYBIT" || liq.Side != "Sell" || liq.Qty != 0.5 || liq.Price != 43000.5 || liq.TsMs != 5 {
+			t.Fatalf("unexpected liquidation: %+v", liq)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("no liquidation delivered")
+	}
+	select {
+	case fr := <-router.Funding():
+		if fr.Rate != 0.0001 || fr.NextFundingMs != 1700000000000 {
+			t.Fatalf("unexpected funding: %+v", fr)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("no funding delivered")
+	}
+	// the lastPrice-only delta publishes nothing; the mark keeps the rate
+	select {
+	case fr := <-router.Funding():
+		if fr.Rate != 0.0001 || fr.Mark != 43002.5 || fr.NextFundingMs != 1700000000000 || fr.TsMs != 8 {
+			t.Fatalf("unexpected mark update: %+v", fr)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("no mark update delivered")
+	}
+	select {
+	case fr := <-router.Funding():
+		t.Fatalf("unexpected funding %+v", fr)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// depthFrame is a depth-n Bybit orderbook snapshot.
+func depthFrame(n int) []byte {
+	var b strings.Builder
+	b.WriteString(`{"topic":"orderbook.500.BTCUSDT","type":"snapshot","ts":1700000000123,"data":{"s":"BTCUSDT","b":[`)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `["%.1f","%.3f"]`, 65000-float64(i)/2, 1+float64(i)/1000)
+	}
+	b.WriteString(`],"a":[`)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `["%.1f","%.3f"]`, 65000.5+float64(i)/2, 2+float64(i)/1000)
+	}
+	b.WriteString(`],"u":4242,"seq":987654321},"cts":1700000000120}`)
+	return []byte(b.String())
+}
+
+func TestBybitJSONDecode(t *testing.T) {
+	frames := []string{
+		string(depthFrame(3)),
+		` { "type" : "delta", "data" : { "a" : [ ["101.5", "0"] , ["102","3","x"], ["9"] ], "b":[], "s":"ETHUSDT", "u":7, "pu":6, "extra":{"k":[1,{"z":"]}"}]} }, "topic":"orderbook.50.ETHUSDT", "ts":5 }`,
+		`{"topic":"publicTrade.BTCUSDT","type":"snapshot","ts":9,"data":[{"p":"1","v":"2"}]}`,
+	}
+	type level = []string
+	for _, f := range frames {
+		var want struct {
+			Topic string `json:"topic"`
+			Type  string `json:"type"`
+			Ts    int64  `json:"ts"`
+			Data  json.RawMessage
+		}
+		if err := json.Unmarshal([]byte(f), &want); err != nil {
+			t.Fatal(err)
+		}
+		var wantData struct {
+			Symbol string  `json:"s"`
+			Seq    int64   `json:"seq"`
+			U      int64   `json:"u"`
+			Pu     int64   `json:"pu"`
+			B      []level `json:"b"`
+			A      []level `json:"a"`
+		}
+		json.Unmarshal(want.Data, &wantData)
+
+		var got bybitjson.Book
+		if err := got.Decode([]byte(f)); err != nil {
+			t.Fatalf("%s: %v", f, err)
+		}
+		if string(got.Topic) != want.Topic || string(got.Type) != want.Type || got.Ts != want.Ts ||
+			string(got.Symbol) != wantData.Symbol || got.Seq != wantData.Seq || got.U != wantData.U || got.Pu != wantData.Pu {
+			t.Fatalf("%s: decoded %+v", f, got)
+		}
+		for _, side := range []struct {
+			got  []bybitjson.Level
+			want []level
+		}{{got.Bids, wantData.B}, {got.Asks, wantData.A}} {
+			var pairs []level
+			for _, l := range side.want {
+				if len(l) >= 2 {
+					pairs = append(pairs, l)
+				}
+			}
+			if len(side.got) != len(pairs) {
+				t.Fatalf("%s: %d levels, want %d", f, len(side.got), len(pairs))
+			}
+			for i, l := range side.got {
+				if string(l.Price) != pairs[i][0] || string(l.Size) != pairs[i][1] {
+					t.Fatalf("%s: level %d = %s/%s", f, i, l.Price, l.Size)
+				}
+			}
+		}
+	}
+	var b bybitjson.Book
+	for _, bad := range []string{``, `[]`, `{"topic":"x"`, `{"data":{"b":[["1","2"]}}`} {
+		if b.Decode([]byte(bad)) == nil {
+			t.Fatalf("%q decoded", bad)
+		}
+	}
+}
+
+func TestBybitOrderbookFrameAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocation counts are meaningless under -race")
+	}
+	s := ws.NewBybitStream("", []string{"BTCUSDT"}, 500)
+	depth := make(chan transport.DepthUpdate, 1)
+	feeds := ws.Feeds{Depth: depth}
+	frame := depthFrame(500)
+	ctx := context.Background()
+	handle := func() {
+		if !s.HandleFrame(ctx, feeds, 1, frame) {
+			t.Fatal("frame rejected")
+		}
+		<-depth
+	}
+	handle()
+	var book bybitjson.Book
+	if n := testing.AllocsPerRun(100, func() { book.Decode(frame) }); n != 0 {
+		t.Fatalf("Decode: %v allocs/frame", n)
+	}
+	if n := testing.AllocsPerRun(100, handle); n != 0 {
+		t.Fatalf("HandleFrame: %v allocs/frame", n)
+	}
+}
+
+func BenchmarkBybitOrderbookDecode(b *testing.B) {
+	frame := depthFrame(500)
+	b.Run("encoding_json", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(frame)))
+		for i := 0; i < b.N; i++ {
+			var msg struct {
+				Topic string `json:"topic"`
+				Type  string `json:"type"`
+				Ts    int64  `json:"ts"`
+				Data  struct {
+					Symbol string     `json:"s"`
+					Bids   [][]string `json:"b"`
+					Asks   [][]string `json:"a"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(frame, &msg); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("bybitjson", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(frame)))
+		var msg bybitjson.Book
+		for i := 0; i < b.N; i++ {
+			if err := msg.Decode(frame); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestBybitStreamGapRecovery(t *testing.T) {
+	var mu sync.Mutex
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		queries = append(queries, r.URL.Path+"?"+r.URL.RawQuery)
+		n := len(queries)
+		mu.Unlock()
+		// the first snapshot predates the deltas buffered since the gap
+		u := 3
+		if n > 1 {
+			u = 4
+		}
+		fmt.Fprintf(w, `{"retCode":0,"retMsg":"OK","result":{"s":"BTCUSDT","b":[["100","3"],["99.5","1"]],"a":[["101","1"]],"u":%d}}`, u)
+	}))
+	defer srv.Close()
+
+	stream := ws.NewBybitStream("wss://stream.bybit.com/v5/public/linear", []string{"BTCUSDT"}, 50)
+	stream.REST = srv.URL
+	depth := make(chan transport.DepthUpdate, 8)
+	out := ws.Feeds{Depth: depth}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	frame := func(typ string, u int, bids, asks string) {
+		stream.HandleFrame(ctx, out, 1, []byte(fmt.Sprintf(`{"topic":"orderbook.50.BTCUSDT","type":%q,"ts":1,"data":{"s":"BTCUSDT","b":[%s],"a":[%s],"u":%d}}`, typ, bids, asks, u)))
+	}
+	frame("snapshot", 1, `["100","1"]`, `["101","1"]`)
+	frame("delta", 2, `["100","2"]`, ``)
+	<-depth
+	<-depth
+	// 3 and 4 are lost; 5 and 6 wait for the snapshot
+	frame("delta", 5, ``, `["100.5","2"]`)
+	frame("delta", 6, `["100","0"]`, ``)
+
+	var got transport.DepthUpdate
+	select {
+	case got = <-depth:
+	case <-ctx.Done():
+		t.Fatal("book not recovered")
+	}
+	if got.BestBid != 99.5 || got.BidSize != 1 || got.BestAsk != 100.5 || got.AskSize != 2 || got.Category != "linear" {
+		t.Fatalf("recovered top = %+v", got)
+	}
+	st := stream.Gaps()
+	if st.Gaps != 1 || st.Recovered != 1 || st.Last == nil {
+		t.Fatalf("gaps = %+v", st)
+	}
+	if r := st.Last; r.From != 2 || r.To != 5 || r.SnapshotU != 4 || r.Replayed != 2 || r.Attempts != 2 || r.Err != "" {
+		t.Fatalf("recovery = %+v", r)
+	}
+	mu.Lock()
+	if len(queries) != 2 || queries[0] != "/v5/market/orderbook?category=linear&limit=50&symbol=BTCUSDT" {
+		t.Fatalf("queries = %v", queries)
+	}
+	mu.Unlock()
+
+	// the stream carries on from the recovered update id
+	frame("delta", 7, `["99.8","4"]`, ``)
+	if got := <-depth; got.BestBid != 99.8 || stream.Gaps().Gaps != 1 {
+		t.Fatalf("after recovery: %+v, %+v", got, stream.Gaps())
+	}
+}
+
+func TestBybitStreamBookSnapshots(t *testing.T) {
+	stream := ws.NewBybitStream("wss://stream.bybit.com/v5/public/spot", []string{"BTCUSDT", "ETHUSDT"}, 50)
+	out := ws.Feeds{Depth: make(chan transport.DepthUpdate, 8)}
+	ctx := context.Background()
+	stream.HandleFrame(ctx, out, 1, []byte(`{"topic":"orderbook.50.BTCUSDT","type":"snapshot","ts":1000,"data":{"s":"BTCUSDT","b":[["100","1"],["99","2"],["98","3"]],"a":[["101","1"],["102","2"],["103","3"]],"u":1}}`))
+	stream.HandleFrame(ctx, out, 1, []byte(`{"topic":"orderbook.50.BTCUSDT","type":"delta","ts":1005,"data":{"s":"BTCUSDT","b":[["100","0"],["99.5","4"]],"a":[],"u":2}}`))
+	// one-sided books are left out
+	stream.HandleFrame(ctx, out, 1, []byte(`{"topic":"orderbook.50.ETHUSDT","type":"snapshot","ts":1000,"data":{"s":"ETHUSDT","b":[["10","1"]],"a":[],"u":1}}`))
+
+	r := ws.NewRouterWithConfig(ws.DefaultRouterConfig())
+	r.Add(stream)
+	snaps := r.BookSnapshots(2)
+	if len(snaps) != 1 {
+		t.Fatalf("snapshots = %+v", snaps)
+	}
+	s := snaps[0]
+	want := transport.BookSnapshot{Venue: "BYBIT", Symbol: "BTCUSDT", Category: "spot",
+		Bids: []transport.BookLevel{{Price: 99.5, Size: 4}, {Price: 99, Size: 2}},
+		Asks: []transport.BookLevel{{Price: 101, Size: 1}, {Price: 102, Size: 2}}, ExchTsMs: 1005, TsMs: s.TsMs}
+	if fmt.Sprint(s) != fmt.Sprint(want) || s.TsMs == 0 {
+		t.Fatalf("snapshot = %+v", s)
+	}
+}
+
+func TestBybitStreamBookEvents(t *testing.T) {
+	stream := ws.NewBybitStream("wss://stream.bybit.com/v5/public/linear", []string{"BTCUSDT"}, 50)
+	stream.BookEvents = true
+	events := make(chan transport.BookEvent, 16)
+	out := ws.Feeds{Depth: make(chan transport.DepthUpdate, 8), BookEvents: events}
+	ctx := context.Background()
+	frame := func(typ string, u int, bids, asks string) {
+		stream.HandleFrame(ctx, out, 7, []byte(fmt.Sprintf(`{"topic":"orderbook.50.BTCUSDT","type":%q,"ts":%d,"data":{"s":"BTCUSDT","b":[%s],"a":[%s],"u":%d}}`, typ, 1000+u, bids, asks, u)))
+	}
+	got := func() []string {
+		var out []string
+		for len(events) > 0 {
+			e := <-events
+			if e.Venue != "BYBIT" || e.Symbol != "BTCUSDT" || e.Category != "linear" || e.RecvNs != 7 || e.ExchTsMs < 1000 {
+				t.Fatalf("event %+v", e)
+			}
+			out = append(out, fmt.Sprintf("%s %s %g %g<-%g/%g", e.Kind, e.Side, e.Price, e.Size, e.PrevPrice, e.PrevSize))
+		}
+		return out
+	}
+
+	// a snapshot rebuilds the book without level events
+	frame("snapshot", 1, `["100","1"],["99","2"]`, `["101","1"],["102","2"]`)
+	if ev := got(); len(ev) != 0 {
+		t.Fatalf("snapshot events = %v", ev)
+	}
+	frame("delta", 2, `["100.5","3"],["99","5"]`, `["101","0"],["103","0"]`)
+	want := []string{
+		"level_added bid 100.5 3<-0/0",
+		"size_changed bid 99 5<-0/2",
+		"level_removed ask 101 0<-0/1",
+		"bbo_improved bid 100.5 3<-100/0",
+		"bbo_worsened ask 102 2<-101/0",
+	}
+	if ev := got(); fmt.Sprint(ev) != fmt.Sprint(want) {
+		t.Fatalf("delta events = %v, want %v", ev, want)
+	}
+	// a new snapshot still reports where the top moved
+	frame("snapshot", 3, `["99","1"]`, `["102","1"]`)
+	if ev := got(); fmt.Sprint(ev) != "[bbo_worsened bid 99 1<-100.5/0]" {
+		t.Fatalf("resnapshot events = %v", ev)
+	}
+}
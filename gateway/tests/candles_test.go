@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/candles"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+func TestCandleAggregation(t *testing.T) {
+	if _, err := candles.ParseIntervals("1s,1500us"); err == nil {
+		t.Fatal("sub-millisecond interval accepted")
+	}
+	intervals, err := candles.ParseIntervals("1s, 1m")
+	if err != nil || len(intervals) != 2 {
+		t.Fatalf("intervals = %v, %v", intervals, err)
+	}
+	agg := candles.New(intervals...)
+	trade := func(ms int64, px, qty float64) []transport.Bar {
+		return agg.Trade(transport.Trade{Venue: "BYBIT", Symbol: "BTCUSDT", Side: "BUY", Price: px, Qty: qty, TsMs: ms})
+	}
+
+	if done := trade(60_000, 100, 1); len(done) != 0 {
+		t.Fatalf("first trade completed %+v", done)
+	}
+	trade(60_400, 103, 1)
+	trade(60_999, 98, 2)
+	done := trade(61_200, 101, 1)
+	if len(done) != 1 {
+		t.Fatalf("completed = %+v, want the first 1s bar", done)
+	}
+	b := done[0]
+	if b.StartMs != 60_000 || b.Interval != time.Second || b.Open != 100 || b.High != 103 || b.Low != 98 || b.Close != 98 ||
+		b.Volume != 4 || b.Trades != 3 || b.VWAP != (100+103+196)/4.0 {
+		t.Fatalf("bar = %+v", b)
+	}
+	if late := trade(60_500, 50, 1); len(late) != 0 {
+		t.Fatalf("late trade completed %+v", late)
+	}
+
+	// the live clock completes the open 1s bar; a late trade for it is ignored
+	if adv := agg.Advance(time.UnixMilli(62_000)); len(adv) != 1 || adv[0].StartMs != 61_000 || adv[0].Trades != 1 {
+		t.Fatalf("advance = %+v", adv)
+	}
+	trade(61_900, 1, 1)
+
+	// both late trades still fall in the open minute bar
+	done = trade(125_000, 110, 1)
+	if len(done) != 1 || done[0].Interval != time.Minute || done[0].Trades != 6 || done[0].Low != 1 {
+		t.Fatalf("minute bar = %+v", done)
+	}
+	rest := agg.Flush()
+	if len(rest) != 2 || rest[0].Interval != time.Minute || rest[0].StartMs != 120_000 || rest[1].Interval != time.Second {
+		t.Fatalf("flush = %+v", rest)
+	}
+	if again := agg.Flush(); len(again) != 0 {
+		t.Fatalf("second flush = %+v", again)
+	}
+
+	var buf bytes.Buffer
+	w := candles.NewCSVWriter(&buf)
+	for _, b := range rest {
+		if err := w.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || lines[2] != "125000,1s,BYBIT,BTCUSDT,110,110,110,110,1,110,1" {
+		t.Fatalf("csv:\n%s", buf.String())
+	}
+}
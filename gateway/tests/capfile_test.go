@@ -0,0 +1,269 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/capfile"
+	"github.com/helix-lab/helix/gateway/pkg/catalog"
+	"github.com/helix-lab/helix/gateway/pkg/l2book"
+	"github.com/helix-lab/helix/gateway/pkg/parquet"
+)
+
+func TestMergeCaptures(t *testing.T) {
+	dir := t.TempDir()
+	// a lost seq 3, b lost seq 4 and joined late
+	a := writeCapture(t, dir, "a.csv", `ts_ms,seq,prev_seq,book_side,price,size,type
+1000,1,0,bid,100,1,snapshot
+1000,1,0,ask,101,1,snapshot
+2000,2,1,bid,100,2,delta
+4000,4,2,ask,101,3,delta
+5000,5,4,bid,99,1,delta
+`, "BTCUSDT")
+	b := writeCapture(t, dir, "b.csv", `ts_ms,seq,prev_seq,book_side,price,size,type
+2000,2,1,bid,100,2,delta
+3000,3,2,ask,101,2,delta
+5000,5,3,bid,99,1,delta
+6000,6,5,ask,102,1,delta
+`, "BTCUSDT")
+
+	merged, st, err := capfile.Merge([]string{a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Kind != catalog.KindL2 || st.Gaps != 0 || st.Sources[0].Filled != 1 || st.Sources[1].Filled != 1 || st.Duplicates != 2 {
+		t.Fatalf("stats = %+v", st)
+	}
+	var seqs []string
+	for _, row := range merged.Rows {
+		seqs = append(seqs, merged.Get(row, "seq")+"/"+merged.Get(row, "prev_seq"))
+	}
+	if got := strings.Join(seqs, " "); got != "1/0 1/0 2/1 3/2 4/3 5/4 6/5" {
+		t.Fatalf("merged seq/prev = %s", got)
+	}
+	out := filepath.Join(dir, "merged.csv")
+	if err := merged.WriteFile(out); err != nil {
+		t.Fatal(err)
+	}
+	if err := capfile.WriteMeta(a, out, map[string]any{"merged_from": []string{a, b}}); err != nil {
+		t.Fatal(err)
+	}
+	if ds, ok, err := catalog.Inspect(out); err != nil || !ok || ds.Symbol != "BTCUSDT" || ds.StartMs != 1000 || ds.EndMs != 6000 {
+		t.Fatalf("merged dataset = %+v %t %v", ds, ok, err)
+	}
+	if v, err := catalog.Validate(out, 0); err != nil || v.Status != catalog.StatusOK {
+		t.Fatalf("merged capture validation = %+v %v", v, err)
+	}
+
+	// the faster recorder wins duplicates, whatever the argument order
+	slow := writeCapture(t, dir, "slow.csv", `ts_ms,side,price,size,exec_id,seq,recv_ts_ms
+1000,Buy,100,1,e1,1,1050
+2000,Sell,101,1,e2,2,2040
+`, "BTCUSDT")
+	fast := writeCapture(t, dir, "fast.csv", `ts_ms,side,price,size,exec_id,seq,recv_ts_ms
+2000,Sell,101,1,e2,2,2005
+3000,Buy,102,1,e3,3,3005
+`, "BTCUSDT")
+	merged, st, err = capfile.Merge([]string{slow, fast})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Sources[0].Path != fast || st.Sources[0].LatencyMs != 5 || st.Duplicates != 1 || len(merged.Rows) != 3 {
+		t.Fatalf("trades stats = %+v", st)
+	}
+	if got := merged.Get(merged.Rows[1], "recv_ts_ms"); got != "2005" {
+		t.Fatalf("e2 came from the slow recorder (recv %s)", got)
+	}
+
+	other := writeCapture(t, dir, "eth.csv", "ts_ms,side,price,size,trade_id\n1,Buy,1,1,x\n", "ETHUSDT")
+	if _, _, err := capfile.Merge([]string{slow, other}); err == nil {
+		t.Fatal("merged captures of different symbols")
+	}
+}
+
+func TestConvertToParquet(t *testing.T) {
+	dir := t.TempDir()
+	src := writeCapture(t, dir, "l2.csv", btL2, "BTCUSDT")
+	csvRows, err := capfile.Read(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, codec := range []parquet.Codec{parquet.Uncompressed, parquet.Gzip} {
+		dst := filepath.Join(dir, "l2.parquet")
+		rows, err := capfile.ToParquet(src, dst, codec, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := parquet.ReadFile(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rows != 8 || len(f.Rows) != 8 || len(f.Columns) != 7 {
+			t.Fatalf("codec %d: %d rows converted, read %d rows of %+v", codec, rows, len(f.Rows), f.Columns)
+		}
+		if f.Columns[0] != (parquet.Column{Name: "ts_ms", Type: parquet.Int64}) || f.Columns[3].Type != parquet.String || f.Columns[4].Type != parquet.Double {
+			t.Fatalf("schema = %+v", f.Columns)
+		}
+		for i := range f.Rows {
+			if strings.Join(f.Rows[i], ",") != strings.Join(csvRows.Rows[i], ",") {
+				t.Fatalf("row %d = %v, csv %v", i, f.Rows[i], csvRows.Rows[i])
+			}
+		}
+		if !strings.Contains(f.Metadata[capfile.MetaKey], `"BTCUSDT"`) {
+			t.Fatalf("footer metadata = %v", f.Metadata)
+		}
+	}
+	// the shared sidecar gains the Parquet file's checksum
+	if err := catalog.Verified(filepath.Join(dir, "l2.parquet")); err != nil {
+		t.Fatal(err)
+	}
+
+	// elsewhere the sidecar is copied
+	trades := writeCapture(t, dir, "trades.csv", btTrades, "BTCUSDT")
+	out := t.TempDir()
+	if _, err := capfile.ToParquet(trades, filepath.Join(out, "trades.parquet"), parquet.Gzip, 0); err != nil {
+		t.Fatal(err)
+	}
+	meta, err := os.ReadFile(filepath.Join(out, "trades.meta.json"))
+	if err != nil || !strings.Contains(string(meta), `"converted_from"`) || !strings.Contains(string(meta), `"symbol": "BTCUSDT"`) {
+		t.Fatalf("sidecar = %s %v", meta, err)
+	}
+	bad := writeCapture(t, dir, "bad.csv", "ts_ms,side,price,size\nnope,Buy,1,1\n", "")
+	if _, err := capfile.ToParquet(bad, filepath.Join(out, "bad.parquet"), parquet.Gzip, 0); err == nil {
+		t.Fatal("converted a non-numeric ts_ms")
+	}
+	if _, err := os.Stat(filepath.Join(out, "bad.parquet")); !os.IsNotExist(err) {
+		t.Fatalf("partial output left behind: %v", err)
+	}
+}
+
+func TestDownsample(t *testing.T) {
+	var out strings.Builder
+	st, err := l2book.Downsample(strings.NewReader(btL2), &out, 500*time.Millisecond, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// seq 3 leaves the book crossed, so 3000 is skipped
+	want := `ts_ms,seq,best_bid,best_ask,bid_size,ask_size
+1000,1,100,101,1,1
+1500,1,100,101,1,1
+2000,2,100,102,1,3
+2500,2,100,102,1,3
+`
+	if out.String() != want {
+		t.Fatalf("sampled:\n%s", out.String())
+	}
+	if st.Deltas != 8 || st.Messages != 3 || st.Samples != 4 || st.Skipped != 1 {
+		t.Fatalf("stats = %+v", st)
+	}
+
+	out.Reset()
+	if _, err := l2book.Downsample(strings.NewReader(btL2), &out, time.Second, 3); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(out.String(), "\n")
+	if lines[0] != "ts_ms,seq,bid_px_1,bid_sz_1,ask_px_1,ask_sz_1,bid_px_2,bid_sz_2,ask_px_2,ask_sz_2,bid_px_3,bid_sz_3,ask_px_3,ask_sz_3" ||
+		lines[1] != "1000,1,100,1,101,1,99,2,102,3,,,," {
+		t.Fatalf("top-3 sampled:\n%s", out.String())
+	}
+
+	gap := strings.Replace(btL2, "3000,3,2,", "3000,3,1,", -1)
+	if _, err := l2book.Downsample(strings.NewReader(gap), &out, time.Second, 1); err == nil {
+		t.Fatal("expected seq gap error")
+	}
+}
+
+func TestSliceCapture(t *testing.T) {
+	l2, err := capfile.ReadFrom(strings.NewReader(btL2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := func(tb *capfile.Table) string {
+		var b strings.Builder
+		if err := tb.Write(&b); err != nil {
+			t.Fatal(err)
+		}
+		return strings.SplitN(b.String(), "\n", 2)[1]
+	}
+
+	s, st, err := capfile.Slice(l2, capfile.Window{FromMs: 2500}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rows(s); got != `2500,2,1,bid,100,1,snapshot
+2500,2,1,bid,99,2,snapshot
+2500,2,1,ask,102,3,snapshot
+3000,3,2,bid,100,0,delta
+3000,3,2,bid,103,1,delta
+3000,3,2,ask,104,1,delta
+` || !st.Synthesized || st.Replayed != 5 {
+		t.Fatalf("synthesized slice %+v:\n%s", st, got)
+	}
+
+	s, st, err = capfile.Slice(l2, capfile.Window{FromMs: 2500}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Synthesized || st.Replayed != 5 || st.Rows != 8 || s.Get(s.Rows[0], "type") != "snapshot" {
+		t.Fatalf("slice from snapshot = %+v", st)
+	}
+
+	s, _, err = capfile.Slice(l2, capfile.Window{FromSeq: 2, ToSeq: 2}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rows(s); !strings.HasPrefix(got, "1000,1,0,bid,100,1,snapshot\n") || !strings.HasSuffix(got, "\n2000,2,1,ask,101,0,delta\n") || len(s.Rows) != 5 {
+		t.Fatalf("seq slice:\n%s", got)
+	}
+
+	if _, _, err := capfile.Slice(l2, capfile.Window{FromMs: 5000}, false); err == nil {
+		t.Fatal("expected empty window error")
+	}
+
+	trades, err := capfile.ReadFrom(strings.NewReader(btTrades))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _, err = capfile.Slice(trades, capfile.Window{FromMs: 2000}, false); err != nil || len(s.Rows) != 1 || s.Get(s.Rows[0], "trade_id") != "t2" {
+		t.Fatalf("trades slice = %v %v", s, err)
+	}
+}
+
+func TestCrossCheckDepths(t *testing.T) {
+	shallow := `ts_ms,seq,prev_seq,book_side,price,size,type
+1000,10,9,bid,100,1,snapshot
+1000,10,9,ask,101,1,snapshot
+1010,11,10,bid,100,2,delta
+1020,12,11,ask,101,0,delta
+1020,12,11,ask,102,4,delta
+1030,13,12,bid,100,5,delta
+`
+	deep := `ts_ms,seq,prev_seq,book_side,price,size,type
+1000,500,499,bid,100,1,snapshot
+1000,500,499,bid,99,3,snapshot
+1000,500,499,ask,101,1,snapshot
+1000,500,499,ask,102,4,snapshot
+1020,501,500,bid,100,2,delta
+1020,501,500,ask,101,0,delta
+1030,502,501,bid,100,4,delta
+`
+	s, err := l2book.Tops(strings.NewReader(shallow))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := l2book.Tops(strings.NewReader(deep))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s) != 4 || len(d) != 3 {
+		t.Fatalf("tops = %d shallow, %d deep", len(s), len(d))
+	}
+	// 1010 has no deep message; 1030 disagrees on the bid size
+	st := l2book.CrossCheck(s, d, 1e-9)
+	if st.Aligned != 3 || st.Matched != 2 || len(st.Mismatches) != 1 || st.Mismatches[0].Shallow.TsMs != 1030 {
+		t.Fatalf("cross check = %+v", st)
+	}
+}
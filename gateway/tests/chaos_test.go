@@ -0,0 +1,129 @@
+package tests
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/chaos"
+	"github.com/helix-lab/helix/gateway/pkg/clock"
+	"github.com/helix-lab/helix/gateway/pkg/config"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/ws"
+)
+
+func TestChaosInjector(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "scenario.json")
+	os.WriteFile(path, []byte(`{"seed": 7, "faults": [{"kind": "storm", "every": "1s"}, {"kind": "disconnect", "every": "1s", "for": "1s"}]}`), 0o644)
+	sc, err := chaos.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.Faults[0].Count != 1000 {
+		t.Fatalf("storm count = %d, want the default", sc.Faults[0].Count)
+	}
+	for _, bad := range []string{`{"faults": []}`, `{"faults": [{"kind": "meteor", "every": "1s"}]}`,
+		`{"faults": [{"kind": "disconnect", "every": "1s"}]}`, `{"faults": [{"kind": "storm", "every": "1s", "typo": 1}]}`} {
+		os.WriteFile(path, []byte(bad), 0o644)
+		if _, err := chaos.Load(path); err == nil {
+			t.Fatalf("accepted %s", bad)
+		}
+	}
+
+	inj := chaos.New(chaos.Scenario{})
+	books := orderbook.NewManager()
+	inj.Books = books
+	cfg := ws.DefaultSimConfig("SIM")
+	cfg.Interval = 10 * time.Millisecond
+	r := ws.NewRouter()
+	r.Add(inj.Wrap(ws.NewSimConnector(cfg)))
+	r.Start()
+	defer r.Stop()
+	if subs := r.Subscriptions()["SIM"]; len(subs) != 1 {
+		t.Fatalf("subscriptions through the wrapper = %v", r.Subscriptions())
+	}
+	next := func(within time.Duration) bool {
+		select {
+		case u := <-r.Updates():
+			books.Apply(u)
+			return true
+		case <-time.After(within):
+			return false
+		}
+	}
+	if !next(time.Second) {
+		t.Fatal("no updates")
+	}
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(1))
+
+	// a storm arrives far faster than the feed's own pace
+	go inj.Inject(ctx, chaos.Fault{Kind: chaos.KindStorm, Count: 200}, rand.New(rand.NewSource(2)))
+	start := time.Now()
+	for i := 0; i < 150; i++ {
+		if !next(time.Second) {
+			t.Fatalf("storm stalled after %d updates", i)
+		}
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Fatalf("150 updates took %v", took)
+	}
+	for n := 0; inj.Injected()[chaos.KindStorm] == 0; n++ {
+		if !next(time.Second) {
+			t.Fatalf("storm stalled after %d more updates", n)
+		}
+	}
+
+	// a disconnect silences the venue, which comes back after it
+	inj.Inject(ctx, chaos.Fault{Kind: chaos.KindDisconnect, For: config.Duration(300 * time.Millisecond)}, rng)
+	for next(50 * time.Millisecond) {
+	}
+	if next(100 * time.Millisecond) {
+		t.Fatal("updates during the disconnect")
+	}
+	if !next(2 * time.Second) {
+		t.Fatal("no updates after the disconnect")
+	}
+
+	// the sim parses no frames
+	inj.Inject(ctx, chaos.Fault{Kind: chaos.KindMalformed, Count: 1}, rng)
+
+	n := inj.Injected()
+	if n[chaos.KindStorm] != 1 || n[chaos.KindDisconnect] != 1 || n[chaos.KindMalformed] != 0 {
+		t.Fatalf("injected = %v", n)
+	}
+
+	// consumer and transport faults on a virtual clock
+	vclock := clock.NewVirtual(time.Unix(0, 0))
+	inj = chaos.New(chaos.Scenario{})
+	inj.Clock = vclock
+	inj.Stall()
+	inj.Inject(ctx, chaos.Fault{Kind: chaos.KindSlowConsumer, For: config.Duration(time.Second), Delay: config.Duration(5 * time.Millisecond)}, rng)
+	stalled := make(chan struct{})
+	go func() {
+		defer close(stalled)
+		inj.Stall()
+	}()
+	vclock.BlockUntil(1)
+	vclock.Advance(5 * time.Millisecond)
+	<-stalled
+	if inj.Publish("depth") != nil {
+		t.Fatal("transport failing before the fault")
+	}
+	inj.Inject(ctx, chaos.Fault{Kind: chaos.KindTransport, For: config.Duration(time.Second)}, rng)
+	if inj.Publish("depth") == nil {
+		t.Fatal("transport not failing")
+	}
+	vclock.Advance(2 * time.Second)
+	if inj.Publish("depth") != nil {
+		t.Fatal("transport still failing after the fault")
+	}
+	inj.Stall() // over, returns at once
+	if n := inj.Injected(); n[chaos.KindSlowConsumer] != 1 || n[chaos.KindTransport] != 1 {
+		t.Fatalf("injected = %v", n)
+	}
+}
@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/clickhouse"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+type chRequest struct {
+	query, body string
+}
+
+func clickhouseServer(t *testing.T, fail func(query string) bool) (*httptest.Server, func() []chRequest) {
+	var mu sync.Mutex
+	var reqs []chRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		reqs = append(reqs, chRequest{query: r.URL.Query().Get("query"), body: string(body)})
+		mu.Unlock()
+		if fail != nil && fail(r.URL.Query().Get("query")) {
+			http.Error(w, "Code: 60. Table does not exist", http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []chRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]chRequest(nil), reqs...)
+	}
+}
+
+func TestClickHouseMigrate(t *testing.T) {
+	srv, requests := clickhouseServer(t, nil)
+	sink := clickhouse.New(clickhouse.Config{URL: srv.URL, Database: "md", TTL: 48 * time.Hour})
+	if err := sink.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	reqs := requests()
+	if len(reqs) != 5 || reqs[0].body != "CREATE DATABASE IF NOT EXISTS md" {
+		t.Fatalf("statements = %+v", reqs)
+	}
+	// older bbo tables gain the category column
+	if alter := reqs[4].body; alter != "ALTER TABLE md.helix_bbo ADD COLUMN IF NOT EXISTS category LowCardinality(String) AFTER symbol" {
+		t.Fatalf("statement = %s", alter)
+	}
+	for _, r := range reqs[1:4] {
+		if !strings.HasPrefix(r.body, "CREATE TABLE IF NOT EXISTS md.helix_") || !strings.Contains(r.body, "INTERVAL 172800 SECOND") {
+			t.Fatalf("statement = %s", r.body)
+		}
+	}
+
+	bad, _ := clickhouseServer(t, func(string) bool { return true })
+	if err := clickhouse.New(clickhouse.Config{URL: bad.URL}).Migrate(context.Background()); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("err = %v, want the server's status", err)
+	}
+}
+
+func TestClickHouseBatches(t *testing.T) {
+	srv, requests := clickhouseServer(t, func(q string) bool { return strings.Contains(q, "helix_trades") })
+	sink := clickhouse.New(clickhouse.Config{URL: srv.URL, Database: "md", BatchSize: 2, FlushEvery: time.Hour})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sink.Run()
+	}()
+
+	u := transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", Category: "linear", BestBid: 100, BestAsk: 101, BidSize: 1, AskSize: 2, RecvNs: 1_700_000_000_123_456_789}
+	sink.Depth(u)
+	sink.BBO(u, orderbook.Level{BestBid: 100.5, BestAsk: 101, BidSize: 3, AskSize: 2})
+	// the batch of two goes out without waiting for the flush interval
+	deadline := time.Now().Add(2 * time.Second)
+	for len(requests()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	sink.Trade(transport.Trade{Venue: "BYBIT", Symbol: "BTCUSDT", Side: "BUY", Price: 101, Qty: 0.5, TsMs: 1_700_000_000_500})
+	sink.Close()
+	<-done
+	sink.Depth(u) // after Close: dropped, not a panic
+
+	byQuery := map[string]string{}
+	for _, r := range requests() {
+		byQuery[r.query] = r.body
+	}
+	depth := byQuery["INSERT INTO md.helix_depth FORMAT JSONEachRow"]
+	if !strings.Contains(depth, `"ts":"2023-11-14 22:13:20.123456789"`) || !strings.Contains(depth, `"venue":"BYBIT"`) {
+		t.Fatalf("depth insert = %q", depth)
+	}
+	if bbo := byQuery["INSERT INTO md.helix_bbo FORMAT JSONEachRow"]; !strings.Contains(bbo, `"venue":"NBBO"`) || !strings.Contains(bbo, `"bid":100.5`) ||
+		!strings.Contains(bbo, `"category":"linear"`) {
+		t.Fatalf("bbo insert = %q", bbo)
+	}
+	// flushed on Close even though the batch was not full
+	if trades := byQuery["INSERT INTO md.helix_trades FORMAT JSONEachRow"]; !strings.Contains(trades, `"side":"BUY"`) {
+		t.Fatalf("trades insert = %q", trades)
+	}
+}
@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/internal/app/replay"
+	"github.com/helix-lab/helix/gateway/pkg/capture"
+	"github.com/helix-lab/helix/gateway/pkg/clock"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+	"github.com/helix-lab/helix/gateway/pkg/ws"
+)
+
+func TestClockMonitorEstimatesOffsetAndDrift(t *testing.T) {
+	var skew atomic.Int64
+	skew.Store(int64(2 * time.Second))
+	bybit := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now().Add(time.Duration(skew.Load()))
+		fmt.Fprintf(w, `{"retCode":0,"retMsg":"OK","result":{"timeSecond":"%d","timeNano":"%d"},"time":%d}`,
+			now.Unix(), now.UnixNano(), now.UnixMilli())
+	}))
+	defer bybit.Close()
+	binance := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"serverTime":%d}`, time.Now().UnixMilli())
+	}))
+	defer binance.Close()
+
+	bt := ws.NewBybitTime()
+	bt.URL = bybit.URL
+	nt := ws.NewBinanceTime()
+	nt.URL = binance.URL
+	mon := ws.NewClockMonitor(time.Minute, bt, nt)
+	mon.MaxSkew = 500 * time.Millisecond
+
+	if _, ok := mon.Estimate("BYBIT"); ok {
+		t.Fatal("no estimate before the first poll")
+	}
+	for i := 0; i < 3; i++ {
+		mon.Poll(context.Background())
+	}
+	by, ok := mon.Estimate("BYBIT")
+	if !ok || by.Samples != 3 {
+		t.Fatalf("expected 3 BYBIT samples, got %+v", by)
+	}
+	if d := by.Offset - 2*time.Second; d < -50*time.Millisecond || d > 50*time.Millisecond {
+		t.Fatalf("BYBIT offset=%s, want ~2s", by.Offset)
+	}
+	if !by.Drift {
+		t.Fatal("2s skew should flag drift")
+	}
+	bn, _ := mon.Estimate("BINANCE")
+	if bn.Drift || bn.Offset < -50*time.Millisecond || bn.Offset > 50*time.Millisecond {
+		t.Fatalf("BINANCE should be in sync, got %+v", bn)
+	}
+
+	skew.Store(0)
+	binance.Close()
+	for i := 0; i < 8; i++ {
+		mon.Poll(context.Background())
+	}
+	if by, _ = mon.Estimate("BYBIT"); by.Drift {
+		t.Fatalf("drift should clear once old samples leave the window: %+v", by)
+	}
+	if bn, _ = mon.Estimate("BINANCE"); bn.Err == "" || bn.Samples == 0 {
+		t.Fatalf("failed poll should keep the last estimate and record the error: %+v", bn)
+	}
+}
+
+func TestVirtualClockDrivesReplay(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	vclock := clock.NewVirtual(start)
+	tick := vclock.NewTicker(time.Second)
+	after := vclock.After(1500 * time.Millisecond)
+	vclock.Advance(time.Second)
+	if at := <-tick.C(); !at.Equal(start.Add(time.Second)) {
+		t.Fatalf("tick at %v", at)
+	}
+	select {
+	case <-after:
+		t.Fatal("timer fired early")
+	default:
+	}
+	vclock.Advance(2 * time.Second)
+	if at := <-after; !at.Equal(start.Add(1500 * time.Millisecond)) {
+		t.Fatalf("timer at %v", at)
+	}
+	// a slow reader misses ticks rather than queueing them
+	if at := <-tick.C(); !at.Equal(start.Add(2 * time.Second)) {
+		t.Fatalf("tick at %v", at)
+	}
+	tick.Stop()
+	if n := vclock.Waiters(); n != 0 {
+		t.Fatalf("waiters = %d after stop", n)
+	}
+
+	// frames an hour apart replay at speed 1 without an hour passing
+	path := filepath.Join(t.TempDir(), "tap.jsonl")
+	fl, err := capture.OpenFrameLog(path, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tap := fl.Tap("BYBIT")
+	frames := []string{
+		`{"topic":"orderbook.1.BTCUSDT","type":"snapshot","ts":1,"data":{"s":"BTCUSDT","b":[["100","1"]],"a":[["101","2"]]}}`,
+		`{"topic":"orderbook.1.BTCUSDT","type":"delta","ts":2,"data":{"s":"BTCUSDT","b":[["100.5","3"]],"a":[]}}`,
+		`{"topic":"orderbook.1.BTCUSDT","type":"delta","ts":3,"data":{"s":"BTCUSDT","b":[],"a":[["100.8","1"]]}}`,
+	}
+	for i, f := range frames {
+		tap(start.Add(time.Duration(i)*time.Hour).UnixNano(), []byte(f))
+	}
+	if err := fl.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var depth atomic.Int32
+	done := make(chan error, 1)
+	go func() {
+		_, err := replay.RunClock(context.Background(), vclock, path, 1, func(transport.DepthUpdate) { depth.Add(1) })
+		done <- err
+	}()
+	for i := 1; i < len(frames); i++ {
+		vclock.BlockUntil(1)
+		if n := depth.Load(); n != int32(i) {
+			t.Fatalf("%d updates before advancing to frame %d", n, i)
+		}
+		vclock.Advance(time.Hour)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if n := depth.Load(); n != 3 {
+		t.Fatalf("depth updates = %d", n)
+	}
+}
@@ -0,0 +1,190 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/helix-lab/helix/gateway/pkg/config"
+)
+
+func TestConfigLoadsRepoGatewayYAML(t *testing.T) {
+	cfg, err := config.Load("../../config/gateway.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := cfg.Gateway
+	if len(g.Venues) != 2 || g.Venues[0].Name != "BYBIT" || g.Venues[1].Kind != config.KindSim {
+		t.Fatalf("unexpected venues: %+v", g.Venues)
+	}
+	by := g.Venues[0]
+	if by.Fees.TakerBps != 6 || by.SymbolDepth("BTCUSDT") != 200 || by.Depth != 50 {
+		t.Fatalf("venue settings not decoded: %+v", by)
+	}
+	if got := g.Venues[1].Symbols; len(got) != 2 || got[0] != "BTCUSDT" || got[1] != "ETHUSDT" {
+		t.Fatalf("venue should inherit gateway symbols, got %v", got)
+	}
+	if g.Risk.MaxNotional != 250000 || g.Router.MaxBacklog != 1024 {
+		t.Fatalf("risk/router not decoded: %+v %+v", g.Risk, g.Router)
+	}
+}
+
+func TestConfigParsesYAMLSubset(t *testing.T) {
+	doc := `
+# comment
+gateway:
+  symbols: [BTCUSDT, "ETHUSDT"]
+  publish_endpoint: 'tcp://*:7001'  # trailing comment
+  venues:
+  - name: bybit
+    kind: bybit
+    ws_public: "wss://example/#frag"
+    liquidations: true
+    symbols:
+      - SOLUSDT
+`
+	cfg, err := config.Parse([]byte(doc), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := cfg.Gateway
+	v := g.Venues[0]
+	if g.PublishEndpoint != "tcp://*:7001" || len(g.Symbols) != 2 || g.Symbols[1] != "ETHUSDT" {
+		t.Fatalf("scalars/flow lists: %+v", g)
+	}
+	if v.Name != "BYBIT" || !v.Liquidations || v.WSPublic != "wss://example/#frag" || v.Symbols[0] != "SOLUSDT" {
+		t.Fatalf("venue: %+v", v)
+	}
+}
+
+func TestConfigValidationErrors(t *testing.T) {
+	doc := `
+gateway:
+  router: {backpressure: sometimes}
+  venues:
+    - name: BYBIT
+      kind: bybit
+      ws_public: https://api.bybit.com
+      credentials: sk-live-123
+      symbol_settings:
+        ETHUSDT: {depth: 5}
+    - name: BYBIT
+      kind: carrier-pigeon
+      symbols: [btcusdt]
+  clients:
+    - name: alpha
+      risk: {max_position: 1, max_orders_per_sec: -1}
+`
+	_, err := config.Parse([]byte(doc), false)
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{
+		"gateway.router.backpressure",
+		"gateway.venues[0].ws_public",
+		"gateway.venues[0].symbols: no symbols",
+		"gateway.venues[0].credentials",
+		"gateway.venues[0].symbol_settings.ETHUSDT",
+		"gateway.venues[1].name: duplicate",
+		"gateway.venues[1].kind",
+		"gateway.venues[1].symbols[0]",
+		"gateway.clients[0].token_env",
+		"gateway.clients[0].risk",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing %q in:\n%v", want, err)
+		}
+	}
+
+	if _, err := config.Parse([]byte("gateway:\n  symbolz: [X]\n"), false); err == nil || !strings.Contains(err.Error(), "symbolz") {
+		t.Fatalf("unknown keys must be rejected, got %v", err)
+	}
+	if _, err := config.Parse([]byte("gateway:\n  symbols:\n   - A\n  - B\n"), false); err == nil || !strings.Contains(err.Error(), "line 4") {
+		t.Fatalf("bad indentation should report its line, got %v", err)
+	}
+}
+
+func TestConfigCategories(t *testing.T) {
+	doc := `
+gateway:
+  symbols: [BTCUSDT]
+  venues:
+  - name: BYBIT
+    kind: bybit
+  - name: BYBIT_SPOT
+    kind: bybit
+    category: spot
+  - name: SIM
+    ws_public: wss://stream.bybit.com/v5/public/inverse
+  risk: {max_order_size: 2}
+`
+	cfg, err := config.Parse([]byte(doc), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := cfg.Gateway.Venues
+	if v[0].Category != config.CategoryLinear || v[0].WSPublic != "wss://stream.bybit.com/v5/public/linear" {
+		t.Fatalf("default category: %+v", v[0])
+	}
+	if v[1].WSPublic != "wss://stream.bybit.com/v5/public/spot" || v[2].Category != config.CategoryInverse {
+		t.Fatalf("categories: %+v %+v", v[1], v[2])
+	}
+	if got := cfg.Gateway.MaxOrderSize("BYBIT", "BTCUSDT"); got != 2 {
+		t.Fatalf("MaxOrderSize = %g", got)
+	}
+
+	bad := `
+gateway:
+  symbols: [BTCUSDT]
+  venues:
+  - name: BYBIT
+    kind: bybit
+    category: spot
+    ws_public: wss://stream.bybit.com/v5/public/linear
+  - name: SIM
+    category: futures
+`
+	_, err = config.Parse([]byte(bad), false)
+	if err == nil || !strings.Contains(err.Error(), "venues[0].ws_public") || !strings.Contains(err.Error(), "venues[1].category") {
+		t.Fatalf("want category errors, got %v", err)
+	}
+}
+
+func TestConfigTestnetProfile(t *testing.T) {
+	cfg, err := config.Load("../../config/gateway-testnet.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := cfg.Gateway
+	if !g.Testnet || len(g.Venues) != 2 {
+		t.Fatalf("profile = %+v", g)
+	}
+	for _, v := range g.Venues {
+		if v.WSPublic != config.BybitTestnetWS+v.Category || v.REST != config.BybitTestnetREST {
+			t.Fatalf("%s endpoints %s %s", v.Name, v.WSPublic, v.REST)
+		}
+	}
+
+	// a testnet profile refuses production endpoints, but takes local ones
+	doc := `
+gateway:
+  testnet: true
+  symbols: [BTCUSDT]
+  venues:
+    - name: BYBIT
+      kind: bybit
+      ws_public: wss://stream.bybit.com/v5/public/linear
+    - name: LOCAL
+      kind: bybit
+      ws_public: ws://127.0.0.1:9000/v5/public/linear
+      rest: http://localhost:9001
+`
+	_, err = config.Parse([]byte(doc), false)
+	if err == nil {
+		t.Fatal("testnet profile took a production endpoint")
+	}
+	// rest was left to default to the testnet
+	if msg := err.Error(); !strings.Contains(msg, "venues[0].ws_public") || strings.Contains(msg, "venues[0].rest") ||
+		strings.Contains(msg, "venues[1]") {
+		t.Fatalf("err = %v", msg)
+	}
+}
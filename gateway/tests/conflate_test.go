@@ -0,0 +1,50 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+func TestDepthConflation(t *testing.T) {
+	var got []transport.DepthUpdate
+	c := transport.NewConflater(func(u transport.DepthUpdate) { got = append(got, u) })
+
+	for i := 0; i < 500; i++ {
+		c.Depth(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: float64(i)})
+	}
+	c.Depth(transport.DepthUpdate{Venue: "OKX", Symbol: "BTCUSDT", BestBid: 1})
+	c.Depth(transport.DepthUpdate{Venue: "BYBIT", Symbol: "ETHUSDT", BestBid: 2})
+	c.Depth(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 1000})
+
+	if n := c.Flush(); n != 3 {
+		t.Fatalf("flushed %d updates, want 3", n)
+	}
+	want := []transport.DepthUpdate{
+		{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 1000},
+		{Venue: "OKX", Symbol: "BTCUSDT", BestBid: 1},
+		{Venue: "BYBIT", Symbol: "ETHUSDT", BestBid: 2},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("published %d updates, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Venue != want[i].Venue || got[i].Symbol != want[i].Symbol || got[i].BestBid != want[i].BestBid {
+			t.Errorf("update %d = %s %s %g, want %s %s %g", i, got[i].Venue, got[i].Symbol, got[i].BestBid,
+				want[i].Venue, want[i].Symbol, want[i].BestBid)
+		}
+	}
+	if c.Conflated() != 500 {
+		t.Errorf("conflated = %d, want 500", c.Conflated())
+	}
+
+	got = got[:0]
+	if n := c.Flush(); n != 0 || len(got) != 0 {
+		t.Fatalf("empty window published %d updates", len(got))
+	}
+	c.Depth(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 7})
+	c.Flush()
+	if len(got) != 1 || got[0].BestBid != 7 {
+		t.Fatalf("next window published %+v", got)
+	}
+}
@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/helix-lab/helix/gateway/pkg/decimal"
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/l2book"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+func TestDecimalParse(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		want decimal.Decimal
+		str  string
+		err  error
+	}{
+		{"65000.5", 6500050000000, "65000.5", nil},
+		{"65000.50000000000000", 6500050000000, "65000.5", nil},
+		{"0.10", 10000000, "0.1", nil},
+		{"-0.001", -100000, "-0.001", nil},
+		{"+3", 300000000, "3", nil},
+		{"1e-8", 1, "0.00000001", nil},
+		{"1.5E3", 150000000000, "1500", nil},
+		{".5", 50000000, "0.5", nil},
+		{"0.000000001", 0, "", decimal.ErrPrecision},
+		{"100000000000", 0, "", decimal.ErrRange},
+		{"1.2.3", 0, "", decimal.ErrSyntax},
+		{"", 0, "", decimal.ErrSyntax},
+		{"1e", 0, "", decimal.ErrSyntax},
+	} {
+		got, err := decimal.Parse(c.in)
+		if !errors.Is(err, c.err) || got != c.want {
+			t.Fatalf("Parse(%q) = %d, %v; want %d, %v", c.in, got, err, c.want, c.err)
+		}
+		if err == nil && got.String() != c.str {
+			t.Fatalf("Parse(%q).String() = %q", c.in, got.String())
+		}
+	}
+	px := []byte("65000.5")
+	if n := testing.AllocsPerRun(100, func() { decimal.ParseBytes(px) }); n != 0 {
+		t.Fatalf("ParseBytes allocates %v", n)
+	}
+
+	var v struct{ Px, Qty decimal.Decimal }
+	if err := json.Unmarshal([]byte(`{"Px":"88633.20","Qty":0.813}`), &v); err != nil || v.Px.String() != "88633.2" || v.Qty.String() != "0.813" {
+		t.Fatalf("unmarshal: %+v %v", v, err)
+	}
+	if b, _ := json.Marshal(v); string(b) != `{"Px":"88633.2","Qty":"0.813"}` {
+		t.Fatalf("marshal: %s", b)
+	}
+	if !decimal.Equal(0.1+0.2, 0.3) || decimal.Add(0.1, 0.2) != 0.3 {
+		t.Fatal("float noise survives decimal rounding")
+	}
+}
+
+func TestDecimalBooksAndFills(t *testing.T) {
+	// a level deleted by a price computed in floating point still goes
+	book := l2book.New()
+	for _, d := range []l2book.Delta{
+		{Seq: 1, PrevSeq: 0, Snapshot: true, Side: 'b', Price: 0.3, Qty: 1},
+		{Seq: 1, PrevSeq: 0, Snapshot: true, Side: 'b', Price: 0.2, Qty: 1},
+		{Seq: 1, PrevSeq: 0, Snapshot: true, Side: 'a', Price: 0.4, Qty: 1},
+		{Seq: 2, PrevSeq: 1, Side: 'b', Price: 0.1 + 0.2, Qty: 0},
+	} {
+		if err := book.Update(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if book.BestBid != 0.2 {
+		t.Fatalf("best bid %g: the 0.3 level was not deleted", book.BestBid)
+	}
+
+	// partial fills sum to the order size exactly
+	tr := executor.NewTracker()
+	o := tr.Open(transport.Action{Venue: "BYBIT", Symbol: "BTCUSDT", Side: "BUY", Size: 0.3})
+	for _, q := range []float64{0.1, 0.2} {
+		if err := tr.Fill(o.ID, 100, q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(tr.OpenOrders()) != 0 {
+		t.Fatalf("0.1 + 0.2 left the 0.3 order open: %+v", tr.OpenOrders())
+	}
+	if pos := tr.Positions(); len(pos) != 1 || pos[0].Qty != 0.3 {
+		t.Fatalf("positions = %+v", pos)
+	}
+}
@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/helix-lab/helix/gateway/pkg/eventlog"
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+func TestEventLogRebuild(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gateway.journal")
+	events, err := eventlog.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker := executor.NewTracker()
+	tracker.SetJournal(events)
+
+	events.Depth(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 100, BestAsk: 101, BidSize: 1, AskSize: 2})
+	events.Trade(transport.Trade{Venue: "BYBIT", Symbol: "BTCUSDT", Side: "BUY", Price: 101, Qty: 1})
+	events.Route(transport.RouteDecision{Symbol: "BTCUSDT", Side: "BUY", Size: 5, Venue: "BYBIT"}, errors.New("risk: gross notional over limit"))
+	events.Route(transport.RouteDecision{Symbol: "BTCUSDT", Side: "BUY", Size: 2, Venue: "BYBIT"}, nil)
+	a := tracker.Open(transport.Action{Venue: "BYBIT", Symbol: "BTCUSDT", Side: "BUY", Size: 2})
+	if err := tracker.Fill(a.ID, 101, 2); err != nil {
+		t.Fatal(err)
+	}
+	b := tracker.Open(transport.Action{Venue: "BYBIT", Symbol: "BTCUSDT", Side: "SELL", Size: 1})
+	if err := events.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a restarted gateway continues the sequence
+	events, err = eventlog.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker.SetJournal(events)
+	if events.Seq() != 8 {
+		t.Fatalf("seq after reopen = %d", events.Seq())
+	}
+	if err := tracker.Cancel(b.ID); err != nil {
+		t.Fatal(err)
+	}
+	events.Depth(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 102, BestAsk: 103, BidSize: 1, AskSize: 1})
+	if err := events.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	all, err := eventlog.Rebuild(f, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := all.Stats()
+	if st.First != 1 || st.Last != 10 || st.Gaps != 0 {
+		t.Fatalf("stats = %+v", st)
+	}
+	for typ, n := range map[string]int{eventlog.TypeDepth: 2, eventlog.TypeTrade: 1, eventlog.TypeReject: 1, eventlog.TypeRoute: 1,
+		eventlog.TypeOrder: 3, eventlog.TypeFill: 1, eventlog.TypeCancel: 1} {
+		if st.Events[typ] != n {
+			t.Fatalf("%s events = %d, want %d (%v)", typ, st.Events[typ], n, st.Events)
+		}
+	}
+	end := all.State()
+	if len(end.Orders) != 0 || len(end.Positions) != 1 || end.Positions[0].Qty != 2 || end.Positions[0].AvgPrice != 101 {
+		t.Fatalf("final state = %+v", end)
+	}
+	if len(end.Books) != 1 || end.Books[0].BestBid != 102 {
+		t.Fatalf("final books = %+v", end.Books)
+	}
+
+	// as of the sell order opening, before the restart
+	f.Seek(0, 0)
+	mid, err := eventlog.Rebuild(f, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	then := mid.State()
+	if len(then.Orders) != 1 || then.Orders[0].ID != b.ID || then.Books[0].BestBid != 100 {
+		t.Fatalf("state at seq 8 = %+v", then)
+	}
+}
@@ -0,0 +1,307 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/router"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+func TestTrackerPositions(t *testing.T) {
+	tr := executor.NewTracker()
+	buy := tr.Open(transport.Action{Venue: "BYBIT", Symbol: "BTCUSDT", Side: "BUY", Size: 2})
+	if err := tr.Fill(buy.ID, 100, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Fill(buy.ID, 110, 1); err != nil {
+		t.Fatal(err)
+	}
+	sell := tr.Open(transport.Action{Venue: "BYBIT", Symbol: "BTCUSDT", Side: "SELL", Size: 3})
+	if got := tr.Exposure("BYBIT", "BTCUSDT"); got != -1 {
+		t.Fatalf("exposure with open sell = %g", got)
+	}
+	if err := tr.Fill(sell.ID, 120, 3); err != nil {
+		t.Fatal(err)
+	}
+	pos := tr.Positions()
+	if len(pos) != 1 || pos[0].Qty != -1 || pos[0].AvgPrice != 120 || pos[0].Realized != 30 {
+		t.Fatalf("positions = %+v", pos)
+	}
+	if err := tr.Fill(sell.ID, 120, 1); err == nil {
+		t.Fatal("fill on a filled order should fail")
+	}
+	if len(tr.OpenOrders()) != 0 {
+		t.Fatalf("open orders = %+v", tr.OpenOrders())
+	}
+}
+
+func TestTrackerPartialFills(t *testing.T) {
+	tr := executor.NewTracker()
+	var done []transport.OrderComplete
+	tr.OnComplete(func(o executor.Order) { done = append(done, o.Completion()) })
+	buy := tr.Open(transport.Action{Venue: "BYBIT", Symbol: "BTCUSDT", Side: "BUY", Size: 1})
+	tr.Fill(buy.ID, 100, 0.25)
+	tr.Fill(buy.ID, 102, 0.5)
+	o, _ := tr.Order(buy.ID)
+	if o.Fills != 2 || o.Filled != 0.75 || o.Remaining() != 0.25 || math.Abs(o.AvgPrice-(25+51)/0.75) > 1e-9 || len(done) != 0 {
+		t.Fatalf("partly filled = %+v, done %+v", o, done)
+	}
+	tr.Fill(buy.ID, 104, 0.25)
+	if len(done) != 1 || done[0].Status != executor.StatusFilled || done[0].Fills != 3 || done[0].AvgPrice != 102 {
+		t.Fatalf("complete = %+v", done)
+	}
+
+	sell := tr.Open(transport.Action{Venue: "BYBIT", Symbol: "BTCUSDT", Side: "SELL", Size: 2})
+	tr.Fill(sell.ID, 105, 0.5)
+	if err := tr.Cancel(sell.ID); err != nil {
+		t.Fatal(err)
+	}
+	if o, _ := tr.Order(sell.ID); o.Remaining() != 0 || len(done) != 2 || done[1].Status != executor.StatusCancelled || done[1].Filled != 0.5 {
+		t.Fatalf("cancelled = %+v, complete %+v", o, done)
+	}
+}
+
+func TestOrderSenderLimitsPerSymbol(t *testing.T) {
+	sender := executor.NewOrderSender(transport.NewPublisher("inproc://limits"), router.NewSmartRouter(router.DefaultFees()))
+	sender.SetTracker(executor.NewTracker())
+	sender.SetLimits(executor.Limits{
+		MaxOrderSize: func(venue, symbol string) float64 {
+			if symbol == "ETHUSDT" {
+				return 10
+			}
+			return 1
+		},
+		MaxPosition: 1.5,
+	})
+	ctx := context.Background()
+	views := map[string]router.BookView{"BYBIT": {BestBid: 100, BestAsk: 101}}
+	send := func(sym string, size float64) error {
+		_, err := sender.Send(ctx, transport.Action{Symbol: sym, Side: "BUY", Size: size}, views)
+		return err
+	}
+	if err := send("BTCUSDT", 2); !errors.Is(err, executor.ErrOrderSize) {
+		t.Fatalf("oversized BTC order: %v", err)
+	}
+	if err := send("BTCUSDT", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := send("BTCUSDT", 1); !errors.Is(err, executor.ErrPositionLimit) {
+		t.Fatalf("second BTC order should hit the position limit: %v", err)
+	}
+	// limits are per symbol: ETH has its own size and exposure
+	if err := send("ETHUSDT", 1.5); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPaperSinkFillsAtTouch(t *testing.T) {
+	books := orderbook.NewManager()
+	books.Apply(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 100, BestAsk: 101, BidSize: 1, AskSize: 1})
+	tracker := executor.NewTracker()
+	paper := executor.NewPaper(books)
+	sender := executor.NewOrderSender(transport.NewPublisher("inproc://paper"), router.NewSmartRouter(router.DefaultFees()))
+	sender.SetTracker(tracker)
+	sender.SetSink(paper)
+	views := map[string]router.BookView{"BYBIT": {BestBid: 100, BestAsk: 101}}
+	ctx := context.Background()
+
+	buy, err := sender.Send(ctx, transport.Action{Symbol: "BTCUSDT", Side: "BUY", Size: 2}, views)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sender.Send(ctx, transport.Action{Symbol: "BTCUSDT", Side: "SELL", Size: 1}, views); err != nil {
+		t.Fatal(err)
+	}
+	<-paper.Ready()
+	fills := paper.TakeFills()
+	if len(fills) != 2 || fills[0].OrderID != buy.ID || fills[0].Price != 101 || fills[1].Price != 100 {
+		t.Fatalf("fills = %+v", fills)
+	}
+	for _, f := range fills {
+		if err := tracker.Fill(f.OrderID, f.Price, f.Qty); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if pos := tracker.Positions(); len(pos) != 1 || pos[0].Qty != 1 || pos[0].Realized != -1 {
+		t.Fatalf("positions = %+v", pos)
+	}
+
+	// no book on the routed symbol: refused, and the order is not left open
+	if _, err := sender.Send(ctx, transport.Action{Symbol: "ETHUSDT", Side: "BUY", Size: 1}, views); err == nil {
+		t.Fatal("paper fill without a book should fail")
+	}
+	if n := len(tracker.OpenOrders()); n != 0 {
+		t.Fatalf("open orders = %d", n)
+	}
+}
+
+type countingSink struct {
+	n    int
+	last transport.Action
+}
+
+func (c *countingSink) Submit(_ context.Context, a transport.Action) error {
+	c.n++
+	c.last = a
+	return nil
+}
+
+func TestOrderSenderDryRun(t *testing.T) {
+	tracker := executor.NewTracker()
+	sink := &countingSink{}
+	sender := executor.NewOrderSender(transport.NewPublisher("inproc://dry"), router.NewSmartRouter(router.DefaultFees()))
+	sender.SetTracker(tracker)
+	sender.SetSink(sink)
+	sender.SetDryRun(true)
+	sender.SetLimits(executor.Limits{MaxOrderSize: func(string, string) float64 { return 1 }})
+	views := map[string]router.BookView{
+		"BYBIT":   {BestBid: 100, BestAsk: 101},
+		"BINANCE": {BestBid: 100, BestAsk: 100.5},
+	}
+	ctx := context.Background()
+	sent, err := sender.Send(ctx, transport.Action{Symbol: "BTCUSDT", Side: "BUY", Size: 1}, views)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent.Venue != "BINANCE" || sent.ID != "" || sink.n != 0 || len(tracker.OpenOrders()) != 0 {
+		t.Fatalf("dry run sent something: %+v sink=%d", sent, sink.n)
+	}
+	// limits still apply, so the shadow run rejects what live would
+	if _, err := sender.Send(ctx, transport.Action{Symbol: "BTCUSDT", Side: "BUY", Size: 2}, views); !errors.Is(err, executor.ErrOrderSize) {
+		t.Fatalf("oversized dry-run order: %v", err)
+	}
+
+	sender.SetDryRun(false)
+	if _, err := sender.Send(ctx, transport.Action{Symbol: "BTCUSDT", Side: "BUY", Size: 1}, views); err != nil || sink.n != 1 {
+		t.Fatalf("live send: err=%v sink=%d", err, sink.n)
+	}
+}
+
+func TestMakerQueuePosition(t *testing.T) {
+	q := executor.NewMakerQueue()
+	book := orderbook.Level{BestBid: 100, BestAsk: 101, BidSize: 5, AskSize: 5}
+	q.Add(transport.Action{ID: "b1", Venue: "BYBIT", Symbol: "BTCUSDT", Side: "BUY", Size: 1, Price: 100}, book)
+	depth := func(bid, bidSize, ask float64) []transport.Fill {
+		return q.Depth(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: bid, BidSize: bidSize, BestAsk: ask, AskSize: 5})
+	}
+	trade := func(side string, px, qty float64) []transport.Fill {
+		return q.Trade(transport.Trade{Venue: "BYBIT", Symbol: "BTCUSDT", Side: side, Price: px, Qty: qty})
+	}
+
+	// 5 ahead: buys and a 3 lot sold into the level only shrink the queue
+	if f := trade("BUY", 101, 10); len(f) != 0 {
+		t.Fatalf("buy print filled a resting buy: %+v", f)
+	}
+	if f := trade("SELL", 100, 3); len(f) != 0 {
+		t.Fatalf("filled with 2 still ahead: %+v", f)
+	}
+	// the level drops from 2 to 1 without a print: half the cancels were ahead
+	if f := depth(100, 1, 101); len(f) != 0 {
+		t.Fatalf("filled on a cancel: %+v", f)
+	}
+	f := trade("SELL", 100, 1.5)
+	if len(f) != 1 || f[0].Qty != 0.5 || f[0].Price != 100 || !f[0].Maker {
+		t.Fatalf("partial fill = %+v, want 0.5 maker at 100", f)
+	}
+	if f := trade("SELL", 99.5, 0.1); len(f) != 1 || f[0].Qty != 0.5 {
+		t.Fatalf("print through our price = %+v, want the remaining 0.5", f)
+	}
+	if q.Len() != 0 {
+		t.Fatalf("%d orders still resting", q.Len())
+	}
+
+	// behind the touch the queue is unknown until the price becomes the touch
+	q.Add(transport.Action{ID: "b2", Venue: "BYBIT", Symbol: "BTCUSDT", Side: "BUY", Size: 1, Price: 99}, book)
+	if f := trade("SELL", 99, 10); len(f) != 0 {
+		t.Fatalf("filled before the queue was seen: %+v", f)
+	}
+	depth(99, 4, 100)
+	if f := trade("SELL", 99, 4.5); len(f) != 1 || f[0].Qty != 0.5 {
+		t.Fatalf("fill after 4 ahead = %+v", f)
+	}
+	// the ask coming down through our price fills the rest
+	if f := depth(98, 1, 98.5); len(f) != 1 || f[0].Qty != 0.5 {
+		t.Fatalf("crossed book fill = %+v", f)
+	}
+}
+
+func TestPaperRestsPassiveLimits(t *testing.T) {
+	books := orderbook.NewManager()
+	books.Apply(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 100, BestAsk: 101, BidSize: 1, AskSize: 1})
+	paper := executor.NewPaper(books)
+	ctx := context.Background()
+	if err := paper.Submit(ctx, transport.Action{ID: "s1", Venue: "BYBIT", Symbol: "BTCUSDT", Side: "SELL", Size: 2, Price: 102}); err != nil {
+		t.Fatal(err)
+	}
+	if err := paper.Submit(ctx, transport.Action{ID: "b1", Venue: "BYBIT", Symbol: "BTCUSDT", Side: "BUY", Size: 1, Price: 101.5}); err != nil {
+		t.Fatal(err)
+	}
+	fills := paper.TakeFills()
+	if len(fills) != 1 || fills[0].OrderID != "b1" || fills[0].Price != 101 || fills[0].Maker {
+		t.Fatalf("marketable limit = %+v, want a taker fill at 101", fills)
+	}
+	<-paper.Ready()
+	if r := paper.Resting(); len(r) != 1 || r[0].ID != "s1" {
+		t.Fatalf("resting = %+v", r)
+	}
+	paper.Depth(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 102.5, BestAsk: 103, BidSize: 1, AskSize: 1})
+	select {
+	case <-paper.Ready():
+	default:
+		t.Fatal("no fill signalled after the bid crossed the resting sell")
+	}
+	if fills := paper.TakeFills(); len(fills) != 1 || fills[0].Price != 102 || fills[0].Qty != 2 || !fills[0].Maker {
+		t.Fatalf("crossed fill = %+v", fills)
+	}
+}
+
+func TestPaperLatency(t *testing.T) {
+	for in, want := range map[string]string{"": "0s", "5ms": "5ms", "uniform:2ms,8ms": "uniform:2ms,8ms", "lognormal:5ms,0.5": "lognormal:5ms,0.5"} {
+		if d, err := executor.ParseDist(in); err != nil || d.String() != want {
+			t.Fatalf("ParseDist(%q) = %v, %v; want %s", in, d, err, want)
+		}
+	}
+	for _, in := range []string{"fast", "uniform:8ms,2ms", "gamma:1ms,2ms", "normal:5ms"} {
+		if _, err := executor.ParseDist(in); err == nil {
+			t.Fatalf("ParseDist(%q) accepted", in)
+		}
+	}
+
+	books := orderbook.NewManager()
+	books.Apply(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 100, BestAsk: 101, BidSize: 1, AskSize: 1})
+	paper := executor.NewPaper(books)
+	paper.SetLatency(executor.Latency{Transit: executor.Dist{A: 30 * time.Millisecond}, Ack: executor.Dist{A: 30 * time.Millisecond}})
+	ctx := context.Background()
+	start := time.Now()
+	if err := paper.Submit(ctx, transport.Action{ID: "b1", Venue: "BYBIT", Symbol: "BTCUSDT", Side: "BUY", Size: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := paper.Submit(ctx, transport.Action{ID: "b2", Venue: "BYBIT", Symbol: "BTCUSDT", Side: "BUY", Size: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := paper.Cancel(ctx, executor.Order{ID: "b2"}); err != nil {
+		t.Fatalf("cancel in flight: %v", err)
+	}
+	// the ask moves while the order is on its way
+	books.Apply(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 101, BestAsk: 102, BidSize: 1, AskSize: 1})
+	if fills := paper.TakeFills(); len(fills) != 0 {
+		t.Fatalf("fills before arrival = %+v", fills)
+	}
+	select {
+	case <-paper.Ready():
+	case <-time.After(2 * time.Second):
+		t.Fatal("no fill reported")
+	}
+	if took := time.Since(start); took < 60*time.Millisecond {
+		t.Fatalf("fill reported after %v, want transit and ack", took)
+	}
+	if fills := paper.TakeFills(); len(fills) != 1 || fills[0].OrderID != "b1" || fills[0].Price != 102 {
+		t.Fatalf("fills = %+v, want b1 at the ask it arrived to", fills)
+	}
+}
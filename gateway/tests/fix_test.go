@@ -0,0 +1,324 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/fix"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/router"
+	"github.com/helix-lab/helix/gateway/pkg/stratapi"
+	"github.com/helix-lab/helix/gateway/pkg/strategy"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+func TestFIXCodec(t *testing.T) {
+	m := fix.New(fix.MsgNewOrderSingle).Add(fix.TagClOrdID, "c1").Add(fix.TagOrderQty, 0.5).Add(fix.TagPossDupFlag, true)
+	raw := m.Encode()
+	if !strings.HasPrefix(string(raw), "8=FIX.4.4\x019=") || !strings.HasSuffix(string(raw), "\x01") {
+		t.Fatalf("encoded %q", raw)
+	}
+	got, err := fix.Read(bufio.NewReader(strings.NewReader(string(raw) + string(raw))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != m.String() || got.Get(fix.TagOrderQty) != "0.5" || got.Get(fix.TagPossDupFlag) != "Y" {
+		t.Fatalf("round trip = %s, want %s", got, m)
+	}
+	bad := strings.Replace(string(raw), "c1", "c2", 1)
+	if _, err := fix.Read(bufio.NewReader(strings.NewReader(bad))); err == nil {
+		t.Fatal("a changed body passed its checksum")
+	}
+}
+
+func TestFIXOrderEntry(t *testing.T) {
+	books := orderbook.NewManager()
+	tracker := executor.NewTracker()
+	sender := executor.NewOrderSender(transport.NewPublisher("inproc://fix"), router.NewSmartRouter(router.DefaultFees()))
+	sender.SetTracker(tracker)
+	paper := executor.NewPaper(books)
+	sender.SetSink(paper)
+	host := strategy.NewHost(books, sender, tracker)
+	srv, err := fix.NewServer("", []fix.Client{{CompID: "OMS1", Password: "secret", Symbols: []string{"BTCUSDT"},
+		Limits: stratapi.Limits{MaxOrderSize: 2}}}, tracker, sender)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Strategies(host.Add)
+	if err := srv.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	u := transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 100, BestAsk: 101, BidSize: 5, AskSize: 5}
+	books.Apply(u)
+	host.Book(context.Background(), u)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addr := srv.Addr().String()
+	if _, err := fix.Dial(ctx, addr, fix.InitiatorConfig{SenderCompID: "OMS1", Password: "wrong"}); err == nil || !strings.Contains(err.Error(), "refused") {
+		t.Fatalf("bad password: %v", err)
+	}
+	cl, err := fix.Dial(ctx, addr, fix.InitiatorConfig{SenderCompID: "OMS1", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fix.Dial(ctx, addr, fix.InitiatorConfig{SenderCompID: "OMS1", Password: "secret"}); err == nil {
+		t.Fatal("second logon accepted")
+	}
+
+	read := func() fix.Message {
+		t.Helper()
+		select {
+		case m, ok := <-cl.Messages():
+			if !ok {
+				t.Fatal("session ended")
+			}
+			return m
+		case <-ctx.Done():
+			t.Fatal("no message")
+		}
+		return nil
+	}
+	// send sends m and runs it the way the gateway loop does
+	send := func(m fix.Message) fix.Message {
+		t.Helper()
+		if err := cl.Send(m); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case req := <-srv.Requests():
+			srv.Exec(ctx, req, host)
+		case <-ctx.Done():
+			t.Fatal("no request")
+		}
+		return read()
+	}
+	fills := func() {
+		t.Helper()
+		for _, f := range paper.TakeFills() {
+			if err := host.Fill(ctx, f); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// a market order is accepted, then filled at the ask
+	m := send(fix.NewOrderSingle("c1", "BTCUSDT", fix.SideBuy, 1, 0, ""))
+	if m.Type() != fix.MsgExecutionReport || m.Get(fix.TagExecType) != fix.ExecNew || m.Get(fix.TagClOrdID) != "c1" ||
+		m.Get(fix.TagLeavesQty) != "1" {
+		t.Fatalf("new = %s", m)
+	}
+	id := m.Get(fix.TagOrderID)
+	fills()
+	m = read()
+	if m.Get(fix.TagExecType) != fix.ExecTrade || m.Get(fix.TagOrdStatus) != fix.StatusFilled || m.Get(fix.TagOrderID) != id ||
+		m.Get(fix.TagLastPx) != "101" || m.Get(fix.TagCumQty) != "1" || m.Get(fix.TagLeavesQty) != "0" || m.Get(fix.TagAvgPx) != "101" {
+		t.Fatalf("fill = %s", m)
+	}
+	if m := send(fix.OrderCancelRequest("x1", "c1", "BTCUSDT", fix.SideBuy)); m.Type() != fix.MsgOrderCancelReject ||
+		m.Get(fix.TagOrdStatus) != fix.StatusFilled || m.Get(fix.TagCxlRejReason) != "0" {
+		t.Fatalf("cancel of a filled order = %s", m)
+	}
+
+	// a resting limit order is canceled
+	m = send(fix.NewOrderSingle("c2", "BTCUSDT", fix.SideSell, 0.5, 105, ""))
+	if m.Get(fix.TagExecType) != fix.ExecNew || m.Get(fix.TagPrice) != "105" || m.Get(fix.TagOrdType) != fix.OrdTypeLimit {
+		t.Fatalf("limit = %s", m)
+	}
+	m = send(fix.OrderCancelRequest("x2", "c2", "BTCUSDT", fix.SideSell))
+	if m.Get(fix.TagExecType) != fix.ExecCanceled || m.Get(fix.TagOrdStatus) != fix.StatusCanceled ||
+		m.Get(fix.TagClOrdID) != "x2" || m.Get(fix.TagOrigClOrdID) != "c2" || m.Get(fix.TagLeavesQty) != "0" {
+		t.Fatalf("canceled = %s", m)
+	}
+	if len(paper.Resting()) != 0 {
+		t.Fatalf("still resting: %+v", paper.Resting())
+	}
+	if m := send(fix.OrderCancelRequest("x3", "nope", "BTCUSDT", fix.SideSell)); m.Type() != fix.MsgOrderCancelReject ||
+		m.Get(fix.TagCxlRejReason) != "1" || m.Get(fix.TagOrderID) != "NONE" {
+		t.Fatalf("unknown cancel = %s", m)
+	}
+
+	for _, tc := range []struct {
+		m    fix.Message
+		want string
+	}{
+		{fix.NewOrderSingle("r1", "BTCUSDT", fix.SideBuy, 3, 0, ""), "max order size"},
+		{fix.NewOrderSingle("r2", "ETHUSDT", fix.SideBuy, 1, 0, ""), "may not trade"},
+		{fix.NewOrderSingle("c1", "BTCUSDT", fix.SideBuy, 1, 0, ""), "duplicate ClOrdID"},
+		{fix.NewOrderSingle("r3", "BTCUSDT", "7", 1, 0, ""), "Side"},
+		{fix.New(fix.MsgNewOrderSingle).Add(fix.TagClOrdID, "r4").Add(fix.TagSymbol, "BTCUSDT").Add(fix.TagSide, fix.SideBuy).
+			Add(fix.TagOrderQty, "one").Add(fix.TagOrdType, fix.OrdTypeMarket), "OrderQty"},
+	} {
+		if m := send(tc.m); m.Get(fix.TagExecType) != fix.ExecRejected || !strings.Contains(m.Get(fix.TagText), tc.want) {
+			t.Fatalf("%s: got %s, want a reject mentioning %q", tc.m, m, tc.want)
+		}
+	}
+	if err := cl.Logout(); err != nil {
+		t.Fatal(err)
+	}
+	// the CompID is free again once logged out
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		cl, err = fix.Dial(ctx, addr, fix.InitiatorConfig{SenderCompID: "OMS1", Password: "secret"})
+		if err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = cl.Logout()
+}
+
+func TestFIXSessionLevel(t *testing.T) {
+	srv, err := fix.NewServer("GW", []fix.Client{{CompID: "OMS1", Password: "secret"}}, executor.NewTracker(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	conn, err := net.Dial("tcp", srv.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	seq := 0
+	write := func(m fix.Message, num int) {
+		t.Helper()
+		seq = num
+		h := fix.Message{m[0]}.Add(fix.TagSenderCompID, "OMS1").Add(fix.TagTargetCompID, "GW").
+			Add(fix.TagMsgSeqNum, num).Add(fix.TagSendingTime, time.Now())
+		if _, err := conn.Write(append(h, m[1:]...).Encode()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(msgType string) fix.Message {
+		t.Helper()
+		for {
+			m, err := fix.Read(r)
+			if err != nil {
+				t.Fatalf("waiting for %s: %v", msgType, err)
+			}
+			if m.Type() == msgType {
+				return m
+			}
+		}
+	}
+
+	write(fix.New(fix.MsgLogon).Add(fix.TagEncryptMethod, 0).Add(fix.TagHeartBtInt, 1).Add(fix.TagPassword, "secret"), 1)
+	if m := read(fix.MsgLogon); m.Get(fix.TagHeartBtInt) != "1" || m.Get(fix.TagSenderCompID) != "GW" || m.Int(fix.TagMsgSeqNum) != 1 {
+		t.Fatalf("logon = %s", m)
+	}
+	write(fix.New(fix.MsgTestRequest).Add(fix.TagTestReqID, "ping"), seq+1)
+	if m := read(fix.MsgHeartbeat); m.Get(fix.TagTestReqID) != "ping" {
+		t.Fatalf("heartbeat = %s", m)
+	}
+	// a gap is asked for again; the resend fills it and the session goes on
+	write(fix.New(fix.MsgHeartbeat), 5)
+	if m := read(fix.MsgResendRequest); m.Int(fix.TagBeginSeqNo) != 3 || m.Int(fix.TagEndSeqNo) != 0 {
+		t.Fatalf("resend request = %s", m)
+	}
+	write(fix.New(fix.MsgSequenceReset).Add(fix.TagGapFillFlag, true).Add(fix.TagNewSeqNo, 6).Add(fix.TagPossDupFlag, true), 3)
+	write(fix.New(fix.MsgResendRequest).Add(fix.TagBeginSeqNo, 1).Add(fix.TagEndSeqNo, 0), 6)
+	if m := read(fix.MsgSequenceReset); m.Get(fix.TagGapFillFlag) != "Y" || m.Int(fix.TagMsgSeqNum) != 1 || m.Int(fix.TagNewSeqNo) < 3 {
+		t.Fatalf("gap fill = %s", m)
+	}
+	write(fix.New("U1"), 7)
+	if m := read(fix.MsgReject); m.Int(fix.TagRefSeqNum) != 7 {
+		t.Fatalf("reject = %s", m)
+	}
+	// silence draws a TestRequest, then a disconnect
+	read(fix.MsgTestRequest)
+	for {
+		if _, err := fix.Read(r); err != nil {
+			break
+		}
+	}
+}
+
+func TestFIXDropCopy(t *testing.T) {
+	dc, err := fix.NewDropCopy("", []fix.DropCopyClient{{CompID: "RECON", Password: "r"},
+		{CompID: "DESK", Password: "d", Accounts: []string{"alpha"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dc.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer dc.Close()
+	tracker := executor.NewTracker()
+	tracker.SetJournal(executor.Journals{dc})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// RECON is logged off for the first order and gets it on logon
+	a := tracker.Open(transport.Action{Symbol: "BTCUSDT", Side: "BUY", Size: 1, Venue: "BYBIT", Account: "alpha"})
+	recon, err := fix.Dial(ctx, dc.Addr().String(), fix.InitiatorConfig{SenderCompID: "RECON", Password: "r"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recon.Logout()
+	desk, err := fix.Dial(ctx, dc.Addr().String(), fix.InitiatorConfig{SenderCompID: "DESK", Password: "d"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer desk.Logout()
+	b := tracker.Open(transport.Action{Symbol: "ETHUSDT", Side: "SELL", Size: 2, Venue: "BYBIT", Account: "beta"})
+	if err := tracker.Fill(a.ID, 100, 0.4); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.Fill(a.ID, 101, 0.6); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.Cancel(b.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	type report struct{ id, account, execType, status, cum, leaves, lastPx string }
+	read := func(cl *fix.Initiator, n int) []report {
+		t.Helper()
+		var out []report
+		for len(out) < n {
+			select {
+			case m := <-cl.Messages():
+				if m.Type() != fix.MsgExecutionReport || m.Get(fix.TagCopyMsgIndicator) != "Y" {
+					t.Fatalf("copy = %s", m)
+				}
+				out = append(out, report{m.Get(fix.TagOrderID), m.Get(fix.TagAccount), m.Get(fix.TagExecType), m.Get(fix.TagOrdStatus),
+					m.Get(fix.TagCumQty), m.Get(fix.TagLeavesQty), m.Get(fix.TagLastPx)})
+			case <-ctx.Done():
+				t.Fatalf("%d of %d copies", len(out), n)
+			}
+		}
+		return out
+	}
+	want := []report{
+		{a.ID, "alpha", fix.ExecNew, fix.StatusNew, "0", "1", ""},
+		{b.ID, "beta", fix.ExecNew, fix.StatusNew, "0", "2", ""},
+		{a.ID, "alpha", fix.ExecTrade, fix.StatusPartiallyFilled, "0.4", "0.6", "100"},
+		{a.ID, "alpha", fix.ExecTrade, fix.StatusFilled, "1", "0", "101"},
+		{b.ID, "beta", fix.ExecCanceled, fix.StatusCanceled, "0", "0", ""},
+	}
+	for i, r := range read(recon, len(want)) {
+		if r != want[i] {
+			t.Fatalf("RECON copy %d = %+v, want %+v", i, r, want[i])
+		}
+	}
+	// DESK follows alpha only and was logged off for its opening
+	for i, r := range read(desk, 3) {
+		if w := []report{want[0], want[2], want[3]}[i]; r != w {
+			t.Fatalf("DESK copy %d = %+v, want %+v", i, r, w)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/fundarb"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/router"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+func TestFundingArbitrage(t *testing.T) {
+	books := orderbook.NewManager()
+	for _, u := range []transport.DepthUpdate{
+		{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 10000, BestAsk: 10001, BidSize: 1, AskSize: 1},
+		{Venue: "BINANCE", Symbol: "BTCUSDT", BestBid: 9999, BestAsk: 10000, BidSize: 1, AskSize: 1},
+		{Venue: "BYBIT_SPOT", Symbol: "BTCUSDT", BestBid: 9990, BestAsk: 9995, BidSize: 1, AskSize: 1},
+	} {
+		books.Apply(u)
+	}
+	fees := router.FeeModel{Taker: map[string]float64{"BYBIT": 0.0002, "BINANCE": 0.0002, "BYBIT_SPOT": 0.0005}}
+	now := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	m := fundarb.New(fundarb.Config{Spot: []string{"BYBIT_SPOT"}, Fees: fees, Periods: 3, MinNetBps: -100}, books)
+	m.Funding(transport.FundingRate{Venue: "BYBIT", Symbol: "BTCUSDT", Rate: 0.001, TsMs: now.UnixMilli()})
+	m.Funding(transport.FundingRate{Venue: "BINANCE", Symbol: "BTCUSDT", Rate: 0.0001, TsMs: now.UnixMilli()})
+	// stale rates are ignored
+	m.Funding(transport.FundingRate{Venue: "OKX", Symbol: "BTCUSDT", Rate: 0.01, TsMs: now.Add(-time.Hour).UnixMilli()})
+
+	ops := m.Evaluate(now)
+	if len(ops) != 3 {
+		t.Fatalf("opportunities = %+v", ops)
+	}
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-6 }
+	// short BYBIT perp at 10000 against spot bought at 9995: 3x10bps + ~5bps basis - 14bps fees
+	best := ops[0]
+	if best.Rank != 1 || best.Kind != fundarb.KindCashCarry || best.ShortVenue != "BYBIT" || best.LongVenue != "BYBIT_SPOT" ||
+		!near(best.FundingBps, 10) || !near(best.FeesBps, 14) || !near(best.NetBps, 30+best.BasisBps-14) || best.BasisBps < 4.9 {
+		t.Fatalf("best = %+v", best)
+	}
+	spread := ops[1]
+	if spread.Rank != 2 || spread.Kind != fundarb.KindFundingSpread || spread.ShortVenue != "BYBIT" || spread.LongVenue != "BINANCE" ||
+		!near(spread.FundingBps, 9) || spread.BasisBps != 0 || !near(spread.NetBps, 27-8) {
+		t.Fatalf("spread = %+v", spread)
+	}
+	if ops[2].Kind != fundarb.KindCashCarry || ops[2].ShortVenue != "BINANCE" || ops[2].Rank != 3 {
+		t.Fatalf("third = %+v", ops[2])
+	}
+
+	// the threshold drops whatever nets less after fees
+	m = fundarb.New(fundarb.Config{Spot: []string{"BYBIT_SPOT"}, Fees: fees, Periods: 3, MinNetBps: 15}, books)
+	m.Funding(transport.FundingRate{Venue: "BYBIT", Symbol: "BTCUSDT", Rate: 0.001, TsMs: now.UnixMilli()})
+	m.Funding(transport.FundingRate{Venue: "BINANCE", Symbol: "BTCUSDT", Rate: 0.0001, TsMs: now.UnixMilli()})
+	if ops := m.Evaluate(now); len(ops) != 2 {
+		t.Fatalf("above 15bps = %+v", ops)
+	}
+}
@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/fx"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/risk"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+func TestFXRates(t *testing.T) {
+	books := orderbook.NewManager()
+	for _, u := range []transport.DepthUpdate{
+		{Venue: "BYBIT_SPOT", Symbol: "BTCUSDT", Category: "spot", BestBid: 29999, BestAsk: 30001},
+		// the perpetual trades at a premium and is only used without spot
+		{Venue: "BYBIT", Symbol: "BTCUSDT", Category: "linear", BestBid: 30099, BestAsk: 30101},
+		{Venue: "BINANCE", Symbol: "ETHBTC", Category: "spot", BestBid: 0.0499, BestAsk: 0.0501},
+		{Venue: "BINANCE", Symbol: "USDCUSDT", Category: "spot", BestBid: 0.9998, BestAsk: 1.0002},
+	} {
+		books.Apply(u)
+	}
+	rates := fx.New(books, "usdt")
+	near := func(got, want float64) bool { return math.Abs(got-want) < 1e-9 }
+	for _, tc := range []struct {
+		asset string
+		want  float64
+		ok    bool
+	}{
+		{"USDT", 1, true},
+		{"BTC", 30000, true},
+		{"ETH", 1500, true}, // through BTC
+		{"USDC", 1, true},
+		{"DAI", 1, true}, // no book, at par as a stable
+		{"DOGE", 0, false},
+	} {
+		if got, ok := rates.Rate(tc.asset); ok != tc.ok || !near(got, tc.want) {
+			t.Errorf("Rate(%s) = %g, %v; want %g, %v", tc.asset, got, ok, tc.want, tc.ok)
+		}
+	}
+	if v, ok := rates.ConvertQuote("ETHBTC", 0.01); !ok || !near(v, 300) {
+		t.Fatalf("0.01 BTC of ETHBTC PnL = %g, %v", v, ok)
+	}
+	if _, ok := rates.ConvertQuote("XYZ", 1); ok {
+		t.Fatal("converted a symbol with no known quote")
+	}
+	if usdc, _ := fx.New(books, "USDC").Rate("BTC"); !near(usdc, 30000) {
+		t.Fatalf("BTC in USDC = %g", usdc)
+	}
+
+	// the risk engine sums a BTC-quoted position with USDT ones in USDT
+	tracker := executor.NewTracker()
+	for _, a := range []transport.Action{
+		{Venue: "BINANCE", Symbol: "ETHBTC", Side: "BUY", Size: 10},
+		{Venue: "BYBIT_SPOT", Symbol: "BTCUSDT", Side: "BUY", Size: 1},
+	} {
+		o := tracker.Open(a)
+		px := map[string]float64{"ETHBTC": 0.049, "BTCUSDT": 29000}[a.Symbol]
+		if err := tracker.Fill(o.ID, px, a.Size); err != nil {
+			t.Fatal(err)
+		}
+	}
+	eng := risk.New(risk.Limits{}, tracker, risk.BookMarks(books))
+	eng.SetRates(rates)
+	st := eng.Evaluate(time.Now())
+	// ETHBTC: (0.05 - 0.049) * 10 BTC = 300 USDT; BTCUSDT, marked on the
+	// spot market it was bought in, not the perpetual: 1000 USDT
+	if st.Base != "USDT" || !near(st.Unrealized, 1300) || !near(st.GrossNotional, 0.5*30000+30000) {
+		t.Fatalf("risk state = %+v", st)
+	}
+}
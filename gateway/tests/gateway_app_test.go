@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"bytes"
+	"database/sql"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/internal/app"
+	"github.com/helix-lab/helix/gateway/internal/app/gateway"
+	"github.com/helix-lab/helix/gateway/pkg/capture"
+)
+
+func TestGatewayDaemonExitCodes(t *testing.T) {
+	if code := gateway.Main([]string{"-config", "/nonexistent/gateway.yaml"}); code != app.ExitConfig {
+		t.Fatalf("missing config: exit %d, want %d", code, app.ExitConfig)
+	}
+	if code := gateway.Main([]string{"-md_token_file", "/nonexistent/md.token"}); code != app.ExitConfig {
+		t.Fatalf("missing token file: exit %d, want %d", code, app.ExitConfig)
+	}
+	if code := gateway.Main([]string{"-backpressure", "sometimes"}); code != app.ExitUsage {
+		t.Fatalf("bad flag value: exit %d, want %d", code, app.ExitUsage)
+	}
+	code := gateway.Main([]string{"-daemon", "-bybit_symbols", "BTCUSDT",
+		"-bybit_endpoint", "ws://127.0.0.1:1", "-feed_timeout", "400ms"})
+	if code != app.ExitFeedLost {
+		t.Fatalf("silent feed: exit %d, want %d", code, app.ExitFeedLost)
+	}
+}
+
+func TestGatewayReplayMode(t *testing.T) {
+	if code := gateway.Main([]string{"-mode", "replay"}); code != app.ExitUsage {
+		t.Fatalf("replay without input: exit %d", code)
+	}
+	if code := gateway.Main([]string{"-mode", "backtest"}); code != app.ExitUsage {
+		t.Fatalf("unknown mode: exit %d", code)
+	}
+
+	path := filepath.Join(t.TempDir(), "tap.jsonl")
+	fl, err := capture.OpenFrameLog(path, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tap := fl.Tap("BYBIT")
+	base := time.Now().UnixNano()
+	tap(base, []byte(`{"topic":"orderbook.1.BTCUSDT","type":"snapshot","ts":1,"data":{"s":"BTCUSDT","b":[["100","1"]],"a":[["101","2"]]}}`))
+	tap(base+1e6, []byte(`{"topic":"orderbook.1.BTCUSDT","type":"delta","ts":2,"data":{"s":"BTCUSDT","b":[["100.5","3"]],"a":[]}}`))
+	if err := fl.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, extra := range [][]string{nil, {"-shards", "4", "-publish_window", "5ms"}} {
+		done := make(chan int, 1)
+		go func() {
+			args := []string{"-daemon", "-mode", "replay", "-replay_in", path, "-replay_speed", "0"}
+			done <- gateway.Main(append(args, extra...))
+		}()
+		select {
+		case code := <-done:
+			if code != app.ExitOK {
+				t.Fatalf("replay mode %v: exit %d", extra, code)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("gateway %v did not stop after the replay ended", extra)
+		}
+	}
+}
+
+func TestGatewayStoreSQLite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tap.jsonl")
+	fl, err := capture.OpenFrameLog(path, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fl.Tap("BYBIT")(time.Now().UnixNano(), []byte(`{"topic":"orderbook.1.BTCUSDT","type":"snapshot","ts":1,"data":{"s":"BTCUSDT","b":[["100","1"]],"a":[["101","2"]]}}`))
+	if err := fl.Close(); err != nil {
+		t.Fatal(err)
+	}
+	dsn := filepath.Join(dir, "orders.db")
+	if code := gateway.Main([]string{"-daemon", "-mode", "replay", "-replay_in", path, "-replay_speed", "0", "-store_dsn", dsn}); code != app.ExitOK {
+		t.Fatalf("exit %d", code)
+	}
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM helix_orders`).Scan(&n); err != nil {
+		t.Fatalf("store not migrated: %v", err)
+	}
+}
+
+func TestGatewayTimescaleDriver(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	// nothing listens on port 1: the pgx driver is found and fails to connect
+	code := gateway.Main([]string{"-daemon", "-mode", "replay", "-replay_in", filepath.Join(t.TempDir(), "none.jsonl"),
+		"-tsdb_url", "postgres://helix@127.0.0.1:1/metrics?connect_timeout=2"})
+	if code != app.ExitStartup {
+		t.Fatalf("exit %d, want %d\n%s", code, app.ExitStartup, logs.String())
+	}
+	if out := logs.String(); !strings.Contains(out, "tsdb: ") || !strings.Contains(out, "failed to connect") {
+		t.Fatalf("log:\n%s", out)
+	}
+}
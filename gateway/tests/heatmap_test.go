@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"bytes"
+	"image/png"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/heatmap"
+	"github.com/helix-lab/helix/gateway/pkg/parquet"
+)
+
+func TestHeatmapFromCapture(t *testing.T) {
+	h, st, err := heatmap.FromCapture(strings.NewReader(btL2), heatmap.Config{Every: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the crossed book at 3000 is skipped, as in downsample
+	if h.Tick != 1 || len(h.Columns) != 2 || st.Skipped != 1 {
+		t.Fatalf("tick=%g columns=%d stats=%+v", h.Tick, len(h.Columns), st)
+	}
+	var out strings.Builder
+	if err := h.WriteCSV(&out); err != nil {
+		t.Fatal(err)
+	}
+	want := `ts_ms,price,bid_size,ask_size
+1000,99,2,0
+1000,100,1,0
+1000,101,0,1
+1000,102,0,3
+2000,99,2,0
+2000,100,1,0
+2000,102,0,3
+`
+	if out.String() != want {
+		t.Fatalf("csv:\n%s", out.String())
+	}
+
+	// coarser buckets sum the levels in them
+	h, _, err = heatmap.FromCapture(strings.NewReader(btL2), heatmap.Config{Every: time.Second, Tick: 2, Levels: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := h.Columns[0].Cells; len(c) != 1 || c[0].Price != 100 || c[0].Bid != 1 || c[0].Ask != 1 {
+		t.Fatalf("tick 2, top level = %+v", c)
+	}
+
+	h, _, _ = heatmap.FromCapture(strings.NewReader(btL2), heatmap.Config{Every: time.Second})
+	dir := t.TempDir()
+	pq := filepath.Join(dir, "heat.parquet")
+	if err := h.WriteFile(pq, parquet.Gzip); err != nil {
+		t.Fatal(err)
+	}
+	f, err := parquet.ReadFile(pq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Rows) != 7 || f.Rows[3][1] != "102" || f.Metadata["helix.heatmap.tick"] != "1" {
+		t.Fatalf("parquet rows=%v meta=%v", f.Rows, f.Metadata)
+	}
+
+	var img bytes.Buffer
+	if err := h.WritePNG(&img); err != nil {
+		t.Fatal(err)
+	}
+	m, err := png.Decode(&img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// two samples wide, 99 to 102 tall with the highest price on top
+	if b := m.Bounds(); b.Dx() != 2 || b.Dy() != 4 {
+		t.Fatalf("png bounds = %v", b)
+	}
+	if r, g, _, _ := m.At(0, 0).RGBA(); r == 0 || g != 0 {
+		t.Fatalf("102 ask pixel = %v", m.At(0, 0))
+	}
+	if r, g, _, _ := m.At(1, 2).RGBA(); r != 0 || g == 0 {
+		t.Fatalf("100 bid pixel = %v", m.At(1, 2))
+	}
+	if r, _, _, _ := m.At(1, 1).RGBA(); r != 0 {
+		t.Fatalf("101 ask pixel after it was pulled = %v", m.At(1, 1))
+	}
+	if err := h.WriteFile(filepath.Join(dir, "heat.txt"), parquet.Gzip); err == nil {
+		t.Fatal("accepted an unknown extension")
+	}
+}
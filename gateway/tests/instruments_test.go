@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/instruments"
+	"github.com/helix-lab/helix/gateway/pkg/router"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+func fakeBybitInstruments(t *testing.T, failing *bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v5/market/instruments-info" || r.URL.Query().Get("category") != "linear" {
+			t.Errorf("request %s", r.URL)
+		}
+		if *failing {
+			http.Error(w, "down", http.StatusBadGateway)
+			return
+		}
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			fmt.Fprint(w, `{"retCode":0,"result":{"nextPageCursor":"p2","list":[
+				{"symbol":"BTCUSDT","status":"Trading","baseCoin":"BTC","quoteCoin":"USDT",
+				 "priceFilter":{"tickSize":"0.10"},
+				 "lotSizeFilter":{"qtyStep":"0.001","minOrderQty":"0.001","maxOrderQty":"100","minNotionalValue":"5"}}]}}`)
+		case "p2":
+			fmt.Fprint(w, `{"retCode":0,"result":{"nextPageCursor":"","list":[
+				{"symbol":"1000PEPEUSDT","status":"Trading","baseCoin":"1000PEPE","quoteCoin":"USDT",
+				 "priceFilter":{"tickSize":"0.0000001"},"lotSizeFilter":{"qtyStep":"100","minOrderQty":"100"}},
+				{"symbol":"ETHUSDT","status":"Settling","baseCoin":"ETH","quoteCoin":"USDT",
+				 "priceFilter":{"tickSize":"0.01"},"lotSizeFilter":{"qtyStep":"0.01"}}]}}`)
+		default:
+			t.Errorf("cursor %q", r.URL.Query().Get("cursor"))
+		}
+	}))
+}
+
+func TestInstrumentCache(t *testing.T) {
+	failing := false
+	srv := fakeBybitInstruments(t, &failing)
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "instruments.json")
+
+	cache := instruments.NewCache(path, instruments.NewBybit("BYBIT", srv.URL, "linear"))
+	if err := cache.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	specs := cache.Specs()
+	if len(specs) != 3 || specs[0].Symbol != "1000PEPEUSDT" || specs[0].Multiplier != 1000 || specs[1].Multiplier != 1 {
+		t.Fatalf("specs = %+v", specs)
+	}
+	btc, _ := cache.Spec("BYBIT", "BTCUSDT")
+	if btc.TickSize != 0.1 || btc.LotSize != 0.001 || btc.MinNotional != 5 || !btc.Trading() {
+		t.Fatalf("BTCUSDT = %+v", btc)
+	}
+	if eth, _ := cache.Spec("BYBIT", "ETHUSDT"); eth.Status != "settling" {
+		t.Fatalf("ETHUSDT status %q", eth.Status)
+	}
+
+	for _, tc := range []struct {
+		size, price float64
+		ok          bool
+	}{
+		{0.003, 30000, true},
+		{0.0005, 30000, false}, // under the minimum quantity
+		{0.0015, 30000, false}, // off the lot step
+		{101, 30000, false},    // over the maximum
+		{0.001, 1000, false},   // 1 USDT of notional
+		{0.001, 0, true},       // no price, no notional check
+	} {
+		if err := btc.Check(tc.size, tc.price); (err == nil) != tc.ok || (err != nil && !errors.Is(err, instruments.ErrSpec)) {
+			t.Errorf("Check(%g, %g) = %v", tc.size, tc.price, err)
+		}
+	}
+	if btc.CheckPrice(30000.1) != nil || btc.CheckPrice(30000.15) == nil {
+		t.Fatal("tick size not enforced")
+	}
+
+	// a restart without REST access still has the last specs
+	failing = true
+	restarted := instruments.NewCache(path, instruments.NewBybit("BYBIT", srv.URL, "linear"))
+	if err := restarted.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := restarted.Refresh(context.Background()); err == nil {
+		t.Fatal("refresh against a failing venue succeeded")
+	}
+	if got := restarted.Specs(); len(got) != 3 || got[1] != btc || restarted.Fetched("BYBIT").IsZero() {
+		t.Fatalf("specs after a failed refresh = %+v", got)
+	}
+
+	// the router passes over a venue whose instrument is not trading
+	smart := router.NewSmartRouter(router.DefaultFees())
+	smart.SetInstruments(restarted.Tradable)
+	books := map[string]router.BookView{"BYBIT": {BestBid: 2000, BestAsk: 2001}, "BINANCE": {BestBid: 2000, BestAsk: 2003}}
+	if d := smart.Decide(transport.Action{Symbol: "ETHUSDT", Side: "BUY", Size: 1}, books); d.Venue != "BINANCE" {
+		t.Fatalf("ETHUSDT routed to %s, want BINANCE while BYBIT is settling", d.Venue)
+	}
+
+	// and the executor refuses orders that break the routed venue's spec
+	sender := executor.NewOrderSender(transport.NewPublisher("inproc://instruments"), router.NewSmartRouter(router.DefaultFees()))
+	sender.SetTracker(executor.NewTracker())
+	sender.SetLimits(executor.Limits{Instrument: restarted.Check})
+	views := map[string]router.BookView{"BYBIT": {BestBid: 30000, BestAsk: 30000.5}}
+	send := func(size, price float64) error {
+		_, err := sender.Send(context.Background(), transport.Action{Symbol: "BTCUSDT", Side: "BUY", Size: size, Price: price}, views)
+		return err
+	}
+	if err := send(0.0015, 0); !errors.Is(err, instruments.ErrSpec) {
+		t.Fatalf("off-step order: %v", err)
+	}
+	if err := send(0.0001, 0); !errors.Is(err, instruments.ErrSpec) {
+		t.Fatalf("order under the minimum quantity: %v", err)
+	}
+	if err := send(0.01, 30000.05); !errors.Is(err, instruments.ErrSpec) {
+		t.Fatalf("off-tick limit order: %v", err)
+	}
+	if err := send(0.01, 0); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"encoding/csv"
+	"math/rand"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/helix-lab/helix/gateway/pkg/decimal"
+	"github.com/helix-lab/helix/gateway/pkg/l2book"
+)
+
+// refSide is the map-and-scan book side the ladder replaced.
+type refSide struct {
+	bids   bool
+	levels map[decimal.Decimal]decimal.Decimal
+}
+
+func (r *refSide) set(px, sz decimal.Decimal) {
+	if sz <= 0 {
+		delete(r.levels, px)
+	} else {
+		r.levels[px] = sz
+	}
+}
+
+func (r *refSide) top(n int) []l2book.Level {
+	px := make([]decimal.Decimal, 0, len(r.levels))
+	for p := range r.levels {
+		px = append(px, p)
+	}
+	sort.Slice(px, func(i, j int) bool { return (px[i] > px[j]) == r.bids })
+	if len(px) > n {
+		px = px[:n]
+	}
+	out := make([]l2book.Level, len(px))
+	for i, p := range px {
+		out[i] = l2book.Level{Price: p.Float(), Size: r.levels[p].Float()}
+	}
+	return out
+}
+
+func sameLevels(a, b []l2book.Level) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestLadderMatchesMapReference(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	for _, bids := range []bool{true, false} {
+		l := l2book.NewLadder(bids)
+		ref := &refSide{bids: bids, levels: map[decimal.Decimal]decimal.Decimal{}}
+		for i := 0; i < 6000; i++ {
+			px := decimal.Decimal(rng.Intn(400)) * decimal.Scale / 2
+			sz := decimal.Decimal(rng.Intn(5)) * decimal.Scale / 10
+			if i%2000 == 0 {
+				l.Reset()
+				clear(ref.levels)
+			}
+			l.Set(px, sz)
+			ref.set(px, sz)
+			if l.Len() != len(ref.levels) || l.Get(px) != ref.levels[px] {
+				t.Fatalf("op %d: len %d/%d, size at %s %s/%s", i, l.Len(), len(ref.levels), px, l.Get(px), ref.levels[px])
+			}
+			want := ref.top(1)
+			if bp, bs, ok := l.Best(); ok != (len(want) == 1) || ok && (bp.Float() != want[0].Price || bs.Float() != want[0].Size) {
+				t.Fatalf("op %d: best %s x %s, want %+v", i, bp, bs, want)
+			}
+			if i%97 == 0 && !sameLevels(l.Top(25), ref.top(25)) {
+				t.Fatalf("op %d: top 25 differs", i)
+			}
+		}
+	}
+}
+
+func TestLadderBookOnRecordedData(t *testing.T) {
+	f, err := os.Open("../../data/replay/btc_l2_mini.csv")
+	if err != nil {
+		t.Skipf("recorded capture not available: %v", err)
+	}
+	defer f.Close()
+	recs, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parser l2book.Parser
+	book := l2book.New()
+	bids := &refSide{bids: true, levels: map[decimal.Decimal]decimal.Decimal{}}
+	asks := &refSide{levels: map[decimal.Decimal]decimal.Decimal{}}
+	compare := func() {
+		gb, ga := book.Depth(50)
+		if !sameLevels(gb, bids.top(50)) || !sameLevels(ga, asks.top(50)) {
+			t.Fatalf("seq %d: depth differs from map reference", book.LastSeq)
+		}
+	}
+	msgs := 0
+	for _, rec := range recs {
+		d, ok := parser.Parse(rec)
+		if !ok {
+			continue
+		}
+		if book.LastSeq >= 0 && d.Seq != book.LastSeq {
+			compare()
+			msgs++
+		}
+		if d.Snapshot && d.Seq != book.LastSeq {
+			clear(bids.levels)
+			clear(asks.levels)
+		}
+		if err := book.Update(d); err != nil {
+			t.Fatal(err)
+		}
+		side := asks
+		if d.Side == 'b' {
+			side = bids
+		}
+		side.set(decimal.FromFloat(d.Price), decimal.FromFloat(d.Qty))
+	}
+	compare()
+	if msgs == 0 {
+		t.Fatal("no messages compared")
+	}
+}
@@ -0,0 +1,184 @@
+package tests
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/latency"
+)
+
+func TestHistogramPercentiles(t *testing.T) {
+	reg := latency.NewRegistry()
+	h := reg.Histogram("route")
+	for i := 1; i <= 1000; i++ {
+		h.Record(time.Duration(i) * time.Microsecond)
+	}
+	s := h.Summary("route")
+	if s.Count != 1000 || s.Max != time.Millisecond {
+		t.Fatalf("bad count/max: %+v", s)
+	}
+	within := func(name string, got, want time.Duration) {
+		t.Helper()
+		if d := float64(got-want) / float64(want); d < -0.07 || d > 0.07 {
+			t.Fatalf("%s=%s, want ~%s", name, got, want)
+		}
+	}
+	within("p50", s.P50, 500*time.Microsecond)
+	within("p95", s.P95, 950*time.Microsecond)
+	within("p99", s.P99, 990*time.Microsecond)
+	within("mean", s.Mean, 500500*time.Nanosecond)
+}
+
+func TestProfilerRecordsIntoRegistry(t *testing.T) {
+	reg := latency.NewRegistry()
+	for i := 0; i < 3; i++ {
+		latency.StartIn(reg, "send").Stop()
+	}
+	rep := reg.Report()
+	if len(rep) != 1 || rep[0].Label != "send" || rep[0].Count != 3 {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+}
+
+func TestProfilerAggregatesByLabelSet(t *testing.T) {
+	reg := latency.NewRegistry()
+	for _, venue := range []string{"BYBIT", "BINANCE", "BYBIT"} {
+		p := latency.StartIn(reg, "route", latency.Labels{Symbol: "BTCUSDT", Stage: "route"})
+		p.Label(latency.Labels{Venue: venue})
+		p.Stop()
+	}
+	counts := map[string]uint64{}
+	for _, s := range reg.Report() {
+		if s.Labels.Symbol != "BTCUSDT" || s.Labels.Stage != "route" {
+			t.Fatalf("labels lost: %+v", s.Labels)
+		}
+		counts[s.Labels.Venue] = s.Count
+	}
+	if counts["BYBIT"] != 2 || counts["BINANCE"] != 1 || len(counts) != 2 {
+		t.Fatalf("expected per-venue series, got %v", counts)
+	}
+}
+
+func TestAsyncSinkAggregatesAndCountsOverflow(t *testing.T) {
+	reg := latency.NewRegistry()
+	stop := reg.StartAsync(4)
+	for i := 0; i < 1000; i++ {
+		latency.StartIn(reg, "tick").Stop()
+	}
+	reg.Sync()
+	var got uint64
+	for _, s := range reg.Report() {
+		got += s.Count
+	}
+	if got+reg.Overflow() != 1000 {
+		t.Fatalf("recorded %d + dropped %d != 1000", got, reg.Overflow())
+	}
+	stop()
+	latency.StartIn(reg, "tick").Stop()
+	if reg.Report()[0].Count != got+1 {
+		t.Fatalf("expected synchronous recording after stop")
+	}
+}
+
+func TestRecordTickToTradeStages(t *testing.T) {
+	reg := latency.NewRegistry()
+	recv := time.Now().UnixNano()
+	reg.RecordTickToTrade(latency.Labels{Venue: "BYBIT", Symbol: "BTCUSDT"}, latency.TickTimes{
+		ExchTsMs:    recv/int64(time.Millisecond) - 3,
+		RecvNs:      recv,
+		RoutedNs:    recv + int64(40*time.Microsecond),
+		PublishedNs: recv + int64(100*time.Microsecond),
+	})
+	got := map[string]time.Duration{}
+	for _, s := range reg.Report() {
+		if s.Label != latency.TickToTrade || s.Labels.Venue != "BYBIT" {
+			t.Fatalf("unexpected series %+v", s)
+		}
+		got[s.Labels.Stage] = s.Max
+	}
+	near := func(stage string, want time.Duration) {
+		t.Helper()
+		if d := got[stage] - want; d < -want/10 || d > want/10 {
+			t.Fatalf("%s=%s, want ~%s", stage, got[stage], want)
+		}
+	}
+	near("recv_to_route", 40*time.Microsecond)
+	near("route_to_publish", 60*time.Microsecond)
+	near("total", 100*time.Microsecond)
+	if got["exch_to_recv"] < 2*time.Millisecond || got["exch_to_recv"] > 4*time.Millisecond {
+		t.Fatalf("exch_to_recv=%s", got["exch_to_recv"])
+	}
+
+	reg = latency.NewRegistry()
+	reg.RecordTickToTrade(latency.Labels{}, latency.TickTimes{RoutedNs: recv, PublishedNs: recv + 1})
+	if rep := reg.Report(); len(rep) != 1 || rep[0].Labels.Stage != "route_to_publish" {
+		t.Fatalf("stages without a tick should be skipped: %+v", rep)
+	}
+}
+
+func TestFileReporterWritesIntervalsAndRotates(t *testing.T) {
+	dir := t.TempDir()
+	reg := latency.NewRegistry()
+	path := filepath.Join(dir, "latency.jsonl")
+	rep := latency.NewFileReporter(reg, path, time.Minute)
+	start := time.Unix(1700000000, 0)
+	rep.Mark(start)
+
+	for i := 0; i < 10; i++ {
+		reg.Series("send", latency.Labels{Venue: "BYBIT"}).Record(time.Millisecond)
+	}
+	if err := rep.Flush(start.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	reg.Series("send", latency.Labels{Venue: "BYBIT"}).Record(5 * time.Microsecond)
+	if err := rep.Flush(start.Add(2 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := rep.Flush(start.Add(3 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	rep.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("empty intervals should be skipped, got %d lines", len(lines))
+	}
+	var second latency.IntervalReport
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatal(err)
+	}
+	if len(second.Series) != 1 || second.Series[0].Count != 1 || second.Series[0].MaxNs > int64(6*time.Microsecond) {
+		t.Fatalf("second interval should only hold its own sample: %+v", second)
+	}
+
+	csvPath := filepath.Join(dir, "latency.csv")
+	rep = latency.NewFileReporter(reg, csvPath, time.Minute)
+	rep.MaxBytes = 1
+	rep.MaxFiles = 2
+	for i := 0; i < 3; i++ {
+		reg.Histogram("tick").Record(time.Microsecond)
+		if err := rep.Flush(start.Add(time.Duration(i) * time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, p := range []string{csvPath + ".1", csvPath + ".2"} {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(b), "ts,interval_s,op,") {
+			t.Fatalf("%s should start with a header: %q", p, b)
+		}
+	}
+	if _, err := os.Stat(csvPath + ".3"); !os.IsNotExist(err) {
+		t.Fatal("only MaxFiles rotated copies should be kept")
+	}
+}
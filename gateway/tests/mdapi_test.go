@@ -0,0 +1,408 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/internal/app"
+	"github.com/helix-lab/helix/gateway/internal/app/gc"
+	"github.com/helix-lab/helix/gateway/pkg/catalog"
+	"github.com/helix-lab/helix/gateway/pkg/mdapi"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+func writeCapture(t *testing.T, dir, name, body, symbol string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if symbol != "" {
+		meta := `{"version":"test/1","symbol":"` + symbol + `","endpoint":"wss://stream.bybit.com/v5/public/linear"}`
+		if err := os.WriteFile(catalog.MetaPath(path), []byte(meta), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestCatalogScan(t *testing.T) {
+	dir := t.TempDir()
+	writeCapture(t, dir, "l2.csv", btL2, "BTCUSDT")
+	writeCapture(t, dir, "trades.csv", btTrades, "BTCUSDT")
+	writeCapture(t, dir, "other.csv", "a,b\n1,2\n", "")
+	writeCapture(t, dir, "nometa.csv", "ts_ms,side,price,size\n5,Buy,1,1\n", "")
+
+	cat, err := catalog.Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cat.Datasets) != 3 {
+		t.Fatalf("datasets = %+v", cat.Datasets)
+	}
+	l2 := cat.Find(catalog.KindL2, "btcusdt", time.Time{}, time.Time{})
+	if len(l2) != 1 || l2[0].StartMs != 1000 || l2[0].EndMs != 3000 || l2[0].Venue != "BYBIT" || l2[0].Version != "test/1" {
+		t.Fatalf("l2 = %+v", l2)
+	}
+	if got := cat.Find("", "BTCUSDT", time.UnixMilli(2600), time.Time{}); len(got) != 1 || got[0].Kind != catalog.KindL2 {
+		t.Fatalf("after 2.6s = %+v, want only the L2 capture", got)
+	}
+	if got := cat.Find(catalog.KindTrades, "", time.Time{}, time.Time{}); len(got) != 2 {
+		t.Fatalf("all trades = %+v", got)
+	}
+}
+
+func TestMarketDataAPI(t *testing.T) {
+	dir := t.TempDir()
+	writeCapture(t, dir, "l2.csv", btL2, "BTCUSDT")
+	writeCapture(t, dir, "trades.csv", btTrades, "BTCUSDT")
+
+	books := orderbook.NewManager()
+	books.Apply(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 100, BestAsk: 101, BidSize: 1, AskSize: 2})
+	recent := mdapi.NewRecent(3)
+	for i := int64(1); i <= 5; i++ {
+		recent.AddTrade(transport.Trade{Venue: "BYBIT", Symbol: "BTCUSDT", Side: "BUY", Price: float64(100 + i), Qty: 1, TsMs: i * 1000})
+	}
+	recent.AddBar(transport.Bar{Venue: "BYBIT", Symbol: "BTCUSDT", Interval: time.Second, StartMs: 1000, Close: 101})
+	recent.AddBar(transport.Bar{Venue: "BYBIT", Symbol: "BTCUSDT", Interval: time.Minute, StartMs: 0, Close: 105})
+
+	srv := httptest.NewServer((&mdapi.Server{Token: "t", Books: books, Recent: recent, DataDir: dir}).Handler())
+	defer srv.Close()
+	get := func(path string, v any) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		req.Header.Set("Authorization", "Bearer t")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if v != nil && resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp.StatusCode
+	}
+
+	if resp, err := http.Get(srv.URL + "/md/v1/books"); err != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("no token: %v %v", resp, err)
+	}
+	var sb []mdapi.SymbolBooks
+	if code := get("/md/v1/books/btcusdt", &sb); code != http.StatusOK || len(sb) != 1 || sb[0].NBBO.BestAsk != 101 {
+		t.Fatalf("books %d %+v", code, sb)
+	}
+
+	var trades []mdapi.Trade
+	if code := get("/md/v1/trades?symbol=BTCUSDT&limit=2", &trades); code != http.StatusOK || len(trades) != 2 || trades[0].TsMs != 4000 || trades[1].TsMs != 5000 {
+		t.Fatalf("trades %d %+v, want the latest two oldest first", code, trades)
+	}
+	if code := get("/md/v1/trades", nil); code != http.StatusBadRequest {
+		t.Fatalf("trades without symbol = %d", code)
+	}
+	var bars []mdapi.Bar
+	if code := get("/md/v1/candles?symbol=BTCUSDT&interval=1m", &bars); code != http.StatusOK || len(bars) != 1 || bars[0].Close != 105 {
+		t.Fatalf("candles %d %+v", code, bars)
+	}
+
+	var sets []catalog.Dataset
+	if code := get("/md/v1/datasets?kind=trades", &sets); code != http.StatusOK || len(sets) != 1 || sets[0].Kind != "trades" {
+		t.Fatalf("datasets %d %+v", code, sets)
+	}
+	var ht mdapi.History[mdapi.Trade]
+	if code := get("/md/v1/history/trades?symbol=BTCUSDT&from=1000&to=3000", &ht); code != http.StatusOK || len(ht.Rows) != 2 || ht.Rows[0].Side != "BUY" || ht.Truncated {
+		t.Fatalf("history trades %d %+v", code, ht)
+	}
+	var hq mdapi.History[mdapi.Quote]
+	if code := get("/md/v1/history/quotes?symbol=BTCUSDT&limit=2", &hq); code != http.StatusOK || len(hq.Rows) != 2 || !hq.Truncated || hq.Rows[1].BestAsk != 102 {
+		t.Fatalf("history quotes %d %+v", code, hq)
+	}
+	if code := get("/md/v1/history/quotes?symbol=BTCUSDT&from=yesterday", nil); code != http.StatusBadRequest {
+		t.Fatalf("bad from = %d", code)
+	}
+}
+
+func TestCatalogValidation(t *testing.T) {
+	dir := t.TempDir()
+	good := writeCapture(t, dir, "l2.csv", btL2, "BTCUSDT")
+	bad := writeCapture(t, dir, "gappy.csv", `ts_ms,seq,prev_seq,book_side,price,size,type
+1000,1,0,bid,100,1,snapshot
+9000,2,1,bid,100,2,delta
+10000,4,3,bid,100,3,delta
+9500,5,4,ask,101,1,delta
+`, "BTCUSDT")
+
+	v, err := catalog.Validate(good, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Status != catalog.StatusOK || v.Rows != 8 || v.Gaps != 2 || v.MaxGapMs != 1000 || v.GapTotalMs != 2000 {
+		t.Fatalf("good = %+v", v)
+	}
+	if err := catalog.WriteValidation(good, v); err != nil {
+		t.Fatal(err)
+	}
+	v, err = catalog.Validate(bad, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Status != catalog.StatusFailed || v.SeqGaps != 1 || v.OutOfOrder != 1 || v.Gaps != 1 || v.MaxGapMs != 8000 || len(v.Errors) != 2 {
+		t.Fatalf("bad = %+v", v)
+	}
+	if err := catalog.WriteValidation(bad, v); err != nil {
+		t.Fatal(err)
+	}
+	writeCapture(t, dir, "trades.csv", btTrades, "BTCUSDT")
+
+	cat, err := catalog.Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := map[string]string{}
+	for _, d := range cat.Datasets {
+		status[filepath.Base(d.Path)] = d.Status
+	}
+	if status["l2.csv"] != catalog.StatusOK || status["gappy.csv"] != catalog.StatusFailed || status["trades.csv"] != catalog.StatusUnvalidated {
+		t.Fatalf("statuses = %v", status)
+	}
+	if ok := catalog.WithStatus(cat.Datasets, catalog.StatusOK); len(ok) != 1 || ok[0].Validation.Rows != 8 || ok[0].Version != "test/1" {
+		t.Fatalf("ok = %+v", ok)
+	}
+
+	// growing the file after validation makes it stale
+	f, err := os.OpenFile(good, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("4000,4,3,bid,100,1,delta\n")
+	f.Close()
+	if ds, ok, err := catalog.Inspect(good); err != nil || !ok || ds.Status != catalog.StatusStale {
+		t.Fatalf("after append = %+v %t %v", ds, ok, err)
+	}
+
+	srv := httptest.NewServer((&mdapi.Server{DataDir: dir}).Handler())
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/md/v1/datasets?status=ok,unvalidated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var sets []catalog.Dataset
+	if err := json.NewDecoder(resp.Body).Decode(&sets); err != nil {
+		t.Fatal(err)
+	}
+	if len(sets) != 1 || filepath.Base(sets[0].Path) != "trades.csv" {
+		t.Fatalf("ok or unvalidated = %+v", sets)
+	}
+}
+
+func TestCaptureChecksums(t *testing.T) {
+	dir := t.TempDir()
+	l2 := writeCapture(t, dir, "l2.csv", btL2, "BTCUSDT")
+	if _, err := catalog.Verify(l2); !errors.Is(err, catalog.ErrNoChecksum) {
+		t.Fatalf("unsealed verify err = %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "bc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	bc := filepath.Join(dir, "bc", "bookcheck.csv")
+	if err := os.WriteFile(bc, []byte("ts_ms,seq,best_bid,best_ask,bid_size,ask_size\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := catalog.Seal(l2, bc); err != nil {
+		t.Fatal(err)
+	}
+	checks, err := catalog.Verify(l2)
+	if err != nil || len(checks) != 2 || !checks[0].OK() || !checks[1].OK() || checks[0].Path != bc {
+		t.Fatalf("verify = %+v %v", checks, err)
+	}
+	ds, ok, err := catalog.Inspect(l2)
+	if err != nil || !ok || ds.Symbol != "BTCUSDT" || ds.Sha256 != checks[1].Want {
+		t.Fatalf("sealed dataset = %+v %t %v", ds, ok, err)
+	}
+
+	// a truncated copy no longer matches
+	if err := os.WriteFile(l2, []byte(btL2[:len(btL2)-10]), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := catalog.Verified(l2); err == nil || !strings.Contains(err.Error(), "mismatch") {
+		t.Fatalf("verified after truncation = %v", err)
+	}
+	os.Remove(bc)
+	if checks, _ := catalog.Verify(l2); checks[0].Err == "" {
+		t.Fatalf("missing file verified: %+v", checks[0])
+	}
+}
+
+func TestRunManifest(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "l2.csv")
+	bodies := []string{
+		"ts_ms,seq,prev_seq,book_side,price,size,type\n1000,1,0,bid,100,1,snapshot\n2000,2,1,ask,101,1,delta\n",
+		"ts_ms,seq,prev_seq,book_side,price,size,type\n3000,3,2,bid,100,2,delta\n",
+		"ts_ms,seq,prev_seq,book_side,price,size,type\n5000,5,4,bid,100,3,delta\n",
+		"ts_ms,seq,prev_seq,book_side,price,size,type\n6000,9,8,bid,100,1,snapshot\n",
+	}
+	segs := []catalog.Segment{
+		{StartMs: 1000, EndMs: 2000, FirstSeq: 1, LastSeq: 2, Snapshot: true},
+		{StartMs: 3000, EndMs: 3000, FirstPrevSeq: 2, FirstSeq: 3, LastSeq: 3},
+		{StartMs: 5000, EndMs: 5000, FirstPrevSeq: 4, FirstSeq: 5, LastSeq: 5},
+		{StartMs: 6000, EndMs: 6000, FirstPrevSeq: 8, FirstSeq: 9, LastSeq: 9, Snapshot: true},
+	}
+	m := &catalog.Manifest{Kind: catalog.KindL2, Complete: true}
+	for i, body := range bodies {
+		p := writeCapture(t, dir, filepath.Base(catalog.SegmentPath(out, i)), body, "BTCUSDT")
+		if err := catalog.Seal(p); err != nil {
+			t.Fatal(err)
+		}
+		ds, _, err := catalog.Inspect(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := segs[i]
+		s.Path, s.Bytes, s.Sha256 = filepath.Base(p), ds.Bytes, ds.Sha256
+		m.Add(s)
+	}
+	var cont []string
+	for _, s := range m.Segments {
+		cont = append(cont, s.Continuity)
+	}
+	if got := strings.Join(cont, ","); got != "first,ok,gap,resync" {
+		t.Fatalf("continuity = %s", got)
+	}
+	run := catalog.ManifestPath(out)
+	if err := catalog.WriteManifest(run, m); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := catalog.OpenRun(run, false); err == nil || !strings.Contains(err.Error(), "seq gap from 3 to 4") {
+		t.Fatalf("gapped run opened: %v", err)
+	}
+	paths, _, err := catalog.OpenRun(run, true)
+	if err != nil || len(paths) != 4 || paths[1] != filepath.Join(dir, "l2.0001.csv") {
+		t.Fatalf("run segments = %v %v", paths, err)
+	}
+
+	os.Remove(paths[3])
+	if err := os.WriteFile(paths[1], []byte(bodies[2]), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = catalog.OpenRun(run, true)
+	if err == nil || !strings.Contains(err.Error(), "segment 3 missing") || !strings.Contains(err.Error(), "segment 1 (l2.0001.csv): checksum mismatch") {
+		t.Fatalf("damaged run = %v", err)
+	}
+}
+
+func TestRetentionPlan(t *testing.T) {
+	dir := t.TempDir()
+	done := writeCapture(t, dir, "done.csv", btL2, "BTCUSDT")
+	bc := filepath.Join(dir, "done.bookcheck.csv")
+	if err := os.WriteFile(bc, []byte("ts_ms,seq,best_bid,best_ask,bid_size,ask_size\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pending := writeCapture(t, dir, "pending.csv", btL2, "BTCUSDT")
+	writeCapture(t, dir, "open.csv", btL2, "BTCUSDT")
+	if err := catalog.Seal(done, bc); err != nil {
+		t.Fatal(err)
+	}
+	if err := catalog.Seal(pending); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{done, pending} {
+		v, err := catalog.Validate(p, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := catalog.WriteValidation(p, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := catalog.MarkUploaded(done, "s3://captures/done.csv", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	cat, err := catalog.Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := catalog.Retention{KeepFor: 24 * time.Hour, Validated: true, Uploaded: true}
+	plan := catalog.PlanRetention(cat, policy, time.Now())
+	if len(plan.Remove) != 1 || plan.Remove[0].Dataset.Path != done || len(plan.Held) != 2 {
+		t.Fatalf("plan = %+v", plan)
+	}
+	held := map[string]string{}
+	for _, r := range plan.Held {
+		held[filepath.Base(r.Dataset.Path)] = r.Reason
+	}
+	if !strings.Contains(held["pending.csv"], "not uploaded") || !strings.Contains(held["open.csv"], "not sealed") {
+		t.Fatalf("held = %v", held)
+	}
+	// nothing is old yet at the time of recording
+	if p := catalog.PlanRetention(cat, policy, time.UnixMilli(3000)); len(p.Remove)+len(p.Held) != 0 {
+		t.Fatalf("fresh plan = %+v", p)
+	}
+
+	if err := catalog.Remove(plan.Remove[0].Dataset); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{done, bc, catalog.MetaPath(done), catalog.ValidationPath(done), catalog.UploadPath(done)} {
+		if _, err := os.Stat(p); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("%s left behind: %v", p, err)
+		}
+	}
+	if cat, err := catalog.Scan(dir); err != nil || len(cat.Datasets) != 2 {
+		t.Fatalf("after remove = %+v %v", cat, err)
+	}
+}
+
+func TestGCConfigWithoutRetention(t *testing.T) {
+	dir := t.TempDir()
+	checked := writeCapture(t, dir, "checked.csv", btL2, "BTCUSDT")
+	unchecked := writeCapture(t, dir, "unchecked.csv", btL2, "BTCUSDT")
+	for _, p := range []string{checked, unchecked} {
+		if err := catalog.Seal(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v, err := catalog.Validate(checked, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := catalog.WriteValidation(checked, v); err != nil {
+		t.Fatal(err)
+	}
+	cfgPath := filepath.Join(t.TempDir(), "gateway.yaml")
+	if err := os.WriteFile(cfgPath, []byte("gateway:\n  symbols: [BTCUSDT]\n  venues:\n  - name: BYBIT\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// a config without a retention section keeps the flag defaults, so the
+	// unvalidated capture stays
+	if code := gc.Main([]string{"-config", cfgPath, "-data", dir, "-keep_days", "1"}); code != app.ExitOK {
+		t.Fatalf("gc: exit %d", code)
+	}
+	if _, err := os.Stat(checked); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("validated capture kept: %v", err)
+	}
+	if _, err := os.Stat(unchecked); err != nil {
+		t.Fatalf("unvalidated capture removed: %v", err)
+	}
+
+	// one that sets validated: false does remove it
+	if err := os.WriteFile(cfgPath, []byte("gateway:\n  symbols: [BTCUSDT]\n  venues:\n  - name: BYBIT\n  recorder:\n    retention:\n      keep_days: 1\n      validated: false\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code := gc.Main([]string{"-config", cfgPath, "-data", dir}); code != app.ExitOK {
+		t.Fatalf("gc: exit %d", code)
+	}
+	if _, err := os.Stat(unchecked); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("unvalidated capture kept: %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/latency"
+	"github.com/helix-lab/helix/gateway/pkg/metrics"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/router"
+	"github.com/helix-lab/helix/gateway/pkg/strategy"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+	"github.com/helix-lab/helix/gateway/pkg/ws"
+)
+
+func TestMetricsTextExposition(t *testing.T) {
+	reg := metrics.NewRegistry()
+	reg.Counter("helix_test_total", "A counter.").Add(3)
+	reg.CounterVec("helix_test_routed_total", "Routed.", "venue").With("BYBIT").Inc()
+	reg.Gauge("helix_test_gauge", "A gauge.").Set(-1.5)
+
+	lat := latency.NewRegistry()
+	lat.Series("route", latency.Labels{Venue: "BYBIT", Stage: "route"}).Record(2 * time.Millisecond)
+	metrics.RegisterLatency(reg, lat)
+
+	srv := httptest.NewServer(reg.Handler())
+	defer srv.Close()
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	body := string(raw)
+
+	for _, want := range []string{
+		"# TYPE helix_test_total counter\nhelix_test_total 3\n",
+		`helix_test_routed_total{venue="BYBIT"} 1`,
+		"helix_test_gauge -1.5\n",
+		"# TYPE helix_latency_seconds summary\n",
+		`helix_latency_seconds{op="route",venue="BYBIT",symbol="",stage="route",quantile="0.99"} 0.002`,
+		`helix_latency_seconds_count{op="route",venue="BYBIT",symbol="",stage="route"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("missing %q in:\n%s", want, body)
+		}
+	}
+	if reg.Counter("helix_test_total", "A counter.").Value() != 3 {
+		t.Fatal("Counter must be get-or-create")
+	}
+}
+
+func TestRuntimeMetricsAndPprof(t *testing.T) {
+	reg := metrics.NewRegistry()
+	metrics.RegisterRuntime(reg)
+	runtime.GC()
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", reg.Handler())
+	metrics.HandlePprof(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	get := func(path string) string {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s: %s", path, resp.Status)
+		}
+		return string(body)
+	}
+	text := get("/metrics")
+	for _, want := range []string{"go_goroutines ", "go_memstats_heap_alloc_bytes ", `go_gc_duration_seconds{quantile="0.99"}`, "go_gc_duration_seconds_count "} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("missing %q in:\n%s", want, text)
+		}
+	}
+	if !strings.Contains(get("/debug/pprof/"), "goroutine") {
+		t.Fatal("pprof index not served")
+	}
+}
+
+func TestMetricsServerAggregatesSources(t *testing.T) {
+	books := orderbook.NewManager()
+	books.Apply(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 100, BestAsk: 100.1})
+	tracker := executor.NewTracker()
+	sender := executor.NewOrderSender(transport.NewPublisher("inproc://metrics"), router.NewSmartRouter(router.DefaultFees()))
+	sender.SetTracker(tracker)
+	host := strategy.NewHost(books, sender, tracker)
+	host.Add(strategy.Config{Name: "noop"}, strategy.Base{})
+	tracker.Open(transport.Action{Venue: "BYBIT", Symbol: "BTCUSDT", Side: "BUY", Size: 1})
+
+	reg := metrics.NewRegistry()
+	srv := metrics.NewServer(reg, false)
+	srv.Register(ws.NewRouter(), books, sender, tracker, host)
+	srv.Handle("/extra", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { io.WriteString(w, "ok") }))
+	if err := srv.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Shutdown(context.Background())
+
+	resp, err := http.Get("http://" + srv.Addr() + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	text := string(body)
+	for _, want := range []string{
+		`helix_book_best_bid{venue="BYBIT",symbol="BTCUSDT",category=""} 100`,
+		`helix_book_nbbo_spread_bps{symbol="BTCUSDT",category=""}`,
+		`helix_executor_open_orders{venue="BYBIT",symbol="BTCUSDT"} 1`,
+		"helix_executor_kill_switch_armed 0",
+		`helix_strategy_running{strategy="noop"} 1`,
+		"helix_router_backlog",
+		"go_goroutines ",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+	if resp, err := http.Get("http://" + srv.Addr() + "/extra"); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("mounted handler: %v", err)
+	}
+	if err := metrics.NewServer(reg, false).Start(srv.Addr()); err == nil {
+		t.Fatal("second server on a busy port should fail to start")
+	}
+}
@@ -0,0 +1,729 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/internal/app"
+	"github.com/helix-lab/helix/gateway/internal/app/bookcheck"
+	"github.com/helix-lab/helix/gateway/internal/app/gateway"
+	"github.com/helix-lab/helix/gateway/internal/app/l2recorder"
+	"github.com/helix-lab/helix/gateway/internal/app/syncrecord"
+	"github.com/helix-lab/helix/gateway/pkg/backtest"
+	"github.com/helix-lab/helix/gateway/pkg/capture"
+	"github.com/helix-lab/helix/gateway/pkg/catalog"
+	"github.com/helix-lab/helix/gateway/pkg/decimal"
+	"github.com/helix-lab/helix/gateway/pkg/l2book"
+	"github.com/helix-lab/helix/gateway/pkg/mockexchange"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+	"github.com/helix-lab/helix/gateway/pkg/ws"
+	"github.com/helix-lab/helix/gateway/pkg/wsclient"
+)
+
+// walkTop is the top of book after applying books.
+func walkTop(books []mockexchange.Book) (bid, ask float64) {
+	bids, asks := l2book.NewLadder(true), l2book.NewLadder(false)
+	for _, b := range books {
+		if b.Snapshot {
+			bids.Reset()
+			asks.Reset()
+		}
+		for _, side := range []struct {
+			l      *l2book.Ladder
+			levels []mockexchange.Level
+		}{{bids, b.Bids}, {asks, b.Asks}} {
+			for _, lvl := range side.levels {
+				px, _ := decimal.Parse(lvl[0])
+				sz, _ := decimal.Parse(lvl[1])
+				side.l.Set(px, sz)
+			}
+		}
+	}
+	bp, _, _ := bids.Best()
+	ap, _, _ := asks.Best()
+	return bp.Float(), ap.Float()
+}
+
+func TestMockExchangeBybitConnectorReconnects(t *testing.T) {
+	books := mockexchange.Walk("BTCUSDT", 20, 60, 1)
+	frames := mockexchange.Frames(mockexchange.Bybit, books)
+	// the first session breaks off with garbage and a dropped connection;
+	// the second starts over from a snapshot
+	first := []mockexchange.Step{}
+	for _, f := range frames[:20] {
+		first = append(first, mockexchange.Send(f))
+	}
+	first = append(first, mockexchange.Malformed(), mockexchange.Send([]byte("not json")), mockexchange.Disconnect())
+	var second []mockexchange.Step
+	for _, f := range frames {
+		second = append(second, mockexchange.Send(f))
+	}
+	venue := mockexchange.Start(mockexchange.Bybit, first, second)
+	defer venue.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stream := ws.NewBybitStream(venue.URL(), []string{"BTCUSDT"}, 20)
+	out := make(chan transport.DepthUpdate, 256)
+	go stream.Run(ctx, ws.Feeds{Depth: out})
+
+	bid, ask := walkTop(books)
+	last := books[len(books)-1].Ts
+	for {
+		select {
+		case u := <-out:
+			if u.ExchTsMs != last {
+				continue
+			}
+			if u.BestBid != bid || u.BestAsk != ask {
+				t.Fatalf("top after replay = %g/%g, want %g/%g", u.BestBid, u.BestAsk, bid, ask)
+			}
+		case <-ctx.Done():
+			t.Fatal("second session never completed")
+		}
+		break
+	}
+	if n := venue.Connections(); n != 2 {
+		t.Fatalf("%d connections, want 2", n)
+	}
+	for _, topics := range venue.Subscriptions() {
+		if len(topics) != 1 || topics[0] != "orderbook.20.BTCUSDT" {
+			t.Fatalf("subscribed %v", topics)
+		}
+	}
+}
+
+func TestMockExchangeBinanceProtocol(t *testing.T) {
+	books := mockexchange.Walk("BTCUSDT", 5, 3, 2)
+	venue := mockexchange.Start(mockexchange.Binance, mockexchange.Paced(mockexchange.Frames(mockexchange.Binance, books), 1000))
+	defer venue.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	got := make(chan string, 8)
+	client := wsclient.New(wsclient.Config{
+		Endpoint: venue.URL(),
+		Topics:   []string{"btcusdt@depth@100ms"},
+		SubscribeRequest: func(reqID string, topics []string) any {
+			return map[string]any{"method": "SUBSCRIBE", "params": topics, "id": 1}
+		},
+		OnMessage: func(frame []byte) bool {
+			got <- string(frame)
+			return true
+		},
+	})
+	go client.Run(ctx)
+
+	// the ack, then the depth updates
+	want := []string{`{"result":null,"id":1}`}
+	for _, f := range mockexchange.Frames(mockexchange.Binance, books) {
+		want = append(want, string(f))
+	}
+	for i, w := range want {
+		select {
+		case g := <-got:
+			if g != w {
+				t.Fatalf("frame %d = %s, want %s", i, g, w)
+			}
+		case <-ctx.Done():
+			t.Fatalf("frame %d never arrived", i)
+		}
+	}
+}
+
+func TestMockExchangeRecorderGapAndMalformed(t *testing.T) {
+	books := mockexchange.Walk("BTCUSDT", 50, 40, 3)
+	// seq 21 never arrives
+	books = append(books[:20:20], books[21:]...)
+	var script []mockexchange.Step
+	for i, f := range mockexchange.Frames(mockexchange.Bybit, books) {
+		if i == 10 {
+			script = append(script, mockexchange.Malformed())
+		}
+		script = append(script, mockexchange.Send(f))
+	}
+	venue := mockexchange.Start(mockexchange.Bybit, script)
+	defer venue.Close()
+
+	out := filepath.Join(t.TempDir(), "l2.csv")
+	if code := l2recorder.Main([]string{"-endpoint", venue.URL(), "-symbol", "BTCUSDT", "-depth", "50",
+		"-out", out, "-duration", "700ms"}); code != 0 {
+		t.Fatalf("record l2: exit %d", code)
+	}
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := 0
+	for _, b := range books {
+		want += len(b.Bids) + len(b.Asks)
+	}
+	if len(rows)-1 != want {
+		t.Fatalf("%d rows, want %d", len(rows)-1, want)
+	}
+	var seqs []int64
+	for _, r := range rows[1:] {
+		seq, _ := strconv.ParseInt(r[1], 10, 64)
+		if len(seqs) == 0 || seqs[len(seqs)-1] != seq {
+			seqs = append(seqs, seq)
+		}
+	}
+	for i, b := range books {
+		if len(b.Bids)+len(b.Asks) > 0 && (i >= len(seqs) || seqs[i] != b.Seq) {
+			t.Fatalf("message %d: seqs %v", i, seqs)
+		}
+	}
+}
+
+func TestMockExchangeGatewayEndToEnd(t *testing.T) {
+	books := mockexchange.Walk("BTCUSDT", 1, 50, 4)
+	frames := mockexchange.Frames(mockexchange.Bybit, books)
+	venue := mockexchange.Start(mockexchange.Bybit, mockexchange.Paced(frames, 500))
+	defer venue.Close()
+
+	archive := filepath.Join(t.TempDir(), "raw.bin")
+	code := gateway.Main([]string{"-daemon", "-bybit_symbols", "BTCUSDT", "-bybit_endpoint", venue.URL(),
+		"-archive", archive, "-feed_timeout", "600ms"})
+	if code != app.ExitFeedLost {
+		t.Fatalf("gateway: exit %d, want %d once the venue goes quiet", code, app.ExitFeedLost)
+	}
+	if venue.Sent() != len(frames) {
+		t.Fatalf("venue sent %d/%d frames", venue.Sent(), len(frames))
+	}
+	var got []string
+	err := capture.ReadFrames(archive, func(fr capture.Frame) error {
+		if len(fr.Raw) > 0 && fr.Raw[2] == 't' {
+			got = append(got, string(fr.Raw))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(frames) {
+		t.Fatalf("archived %d market frames, want %d", len(got), len(frames))
+	}
+	for i := range frames {
+		if got[i] != string(frames[i]) {
+			t.Fatalf("frame %d = %s, want %s", i, got[i], frames[i])
+		}
+	}
+}
+
+func TestL2RecorderSelfCheck(t *testing.T) {
+	record := func(books []mockexchange.Book, extra ...string) (int, map[string]any) {
+		var script []mockexchange.Step
+		for _, f := range mockexchange.Frames(mockexchange.Bybit, books) {
+			script = append(script, mockexchange.Send(f))
+		}
+		venue := mockexchange.Start(mockexchange.Bybit, script)
+		defer venue.Close()
+		out := filepath.Join(t.TempDir(), "l2.csv")
+		code := l2recorder.Main(append([]string{"-endpoint", venue.URL(), "-symbol", "BTCUSDT", "-depth", "50",
+			"-out", out, "-duration", "500ms", "-selfcheck_every", "5"}, extra...))
+		b, err := os.ReadFile(filepath.Join(filepath.Dir(out), "l2.meta.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		meta := map[string]any{}
+		if err := json.Unmarshal(b, &meta); err != nil {
+			t.Fatal(err)
+		}
+		return code, meta
+	}
+
+	if code, meta := record(mockexchange.Walk("BTCUSDT", 50, 40, 3)); code != 0 || meta["selfcheck"] != nil {
+		t.Fatalf("clean capture: exit %d, meta %v", code, meta)
+	}
+
+	// the venue crosses its own book, which bookcheck would reject offline
+	books := mockexchange.Walk("BTCUSDT", 50, 20, 3)
+	crossed := mockexchange.Book{Symbol: "BTCUSDT", Depth: 50, Seq: books[len(books)-1].Seq + 1, Ts: books[len(books)-1].Ts + 1,
+		Bids: []mockexchange.Level{{"2000.0", "1"}}}
+	books = append(books, crossed)
+	code, meta := record(books, "-selfcheck_abort")
+	if code != app.ExitFailure {
+		t.Fatalf("diverged capture: exit %d", code)
+	}
+	if note, _ := meta["selfcheck"].(string); !strings.Contains(note, "best_bid/best_ask invalid") || meta["sha256"] == nil {
+		t.Fatalf("meta = %v", meta)
+	}
+}
+
+func TestL2RecorderColumns(t *testing.T) {
+	books := mockexchange.Walk("BTCUSDT", 50, 30, 5)
+	var script []mockexchange.Step
+	for _, f := range mockexchange.Frames(mockexchange.Bybit, books) {
+		script = append(script, mockexchange.Send(f))
+	}
+	venue := mockexchange.Start(mockexchange.Bybit, script)
+	defer venue.Close()
+	dir := t.TempDir()
+	out := filepath.Join(dir, "l2.csv")
+	cols := "ts_ms,seq,book_side,price,size,type,recv_ts_ms,venue"
+	if code := l2recorder.Main([]string{"-endpoint", venue.URL(), "-symbol", "BTCUSDT", "-depth", "50",
+		"-out", out, "-duration", "500ms", "-columns", cols}); code != 0 {
+		t.Fatalf("exit %d", code)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, body, _ := strings.Cut(string(data), "\n")
+	if header != cols || !strings.Contains(body, ",BYBIT\n") {
+		t.Fatalf("csv starts %q", data[:min(len(data), 200)])
+	}
+	var meta struct{ Columns []string }
+	if b, err := os.ReadFile(filepath.Join(dir, "l2.meta.json")); err != nil || json.Unmarshal(b, &meta) != nil {
+		t.Fatalf("meta: %v", err)
+	}
+	if strings.Join(meta.Columns, ",") != cols {
+		t.Fatalf("meta columns = %v", meta.Columns)
+	}
+
+	// without prev_seq bookcheck takes messages as chained, and a copy
+	// split off without its header is read by the sidecar's columns
+	headerless := filepath.Join(dir, "part.csv")
+	if err := os.WriteFile(headerless, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sidecar, _ := os.ReadFile(filepath.Join(dir, "l2.meta.json"))
+	if err := os.WriteFile(filepath.Join(dir, "part.meta.json"), sidecar, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	wantBid, wantAsk := walkTop(books)
+	for _, in := range []string{out, headerless} {
+		check := filepath.Join(dir, "check.csv")
+		if code := bookcheck.Main([]string{"-in", in, "-out", check, "-every", "1"}); code != 0 {
+			t.Fatalf("bookcheck %s: exit %d", in, code)
+		}
+		f, err := os.Open(check)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, _ := csv.NewReader(f).ReadAll()
+		f.Close()
+		if len(rows) != len(books)+1 {
+			t.Fatalf("bookcheck %s: %d rows", in, len(rows))
+		}
+		events, err := backtest.LoadL2CSV(in, "BYBIT", "BTCUSDT")
+		if err != nil || len(events) != len(books) {
+			t.Fatalf("load %s: %d events, %v", in, len(events), err)
+		}
+		last := events[len(events)-1].Depth
+		if last.BestBid != wantBid || last.BestAsk != wantAsk || last.RecvNs <= last.ExchTsMs*1e6 {
+			t.Fatalf("load %s: last = %+v", in, last)
+		}
+	}
+
+	if code := l2recorder.Main([]string{"-endpoint", venue.URL(), "-out", out, "-columns", "ts_ms,seq,price,size,type"}); code != app.ExitUsage {
+		t.Fatalf("without book_side: exit %d", code)
+	}
+}
+
+func TestL2RecorderBBOOnly(t *testing.T) {
+	books := mockexchange.Walk("BTCUSDT", 5, 40, 7)
+	var script []mockexchange.Step
+	for _, f := range mockexchange.Frames(mockexchange.Bybit, books) {
+		script = append(script, mockexchange.Send(f))
+	}
+	venue := mockexchange.Start(mockexchange.Bybit, script)
+	defer venue.Close()
+	out := filepath.Join(t.TempDir(), "bbo.csv")
+	if code := l2recorder.Main([]string{"-endpoint", venue.URL(), "-symbol", "BTCUSDT", "-depth", "5",
+		"-out", out, "-duration", "500ms", "-bbo_only"}); code != 0 {
+		t.Fatalf("exit %d", code)
+	}
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(f).ReadAll()
+	f.Close()
+	if err != nil || len(rows) < 2 {
+		t.Fatalf("rows = %v, %v", rows, err)
+	}
+	if h := strings.Join(rows[0], ","); h != "ts_ms,seq,prev_seq,best_bid,best_ask,bid_size,ask_size,type" {
+		t.Fatalf("header = %s", h)
+	}
+
+	// a row for the snapshot and for each message that moves the top, each
+	// chained to the row before it
+	type want struct {
+		seq, prev int64
+		top       [4]float64
+	}
+	var wants []want
+	bids, asks := l2book.NewLadder(true), l2book.NewLadder(false)
+	var last [4]float64
+	for i, b := range books {
+		for _, side := range []struct {
+			l      *l2book.Ladder
+			levels []mockexchange.Level
+		}{{bids, b.Bids}, {asks, b.Asks}} {
+			for _, lvl := range side.levels {
+				px, _ := decimal.Parse(lvl[0])
+				sz, _ := decimal.Parse(lvl[1])
+				side.l.Set(px, sz)
+			}
+		}
+		bp, bs, _ := bids.Best()
+		ap, as, _ := asks.Best()
+		top := [4]float64{bp.Float(), ap.Float(), bs.Float(), as.Float()}
+		if i == 0 || top != last {
+			w := want{seq: b.Seq, top: top}
+			if len(wants) > 0 {
+				w.prev = wants[len(wants)-1].seq
+			}
+			wants = append(wants, w)
+		}
+		last = top
+	}
+	if len(wants) == len(books) {
+		t.Fatal("every message moves the top; pick another seed")
+	}
+	body := rows[1:]
+	if len(body) != len(wants) {
+		t.Fatalf("%d bbo rows, want %d", len(body), len(wants))
+	}
+	for i, row := range body {
+		w := wants[i]
+		seq, _ := strconv.ParseInt(row[1], 10, 64)
+		prev, _ := strconv.ParseInt(row[2], 10, 64)
+		var top [4]float64
+		for j := range top {
+			top[j], _ = strconv.ParseFloat(row[3+j], 64)
+		}
+		if seq != w.seq || top != w.top || (i > 0 && prev != w.prev) || prev >= seq {
+			t.Fatalf("row %d = %v, want seq %d prev_seq %d top %v", i+1, row, w.seq, w.prev, w.top)
+		}
+	}
+	if body[0][7] != "snapshot" || body[len(body)-1][7] != "delta" {
+		t.Fatalf("types = %s, %s", body[0][7], body[len(body)-1][7])
+	}
+}
+
+func TestBookcheckFormats(t *testing.T) {
+	books := mockexchange.Walk("BTCUSDT", 50, 30, 5)
+	var script []mockexchange.Step
+	for _, f := range mockexchange.Frames(mockexchange.Bybit, books) {
+		script = append(script, mockexchange.Send(f))
+	}
+	venue := mockexchange.Start(mockexchange.Bybit, script)
+	defer venue.Close()
+	dir := t.TempDir()
+	out, live := filepath.Join(dir, "l2.csv"), filepath.Join(dir, "live.csv")
+	if code := l2recorder.Main([]string{"-endpoint", venue.URL(), "-symbol", "BTCUSDT", "-depth", "50", "-out", out,
+		"-duration", "500ms", "-bookcheck", live, "-bookcheck_every", "1", "-bookcheck_format", "raw"}); code != 0 {
+		t.Fatalf("record: exit %d", code)
+	}
+	raw, fixed := filepath.Join(dir, "raw.csv"), filepath.Join(dir, "fixed.csv")
+	for path, format := range map[string]string{raw: "raw", fixed: "fixed"} {
+		if code := bookcheck.Main([]string{"-in", out, "-out", path, "-every", "1", "-format", format}); code != 0 {
+			t.Fatalf("bookcheck -format %s: exit %d", format, code)
+		}
+	}
+	// raw writes levels as quoted, not padded to -digits decimals
+	f, err := os.Open(live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, _ := csv.NewReader(f).ReadAll()
+	f.Close()
+	if len(rows) < 2 || strings.HasSuffix(rows[1][2], "00") {
+		t.Fatalf("live bookcheck not as quoted: %v", rows[:min(len(rows), 2)])
+	}
+	if code := bookcheck.Main([]string{"-compare", live, raw}); code != 0 {
+		t.Fatalf("raw vs raw: exit %d", code)
+	}
+	if code := bookcheck.Main([]string{"-compare", live, fixed}); code != 1 {
+		t.Fatalf("raw vs fixed: exit %d", code)
+	}
+	if code := bookcheck.Main([]string{"-compare", "-normalize", live, fixed}); code != 0 {
+		t.Fatalf("raw vs fixed normalized: exit %d", code)
+	}
+	if code := bookcheck.Main([]string{"-format", "exact", "-compare", live, raw}); code != app.ExitUsage {
+		t.Fatalf("unknown format: exit %d", code)
+	}
+}
+
+func TestSyncRecord(t *testing.T) {
+	var urls []string
+	for _, seed := range []int64{1, 2} {
+		var script []mockexchange.Step
+		for _, f := range mockexchange.Frames(mockexchange.Bybit, mockexchange.Walk("BTCUSDT", 1, 10, seed)) {
+			script = append(script, mockexchange.Send(f))
+		}
+		venue := mockexchange.Start(mockexchange.Bybit, script)
+		defer venue.Close()
+		urls = append(urls, venue.URL())
+	}
+	dir := t.TempDir()
+	start := time.Now().Add(200 * time.Millisecond)
+	code := syncrecord.Main([]string{"-venues", "BYBIT=" + urls[0] + ",bybit_spot=" + urls[1], "-out_dir", dir,
+		"-start_at", start.Format(time.RFC3339Nano), "-duration", "500ms"})
+	if code != 0 {
+		t.Fatalf("exit %d", code)
+	}
+	var m catalog.SyncManifest
+	if b, err := os.ReadFile(catalog.SyncManifestPath(dir, "BTCUSDT")); err != nil || json.Unmarshal(b, &m) != nil {
+		t.Fatalf("manifest: %v", err)
+	}
+	if m.StartTime != start.Format(time.RFC3339Nano) || len(m.Venues) != 2 {
+		t.Fatalf("manifest = %+v", m)
+	}
+	for _, v := range m.Venues {
+		// the mock stamps 2023 exchange times, far behind the local clock
+		if v.Exit != 0 || v.Sha256 == "" || v.Offset == nil || v.Offset.Messages != 10 || v.Offset.MinMs < 1e9 {
+			t.Fatalf("venue %+v offset %+v", v, v.Offset)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, v.Path))
+		if err != nil || !strings.Contains(string(data), ","+v.Venue+"\n") {
+			t.Fatalf("%s: %v", v.Path, err)
+		}
+	}
+	if m.Venues[1].Venue != "BYBIT_SPOT" || m.Venues[1].Path != "BYBIT_SPOT.BTCUSDT.csv" {
+		t.Fatalf("venues = %+v", m.Venues)
+	}
+
+	if code := syncrecord.Main([]string{"-venues", "BYBIT=" + urls[0], "-out_dir", dir}); code != app.ExitUsage {
+		t.Fatalf("one venue: exit %d", code)
+	}
+}
+
+func TestBinanceRecorderSnapshotSync(t *testing.T) {
+	books := mockexchange.Walk("BTCUSDT", 10, 60, 5)
+	// update 31 never arrives, so the recorder resyncs
+	sent := append(books[:30:30], books[31:]...)
+	venue := mockexchange.Start(mockexchange.Binance, mockexchange.Paced(mockexchange.Frames(mockexchange.Binance, sent), 200))
+	defer venue.Close()
+
+	// the REST depth as of update 5, then as of update 40
+	var calls atomic.Int32
+	rest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := 5
+		if calls.Add(1) > 1 {
+			n = 40
+		}
+		if r.URL.Query().Get("symbol") != "BTCUSDT" {
+			http.Error(w, "bad symbol", http.StatusBadRequest)
+			return
+		}
+		side := func(levels func(mockexchange.Book) []mockexchange.Level) [][2]string {
+			m := map[string]string{}
+			for _, b := range books[:n] {
+				for _, l := range levels(b) {
+					if l[1] == "0" {
+						delete(m, l[0])
+					} else {
+						m[l[0]] = l[1]
+					}
+				}
+			}
+			var out [][2]string
+			for px, sz := range m {
+				out = append(out, [2]string{px, sz})
+			}
+			return out
+		}
+		json.NewEncoder(w).Encode(map[string]any{"lastUpdateId": n, "E": books[n-1].Ts,
+			"bids": side(func(b mockexchange.Book) []mockexchange.Level { return b.Bids }),
+			"asks": side(func(b mockexchange.Book) []mockexchange.Level { return b.Asks })})
+	}))
+	defer rest.Close()
+
+	dir := t.TempDir()
+	out := filepath.Join(dir, "binance.csv")
+	if code := l2recorder.BinanceMain([]string{"-endpoint", venue.URL(), "-rest", rest.URL, "-symbol", "BTCUSDT",
+		"-out", out, "-duration", "600ms"}); code != app.ExitOK {
+		t.Fatalf("record binance-l2: exit %d", code)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("%d snapshots fetched, want 2", calls.Load())
+	}
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(f).ReadAll()
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(rows[0], ",") != "ts_ms,seq,prev_seq,book_side,price,size,type" {
+		t.Fatalf("header = %v", rows[0])
+	}
+	var snaps []string
+	for _, r := range rows[1:] {
+		if r[6] == "snapshot" && (len(snaps) == 0 || snaps[len(snaps)-1] != r[1]) {
+			snaps = append(snaps, r[1])
+		}
+	}
+	if fmt.Sprint(snaps) != "[5 40]" {
+		t.Fatalf("snapshots at %v", snaps)
+	}
+
+	check := filepath.Join(dir, "check.csv")
+	if code := bookcheck.Main([]string{"-in", out, "-out", check}); code != 0 {
+		t.Fatalf("bookcheck: exit %d", code)
+	}
+	events, err := backtest.LoadL2CSV(out, "BINANCE", "BTCUSDT")
+	if err != nil || len(events) == 0 {
+		t.Fatalf("load: %d events, %v", len(events), err)
+	}
+	wantBid, wantAsk := walkTop(books)
+	if last := events[len(events)-1].Depth; last.BestBid != wantBid || last.BestAsk != wantAsk {
+		t.Fatalf("last = %+v, want %g/%g", last, wantBid, wantAsk)
+	}
+	if ds, ok, err := catalog.Inspect(out); err != nil || !ok || ds.Venue != "BINANCE" {
+		t.Fatalf("catalog: %+v %t %v", ds, ok, err)
+	}
+}
+
+func TestOKXRecorderChecksum(t *testing.T) {
+	books := mockexchange.Walk("BTC-USDT-SWAP", 30, 60, 7)
+	first := mockexchange.Frames(mockexchange.OKX, books)
+	// update 31 arrives with a checksum the book does not give, so the
+	// recorder resubscribes and gets a snapshot as of update 40
+	first[30] = bytes.Replace(first[30], []byte(`"checksum":`), []byte(`"checksum":1`), 1)
+	second := mockexchange.Frames(mockexchange.OKX, append([]mockexchange.Book{mockexchange.Snapshot(books[:40])}, books[40:]...))
+	venue := mockexchange.Start(mockexchange.OKX, mockexchange.Paced(first, 200), mockexchange.Paced(second, 200))
+	defer venue.Close()
+
+	dir := t.TempDir()
+	out := filepath.Join(dir, "okx.csv")
+	if code := l2recorder.OKXMain([]string{"-endpoint", venue.URL(), "-symbol", "BTC-USDT-SWAP",
+		"-out", out, "-duration", "600ms"}); code != app.ExitOK {
+		t.Fatalf("record okx-l2: exit %d", code)
+	}
+	if subs := venue.Subscriptions(); len(subs) != 2 || venue.Connections() != 1 || subs[1][0] != "books-l2-tbt:BTC-USDT-SWAP" {
+		t.Fatalf("subscriptions %v over %d connections, want 2 over 1", subs, venue.Connections())
+	}
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(f).ReadAll()
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snaps []string
+	for _, r := range rows[1:] {
+		if r[6] == "snapshot" && (len(snaps) == 0 || snaps[len(snaps)-1] != r[1]) {
+			snaps = append(snaps, r[1])
+		}
+		if seq, _ := strconv.Atoi(r[1]); seq > 30 && seq < 40 {
+			t.Fatalf("row of update %d written after the mismatch: %v", seq, r)
+		}
+	}
+	if fmt.Sprint(snaps) != "[1 40]" {
+		t.Fatalf("snapshots at %v", snaps)
+	}
+	if v, err := catalog.Validate(out, 0); err != nil || v.Status != catalog.StatusOK {
+		t.Fatalf("validate = %+v %v", v, err)
+	}
+	events, err := backtest.LoadL2CSV(out, "OKX", "BTC-USDT-SWAP")
+	if err != nil || len(events) == 0 {
+		t.Fatalf("load: %d events, %v", len(events), err)
+	}
+	wantBid, wantAsk := walkTop(books)
+	if last := events[len(events)-1].Depth; last.BestBid != wantBid || last.BestAsk != wantAsk {
+		t.Fatalf("last = %+v, want %g/%g", last, wantBid, wantAsk)
+	}
+}
+
+func TestKrakenRecorderGap(t *testing.T) {
+	const product = "PF_XBTUSD"
+	trade := func(uid string, seq int64) []byte {
+		return []byte(fmt.Sprintf(`{"feed":"trade","product_id":%q,"uid":%q,"side":"sell","type":"fill","seq":%d,"time":%d,"qty":0.5,"price":99.8}`,
+			product, uid, seq, 1700000000000+seq))
+	}
+	tradeSnapshot := func(seqs ...int64) []byte {
+		var list []string
+		for _, seq := range seqs {
+			list = append(list, strings.TrimPrefix(string(trade(fmt.Sprintf("t%d", seq), seq)), `{"feed":"trade","product_id":"PF_XBTUSD",`))
+		}
+		return []byte(fmt.Sprintf(`{"feed":"trade_snapshot","product_id":%q,"trades":[{%s]}`, product, strings.Join(list, ",{")))
+	}
+
+	books := mockexchange.Walk(product, 20, 40, 5)
+	// frame i is book seq 1000+i; dropping frame 20 is a gap the recorder
+	// resubscribes over, to a snapshot as of book 30 at seq 30000
+	frames := mockexchange.Frames(mockexchange.Kraken, books)
+	first := [][]byte{tradeSnapshot(2, 1)}
+	first = append(first, frames[:5]...)
+	first = append(first, trade("t3", 3))
+	first = append(first, frames[5:20]...)
+	first = append(first, frames[21:]...)
+	// the resubscribe's trade snapshot holds trade 4, missed meanwhile
+	second := append([][]byte{tradeSnapshot(4, 3, 2)},
+		mockexchange.Frames(mockexchange.Kraken, append([]mockexchange.Book{mockexchange.Snapshot(books[:30])}, books[30:]...))...)
+	second = append(second, trade("t5", 5))
+	venue := mockexchange.Start(mockexchange.Kraken, mockexchange.Paced(first, 200), mockexchange.Paced(second, 200))
+	defer venue.Close()
+
+	dir := t.TempDir()
+	out, tradesOut := filepath.Join(dir, "kraken.csv"), filepath.Join(dir, "kraken_trades.csv")
+	if code := l2recorder.KrakenMain([]string{"-endpoint", venue.URL(), "-symbol", product,
+		"-out", out, "-trades_out", tradesOut, "-duration", "700ms"}); code != app.ExitOK {
+		t.Fatalf("record kraken: exit %d", code)
+	}
+	if subs := venue.Subscriptions(); len(subs) != 4 || venue.Connections() != 1 || subs[2][0] != "book:"+product {
+		t.Fatalf("subscriptions %v over %d connections, want 4 over 1", subs, venue.Connections())
+	}
+	read := func(path string) [][]string {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		rows, err := csv.NewReader(f).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rows
+	}
+	var snaps []string
+	for _, r := range read(out)[1:] {
+		if r[6] == "snapshot" && (len(snaps) == 0 || snaps[len(snaps)-1] != r[1]) {
+			snaps = append(snaps, r[1])
+		}
+		if seq, _ := strconv.Atoi(r[1]); seq >= 1020 && seq < 30000 {
+			t.Fatalf("row %v written after the gap", r)
+		}
+	}
+	if fmt.Sprint(snaps) != "[1000 30000]" {
+		t.Fatalf("snapshots at %v", snaps)
+	}
+	if v, err := catalog.Validate(out, 0); err != nil || v.Status != catalog.StatusOK {
+		t.Fatalf("validate = %+v %v", v, err)
+	}
+	if ds, ok, err := catalog.Inspect(out); err != nil || !ok || ds.Venue != "KRAKEN" {
+		t.Fatalf("catalog: %+v %t %v", ds, ok, err)
+	}
+	var ids []string
+	for _, r := range read(tradesOut)[1:] {
+		ids = append(ids, r[4])
+	}
+	if fmt.Sprint(ids) != "[t3 t4 t5]" {
+		t.Fatalf("trades %v, want history skipped and t4 backfilled once", ids)
+	}
+}
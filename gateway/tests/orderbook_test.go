@@ -1,10 +1,22 @@
 package tests
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/mdapi"
 	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/risk"
+	"github.com/helix-lab/helix/gateway/pkg/router"
+	"github.com/helix-lab/helix/gateway/pkg/strategy"
 	"github.com/helix-lab/helix/gateway/pkg/transport"
+	"github.com/helix-lab/helix/gateway/pkg/tsdb"
 )
 
 func TestOrderbookApply(t *testing.T) {
@@ -26,3 +38,202 @@ func TestOrderbookApply(t *testing.T) {
 		t.Fatalf("wrong ask: %f", level.BestAsk)
 	}
 }
+
+func TestOrderbookRankVenues(t *testing.T) {
+	mgr := orderbook.NewManager()
+	mgr.SetTakerFees(map[string]float64{"BYBIT": 0.001, "OKX": 0.002})
+	book := func(venue, category string, bid, bidSize, ask, askSize float64) {
+		mgr.Apply(transport.DepthUpdate{Venue: venue, Symbol: "BTCUSDT", Category: category, BestBid: bid, BidSize: bidSize, BestAsk: ask, AskSize: askSize})
+	}
+	book("BYBIT", "linear", 100, 1, 101, 1)
+	book("BINANCE", "linear", 99.9, 5, 101.1, 3)
+	book("OKX", "spot", 100.2, 4, 100.5, 0.5)
+	book("KRAKEN", "spot", 0, 0, 101.101, 2)
+	mgr.Apply(transport.DepthUpdate{Venue: "BYBIT", Symbol: "ETHUSDT", BestBid: 1, BestAsk: 2})
+
+	// buying 2: OKX is cheapest but thin; BYBIT's fee makes it 101.101,
+	// tying KRAKEN, which shows enough size
+	got := mgr.RankVenues("BTCUSDT", "BUY", 2)
+	want := []string{"OKX", "BINANCE", "KRAKEN", "BYBIT"}
+	if len(got) != len(want) {
+		t.Fatalf("ranks = %+v", got)
+	}
+	for i, r := range got {
+		if r.Venue != want[i] {
+			t.Fatalf("rank %d = %s, want %s: %+v", i, r.Venue, want[i], got)
+		}
+	}
+	if r := got[0]; r.Price != 100.5 || r.Effective != 100.5*1.002 || r.Size != 0.5 || r.Sufficient || r.Category != "spot" {
+		t.Fatalf("OKX = %+v", r)
+	}
+	if !got[1].Sufficient || !got[2].Sufficient || got[3].Sufficient {
+		t.Fatalf("sufficient flags = %+v", got)
+	}
+
+	// selling leaves out KRAKEN's empty bid and ranks highest first
+	got = mgr.RankVenues("BTCUSDT", "SELL", 1)
+	if len(got) != 3 || got[0].Venue != "OKX" || got[1].Venue != "BINANCE" || got[2].Venue != "BYBIT" || got[2].Effective != 100*0.999 {
+		t.Fatalf("sell ranks = %+v", got)
+	}
+	if got := mgr.RankVenues("SOLUSDT", "BUY", 1); len(got) != 0 {
+		t.Fatalf("unknown symbol ranks = %+v", got)
+	}
+}
+
+func TestOrderbookCategories(t *testing.T) {
+	mgr := orderbook.NewManager()
+	mgr.Apply(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", Category: "linear", BestBid: 30000, BestAsk: 30001})
+	mgr.Apply(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", Category: "spot", BestBid: 29990, BestAsk: 29991})
+	mgr.Apply(transport.DepthUpdate{Venue: "BINANCE", Symbol: "BTCUSDT", Category: "spot", BestBid: 29995, BestAsk: 29996})
+
+	if got := mgr.MarketSnapshot("BTCUSDT", "linear"); len(got) != 1 || got["BYBIT"].BestAsk != 30001 {
+		t.Fatalf("linear books = %+v", got)
+	}
+	if got := mgr.MarketSnapshot("BTCUSDT", "spot"); len(got) != 2 || got["BYBIT"].BestAsk != 29991 {
+		t.Fatalf("spot books = %+v", got)
+	}
+	if got := mgr.Categories("BTCUSDT"); len(got) != 2 || got[0] != "linear" || got[1] != "spot" {
+		t.Fatalf("categories = %v", got)
+	}
+	books := mgr.Books()
+	if len(books) != 3 || books[1].Venue != "BYBIT" || books[1].Category != "linear" || books[2].Category != "spot" {
+		t.Fatalf("books = %+v", books)
+	}
+
+	// an action for one market never routes to a book in another
+	smart := router.NewSmartRouter(router.DefaultFees())
+	views := map[string]router.BookView{
+		"BYBIT":   {BestBid: 30000, BestAsk: 30001, Category: "linear"},
+		"BINANCE": {BestBid: 29995, BestAsk: 29996, Category: "spot"},
+	}
+	if d := smart.Decide(transport.Action{Symbol: "BTCUSDT", Side: "BUY", Size: 1, Category: "linear"}, views); d.Venue != "BYBIT" {
+		t.Fatalf("linear buy routed to %s", d.Venue)
+	}
+	if d := smart.Decide(transport.Action{Symbol: "BTCUSDT", Side: "BUY", Size: 1}, views); d.Venue != "BINANCE" {
+		t.Fatalf("uncategorised buy routed to %s", d.Venue)
+	}
+
+	// and the category follows the order to its fills, priced off its own
+	// market's book
+	buy := transport.Action{ID: "o1", Symbol: "BTCUSDT", Side: "BUY", Size: 1, Venue: "BYBIT", Category: "linear"}
+	if o := executor.NewTracker().Open(buy); o.Category != "linear" {
+		t.Fatalf("order category %q", o.Category)
+	}
+	paper := executor.NewPaper(mgr)
+	if err := paper.Submit(context.Background(), buy); err != nil {
+		t.Fatal(err)
+	}
+	fills := paper.TakeFills()
+	if len(fills) != 1 || fills[0].Category != "linear" || fills[0].Price != 30001 {
+		t.Fatalf("fills = %+v", fills)
+	}
+}
+
+type bookRecorder struct {
+	strategy.Base
+	books []strategy.Book
+}
+
+func (r *bookRecorder) OnBook(_ context.Context, _ strategy.Handle, b strategy.Book) {
+	r.books = append(r.books, b)
+}
+
+// A symbol's spot and perpetual books trade at different prices; every
+// consumer of the books keeps them apart.
+func TestOrderbookMarketsApart(t *testing.T) {
+	books := orderbook.NewManager()
+	spot := transport.DepthUpdate{Venue: "BYBIT_SPOT", Symbol: "BTCUSDT", Category: "spot", BestBid: 29990, BestAsk: 29992, BidSize: 1, AskSize: 1}
+	perp := transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", Category: "linear", BestBid: 30100, BestAsk: 30102, BidSize: 1, AskSize: 1}
+	rec := &bookRecorder{}
+	host := strategy.NewHost(books, nil, nil)
+	host.Add(strategy.Config{Name: "rec"}, rec)
+	for _, u := range []transport.DepthUpdate{spot, perp} {
+		books.Apply(u)
+		host.Book(context.Background(), u)
+	}
+
+	// strategies see one market per book
+	if len(rec.books) != 2 {
+		t.Fatalf("books = %+v", rec.books)
+	}
+	for i, want := range []transport.DepthUpdate{spot, perp} {
+		b := rec.books[i]
+		if b.Category != want.Category || b.NBBO.BestBid != want.BestBid || b.NBBO.BestAsk != want.BestAsk || len(b.Venues) != 1 {
+			t.Fatalf("%s book = %+v", want.Category, b)
+		}
+	}
+	h, _ := host.Handle("rec")
+	if b, ok := h.Book("BTCUSDT", "spot"); !ok || b.NBBO.BestAsk != 29992 {
+		t.Fatalf("spot book = %+v, %t", b, ok)
+	}
+	if b, ok := h.Book("BTCUSDT", ""); !ok || b.Category != "linear" {
+		t.Fatalf("latest book = %+v, %t", b, ok)
+	}
+
+	// positions mark on their own venue's market
+	marks := risk.BookMarks(books)
+	if px, ok := marks("BYBIT_SPOT", "BTCUSDT"); !ok || px != 29991 {
+		t.Fatalf("spot mark = %g, %t", px, ok)
+	}
+	if px, ok := marks("BYBIT", "BTCUSDT"); !ok || px != 30101 {
+		t.Fatalf("perp mark = %g, %t", px, ok)
+	}
+	if _, ok := marks("BINANCE", "BTCUSDT"); ok {
+		t.Fatal("marked a venue with no book in a symbol with two markets")
+	}
+
+	// the market data API answers one entry per market
+	srv := httptest.NewServer((&mdapi.Server{Books: books}).Handler())
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/md/v1/books/BTCUSDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb []mdapi.SymbolBooks
+	err = json.NewDecoder(resp.Body).Decode(&sb)
+	resp.Body.Close()
+	if err != nil || len(sb) != 2 || sb[0].Category != "linear" || sb[0].NBBO.BestBid != 30100 ||
+		sb[1].Category != "spot" || sb[1].NBBO.BestBid != 29990 {
+		t.Fatalf("books = %+v, %v", sb, err)
+	}
+
+	// and the time series NBBO is per market, tagged with it
+	nbbo := map[string]float64{}
+	for _, p := range tsdb.Books(books)(time.Unix(1, 0)) {
+		if p.Tags["venue"] == "NBBO" {
+			nbbo[p.Tags["category"]] = p.Fields["bid"]
+		}
+	}
+	if len(nbbo) != 2 || nbbo["spot"] != 29990 || nbbo["linear"] != 30100 {
+		t.Fatalf("tsdb nbbo bids = %v", nbbo)
+	}
+}
+
+func TestOrderbookNBBO(t *testing.T) {
+	books := orderbook.NewManager()
+	nbbo := orderbook.NewNBBOTracker(books)
+	update := func(u transport.DepthUpdate) (transport.NBBO, bool) {
+		books.Apply(u)
+		return nbbo.Update(u)
+	}
+	if n, ok := update(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 100, BestAsk: 101, BidSize: 1, AskSize: 2, ExchTsMs: 7}); !ok ||
+		n.BestBid != 100 || n.BidVenues[0] != "BYBIT" || n.ExchTsMs != 7 {
+		t.Fatalf("first = %+v, %t", n, ok)
+	}
+	// BINANCE joins the bid and improves the offer
+	n, ok := update(transport.DepthUpdate{Venue: "BINANCE", Symbol: "BTCUSDT", BestBid: 100, BestAsk: 100.5, BidSize: 3, AskSize: 1})
+	if !ok || n.BidSize != 4 || fmt.Sprint(n.BidVenues) != "[BINANCE BYBIT]" || n.BestAsk != 100.5 || fmt.Sprint(n.AskVenues) != "[BINANCE]" {
+		t.Fatalf("merged = %+v, %t", n, ok)
+	}
+	// a move behind the touch leaves the NBBO as it was
+	if _, ok := update(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 100, BestAsk: 102, BidSize: 1, AskSize: 2}); ok {
+		t.Fatal("unchanged NBBO reported")
+	}
+	// other markets are consolidated apart
+	if n, ok := update(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", Category: "spot", BestBid: 99, BestAsk: 99.5, BidSize: 1, AskSize: 1}); !ok || n.BestBid != 99 || n.Category != "spot" {
+		t.Fatalf("spot = %+v, %t", n, ok)
+	}
+	if last, _ := nbbo.Last("BTCUSDT", ""); last.BestAsk != 100.5 {
+		t.Fatalf("last = %+v", last)
+	}
+}
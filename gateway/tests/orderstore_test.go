@@ -0,0 +1,403 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/orderstore"
+	"github.com/helix-lab/helix/gateway/pkg/router"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+// recDB is a database/sql driver that records statements and answers
+// queries with canned rows.
+type recDB struct {
+	mu      sync.Mutex
+	execs   []recExec
+	commits int
+	rows    map[string][][]driver.Value // by table name in the query
+}
+
+type recExec struct {
+	query string
+	args  []driver.Value
+}
+
+func (d *recDB) Open(string) (driver.Conn, error) { return recConn{d}, nil }
+
+func (d *recDB) statements() []recExec {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]recExec(nil), d.execs...)
+}
+
+type recConn struct{ d *recDB }
+
+func (c recConn) Prepare(q string) (driver.Stmt, error) { return recStmt{c.d, q}, nil }
+func (c recConn) Close() error                          { return nil }
+func (c recConn) Begin() (driver.Tx, error)             { return recTx{c.d}, nil }
+
+type recTx struct{ d *recDB }
+
+func (t recTx) Commit() error {
+	t.d.mu.Lock()
+	t.d.commits++
+	t.d.mu.Unlock()
+	return nil
+}
+func (t recTx) Rollback() error { return nil }
+
+type recStmt struct {
+	d *recDB
+	q string
+}
+
+func (s recStmt) Close() error  { return nil }
+func (s recStmt) NumInput() int { return -1 }
+
+func (s recStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.execs = append(s.d.execs, recExec{query: s.q, args: args})
+	return driver.RowsAffected(1), nil
+}
+
+func (s recStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.execs = append(s.d.execs, recExec{query: s.q, args: args})
+	for table, rows := range s.d.rows {
+		if strings.Contains(s.q, " FROM "+table) {
+			return &recRows{cols: strings.Count(strings.SplitN(s.q, " FROM ", 2)[0], ",") + 1, rows: rows}, nil
+		}
+	}
+	return nil, errors.New("no rows for " + s.q)
+}
+
+type recRows struct {
+	cols int
+	rows [][]driver.Value
+}
+
+func (r *recRows) Columns() []string { return make([]string, r.cols) }
+func (r *recRows) Close() error      { return nil }
+func (r *recRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+var recDriver = &recDB{}
+
+func init() { sql.Register("helixrec", recDriver) }
+
+func TestOrderStoreJournal(t *testing.T) {
+	recDriver.mu.Lock()
+	recDriver.execs, recDriver.commits = nil, 0
+	recDriver.mu.Unlock()
+	db, err := sql.Open("helixrec", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := orderstore.New(db, orderstore.Config{Driver: "postgres", FlushEvery: time.Hour})
+	if err := store.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		store.Run()
+	}()
+
+	tracker := executor.NewTracker()
+	tracker.SetJournal(store)
+	sender := executor.NewOrderSender(transport.NewPublisher("test"), router.NewSmartRouter(router.FeeModel{}))
+	sender.SetTracker(tracker)
+	sender.SetJournal(store)
+	sender.SetLimits(executor.Limits{MaxOrderSize: func(string, string) float64 { return 1 }})
+	books := map[string]router.BookView{"BYBIT": {BestBid: 100, BestAsk: 101}}
+	sent, err := sender.Send(context.Background(), transport.Action{Symbol: "BTCUSDT", Side: "BUY", Size: 1}, books)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sender.Send(context.Background(), transport.Action{Symbol: "BTCUSDT", Side: "BUY", Size: 5}, books); !errors.Is(err, executor.ErrOrderSize) {
+		t.Fatalf("err = %v", err)
+	}
+	if err := tracker.Fill(sent.ID, 101, 1); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+	<-done
+
+	var tables []string
+	var refused []string
+	for _, e := range recDriver.statements() {
+		switch {
+		case strings.HasPrefix(e.query, "CREATE TABLE"):
+			tables = append(tables, strings.Fields(e.query)[5])
+		case strings.HasPrefix(e.query, "INSERT INTO helix_routes"):
+			if !strings.Contains(e.query, "$10") {
+				t.Fatalf("placeholders not rebound for postgres: %s", e.query)
+			}
+			refused = append(refused, e.args[9].(string))
+		}
+	}
+	if strings.Join(tables, " ") != "helix_orders helix_fills helix_positions helix_routes" {
+		t.Fatalf("tables = %v", tables)
+	}
+	if len(refused) != 2 || refused[0] != "" || !strings.Contains(refused[1], "order size over limit") {
+		t.Fatalf("route audit = %q, want the sent and the refused decision", refused)
+	}
+
+	var kinds []string
+	for _, e := range recDriver.statements() {
+		if strings.HasPrefix(e.query, "INSERT INTO ") {
+			kinds = append(kinds, strings.TrimPrefix(strings.Fields(e.query)[2], "helix_"))
+		}
+	}
+	// open, route, refused route, fill: order update, fill, position
+	if got := strings.Join(kinds, " "); got != "orders routes routes orders fills positions" {
+		t.Fatalf("writes = %s", got)
+	}
+	if recDriver.commits != 1 {
+		t.Fatalf("commits = %d, want one batch flushed on Close", recDriver.commits)
+	}
+}
+
+func TestOrderStoreQueries(t *testing.T) {
+	recDriver.mu.Lock()
+	recDriver.execs = nil
+	recDriver.rows = map[string][][]driver.Value{
+		"helix_fills": {
+			{"BYBIT", "BTCUSDT", "BUY", int64(2), 1.5, 150.75},
+		},
+		"helix_routes": {
+			{int64(5), "hx-1", "BTCUSDT", "BUY", 1.0, "BYBIT", 101.0, `{"BYBIT":101,"BINANCE":101.2}`, int64(0), "", "maker"},
+		},
+	}
+	recDriver.mu.Unlock()
+	db, _ := sql.Open("helixrec", "")
+	store := orderstore.New(db, orderstore.Config{Driver: "sqlite"})
+
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	totals, err := store.Totals(context.Background(), orderstore.Query{Symbol: "BTCUSDT", From: day, To: day.AddDate(0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(totals) != 1 || totals[0].Fills != 2 || totals[0].AvgPrice() != 100.5 {
+		t.Fatalf("totals = %+v", totals)
+	}
+	q := recDriver.statements()[0]
+	if !strings.Contains(q.query, "WHERE symbol = ? AND ts_ns >= ? AND ts_ns < ?") || q.args[1] != day.UnixNano() {
+		t.Fatalf("query = %s %v", q.query, q.args)
+	}
+
+	routes, err := store.Routes(context.Background(), orderstore.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 1 || routes[0].OrderID != "hx-1" || routes[0].Prices["BINANCE"] != 101.2 || routes[0].DryRun || routes[0].Account != "maker" {
+		t.Fatalf("routes = %+v", routes)
+	}
+}
+
+func TestOrderStoreTestnet(t *testing.T) {
+	recDriver.mu.Lock()
+	recDriver.execs = nil
+	recDriver.rows = map[string][][]driver.Value{"helix_positions": {{"BYBIT", "BTCUSDT", 1.0, 100.0, 0.0}}}
+	recDriver.mu.Unlock()
+	db, _ := sql.Open("helixrec", "")
+	store := orderstore.New(db, orderstore.Config{Driver: "sqlite", Testnet: true})
+	if err := store.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		store.Run()
+	}()
+
+	tracker := executor.NewTracker()
+	tracker.SetJournal(store)
+	sink := &countingSink{}
+	sender := executor.NewOrderSender(transport.NewPublisher("test"), router.NewSmartRouter(router.FeeModel{}))
+	sender.SetSink(sink)
+	sender.SetTracker(tracker)
+	sender.SetJournal(store)
+	sender.SetTestnet(true)
+	books := map[string]router.BookView{"BYBIT": {BestBid: 100, BestAsk: 101}}
+	a, err := sender.Send(context.Background(), transport.Action{Symbol: "BTCUSDT", Side: "BUY", Size: 1}, books)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sink.n != 1 || !sink.last.Testnet {
+		t.Fatalf("sent %+v, want the action marked testnet", sink.last)
+	}
+	if err := tracker.Fill(a.ID, 101, 1); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+	<-done
+	if _, err := store.Positions(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var altered, inserts int
+	for _, e := range recDriver.statements() {
+		switch {
+		case strings.HasPrefix(e.query, "ALTER TABLE"):
+			altered++
+		case strings.HasPrefix(e.query, "INSERT INTO "):
+			inserts++
+			if e.args[len(e.args)-1] != int64(1) {
+				t.Errorf("not tagged testnet: %s %v", e.query, e.args)
+			}
+		case strings.HasPrefix(e.query, "SELECT venue, symbol, qty"):
+			if !strings.Contains(e.query, "WHERE testnet = ?") || e.args[0] != int64(1) {
+				t.Errorf("positions read %s %v", e.query, e.args)
+			}
+		}
+	}
+	// the canned driver only answers the positions column probe: three
+	// tables get testnet, orders remaining and fills, routes account
+	if altered != 6 || inserts != 5 {
+		t.Fatalf("altered %d tables, %d inserts", altered, inserts)
+	}
+}
+
+func TestOrderStoreFullQueue(t *testing.T) {
+	recDriver.mu.Lock()
+	recDriver.execs = nil
+	recDriver.mu.Unlock()
+	db, _ := sql.Open("helixrec", "")
+	d := transport.RouteDecision{OrderID: "hx-1", Symbol: "BTCUSDT", Side: "BUY", Size: 1}
+
+	// nothing drains the queue: the second write waits out Wait, then drops
+	store := orderstore.New(db, orderstore.Config{Driver: "sqlite", Buffer: 1, Wait: 20 * time.Millisecond})
+	store.Route(d, nil)
+	start := time.Now()
+	store.Route(d, nil)
+	if waited := time.Since(start); waited < 20*time.Millisecond {
+		t.Fatalf("full queue returned after %v, want it to wait", waited)
+	}
+	if store.Dropped() != 1 {
+		t.Fatalf("dropped = %d, want 1", store.Dropped())
+	}
+
+	// a write blocked on the full queue lands once Run makes room
+	store = orderstore.New(db, orderstore.Config{Driver: "sqlite", Buffer: 1, Wait: time.Hour})
+	store.Route(d, nil)
+	queued := make(chan struct{})
+	go func() {
+		defer close(queued)
+		store.Route(d, nil)
+	}()
+	select {
+	case <-queued:
+		t.Fatal("write did not wait for room")
+	case <-time.After(20 * time.Millisecond):
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		store.Run()
+	}()
+	<-queued
+	store.Close()
+	<-done
+	inserts := 0
+	for _, e := range recDriver.statements() {
+		if strings.HasPrefix(e.query, "INSERT INTO helix_routes") {
+			inserts++
+		}
+	}
+	if inserts != 2 || store.Dropped() != 0 {
+		t.Fatalf("inserts = %d, dropped = %d; want both writes applied", inserts, store.Dropped())
+	}
+
+	// the trading path's default wait is short, and Close does not
+	// deadlock behind a waiting write
+	store = orderstore.New(db, orderstore.Config{Driver: "sqlite", Buffer: 1})
+	store.Route(d, nil)
+	start = time.Now()
+	store.Route(d, nil)
+	if waited := time.Since(start); waited > 100*time.Millisecond || store.Dropped() != 1 {
+		t.Fatalf("default wait %v, dropped %d", waited, store.Dropped())
+	}
+	go store.Route(d, nil)
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		store.Close()
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked behind a waiting write")
+	}
+	store.Route(d, nil)
+}
+
+func TestOrderStoreSQLite(t *testing.T) {
+	// the gateway links the sqlite and pgx drivers for -store_driver
+	drivers := strings.Join(sql.Drivers(), ",")
+	if !strings.Contains(drivers, "sqlite") || !strings.Contains(drivers, "pgx") {
+		t.Fatalf("drivers = %s", drivers)
+	}
+	store, err := orderstore.Open(orderstore.Config{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "orders.db"), FlushEvery: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.CloseDB()
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := store.Migrate(ctx); err != nil {
+			t.Fatalf("migrate %d: %v", i+1, err)
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		store.Run()
+	}()
+	at := time.Unix(1700000000, 0)
+	o := executor.Order{ID: "hx-1", Venue: "BYBIT", Symbol: "BTCUSDT", Side: "BUY", Size: 2, Status: executor.StatusOpen, CreatedAt: at, UpdatedAt: at}
+	store.Order(o)
+	o.Filled, o.AvgPrice, o.Fills, o.UpdatedAt = 1, 101, 1, at.Add(time.Second)
+	store.Order(o)
+	store.Fill(transport.Fill{OrderID: "hx-1", Venue: "BYBIT", Symbol: "BTCUSDT", Side: "BUY", Price: 101, Qty: 1},
+		executor.Position{Venue: "BYBIT", Symbol: "BTCUSDT", Qty: 1, AvgPrice: 101}, at.Add(time.Second))
+	store.Route(transport.RouteDecision{TsNs: at.UnixNano(), OrderID: "hx-1", Symbol: "BTCUSDT", Side: "BUY", Size: 2, Venue: "BYBIT",
+		Price: 101, Prices: map[string]float64{"BYBIT": 101}}, nil)
+	store.Close()
+	<-done
+
+	orders, err := store.Orders(ctx, orderstore.Query{})
+	if err != nil || len(orders) != 1 || orders[0].Filled != 1 || orders[0].Fills != 1 || !orders[0].CreatedAt.Equal(at) {
+		t.Fatalf("orders = %+v, %v", orders, err)
+	}
+	totals, err := store.Totals(ctx, orderstore.Query{Symbol: "BTCUSDT"})
+	if err != nil || len(totals) != 1 || totals[0].AvgPrice() != 101 {
+		t.Fatalf("totals = %+v, %v", totals, err)
+	}
+	positions, err := store.Positions(ctx)
+	if err != nil || len(positions) != 1 || positions[0].Qty != 1 {
+		t.Fatalf("positions = %+v, %v", positions, err)
+	}
+	routes, err := store.Routes(ctx, orderstore.Query{})
+	if err != nil || len(routes) != 1 || routes[0].Prices["BYBIT"] != 101 {
+		t.Fatalf("routes = %+v, %v", routes, err)
+	}
+}
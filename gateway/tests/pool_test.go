@@ -0,0 +1,196 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"nhooyr.io/websocket"
+
+	"github.com/helix-lab/helix/gateway/internal/app/l2recorder"
+	"github.com/helix-lab/helix/gateway/pkg/capture"
+)
+
+// l2Frames is a depth-50 snapshot followed by n-1 deltas moving k levels a
+// side, with seq 1..n.
+func l2Frames(n, k int) [][]byte {
+	frames := make([][]byte, n)
+	for i := range frames {
+		typ, levels := "delta", k
+		if i == 0 {
+			typ, levels = "snapshot", 50
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, `{"topic":"orderbook.50.BTCUSDT","type":"%s","ts":%d,"data":{"s":"BTCUSDT","b":[`, typ, 1700000000000+int64(i))
+		for j := 0; j < levels; j++ {
+			if j > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(&b, `["%.1f","%.3f"]`, 65000-float64(j)/2, float64((i+j)%7))
+		}
+		b.WriteString(`],"a":[`)
+		for j := 0; j < levels; j++ {
+			if j > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(&b, `["%.1f","%.3f"]`, 65000.5+float64(j)/2, float64((i+2*j)%5))
+		}
+		fmt.Fprintf(&b, `],"u":%d,"seq":%d}}`, i+1, i+1)
+		frames[i] = []byte(b.String())
+	}
+	return frames
+}
+
+// recordL2 serves frames at rate messages a second (0 = as fast as the
+// recorder reads) to "helix record l2" and returns its CSV.
+func recordL2(tb testing.TB, frames [][]byte, rate int) string {
+	mock := &mockVenue{}
+	mock.onSession = func(ctx context.Context, c *websocket.Conn, n int) {
+		if n > 1 {
+			<-ctx.Done()
+			return
+		}
+		start := time.Now()
+		for i, f := range frames {
+			if rate > 0 && i%100 == 0 {
+				time.Sleep(time.Until(start.Add(time.Duration(i) * time.Second / time.Duration(rate))))
+			}
+			if c.Write(ctx, websocket.MessageText, f) != nil {
+				return
+			}
+		}
+		<-ctx.Done()
+	}
+	srv := httptest.NewServer(mock)
+	defer srv.Close()
+
+	out := filepath.Join(tb.TempDir(), "l2.csv")
+	d := 500 * time.Millisecond
+	if rate > 0 {
+		d += time.Duration(len(frames)) * time.Second / time.Duration(rate)
+	}
+	code := l2recorder.Main([]string{"-endpoint", wsURL(srv), "-symbol", "BTCUSDT", "-depth", "50",
+		"-out", out, "-duration", d.String()})
+	if code != 0 {
+		tb.Fatalf("record l2: exit %d", code)
+	}
+	return out
+}
+
+func TestL2RecorderBatchedRows(t *testing.T) {
+	frames := l2Frames(300, 5)
+	out := recordL2(t, frames, 0)
+
+	var want []string
+	for i, f := range frames {
+		var msg struct {
+			Type string `json:"type"`
+			Ts   int64  `json:"ts"`
+			Data struct {
+				B, A [][]string
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(f, &msg); err != nil {
+			t.Fatal(err)
+		}
+		for _, side := range []struct {
+			name   string
+			levels [][]string
+		}{{"bid", msg.Data.B}, {"ask", msg.Data.A}} {
+			for _, l := range side.levels {
+				want = append(want, fmt.Sprintf("%d,%d,%d,%s,%s,%s,%s", msg.Ts, i+1, i, side.name, l[0], l[1], msg.Type))
+			}
+		}
+	}
+
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Scan()
+	if sc.Text() != "ts_ms,seq,prev_seq,book_side,price,size,type" {
+		t.Fatalf("header %q", sc.Text())
+	}
+	var got []string
+	for sc.Scan() {
+		got = append(got, sc.Text())
+	}
+	if len(got) != len(want) {
+		t.Fatalf("%d rows, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("row %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCaptureTapCopiesFrame(t *testing.T) {
+	dir := t.TempDir()
+	a, err := capture.OpenArchive(filepath.Join(dir, "raw.bin"), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := capture.OpenFrameLog(filepath.Join(dir, "tap.jsonl"), 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the connection reuses its read buffer once the tap returns
+	buf := []byte(`{"n":0}`)
+	archiveTap, logTap := a.Tap("BYBIT"), l.Tap("BYBIT")
+	for i := 0; i < 100; i++ {
+		buf[5] = byte('0' + i%10)
+		archiveTap(int64(i), buf)
+		logTap(int64(i), buf)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+
+	for _, path := range []string{"raw.bin", "tap.jsonl"} {
+		i := 0
+		err := capture.ReadFrames(filepath.Join(dir, path), func(fr capture.Frame) error {
+			if want := fmt.Sprintf(`{"n":%d}`, i%10); string(fr.Raw) != want {
+				return fmt.Errorf("frame %d = %s, want %s", i, fr.Raw, want)
+			}
+			i++
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if path == "raw.bin" && i != 100 {
+			t.Fatalf("%s: %d frames", path, i)
+		}
+	}
+}
+
+// BenchmarkL2RecorderSustained feeds the recorder depth-50 deltas at
+// 50k msg/s and reports the heap allocations and collections per message,
+// the mock venue's writes included.
+func BenchmarkL2RecorderSustained(b *testing.B) {
+	const rate = 50000
+	frames := l2Frames(max(b.N, 2), 5)
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	b.ResetTimer()
+	recordL2(b, frames, rate)
+	b.StopTimer()
+	runtime.ReadMemStats(&after)
+	n := float64(len(frames))
+	b.ReportMetric(float64(after.Mallocs-before.Mallocs)/n, "allocs/msg")
+	b.ReportMetric(float64(after.TotalAlloc-before.TotalAlloc)/n, "B/msg")
+	b.ReportMetric(float64(after.NumGC-before.NumGC)*rate/n, "gc/s")
+}
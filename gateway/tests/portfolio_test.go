@@ -0,0 +1,175 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/helix-lab/helix/gateway/pkg/admin"
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/portfolio"
+	"github.com/helix-lab/helix/gateway/pkg/router"
+	"github.com/helix-lab/helix/gateway/pkg/secrets"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+func fakeBybitAccount(t *testing.T, failing *bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := r.Header
+		want := portfolio.BybitSign("s3cret", h.Get("X-BAPI-TIMESTAMP")+"key"+h.Get("X-BAPI-RECV-WINDOW")+r.URL.RawQuery)
+		if h.Get("X-BAPI-API-KEY") != "key" || h.Get("X-BAPI-SIGN") != want {
+			t.Errorf("%s: bad signature headers %v", r.URL.Path, h)
+		}
+		if *failing {
+			fmt.Fprint(w, `{"retCode":10003,"retMsg":"API key is invalid."}`)
+			return
+		}
+		switch r.URL.Path {
+		case "/v5/account/wallet-balance":
+			fmt.Fprint(w, `{"retCode":0,"result":{"list":[{"coin":[
+				{"coin":"USDT","walletBalance":"1000","locked":"100"},
+				{"coin":"BTC","walletBalance":"0.5","locked":"0"}]}]}}`)
+		case "/v5/position/list":
+			if r.URL.Query().Get("settleCoin") != "USDT" {
+				t.Errorf("positions query %s", r.URL.RawQuery)
+			}
+			fmt.Fprint(w, `{"retCode":0,"result":{"list":[
+				{"symbol":"BTCUSDT","side":"Buy","size":"0.2","avgPrice":"30000","cumRealisedPnl":"5"},
+				{"symbol":"ETHUSDT","side":"","size":"0","avgPrice":"0"}]}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestPortfolio(t *testing.T) {
+	failing := false
+	srv := fakeBybitAccount(t, &failing)
+	defer srv.Close()
+
+	books := orderbook.NewManager()
+	books.Apply(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 30990, BestAsk: 31010, BidSize: 1, AskSize: 1})
+	tracker := executor.NewTracker()
+	o := tracker.Open(transport.Action{Venue: "BINANCE", Symbol: "ETHUSDT", Side: "SELL", Size: 2})
+	if err := tracker.Fill(o.ID, 2000, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	acct := portfolio.NewBybitAccount("BYBIT", srv.URL, "linear", secrets.Credentials{Key: "key", Secret: "s3cret"})
+	pf := portfolio.New("USDT", portfolio.BookPrices(books, "USDT"), tracker, acct)
+	var views []transport.Portfolio
+	pf.SetPublisher(func(v transport.Portfolio) { views = append(views, v) })
+	pf.Poll(context.Background())
+
+	if len(views) != 1 {
+		t.Fatalf("published %d views", len(views))
+	}
+	v := views[0]
+	// 1000 USDT + 0.5 BTC at 31000 + 0.2 BTC long from 30000 marked at 31000
+	if v.Equity != 16700 || len(v.Assets) != 2 || v.Assets[0].Asset != "BTC" || v.Assets[0].Value != 15500 {
+		t.Fatalf("view = %+v", v)
+	}
+	if len(v.Positions) != 2 || v.Positions[0].Symbol != "BTCUSDT" || v.Positions[0].Unrealized != 200 ||
+		v.Positions[1].Venue != "BINANCE" || v.Positions[1].Qty != -2 {
+		t.Fatalf("positions = %+v", v.Positions)
+	}
+	if len(v.Unpriced) != 1 || v.Unpriced[0] != "ETHUSDT" {
+		t.Fatalf("unpriced = %v", v.Unpriced)
+	}
+
+	for _, tc := range []struct {
+		venue string
+		side  string
+		size  float64
+		want  bool
+	}{
+		{"BYBIT", "SELL", 0.4, true},
+		{"BYBIT", "SELL", 0.6, false},
+		{"BYBIT", "BUY", 0.02, true}, // 620 of 900 free USDT
+		{"BYBIT", "BUY", 0.05, false},
+		{"BINANCE", "BUY", 10, true}, // no account source
+	} {
+		if got := pf.Covers(tc.venue, transport.Action{Symbol: "BTCUSDT", Side: tc.side, Size: tc.size}, 31000); got != tc.want {
+			t.Errorf("%s %s %g covered = %t", tc.venue, tc.side, tc.size, got)
+		}
+	}
+
+	smart := router.NewSmartRouter(router.DefaultFees())
+	smart.SetInventory(pf.Covers)
+	books2 := map[string]router.BookView{"BYBIT": {BestBid: 31000, BestAsk: 31001}, "BINANCE": {BestBid: 30000, BestAsk: 31100}}
+	if d := smart.Decide(transport.Action{Symbol: "BTCUSDT", Side: "BUY", Size: 0.05}, books2); d.Venue != "BINANCE" {
+		t.Fatalf("routed to %s, want BINANCE since BYBIT lacks USDT", d.Venue)
+	}
+
+	// a failed poll keeps the previous holdings
+	failing = true
+	pf.Poll(context.Background())
+	if len(views) != 2 || views[1].Equity != 16700 {
+		t.Fatalf("after a failed poll: %+v", views[len(views)-1])
+	}
+}
+
+func TestPortfolioReconcile(t *testing.T) {
+	failing := false
+	srv := fakeBybitAccount(t, &failing)
+	defer srv.Close()
+
+	tracker := executor.NewTracker()
+	fill := func(venue, symbol, side string, qty float64) {
+		o := tracker.Open(transport.Action{Venue: venue, Symbol: symbol, Side: side, Size: qty})
+		if err := tracker.Fill(o.ID, 30000, qty); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fill("BYBIT", "BTCUSDT", "BUY", 0.15)
+	fill("BYBIT", "ETHUSDT", "SELL", 1)
+	fill("BINANCE", "BTCUSDT", "BUY", 1) // no account source: nothing to reconcile against
+
+	acct := portfolio.NewBybitAccount("BYBIT", srv.URL, "linear", secrets.Credentials{Key: "key", Secret: "s3cret"})
+	pf := portfolio.New("USDT", func(string) (float64, bool) { return 1, true }, tracker, acct)
+	var logs []string
+	pf.Logf = func(format string, args ...any) { logs = append(logs, fmt.Sprintf(format, args...)) }
+	pf.Poll(context.Background())
+
+	drift := pf.Reconcile()
+	if len(drift) != 2 ||
+		drift[0] != (portfolio.Drift{Venue: "BYBIT", Symbol: "BTCUSDT", Reported: 0.2, Tracked: 0.15, Delta: 0.05}) ||
+		drift[1] != (portfolio.Drift{Venue: "BYBIT", Symbol: "ETHUSDT", Reported: 0, Tracked: -1, Delta: 1}) {
+		t.Fatalf("drift = %+v", drift)
+	}
+	if len(logs) != 2 {
+		t.Fatalf("logs = %q", logs)
+	}
+	// unchanged drift is not logged again
+	pf.Poll(context.Background())
+	if len(logs) != 2 {
+		t.Fatalf("logs after a second poll = %q", logs)
+	}
+
+	var got admin.Reconciliation
+	h := (&admin.Server{Token: "secret", Portfolio: pf}).Handler()
+	if code := adminCall(t, h, "GET", "/v1/reconcile", "secret", "", &got); code != http.StatusOK {
+		t.Fatalf("reconcile: %d", code)
+	}
+	if got.Drifting != 2 || len(got.Positions) != 2 || len(got.Venues) != 1 || len(got.Venues[0].Balances) != 2 ||
+		got.Venues[0].CheckedAt.IsZero() || got.Positions[0].Delta != 0.05 {
+		t.Fatalf("reconciliation = %+v", got)
+	}
+
+	// booking the missing fills clears the drift
+	fill("BYBIT", "BTCUSDT", "BUY", 0.05)
+	fill("BYBIT", "ETHUSDT", "BUY", 1)
+	pf.Poll(context.Background())
+	for _, d := range pf.Reconcile() {
+		if d.Delta != 0 {
+			t.Fatalf("still drifting: %+v", d)
+		}
+	}
+	if len(logs) != 4 || !strings.Contains(logs[2], "reconciled") {
+		t.Fatalf("logs = %q", logs)
+	}
+}
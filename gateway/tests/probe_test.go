@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/probe"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+func probeGet(t *testing.T, mux *http.ServeMux, path string) (int, map[string]any) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%s: %v: %s", path, err, rec.Body.String())
+	}
+	return rec.Code, body
+}
+
+func TestProbes(t *testing.T) {
+	p := probe.New(50 * time.Millisecond)
+	mux := http.NewServeMux()
+	p.Handle(func(pattern string, h http.Handler) { mux.Handle(pattern, h) })
+
+	warm := errors.New("books not warm")
+	p.AddCheck("books", func() error { return warm })
+
+	if code, _ := probeGet(t, mux, "/live"); code != http.StatusOK {
+		t.Fatalf("live = %d, want 200", code)
+	}
+	code, body := probeGet(t, mux, "/ready")
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("ready = %d, want 503", code)
+	}
+	if failed := body["failed"].(map[string]any); failed["books"] != "books not warm" {
+		t.Fatalf("failed = %v", failed)
+	}
+
+	warm = nil
+	if code, body := probeGet(t, mux, "/ready"); code != http.StatusOK || body["status"] != "ok" {
+		t.Fatalf("ready = %d %v, want 200 ok", code, body)
+	}
+
+	time.Sleep(80 * time.Millisecond)
+	if code, _ := probeGet(t, mux, "/live"); code != http.StatusServiceUnavailable {
+		t.Fatalf("live after stall = %d, want 503", code)
+	}
+	p.Beat()
+	if code, _ := probeGet(t, mux, "/live"); code != http.StatusOK {
+		t.Fatalf("live after beat = %d, want 200", code)
+	}
+
+	p.Drain()
+	if code, body := probeGet(t, mux, "/ready"); code != http.StatusServiceUnavailable || body["failed"].(map[string]any)["draining"] == nil {
+		t.Fatalf("ready while draining = %d %v", code, body)
+	}
+	if code, _ := probeGet(t, mux, "/live"); code != http.StatusOK {
+		t.Fatalf("live while draining = %d, want 200", code)
+	}
+}
+
+func TestManagerCold(t *testing.T) {
+	m := orderbook.NewManager()
+	m.Apply(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 100, BestAsk: 101})
+	m.Apply(transport.DepthUpdate{Venue: "BYBIT", Symbol: "ETHUSDT", BestBid: 10})
+
+	cold := m.Cold("BYBIT", []string{"BTCUSDT", "ETHUSDT", "SOLUSDT"})
+	if len(cold) != 2 || cold[0] != "ETHUSDT" || cold[1] != "SOLUSDT" {
+		t.Fatalf("cold = %v, want [ETHUSDT SOLUSDT]", cold)
+	}
+	if cold := m.Cold("BINANCE", []string{"BTCUSDT"}); len(cold) != 1 {
+		t.Fatalf("other venue cold = %v", cold)
+	}
+}
@@ -0,0 +1,32 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/internal/app"
+)
+
+func TestRecorderProgress(t *testing.T) {
+	p := &app.Progress{Recorder: "l2", Topic: "orderbook.1.BTCUSDT", Reconnects: func() uint64 { return 2 }, Stale: func() uint64 { return 1 }}
+	t0 := time.UnixMilli(1_700_000_000_000)
+	if ev := p.Event("start", t0); ev.LagMs != -1 || ev.Rows != 0 || ev.MsgsPerSec != 0 {
+		t.Fatalf("start = %+v", ev)
+	}
+	p.Msgs.Add(50)
+	p.Rows.Add(120)
+	p.LastTsMs.Store(t0.Add(9750 * time.Millisecond).UnixMilli())
+	ev := p.Event("progress", t0.Add(10*time.Second))
+	if ev.Msgs != 50 || ev.Rows != 120 || ev.MsgsPerSec != 5 || ev.LagMs != 250 || ev.Reconnects != 2 || ev.Stale != 1 || ev.ElapsedS != 10 {
+		t.Fatalf("progress = %+v", ev)
+	}
+
+	var buf bytes.Buffer
+	p.Emit(&buf, "done")
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil || line["event"] != "done" || line["recorder"] != "l2" || line["rows"] != 120.0 {
+		t.Fatalf("line %q: %v", buf.String(), err)
+	}
+}
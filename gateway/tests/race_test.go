@@ -0,0 +1,7 @@
+//go:build race
+
+package tests
+
+// raceEnabled is set under -race, which allocates where a normal build
+// does not; allocation tests skip then.
+const raceEnabled = true
@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/instruments"
+	"github.com/helix-lab/helix/gateway/pkg/ratelimit"
+	"github.com/helix-lab/helix/gateway/pkg/router"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+func TestRateLimitHeaders(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 30, 0, time.UTC)
+	resp := func(code int, kv ...string) *http.Response {
+		r := &http.Response{StatusCode: code, Header: http.Header{}}
+		for i := 0; i < len(kv); i += 2 {
+			r.Header.Set(kv[i], kv[i+1])
+		}
+		return r
+	}
+
+	s, ok := ratelimit.Parse(resp(200, "X-Bapi-Limit", "10", "X-Bapi-Limit-Status", "3",
+		"X-Bapi-Limit-Reset-Timestamp", strconv.FormatInt(now.Add(time.Second).UnixMilli(), 10)), now)
+	if !ok || s.Limit != 10 || s.Remaining != 3 || !s.Reset.Equal(now.Add(time.Second)) {
+		t.Fatalf("bybit = %+v %t", s, ok)
+	}
+	s, ok = ratelimit.Parse(resp(200, "X-MBX-USED-WEIGHT-1M", "1150", "X-MBX-USED-WEIGHT-1S", "5"), now)
+	if !ok || s.Used != 1150 || s.Window != time.Minute || !s.Reset.Equal(now.Truncate(time.Minute).Add(time.Minute)) {
+		t.Fatalf("binance = %+v %t", s, ok)
+	}
+	if s, _ := ratelimit.Parse(resp(429, "Retry-After", "7"), now); s.RetryAfter != 7*time.Second {
+		t.Fatalf("retry after = %+v", s)
+	}
+	if _, ok := ratelimit.Parse(resp(200), now); ok {
+		t.Fatal("parsed a status out of no headers")
+	}
+
+	clock := now
+	l := ratelimit.New()
+	l.Now = func() time.Time { return clock }
+
+	// the static rate until the venue says otherwise
+	l.SetRate("BYBIT", "", 2)
+	if !l.Allow("BYBIT", "/v5/x") || !l.Allow("BYBIT", "/v5/x") || l.Allow("BYBIT", "/v5/x") {
+		t.Fatal("static rate of 2/s not applied")
+	}
+	clock = clock.Add(500 * time.Millisecond)
+	if !l.Allow("BYBIT", "/v5/x") {
+		t.Fatal("bucket did not refill")
+	}
+
+	// the venue's headers: one left (the reserve) until the reset
+	l.Observe("BYBIT", "/v5/y", ratelimit.Status{Limit: 50, Remaining: 1, Reset: clock.Add(800 * time.Millisecond)})
+	if l.Available("BYBIT", "/v5/y") {
+		t.Fatal("call allowed into the reserve")
+	}
+	clock = clock.Add(800 * time.Millisecond)
+	if !l.Allow("BYBIT", "/v5/y") {
+		t.Fatal("still throttled after the reset")
+	}
+
+	// Binance's used weight against the configured rate over its window
+	l.SetRate("BINANCE", "", 20) // 1200 weight a minute
+	l.Observe("BINANCE", "/api/v3/depth", ratelimit.Status{Used: 1199, Window: time.Minute, Reset: clock.Add(10 * time.Second)})
+	if l.Available("BINANCE", "/api/v3/depth") {
+		t.Fatal("binance weight exhausted but allowed")
+	}
+
+	// Retry-After holds everything back, and Wait gives up past its deadline
+	l.Observe("BYBIT", "/v5/x", ratelimit.Status{RetryAfter: time.Minute})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx, "BYBIT", "/v5/x"); !errors.Is(err, ratelimit.ErrThrottled) {
+		t.Fatalf("wait = %v", err)
+	}
+}
+
+func TestRateLimitFeedback(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		// one call left in a window that resets in 300ms
+		w.Header().Set("X-Bapi-Limit", "5")
+		w.Header().Set("X-Bapi-Limit-Status", "1")
+		w.Header().Set("X-Bapi-Limit-Reset-Timestamp", strconv.FormatInt(time.Now().Add(300*time.Millisecond).UnixMilli(), 10))
+		fmt.Fprint(w, `{"retCode":0,"result":{"list":[{"symbol":"BTCUSDT","status":"Trading"}]}}`)
+	}))
+	defer srv.Close()
+
+	l := ratelimit.New()
+	src := instruments.NewBybit("BYBIT", srv.URL, "linear")
+	src.Limits = l
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if _, err := src.Instruments(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls.Load() != 2 || time.Since(start) < 250*time.Millisecond {
+		t.Fatalf("%d calls in %v, want the second held until the reset", calls.Load(), time.Since(start))
+	}
+
+	// the executor refuses orders once the venue's order budget is spent
+	l.SetRate("BYBIT", ratelimit.Orders, 1)
+	sender := executor.NewOrderSender(transport.NewPublisher("inproc://ratelimit"), router.NewSmartRouter(router.DefaultFees()))
+	sender.SetRateLimiter(l)
+	books := map[string]router.BookView{"BYBIT": {BestBid: 100, BestAsk: 101}}
+	send := func() error {
+		_, err := sender.Send(context.Background(), transport.Action{Symbol: "BTCUSDT", Side: "BUY", Size: 1}, books)
+		return err
+	}
+	if err := send(); err != nil {
+		t.Fatal(err)
+	}
+	if err := send(); !errors.Is(err, ratelimit.ErrThrottled) {
+		t.Fatalf("second order in the second: %v", err)
+	}
+}
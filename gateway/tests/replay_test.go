@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/internal/app/replay"
+	"github.com/helix-lab/helix/gateway/pkg/capture"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+func TestReplayRebuildsBooksFromFrameLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tap.jsonl")
+	fl, err := capture.OpenFrameLog(path, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tap := fl.Tap("BYBIT")
+	base := time.Now().UnixNano()
+	frames := []string{
+		`{"op":"subscribe","success":true}`,
+		`{"topic":"orderbook.1.BTCUSDT","type":"snapshot","ts":1,"data":{"s":"BTCUSDT","b":[["100","1"]],"a":[["101","2"]]}}`,
+		`{"topic":"orderbook.1.BTCUSDT","type":"delta","ts":2,"data":{"s":"BTCUSDT","b":[["100.5","3"]],"a":[]}}`,
+		`{"topic":"allLiquidation.BTCUSDT","type":"snapshot","ts":3,"data":[{"T":3,"s":"BTCUSDT","S":"Sell","v":"0.5","p":"99"}]}`,
+	}
+	for i, f := range frames {
+		tap(base+int64(i)*int64(10*time.Millisecond), []byte(f))
+	}
+	fl.Tap("OTHER")(base, []byte(`{}`))
+	if err := fl.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var last transport.DepthUpdate
+	start := time.Now()
+	st, err := replay.Run(context.Background(), path, 1, func(u transport.DepthUpdate) { last = u })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Frames != 5 || st.Depth != 2 || st.Liquidations != 1 || st.Skipped != 2 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+	if last.BestBid != 100.5 || last.BestAsk != 101 || last.RecvNs != base+int64(20*time.Millisecond) {
+		t.Fatalf("unexpected final book %+v", last)
+	}
+	if time.Since(start) < 25*time.Millisecond {
+		t.Fatal("speed 1 should honour recorded gaps")
+	}
+}
@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/risk"
+	"github.com/helix-lab/helix/gateway/pkg/router"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+type riskEvents []transport.RiskState
+
+func (r *riskEvents) PublishRisk(s transport.RiskState) { *r = append(*r, s) }
+
+func TestRiskEngine(t *testing.T) {
+	books := orderbook.NewManager()
+	for _, u := range []transport.DepthUpdate{
+		{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 100, BestAsk: 101, BidSize: 5, AskSize: 5},
+		{Venue: "BYBIT", Symbol: "ETHUSDT", BestBid: 49, BestAsk: 51, BidSize: 5, AskSize: 5},
+	} {
+		books.Apply(u)
+	}
+	tracker := executor.NewTracker()
+	o := tracker.Open(transport.Action{Venue: "BYBIT", Symbol: "BTCUSDT", Side: "BUY", Size: 2})
+	if err := tracker.Fill(o.ID, 100, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	eng := risk.New(risk.Limits{MaxSymbolPosition: 3, MaxVenueNotional: 350, MaxDailyLoss: 50, MaxOrdersPerSec: 3},
+		tracker, risk.BookMarks(books))
+	sender := executor.NewOrderSender(transport.NewPublisher("inproc://risk"), router.NewSmartRouter(router.DefaultFees()))
+	var events riskEvents
+	eng.SetKillSwitch(sender)
+	eng.SetPublisher(&events)
+
+	for _, tc := range []struct {
+		action transport.Action
+		want   string // "" passes
+	}{
+		{transport.Action{Venue: "BINANCE", Symbol: "BTCUSDT", Side: "BUY", Size: 2}, "BTCUSDT position would be 4"},
+		{transport.Action{Venue: "BYBIT", Symbol: "ETHUSDT", Side: "BUY", Size: 1}, ""},
+		{transport.Action{Venue: "BYBIT", Symbol: "ETHUSDT", Side: "BUY", Size: 3}, "BYBIT notional would be 351.00"},
+		{transport.Action{Venue: "BINANCE", Symbol: "ETHUSDT", Side: "BUY", Size: 3}, ""},
+		{transport.Action{Venue: "BYBIT", Symbol: "XRPUSDT", Side: "BUY", Size: 1}, "no mark for XRPUSDT"},
+		// reducing always passes the exposure limits
+		{transport.Action{Venue: "BYBIT", Symbol: "BTCUSDT", Side: "SELL", Size: 2}, ""},
+		{transport.Action{Venue: "BYBIT", Symbol: "BTCUSDT", Side: "SELL", Size: 1}, "orders in the last second"},
+	} {
+		err := eng.Check(tc.action)
+		switch {
+		case tc.want == "" && err != nil:
+			t.Fatalf("%+v: %v", tc.action, err)
+		case tc.want != "" && (!errors.Is(err, risk.ErrLimit) || !strings.Contains(err.Error(), tc.want)):
+			t.Fatalf("%+v: got %v, want %q", tc.action, err, tc.want)
+		}
+	}
+
+	// the sender refuses what the engine refuses
+	sender.SetChecker(eng)
+	if _, err := sender.Send(context.Background(), transport.Action{Symbol: "BTCUSDT", Side: "BUY", Size: 5},
+		map[string]router.BookView{"BYBIT": {BestBid: 100, BestAsk: 101}}); !errors.Is(err, risk.ErrLimit) {
+		t.Fatalf("send = %v", err)
+	}
+
+	day := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	if st := eng.Evaluate(day); st.Unrealized != 1 || st.DailyPnL != 0 || st.GrossNotional != 201 || len(events) != 0 {
+		t.Fatalf("start of day = %+v, events %d", st, len(events))
+	}
+	books.Apply(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 69, BestAsk: 71, BidSize: 5, AskSize: 5})
+	st := eng.Evaluate(day.Add(time.Hour))
+	if st.DailyPnL != -61 || st.Breach != risk.BreachDailyLoss || !sender.KillSwitch().Armed || len(events) != 1 {
+		t.Fatalf("after the drop = %+v, kill switch %+v, events %d", st, sender.KillSwitch(), len(events))
+	}
+	eng.Evaluate(day.Add(2 * time.Hour))
+	eng.Publish(day.Add(2 * time.Hour))
+	if len(events) != 2 || events[1].Breach != risk.BreachDailyLoss {
+		t.Fatalf("events = %+v, want the breach once and the publish", events)
+	}
+	if st := eng.Evaluate(day.Add(24 * time.Hour)); st.Breach != "" || st.DailyPnL != 0 || len(events) != 3 {
+		t.Fatalf("next day = %+v", st)
+	}
+}
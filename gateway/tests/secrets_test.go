@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/helix-lab/helix/gateway/pkg/config"
+	"github.com/helix-lab/helix/gateway/pkg/secrets"
+)
+
+func TestSecretsEnvAndRedaction(t *testing.T) {
+	t.Setenv("HXTEST_API_KEY", "abcdef123")
+	t.Setenv("HXTEST_API_SECRET", "s3cr3t")
+	t.Setenv("HXTEST_HEDGE_API_KEY", "hedgekey")
+	t.Setenv("HXTEST_HEDGE_API_SECRET", "hedgesecret")
+	ctx := context.Background()
+
+	c, err := secrets.Resolve(ctx, "env:HXTEST", "")
+	if err != nil || c.Secret != "s3cr3t" {
+		t.Fatalf("default: %v %v", c, err)
+	}
+	if h, err := secrets.Resolve(ctx, "env:HXTEST", "hedge"); err != nil || h.Key != "hedgekey" {
+		t.Fatalf("hedge account: %v", err)
+	}
+	for _, s := range []string{fmt.Sprint(c), fmt.Sprintf("%+v", c), fmt.Sprintf("%#v", c)} {
+		if strings.Contains(s, "s3cr3t") || strings.Contains(s, "abcdef123") {
+			t.Fatalf("credentials leaked when printed: %s", s)
+		}
+	}
+	if _, err := secrets.Resolve(ctx, "env:HXMISSING", ""); err == nil {
+		t.Fatal("unset env should fail")
+	}
+	if _, err := secrets.Parse("sk-live-123"); err == nil {
+		t.Fatal("a literal key is not a reference")
+	}
+}
+
+func TestSecretsSealedFile(t *testing.T) {
+	key, err := secrets.NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(secrets.MasterKeyEnv, key)
+	raw, _ := base64.StdEncoding.DecodeString(key)
+	sealed, err := secrets.Seal([]byte(`{"default":{"api_key":"k1","api_secret":"s1"},"hedge":{"api_key":"k2","api_secret":"s2"}}`), raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(sealed), "s1") {
+		t.Fatal("sealed file contains plaintext")
+	}
+	path := filepath.Join(t.TempDir(), "keys.enc")
+	if err := os.WriteFile(path, sealed, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if c, err := secrets.Resolve(ctx, "file:"+path, ""); err != nil || c.Key != "k1" {
+		t.Fatalf("default account: %v %v", c, err)
+	}
+	if c, err := secrets.Resolve(ctx, "file:"+path+"#hedge", ""); err != nil || c.Key != "k2" {
+		t.Fatalf("#hedge: %v %v", c, err)
+	}
+	other, _ := secrets.NewKey()
+	t.Setenv(secrets.MasterKeyEnv, other)
+	if _, err := secrets.Resolve(ctx, "file:"+path, ""); err == nil || !strings.Contains(err.Error(), "decryption failed") {
+		t.Fatalf("wrong master key: %v", err)
+	}
+}
+
+func TestSecretsCommand(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "creds.sh")
+	body := "#!/bin/sh\necho \"{\\\"api_key\\\":\\\"cmd-$HELIX_ACCOUNT\\\",\\\"api_secret\\\":\\\"x\\\"}\"\n"
+	if err := os.WriteFile(script, []byte(body), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	c, err := secrets.Resolve(context.Background(), "cmd:"+script, "main")
+	if err != nil || c.Key != "cmd-main" {
+		t.Fatalf("cmd: %v %v", c, err)
+	}
+}
+
+func TestSecretsTokenFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "md.token")
+	if err := os.WriteFile(path, []byte("  tok-123\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if tok, err := secrets.TokenFile(path); err != nil || tok != "tok-123" {
+		t.Fatalf("token = %q, %v", tok, err)
+	}
+	empty := filepath.Join(dir, "empty.token")
+	if err := os.WriteFile(empty, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := secrets.TokenFile(empty); err == nil {
+		t.Fatal("an empty token file should fail")
+	}
+}
+
+func TestConfigCredentialRefs(t *testing.T) {
+	cfg, err := config.Load("../../config/gateway.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	by := cfg.Gateway.Venues[0]
+	if ref, err := by.CredentialRef("hedge"); err != nil || ref != "file:/etc/helix/keys.enc#bybit-hedge" {
+		t.Fatalf("hedge ref = %q, %v", ref, err)
+	}
+	if _, err := by.CredentialRef("nope"); err == nil {
+		t.Fatal("unknown account should fail")
+	}
+	doc := "gateway:\n  symbols: [BTCUSDT]\n  venues:\n  - name: X\n    accounts: {main: plaintext-key}\n"
+	if _, err := config.Parse([]byte(doc), false); err == nil || !strings.Contains(err.Error(), "accounts.main") {
+		t.Fatalf("literal account secret accepted: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/config"
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/router"
+	"github.com/helix-lab/helix/gateway/pkg/shadow"
+	"github.com/helix-lab/helix/gateway/pkg/strategy"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+func TestShadowDivergence(t *testing.T) {
+	cfg, err := config.Parse([]byte(`
+gateway:
+  symbols: [ETHUSDT]
+  venues:
+  - name: BYBIT
+  strategies:
+  - name: taker
+    kind: demo
+  shadows:
+  - name: taker-v2
+    of: taker
+    kind: demo
+    symbols: [ETHUSDT]
+    timer: 500ms
+`), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sh := cfg.Gateway.Shadows[0]; sh.Of != "taker" || sh.Name != "taker-v2" || time.Duration(sh.Timer) != 500*time.Millisecond {
+		t.Fatalf("shadow = %+v", sh)
+	}
+	if _, err := config.Parse([]byte(`
+gateway:
+  symbols: [ETHUSDT]
+  venues:
+  - name: BYBIT
+  shadows:
+  - name: taker-v2
+    of: taker
+    kind: demo
+`), false); err == nil {
+		t.Fatal("a shadow of no production strategy should fail")
+	}
+
+	books := orderbook.NewManager()
+	runner := shadow.New(books, router.DefaultFees(), executor.Limits{})
+	var out bytes.Buffer
+	runner.Record(&out)
+	rec := &recordingStrategy{}
+	runner.Add("taker", strategy.Config{Name: "taker-v2", Symbols: []string{"ETHUSDT"}}, rec)
+
+	// production buys, as the candidate will, then sells alone
+	now := time.Now().UnixNano()
+	runner.Route(transport.RouteDecision{Account: "taker", Symbol: "ETHUSDT", Side: "BUY", Size: 2, Venue: "BYBIT", Price: 11, TsNs: now}, nil)
+	runner.Route(transport.RouteDecision{Account: "taker", Symbol: "ETHUSDT", Side: "SELL", Size: 1, Venue: "BYBIT", Price: 10, TsNs: now}, nil)
+	runner.Route(transport.RouteDecision{Account: "other", Symbol: "ETHUSDT", Side: "BUY", Size: 1, TsNs: now}, nil)
+
+	ctx := context.Background()
+	u := transport.DepthUpdate{Venue: "BYBIT", Symbol: "ETHUSDT", BestBid: 10, BestAsk: 11, BidSize: 5, AskSize: 5}
+	books.Apply(u)
+	runner.Depth(ctx, u)
+	if len(rec.ids) != 1 {
+		t.Fatalf("candidate orders = %v", rec.ids)
+	}
+	select {
+	case <-runner.Ready():
+	case <-time.After(time.Second):
+		t.Fatal("no paper fill")
+	}
+	runner.Fills(ctx)
+	if len(rec.fills) != 1 || rec.fills[0].Price != 11 {
+		t.Fatalf("candidate fills = %+v", rec.fills)
+	}
+
+	report := runner.Report([]strategy.Status{{Name: "taker", Realized: 3}})
+	if len(report) != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	d := report[0]
+	if d.Production != "taker" || d.Candidate != "taker-v2" || d.ProductionOrders != 2 || d.CandidateOrders != 1 ||
+		d.Matched != 1 || d.OnlyProduction != 1 || d.OnlyCandidate != 0 || d.MatchRate != 0.5 || d.SizeDiff != 1 {
+		t.Fatalf("divergence = %+v", d)
+	}
+	if d.ProductionPnL != 3 || d.FirstDivergenceNs != now {
+		t.Fatalf("pnl/first divergence = %+v", d)
+	}
+	if err := runner.Close(); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], `"run":"production"`) || !strings.Contains(lines[2], `"run":"shadow"`) {
+		t.Fatalf("decisions:\n%s", out.String())
+	}
+}
@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/helix-lab/helix/gateway/pkg/ws"
+)
+
+func TestSimConnectorIsSeedDeterministic(t *testing.T) {
+	cfg := ws.DefaultSimConfig("SIM")
+	cfg.Symbols = []string{"BTCUSDT", "ETHUSDT"}
+	cfg.Seed = 42
+	cfg.BurstProb = 0.1
+	cfg.OutageProb = 0.05
+	cfg.OutageSteps = 3
+
+	a, b := ws.NewSimConnector(cfg), ws.NewSimConnector(cfg)
+	for i := 0; i < 500; i++ {
+		if ua, ub := a.Step(), b.Step(); !reflect.DeepEqual(ua, ub) {
+			t.Fatalf("step %d diverged: %+v vs %+v", i, ua, ub)
+		}
+	}
+	if a.Bursts() == 0 || a.Outages() == 0 {
+		t.Fatalf("expected injected bursts and outages, got bursts=%d outages=%d", a.Bursts(), a.Outages())
+	}
+}
+
+func TestSimConnectorOUQuotesStayValid(t *testing.T) {
+	cfg := ws.DefaultSimConfig("SIM")
+	cfg.Process = "ou"
+	cfg.StartPrice = 100
+	cfg.MeanReversion = 2
+	cfg.Vol = 0.5
+	cfg.TickSize = 0.01
+	cfg.SpreadJitter = 0.5
+	sim := ws.NewSimConnector(cfg)
+
+	for i := 0; i < 2000; i++ {
+		for _, u := range sim.Step() {
+			if !(u.BestBid > 0 && u.BestBid < u.BestAsk) {
+				t.Fatalf("step %d crossed or non-positive quote: %+v", i, u)
+			}
+			if u.BidSize <= 0 || u.AskSize <= 0 {
+				t.Fatalf("step %d non-positive size: %+v", i, u)
+			}
+			if mid := (u.BestBid + u.BestAsk) / 2; mid < 90 || mid > 110 {
+				t.Fatalf("step %d OU mid wandered to %.4f", i, mid)
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/state"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+func TestStateRoundTrip(t *testing.T) {
+	books := orderbook.NewManager()
+	books.Apply(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 100, BestAsk: 101, BidSize: 2, AskSize: 3})
+	books.Apply(transport.DepthUpdate{Venue: "BINANCE", Symbol: "BTCUSDT", BestBid: 99.5, BestAsk: 100.5})
+
+	tr := executor.NewTracker()
+	a := tr.Open(transport.Action{Venue: "BYBIT", Symbol: "BTCUSDT", Side: "BUY", Size: 2})
+	tr.Open(transport.Action{Venue: "BYBIT", Symbol: "BTCUSDT", Side: "SELL", Size: 1})
+	if err := tr.Fill(a.ID, 100, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "gateway.state")
+	if _, err := state.Load(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("load missing = %v, want ErrNotExist", err)
+	}
+	err := state.Save(path, state.State{Books: books.Books(), Orders: tr.OpenOrders(), Positions: tr.Positions(),
+		Subscriptions: map[string][]string{"BYBIT": {"BTCUSDT", "ETHUSDT"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Fatalf("temporary files left behind: %v", entries)
+	}
+
+	st, err := state.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Age() > time.Minute || len(st.Books) != 2 || st.Books[1].Venue != "BYBIT" || st.Books[1].BidSize != 2 {
+		t.Fatalf("books = %+v", st.Books)
+	}
+	if len(st.Subscriptions["BYBIT"]) != 2 {
+		t.Fatalf("subscriptions = %v", st.Subscriptions)
+	}
+
+	restored := executor.NewTracker()
+	restored.Restore(st.Orders, st.Positions)
+	if open := restored.OpenOrders(); len(open) != 2 || open[0].Filled != 1 {
+		t.Fatalf("open orders = %+v", open)
+	}
+	if pos := restored.Positions(); len(pos) != 1 || pos[0].Qty != 1 || pos[0].AvgPrice != 100 {
+		t.Fatalf("positions = %+v", pos)
+	}
+	if next := restored.Open(transport.Action{Venue: "BYBIT", Symbol: "BTCUSDT", Side: "BUY", Size: 1}); next.ID != "hx-3" {
+		t.Fatalf("next ID = %s, want hx-3", next.ID)
+	}
+	if err := restored.Fill(a.ID, 101, 1); err != nil {
+		t.Fatalf("fill restored order: %v", err)
+	}
+}
+
+func TestStateVersionAndRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gateway.state")
+	if err := os.WriteFile(path, []byte(`{"version":99}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := state.Load(path); err == nil {
+		t.Fatal("loaded a state file of an unknown version")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	calls := 0
+	go func() {
+		defer close(done)
+		state.Run(ctx, path, time.Hour, func() state.State {
+			calls++
+			return state.State{Positions: []executor.Position{{Venue: "BYBIT", Symbol: "BTCUSDT", Qty: 1}}}
+		}, t.Logf)
+	}()
+	cancel()
+	<-done
+	if calls != 1 {
+		t.Fatalf("captures = %d, want the final save only", calls)
+	}
+	st, err := state.Load(path)
+	if err != nil || len(st.Positions) != 1 {
+		t.Fatalf("state after shutdown = %+v, %v", st, err)
+	}
+}
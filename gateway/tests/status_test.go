@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/router"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+	"github.com/helix-lab/helix/gateway/pkg/ws"
+)
+
+func TestStatusMonitorMarksMaintenance(t *testing.T) {
+	begin := time.Now().Add(30 * time.Second).UnixMilli()
+	end := time.Now().Add(time.Hour).UnixMilli()
+	bybit := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"retCode":0,"retMsg":"OK","result":{"list":[
+			{"title":"old","state":"completed","begin":"1","end":"2"},
+			{"title":"upgrade","state":"scheduled","begin":"%d","end":"%d"}]}}`, begin, end)
+	}))
+	defer bybit.Close()
+	binance := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":0,"msg":"normal"}`)
+	}))
+	defer binance.Close()
+
+	bs := ws.NewBybitStatus()
+	bs.URL = bybit.URL
+	ns := ws.NewBinanceStatus()
+	ns.URL = binance.URL
+	mon := ws.NewStatusMonitor(time.Minute, bs, ns)
+
+	mon.Poll(context.Background())
+	if mon.InMaintenance("BYBIT") {
+		t.Fatal("scheduled window 30s out should not count without lead time")
+	}
+	mon.Lead = time.Minute
+	mon.Poll(context.Background())
+	st, _ := mon.Status("BYBIT")
+	if !st.Maintenance || st.Reason != "upgrade" || st.Until.UnixMilli() != end {
+		t.Fatalf("expected BYBIT maintenance within lead, got %+v", st)
+	}
+	if mon.InMaintenance("BINANCE") {
+		t.Fatal("BINANCE reported normal")
+	}
+
+	smart := router.NewSmartRouter(router.DefaultFees())
+	smart.SetAvailability(func(v string) bool { return !mon.InMaintenance(v) })
+	books := map[string]router.BookView{
+		"BYBIT":   {BestBid: 100, BestAsk: 100.1},
+		"BINANCE": {BestBid: 99, BestAsk: 105},
+	}
+	if venue := smart.Route(transport.Action{Side: "BUY"}, books); venue != "BINANCE" {
+		t.Fatalf("router must skip venue in maintenance, routed to %s", venue)
+	}
+}
+
+func TestSmartRouterDecide(t *testing.T) {
+	smart := router.NewSmartRouter(router.FeeModel{Taker: map[string]float64{"A": 0.01, "B": 0}})
+	books := map[string]router.BookView{"A": {BestBid: 100, BestAsk: 100}, "B": {BestBid: 99.5, BestAsk: 100.5}}
+	d := smart.Decide(transport.Action{Side: "BUY"}, books)
+	if d.Venue != "B" || d.Price != 100.5 || d.Prices["A"] != 101 {
+		t.Fatalf("buy decision = %+v", d)
+	}
+	if d := smart.Decide(transport.Action{Side: "HOLD"}, books); d.Venue != "SIM" {
+		t.Fatalf("unknown side = %+v", d)
+	}
+}
@@ -0,0 +1,282 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/router"
+	"github.com/helix-lab/helix/gateway/pkg/stratapi"
+	"github.com/helix-lab/helix/gateway/pkg/strategy"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+func TestStrategyAPI(t *testing.T) {
+	books := orderbook.NewManager()
+	tracker := executor.NewTracker()
+	sender := executor.NewOrderSender(transport.NewPublisher("inproc://stratapi"), router.NewSmartRouter(router.DefaultFees()))
+	sender.SetTracker(tracker)
+	host := strategy.NewHost(books, sender, tracker)
+	api, err := stratapi.New([]stratapi.Client{{Name: "alpha", Token: "secret", Symbols: []string{"BTCUSDT"},
+		Limits: stratapi.Limits{MaxOrderSize: 2, MaxPosition: 2}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.Strategies(host.Add)
+	if err := api.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer api.Close()
+	addr := api.Addr().String()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	refused := func(token string, want codes.Code) {
+		t.Helper()
+		c, err := stratapi.Dial(ctx, addr, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		if _, err := c.Recv(); status.Code(err) != want {
+			t.Fatalf("token %q: %v, want %s", token, err, want)
+		}
+	}
+	refused("wrong", codes.Unauthenticated)
+	conn, err := stratapi.Dial(ctx, addr, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	read := func() stratapi.Message {
+		t.Helper()
+		m, err := conn.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	if m := read(); m.Type != stratapi.TypeHello || m.Client != "alpha" || strings.Join(m.Symbols, ",") != "BTCUSDT" {
+		t.Fatalf("hello = %+v", m)
+	}
+	refused("secret", codes.AlreadyExists)
+
+	// ETHUSDT is filtered by the host; BTCUSDT reaches the client
+	for _, u := range []transport.DepthUpdate{
+		{Venue: "BYBIT", Symbol: "ETHUSDT", BestBid: 10, BestAsk: 11, BidSize: 1, AskSize: 1},
+		{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 100, BestAsk: 101, BidSize: 5, AskSize: 5},
+	} {
+		books.Apply(u)
+		host.Book(ctx, u)
+	}
+	if m := read(); m.Type != stratapi.TypeBook || m.Book.Symbol != "BTCUSDT" || m.Book.NBBO.BestAsk != 101 || m.Book.Venues["BYBIT"].BidSize != 5 {
+		t.Fatalf("book = %+v", m)
+	}
+	host.Trade(ctx, transport.Trade{TsMs: 7, Venue: "BYBIT", Symbol: "BTCUSDT", Side: "SELL", Price: 100, Qty: 0.5})
+	if m := read(); m.Type != stratapi.TypeTrade || m.Trade.TsMs != 7 || m.Trade.Qty != 0.5 || m.Trade.Side != "SELL" {
+		t.Fatalf("trade = %+v", m.Trade)
+	}
+	host.Bar(ctx, transport.Bar{Venue: "BYBIT", Symbol: "BTCUSDT", Interval: time.Minute, StartMs: 60000, Open: 1, High: 3, Low: 0.5, Close: 2, Trades: 4})
+	if m := read(); m.Type != stratapi.TypeBar || m.Bar.Interval != "1m0s" || m.Bar.Low != 0.5 || m.Bar.Trades != 4 {
+		t.Fatalf("bar = %+v", m.Bar)
+	}
+
+	// submit sends an order and runs it the way the gateway loop does
+	submit := func(ref string, o stratapi.Order) stratapi.Message {
+		t.Helper()
+		if err := conn.Send(stratapi.Message{Type: stratapi.TypeSubmit, Ref: ref, Order: &o}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case sub := <-api.Submits():
+			api.Exec(ctx, sub, host)
+		case <-ctx.Done():
+			t.Fatal("no submit")
+		}
+		return read()
+	}
+	ack := submit("a1", stratapi.Order{Symbol: "btcusdt", Side: "buy", Size: 1})
+	if ack.Type != stratapi.TypeAck || ack.Ref != "a1" || ack.OrderID == "" {
+		t.Fatalf("ack = %+v", ack)
+	}
+	if err := host.Fill(ctx, transport.Fill{OrderID: ack.OrderID, Venue: "BYBIT", Symbol: "BTCUSDT", Side: "BUY", Price: 101, Qty: 1, Maker: true}); err != nil {
+		t.Fatal(err)
+	}
+	if m := read(); m.Type != stratapi.TypeFill || m.Fill.OrderID != ack.OrderID || m.Fill.Position != 1 || !m.Fill.Maker {
+		t.Fatalf("fill = %+v", m)
+	}
+
+	for _, tc := range []struct {
+		order stratapi.Order
+		want  string
+	}{
+		{stratapi.Order{Symbol: "BTCUSDT", Side: "BUY", Size: 3}, "max order size"},
+		{stratapi.Order{Symbol: "BTCUSDT", Side: "BUY", Size: 2}, "max position"},
+		{stratapi.Order{Symbol: "ETHUSDT", Side: "BUY", Size: 1}, "may not trade"},
+		{stratapi.Order{Symbol: "BTCUSDT", Side: "HOLD", Size: 1}, "side"},
+	} {
+		if m := submit("r", tc.order); m.Type != stratapi.TypeReject || !strings.Contains(m.Error, tc.want) {
+			t.Fatalf("%+v: got %+v, want a reject mentioning %q", tc.order, m, tc.want)
+		}
+	}
+	// reducing is allowed past the position limit
+	if m := submit("a2", stratapi.Order{Symbol: "BTCUSDT", Side: "SELL", Size: 2}); m.Type != stratapi.TypeAck {
+		t.Fatalf("sell = %+v", m)
+	}
+	// open orders count toward the limit: a2 is unfilled, so the client
+	// stands at -1 and a burst of sells is refused once it would pass -2
+	for i, want := range []string{stratapi.TypeAck, stratapi.TypeReject} {
+		if m := submit("b", stratapi.Order{Symbol: "BTCUSDT", Side: "SELL", Size: 1}); m.Type != want ||
+			want == stratapi.TypeReject && !strings.Contains(m.Error, "max position") {
+			t.Fatalf("burst sell %d = %+v, want %s", i, m, want)
+		}
+	}
+	// a frame that is not a submit is answered, not queued
+	if err := conn.Send(stratapi.Message{Type: stratapi.TypeAck, Ref: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if m := read(); m.Type != stratapi.TypeReject || m.Ref != "x" {
+		t.Fatalf("bad frame = %+v", m)
+	}
+}
+
+// strategyProto is the part of strategy.proto a client in another
+// language would generate stubs from: Message up to its book, Order,
+// Level and Book.
+func strategyProto(t *testing.T) protoreflect.FileDescriptor {
+	t.Helper()
+	field := func(name string, n int32, typ descriptorpb.FieldDescriptorProto_Type, msg string, repeated bool) *descriptorpb.FieldDescriptorProto {
+		label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+		if repeated {
+			label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+		}
+		f := &descriptorpb.FieldDescriptorProto{Name: &name, Number: &n, Type: typ.Enum(), Label: label.Enum(), JsonName: &name}
+		if msg != "" {
+			f.TypeName = &msg
+		}
+		return f
+	}
+	const (
+		str = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		dbl = descriptorpb.FieldDescriptorProto_TYPE_DOUBLE
+		msg = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	)
+	message := func(name string, fields ...*descriptorpb.FieldDescriptorProto) *descriptorpb.DescriptorProto {
+		return &descriptorpb.DescriptorProto{Name: &name, Field: fields}
+	}
+	entry := message("VenuesEntry", field("key", 1, str, "", false), field("value", 2, msg, ".helix.strategy.v1.Level", false))
+	entry.Options = &descriptorpb.MessageOptions{MapEntry: new(bool)}
+	*entry.Options.MapEntry = true
+	book := message("Book", field("symbol", 1, str, "", false), field("nbbo", 2, msg, ".helix.strategy.v1.Level", false),
+		field("venues", 3, msg, ".helix.strategy.v1.Book.VenuesEntry", true), field("category", 4, str, "", false))
+	book.NestedType = []*descriptorpb.DescriptorProto{entry}
+	name, pkg, syntax := "strategy.proto", "helix.strategy.v1", "proto3"
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{Name: &name, Package: &pkg, Syntax: &syntax,
+		MessageType: []*descriptorpb.DescriptorProto{
+			message("Message", field("type", 1, str, "", false), field("ref", 2, str, "", false), field("order_id", 3, str, "", false),
+				field("error", 4, str, "", false), field("client", 5, str, "", false), field("symbols", 6, str, "", true),
+				field("order", 7, msg, ".helix.strategy.v1.Order", false), field("book", 8, msg, ".helix.strategy.v1.Book", false)),
+			message("Order", field("symbol", 1, str, "", false), field("side", 2, str, "", false), field("size", 3, dbl, "", false)),
+			message("Level", field("best_bid", 1, dbl, "", false), field("best_ask", 2, dbl, "", false),
+				field("bid_size", 3, dbl, "", false), field("ask_size", 4, dbl, "", false)),
+			book,
+		}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd
+}
+
+func TestStrategyAPIProtobufClient(t *testing.T) {
+	books := orderbook.NewManager()
+	tracker := executor.NewTracker()
+	sender := executor.NewOrderSender(transport.NewPublisher("inproc://stratapi-pb"), router.NewSmartRouter(router.DefaultFees()))
+	sender.SetTracker(tracker)
+	host := strategy.NewHost(books, sender, tracker)
+	api, err := stratapi.New([]stratapi.Client{{Name: "beta", Token: "pb-secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.Strategies(host.Add)
+	if err := api.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer api.Close()
+
+	// a stock gRPC client with messages from the .proto, no stratapi code
+	fd := strategyProto(t)
+	msgDesc := fd.Messages().ByName("Message")
+	fields := msgDesc.Fields()
+	cc, err := grpc.NewClient(api.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := cc.NewStream(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer pb-secret"),
+		&grpc.StreamDesc{ServerStreams: true, ClientStreams: true}, stratapi.Method)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recv := func() *dynamicpb.Message {
+		t.Helper()
+		m := dynamicpb.NewMessage(msgDesc)
+		if err := stream.RecvMsg(m); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	if m := recv(); m.Get(fields.ByName("type")).String() != stratapi.TypeHello || m.Get(fields.ByName("client")).String() != "beta" {
+		t.Fatalf("hello = %v", m)
+	}
+
+	u := transport.DepthUpdate{Venue: "BINANCE", Symbol: "ETHUSDT", Category: "spot", BestBid: 10, BestAsk: 11, BidSize: 2, AskSize: 3}
+	books.Apply(u)
+	host.Book(ctx, u)
+	m := recv()
+	book := m.Get(fields.ByName("book")).Message()
+	bookFields := book.Descriptor().Fields()
+	venues := book.Get(bookFields.ByName("venues")).Map()
+	lvl := venues.Get(protoreflect.ValueOfString("BINANCE").MapKey())
+	if m.Get(fields.ByName("type")).String() != stratapi.TypeBook || book.Get(bookFields.ByName("symbol")).String() != "ETHUSDT" ||
+		book.Get(bookFields.ByName("category")).String() != "spot" || !lvl.IsValid() ||
+		lvl.Message().Get(lvl.Message().Descriptor().Fields().ByName("ask_size")).Float() != 3 {
+		t.Fatalf("book = %v", m)
+	}
+
+	sub := dynamicpb.NewMessage(msgDesc)
+	sub.Set(fields.ByName("type"), protoreflect.ValueOfString(stratapi.TypeSubmit))
+	sub.Set(fields.ByName("ref"), protoreflect.ValueOfString("pb1"))
+	order := sub.Mutable(fields.ByName("order")).Message()
+	of := order.Descriptor().Fields()
+	order.Set(of.ByName("symbol"), protoreflect.ValueOfString("ETHUSDT"))
+	order.Set(of.ByName("side"), protoreflect.ValueOfString("BUY"))
+	order.Set(of.ByName("size"), protoreflect.ValueOfFloat64(1))
+	if err := stream.SendMsg(sub); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case s := <-api.Submits():
+		api.Exec(ctx, s, host)
+	case <-ctx.Done():
+		t.Fatal("no submit")
+	}
+	if m := recv(); m.Get(fields.ByName("type")).String() != stratapi.TypeAck || m.Get(fields.ByName("ref")).String() != "pb1" ||
+		m.Get(fields.ByName("order_id")).String() == "" {
+		t.Fatalf("ack = %v", m)
+	}
+}
@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/config"
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/router"
+	"github.com/helix-lab/helix/gateway/pkg/strategy"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+type recordingStrategy struct {
+	strategy.Base
+	books []string
+	fills []transport.Fill
+	ids   []string
+}
+
+func (r *recordingStrategy) OnBook(ctx context.Context, h strategy.Handle, b strategy.Book) {
+	r.books = append(r.books, b.Symbol)
+	if len(r.ids) == 0 {
+		id, err := h.Submit(ctx, transport.Action{Symbol: b.Symbol, Side: "BUY", Size: 1})
+		if err == nil {
+			r.ids = append(r.ids, id)
+		}
+	}
+}
+
+func (r *recordingStrategy) OnFill(_ context.Context, _ strategy.Handle, f transport.Fill) {
+	r.fills = append(r.fills, f)
+}
+
+func TestStrategyHost(t *testing.T) {
+	books := orderbook.NewManager()
+	tracker := executor.NewTracker()
+	sender := executor.NewOrderSender(transport.NewPublisher("inproc://strategy"), router.NewSmartRouter(router.DefaultFees()))
+	sender.SetTracker(tracker)
+	host := strategy.NewHost(books, sender, tracker)
+
+	rec := &recordingStrategy{}
+	host.Add(strategy.Config{Name: "rec", Symbols: []string{"ETHUSDT"}}, rec)
+	demo, err := strategy.New("demo", strategy.Config{Name: "demo", Params: map[string]any{"rounds": 2.0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	host.Add(strategy.Config{Name: "demo", Timer: time.Second}, demo)
+	if host.Interval() != time.Second {
+		t.Fatalf("interval = %s", host.Interval())
+	}
+
+	ctx := context.Background()
+	for _, u := range []transport.DepthUpdate{
+		{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 100, BestAsk: 101, BidSize: 1, AskSize: 1},
+		{Venue: "BYBIT", Symbol: "ETHUSDT", BestBid: 10, BestAsk: 11, BidSize: 1, AskSize: 1},
+	} {
+		books.Apply(u)
+		host.Book(ctx, u)
+	}
+	if len(rec.books) != 1 || rec.books[0] != "ETHUSDT" || len(rec.ids) != 1 {
+		t.Fatalf("symbol filter: books=%v ids=%v", rec.books, rec.ids)
+	}
+	if err := host.Fill(ctx, transport.Fill{OrderID: rec.ids[0], Venue: "BYBIT", Symbol: "ETHUSDT", Price: 11, Qty: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.fills) != 1 || tracker.Exposure("BYBIT", "ETHUSDT") != 1 {
+		t.Fatalf("fill not delivered: %+v", rec.fills)
+	}
+
+	now := time.Now()
+	host.Timer(ctx, now)
+	host.Timer(ctx, now.Add(500*time.Millisecond)) // not due yet
+	host.Timer(ctx, now.Add(time.Second))
+	// the demo sent BTC and ETH twice; rec's strategy never finishes
+	if n := len(tracker.OpenOrders()); n != 4 {
+		t.Fatalf("open orders = %d, want 4", n)
+	}
+	select {
+	case <-host.Done():
+		t.Fatal("host done while rec is running")
+	default:
+	}
+}
+
+func TestStrategyConfig(t *testing.T) {
+	base := `
+gateway:
+  symbols: [BTCUSDT]
+  venues:
+  - name: SIM
+  strategies:
+  - name: taker
+    kind: demo
+    timer: 250ms
+    params: {size: 0.5, side: SELL}
+`
+	bad := base + `
+  - name: taker
+    kind: ""
+    symbols: [DOGEUSDT]
+`
+	if _, err := config.Parse([]byte(bad), false); err == nil {
+		t.Fatal("expected duplicate name, missing kind and unknown symbol errors")
+	}
+	cfg, err := config.Parse([]byte(base), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := cfg.Gateway.Strategies[0]
+	if time.Duration(st.Timer) != 250*time.Millisecond || st.Params["side"] != "SELL" {
+		t.Fatalf("strategy = %+v", st)
+	}
+	if _, err := strategy.New("demo", strategy.Config{Name: "x", Params: map[string]any{"size": "big"}}); err == nil {
+		t.Fatal("non-numeric size should fail")
+	}
+	if _, err := strategy.New("nope", strategy.Config{}); err == nil {
+		t.Fatal("unknown kind should fail")
+	}
+}
@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/tape"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+func TestTapeRollingFlow(t *testing.T) {
+	tp := tape.New(tape.Config{Windows: []time.Duration{time.Minute, 10 * time.Second}, MinTrades: 3})
+	const t0 = int64(1_700_000_000_000)
+	trade := func(dtMs int64, side string, qty, price float64) (transport.LargePrint, bool) {
+		return tp.Trade(transport.Trade{Venue: "BYBIT", Symbol: "BTCUSDT", Side: side, Price: price, Qty: qty, TsMs: t0 + dtMs})
+	}
+	trade(0, "Buy", 1, 100)
+	trade(1000, "Sell", 1, 101)
+	if _, large := trade(2000, "BUY", 2, 102); large {
+		t.Fatal("large print before MinTrades")
+	}
+	lp, large := trade(15_000, "Buy", 20, 103)
+	if !large || lp.Venue != "BYBIT" || math.Abs(lp.Multiple-20/(4.0/3)) > 1e-9 {
+		t.Fatalf("large print = %+v, %v", lp, large)
+	}
+	tp.Trade(transport.Trade{Venue: "BINANCE", Symbol: "ETHUSDT", Side: "SELL", Price: 10, Qty: 5, TsMs: t0 + 15_000})
+
+	st := tp.Stats()
+	if len(st) != 4 || st[0].Symbol != "BTCUSDT" || st[0].Window != 10*time.Second || st[2].Symbol != "ETHUSDT" {
+		t.Fatalf("stats = %+v", st)
+	}
+	// the 10s window has rolled past the first three trades
+	if s := st[0]; s.BuyVolume != 20 || s.BuyTrades != 1 || s.SellTrades != 0 || s.LargePrints != 1 || s.Imbalance != 1 || s.TsMs != t0+15_000 {
+		t.Fatalf("10s = %+v", s)
+	}
+	s := st[1]
+	if s.BuyVolume != 23 || s.SellVolume != 1 || s.BuyTrades != 3 || s.SellTrades != 1 || s.LargePrints != 1 {
+		t.Fatalf("1m = %+v", s)
+	}
+	if want := (100 + 101 + 204 + 2060) / 24.0; math.Abs(s.VWAP-want) > 1e-9 || math.Abs(s.Imbalance-22.0/24) > 1e-9 {
+		t.Fatalf("1m vwap=%v imbalance=%v", s.VWAP, s.Imbalance)
+	}
+
+	// a quiet market empties the windows
+	tp.Advance(t0 + 80_000)
+	for _, s := range tp.Stats() {
+		if s.BuyTrades+s.SellTrades != 0 || s.BuyVolume != 0 || s.VWAP != 0 || s.TsMs != t0+80_000 {
+			t.Fatalf("after a quiet minute: %+v", s)
+		}
+	}
+
+	// an absolute threshold flags regardless of history
+	abs := tape.New(tape.Config{Windows: []time.Duration{time.Minute}, LargeQty: 5})
+	if _, large := abs.Trade(transport.Trade{Symbol: "BTCUSDT", Side: "Sell", Price: 1, Qty: 5, TsMs: t0}); !large {
+		t.Fatal("LargeQty not applied")
+	}
+}
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/helix-lab/helix/gateway/pkg/executor"
+	"github.com/helix-lab/helix/gateway/pkg/router"
+	"github.com/helix-lab/helix/gateway/pkg/tracing"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+)
+
+func TestOrderPathSpansExportOTLP(t *testing.T) {
+	type span struct {
+		TraceID      string `json:"traceId"`
+		SpanID       string `json:"spanId"`
+		ParentSpanID string `json:"parentSpanId"`
+		Name         string `json:"name"`
+	}
+	var mu sync.Mutex
+	var got []span
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []span `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		for _, rs := range body.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				got = append(got, ss.Spans...)
+			}
+		}
+		mu.Unlock()
+	}))
+	defer collector.Close()
+
+	exp := tracing.NewOTLPExporter(collector.URL, "helix-test")
+	tracing.SetTracer(tracing.NewTracer(exp, 1))
+	defer tracing.SetTracer(nil)
+
+	sender := executor.NewOrderSender(transport.NewPublisher("inproc://test"), router.NewSmartRouter(router.DefaultFees()))
+	sender.Send(context.Background(), transport.Action{Symbol: "BTCUSDT", Side: "BUY", Size: 1},
+		map[string]router.BookView{"BYBIT": {BestBid: 100, BestAsk: 101}})
+	exp.Shutdown()
+
+	mu.Lock()
+	defer mu.Unlock()
+	byName := map[string]span{}
+	for _, s := range got {
+		byName[s.Name] = s
+	}
+	root, ok := byName["executor.send"]
+	if !ok || root.ParentSpanID != "" {
+		t.Fatalf("missing root span, got %+v", got)
+	}
+	for _, child := range []string{"router.route", "transport.publish_action"} {
+		s, ok := byName[child]
+		if !ok || s.ParentSpanID != root.SpanID || s.TraceID != root.TraceID {
+			t.Fatalf("%s not a child of executor.send: %+v", child, got)
+		}
+	}
+	if exp.Failed() != 0 {
+		t.Fatalf("export failed for %d spans", exp.Failed())
+	}
+}
+
+func TestUnsampledRootSuppressesChildren(t *testing.T) {
+	exp := tracing.NewOTLPExporter("http://127.0.0.1:1/unused", "helix-test")
+	defer exp.Shutdown()
+	tracing.SetTracer(tracing.NewTracer(exp, 1))
+	defer tracing.SetTracer(nil)
+
+	never := tracing.NewTracer(exp, 0)
+	ctx, root := never.Start(context.Background(), "tick")
+	if root != nil {
+		t.Fatal("ratio 0 must not sample")
+	}
+	if _, child := tracing.Start(ctx, "apply"); child != nil {
+		t.Fatal("child of unsampled root must not start a new trace")
+	}
+}
@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helix-lab/helix/gateway/pkg/latency"
+	"github.com/helix-lab/helix/gateway/pkg/orderbook"
+	"github.com/helix-lab/helix/gateway/pkg/transport"
+	"github.com/helix-lab/helix/gateway/pkg/tsdb"
+)
+
+func TestTSDBLineProtocol(t *testing.T) {
+	p := tsdb.Point{
+		Measurement: "helix book",
+		Tags:        map[string]string{"venue": "BY,BIT", "symbol": "BTC=USDT", "empty": ""},
+		Fields:      map[string]float64{"mid": 100.5, "bid": 100},
+		Time:        time.Unix(1, 5),
+	}
+	want := `helix\ book,symbol=BTC\=USDT,venue=BY\,BIT bid=100,mid=100.5 1000000005` + "\n"
+	if got := string(tsdb.AppendLine(nil, p)); got != want {
+		t.Fatalf("line = %q, want %q", got, want)
+	}
+}
+
+func TestTSDBPushInflux(t *testing.T) {
+	var query, auth, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		query, auth, body = r.URL.RawQuery, r.Header.Get("Authorization"), string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	books := orderbook.NewManager()
+	books.Apply(transport.DepthUpdate{Venue: "BYBIT", Symbol: "BTCUSDT", BestBid: 100, BestAsk: 101, BidSize: 3, AskSize: 1})
+	books.Apply(transport.DepthUpdate{Venue: "BINANCE", Symbol: "BTCUSDT", BestBid: 100.5, BestAsk: 102, BidSize: 1, AskSize: 1})
+	reg := latency.NewRegistry()
+	lat := tsdb.Latency(reg)
+	reg.Observe("tick_to_trade", latency.Labels{Venue: "BYBIT"}, 2*time.Millisecond)
+
+	pusher := &tsdb.Pusher{
+		Writer:  &tsdb.Influx{URL: srv.URL + "/api/v2/write?org=o&bucket=b", Token: "secret"},
+		Sources: []tsdb.Source{tsdb.Books(books), lat},
+	}
+	pusher.Push(context.Background(), time.Unix(10, 0))
+
+	if !strings.Contains(query, "precision=ns") || !strings.Contains(query, "bucket=b") || auth != "Token secret" {
+		t.Fatalf("query=%s auth=%q", query, auth)
+	}
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("lines:\n%s", body)
+	}
+	if want := "helix_book,symbol=BTCUSDT,venue=BYBIT ask=101,bid=100,imbalance=0.5,mid=100.5,spread_bps="; !strings.HasPrefix(lines[1], want) {
+		t.Fatalf("venue line = %s", lines[1])
+	}
+	if !strings.HasPrefix(lines[2], "helix_book,symbol=BTCUSDT,venue=NBBO ask=101,bid=100.5,imbalance=0,mid=100.75,") {
+		t.Fatalf("nbbo line = %s", lines[2])
+	}
+	if !strings.HasPrefix(lines[3], "helix_latency,op=tick_to_trade,venue=BYBIT count=1,") || !strings.HasSuffix(lines[3], " 10000000000") {
+		t.Fatalf("latency line = %s", lines[3])
+	}
+
+	// latency series report only what is new since the last push
+	body = ""
+	pusher.Sources = []tsdb.Source{lat}
+	pusher.Push(context.Background(), time.Unix(20, 0))
+	if body != "" {
+		t.Fatalf("pushed with nothing new:\n%s", body)
+	}
+}
@@ -1,9 +1,11 @@
 package tests
 
 import (
+	"context"
 	"testing"
 	"time"
 
+	"github.com/helix-lab/helix/gateway/pkg/transport"
 	"github.com/helix-lab/helix/gateway/pkg/ws"
 )
 
@@ -18,3 +20,148 @@ func TestRouterEmits(t *testing.T) {
 		t.Fatal("no updates received")
 	}
 }
+
+func TestRouterConflatesWhenConsumerStalls(t *testing.T) {
+	cfg := ws.DefaultRouterConfig()
+	cfg.Buffer = 1
+	cfg.Policy = ws.PolicyConflate
+	r := ws.NewRouterWithConfig(cfg)
+	feed := &symbolsFeed{venue: "A", symbols: []string{"BTCUSDT", "ETHUSDT"}, limit: 100, done: make(chan struct{})}
+	r.Add(feed)
+	r.Start()
+	defer r.Stop()
+
+	// the router's intake is unbuffered, so once the feed has handed over
+	// its last update the router has queued every one before it
+	select {
+	case <-feed.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("feed stalled under conflation")
+	}
+	st := r.Backpressure()
+	if st.Conflated == 0 {
+		t.Fatalf("expected conflation while stalled, got %+v", st)
+	}
+	if st.Backlog > 2 {
+		t.Fatalf("conflated backlog should hold at most one update per book, got %d", st.Backlog)
+	}
+}
+
+func TestRouterFansOutToSubscribers(t *testing.T) {
+	r := ws.NewRouter()
+	extra := r.Subscribe("strategy", 64, false)
+	slow := r.Subscribe("metrics", 1, true)
+	r.Start()
+	defer r.Stop()
+
+	for i := 0; i < 5; i++ {
+		select {
+		case <-r.Updates():
+		case <-time.After(2 * time.Second):
+			t.Fatal("primary starved")
+		}
+		select {
+		case <-extra.C():
+		case <-time.After(2 * time.Second):
+			t.Fatal("second lossless subscriber starved")
+		}
+	}
+
+	var slowStats ws.SubscriberStats
+	for _, st := range r.Subscribers() {
+		if st.Name == "metrics" {
+			slowStats = st
+		}
+	}
+	if slowStats.Dropped == 0 {
+		t.Fatalf("lossy subscriber that never reads should drop, got %+v", slowStats)
+	}
+	if len(slow.C()) != 1 {
+		t.Fatalf("lossy subscriber should hold its one buffered update")
+	}
+}
+
+// symbolsFeed streams top-of-book for its symbols, round robin, as fast as
+// the router takes them. With limit it stops after that many updates and
+// closes done.
+type symbolsFeed struct {
+	venue   string
+	symbols []string
+	limit   int
+	done    chan struct{}
+}
+
+func (f *symbolsFeed) Venue() string { return f.venue }
+
+func (f *symbolsFeed) Run(ctx context.Context, out ws.Feeds) {
+	for i := 0; ; i++ {
+		if f.limit > 0 && i == f.limit {
+			close(f.done)
+			return
+		}
+		sym := f.symbols[i%len(f.symbols)]
+		select {
+		case out.DepthFor(sym) <- transport.DepthUpdate{Venue: f.venue, Symbol: sym, BestBid: float64(i), BestAsk: float64(i + 1)}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func TestRouterShardsBySymbol(t *testing.T) {
+	const shards = 4
+	// two symbols on different shards
+	busy, other := "BTCUSDT", ""
+	for _, sym := range []string{"ETHUSDT", "SOLUSDT", "XRPUSDT", "DOGEUSDT", "ADAUSDT"} {
+		if ws.ShardOf(sym, shards) != ws.ShardOf(busy, shards) {
+			other = sym
+			break
+		}
+	}
+	if other == "" {
+		t.Fatal("no symbol on another shard")
+	}
+	if ws.ShardOf(busy, 1) != 0 {
+		t.Fatal("a single shard must take every symbol")
+	}
+
+	for _, policy := range []ws.Policy{ws.PolicyBlock, ws.PolicyConflate} {
+		cfg := ws.DefaultRouterConfig()
+		cfg.Buffer, cfg.Policy, cfg.Shards = 1, policy, shards
+		r := ws.NewRouterWithConfig(cfg)
+		if policy == ws.PolicyBlock {
+			// a stalled lane still blocks a connector feeding it, so one per symbol
+			r.Add(&symbolsFeed{venue: "A", symbols: []string{busy}})
+			r.Add(&symbolsFeed{venue: "B", symbols: []string{other}})
+		} else {
+			r.Add(&symbolsFeed{venue: "A", symbols: []string{busy, other}})
+		}
+		r.Start()
+
+		// nobody drains busy's shard; other's must keep flowing
+		lanes := r.Shards()
+		if len(lanes) != shards {
+			t.Fatalf("%s: %d shards, want %d", policy, len(lanes), shards)
+		}
+		lane := lanes[ws.ShardOf(other, shards)]
+		for i := 0; i < 200; i++ {
+			select {
+			case u := <-lane:
+				if u.Symbol != other {
+					t.Fatalf("%s: %s arrived on %s's shard", policy, u.Symbol, other)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("%s: %s starved behind a stalled shard after %d updates", policy, other, i)
+			}
+		}
+		r.Stop()
+
+		names := map[string]bool{}
+		for _, st := range r.Subscribers() {
+			names[st.Name] = true
+		}
+		if !names["shard0"] || !names["shard3"] {
+			t.Fatalf("%s: subscribers = %v, want the shards", policy, names)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"nhooyr.io/websocket"
+
+	"github.com/helix-lab/helix/gateway/pkg/wsclient"
+)
+
+// mockVenue accepts websocket clients, records subscribe requests and lets the
+// test decide what each session sends.
+type mockVenue struct {
+	mu        sync.Mutex
+	subs      [][]string
+	onSession func(ctx context.Context, c *websocket.Conn, n int)
+}
+
+func (m *mockVenue) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer c.CloseNow()
+
+	_, data, err := c.Read(r.Context())
+	if err != nil {
+		return
+	}
+	var req struct {
+		Op   string   `json:"op"`
+		Args []string `json:"args"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil || req.Op != "subscribe" {
+		return
+	}
+	m.mu.Lock()
+	m.subs = append(m.subs, req.Args)
+	n := len(m.subs)
+	m.mu.Unlock()
+
+	ctx := c.CloseRead(r.Context())
+	m.onSession(ctx, c, n)
+}
+
+func (m *mockVenue) subscriptions() [][]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([][]string(nil), m.subs...)
+}
+
+func wsURL(srv *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func TestWSClientResubscribesAfterDrop(t *testing.T) {
+	mock := &mockVenue{}
+	mock.onSession = func(ctx context.Context, c *websocket.Conn, n int) {
+		_ = c.Write(ctx, websocket.MessageText, []byte(`{"n":1}`))
+		if n == 1 {
+			// drop the first session to force a reconnect
+			return
+		}
+		<-ctx.Done()
+	}
+	srv := httptest.NewServer(mock)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	frames := make(chan string, 8)
+	var reconnectErrs atomic.Int32
+	client := wsclient.New(wsclient.Config{
+		Endpoint:    wsURL(srv),
+		Topics:      []string{"orderbook.1.BTCUSDT", "publicTrade.BTCUSDT"},
+		BackoffBase: 10 * time.Millisecond,
+		BackoffMax:  20 * time.Millisecond,
+		OnMessage: func(frame []byte) bool {
+			frames <- string(frame)
+			return true
+		},
+		OnReconnect: func(err error) {
+			if err != nil {
+				reconnectErrs.Add(1)
+			}
+		},
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = client.Run(ctx)
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-frames:
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for frame %d", i)
+		}
+	}
+	cancel()
+	<-done
+
+	subs := mock.subscriptions()
+	if len(subs) < 2 {
+		t.Fatalf("expected resubscribe after reconnect, got %d subscribes", len(subs))
+	}
+	for _, s := range subs {
+		if len(s) != 2 || s[0] != "orderbook.1.BTCUSDT" || s[1] != "publicTrade.BTCUSDT" {
+			t.Fatalf("unexpected subscribe args: %v", s)
+		}
+	}
+	if client.Reconnects() == 0 || reconnectErrs.Load() == 0 {
+		t.Fatalf("reconnects = %d, OnReconnect with an error = %d", client.Reconnects(), reconnectErrs.Load())
+	}
+}
+
+func TestWSClientStaleStreamReconnects(t *testing.T) {
+	mock := &mockVenue{}
+	mock.onSession = func(ctx context.Context, c *websocket.Conn, n int) {
+		// acks only, never any data
+		_ = c.Write(ctx, websocket.MessageText, []byte(`{"success":true,"op":"subscribe"}`))
+		<-ctx.Done()
+	}
+	srv := httptest.NewServer(mock)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	client := wsclient.New(wsclient.Config{
+		Endpoint:    wsURL(srv),
+		Topics:      []string{"publicTrade.BTCUSDT"},
+		StaleAfter:  100 * time.Millisecond,
+		BackoffBase: 10 * time.Millisecond,
+		BackoffMax:  20 * time.Millisecond,
+		OnMessage:   func(frame []byte) bool { return false },
+	})
+	go client.Run(ctx)
+
+	for client.Reconnects() < 2 {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("stale stream was not recycled, reconnects=%d", client.Reconnects())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if client.Stale() == 0 || client.Stale() > client.Reconnects() {
+		t.Fatalf("stale = %d of %d reconnects", client.Stale(), client.Reconnects())
+	}
+}
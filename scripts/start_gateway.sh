@@ -1,4 +1,4 @@
 #!/usr/bin/env bash
 set -euo pipefail
 
-go run ./gateway/cmd/gateway "$@"
+go run ./gateway/cmd/helix gateway "$@"